@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// actionOutputMsg reports new output from, or the exit of, an action run.
+type actionOutputMsg struct {
+	run *ActionRun
+}
+
+// waitAction delivers an actionOutputMsg when run has more to show.
+func waitAction(run *ActionRun) tea.Cmd {
+	return func() tea.Msg {
+		run.Wait()
+		return actionOutputMsg{run: run}
+	}
+}
+
+// newActionPane returns the scrollable pane action output is shown in.
+func newActionPane() viewport.Model {
+	return viewport.New(80, 20)
+}
+
+// cursorPeer returns the peer under the cursor on the Peers tab.
+func (m Model) cursorPeer() *PeerSummary {
+	row := m.peerTable.SelectedRow()
+	if row == nil {
+		return nil
+	}
+	peers := m.visiblePeers()
+	for i := range peers {
+		if peers[i].Address == rowAddress(row[0]) {
+			return &peers[i]
+		}
+	}
+	return nil
+}
+
+// openActions shows the action menu for p.
+func (m *Model) openActions(p *PeerSummary) {
+	if len(m.actions) == 0 {
+		m.setStatus("No actions configured (actions in the config)")
+		return
+	}
+	if p == nil {
+		return
+	}
+	m.actionPeer = p
+	m.actionReturn = m.activeView
+	m.activeView = "actions"
+}
+
+// handleActionKey handles keys while the action menu or output pane is
+// open. The number keys run an action from the menu; Esc closes the menu,
+// or stops the running action and closes its pane.
+func (m Model) handleActionKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key {
+	case "esc":
+		if m.actionRun != nil {
+			m.actionRun.Cancel()
+			m.actionRun = nil
+		}
+		m.activeView = m.actionReturn
+		return m, nil
+	case "q":
+		m.quitting = true
+		if m.actionRun != nil {
+			m.actionRun.Cancel()
+		}
+		return m, tea.Quit
+	}
+
+	if m.activeView == "output" {
+		var cmd tea.Cmd
+		m.actionPane, cmd = m.actionPane.Update(msg)
+		return m, cmd
+	}
+
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 1 || i > len(m.actions) {
+		return m, nil
+	}
+	run, err := m.actions[i-1].Start(*m.actionPeer)
+	if err != nil {
+		m.setStatus(err.Error())
+		return m, nil
+	}
+	m.actionRun = run
+	m.actionPane.SetContent("")
+	m.actionPane.GotoTop()
+	m.activeView = "output"
+	return m, waitAction(run)
+}
+
+// updateActionOutput shows new output of the current run, following it
+// while the pane is scrolled to the bottom.
+func (m *Model) updateActionOutput(msg actionOutputMsg) tea.Cmd {
+	if msg.run != m.actionRun {
+		return nil // closed or replaced
+	}
+	follow := m.actionPane.AtBottom()
+	m.actionPane.SetContent(msg.run.Output())
+	if follow {
+		m.actionPane.GotoBottom()
+	}
+	if done, _ := msg.run.Finished(); done {
+		return nil
+	}
+	return waitAction(msg.run)
+}
+
+// renderActionMenu lists the actions that can run on the selected peer.
+func (m Model) renderActionMenu() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Actions on " + m.actionPeer.Address))
+	b.WriteString("\n\n")
+	for i, a := range m.actions[:min(len(m.actions), 9)] {
+		argv, err := a.CommandLine(*m.actionPeer)
+		line := strings.Join(argv, " ")
+		if err != nil {
+			line = err.Error()
+		}
+		b.WriteString(fmt.Sprintf("  %d  %-16s %s\n", i+1, a.Name(), detailLabel.Render(line)))
+	}
+	return b.String()
+}
+
+// renderActionOutput shows the running or finished action and its output.
+func (m Model) renderActionOutput() string {
+	var b strings.Builder
+	run := m.actionRun
+	b.WriteString(headerStyle.Render(run.Action + ": " + strings.Join(run.Argv, " ")))
+	b.WriteString("  ")
+	switch done, err := run.Finished(); {
+	case !done:
+		b.WriteString(detailLabel.Render("running"))
+	case err != nil:
+		b.WriteString(warnStyle.Render(err.Error()))
+	default:
+		b.WriteString(detailLabel.Render("done"))
+	}
+	b.WriteString("\n\n")
+	b.WriteString(m.actionPane.View())
+	return b.String()
+}
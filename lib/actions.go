@@ -0,0 +1,280 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	// defaultActionTimeout bounds each run of a peer action.
+	defaultActionTimeout = 30 * time.Second
+	// maxActionOutput caps the output kept per run; the rest is dropped.
+	maxActionOutput = 1 << 20
+)
+
+// ActionConfig is an external command the TUI can run on the selected peer,
+// e.g. ping6 or nmap. Args are text/template strings over the peer
+// ({{.Address}}, {{.Zoned}}, {{.MAC}}, {{.Interface}}, ...); the same
+// fields are in the command's environment as NDPEEKR_PEER_*.
+type ActionConfig struct {
+	Name    string        `yaml:"name"`
+	Command string        `yaml:"command"`
+	Args    []string      `yaml:"args"`
+	Timeout time.Duration `yaml:"timeout"` // per run (default 30s)
+}
+
+// PeerAction is a compiled ActionConfig.
+type PeerAction struct {
+	cfg  ActionConfig
+	args []*template.Template
+}
+
+// NewPeerAction validates cfg and compiles its argument templates.
+func NewPeerAction(cfg ActionConfig) (*PeerAction, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+	args, err := parseExecArgs(cfg.Args)
+	if err != nil {
+		return nil, fmt.Errorf("args: %w", err)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultActionTimeout
+	}
+	return &PeerAction{cfg: cfg, args: args}, nil
+}
+
+// Name returns the action's name.
+func (a *PeerAction) Name() string {
+	return a.cfg.Name
+}
+
+// actionPeer is what action templates see: the peer plus derived fields.
+type actionPeer struct {
+	PeerSummary
+	// Zoned is Address with the interface as its zone for link-local
+	// addresses (fe80::1%eth0), which is what tools like ping6 need.
+	Zoned string
+}
+
+// newActionPeer derives the template data for p.
+func newActionPeer(p PeerSummary) actionPeer {
+	ap := actionPeer{PeerSummary: p, Zoned: p.Address}
+	addr, err := netip.ParseAddr(p.Address)
+	if err == nil && addr.IsLinkLocalUnicast() && addr.Zone() == "" && p.Interface != "" {
+		ap.Zoned = addr.WithZone(p.Interface).String()
+	}
+	return ap
+}
+
+// CommandLine renders the command the action would run on p.
+func (a *PeerAction) CommandLine(p PeerSummary) ([]string, error) {
+	data := newActionPeer(p)
+	argv := []string{a.cfg.Command}
+	for _, t := range a.args {
+		var b bytes.Buffer
+		if err := t.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("action %s: %w", a.cfg.Name, err)
+		}
+		argv = append(argv, b.String())
+	}
+	return argv, nil
+}
+
+// Start runs the action on p in the background. Its output is collected in
+// the returned ActionRun until the command exits, times out or is cancelled.
+func (a *PeerAction) Start(p PeerSummary) (*ActionRun, error) {
+	argv, err := a.CommandLine(p)
+	if err != nil {
+		return nil, err
+	}
+	env, err := peerEnv(newActionPeer(p))
+	if err != nil {
+		return nil, fmt.Errorf("action %s: %w", a.cfg.Name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.Timeout)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	run := &ActionRun{
+		Action:  a.cfg.Name,
+		Argv:    argv,
+		cancel:  cancel,
+		updated: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	cmd.Stdout = run
+	cmd.Stderr = run
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("action %s: %w", a.cfg.Name, err)
+	}
+	go func() {
+		err := cmd.Wait()
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", a.cfg.Timeout)
+		}
+		cancel()
+		run.mu.Lock()
+		run.err = err
+		run.mu.Unlock()
+		close(run.done)
+	}()
+	return run, nil
+}
+
+// StartBatch runs the action on each of peers in turn in the background,
+// collecting the output of every run under a header line naming the peer.
+// The timeout applies to each run; a failed run doesn't stop the rest.
+func (a *PeerAction) StartBatch(peers []PeerSummary) (*ActionRun, error) {
+	argvs := make([][]string, len(peers))
+	envs := make([][]string, len(peers))
+	for i, p := range peers {
+		argv, err := a.CommandLine(p)
+		if err != nil {
+			return nil, err
+		}
+		env, err := peerEnv(newActionPeer(p))
+		if err != nil {
+			return nil, fmt.Errorf("action %s: %w", a.cfg.Name, err)
+		}
+		argvs[i], envs[i] = argv, env
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &ActionRun{
+		Action:  a.cfg.Name,
+		Argv:    []string{fmt.Sprintf("on %d peers", len(peers))},
+		cancel:  cancel,
+		updated: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		failed := 0
+		for i, p := range peers {
+			if ctx.Err() != nil {
+				break
+			}
+			fmt.Fprintf(run, "== %s: %s\n", p.Address, strings.Join(argvs[i], " "))
+			runCtx, runCancel := context.WithTimeout(ctx, a.cfg.Timeout)
+			cmd := exec.CommandContext(runCtx, argvs[i][0], argvs[i][1:]...)
+			cmd.Env = append(os.Environ(), envs[i]...)
+			cmd.Stdout = run
+			cmd.Stderr = run
+			err := cmd.Run()
+			if runCtx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("timed out after %s", a.cfg.Timeout)
+			}
+			runCancel()
+			if err != nil {
+				failed++
+				fmt.Fprintf(run, "[%v]\n", err)
+			}
+		}
+		var err error
+		if failed > 0 {
+			err = fmt.Errorf("%d of %d runs failed", failed, len(peers))
+		}
+		cancel()
+		run.mu.Lock()
+		run.err = err
+		run.mu.Unlock()
+		close(run.done)
+	}()
+	return run, nil
+}
+
+// ActionRun is a running or finished peer action.
+type ActionRun struct {
+	Action string
+	Argv   []string
+
+	cancel  context.CancelFunc
+	updated chan struct{} // signalled when output arrives
+	done    chan struct{} // closed when the command has exited
+
+	mu        sync.Mutex
+	out       bytes.Buffer
+	truncated bool
+	err       error
+}
+
+// Write collects the command's output.
+func (r *ActionRun) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	if room := maxActionOutput - r.out.Len(); room < len(p) {
+		r.out.Write(p[:max(room, 0)])
+		r.truncated = true
+	} else {
+		r.out.Write(p)
+	}
+	r.mu.Unlock()
+	select {
+	case r.updated <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// Output returns the output so far.
+func (r *ActionRun) Output() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.truncated {
+		return r.out.String() + "\n[output truncated]\n"
+	}
+	return r.out.String()
+}
+
+// Finished reports whether the command has exited, and how.
+func (r *ActionRun) Finished() (bool, error) {
+	select {
+	case <-r.done:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return true, r.err
+	default:
+		return false, nil
+	}
+}
+
+// Wait blocks until there is new output or the command has exited.
+func (r *ActionRun) Wait() {
+	select {
+	case <-r.updated:
+	case <-r.done:
+	}
+}
+
+// Cancel kills the command if it is still running.
+func (r *ActionRun) Cancel() {
+	r.cancel()
+}
+
+// peerEnv passes the peer's main fields to the command as environment
+// variables, plus the whole summary as JSON.
+func peerEnv(p actionPeer) ([]string, error) {
+	js, err := json.Marshal(p.PeerSummary)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"NDPEEKR_PEER_ADDRESS=" + p.Address,
+		"NDPEEKR_PEER_ZONED=" + p.Zoned,
+		"NDPEEKR_PEER_MAC=" + p.MAC,
+		"NDPEEKR_PEER_INTERFACE=" + p.Interface,
+		"NDPEEKR_PEER_JSON=" + string(js),
+	}, nil
+}
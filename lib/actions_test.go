@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPeerAction_Run(t *testing.T) {
+	p := PeerSummary{Address: "fe80::1", MAC: "aa:bb:cc:dd:ee:ff", Interface: "eth0"}
+	a, err := NewPeerAction(ActionConfig{
+		Name:    "echo",
+		Command: "/bin/sh",
+		Args:    []string{"-c", `echo "$0 $NDPEEKR_PEER_MAC"; echo oops >&2`, "{{.Zoned}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	argv, err := a.CommandLine(p)
+	if err != nil || argv[len(argv)-1] != "fe80::1%eth0" {
+		t.Fatalf("command line = %q, %v; want the zoned address last", argv, err)
+	}
+
+	run, err := a.Start(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for done, _ := run.Finished(); !done; done, _ = run.Finished() {
+		run.Wait()
+	}
+	if _, err := run.Finished(); err != nil {
+		t.Errorf("run failed: %v", err)
+	}
+	if got := run.Output(); !strings.Contains(got, "fe80::1%eth0 aa:bb:cc:dd:ee:ff\n") || !strings.Contains(got, "oops\n") {
+		t.Errorf("output = %q, want stdout and stderr", got)
+	}
+
+	slow, err := NewPeerAction(ActionConfig{Name: "slow", Command: "/bin/sleep", Args: []string{"5"}, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err = slow.Start(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-run.done
+	if _, err := run.Finished(); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+
+	for name, cfg := range map[string]ActionConfig{
+		"no name":    {Command: "ping6"},
+		"no command": {Name: "ping"},
+		"bad args":   {Name: "ping", Command: "ping6", Args: []string{"{{.Address"}},
+	} {
+		if _, err := NewPeerAction(cfg); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+func TestModel_PeerAction(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("2001:db8::1", "neighbor_solicitation")
+	a, err := NewPeerAction(ActionConfig{Name: "echo", Command: "/bin/echo", Args: []string{"hello {{.Address}}"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewModel(ModelConfig{Stats: stats, Actions: []*PeerAction{a}})
+	press := func(key string) {
+		next, cmd := m.handleKey(keyMsg(key))
+		m = next.(Model)
+		for cmd != nil {
+			msg, ok := cmd().(actionOutputMsg)
+			if !ok {
+				break
+			}
+			next, cmd = m.Update(msg)
+			m = next.(Model)
+		}
+	}
+
+	press("x")
+	if m.activeView != "actions" || m.actionPeer.Address != "2001:db8::1" {
+		t.Fatalf("view = %q, peer = %+v; want the action menu", m.activeView, m.actionPeer)
+	}
+	press("1")
+	if m.activeView != "output" || !strings.Contains(m.actionPane.View(), "hello 2001:db8::1") {
+		t.Errorf("view = %q, pane = %q; want the echo output", m.activeView, m.actionPane.View())
+	}
+	press("esc")
+	if m.activeView != "table" || m.actionRun != nil {
+		t.Errorf("view = %q after esc, want the table", m.activeView)
+	}
+}
@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// sleepGap is the silence after which a host is considered to have been
+	// asleep rather than merely quiet.
+	sleepGap = 5 * time.Minute
+	// alwaysOnAfter is how long a host must be watched without a gap before
+	// it is classified as always-on.
+	alwaysOnAfter = time.Hour
+	// activityRetention is how long a silent MAC's history is kept; it has to
+	// outlive the peer window so sleepy hosts are recognised when they wake.
+	activityRetention = 24 * time.Hour
+	// maxWakeHistory bounds the wake times kept per MAC.
+	maxWakeHistory = 16
+	// periodicJitter is the largest spread between wake intervals, relative
+	// to their median, for a host to count as periodic.
+	periodicJitter = 0.25
+)
+
+// Activity classifications.
+const (
+	ActivityAlwaysOn = "always-on"
+	ActivityPeriodic = "periodic"
+	ActivitySleepy   = "sleepy"
+)
+
+// macActivity is the sleep/wake history of one link-layer address.
+type macActivity struct {
+	firstSeen time.Time
+	lastSeen  time.Time
+	wakes     []time.Time // start of each active period after a gap, oldest first
+}
+
+// touchActivityLocked records activity from mac at now. Callers must hold s.mu.
+func (s *NDPStats) touchActivityLocked(mac string, now time.Time) {
+	if mac == "" {
+		return
+	}
+	a, ok := s.activity[mac]
+	if !ok {
+		s.activity[mac] = &macActivity{firstSeen: now, lastSeen: now}
+		return
+	}
+	if now.Sub(a.lastSeen) >= sleepGap {
+		a.wakes = append(a.wakes, now)
+		if len(a.wakes) > maxWakeHistory {
+			a.wakes = a.wakes[len(a.wakes)-maxWakeHistory:]
+		}
+	}
+	if now.After(a.lastSeen) {
+		a.lastSeen = now
+	}
+}
+
+// classify returns the activity class and, for periodic and sleepy hosts, the
+// typical interval between wake-ups. It returns "" until there is enough history.
+func (a *macActivity) classify(now time.Time) (string, time.Duration) {
+	if len(a.wakes) == 0 {
+		if a.lastSeen.Sub(a.firstSeen) >= alwaysOnAfter && now.Sub(a.lastSeen) < sleepGap {
+			return ActivityAlwaysOn, 0
+		}
+		return "", 0
+	}
+	if len(a.wakes) < 2 {
+		return ActivitySleepy, 0
+	}
+
+	intervals := make([]time.Duration, 0, len(a.wakes)-1)
+	for i := 1; i < len(a.wakes); i++ {
+		intervals = append(intervals, a.wakes[i].Sub(a.wakes[i-1]))
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+	median := intervals[len(intervals)/2]
+
+	// Periodic needs at least three evenly spaced wake-ups.
+	if len(intervals) >= 2 {
+		spread := intervals[len(intervals)-1] - intervals[0]
+		if float64(spread) <= periodicJitter*float64(median) {
+			return ActivityPeriodic, median
+		}
+	}
+	return ActivitySleepy, median
+}
+
+// activityLocked returns the classification for mac. Callers must hold s.mu.
+func (s *NDPStats) activityLocked(mac string, now time.Time) (string, time.Duration) {
+	a, ok := s.activity[mac]
+	if !ok {
+		return "", 0
+	}
+	return a.classify(now)
+}
+
+// pruneActivityLocked forgets MACs silent for longer than activityRetention.
+// Callers must hold s.mu.
+func (s *NDPStats) pruneActivityLocked(now time.Time) {
+	for mac, a := range s.activity {
+		if now.Sub(a.lastSeen) > activityRetention {
+			delete(s.activity, mac)
+		}
+	}
+}
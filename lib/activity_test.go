@@ -0,0 +1,65 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActivityClassification(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		seen         []time.Duration // activity offsets from t0
+		now          time.Duration
+		wantClass    string
+		wantInterval time.Duration
+	}{
+		{
+			name:      "too new",
+			seen:      []time.Duration{0, time.Minute},
+			now:       2 * time.Minute,
+			wantClass: "",
+		},
+		{
+			name:      "one gap",
+			seen:      []time.Duration{0, 2 * time.Minute, 30 * time.Minute},
+			now:       31 * time.Minute,
+			wantClass: ActivitySleepy, // no interval yet
+		},
+		{
+			name:         "periodic sensor",
+			seen:         []time.Duration{0, 15 * time.Minute, 30 * time.Minute, 45*time.Minute + 20*time.Second, 60 * time.Minute},
+			now:          61 * time.Minute,
+			wantClass:    ActivityPeriodic,
+			wantInterval: 15 * time.Minute,
+		},
+		{
+			name:         "sleepy laptop",
+			seen:         []time.Duration{0, 10 * time.Minute, 70 * time.Minute, 80 * time.Minute},
+			now:          81 * time.Minute,
+			wantClass:    ActivitySleepy,
+			wantInterval: 60 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewNDPStats(5 * time.Minute)
+			for _, d := range tt.seen {
+				s.touchActivityLocked("aa:bb:cc:dd:ee:ff", t0.Add(d))
+			}
+			class, interval := s.activityLocked("aa:bb:cc:dd:ee:ff", t0.Add(tt.now))
+			if class != tt.wantClass || interval != tt.wantInterval {
+				t.Errorf("got (%q, %v), want (%q, %v)", class, interval, tt.wantClass, tt.wantInterval)
+			}
+		})
+	}
+
+	// A host seen steadily for over an hour is always-on.
+	s := NewNDPStats(5 * time.Minute)
+	for d := time.Duration(0); d <= 70*time.Minute; d += 2 * time.Minute {
+		s.touchActivityLocked("aa:bb:cc:dd:ee:01", t0.Add(d))
+	}
+	if class, _ := s.activityLocked("aa:bb:cc:dd:ee:01", t0.Add(71*time.Minute)); class != ActivityAlwaysOn {
+		t.Errorf("steady host = %q, want %q", class, ActivityAlwaysOn)
+	}
+}
@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Peers, routers and targets are keyed by the textual form of a netip.Addr.
+// Link-local addresses are only unique per link, so they carry the capture
+// interface as their zone (fe80::1%eth0) whenever it is known; fe80::1 seen
+// on eth0 and on eth1 are then two peers. Other addresses only carry one
+// with NDPListenerConfig.ScopeByInterface; MergePeers folds them back.
+
+// addrFromNet converts a socket address, keeping its zone.
+func addrFromNet(a net.Addr) netip.Addr {
+	var ip net.IP
+	var zone string
+	switch v := a.(type) {
+	case *net.IPAddr:
+		ip, zone = v.IP, v.Zone
+	case *net.UDPAddr:
+		ip, zone = v.IP, v.Zone
+	default:
+		return netip.Addr{}
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Addr{}
+	}
+	return scopedAddr(addr.Unmap(), zone)
+}
+
+// scopedAddr returns addr with its zone set to iface if it is link-scoped, or
+// with any zone removed if it isn't. An empty iface keeps an existing zone.
+func scopedAddr(addr netip.Addr, iface string) netip.Addr {
+	if !addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() {
+		return addr.WithZone("")
+	}
+	if iface == "" {
+		return addr
+	}
+	return addr.WithZone(iface)
+}
+
+// ip16String formats a 16-byte address field of a message canonically.
+// Unlike net.IP.String it keeps IPv4-mapped addresses in IPv6 notation
+// (::ffff:192.0.2.1), matching the source addresses netip formats.
+func ip16String(b []byte) string {
+	return netip.AddrFrom16([16]byte(b)).String()
+}
+
+// canonicalAddr returns the RFC 5952 text of an address given in any form
+// netip accepts: upper case, leading zeros, fully expanded, a hex IPv4 tail
+// (::ffff:c000:201) or in brackets. A bare IPv4 address, as older releases
+// wrote IPv4-mapped addresses, becomes ::ffff:a.b.c.d. Anything else is
+// returned unchanged. Canonical input is returned without allocating.
+func canonicalAddr(s string) string {
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		return s
+	}
+	if addr.Is4() {
+		addr = netip.AddrFrom16(addr.As16())
+	}
+	var buf [64]byte
+	if b := addr.AppendTo(buf[:0]); string(b) != s {
+		return string(b)
+	}
+	return s
+}
+
+// addrKey parses a peer key. It returns the zero Addr for anything else.
+func addrKey(s string) netip.Addr {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr
+}
+
+// unzoned strips the zone from an address key, for lookups that are not
+// link specific such as reverse DNS or the inventory.
+func unzoned(s string) string {
+	if addr := addrKey(s); addr.IsValid() && addr.Zone() != "" {
+		return addr.WithZone("").String()
+	}
+	return s
+}
+
+// addrMatches reports whether addr is want. An unzoned want matches addr on
+// any link; a zoned want only on its own.
+func addrMatches(addr, want netip.Addr) bool {
+	if want.Zone() == "" {
+		addr = addr.WithZone("")
+	}
+	return addr == want
+}
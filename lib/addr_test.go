@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestScopedAddr(t *testing.T) {
+	tests := []struct {
+		addr, iface, want string
+	}{
+		{"fe80::1", "eth0", "fe80::1%eth0"},
+		{"fe80::1%eth1", "eth0", "fe80::1%eth0"},
+		{"fe80::1%eth1", "", "fe80::1%eth1"},
+		{"ff02::1", "eth0", "ff02::1%eth0"},
+		{"2001:db8::1", "eth0", "2001:db8::1"},
+		{"2001:db8::1%eth0", "", "2001:db8::1"},
+		{"::", "eth0", "::"},
+	}
+	for _, tt := range tests {
+		if got := scopedAddr(netip.MustParseAddr(tt.addr), tt.iface).String(); got != tt.want {
+			t.Errorf("scopedAddr(%s, %q) = %s, want %s", tt.addr, tt.iface, got, tt.want)
+		}
+	}
+}
+
+func TestZonedPeersAreDistinct(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	l.handle(received{src: netip.MustParseAddr("fe80::1%eth0"), payload: buildNA(net.ParseIP("fe80::1"), mac)})
+	l.handle(received{src: netip.MustParseAddr("fe80::1%eth1"), payload: buildRS(nil)})
+	l.handle(received{src: netip.MustParseAddr("2001:db8::1"), payload: buildRS(nil)})
+
+	peers := stats.GetStats()
+	if len(peers) != 3 {
+		t.Fatalf("got %d peers, want fe80::1 on two links and 2001:db8::1: %+v", len(peers), peers)
+	}
+
+	count := func(expr string) int {
+		f, err := ParseFilter(expr)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q): %v", expr, err)
+		}
+		return len(FilterPeers(peers, f))
+	}
+	for expr, want := range map[string]int{
+		`addr == "fe80::1"`:          2,
+		`addr == "FE80:0::1"`:        2,
+		`addr == "fe80::1%eth1"`:     1,
+		`addr != "fe80::1"`:          1,
+		`addr == "2001:db8::/32"`:    1,
+		`addr == "fe80::/10"`:        2,
+		`addr =~ "%eth0$"`:           1,
+		`addr == "not an address"`:   0,
+		`addr != "2001:db8:1::/48"`:  3,
+		`addr == "2001:db8::1%eth0"`: 0,
+	} {
+		if got := count(expr); got != want {
+			t.Errorf("%s matched %d peers, want %d", expr, got, want)
+		}
+	}
+}
+
+func TestCanonicalAddr(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"fe80::1", "fe80::1"},
+		{"FE80::1", "fe80::1"},
+		{"fe80:0000:0000:0000:0000:0000:0000:0001", "fe80::1"},
+		{"fe80:0:0:0:0202:b3ff:fe1e:8329", "fe80::202:b3ff:fe1e:8329"},
+		{"2001:db8:0:0:1:0:0:1", "2001:db8::1:0:0:1"},
+		{"2001:DB8::0001", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"::ffff:192.0.2.1", "::ffff:192.0.2.1"},
+		{"::FFFF:C000:0201", "::ffff:192.0.2.1"},
+		{"0:0:0:0:0:ffff:192.0.2.1", "::ffff:192.0.2.1"},
+		{"192.0.2.1", "::ffff:192.0.2.1"},
+		{"64:ff9b::192.0.2.1", "64:ff9b::c000:201"},
+		{"FE80::0001%eth0", "fe80::1%eth0"},
+		{"::", "::"},
+		{"not an address", "not an address"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := canonicalAddr(tt.in); got != tt.want {
+			t.Errorf("canonicalAddr(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if n := testing.AllocsPerRun(100, func() { canonicalAddr("fe80::202:b3ff:fe1e:8329") }); n != 0 {
+		t.Errorf("canonicalAddr allocated %v times for canonical input", n)
+	}
+}
+
+func TestTextualFormsShareAPeer(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	for _, ip := range []string{"2001:db8::1", "2001:DB8::1", "2001:0db8:0000:0000:0000:0000:0000:0001", "[2001:db8::1]"} {
+		stats.RecordMessage(ip, "router_solicitation")
+	}
+	stats.RecordMessage("::ffff:c000:201", "neighbor_solicitation")
+	stats.RecordMAC("192.0.2.1", "02:00:00:00:00:01")
+
+	peers := stats.GetStats()
+	if len(peers) != 2 {
+		t.Fatalf("got %d peers, want 2: %+v", len(peers), peers)
+	}
+	byAddr := make(map[string]PeerSummary)
+	for _, p := range peers {
+		byAddr[p.Address] = p
+	}
+	if got := byAddr["2001:db8::1"].Counts["router_solicitation"]; got != 4 {
+		t.Errorf("2001:db8::1 RS count = %d, want 4", got)
+	}
+	if got := byAddr["::ffff:192.0.2.1"].MAC; got != "02:00:00:00:00:01" {
+		t.Errorf("::ffff:192.0.2.1 MAC = %q, want the MAC recorded for 192.0.2.1", got)
+	}
+	if got := stats.PeerMAC("::FFFF:192.0.2.1"); got != "02:00:00:00:00:01" {
+		t.Errorf("PeerMAC = %q", got)
+	}
+}
+
+func TestParseNDTarget_IPv4Mapped(t *testing.T) {
+	ns := buildNS(net.ParseIP("::ffff:192.0.2.1"), nil)
+	if got := parseNDTarget(ns); got != "::ffff:192.0.2.1" {
+		t.Errorf("parseNDTarget = %q, want ::ffff:192.0.2.1", got)
+	}
+}
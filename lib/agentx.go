@@ -0,0 +1,543 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AgentX (RFC 2741) subagent exposing NDPeekr counters to an SNMP master
+// agent such as net-snmp's snmpd ("master agentx" in snmpd.conf).
+//
+// MIB layout under the configured base OID:
+//
+//	.1.0         peers in the window           Gauge32
+//	.2.0         routers                       Gauge32
+//	.3.0         multicast groups              Gauge32
+//	.4.0         alerts since startup          Counter32
+//	.5.1.2.<i>   message type name (i = 1..10) OCTET STRING
+//	.5.1.3.<i>   messages of that type in the window  Gauge32
+//	.6.1.2.<s>   severity name (s = 1 info, 2 warning, 3 critical)  OCTET STRING
+//	.6.1.3.<s>   alerts of that severity since startup  Counter32
+
+const (
+	// defaultAgentXAddress is net-snmp's default master agent socket.
+	defaultAgentXAddress = "/var/agentx/master"
+	// defaultAgentXOID is net-snmp's experimental "playpen" subtree; set a
+	// private enterprise OID in production.
+	defaultAgentXOID = "1.3.6.1.4.1.8072.9999.9999.7"
+
+	agentxTimeout = 5 * time.Second
+)
+
+// AgentX PDU types and flags.
+const (
+	agentxOpen     = 1
+	agentxClose    = 2
+	agentxRegister = 3
+	agentxGet      = 5
+	agentxGetNext  = 6
+	agentxGetBulk  = 7
+	agentxTestSet  = 8
+	agentxResponse = 18
+
+	agentxFlagNonDefaultContext = 0x08
+	agentxFlagNetworkByteOrder  = 0x10
+
+	agentxReasonShutdown = 5
+	agentxErrNotWritable = 17
+)
+
+// AgentX varbind types.
+const (
+	agentxOctetString  = 4
+	agentxCounter32    = 65
+	agentxGauge32      = 66
+	agentxNoSuchObject = 128
+	agentxEndOfMibView = 130
+)
+
+// AgentXConfig connects a subagent to an SNMP master agent.
+type AgentXConfig struct {
+	// Address is the master agent: a Unix socket path (default
+	// /var/agentx/master) or "tcp:host:port".
+	Address string `yaml:"address"`
+	// OID is the subtree to register (default 1.3.6.1.4.1.8072.9999.9999.7).
+	OID string `yaml:"oid"`
+}
+
+type agentxHeader struct {
+	typ                      byte
+	flags                    byte
+	session, transaction, id uint32
+}
+
+// agentxVarBind is one MIB object and its value.
+type agentxVarBind struct {
+	oid   []uint32
+	typ   uint16
+	value any // uint32 for numeric types, string for OCTET STRING
+}
+
+// ServeAgentX registers with the master agent and answers its requests until
+// ctx is cancelled, reconnecting when the master agent goes away.
+func ServeAgentX(ctx context.Context, cfg AgentXConfig, stats *NDPStats, logger *slog.Logger) error {
+	if cfg.Address == "" {
+		cfg.Address = defaultAgentXAddress
+	}
+	if cfg.OID == "" {
+		cfg.OID = defaultAgentXOID
+	}
+	base, err := parseOID(cfg.OID)
+	if err != nil {
+		return fmt.Errorf("agentx: %w", err)
+	}
+
+	backoff := time.Second
+	for {
+		err := agentxSession(ctx, cfg.Address, base, stats, logger)
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger.Warn("agentx session ended; reconnecting", "address", cfg.Address, "err", err, "in", backoff)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+func agentxSession(ctx context.Context, address string, base []uint32, stats *NDPStats, logger *slog.Logger) error {
+	network, addr := "unix", address
+	if rest, ok := strings.CutPrefix(address, "tcp:"); ok {
+		network, addr = "tcp", rest
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Unblock the read loop on shutdown.
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	a := &agentxSubagent{conn: conn, r: bufio.NewReader(conn), base: base, stats: stats, started: time.Now()}
+	if err := a.register(); err != nil {
+		return err
+	}
+	logger.Info("agentx subagent registered", "address", address, "oid", formatOID(base))
+	err = a.serve()
+	if ctx.Err() != nil {
+		// Best effort; the master agent also notices the socket closing.
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		conn.Write(agentxPDU(agentxHeader{typ: agentxClose, session: a.session}, []byte{agentxReasonShutdown, 0, 0, 0}))
+	}
+	return err
+}
+
+// agentxSubagent is one connected session.
+type agentxSubagent struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	base    []uint32
+	stats   *NDPStats
+	session uint32
+	packet  uint32
+	started time.Time
+}
+
+// register opens the session and registers the base subtree.
+func (a *agentxSubagent) register() error {
+	var open []byte
+	open = append(open, byte(agentxTimeout/time.Second), 0, 0, 0)
+	open = appendOID(open, a.base, false)
+	open = appendOctetString(open, "NDPeekr NDP/MLD monitor")
+	h, err := a.call(agentxHeader{typ: agentxOpen}, open)
+	if err != nil {
+		return fmt.Errorf("agentx open: %w", err)
+	}
+	a.session = h.session
+
+	reg := []byte{byte(agentxTimeout / time.Second), 127, 0, 0} // timeout, priority, range_subid
+	reg = appendOID(reg, a.base, false)
+	if _, err := a.call(agentxHeader{typ: agentxRegister, session: a.session}, reg); err != nil {
+		return fmt.Errorf("agentx register: %w", err)
+	}
+	return nil
+}
+
+// call sends a request and waits for its response, failing on an AgentX error.
+func (a *agentxSubagent) call(h agentxHeader, payload []byte) (agentxHeader, error) {
+	a.packet++
+	h.id = a.packet
+	a.conn.SetDeadline(time.Now().Add(agentxTimeout))
+	defer a.conn.SetDeadline(time.Time{})
+
+	if _, err := a.conn.Write(agentxPDU(h, payload)); err != nil {
+		return h, err
+	}
+	for {
+		resp, body, err := readAgentXPDU(a.r)
+		if err != nil {
+			return resp, err
+		}
+		if resp.typ != agentxResponse || resp.id != h.id {
+			continue
+		}
+		if len(body) < 8 {
+			return resp, errors.New("short response")
+		}
+		if code := resp.byteOrder().Uint16(body[4:6]); code != 0 {
+			return resp, fmt.Errorf("master agent error %d", code)
+		}
+		return resp, nil
+	}
+}
+
+// serve answers Get/GetNext/GetBulk until the connection fails or the master closes it.
+func (a *agentxSubagent) serve() error {
+	for {
+		h, body, err := readAgentXPDU(a.r)
+		if err != nil {
+			return err
+		}
+		var vbs []agentxVarBind
+		var errCode uint16
+		switch h.typ {
+		case agentxGet, agentxGetNext, agentxGetBulk:
+			vbs, err = a.answer(h, body)
+			if err != nil {
+				return err
+			}
+		case agentxTestSet:
+			errCode = agentxErrNotWritable
+		case agentxClose:
+			return errors.New("closed by master agent")
+		default:
+			// Responses to our own PDUs, pings and set phases we never agreed to.
+			continue
+		}
+		if _, err := a.conn.Write(agentxPDU(agentxHeader{
+			typ: agentxResponse, session: h.session, transaction: h.transaction, id: h.id,
+		}, a.response(errCode, vbs))); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *agentxSubagent) response(errCode uint16, vbs []agentxVarBind) []byte {
+	uptime := uint32(time.Since(a.started) / (10 * time.Millisecond))
+	b := binary.BigEndian.AppendUint32(nil, uptime)
+	b = binary.BigEndian.AppendUint16(b, errCode)
+	b = binary.BigEndian.AppendUint16(b, 0)
+	for _, vb := range vbs {
+		b = appendVarBind(b, vb)
+	}
+	return b
+}
+
+// answer resolves the search ranges of a Get, GetNext or GetBulk request
+// against a fresh view of the stats.
+func (a *agentxSubagent) answer(h agentxHeader, body []byte) ([]agentxVarBind, error) {
+	d := agentxDecoder{buf: body, order: h.byteOrder()}
+	if h.flags&agentxFlagNonDefaultContext != 0 {
+		d.octetString()
+	}
+	var nonRepeaters, maxRepetitions int
+	if h.typ == agentxGetBulk {
+		nonRepeaters, maxRepetitions = int(d.uint16()), int(d.uint16())
+	}
+	type searchRange struct {
+		start, end []uint32
+		include    bool
+	}
+	var ranges []searchRange
+	for d.err == nil && len(d.buf) > 0 {
+		start, include := d.oid()
+		end, _ := d.oid()
+		ranges = append(ranges, searchRange{start, end, include})
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	view := agentxView(a.base, *a.stats.Shared(), a.stats.GetAlertCounts())
+	var vbs []agentxVarBind
+	switch h.typ {
+	case agentxGet:
+		for _, r := range ranges {
+			vbs = append(vbs, view.get(r.start))
+		}
+	case agentxGetNext:
+		for _, r := range ranges {
+			vbs = append(vbs, view.next(r.start, r.end, r.include))
+		}
+	case agentxGetBulk:
+		nonRepeaters = min(nonRepeaters, len(ranges))
+		for _, r := range ranges[:nonRepeaters] {
+			vbs = append(vbs, view.next(r.start, r.end, r.include))
+		}
+		repeaters := ranges[nonRepeaters:]
+		for i := 0; i < maxRepetitions && len(repeaters) > 0; i++ {
+			done := true
+			for j, r := range repeaters {
+				vb := view.next(r.start, r.end, r.include)
+				vbs = append(vbs, vb)
+				repeaters[j].start, repeaters[j].include = vb.oid, false
+				if vb.typ != agentxEndOfMibView {
+					done = false
+				}
+			}
+			if done {
+				break
+			}
+		}
+	}
+	return vbs, nil
+}
+
+// agentxMIB is a sorted set of objects.
+type agentxMIB []agentxVarBind
+
+// agentxView builds the MIB described at the top of this file.
+func agentxView(base []uint32, snap Snapshot, alertCounts []AlertCount) agentxMIB {
+	at := func(sub ...uint32) []uint32 { return append(append([]uint32(nil), base...), sub...) }
+
+	alerts := make(map[Severity]uint32)
+	var totalAlerts uint32
+	for _, c := range alertCounts {
+		alerts[c.Severity] += uint32(c.Count)
+		totalAlerts += uint32(c.Count)
+	}
+	counts := make(map[string]int)
+	for _, p := range snap.Peers {
+		for kind, n := range p.Counts {
+			counts[kind] += n
+		}
+	}
+
+	mib := agentxMIB{
+		{oid: at(1, 0), typ: agentxGauge32, value: uint32(len(snap.Peers))},
+		{oid: at(2, 0), typ: agentxGauge32, value: uint32(len(snap.Routers))},
+		{oid: at(3, 0), typ: agentxGauge32, value: uint32(len(snap.Groups))},
+		{oid: at(4, 0), typ: agentxCounter32, value: totalAlerts},
+	}
+	for i, kind := range msgColumnOrder {
+		idx := uint32(i + 1)
+		mib = append(mib,
+			agentxVarBind{oid: at(5, 1, 2, idx), typ: agentxOctetString, value: kind},
+			agentxVarBind{oid: at(5, 1, 3, idx), typ: agentxGauge32, value: uint32(counts[kind])})
+	}
+	for _, sev := range []Severity{SeverityInfo, SeverityWarning, SeverityCritical} {
+		idx := uint32(sev) + 1
+		mib = append(mib,
+			agentxVarBind{oid: at(6, 1, 2, idx), typ: agentxOctetString, value: sev.String()},
+			agentxVarBind{oid: at(6, 1, 3, idx), typ: agentxCounter32, value: alerts[sev]})
+	}
+	sort.Slice(mib, func(i, j int) bool { return compareOID(mib[i].oid, mib[j].oid) < 0 })
+	return mib
+}
+
+func (m agentxMIB) get(oid []uint32) agentxVarBind {
+	for _, vb := range m {
+		if compareOID(vb.oid, oid) == 0 {
+			return vb
+		}
+	}
+	return agentxVarBind{oid: oid, typ: agentxNoSuchObject}
+}
+
+// next returns the first object after start (or at it, if include) and
+// before end (unless end is empty).
+func (m agentxMIB) next(start, end []uint32, include bool) agentxVarBind {
+	for _, vb := range m {
+		c := compareOID(vb.oid, start)
+		if c < 0 || (c == 0 && !include) {
+			continue
+		}
+		if len(end) > 0 && compareOID(vb.oid, end) >= 0 {
+			break
+		}
+		return vb
+	}
+	return agentxVarBind{oid: start, typ: agentxEndOfMibView}
+}
+
+func compareOID(a, b []uint32) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+func parseOID(s string) ([]uint32, error) {
+	parts := strings.Split(strings.Trim(s, "."), ".")
+	oid := make([]uint32, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		oid = append(oid, uint32(n))
+	}
+	if len(oid) < 2 || len(oid) > 128 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	return oid, nil
+}
+
+func formatOID(oid []uint32) string {
+	parts := make([]string, len(oid))
+	for i, n := range oid {
+		parts[i] = strconv.FormatUint(uint64(n), 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// --- Wire encoding. We always send in network byte order but accept either. ---
+
+func (h agentxHeader) byteOrder() binary.ByteOrder {
+	if h.flags&agentxFlagNetworkByteOrder != 0 {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func agentxPDU(h agentxHeader, payload []byte) []byte {
+	b := []byte{1, h.typ, h.flags | agentxFlagNetworkByteOrder, 0}
+	b = binary.BigEndian.AppendUint32(b, h.session)
+	b = binary.BigEndian.AppendUint32(b, h.transaction)
+	b = binary.BigEndian.AppendUint32(b, h.id)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	return append(b, payload...)
+}
+
+func readAgentXPDU(r io.Reader) (agentxHeader, []byte, error) {
+	var hdr [20]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return agentxHeader{}, nil, err
+	}
+	if hdr[0] != 1 {
+		return agentxHeader{}, nil, fmt.Errorf("unsupported AgentX version %d", hdr[0])
+	}
+	h := agentxHeader{typ: hdr[1], flags: hdr[2]}
+	order := h.byteOrder()
+	h.session = order.Uint32(hdr[4:8])
+	h.transaction = order.Uint32(hdr[8:12])
+	h.id = order.Uint32(hdr[12:16])
+	n := order.Uint32(hdr[16:20])
+	if n > 1<<20 {
+		return h, nil, fmt.Errorf("AgentX payload of %d bytes is too large", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return h, nil, err
+	}
+	return h, body, nil
+}
+
+func appendOID(b []byte, oid []uint32, include bool) []byte {
+	inc := byte(0)
+	if include {
+		inc = 1
+	}
+	b = append(b, byte(len(oid)), 0, inc, 0)
+	for _, n := range oid {
+		b = binary.BigEndian.AppendUint32(b, n)
+	}
+	return b
+}
+
+func appendOctetString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	b = append(b, s...)
+	return append(b, make([]byte, (4-len(s)%4)%4)...)
+}
+
+func appendVarBind(b []byte, vb agentxVarBind) []byte {
+	b = binary.BigEndian.AppendUint16(b, vb.typ)
+	b = append(b, 0, 0)
+	b = appendOID(b, vb.oid, false)
+	switch v := vb.value.(type) {
+	case uint32:
+		b = binary.BigEndian.AppendUint32(b, v)
+	case string:
+		b = appendOctetString(b, v)
+	}
+	return b
+}
+
+// agentxDecoder reads AgentX payload fields, recording the first error.
+type agentxDecoder struct {
+	buf   []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (d *agentxDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = errors.New("truncated AgentX PDU")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *agentxDecoder) uint16() uint16 {
+	if b := d.take(2); b != nil {
+		return d.order.Uint16(b)
+	}
+	return 0
+}
+
+func (d *agentxDecoder) uint32() uint32 {
+	if b := d.take(4); b != nil {
+		return d.order.Uint32(b)
+	}
+	return 0
+}
+
+func (d *agentxDecoder) oid() ([]uint32, bool) {
+	hdr := d.take(4)
+	if hdr == nil {
+		return nil, false
+	}
+	n, prefix, include := int(hdr[0]), hdr[1], hdr[2] != 0
+	var oid []uint32
+	if prefix != 0 {
+		oid = []uint32{1, 3, 6, 1, uint32(prefix)}
+	}
+	for i := 0; i < n && d.err == nil; i++ {
+		oid = append(oid, d.uint32())
+	}
+	return oid, include
+}
+
+func (d *agentxDecoder) octetString() string {
+	n := int(d.uint32())
+	s := string(d.take(n))
+	d.take((4 - n%4) % 4)
+	return s
+}
@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeMaster plays the master agent side of an AgentX session.
+type fakeMaster struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (m *fakeMaster) expect(typ byte) (agentxHeader, []byte) {
+	m.t.Helper()
+	m.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	h, body, err := readAgentXPDU(m.r)
+	if err != nil {
+		m.t.Fatalf("master read: %v", err)
+	}
+	if h.typ != typ {
+		m.t.Fatalf("master got PDU type %d, want %d", h.typ, typ)
+	}
+	return h, body
+}
+
+func (m *fakeMaster) respond(req agentxHeader, session uint32) {
+	m.t.Helper()
+	body := make([]byte, 8) // sysUpTime, error 0, index 0
+	if _, err := m.conn.Write(agentxPDU(agentxHeader{typ: agentxResponse, session: session, id: req.id}, body)); err != nil {
+		m.t.Fatal(err)
+	}
+}
+
+// request sends a Get or GetNext for oids and returns the decoded varbinds.
+func (m *fakeMaster) request(typ byte, oids ...[]uint32) []agentxVarBind {
+	m.t.Helper()
+	var body []byte
+	for _, oid := range oids {
+		body = appendOID(body, oid, false)
+		body = appendOID(body, nil, false)
+	}
+	if _, err := m.conn.Write(agentxPDU(agentxHeader{typ: typ, session: 42, id: 99}, body)); err != nil {
+		m.t.Fatal(err)
+	}
+	_, resp := m.expect(agentxResponse)
+
+	d := agentxDecoder{buf: resp[8:], order: binary.BigEndian}
+	var vbs []agentxVarBind
+	for d.err == nil && len(d.buf) > 0 {
+		vb := agentxVarBind{typ: d.uint16()}
+		d.uint16()
+		vb.oid, _ = d.oid()
+		switch vb.typ {
+		case agentxOctetString:
+			vb.value = d.octetString()
+		case agentxCounter32, agentxGauge32:
+			vb.value = d.uint32()
+		}
+		vbs = append(vbs, vb)
+	}
+	if d.err != nil {
+		m.t.Fatalf("decode response: %v", d.err)
+	}
+	return vbs
+}
+
+func TestAgentXSubagent(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "master")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordMessage("fe80::2", "neighbor_solicitation")
+	stats.RecordAlert(Alert{Time: time.Now(), Severity: SeverityWarning, Category: "test", Source: "fe80::1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeAgentX(ctx, AgentXConfig{Address: sock, OID: "1.3.6.1.4.1.99999.1"}, stats, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	m := &fakeMaster{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	open, _ := m.expect(agentxOpen)
+	m.respond(open, 42)
+	reg, body := m.expect(agentxRegister)
+	if reg.session != 42 {
+		t.Errorf("register session = %d, want 42", reg.session)
+	}
+	d := agentxDecoder{buf: body[4:], order: binary.BigEndian}
+	if oid, _ := d.oid(); formatOID(oid) != "1.3.6.1.4.1.99999.1" {
+		t.Errorf("registered %s", formatOID(oid))
+	}
+	m.respond(reg, 42)
+
+	base := []uint32{1, 3, 6, 1, 4, 1, 99999, 1}
+	vbs := m.request(agentxGet, append(base, 1, 0), append(base, 4, 0), append(base, 9, 0))
+	if len(vbs) != 3 || vbs[0].value != uint32(2) || vbs[1].value != uint32(1) || vbs[2].typ != agentxNoSuchObject {
+		t.Errorf("Get = %+v, want peers 2, alerts 1, noSuchObject", vbs)
+	}
+
+	// Walk into the message type table: NS is the third type.
+	vbs = m.request(agentxGetNext, append(base, 5, 1, 3, 2))
+	if len(vbs) != 1 || formatOID(vbs[0].oid) != "1.3.6.1.4.1.99999.1.5.1.3.3" || vbs[0].value != uint32(2) {
+		t.Errorf("GetNext = %+v, want 2 neighbor solicitations", vbs)
+	}
+
+	// Past the last object the walk ends.
+	vbs = m.request(agentxGetNext, append(base, 7))
+	if len(vbs) != 1 || vbs[0].typ != agentxEndOfMibView {
+		t.Errorf("GetNext past end = %+v, want endOfMibView", vbs)
+	}
+
+	cancel()
+	if h, _ := m.expect(agentxClose); h.session != 42 {
+		t.Errorf("close session = %d, want 42", h.session)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("ServeAgentX: %v", err)
+	}
+}
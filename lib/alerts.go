@@ -0,0 +1,248 @@
+package lib
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// maxAlerts bounds the number of alerts kept in memory. Older alerts are
+// discarded first once the limit is reached.
+const maxAlerts = 1000
+
+// Severity ranks how urgent an alert is.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+// String returns the lowercase name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "info"
+	}
+}
+
+// MarshalText encodes the severity by name so JSON output is self-describing.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses a severity name produced by MarshalText.
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "info":
+		*s = SeverityInfo
+	case "warning":
+		*s = SeverityWarning
+	case "critical":
+		*s = SeverityCritical
+	default:
+		return fmt.Errorf("unknown severity %q", text)
+	}
+	return nil
+}
+
+// Alert is a structured anomaly raised while processing captured traffic.
+type Alert struct {
+	Time     time.Time `json:"time"`
+	Severity Severity  `json:"severity"`
+	Category string    `json:"category"`       // machine-readable category, e.g. "oversized_packet"
+	Source   string    `json:"source"`         // IPv6 address the alert is about
+	Message  string    `json:"message"`        // human-readable description
+	Port     string    `json:"port,omitempty"` // member port the triggering packet arrived on (--member-ports)
+	// Peer is what enrichment knows about Source when the alert was raised.
+	Peer *Enrichment `json:"peer,omitempty"`
+	// Maintenance names the maintenance window that downgraded the alert.
+	Maintenance string `json:"maintenance,omitempty"`
+	// Node is the --node-name of the instance that raised the alert.
+	Node string `json:"node,omitempty"`
+	// Binding is the IP/MAC binding change behind binding_flip and
+	// binding_flood alerts.
+	Binding *BindingChange `json:"binding,omitempty"`
+	// DAD is the DAD run behind dad_conflict alerts.
+	DAD *DADTransaction `json:"dad,omitempty"`
+	// Acked is set once someone acknowledges the alert (see AckAlerts).
+	Acked bool `json:"acked,omitempty"`
+}
+
+// emitAlert records a in stats (if non-nil), logs it at WARN level and
+// writes it to every sink. Components that raise alerts outside the
+// listener use it so alerts reach the same places. Alerts suppressed by a
+// maintenance window go nowhere but the debug log.
+func emitAlert(stats *NDPStats, logger *slog.Logger, sinks []Sink, a Alert) {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+		if stats != nil {
+			a.Time = stats.now()
+		}
+	}
+	if stats != nil {
+		if !stats.applyMaintenance(&a) {
+			logger.Debug("alert suppressed by maintenance window", "window", a.Maintenance, "alert", a)
+			return
+		}
+		if a.Peer == nil {
+			a.Peer = stats.enrichmentFor(a.Source)
+		}
+		stats.RecordAlert(a)
+	}
+	logger.Warn("ndp alert", "alert", a)
+	for _, s := range sinks {
+		if err := s.WriteAlert(a); err != nil {
+			logger.Debug("sink write failed", "err", err)
+		}
+	}
+}
+
+// LogValue renders the alert as structured slog fields.
+func (a Alert) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("severity", a.Severity.String()),
+		slog.String("category", a.Category),
+		slog.String("src", a.Source),
+		slog.String("msg", a.Message),
+	}
+	if a.Port != "" {
+		attrs = append(attrs, slog.String("port", a.Port))
+	}
+	if a.Peer != nil && a.Peer.Name != "" {
+		attrs = append(attrs, slog.String("name", a.Peer.Name))
+	}
+	if a.Maintenance != "" {
+		attrs = append(attrs, slog.String("maintenance", a.Maintenance))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// AlertCount is the number of alerts raised for one category and severity
+// since startup, including alerts that have since been dropped from memory.
+type AlertCount struct {
+	Category string   `json:"category"`
+	Severity Severity `json:"severity"`
+	Count    int      `json:"count"`
+}
+
+type alertTotalKey struct {
+	category string
+	severity Severity
+}
+
+// RecordAlert stores an alert. Once maxAlerts is reached the oldest alert is dropped.
+func (s *NDPStats) RecordAlert(a Alert) {
+	if a.Time.IsZero() {
+		a.Time = s.now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.Node == "" {
+		a.Node = s.status.Node
+	}
+	s.alertTotals[alertTotalKey{a.Category, a.Severity}]++
+	s.alertSeq++
+	s.alerts = append(s.alerts, a)
+	if len(s.alerts) > maxAlerts {
+		s.alerts = s.alerts[len(s.alerts)-maxAlerts:]
+	}
+}
+
+// alertDue reports whether an alert identified by key may be raised at now.
+// It returns false if the same key was raised within the sliding window.
+func (s *NDPStats) alertDue(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.alertKeys[key]; ok && now.Sub(last) < s.window {
+		return false
+	}
+	s.alertKeys[key] = now
+	return true
+}
+
+// pruneAlertKeysLocked forgets alert keys last raised at or before cutoff,
+// which alertDue would let through again anyway. Many keys name a source
+// address, so a flood from rotating sources would otherwise grow them
+// without bound. Callers must hold s.mu.
+func (s *NDPStats) pruneAlertKeysLocked(cutoff time.Time) {
+	for key, last := range s.alertKeys {
+		if !last.After(cutoff) {
+			delete(s.alertKeys, key)
+		}
+	}
+}
+
+// GetAlerts returns a copy of all stored alerts, newest first.
+func (s *NDPStats) GetAlerts() []Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.alertsLocked()
+}
+
+// alertsLocked copies the stored alerts, newest first. Callers must hold s.mu.
+func (s *NDPStats) alertsLocked() []Alert {
+	result := make([]Alert, len(s.alerts))
+	for i, a := range s.alerts {
+		result[len(s.alerts)-1-i] = a
+	}
+	return result
+}
+
+// AlertsSince returns the alerts recorded after the first seq, oldest first,
+// along with the sequence number to pass next time. Alerts already dropped
+// from memory are skipped.
+func (s *NDPStats) AlertsSince(seq uint64) ([]Alert, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := min(s.alertSeq-min(seq, s.alertSeq), uint64(len(s.alerts)))
+	return append([]Alert(nil), s.alerts[len(s.alerts)-int(n):]...), s.alertSeq
+}
+
+// AckAlerts acknowledges the stored alerts matching category and source;
+// an empty category or source matches any. It returns how many alerts
+// were newly acknowledged.
+func (s *NDPStats) AckAlerts(category, source string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for i := range s.alerts {
+		a := &s.alerts[i]
+		if a.Acked || (category != "" && a.Category != category) || (source != "" && a.Source != source) {
+			continue
+		}
+		a.Acked = true
+		n++
+	}
+	return n
+}
+
+// GetAlertCounts returns cumulative alert counts sorted by category and severity.
+func (s *NDPStats) GetAlertCounts() []AlertCount {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]AlertCount, 0, len(s.alertTotals))
+	for k, n := range s.alertTotals {
+		result = append(result, AlertCount{Category: k.category, Severity: k.severity, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Category != result[j].Category {
+			return result[i].Category < result[j].Category
+		}
+		return result[i].Severity < result[j].Severity
+	})
+	return result
+}
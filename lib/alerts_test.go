@@ -0,0 +1,146 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAlert_NewestFirst(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+
+	stats.RecordAlert(Alert{Category: "first"})
+	stats.RecordAlert(Alert{Category: "second"})
+
+	alerts := stats.GetAlerts()
+	if len(alerts) != 2 {
+		t.Fatalf("GetAlerts() returned %d, want 2", len(alerts))
+	}
+	if alerts[0].Category != "second" || alerts[1].Category != "first" {
+		t.Errorf("alerts = %v, want newest first", alerts)
+	}
+	if alerts[0].Time.IsZero() {
+		t.Error("Time should default to now")
+	}
+}
+
+func TestRecordAlert_Capped(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+
+	for i := 0; i < maxAlerts+10; i++ {
+		stats.RecordAlert(Alert{Category: "flood"})
+	}
+
+	if got := len(stats.GetAlerts()); got != maxAlerts {
+		t.Errorf("len(GetAlerts()) = %d, want %d", got, maxAlerts)
+	}
+}
+
+func TestSeverityString(t *testing.T) {
+	tests := map[Severity]string{
+		SeverityInfo:     "info",
+		SeverityWarning:  "warning",
+		SeverityCritical: "critical",
+	}
+	for sev, want := range tests {
+		if got := sev.String(); got != want {
+			t.Errorf("Severity(%d).String() = %q, want %q", sev, got, want)
+		}
+	}
+}
+
+func TestAlertDue_ThrottlesWithinWindow(t *testing.T) {
+	stats := NewNDPStats(time.Minute)
+	now := time.Now()
+
+	if !stats.alertDue("k", now) {
+		t.Error("first alert should be due")
+	}
+	if stats.alertDue("k", now.Add(30*time.Second)) {
+		t.Error("repeat within window should be suppressed")
+	}
+	if !stats.alertDue("other", now) {
+		t.Error("different key should be due")
+	}
+	if !stats.alertDue("k", now.Add(2*time.Minute)) {
+		t.Error("repeat after window should be due")
+	}
+}
+
+func TestPrune_ForgetsAlertKeys(t *testing.T) {
+	clock := time.Now()
+	stats := NewNDPStats(time.Minute)
+	stats.SetClock(func() time.Time { return clock })
+
+	// A flood rotating its source address.
+	for i := range 1000 {
+		stats.alertDue(fmt.Sprintf("bad_checksum|2001:db8::%x", i), clock)
+	}
+	stats.Prune()
+	if n := len(stats.alertKeys); n != 1000 {
+		t.Fatalf("%d alert keys within the window, want 1000", n)
+	}
+
+	clock = clock.Add(time.Minute)
+	stats.alertDue("bad_checksum|2001:db8::1:1", clock)
+	stats.Prune()
+	if n := len(stats.alertKeys); n != 1 {
+		t.Errorf("%d alert keys a window later, want only the fresh one", n)
+	}
+}
+
+func TestAlertsSince(t *testing.T) {
+	stats := NewNDPStats(time.Minute)
+	stats.RecordAlert(Alert{Category: "a"})
+	_, seq := stats.AlertsSince(0)
+
+	stats.RecordAlert(Alert{Category: "b"})
+	stats.RecordAlert(Alert{Category: "c"})
+	got, next := stats.AlertsSince(seq)
+	if len(got) != 2 || got[0].Category != "b" || got[1].Category != "c" || next != seq+2 {
+		t.Errorf("AlertsSince(%d) = %+v, %d", seq, got, next)
+	}
+	if got, _ := stats.AlertsSince(next); len(got) != 0 {
+		t.Errorf("nothing new, got %+v", got)
+	}
+
+	// Alerts already dropped from memory are skipped.
+	for range maxAlerts + 5 {
+		stats.RecordAlert(Alert{Category: "flood"})
+	}
+	if got, _ := stats.AlertsSince(next); len(got) != maxAlerts {
+		t.Errorf("after overflow got %d alerts, want %d", len(got), maxAlerts)
+	}
+}
+
+func TestModelToasts(t *testing.T) {
+	stats := NewNDPStats(time.Minute)
+	stats.RecordAlert(Alert{Severity: SeverityCritical, Category: "before_start"})
+	m := NewModel(ModelConfig{Stats: stats})
+
+	now := time.Now()
+	stats.RecordAlert(Alert{Severity: SeverityInfo, Category: "router_reachable"})
+	stats.RecordAlert(Alert{Severity: SeverityWarning, Category: "oversized_ra", Message: "big"})
+	stats.RecordAlert(Alert{Severity: SeverityCritical, Category: "router_unreachable", Message: "gone", Port: "eth1"})
+	m.updateToasts(now)
+
+	lines := m.renderToasts()
+	if len(lines) != 2 || !strings.Contains(lines[0], "CRITICAL router_unreachable: gone (port eth1)") ||
+		!strings.Contains(lines[1], "WARNING oversized_ra: big") {
+		t.Fatalf("toasts = %q", lines)
+	}
+	if view := overlayLines("a\nb\nc", lines); strings.Count(view, "\n") != 2 || !strings.HasSuffix(view, "\nc") {
+		t.Errorf("overlay = %q, want the first two lines covered", view)
+	}
+
+	// The warning expires first.
+	m.updateToasts(now.Add(6 * time.Second))
+	if len(m.toasts) != 1 || m.toasts[0].alert.Severity != SeverityCritical {
+		t.Errorf("after 6s toasts = %+v", m.toasts)
+	}
+	m.updateToasts(now.Add(11 * time.Second))
+	if len(m.toasts) != 0 {
+		t.Errorf("after 11s toasts = %+v", m.toasts)
+	}
+}
@@ -0,0 +1,242 @@
+package lib
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// AnonymizeConfig anonymizes the snapshots and graphs the TUI writes.
+type AnonymizeConfig struct {
+	// Mode is "hash" or "truncate" (see NewAnonymizer).
+	Mode string `yaml:"mode"`
+	// Key keys the hash. Without one, every run picks a random key, so
+	// addresses only match up within the files of one run.
+	Key string `yaml:"key"`
+}
+
+// anonymizeToken finds candidate IPv6 addresses, prefixes and MACs in free
+// text: anything hex with a colon in it, with an optional zone and length.
+var anonymizeToken = regexp.MustCompile(`(?i)[0-9a-f]*:[0-9a-f:.]*(?:[0-9a-f]|::)(%[\w.-]+)?(/\d{1,3})?`)
+
+// Anonymizer rewrites IPv6 addresses and MACs consistently, so dumps can be
+// shared without the addressing plan while the same host still shows up as
+// the same (fake) host everywhere. IPv4 addresses are left alone. A nil
+// Anonymizer leaves everything unchanged. Safe for concurrent use.
+type Anonymizer struct {
+	truncate bool
+	key      []byte
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewAnonymizer returns an anonymizer in the given mode:
+//
+//   - "hash" replaces every byte of an address after the first, and the
+//     device half of a MAC, with a keyed hash of what came before it. Hosts
+//     in one prefix stay in one (fake) prefix, an EUI-64 address keeps
+//     matching its MAC, and link-local, multicast and unique local
+//     addresses stay recognisable. Well-known multicast groups are kept.
+//   - "truncate" zeroes everything after the /48 of an address (after fe80::
+//     for link-local ones) and the device half of a MAC.
+//
+// Both keep a MAC's vendor (OUI) unless it is locally administered.
+func NewAnonymizer(mode, key string) (*Anonymizer, error) {
+	a := &Anonymizer{key: []byte(key), cache: make(map[string]string)}
+	switch mode {
+	case "hash":
+		if key == "" {
+			a.key = make([]byte, 32)
+			if _, err := rand.Read(a.key); err != nil {
+				return nil, fmt.Errorf("anonymize key: %w", err)
+			}
+		}
+	case "truncate":
+		a.truncate = true
+	default:
+		return nil, fmt.Errorf("unknown anonymize mode %q (want hash or truncate)", mode)
+	}
+	return a, nil
+}
+
+// sum returns the keyed hash of label and b.
+func (a *Anonymizer) sum(label string, b []byte) []byte {
+	h := hmac.New(sha256.New, a.key)
+	h.Write([]byte(label))
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// Addr anonymizes an IPv6 address, keeping its zone. IPv4 addresses, the
+// unspecified and loopback addresses and multicast groups other than
+// solicited-node ones come back unchanged.
+func (a *Anonymizer) Addr(ip netip.Addr) netip.Addr {
+	if a == nil || !ip.Is6() || ip.Is4In6() || ip.IsUnspecified() || ip.IsLoopback() {
+		return ip
+	}
+	in := ip.As16()
+	out := in
+	switch {
+	case ip.IsMulticast():
+		if !solicitedNodePrefix.Contains(ip.WithZone("")) {
+			return ip
+		}
+		if a.truncate {
+			clear(out[13:])
+		} else {
+			copy(out[13:], a.sum("sn", in[13:]))
+		}
+	case ip.IsLinkLocalUnicast():
+		if a.truncate {
+			clear(out[8:])
+		} else {
+			a.iid(out[8:], in[8:])
+		}
+	default:
+		if a.truncate {
+			clear(out[6:])
+			break
+		}
+		// Each byte of the prefix depends on all before it, so addresses
+		// sharing a prefix still share one after hashing.
+		for i := 1; i < 8; i++ {
+			out[i] = a.sum("prefix", in[:i+1])[0]
+		}
+		a.iid(out[8:], in[8:])
+	}
+	return netip.AddrFrom16(out).WithZone(ip.Zone())
+}
+
+// iid writes the hashed interface identifier in to out. An EUI-64 identifier
+// is rebuilt from the anonymized MAC so it keeps matching that MAC.
+func (a *Anonymizer) iid(out, in []byte) {
+	if in[3] == 0xff && in[4] == 0xfe {
+		mac := net.HardwareAddr{in[0] ^ 0x02, in[1], in[2], in[5], in[6], in[7]}
+		m := a.hwAddr(mac)
+		copy(out, []byte{m[0] ^ 0x02, m[1], m[2], 0xff, 0xfe, m[3], m[4], m[5]})
+		return
+	}
+	copy(out, a.sum("iid", in)[:8])
+}
+
+// MAC anonymizes a 48-bit MAC; other lengths come back unchanged.
+func (a *Anonymizer) MAC(mac net.HardwareAddr) net.HardwareAddr {
+	if a == nil || len(mac) != 6 {
+		return mac
+	}
+	return a.hwAddr(mac)
+}
+
+func (a *Anonymizer) hwAddr(mac net.HardwareAddr) net.HardwareAddr {
+	out := make(net.HardwareAddr, 6)
+	copy(out, mac)
+	from := 3
+	if mac[0]&0x02 != 0 { // locally administered: the OUI says nothing but could identify the host
+		from = 1
+		out[0] = mac[0] & 0x03
+		if !a.truncate {
+			out[0] |= a.sum("mac0", mac)[0] &^ 0x03
+		}
+	}
+	if a.truncate {
+		clear(out[from:])
+	} else {
+		copy(out[from:], a.sum("mac", mac))
+	}
+	return out
+}
+
+// Text anonymizes every IPv6 address, prefix and MAC in s, leaving the rest
+// of it, IPv4 addresses and anything that only looks like one (such as a
+// time of day) untouched.
+func (a *Anonymizer) Text(s string) string {
+	if a == nil {
+		return s
+	}
+	return anonymizeToken.ReplaceAllStringFunc(s, a.token)
+}
+
+func (a *Anonymizer) token(tok string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if out, ok := a.cache[tok]; ok {
+		return out
+	}
+	out := tok
+	if ip, err := netip.ParseAddr(tok); err == nil {
+		out = a.Addr(ip).String()
+	} else if p, err := netip.ParsePrefix(tok); err == nil {
+		out = netip.PrefixFrom(a.Addr(p.Addr()), p.Bits()).Masked().String()
+	} else if mac, err := net.ParseMAC(tok); err == nil && len(mac) == 6 {
+		out = a.MAC(mac).String()
+	}
+	a.cache[tok] = out
+	return out
+}
+
+// JSON anonymizes every string and object key in a JSON document. The
+// result is indented with two spaces, its object keys sorted.
+func (a *Anonymizer) JSON(data []byte) ([]byte, error) {
+	if a == nil {
+		return data, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("anonymize: %w", err)
+	}
+	out, err := json.MarshalIndent(a.value(v), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("anonymize: %w", err)
+	}
+	return out, nil
+}
+
+func (a *Anonymizer) value(v any) any {
+	switch v := v.(type) {
+	case string:
+		return a.Text(v)
+	case []any:
+		for i := range v {
+			v[i] = a.value(v[i])
+		}
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, x := range v {
+			out[a.Text(k)] = a.value(x)
+		}
+		return out
+	}
+	return v
+}
+
+// Graph returns g with its addresses anonymized.
+func (a *Anonymizer) Graph(g SolicitGraph) SolicitGraph {
+	if a == nil {
+		return g
+	}
+	out := SolicitGraph{Nodes: make([]string, len(g.Nodes)), Edges: make([]SolicitEdge, len(g.Edges))}
+	for i, n := range g.Nodes {
+		out.Nodes[i] = a.Text(n)
+	}
+	for i, e := range g.Edges {
+		e.Source, e.Target = a.Text(e.Source), a.Text(e.Target)
+		out.Edges[i] = e
+	}
+	slices.Sort(out.Nodes)
+	slices.SortFunc(out.Edges, func(x, y SolicitEdge) int {
+		return cmp.Or(cmp.Compare(x.Source, y.Source), cmp.Compare(x.Target, y.Target))
+	})
+	return out
+}
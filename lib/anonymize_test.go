@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"net"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnonymizer_Hash(t *testing.T) {
+	a, err := NewAnonymizer("hash", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anon := func(s string) netip.Addr { return a.Addr(netip.MustParseAddr(s)) }
+
+	x, y := anon("2001:db8:1:2::10"), anon("2001:db8:1:2::20")
+	if x == netip.MustParseAddr("2001:db8:1:2::10") || x == y {
+		t.Fatalf("hashed %s, %s: want two new addresses", x, y)
+	}
+	if x != anon("2001:db8:1:2::10") {
+		t.Error("the same address hashed twice differs")
+	}
+	if !netip.PrefixFrom(x, 64).Contains(y) || x.As16()[0] != 0x20 {
+		t.Errorf("%s and %s left their shared /64 or lost the first byte", x, y)
+	}
+	if z := anon("2001:db8:1:3::10"); netip.PrefixFrom(x, 64).Contains(z) || !netip.PrefixFrom(x, 48).Contains(z) {
+		t.Errorf("%s: want a different /64 in the same /48 as %s", z, x)
+	}
+
+	ll := anon("fe80::200:5eff:fe00:5301%eth0")
+	if !ll.IsLinkLocalUnicast() || ll.Zone() != "eth0" {
+		t.Errorf("link-local hashed to %s", ll)
+	}
+	mac := a.MAC(net.HardwareAddr{0x00, 0x00, 0x5e, 0x00, 0x53, 0x01})
+	if mac[0] != 0 || mac[1] != 0 || mac[2] != 0x5e {
+		t.Errorf("MAC hashed to %s, want the OUI kept", mac)
+	}
+	if want := netip.MustParseAddr("fe80::").As16(); true {
+		copy(want[8:], []byte{mac[0] ^ 0x02, mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]})
+		if ll.WithZone("") != netip.AddrFrom16(want) {
+			t.Errorf("EUI-64 address %s does not match its hashed MAC %s", ll, mac)
+		}
+	}
+
+	for _, keep := range []string{"ff02::1", "ff02::fb", "::", "::1", "10.0.0.1"} {
+		if got := anon(keep); got.String() != keep {
+			t.Errorf("%s became %s", keep, got)
+		}
+	}
+
+	other, _ := NewAnonymizer("hash", "other")
+	if other.Addr(netip.MustParseAddr("2001:db8:1:2::10")) == x {
+		t.Error("different keys hash alike")
+	}
+}
+
+func TestAnonymizer_Truncate(t *testing.T) {
+	a, err := NewAnonymizer("truncate", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := a.Text("fe80::1 sent RA for 2001:db8:1:2::/64 from 2001:db8:1:2::10 (02:11:22:33:44:55, 00:00:5e:00:53:01)")
+	want := "fe80:: sent RA for 2001:db8:1::/64 from 2001:db8:1:: (02:00:00:00:00:00, 00:00:5e:00:00:00)"
+	if got != want {
+		t.Errorf("Text = %q\nwant   %q", got, want)
+	}
+	if _, err := NewAnonymizer("scramble", ""); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestAnonymizer_Text(t *testing.T) {
+	a, _ := NewAnonymizer("hash", "k")
+	in := "at 12:04:05.123 2001:db8::1. beef:cafe ff02::1 10.1.2.3"
+	got := a.Text(in)
+	for _, keep := range []string{"at 12:04:05.123 ", ". beef:cafe ff02::1 10.1.2.3"} {
+		if !strings.Contains(got, keep) {
+			t.Errorf("Text(%q) = %q, lost %q", in, got, keep)
+		}
+	}
+	if strings.Contains(got, "2001:db8::1") {
+		t.Errorf("Text(%q) = %q, address kept", in, got)
+	}
+
+	data, err := a.JSON([]byte(`{"groups":{"ff02::1:ff00:1":["fe80::1"]},"count":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); strings.Contains(s, "ff00:1") || strings.Contains(s, `"fe80::1"`) || !strings.Contains(s, `"count": 3`) {
+		t.Errorf("JSON = %s", s)
+	}
+}
+
+func TestHistoryExport_Anonymized(t *testing.T) {
+	db, err := OpenHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordMAC("fe80::1", "00:11:22:33:44:55")
+	if err := db.Record(stats.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	a, _ := NewAnonymizer("truncate", "")
+	if err := db.Export(&buf, "peers", time.Now().Add(-time.Hour), time.Now().Add(time.Hour), a); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), ",fe80::,00:11:22:00:00:00,") {
+		t.Errorf("export not anonymized:\n%s", buf.String())
+	}
+}
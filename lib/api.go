@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// APIHandler serves read-only JSON views of the stats:
+//
+//	GET /api/v1/alerts               stored alerts, newest first
+//	GET /api/v1/bindings             recent IP/MAC binding changes, newest first (see NDPStats.RecordBinding)
+//	GET /api/v1/peers?filter=<expr>  peer summaries, optionally filtered (see Filter);
+//	                                 merged=true folds one address on several links (see MergePeers)
+//	GET /api/v1/peers/{address}/story everything known about the host using address (see PeerStory)
+//	GET /api/v1/summary              network-wide message counts and rates per type
+//	GET /api/v1/targets              solicited addresses by popularity (see Popularity)
+//	GET /api/v1/graph?format=<fmt>   who solicits whom (see SolicitGraph) as json, dot or graphml
+//	GET /api/v1/interfaces           peers, routers and message counts per capture interface (see InterfaceSummary)
+//	GET /api/v1/duplicates           duplicated packets per capture interface (see InterfaceDuplicates)
+//	GET /api/v1/multicast            MLD Done-without-Join and silent groups (see MulticastSanity)
+//	GET /api/v1/ipv6-health          IPv6 health score per segment (see SegmentHealth)
+//	GET /api/v1/nud                  NUD probing behavior per host, flagged hosts first (see NUDBehavior)
+//	GET /api/v1/options              NDP option usage by stack and message type (see OptionUsage)
+//	GET /api/v1/routers              routers currently advertising
+//	GET /api/v1/routers/gone         routers that stopped advertising
+//	GET /api/v1/status               build, capture, sinks, rule packs and redacted config (see Status)
+//
+// Every view reads the shared snapshot (see NDPStats.Shared), so requests
+// within one tick agree and don't contend with capture for the stats lock.
+// Stories add the kernel neighbor cache, read at most every 5s however
+// often they are asked for.
+// It also serves the RESTCONF view of the same data (see RESTCONFHandler).
+func APIHandler(stats *NDPStats) http.Handler {
+	mux := http.NewServeMux()
+	neighbors := &neighborCache{}
+	mux.HandleFunc("GET /api/v1/peers", func(w http.ResponseWriter, r *http.Request) {
+		var f *Filter
+		if expr := r.URL.Query().Get("filter"); expr != "" {
+			var err error
+			if f, err = ParseFilter(expr); err != nil {
+				writeAPIError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		peers := stats.Shared().Peers
+		if r.URL.Query().Get("merged") == "true" {
+			peers = MergePeers(peers)
+		}
+		writeJSON(w, FilterPeers(peers, f))
+	})
+	mux.HandleFunc("GET /api/v1/peers/{address}/story", func(w http.ResponseWriter, r *http.Request) {
+		story, ok := stats.Story(r.PathValue("address"))
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, fmt.Errorf("no peer %s", r.PathValue("address")))
+			return
+		}
+		if kernel, err := neighbors.get(r.Context()); err == nil {
+			story.Neighbors = story.NeighborsOf(kernel)
+		}
+		writeJSON(w, story)
+	})
+	mux.HandleFunc("GET /api/v1/alerts", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().Alerts)
+	})
+	mux.HandleFunc("GET /api/v1/bindings", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().Bindings)
+	})
+	mux.HandleFunc("GET /api/v1/summary", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().Summary)
+	})
+	mux.HandleFunc("GET /api/v1/targets", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().SolicitedTargets)
+	})
+	mux.HandleFunc("GET /api/v1/graph", func(w http.ResponseWriter, r *http.Request) {
+		g := stats.Shared().Graph()
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "json":
+			writeJSON(w, g)
+		case "dot":
+			w.Header().Set("Content-Type", "text/vnd.graphviz")
+			_ = g.WriteDOT(w)
+		case "graphml":
+			w.Header().Set("Content-Type", "application/graphml+xml")
+			_ = g.WriteGraphML(w)
+		default:
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q (want json, dot or graphml)", format))
+		}
+	})
+	mux.HandleFunc("GET /api/v1/interfaces", func(w http.ResponseWriter, r *http.Request) {
+		snap := stats.Shared()
+		writeJSON(w, SummarizeInterfaces(snap.Peers, snap.Routers))
+	})
+	mux.HandleFunc("GET /api/v1/duplicates", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().Duplicates)
+	})
+	mux.HandleFunc("GET /api/v1/multicast", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().Multicast)
+	})
+	mux.HandleFunc("GET /api/v1/ipv6-health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().IPv6Health)
+	})
+	mux.HandleFunc("GET /api/v1/nud", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().NUD)
+	})
+	mux.HandleFunc("GET /api/v1/options", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().OptionUsage)
+	})
+	mux.HandleFunc("GET /api/v1/routers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().Routers)
+	})
+	mux.HandleFunc("GET /api/v1/routers/gone", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Shared().Gone)
+	})
+	mux.HandleFunc("GET /api/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, stats.Status())
+	})
+	restconf := RESTCONFHandler(stats)
+	mux.Handle("/.well-known/host-meta", restconf)
+	mux.Handle("/restconf", restconf)
+	mux.Handle("/restconf/", restconf)
+	return mux
+}
+
+// ServeAPI runs the JSON API until ctx is cancelled. With fed set, it also
+// serves the federation's instance list (see Federation.Handler). With
+// cfg.AdminToken set, it serves the admin endpoints too (see adminHandler);
+// level is the log level they change, and may be nil.
+func ServeAPI(ctx context.Context, cfg APIConfig, stats *NDPStats, fed *Federation, level *slog.LevelVar, logger *slog.Logger) error {
+	logger.Info("serving api", "listen", cfg.Listen, "read_token", cfg.ReadToken != "", "admin_token", cfg.AdminToken != "")
+	if err := serveHTTP(ctx, cfg.Listen, apiServer(cfg, stats, fed, level, logger)); err != nil {
+		return fmt.Errorf("api: %w", err)
+	}
+	return nil
+}
+
+// apiServer builds the handler ServeAPI serves.
+func apiServer(cfg APIConfig, stats *NDPStats, fed *Federation, level *slog.LevelVar, logger *slog.Logger) http.Handler {
+	handler := APIHandler(stats)
+	if fed != nil {
+		handler = fed.Handler(handler)
+	}
+	if cfg.AdminToken != "" {
+		handler = adminHandler(handler, stats, level, logger)
+	}
+	return cfg.authorize(handler)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
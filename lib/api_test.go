@@ -0,0 +1,204 @@
+package lib
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIHandler_PeersFilter(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_advertisement")
+	stats.RecordMessage("fe80::2", "neighbor_solicitation")
+
+	rec := httptest.NewRecorder()
+	q := url.Values{"filter": {"counts.ra > 0"}}
+	APIHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/peers?"+q.Encode(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var peers []PeerSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &peers); err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || peers[0].Address != "fe80::1" {
+		t.Errorf("peers = %+v, want only fe80::1", peers)
+	}
+}
+
+func TestAPIHandler_BadFilter(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+
+	rec := httptest.NewRecorder()
+	q := url.Values{"filter": {"nope =="}}
+	APIHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/peers?"+q.Encode(), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestRESTCONF_Neighbor(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_advertisement")
+	stats.RecordMessage("fe80::2", "neighbor_solicitation")
+	stats.RecordMAC("fe80::2", "aa:bb:cc:dd:ee:ff")
+
+	rec := httptest.NewRecorder()
+	APIHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/restconf/data/ndpeekr:ndpeekr/neighbors/neighbor=fe80%3A0%3A%3A2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != restconfMediaType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var body struct {
+		Neighbor []yangNeighbor `json:"ndpeekr:neighbor"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Neighbor) != 1 {
+		t.Fatalf("neighbor = %+v, want one entry", body.Neighbor)
+	}
+	n := body.Neighbor[0]
+	if n.Address != "fe80::2" || n.MAC != "aa:bb:cc:dd:ee:ff" || n.TotalMessages != 1 {
+		t.Errorf("neighbor = %+v", n)
+	}
+	if len(n.MessageCounts) != 1 || n.MessageCounts[0] != (yangMessageCount{Type: "neighbor_solicitation", Count: 1}) {
+		t.Errorf("message-count = %+v", n.MessageCounts)
+	}
+}
+
+func TestRESTCONF_Tree(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordRouter(RouterInfo{
+		Address:  "fe80::1",
+		Lifetime: 1800 * time.Second,
+		Prefixes: []PrefixInfo{{Prefix: "2001:db8::/64", ValidLifetime: time.Hour, OnLink: true}},
+	})
+
+	rec := httptest.NewRecorder()
+	APIHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/restconf/data/ndpeekr:ndpeekr", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Tree yangTree `json:"ndpeekr:ndpeekr"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	routers := body.Tree.Routers.Router
+	if len(routers) != 1 || routers[0].Lifetime != 1800 {
+		t.Fatalf("routers = %+v", routers)
+	}
+	if p := routers[0].Prefixes; len(p) != 1 || p[0].Prefix != "2001:db8::/64" || p[0].ValidLifetime != 3600 {
+		t.Errorf("prefixes = %+v", p)
+	}
+}
+
+func TestRESTCONF_Errors(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	tests := []struct {
+		method, path string
+		code         int
+		tag          string
+	}{
+		{"GET", "/restconf/data/ndpeekr:ndpeekr/neighbors/neighbor=fe80::9", http.StatusNotFound, "invalid-value"},
+		{"GET", "/restconf/data/other:tree", http.StatusNotFound, "invalid-value"},
+		{"DELETE", "/restconf/data/ndpeekr:ndpeekr", http.StatusMethodNotAllowed, "operation-not-supported"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		APIHandler(stats).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, rec.Code, tt.code)
+			continue
+		}
+		var body struct {
+			Errors struct {
+				Error []struct {
+					Tag string `json:"error-tag"`
+				} `json:"error"`
+			} `json:"ietf-restconf:errors"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s: %v", tt.method, tt.path, err)
+		}
+		if len(body.Errors.Error) != 1 || body.Errors.Error[0].Tag != tt.tag {
+			t.Errorf("%s %s: errors = %+v, want tag %s", tt.method, tt.path, body.Errors, tt.tag)
+		}
+	}
+}
+
+func TestAPIServer_Tokens(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordAlert(Alert{Category: "rogue_ra", Source: "fe80::1"})
+	stats.RecordAlert(Alert{Category: "dad_conflict", Source: "fe80::2"})
+	level := new(slog.LevelVar)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := apiServer(APIConfig{ReadToken: "r", AdminToken: "a"}, stats, nil, level, logger)
+
+	tests := []struct {
+		method, path, token, body string
+		code                      int
+	}{
+		{"GET", "/api/v1/summary", "", "", http.StatusUnauthorized},
+		{"GET", "/api/v1/summary", "wrong", "", http.StatusUnauthorized},
+		{"GET", "/api/v1/summary", "r", "", http.StatusOK},
+		{"GET", "/api/v1/summary", "a", "", http.StatusOK},
+		{"POST", "/api/v1/alerts/ack", "r", `{"category":"rogue_ra"}`, http.StatusForbidden},
+		{"POST", "/api/v1/alerts/ack", "a", `{"category":"rogue_ra"}`, http.StatusOK},
+		{"PUT", "/api/v1/log-level", "a", `{"level":"loud"}`, http.StatusBadRequest},
+		{"PUT", "/api/v1/log-level", "a", `{"level":"debug"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s %s with %q: status = %d, want %d: %s", tt.method, tt.path, tt.token, rec.Code, tt.code, rec.Body)
+		}
+	}
+
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("log level = %v, want debug", level.Level())
+	}
+	for _, a := range stats.GetAlerts() {
+		if a.Acked != (a.Category == "rogue_ra") {
+			t.Errorf("alert %+v: acked = %v", a, a.Acked)
+		}
+	}
+}
+
+func TestAPIServer_AdminNeedsToken(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1%eth0", "neighbor_solicitation")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Without admin_token the admin endpoints aren't served at all.
+	rec := httptest.NewRecorder()
+	apiServer(APIConfig{}, stats, nil, nil, logger).ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/stats/clear", nil))
+	if rec.Code == http.StatusNoContent || len(stats.GetStats()) != 1 {
+		t.Fatalf("stats cleared without admin_token: %d", rec.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/stats/clear", nil)
+	req.Header.Set("Authorization", "Bearer a")
+	rec = httptest.NewRecorder()
+	apiServer(APIConfig{AdminToken: "a"}, stats, nil, nil, logger).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || len(stats.GetStats()) != 0 {
+		t.Errorf("clear: status = %d, %d peers left", rec.Code, len(stats.GetStats()))
+	}
+}
@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// apiRole is what a request's bearer token allows.
+type apiRole int
+
+const (
+	roleNone apiRole = iota
+	roleRead
+	roleAdmin
+)
+
+// role returns what r's bearer token allows under cfg.
+func (cfg APIConfig) role(r *http.Request) apiRole {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return roleNone
+	}
+	switch {
+	case cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) == 1:
+		return roleAdmin
+	case cfg.ReadToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.ReadToken)) == 1:
+		return roleRead
+	}
+	return roleNone
+}
+
+// authorize wraps next so GET and HEAD requests need a read or admin token
+// when read_token is set, and every other request needs the admin token
+// when admin_token is set. A missing or unknown token gets 401; a read
+// token used for an action gets 403.
+func (cfg APIConfig) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		need, required := roleAdmin, cfg.AdminToken != ""
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			need, required = roleRead, cfg.ReadToken != ""
+		}
+		if !required {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch role := cfg.role(r); {
+		case role == roleNone:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ndpeekr"`)
+			writeAPIError(w, http.StatusUnauthorized, fmt.Errorf("missing or unknown bearer token"))
+		case role < need:
+			writeAPIError(w, http.StatusForbidden, fmt.Errorf("%s %s needs the admin token", r.Method, r.URL.Path))
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// adminHandler serves the endpoints that change state in front of next:
+//
+//	POST /api/v1/stats/clear         forget peers, routers and alerts (see NDPStats.Clear)
+//	POST /api/v1/alerts/ack          acknowledge alerts: {"category": "...", "source": "..."},
+//	                                 either may be empty to match any (see NDPStats.AckAlerts)
+//	GET  /api/v1/log-level           the current log level
+//	PUT  /api/v1/log-level           change it: {"level": "debug"}
+//
+// The log-level endpoints are only served with level set.
+func adminHandler(next http.Handler, stats *NDPStats, level *slog.LevelVar, logger *slog.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", next)
+	mux.HandleFunc("POST /api/v1/stats/clear", func(w http.ResponseWriter, r *http.Request) {
+		stats.Clear()
+		logger.Info("stats cleared", "remote", r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /api/v1/alerts/ack", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Category string `json:"category"`
+			Source   string `json:"source"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil && err != io.EOF {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		n := stats.AckAlerts(req.Category, req.Source)
+		logger.Info("alerts acknowledged", "category", req.Category, "source", req.Source, "count", n, "remote", r.RemoteAddr)
+		writeJSON(w, map[string]int{"acked": n})
+	})
+	if level != nil {
+		mux.HandleFunc("GET /api/v1/log-level", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]string{"level": strings.ToLower(level.Level().String())})
+		})
+		mux.HandleFunc("PUT /api/v1/log-level", func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Level string `json:"level"`
+			}
+			if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil {
+				writeAPIError(w, http.StatusBadRequest, err)
+				return
+			}
+			var l slog.Level
+			if err := l.UnmarshalText([]byte(req.Level)); err != nil {
+				writeAPIError(w, http.StatusBadRequest, fmt.Errorf("unknown level %q (want debug, info, warn or error)", req.Level))
+				return
+			}
+			level.Set(l)
+			logger.Info("log level changed", "level", l, "remote", r.RemoteAddr)
+			writeJSON(w, map[string]string{"level": strings.ToLower(l.String())})
+		})
+	}
+	return mux
+}
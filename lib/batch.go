@@ -0,0 +1,267 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// markPrefix and unmarkedPrefix start the address cell of marked and
+// unmarked peer rows while any peer is marked.
+const (
+	markPrefix     = "✓ "
+	unmarkedPrefix = "  "
+)
+
+// batchBuiltins are the batch actions every selection has, before the
+// configured peer actions.
+var batchBuiltins = []struct{ name, help string }{
+	{"ignore", "hide the peers until exit"},
+	{"tag", "add a tag until exit (filter with tags == ...)"},
+	{"export", "write the peers to a JSON file in the snapshot directory"},
+}
+
+// IgnorePeers hides the peers at addrs like the ignore rules do, until exit.
+func (s *NDPStats) IgnorePeers(addrs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ignored == nil {
+		s.ignored = make(map[string]bool)
+	}
+	for _, a := range addrs {
+		s.ignored[canonicalAddr(a)] = true
+	}
+}
+
+// TagPeers adds tag to the peers at addrs, on top of the tags the inventory
+// gives them, until exit.
+func (s *NDPStats) TagPeers(addrs []string, tag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tags == nil {
+		s.tags = make(map[string][]string)
+	}
+	for _, a := range addrs {
+		a = canonicalAddr(a)
+		if !slices.Contains(s.tags[a], tag) && !slices.Contains(s.enrich[a].Tags, tag) {
+			s.tags[a] = append(s.tags[a], tag)
+		}
+	}
+}
+
+// WritePeersFile writes peers as JSON to a timestamped file in dir,
+// anonymized if anon is set, and returns its path.
+func WritePeersFile(peers []PeerSummary, dir, instance string, at time.Time, anon *Anonymizer) (string, error) {
+	path := filepath.Join(dir, instanceFileName("peers", instance, at.Format("20060102-150405.000"), ".json"))
+	data, err := json.MarshalIndent(peers, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode peers: %w", err)
+	}
+	if data, err = anon.JSON(data); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// peersSavedMsg reports the outcome of a batch export.
+type peersSavedMsg struct {
+	path string
+	n    int
+	err  error
+}
+
+// rowAddress returns the address in a peer row's first cell.
+func rowAddress(cell string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(cell, markPrefix), unmarkedPrefix)
+}
+
+// markRows prefixes the address cell of rows with whether it is marked.
+func markRows(rows []table.Row, marked map[string]bool) {
+	if len(marked) == 0 {
+		return
+	}
+	for _, row := range rows {
+		if marked[row[0]] {
+			row[0] = markPrefix + row[0]
+		} else {
+			row[0] = unmarkedPrefix + row[0]
+		}
+	}
+}
+
+// toggleMark marks or unmarks the peer under the cursor and moves the
+// cursor down, so holding the key marks a run of rows.
+func (m *Model) toggleMark() {
+	p := m.cursorPeer()
+	if p == nil {
+		return
+	}
+	if m.marked[p.Address] {
+		delete(m.marked, p.Address)
+	} else {
+		m.marked[p.Address] = true
+	}
+	m.setPeerRows()
+	m.peerTable.MoveDown(1)
+}
+
+// toggleMarkAll marks every visible peer, or unmarks them all if they
+// already are.
+func (m *Model) toggleMarkAll() {
+	peers := m.visiblePeers()
+	all := len(peers) > 0
+	for _, p := range peers {
+		all = all && m.marked[p.Address]
+	}
+	for _, p := range peers {
+		if all {
+			delete(m.marked, p.Address)
+		} else {
+			m.marked[p.Address] = true
+		}
+	}
+	m.setPeerRows()
+}
+
+// markedPeers returns the peers behind the marks. A mark set while links
+// were merged covers the address on every link.
+func (m Model) markedPeers() []PeerSummary {
+	var out []PeerSummary
+	for _, p := range m.peers {
+		if m.marked[p.Address] || m.marked[unzoned(p.Address)] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// openBatch shows the batch action menu for the marked peers.
+func (m *Model) openBatch() {
+	if len(m.markedPeers()) == 0 {
+		m.setStatus("None of the marked peers are known any more")
+		return
+	}
+	m.activeView = "batch"
+}
+
+// handleBatchKey runs the batch action picked with a number key.
+func (m Model) handleBatchKey(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "esc":
+		m.activeView = "table"
+		return m, nil
+	case "q":
+		m.quitting = true
+		return m, tea.Quit
+	}
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 1 || i > min(len(batchBuiltins)+len(m.actions), 9) {
+		return m, nil
+	}
+	peers := m.markedPeers()
+	addrs := make([]string, len(peers))
+	for j, p := range peers {
+		addrs[j] = p.Address
+	}
+	m.activeView = "table"
+
+	if i > len(batchBuiltins) {
+		action := m.actions[i-len(batchBuiltins)-1]
+		run, err := action.StartBatch(peers)
+		if err != nil {
+			m.setStatus(err.Error())
+			return m, nil
+		}
+		m.actionRun = run
+		m.actionReturn = "table"
+		m.actionPane.SetContent("")
+		m.actionPane.GotoTop()
+		m.activeView = "output"
+		return m, waitAction(run)
+	}
+
+	switch batchBuiltins[i-1].name {
+	case "ignore", "tag":
+		if m.segment != nil || m.travelling {
+			m.setStatus("Ignoring and tagging only apply to this instance's live stats")
+			return m, nil
+		}
+		if batchBuiltins[i-1].name == "tag" {
+			m.tagging = true
+			m.tagInput.SetValue("")
+			return m, m.tagInput.Focus()
+		}
+		m.stats.IgnorePeers(addrs)
+		m.peers = slices.DeleteFunc(m.peers, func(p PeerSummary) bool { return slices.Contains(addrs, p.Address) })
+		clear(m.marked)
+		m.setPeerRows()
+		m.setStatus(fmt.Sprintf("Ignoring %d peers until exit", len(addrs)))
+	case "export":
+		for j := range peers {
+			peers[j] = m.privacy.Peer(peers[j])
+		}
+		dir, instance, anon := m.snapshotDir, m.instance, m.anon
+		return m, func() tea.Msg {
+			path, err := WritePeersFile(peers, dir, instance, time.Now(), anon)
+			return peersSavedMsg{path: path, n: len(peers), err: err}
+		}
+	}
+	return m, nil
+}
+
+// handleTagKey edits the tag for the marked peers. Enter applies it, Esc
+// cancels.
+func (m Model) handleTagKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.tagging = false
+		m.tagInput.Blur()
+		return m, nil
+	case "enter":
+		tag := strings.TrimSpace(m.tagInput.Value())
+		m.tagging = false
+		m.tagInput.Blur()
+		if tag == "" {
+			return m, nil
+		}
+		var addrs []string
+		for _, p := range m.markedPeers() {
+			addrs = append(addrs, p.Address)
+		}
+		m.stats.TagPeers(addrs, tag)
+		m.setStatus(fmt.Sprintf("Tagged %d peers %s until exit", len(addrs), tag))
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.tagInput, cmd = m.tagInput.Update(msg)
+	return m, cmd
+}
+
+// renderBatchMenu lists the batch actions for the marked peers.
+func (m Model) renderBatchMenu() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render(fmt.Sprintf("Batch actions on %d marked peers", len(m.markedPeers()))))
+	b.WriteString("\n\n")
+	n := 0
+	for _, a := range batchBuiltins {
+		n++
+		b.WriteString(fmt.Sprintf("  %d  %-16s %s\n", n, a.name, detailLabel.Render(a.help)))
+	}
+	for _, a := range m.actions {
+		if n++; n > 9 {
+			break
+		}
+		b.WriteString(fmt.Sprintf("  %d  %-16s %s\n", n, a.Name(), detailLabel.Render("run on each peer in turn")))
+	}
+	return b.String()
+}
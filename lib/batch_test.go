@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+func TestBatchIgnoreAndTag(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1%eth0", "neighbor_solicitation")
+	stats.RecordMessage("fe80::2%eth0", "neighbor_solicitation")
+	stats.RecordMessage("fe80::3%eth0", "neighbor_solicitation")
+
+	stats.IgnorePeers([]string{"fe80::1%eth0"})
+	stats.TagPeers([]string{"fe80::2%eth0", "fe80::3%eth0"}, "lab")
+	stats.TagPeers([]string{"fe80::2%eth0"}, "lab")
+
+	peers := stats.GetStats()
+	if len(peers) != 2 {
+		t.Fatalf("got %d peers, want the ignored one hidden", len(peers))
+	}
+	for _, p := range peers {
+		if p.Address == "fe80::1%eth0" {
+			t.Errorf("ignored peer listed: %+v", p)
+		}
+		if !slices.Equal(p.Tags, []string{"lab"}) {
+			t.Errorf("%s tags = %v, want [lab] once", p.Address, p.Tags)
+		}
+	}
+}
+
+func TestMarkRows(t *testing.T) {
+	rows := []table.Row{{"fe80::1"}, {"fe80::2"}}
+	markRows(rows, nil)
+	if rows[0][0] != "fe80::1" {
+		t.Errorf("rows prefixed with nothing marked: %q", rows[0][0])
+	}
+	markRows(rows, map[string]bool{"fe80::2": true})
+	if rows[0][0] != unmarkedPrefix+"fe80::1" || rows[1][0] != markPrefix+"fe80::2" {
+		t.Errorf("rows = %q", rows)
+	}
+	for i, want := range []string{"fe80::1", "fe80::2"} {
+		if got := rowAddress(rows[i][0]); got != want {
+			t.Errorf("rowAddress(%q) = %q, want %q", rows[i][0], got, want)
+		}
+	}
+}
+
+func TestWritePeersFile(t *testing.T) {
+	dir := t.TempDir()
+	peers := []PeerSummary{{Address: "fe80::1%eth0", MAC: "00:11:22:33:44:55"}}
+	path, err := WritePeersFile(peers, dir, "", time.Now(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []PeerSummary
+	if err := json.Unmarshal(data, &got); err != nil || len(got) != 1 || got[0].MAC != peers[0].MAC {
+		t.Errorf("file = %s, %v", data, err)
+	}
+}
@@ -0,0 +1,193 @@
+package lib
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Binding change kinds, also the categories of the alerts they raise.
+const (
+	// BindingFlip is an address advertised by a MAC other than the one it
+	// was bound to.
+	BindingFlip = "binding_flip"
+	// BindingFlood is a MAC claiming many addresses in a short time.
+	BindingFlood = "binding_flood"
+)
+
+const (
+	// bindingFloodAddrs is how many distinct addresses one MAC may claim
+	// within bindingFloodWindow before it counts as a flood. Hosts have a
+	// link-local, a stable and a temporary address or two per prefix.
+	bindingFloodAddrs  = 16
+	bindingFloodWindow = time.Minute
+	// bindingFlapChanges is how many MAC changes of one address within the
+	// stats window make a flip critical: two MACs taking turns answering
+	// for it, which is what a spoofer racing the real owner looks like.
+	bindingFlapChanges = 3
+	// maxBindingChanges caps the binding changes kept for display.
+	maxBindingChanges = 200
+)
+
+// BindingChange is an IP/MAC binding that changed, the classic NDP
+// spoofing signature.
+type BindingChange struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`    // BindingFlip or BindingFlood
+	Address string    `json:"address"` // the address that flipped, or the latest one the MAC claimed
+	MAC     string    `json:"mac"`     // the MAC now claiming Address
+	// OldMAC is the MAC Address was bound to before a flip.
+	OldMAC string `json:"old_mac,omitempty"`
+	// Changes counts the MAC changes of Address within the window, this
+	// one included (flips only).
+	Changes int `json:"changes,omitempty"`
+	// Addresses are the addresses the MAC claimed within a minute (floods
+	// only).
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// macClaims are the addresses one MAC claimed and when. Claims older than
+// bindingFloodWindow are dropped lazily: by Prune, and when the MAC seems
+// to cross the flood threshold.
+type macClaims struct {
+	at map[string]time.Time
+	// flooding is set once a flood was raised, until Prune finds the MAC
+	// back under the threshold.
+	flooding bool
+}
+
+// RecordBinding checks the claim that ip is at mac against what is known
+// before RecordMAC stores it, and returns what changed: a flip when ip was
+// bound to another MAC, a flood when mac has now claimed more than
+// bindingFloodAddrs addresses within bindingFloodWindow. Changes involving
+// a virtual router MAC (VRRP, HSRP) are failovers and aren't returned.
+func (s *NDPStats) RecordBinding(ip, mac string, now time.Time) []BindingChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ip = canonicalAddr(ip)
+	var changes []BindingChange
+	if peer, ok := s.peers[ip]; ok && peer.MAC != "" && !strings.EqualFold(peer.MAC, mac) && !isVirtualMAC(peer.MAC) && !isVirtualMAC(mac) {
+		n := 1
+		for i, h := range peer.MACHistory {
+			// The first entry is the MAC the address started with.
+			if i > 0 && now.Sub(h.Since) < s.window {
+				n++
+			}
+		}
+		changes = append(changes, BindingChange{Time: now, Kind: BindingFlip, Address: ip, MAC: mac, OldMAC: peer.MAC, Changes: n})
+	}
+
+	claims := s.macClaims[mac]
+	if claims == nil {
+		claims = &macClaims{at: make(map[string]time.Time)}
+		s.macClaims[mac] = claims
+	}
+	_, known := claims.at[ip]
+	claims.at[ip] = now
+	// Raise a flood on the claim that crosses the threshold, not on every
+	// claim after it. Only then are stale claims dropped here, so a flood
+	// costs no more per packet than any other claim.
+	if !known && !claims.flooding && len(claims.at) > bindingFloodAddrs {
+		claims.pruneLocked(now)
+		if len(claims.at) > bindingFloodAddrs && !isVirtualMAC(mac) {
+			claims.flooding = true
+			addrs := slices.Sorted(maps.Keys(claims.at))
+			changes = append(changes, BindingChange{Time: now, Kind: BindingFlood, Address: ip, MAC: mac, Addresses: addrs})
+		}
+	}
+
+	s.bindingChanges = append(s.bindingChanges, changes...)
+	if len(s.bindingChanges) > maxBindingChanges {
+		s.bindingChanges = s.bindingChanges[len(s.bindingChanges)-maxBindingChanges:]
+	}
+	return changes
+}
+
+func isVirtualMAC(mac string) bool {
+	_, _, ok := VirtualMAC(mac)
+	return ok
+}
+
+// pruneBindingsLocked forgets claims older than bindingFloodWindow.
+// Callers must hold s.mu.
+func (s *NDPStats) pruneBindingsLocked(now time.Time) {
+	for mac, claims := range s.macClaims {
+		claims.pruneLocked(now)
+		claims.flooding = claims.flooding && len(claims.at) > bindingFloodAddrs
+		if len(claims.at) == 0 {
+			delete(s.macClaims, mac)
+		}
+	}
+}
+
+// pruneLocked drops the claims older than bindingFloodWindow. Callers must
+// hold the stats lock.
+func (c *macClaims) pruneLocked(now time.Time) {
+	for addr, at := range c.at {
+		if now.Sub(at) >= bindingFloodWindow {
+			delete(c.at, addr)
+		}
+	}
+}
+
+// GetBindingChanges returns the recent binding changes, newest first.
+func (s *NDPStats) GetBindingChanges() []BindingChange {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bindingChangesLocked()
+}
+
+func (s *NDPStats) bindingChangesLocked() []BindingChange {
+	result := make([]BindingChange, len(s.bindingChanges))
+	for i, c := range s.bindingChanges {
+		result[len(s.bindingChanges)-1-i] = c
+	}
+	return result
+}
+
+// bindingAlert describes c as an alert.
+func bindingAlert(c BindingChange) Alert {
+	a := Alert{
+		Time:     c.Time,
+		Severity: SeverityWarning,
+		Category: c.Kind,
+		Source:   c.Address,
+		Binding:  &c,
+	}
+	switch c.Kind {
+	case BindingFlip:
+		a.Message = fmt.Sprintf("%s moved from %s to %s", c.Address, c.OldMAC, c.MAC)
+		if c.Changes >= bindingFlapChanges {
+			a.Severity = SeverityCritical
+			a.Message += fmt.Sprintf(" (%d MAC changes within the window: possible NDP spoofing)", c.Changes)
+		}
+	case BindingFlood:
+		a.Message = fmt.Sprintf("%s claimed %d addresses within %s: possible NDP spoofing or address scan", c.MAC, len(c.Addresses), bindingFloodWindow)
+	}
+	return a
+}
+
+// checkBinding raises an alert for every binding change src's claim that
+// addr is at mac makes (see NDPStats.RecordBinding), once per address or
+// MAC per window. addr is src itself except for an NA, whose TLLA is its
+// target's. Changes claimed by an ND proxy are only logged: it answers
+// for other hosts' addresses, many of them, with its own MAC.
+func (l *NDPListener) checkBinding(src, addr, mac, port string) {
+	for _, c := range l.cfg.Stats.RecordBinding(addr, mac, l.now()) {
+		if l.cfg.Stats.IsNDProxy(src) {
+			l.cfg.Logger.Debug("nd proxy binding change", "kind", c.Kind, "src", src, "addr", addr, "mac", mac, "old_mac", c.OldMAC)
+			continue
+		}
+		a := bindingAlert(c)
+		// A flapping address still alerts after the warning for its first flip.
+		key := c.Kind + "|" + c.Address + "|" + a.Severity.String()
+		if c.Kind == BindingFlood {
+			key = c.Kind + "|" + c.MAC
+		}
+		a.Port = port
+		l.raiseAlertOnce(key, a)
+	}
+}
@@ -0,0 +1,140 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRecordBinding_Flip(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	now := time.Now()
+	stats.RecordMessage("fe80::1%eth0", "neighbor_advertisement")
+
+	if c := stats.RecordBinding("fe80::1%eth0", "00:11:22:33:44:55", now); len(c) != 0 {
+		t.Fatalf("first MAC = %+v, want no change", c)
+	}
+	stats.RecordMAC("fe80::1%eth0", "00:11:22:33:44:55")
+	if c := stats.RecordBinding("fe80::1%eth0", "00:11:22:33:44:55", now); len(c) != 0 {
+		t.Fatalf("same MAC = %+v, want no change", c)
+	}
+
+	// The address taking turns between two MACs turns critical.
+	macs := []string{"66:77:88:99:aa:bb", "00:11:22:33:44:55", "66:77:88:99:aa:bb"}
+	var last BindingChange
+	for i, mac := range macs {
+		c := stats.RecordBinding("fe80::1%eth0", mac, now)
+		if len(c) != 1 || c[0].Kind != BindingFlip || c[0].MAC != mac || c[0].Changes != i+1 {
+			t.Fatalf("flip %d = %+v", i, c)
+		}
+		stats.RecordMAC("fe80::1%eth0", mac)
+		last = c[0]
+	}
+	if a := bindingAlert(last); a.Severity != SeverityCritical || a.Binding.OldMAC != "00:11:22:33:44:55" {
+		t.Errorf("alert = %+v, want critical with the old MAC", a)
+	}
+	if got := stats.GetBindingChanges(); len(got) != 3 || got[0].MAC != "66:77:88:99:aa:bb" {
+		t.Errorf("binding changes = %+v, want three, newest first", got)
+	}
+
+	// A VRRP failover is no flip.
+	stats.RecordMAC("fe80::a%eth0", "00:00:5e:00:02:01")
+	if c := stats.RecordBinding("fe80::a%eth0", "00:11:22:33:44:66", now); len(c) != 0 {
+		t.Errorf("virtual MAC = %+v, want no change", c)
+	}
+}
+
+func TestRecordBinding_Flood(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	now := time.Now()
+	mac := "02:00:00:00:00:01"
+
+	var floods int
+	for i := range bindingFloodAddrs + 5 {
+		for _, c := range stats.RecordBinding(fmt.Sprintf("2001:db8::%x", i+1), mac, now) {
+			if c.Kind != BindingFlood || len(c.Addresses) != bindingFloodAddrs+1 {
+				t.Errorf("change = %+v", c)
+			}
+			floods++
+		}
+	}
+	if floods != 1 {
+		t.Errorf("%d floods, want one as the threshold is crossed", floods)
+	}
+
+	// Claims a minute old don't count.
+	later := now.Add(bindingFloodWindow)
+	for i := range bindingFloodAddrs {
+		if c := stats.RecordBinding(fmt.Sprintf("2001:db8::1:%x", i+1), mac, later); len(c) != 0 {
+			t.Fatalf("claim %d a minute later = %+v", i, c)
+		}
+	}
+
+	// Once Prune finds the MAC quiet, a new flood is raised again, also
+	// past claims that went stale without a Prune.
+	stats.mu.Lock()
+	stats.pruneBindingsLocked(later.Add(bindingFloodWindow))
+	stats.mu.Unlock()
+	for i := range bindingFloodAddrs {
+		stats.RecordBinding(fmt.Sprintf("2001:db8::2:%x", i+1), mac, later.Add(bindingFloodWindow))
+	}
+	floods = 0
+	for i := range bindingFloodAddrs + 1 {
+		for _, c := range stats.RecordBinding(fmt.Sprintf("2001:db8::3:%x", i+1), mac, later.Add(2*bindingFloodWindow)) {
+			if len(c.Addresses) != bindingFloodAddrs+1 {
+				t.Errorf("change = %+v", c)
+			}
+			floods++
+		}
+	}
+	if floods != 1 {
+		t.Errorf("%d floods after the MAC went quiet, want one", floods)
+	}
+}
+
+func TestHandlePacket_BindingFlip(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	for _, mac := range []net.HardwareAddr{{0x02, 0, 0, 0, 0, 1}, {0x02, 0, 0, 0, 0, 2}} {
+		pkt := buildIPv6Packet("2001:db8::1", "ff02::1", 255, nil, nhICMPv6, buildNA(net.ParseIP("2001:db8::1"), mac))
+		l.handlePacket(pkt, 0, "eth0")
+	}
+
+	alerts := stats.GetAlerts()
+	if len(alerts) != 1 || alerts[0].Category != BindingFlip || alerts[0].Binding == nil || alerts[0].Binding.OldMAC != "02:00:00:00:00:01" {
+		t.Fatalf("alerts = %+v, want one binding flip", alerts)
+	}
+	if p := stats.GetStats(); len(p) != 1 || len(p[0].MACHistory) != 2 {
+		t.Errorf("peers = %+v, want both MACs in the history", p)
+	}
+}
+
+func TestHandlePacket_SpoofedNATarget(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	victim, attacker := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}, net.HardwareAddr{0x02, 0, 0, 0, 0, 0x66}
+	l.handlePacket(buildIPv6Packet("2001:db8::1", "ff02::1", 255, nil, nhICMPv6, buildNA(net.ParseIP("2001:db8::1"), victim)), 0, "eth0")
+	// Sent from the attacker's own address, advertising the victim's.
+	l.handlePacket(buildIPv6Packet("2001:db8::66", "ff02::1", 255, nil, nhICMPv6, buildNA(net.ParseIP("2001:db8::1"), attacker)), 0, "eth0")
+
+	var flips []Alert
+	for _, a := range stats.GetAlerts() {
+		if a.Category == BindingFlip {
+			flips = append(flips, a)
+		}
+	}
+	if len(flips) != 1 || flips[0].Binding.Address != "2001:db8::1" || flips[0].Binding.MAC != attacker.String() {
+		t.Fatalf("binding flips = %+v, want 2001:db8::1 moving to the attacker", flips)
+	}
+}
@@ -0,0 +1,243 @@
+//go:build linux
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// packetOutgoing is the sll_pkttype of frames sent by this host.
+const packetOutgoing = 4
+
+// portRefreshInterval bounds how stale the member port list may get, so
+// ports added to a bridge or bond while capturing are picked up.
+const portRefreshInterval = 10 * time.Second
+
+// runPacket reads whole IPv6 packets from an AF_PACKET socket so extension
+// headers are visible. Requires root/CAP_NET_RAW.
+//
+// With MemberPorts the socket listens on every interface instead and keeps
+// the frames that arrived on a member port of the bridge or bond named by
+// Interface. It has to take all EtherTypes: the bridge and bonding drivers
+// claim frames from their ports before protocol-specific sockets see them,
+// but after ETH_P_ALL sockets have.
+func (l *NDPListener) runPacket(ctx context.Context) error {
+	if l.cfg.Mirror {
+		return l.runMirror(ctx)
+	}
+	proto := htons(syscall.ETH_P_IPV6)
+	var ports *memberPorts
+	if l.cfg.MemberPorts {
+		if l.cfg.Interface == "" {
+			return errors.New("member port capture needs an interface")
+		}
+		ports = &memberPorts{master: l.cfg.Interface}
+		if err := ports.refresh(time.Now()); err != nil {
+			return err
+		}
+		l.cfg.Logger.Info("capturing on member ports", "iface", l.cfg.Interface, "ports", ports.names())
+		proto = htons(syscall.ETH_P_ALL)
+	}
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_DGRAM, int(proto))
+	if err != nil {
+		return fmt.Errorf("open packet socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	// Ifindex 0 binds to all interfaces.
+	ifIndex := 0
+	if ports == nil {
+		ifIndex = l.interfaceIndex()
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: proto, Ifindex: ifIndex}); err != nil {
+		return fmt.Errorf("bind packet socket: %w", err)
+	}
+	tv := syscall.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return fmt.Errorf("set packet socket timeout: %w", err)
+	}
+	l.ready()
+
+	buf := make([]byte, 64*1024)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		n, from, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("read: %w", err)
+		}
+
+		ifIndex, port := 0, ""
+		if ll, ok := from.(*syscall.SockaddrLinklayer); ok {
+			if ll.Pkttype == packetOutgoing {
+				continue
+			}
+			ifIndex = ll.Ifindex
+			if ports != nil {
+				if ll.Protocol != htons(syscall.ETH_P_IPV6) {
+					continue
+				}
+				// Frames for the host show up again on the master itself.
+				if port = ports.lookup(ifIndex, time.Now()); port == "" {
+					continue
+				}
+				ifIndex = ports.masterIndex
+			}
+		}
+		l.handlePacket(buf[:n], ifIndex, port)
+	}
+}
+
+// mirrorFilter passes IPv6 frames, tagged or not, so the promiscuous socket
+// of a busy mirror port doesn't copy everything else to user space.
+var mirrorFilter = []bpf.Instruction{
+	bpf.LoadAbsolute{Off: 12, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: etherTypeIPv6, SkipTrue: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: etherTypeVLAN, SkipTrue: 1},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: etherTypeQinQ, SkipFalse: 1},
+	bpf.RetConstant{Val: 0xffff},
+	bpf.RetConstant{Val: 0},
+}
+
+// runMirror reads whole Ethernet frames from a mirror port. The port is put
+// in promiscuous mode, since mirrored frames are addressed to other hosts,
+// and the interface must exist: falling back to every interface, as the
+// other backends do, would mix in the host's own traffic.
+func (l *NDPListener) runMirror(ctx context.Context) error {
+	ifi, err := net.InterfaceByName(l.cfg.Interface)
+	if err != nil {
+		return fmt.Errorf("mirror port: %w", err)
+	}
+	proto := htons(syscall.ETH_P_ALL)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(proto))
+	if err != nil {
+		return fmt.Errorf("open packet socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	raw, err := bpf.Assemble(mirrorFilter)
+	if err != nil {
+		return fmt.Errorf("assemble mirror filter: %w", err)
+	}
+	filter := make([]unix.SockFilter, len(raw))
+	for i, ins := range raw {
+		filter[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &prog); err != nil {
+		return fmt.Errorf("attach mirror filter: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: proto, Ifindex: ifi.Index}); err != nil {
+		return fmt.Errorf("bind packet socket: %w", err)
+	}
+	// The membership, and with it promiscuous mode, ends with the socket.
+	mreq := unix.PacketMreq{Ifindex: int32(ifi.Index), Type: unix.PACKET_MR_PROMISC}
+	if err := unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, &mreq); err != nil {
+		return fmt.Errorf("promiscuous mode on %s: %w", ifi.Name, err)
+	}
+	tv := syscall.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return fmt.Errorf("set packet socket timeout: %w", err)
+	}
+	l.cfg.Logger.Info("capturing on mirror port", "iface", ifi.Name, "ifindex", ifi.Index)
+	l.ready()
+
+	buf := make([]byte, 64*1024)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		n, from, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("read: %w", err)
+		}
+		if ll, ok := from.(*syscall.SockaddrLinklayer); ok && ll.Pkttype == packetOutgoing {
+			continue
+		}
+		l.handleFrame(buf[:n], ifi.Index)
+	}
+}
+
+// memberPorts tracks the member ports of a bridge or bond by ifindex.
+type memberPorts struct {
+	master      string
+	masterIndex int
+	ports       map[int]string
+	refreshed   time.Time
+}
+
+// lookup returns the name of the member port with ifIndex, or "" if it is
+// not a member.
+func (m *memberPorts) lookup(ifIndex int, now time.Time) string {
+	if now.Sub(m.refreshed) >= portRefreshInterval {
+		// Keep the last known ports if the interfaces can't be listed.
+		_ = m.refresh(now)
+	}
+	return m.ports[ifIndex]
+}
+
+// refresh re-reads the member ports from sysfs, where every port of a
+// bridge or bond links to it as its master.
+func (m *memberPorts) refresh(now time.Time) error {
+	m.refreshed = now
+	master, err := net.InterfaceByName(m.master)
+	if err != nil {
+		return fmt.Errorf("member ports of %s: %w", m.master, err)
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return fmt.Errorf("member ports of %s: %w", m.master, err)
+	}
+	ports := make(map[int]string)
+	for _, ifi := range ifaces {
+		link, err := os.Readlink(filepath.Join("/sys/class/net", ifi.Name, "master"))
+		if err == nil && filepath.Base(link) == m.master {
+			ports[ifi.Index] = ifi.Name
+		}
+	}
+	if len(ports) == 0 {
+		return fmt.Errorf("%s has no member ports; is it a bridge or bond?", m.master)
+	}
+	m.masterIndex, m.ports = master.Index, ports
+	return nil
+}
+
+// names lists the member port names for logging.
+func (m *memberPorts) names() []string {
+	names := make([]string, 0, len(m.ports))
+	for _, name := range m.ports {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package lib
+
+import (
+	"context"
+	"errors"
+)
+
+// runPacket is only implemented on Linux (AF_PACKET).
+func (l *NDPListener) runPacket(ctx context.Context) error {
+	return errors.New("packet capture is only supported on linux")
+}
@@ -0,0 +1,147 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// defaultCardinalityOver is the period growth is measured over.
+	defaultCardinalityOver = time.Minute
+	// cardinalityCheckInterval is how often the counts are sampled.
+	cardinalityCheckInterval = 10 * time.Second
+)
+
+// CardinalityCounts is how many distinct peers, MACs and multicast groups
+// NDPStats currently tracks.
+type CardinalityCounts struct {
+	Peers  int `json:"peers"`
+	MACs   int `json:"macs"`
+	Groups int `json:"groups"` // joined by some peer within the window
+}
+
+// Cardinality counts the distinct peers, MACs and multicast groups being
+// tracked, stale and ignored peers included: they still cost memory.
+func (s *NDPStats) Cardinality() CardinalityCounts {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cutoff := s.now().Add(-s.window)
+	macs := make(map[string]bool)
+	groups := make(map[string]bool)
+	for _, peer := range s.peers {
+		if peer.MAC != "" {
+			macs[peer.MAC] = true
+		}
+		for group, last := range peer.Groups {
+			if last.After(cutoff) {
+				groups[group] = true
+			}
+		}
+	}
+	return CardinalityCounts{Peers: len(s.peers), MACs: len(macs), Groups: len(groups)}
+}
+
+// CardinalityGuardConfig configures a CardinalityGuard.
+type CardinalityGuardConfig struct {
+	Config CardinalityConfig
+	Logger *slog.Logger // required
+	Stats  *NDPStats    // required; supplies the counts and records alerts
+	Sinks  []Sink       // optional; receive the alerts
+}
+
+// cardinalitySample is the counts at one check.
+type cardinalitySample struct {
+	at     time.Time
+	counts CardinalityCounts
+}
+
+// CardinalityGuard raises an alert when the number of distinct peers, MACs
+// or multicast groups grows faster than configured. Scans and privacy
+// address rotation storms add peers by the hundred, MAC randomisation adds
+// MACs, and a forwarding loop replicating multicast adds groups from every
+// segment it reaches. Each kind alerts at most once per window.
+type CardinalityGuard struct {
+	cfg     CardinalityGuardConfig
+	samples []cardinalitySample // oldest first, spanning Over
+}
+
+// NewCardinalityGuard returns a guard; call Run to start it.
+func NewCardinalityGuard(cfg CardinalityGuardConfig) *CardinalityGuard {
+	if cfg.Config.Over <= 0 {
+		cfg.Config.Over = defaultCardinalityOver
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &CardinalityGuard{cfg: cfg}
+}
+
+// Run samples the counts until ctx is cancelled.
+func (g *CardinalityGuard) Run(ctx context.Context) error {
+	ticker := time.NewTicker(cardinalityCheckInterval)
+	defer ticker.Stop()
+
+	g.check(g.cfg.Stats.Cardinality(), time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			g.check(g.cfg.Stats.Cardinality(), now)
+		}
+	}
+}
+
+// check records counts taken at now and compares them with the oldest
+// sample within Over.
+func (g *CardinalityGuard) check(counts CardinalityCounts, now time.Time) {
+	cfg := g.cfg.Config
+	g.samples = append(g.samples, cardinalitySample{at: now, counts: counts})
+	// Keep the newest sample at least Over old as the baseline.
+	for len(g.samples) > 1 && now.Sub(g.samples[1].at) >= cfg.Over {
+		g.samples = g.samples[1:]
+	}
+	base := g.samples[0]
+	if base.at.Equal(now) {
+		return
+	}
+
+	severity := SeverityWarning
+	if cfg.Severity != nil {
+		severity = *cfg.Severity
+	}
+	for _, c := range []struct {
+		what     string
+		limit    int
+		from, to int
+	}{
+		{"peers", cfg.Peers, base.counts.Peers, counts.Peers},
+		{"macs", cfg.MACs, base.counts.MACs, counts.MACs},
+		{"groups", cfg.Groups, base.counts.Groups, counts.Groups},
+	} {
+		if c.limit <= 0 || c.to-c.from <= c.limit {
+			continue
+		}
+		category := "cardinality_" + c.what
+		if !g.cfg.Stats.alertDue(category, now) {
+			continue
+		}
+		emitAlert(g.cfg.Stats, g.cfg.Logger, g.cfg.Sinks, Alert{
+			Time:     now,
+			Severity: severity,
+			Category: category,
+			Message: fmt.Sprintf("distinct %s grew by %d in %s (%d to %d, limit %d)",
+				cardinalityNames[c.what], c.to-c.from, formatDuration(now.Sub(base.at)), c.from, c.to, c.limit),
+		})
+	}
+}
+
+// cardinalityNames spell out the counted kinds in alert messages.
+var cardinalityNames = map[string]string{
+	"peers":  "peers",
+	"macs":   "MACs",
+	"groups": "multicast groups",
+}
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCardinality(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	l.handle(received{src: netip.MustParseAddr("fe80::1"), payload: buildNA(net.ParseIP("fe80::1"), mac)})
+	l.handle(received{src: netip.MustParseAddr("2001:db8::1"), payload: buildNA(net.ParseIP("2001:db8::1"), mac)})
+	l.handle(received{src: netip.MustParseAddr("fe80::2"), payload: buildMLDv1Report(net.ParseIP("ff02::fb"))})
+
+	got := stats.Cardinality()
+	if want := (CardinalityCounts{Peers: 3, MACs: 1, Groups: 1}); got != want {
+		t.Errorf("Cardinality() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCardinalityGuard(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	g := NewCardinalityGuard(CardinalityGuardConfig{
+		Config: CardinalityConfig{Over: time.Minute, Peers: 50},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	start := time.Now()
+	g.check(CardinalityCounts{Peers: 10, MACs: 10}, start)
+	// Steady growth within the limit, and MACs are not checked.
+	g.check(CardinalityCounts{Peers: 40, MACs: 500}, start.Add(30*time.Second))
+	g.check(CardinalityCounts{Peers: 60, MACs: 900}, start.Add(time.Minute))
+	if alerts := stats.GetAlerts(); len(alerts) != 0 {
+		t.Fatalf("alerts = %+v, want none", alerts)
+	}
+
+	// 60 -> 200 over the last minute.
+	g.check(CardinalityCounts{Peers: 200}, start.Add(2*time.Minute))
+	alerts := stats.GetAlerts()
+	if len(alerts) != 1 || alerts[0].Category != "cardinality_peers" || alerts[0].Severity != SeverityWarning {
+		t.Fatalf("alerts = %+v, want one cardinality_peers warning", alerts)
+	}
+	if !strings.Contains(alerts[0].Message, "grew by 140") {
+		t.Errorf("message = %q", alerts[0].Message)
+	}
+
+	// Still growing, but already alerted within the window.
+	g.check(CardinalityCounts{Peers: 400}, start.Add(3*time.Minute))
+	if alerts := stats.GetAlerts(); len(alerts) != 1 {
+		t.Errorf("alerts = %d, want the repeat suppressed", len(alerts))
+	}
+}
@@ -0,0 +1,52 @@
+package lib
+
+import "sort"
+
+// Comparison is the difference between what two capture sources observed,
+// e.g. both sides of an RA-guard or MLD-snooping switch, or live traffic
+// against a pcap of the same link. Peers and routers are matched by address.
+type Comparison struct {
+	OnlyAPeers    []PeerSummary `json:"only_a_peers,omitempty"`
+	OnlyBPeers    []PeerSummary `json:"only_b_peers,omitempty"`
+	CommonPeers   int           `json:"common_peers"`
+	OnlyARouters  []RouterInfo  `json:"only_a_routers,omitempty"`
+	OnlyBRouters  []RouterInfo  `json:"only_b_routers,omitempty"`
+	CommonRouters int           `json:"common_routers"`
+}
+
+// Compare returns the peers and routers seen by only one of a and b, each
+// ordered by address.
+func Compare(a, b *NDPStats) Comparison {
+	var c Comparison
+	c.OnlyAPeers, c.OnlyBPeers, c.CommonPeers = diffByAddress(a.GetStats(), b.GetStats(),
+		func(p PeerSummary) string { return p.Address })
+	c.OnlyARouters, c.OnlyBRouters, c.CommonRouters = diffByAddress(a.GetRouters(), b.GetRouters(),
+		func(r RouterInfo) string { return r.Address })
+	return c
+}
+
+// diffByAddress splits a and b into the entries unique to each side and
+// counts the addresses they share.
+func diffByAddress[T any](a, b []T, addr func(T) string) (onlyA, onlyB []T, common int) {
+	inA := make(map[string]bool, len(a))
+	for _, v := range a {
+		inA[addr(v)] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, v := range b {
+		inB[addr(v)] = true
+		if !inA[addr(v)] {
+			onlyB = append(onlyB, v)
+		}
+	}
+	for _, v := range a {
+		if inB[addr(v)] {
+			common++
+		} else {
+			onlyA = append(onlyA, v)
+		}
+	}
+	sort.Slice(onlyA, func(i, j int) bool { return addr(onlyA[i]) < addr(onlyA[j]) })
+	sort.Slice(onlyB, func(i, j int) bool { return addr(onlyB[i]) < addr(onlyB[j]) })
+	return onlyA, onlyB, common
+}
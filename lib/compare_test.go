@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	a := NewNDPStats(5 * time.Minute)
+	b := NewNDPStats(5 * time.Minute)
+
+	// The rogue router and a host are filtered before reaching b.
+	a.RecordRouter(RouterInfo{Address: "fe80::1"})
+	a.RecordRouter(RouterInfo{Address: "fe80::bad"})
+	b.RecordRouter(RouterInfo{Address: "fe80::1"})
+	a.RecordMessage("fe80::10", "neighbor_solicitation")
+	a.RecordMessage("fe80::11", "mld_v2_report")
+	b.RecordMessage("fe80::10", "neighbor_solicitation")
+	b.RecordMessage("fe80::12", "neighbor_solicitation")
+
+	c := Compare(a, b)
+	if c.CommonRouters != 1 || len(c.OnlyBRouters) != 0 {
+		t.Errorf("routers: common %d, only b %+v", c.CommonRouters, c.OnlyBRouters)
+	}
+	if len(c.OnlyARouters) != 1 || c.OnlyARouters[0].Address != "fe80::bad" {
+		t.Errorf("only a routers = %+v, want fe80::bad", c.OnlyARouters)
+	}
+	if c.CommonPeers != 1 {
+		t.Errorf("common peers = %d, want 1", c.CommonPeers)
+	}
+	if len(c.OnlyAPeers) != 1 || c.OnlyAPeers[0].Address != "fe80::11" {
+		t.Errorf("only a peers = %+v, want fe80::11", c.OnlyAPeers)
+	}
+	if len(c.OnlyBPeers) != 1 || c.OnlyBPeers[0].Address != "fe80::12" {
+		t.Errorf("only b peers = %+v, want fe80::12", c.OnlyBPeers)
+	}
+}
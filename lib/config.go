@@ -0,0 +1,574 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the optional YAML configuration file (--config).
+// Command line flags cover capture and display; the config file covers
+// everything that doesn't fit comfortably on a command line.
+type Config struct {
+	Sinks SinksConfig `yaml:"sinks"`
+	API   *APIConfig  `yaml:"api"`
+	// Enrichment is off unless this section is present.
+	Enrichment *EnrichmentConfig `yaml:"enrichment"`
+	// History is off unless this section is present.
+	History *HistoryConfig `yaml:"history"`
+	// Evidence is off unless this section is present.
+	Evidence *EvidenceConfig `yaml:"evidence"`
+	// Rules enables data-driven detection rule packs.
+	Rules *RulesConfig `yaml:"rules"`
+	// Cardinality alerts on abnormally fast growth in distinct peers, MACs
+	// or multicast groups; off unless this section is present.
+	Cardinality *CardinalityConfig `yaml:"cardinality"`
+	// MACLocation looks up which switch port a peer's MAC is on; off unless
+	// this section is present.
+	MACLocation *MACLocationConfig `yaml:"mac_location"`
+	// Maintenance lists windows during which alerts are suppressed or downgraded.
+	Maintenance []MaintenanceWindow `yaml:"maintenance"`
+	// Ignore lists filter expressions (see Filter); matching peers are hidden
+	// from the TUI, API, metrics and snapshots.
+	Ignore []string `yaml:"ignore"`
+	// MulticastGroups labels site-specific groups: an IPv6 address or prefix
+	// mapped to a name. Entries take precedence over the built-in labels.
+	MulticastGroups map[string]string `yaml:"multicast_groups"`
+	// Keys remaps the TUI's keys.
+	Keys *KeysConfig `yaml:"keys"`
+	// Actions are external commands the TUI can run on the selected peer.
+	Actions []ActionConfig `yaml:"actions"`
+	// Federation lists other instances on the site; off unless this
+	// section is present.
+	Federation *FederationConfig `yaml:"federation"`
+	// Anonymize anonymizes the snapshots and graphs the TUI writes; off
+	// unless this section is present.
+	Anonymize *AnonymizeConfig `yaml:"anonymize"`
+	// Privacy keeps MACs and hostnames out of everything stored; off
+	// unless this section is present.
+	Privacy *PrivacyConfig `yaml:"privacy"`
+	// RAGuard alerts on every RA not from an allowed router; off unless
+	// this section is present.
+	RAGuard *RAGuardConfig `yaml:"ra_guard"`
+	// RogueRA alerts on RAs from untrusted routers, with short lifetimes
+	// or with conflicting prefixes; off unless this section is present.
+	RogueRA *RogueRAConfig `yaml:"rogue_ra"`
+	// MLDSnoop checks that MLD queries and reports get through the
+	// switch; off unless this section is present.
+	MLDSnoop *MLDSnoopConfig `yaml:"mld_snoop"`
+
+	ignore          []*Filter             // compiled by validate
+	multicastGroups []MulticastGroupLabel // compiled by validate
+	rules           []Rule                // loaded by validate
+	rulePacks       []RulePackInfo        // loaded by validate
+	maintenance     []*maintenanceWindow  // compiled by validate
+	keymap          Keymap                // compiled by validate
+	actions         []*PeerAction         // compiled by validate
+	anonymizer      *Anonymizer           // built by validate
+}
+
+// APIConfig serves JSON views of the current stats over HTTP. With
+// ReadToken set, reads need it (or AdminToken) as a bearer token; the
+// endpoints that change state are only served with AdminToken set, and
+// need it (see ServeAPI).
+type APIConfig struct {
+	Listen     string `yaml:"listen"`      // e.g. "127.0.0.1:9311"
+	ReadToken  string `yaml:"read_token"`  // bearer token for reads, e.g. a dashboard's
+	AdminToken string `yaml:"admin_token"` // bearer token for reads and actions
+}
+
+// SinksConfig selects the headless outputs that run alongside the TUI.
+// A nil entry disables that sink.
+type SinksConfig struct {
+	Prometheus *PrometheusSinkConfig `yaml:"prometheus"`
+	NDJSON     *NDJSONSinkConfig     `yaml:"ndjson"`
+	Syslog     *SyslogSinkConfig     `yaml:"syslog"`
+	AgentX     *AgentXConfig         `yaml:"agentx"`
+	Exec       *ExecSinkConfig       `yaml:"exec"`
+	GNMI       *GNMISinkConfig       `yaml:"gnmi"`
+}
+
+// Enabled names the configured sinks, in declaration order.
+func (s SinksConfig) Enabled() []string {
+	var names []string
+	if s.Prometheus != nil {
+		names = append(names, "prometheus")
+	}
+	if s.NDJSON != nil {
+		names = append(names, "ndjson")
+	}
+	if s.Syslog != nil {
+		names = append(names, "syslog")
+	}
+	if s.AgentX != nil {
+		names = append(names, "agentx")
+	}
+	if s.Exec != nil {
+		names = append(names, "exec")
+	}
+	if s.GNMI != nil {
+		names = append(names, "gnmi")
+	}
+	return names
+}
+
+// PrometheusSinkConfig serves metrics in the Prometheus text format.
+type PrometheusSinkConfig struct {
+	Listen string `yaml:"listen"` // e.g. ":9310"
+}
+
+// GNMISinkConfig serves the peers, routers and alerts as a gNMI target.
+// Without a certificate it speaks plaintext HTTP/2 (gnmic --insecure).
+type GNMISinkConfig struct {
+	Listen   string        `yaml:"listen"`   // e.g. ":9339"
+	TLSCert  string        `yaml:"tls_cert"` // PEM certificate file
+	TLSKey   string        `yaml:"tls_key"`  // PEM key file
+	Interval time.Duration `yaml:"interval"` // default sample interval of STREAM subscriptions (default 10s)
+}
+
+// NDJSONSinkConfig appends one JSON object per event or alert to a file.
+type NDJSONSinkConfig struct {
+	Path string `yaml:"path"`
+}
+
+// SyslogSinkConfig forwards events and alerts to syslog.
+type SyslogSinkConfig struct {
+	Network string `yaml:"network"` // "", "udp" or "tcp"; empty means the local syslog daemon
+	Address string `yaml:"address"` // e.g. "logs.example.net:514"; ignored for the local daemon
+	Tag     string `yaml:"tag"`     // default "ndpeekr"
+}
+
+// ExecSinkConfig runs a command for each alert. Args are text/template
+// strings over the alert ({{.Severity}}, {{.Category}}, {{.Source}},
+// {{.Message}}, {{.Port}}, {{.Time}}); the same fields are in the
+// command's environment as NDPEEKR_ALERT_*.
+type ExecSinkConfig struct {
+	Command     string        `yaml:"command"`
+	Args        []string      `yaml:"args"`
+	MinSeverity Severity      `yaml:"min_severity"` // default info (every alert)
+	Categories  []string      `yaml:"categories"`   // only these categories; all if empty
+	PerMinute   int           `yaml:"per_minute"`   // runs allowed per minute (default 6)
+	Timeout     time.Duration `yaml:"timeout"`      // per run (default 30s)
+}
+
+// EnrichmentConfig controls reverse DNS, OUI vendor, inventory and
+// reputation lookups.
+// They run on their own cadence, independent of the table refresh.
+type EnrichmentConfig struct {
+	Interval  time.Duration             `yaml:"interval"`  // how often to enrich known peers (default 1m)
+	TTL       time.Duration             `yaml:"ttl"`       // how long DNS answers are cached (default 30m)
+	DNS       bool                      `yaml:"dns"`       // reverse (PTR) lookups
+	OUIFile   string                    `yaml:"oui_file"`  // Wireshark manuf or IEEE oui.txt
+	Inventory map[string]InventoryEntry `yaml:"inventory"` // MAC or IPv6 address -> name or entry
+	// Reputation looks peers up in blocklists and external sources.
+	Reputation []ReputationConfig `yaml:"reputation"`
+}
+
+// InventoryEntry classifies a known device. In YAML it is either just the
+// name or a mapping with any of name, type, trusted and tags.
+type InventoryEntry struct {
+	Name    string   `yaml:"name"`
+	Type    string   `yaml:"type"`    // device type, e.g. "printer"
+	Trusted bool     `yaml:"trusted"` // known-good, e.g. a sanctioned router
+	Tags    []string `yaml:"tags"`
+}
+
+// UnmarshalYAML accepts a plain name as well as a full entry.
+func (e *InventoryEntry) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*e = InventoryEntry{Name: value.Value}
+		return nil
+	}
+	type plain InventoryEntry
+	return value.Decode((*plain)(e))
+}
+
+// HistoryConfig records periodic samples of peers and routers, and every
+// alert, to a SQLite database for later export.
+type HistoryConfig struct {
+	Path     string        `yaml:"path"`     // e.g. "ndpeekr-history.db"
+	Interval time.Duration `yaml:"interval"` // how often to sample (default 1m)
+	// MaxAge drops samples and alerts older than this (default: keep all).
+	MaxAge time.Duration `yaml:"max_age"`
+	// MaxSizeMB drops the oldest samples while the database holds more
+	// than this many megabytes (default: no limit).
+	MaxSizeMB int64 `yaml:"max_size_mb"`
+	// Downsample sets how long each resolution of the message counters is
+	// kept before it is rolled up into the next.
+	Downsample DownsampleConfig `yaml:"downsample"`
+}
+
+// EvidenceConfig writes an evidence bundle for forensics when a severe alert
+// fires: the alert, the peer and router state of its source, recent related
+// events and, with the pcap ring enabled, the raw packets.
+type EvidenceConfig struct {
+	Dir         string        `yaml:"dir"`          // bundles are written to timestamped directories here
+	MinSeverity *Severity     `yaml:"min_severity"` // default critical
+	Events      int           `yaml:"events"`       // recent events kept to draw on (default 2000)
+	Cooldown    time.Duration `yaml:"cooldown"`     // per alert category and source (default 5m)
+}
+
+// RulesConfig selects detection rule packs: built-in packs by name and
+// rule pack files. Rules and whole packs can be disabled by name.
+type RulesConfig struct {
+	Packs    []string      `yaml:"packs"`    // built-in packs, e.g. "home", "enterprise"
+	Files    []string      `yaml:"files"`    // rule pack files, loaded after the built-in packs
+	Disable  []string      `yaml:"disable"`  // rule or pack names to skip
+	Interval time.Duration `yaml:"interval"` // how often to evaluate (default 10s)
+}
+
+// CardinalityConfig limits how fast the number of distinct peers, MACs and
+// multicast groups may grow: by at most the given count within Over. A zero
+// limit is not checked.
+type CardinalityConfig struct {
+	Over     time.Duration `yaml:"over"`     // period growth is measured over (default 1m)
+	Peers    int           `yaml:"peers"`    // most new peers per period
+	MACs     int           `yaml:"macs"`     // most new MACs per period
+	Groups   int           `yaml:"groups"`   // most new multicast groups per period
+	Severity *Severity     `yaml:"severity"` // default warning
+}
+
+// MACLocationConfig lists the switches asked where a MAC is (see
+// MACLocator).
+type MACLocationConfig struct {
+	Switches []SwitchConfig `yaml:"switches"`
+	Timeout  time.Duration  `yaml:"timeout"` // per switch (default 5s)
+	TTL      time.Duration  `yaml:"ttl"`     // how long answers are cached (default 5m)
+}
+
+// SwitchConfig is one switch to query, over SNMP or RESTCONF.
+type SwitchConfig struct {
+	Name string `yaml:"name"`
+	// SNMP is the agent, host or host:port (default port 161), queried with
+	// SNMPv2c for BRIDGE-MIB and Q-BRIDGE-MIB forwarding entries.
+	SNMP      string `yaml:"snmp"`
+	Community string `yaml:"community"` // default "public"
+	// RESTCONF is the RESTCONF root, e.g. "https://sw1/restconf", queried
+	// for the OpenConfig MAC table.
+	RESTCONF        string `yaml:"restconf"`
+	NetworkInstance string `yaml:"network_instance"` // default "default"
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+}
+
+// MaintenanceWindow suppresses or downgrades alerts during planned work,
+// such as router upgrades. A window is either recurring, opening whenever
+// the cron Schedule fires and staying open for Duration, or a one-off
+// interval from Start to End.
+type MaintenanceWindow struct {
+	Name       string        `yaml:"name"`
+	Schedule   string        `yaml:"schedule"`   // cron: minute hour day-of-month month day-of-week
+	Duration   time.Duration `yaml:"duration"`   // how long each scheduled window lasts (at most 7 days)
+	Timezone   string        `yaml:"timezone"`   // for the schedule, e.g. "Europe/Berlin"; default local time
+	Start      time.Time     `yaml:"start"`      // one-off window, RFC 3339
+	End        time.Time     `yaml:"end"`        // one-off window, RFC 3339
+	Categories []string      `yaml:"categories"` // alert categories affected; all if empty
+	Action     string        `yaml:"action"`     // "suppress" (default) or "downgrade"
+	Severity   *Severity     `yaml:"severity"`   // downgraded alerts are raised at most at this severity (default info)
+}
+
+// LoadConfig reads and validates a YAML config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if p := c.Sinks.Prometheus; p != nil && p.Listen == "" {
+		return fmt.Errorf("sinks.prometheus.listen is required")
+	}
+	if n := c.Sinks.NDJSON; n != nil && n.Path == "" {
+		return fmt.Errorf("sinks.ndjson.path is required")
+	}
+	if e := c.Sinks.Exec; e != nil {
+		if e.Command == "" {
+			return fmt.Errorf("sinks.exec.command is required")
+		}
+		if _, err := parseExecArgs(e.Args); err != nil {
+			return fmt.Errorf("sinks.exec.args: %w", err)
+		}
+	}
+	if a := c.Sinks.AgentX; a != nil && a.OID != "" {
+		if _, err := parseOID(a.OID); err != nil {
+			return fmt.Errorf("sinks.agentx.oid: %w", err)
+		}
+	}
+	if g := c.Sinks.GNMI; g != nil {
+		if g.Listen == "" {
+			return fmt.Errorf("sinks.gnmi.listen is required")
+		}
+		if (g.TLSCert == "") != (g.TLSKey == "") {
+			return fmt.Errorf("sinks.gnmi: set both tls_cert and tls_key, or neither")
+		}
+	}
+	if c.API != nil && c.API.Listen == "" {
+		return fmt.Errorf("api.listen is required")
+	}
+	if c.API != nil && c.API.ReadToken != "" && c.API.ReadToken == c.API.AdminToken {
+		return fmt.Errorf("api: read_token and admin_token must differ")
+	}
+	if c.History != nil && c.History.Path == "" {
+		return fmt.Errorf("history.path is required")
+	}
+	if c.History != nil && (c.History.MaxAge < 0 || c.History.MaxSizeMB < 0) {
+		return fmt.Errorf("history: max_age and max_size_mb must not be negative")
+	}
+	if c.History != nil {
+		d := c.History.Downsample.withDefaults()
+		if d.Minutes < d.Seconds {
+			return fmt.Errorf("history.downsample: minutes (%s) must not be shorter than seconds (%s)", d.Minutes, d.Seconds)
+		}
+	}
+	if c.Evidence != nil && c.Evidence.Dir == "" {
+		return fmt.Errorf("evidence.dir is required")
+	}
+	if cd := c.Cardinality; cd != nil {
+		if cd.Peers < 0 || cd.MACs < 0 || cd.Groups < 0 || cd.Over < 0 {
+			return fmt.Errorf("cardinality: limits and over must not be negative")
+		}
+		if cd.Peers == 0 && cd.MACs == 0 && cd.Groups == 0 {
+			return fmt.Errorf("cardinality: set at least one of peers, macs or groups")
+		}
+	}
+	if ml := c.MACLocation; ml != nil {
+		if len(ml.Switches) == 0 {
+			return fmt.Errorf("mac_location.switches is required")
+		}
+		for i, sw := range ml.Switches {
+			if sw.Name == "" {
+				return fmt.Errorf("mac_location.switches[%d].name is required", i)
+			}
+			if (sw.SNMP == "") == (sw.RESTCONF == "") {
+				return fmt.Errorf("mac_location.switches[%d]: set exactly one of snmp or restconf", i)
+			}
+		}
+	}
+	c.ignore = c.ignore[:0]
+	for i, expr := range c.Ignore {
+		f, err := ParseFilter(expr)
+		if err != nil {
+			return fmt.Errorf("ignore[%d]: %w", i, err)
+		}
+		c.ignore = append(c.ignore, f)
+	}
+	groups, err := parseMulticastGroupLabels(c.MulticastGroups)
+	if err != nil {
+		return fmt.Errorf("multicast_groups: %w", err)
+	}
+	c.multicastGroups = groups
+	if c.Rules != nil {
+		rules, packs, err := loadRules(*c.Rules)
+		if err != nil {
+			return fmt.Errorf("rules: %w", err)
+		}
+		c.rules, c.rulePacks = rules, packs
+	}
+	c.maintenance = c.maintenance[:0]
+	for i, w := range c.Maintenance {
+		mw, err := compileMaintenanceWindow(w)
+		if err != nil {
+			return fmt.Errorf("maintenance[%d]: %w", i, err)
+		}
+		c.maintenance = append(c.maintenance, mw)
+	}
+	if f := c.Federation; f != nil {
+		if (f.Accept || f.Advertise != "") && c.API == nil {
+			return fmt.Errorf("federation: accept and advertise need the api section")
+		}
+		if f.Accept && c.API.AdminToken == "" {
+			return fmt.Errorf("federation.accept needs api.admin_token: registrations are polled")
+		}
+		if f.Register != "" && f.Advertise == "" {
+			return fmt.Errorf("federation.advertise is required to register")
+		}
+		for _, u := range []string{f.Advertise, f.Register} {
+			if _, err := instanceURL(u); u != "" && err != nil {
+				return fmt.Errorf("federation: %w", err)
+			}
+		}
+	}
+	c.keymap = nil
+	if c.Keys != nil {
+		km, err := NewKeymap(*c.Keys)
+		if err != nil {
+			return fmt.Errorf("keys: %w", err)
+		}
+		c.keymap = km
+	}
+	c.actions = c.actions[:0]
+	names := make(map[string]bool)
+	for i, a := range c.Actions {
+		pa, err := NewPeerAction(a)
+		if err != nil {
+			return fmt.Errorf("actions[%d]: %w", i, err)
+		}
+		if names[a.Name] {
+			return fmt.Errorf("actions[%d]: duplicate name %q", i, a.Name)
+		}
+		names[a.Name] = true
+		c.actions = append(c.actions, pa)
+	}
+	if c.RAGuard != nil {
+		if _, err := newRAGuard(*c.RAGuard); err != nil {
+			return err
+		}
+	}
+	if c.RogueRA != nil {
+		if _, err := newRogueRA(*c.RogueRA); err != nil {
+			return err
+		}
+	}
+	if ms := c.MLDSnoop; ms != nil && (ms.QueryInterval < 0 || ms.Misses < 0) {
+		return fmt.Errorf("mld_snoop: query_interval and misses must not be negative")
+	}
+	c.anonymizer = nil
+	if a := c.Anonymize; a != nil {
+		anon, err := NewAnonymizer(a.Mode, a.Key)
+		if err != nil {
+			return err
+		}
+		c.anonymizer = anon
+	}
+	return nil
+}
+
+// DetectionRules returns the enabled rules from the rules section.
+func (c *Config) DetectionRules() []Rule {
+	return c.rules
+}
+
+// Redacted returns the configuration as the config file would spell it,
+// with secrets replaced by "<redacted>", for the status view. Command
+// arguments (the exec sink's, reputation lookups' and peer actions') count
+// as secrets: they routinely carry webhook URLs, tokens and API keys.
+// So do switch SNMP communities and passwords, and the anonymize key, which
+// would undo the anonymization.
+func (c *Config) Redacted() map[string]any {
+	cp := *c
+	if e := c.Sinks.Exec; e != nil {
+		exec := *e
+		exec.Args = redactedArgs(e.Args)
+		cp.Sinks.Exec = &exec
+	}
+	if en := c.Enrichment; en != nil {
+		enr := *en
+		enr.Reputation = make([]ReputationConfig, len(en.Reputation))
+		for i, r := range en.Reputation {
+			r.Args = redactedArgs(r.Args)
+			enr.Reputation[i] = r
+		}
+		cp.Enrichment = &enr
+	}
+	cp.Actions = make([]ActionConfig, len(c.Actions))
+	for i, a := range c.Actions {
+		a.Args = redactedArgs(a.Args)
+		cp.Actions[i] = a
+	}
+	if a := c.API; a != nil {
+		api := *a
+		if api.ReadToken != "" {
+			api.ReadToken = "<redacted>"
+		}
+		if api.AdminToken != "" {
+			api.AdminToken = "<redacted>"
+		}
+		cp.API = &api
+	}
+	if f := c.Federation; f != nil {
+		fed := *f
+		if fed.RegisterToken != "" {
+			fed.RegisterToken = "<redacted>"
+		}
+		if fed.ReadToken != "" {
+			fed.ReadToken = "<redacted>"
+		}
+		cp.Federation = &fed
+	}
+	if an := c.Anonymize; an != nil && an.Key != "" {
+		anon := *an
+		anon.Key = "<redacted>"
+		cp.Anonymize = &anon
+	}
+	if ml := c.MACLocation; ml != nil {
+		loc := *ml
+		loc.Switches = make([]SwitchConfig, len(ml.Switches))
+		for i, sw := range ml.Switches {
+			if sw.Community != "" {
+				sw.Community = "<redacted>"
+			}
+			if sw.Password != "" {
+				sw.Password = "<redacted>"
+			}
+			loc.Switches[i] = sw
+		}
+		cp.MACLocation = &loc
+	}
+	data, err := yaml.Marshal(&cp)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
+
+// redactedArgs returns a "<redacted>" for each of args.
+func redactedArgs(args []string) []string {
+	out := make([]string, len(args))
+	for i := range out {
+		out[i] = "<redacted>"
+	}
+	return out
+}
+
+// RulePacks describes the rule packs the rules section loaded.
+func (c *Config) RulePacks() []RulePackInfo {
+	return c.rulePacks
+}
+
+// MaintenanceWindows returns the compiled maintenance windows, for
+// NDPStats.SetMaintenance.
+func (c *Config) MaintenanceWindows() []*maintenanceWindow {
+	return c.maintenance
+}
+
+// Keymap returns the compiled key remapping, nil for the defaults.
+func (c *Config) Keymap() Keymap {
+	return c.keymap
+}
+
+// Anonymizer returns the anonymizer from the anonymize section, or nil.
+func (c *Config) Anonymizer() *Anonymizer {
+	return c.anonymizer
+}
+
+// PeerActions returns the compiled peer actions, for the TUI.
+func (c *Config) PeerActions() []*PeerAction {
+	return c.actions
+}
+
+// IgnoreFilters returns the compiled ignore rules.
+func (c *Config) IgnoreFilters() []*Filter {
+	return c.ignore
+}
+
+// MulticastGroupLabels returns the compiled multicast_groups entries, most
+// specific first.
+func (c *Config) MulticastGroupLabels() []MulticastGroupLabel {
+	return c.multicastGroups
+}
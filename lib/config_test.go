@@ -0,0 +1,165 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ndpeekr.yaml")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_Sinks(t *testing.T) {
+	path := writeTempConfig(t, `
+sinks:
+  prometheus:
+    listen: ":9310"
+  ndjson:
+    path: /tmp/events.ndjson
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Sinks.Prometheus == nil || cfg.Sinks.Prometheus.Listen != ":9310" {
+		t.Errorf("Prometheus = %+v, want listen :9310", cfg.Sinks.Prometheus)
+	}
+	if cfg.Sinks.NDJSON == nil || cfg.Sinks.NDJSON.Path != "/tmp/events.ndjson" {
+		t.Errorf("NDJSON = %+v, want path /tmp/events.ndjson", cfg.Sinks.NDJSON)
+	}
+	if cfg.Sinks.Syslog != nil {
+		t.Errorf("Syslog = %+v, want nil", cfg.Sinks.Syslog)
+	}
+}
+
+func TestLoadConfig_Inventory(t *testing.T) {
+	cfg, err := LoadConfig(writeTempConfig(t, `
+enrichment:
+  inventory:
+    "fe80::1": core-router
+    "dc:a6:32:01:02:03":
+      name: pi-kitchen
+      type: sensor
+      trusted: true
+      tags: [iot, kitchen]
+`))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	inv := cfg.Enrichment.Inventory
+	if got := inv["fe80::1"]; got.Name != "core-router" || got.Type != "" {
+		t.Errorf("plain entry = %+v, want name only", got)
+	}
+	if got := inv["dc:a6:32:01:02:03"]; got.Name != "pi-kitchen" || got.Type != "sensor" || !got.Trusted || len(got.Tags) != 2 {
+		t.Errorf("full entry = %+v", got)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"bad yaml":         "sinks: [",
+		"prometheus":       "sinks:\n  prometheus: {}\n",
+		"ndjson no path":   "sinks:\n  ndjson: {}\n",
+		"api no listen":    "api: {}\n",
+		"history no path":  "history: {}\n",
+		"evidence no dir":  "evidence:\n  events: 10\n",
+		"agentx bad oid":   "sinks:\n  agentx:\n    oid: 1.3.x\n",
+		"exec no command":  "sinks:\n  exec:\n    args: [x]\n",
+		"exec bad args":    "sinks:\n  exec:\n    command: /bin/true\n    args: ['{{.Port']\n",
+		"exec severity":    "sinks:\n  exec:\n    command: /bin/true\n    min_severity: urgent\n",
+		"bad ignore":       "ignore:\n  - 'total >'\n",
+		"unknown pack":     "rules:\n  packs: [office]\n",
+		"missing rules":    "rules:\n  files: [/nonexistent/rules.yaml]\n",
+		"maintenance cron": "maintenance:\n  - name: x\n    schedule: '0 2 * *'\n    duration: 1h\n",
+		"maintenance span": "maintenance:\n  - name: x\n    start: 2024-05-02T00:00:00Z\n    end: 2024-05-01T00:00:00Z\n",
+		"maintenance both": "maintenance:\n  - name: x\n    schedule: '0 2 * * *'\n    duration: 1h\n    start: 2024-05-01T00:00:00Z\n",
+		"bad group":        "multicast_groups:\n  fe80::1: Link-local\n",
+		"empty label":      "multicast_groups:\n  ff05::1:3: ''\n",
+		"cardinality none": "cardinality:\n  over: 1m\n",
+		"cardinality neg":  "cardinality:\n  peers: -1\n",
+		"no switches":      "mac_location:\n  ttl: 1m\n",
+		"switch both":      "mac_location:\n  switches:\n    - name: sw1\n      snmp: 192.0.2.1\n      restconf: https://sw1/restconf\n",
+		"switch no name":   "mac_location:\n  switches:\n    - snmp: 192.0.2.1\n",
+		"gnmi no listen":   "sinks:\n  gnmi:\n    interval: 5s\n",
+		"gnmi cert only":   "sinks:\n  gnmi:\n    listen: ':9339'\n    tls_cert: /etc/gnmi.crt\n",
+		"keys preset":      "keys:\n  preset: nano\n",
+		"keys action":      "keys:\n  bindings:\n    launch: l\n",
+		"action command":   "actions:\n  - name: ping\n",
+		"action duplicate": "actions:\n  - name: ping\n    command: ping6\n  - name: ping\n    command: ping\n",
+		"history max_age":  "history:\n  path: h.db\n  max_age: -1h\n",
+		"downsample":       "history:\n  path: h.db\n  downsample:\n    seconds: 48h\n    minutes: 24h\n",
+		"federation api":   "federation:\n  accept: true\n",
+		"federation url":   "api:\n  listen: \":9311\"\nfederation:\n  advertise: 10.0.0.1:9311\n",
+		"federation open":  "api:\n  listen: \":9311\"\nfederation:\n  accept: true\n",
+		"anonymize":        "anonymize:\n  mode: scramble\n",
+		"ra_guard":         "ra_guard:\n  allow: [router1]\n",
+		"mld_snoop":        "mld_snoop:\n  misses: -1\n",
+		"rogue_ra":         "rogue_ra:\n  trusted: [gw]\n",
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadConfig(writeTempConfig(t, body)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Missing(t *testing.T) {
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "nope.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestLoadConfig_Ignore(t *testing.T) {
+	path := writeTempConfig(t, `
+ignore:
+  - 'mac =~ "^02:42:"'
+  - 'iface == "docker0"'
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	filters := cfg.IgnoreFilters()
+	if len(filters) != 2 {
+		t.Fatalf("IgnoreFilters() returned %d, want 2", len(filters))
+	}
+	if !filters[0].Match(PeerSummary{MAC: "02:42:ac:11:00:02"}) {
+		t.Error("first ignore rule should match a docker MAC")
+	}
+}
+
+func TestLoadConfig_MulticastGroups(t *testing.T) {
+	path := writeTempConfig(t, `
+multicast_groups:
+  "ff15::/16": Site SSM apps
+  "ff15::8000:1": Market data A
+  "ff02::1:3": Our LLMNR
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	labels := cfg.MulticastGroupLabels()
+
+	tests := map[string]string{
+		"ff15::8000:1":   "Market data A", // most specific entry wins
+		"ff15::42":       "Site SSM apps",
+		"ff02::1:3":      "Our LLMNR", // config overrides the built-in label
+		"ff02::fb":       "mDNS",
+		"ff02::1:ff00:9": "Solicited-Node",
+		"ff0e::99":       "",
+	}
+	for group, want := range tests {
+		if got := multicastLabel(group, labels); got != want {
+			t.Errorf("multicastLabel(%s) = %q, want %q", group, got, want)
+		}
+	}
+}
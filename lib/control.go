@@ -0,0 +1,453 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// JSON-RPC 2.0 error codes the control socket answers with.
+const (
+	RPCParseError     = -32700 // the line isn't JSON
+	RPCInvalidRequest = -32600 // not a JSON-RPC 2.0 request object
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCServerError    = -32000 // the method failed
+)
+
+// maxControlLine bounds one request or response line.
+const maxControlLine = 4 << 20
+
+// ControlConfig configures the control socket "ndpeekr ctl" and local
+// automation talk to.
+type ControlConfig struct {
+	Path  string // unix socket path
+	Stats *NDPStats
+	// Group, a group name or ID, may also connect besides root and the
+	// user running NDPeekr; the socket is then mode 0660 and owned by it.
+	Group string
+	// Level is the log level set_log_level changes; nil leaves it fixed.
+	Level *slog.LevelVar
+	// SnapshotDir, Instance, Anonymizer and Privacy shape save_snapshot
+	// files as the TUI's freeze snapshots (see WriteSnapshotFile).
+	SnapshotDir string // default "."
+	Instance    string
+	Anonymizer  *Anonymizer
+	Privacy     *PrivacyConfig
+	Logger      *slog.Logger
+}
+
+// PeerCred is who is on the other end of a control connection, from the
+// kernel (SO_PEERCRED), not from anything the client says.
+type PeerCred struct {
+	PID int
+	UID int
+	GID int
+}
+
+// RPCError is a JSON-RPC error object. Control methods return one for
+// errors the caller made; anything else is reported as RPCServerError.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// controlMethod runs one method. Mutating methods are logged with the
+// caller's credentials.
+type controlMethod struct {
+	mutates bool
+	run     func(params json.RawMessage) (any, error)
+}
+
+// ControlServer answers JSON-RPC 2.0 requests on a unix socket (see
+// ServeControl for the protocol).
+type ControlServer struct {
+	cfg      ControlConfig
+	gid      int // cfg.Group resolved, -1 without one
+	methods  map[string]controlMethod
+	peerCred func(c *net.UnixConn) (PeerCred, error)
+}
+
+// NewControlServer resolves cfg.Group and returns a server for cfg.
+func NewControlServer(cfg ControlConfig) (*ControlServer, error) {
+	if cfg.SnapshotDir == "" {
+		cfg.SnapshotDir = "."
+	}
+	s := &ControlServer{cfg: cfg, gid: -1, peerCred: peerCred}
+	if cfg.Group != "" {
+		g, err := user.LookupGroup(cfg.Group)
+		if err != nil {
+			if g, err = user.LookupGroupId(cfg.Group); err != nil {
+				return nil, fmt.Errorf("control: group %s: %w", cfg.Group, err)
+			}
+		}
+		if s.gid, err = strconv.Atoi(g.Gid); err != nil {
+			return nil, fmt.Errorf("control: group %s: %w", cfg.Group, err)
+		}
+	}
+	s.methods = s.controlMethods()
+	return s, nil
+}
+
+// ServeControl serves JSON-RPC 2.0 on a unix socket at cfg.Path until ctx
+// is cancelled. Each request and response is one JSON object on one line;
+// a connection may carry any number of them, answered in order. Requests
+// without an id are notifications and get no response. Batches are not
+// supported.
+//
+// Methods (params and results are JSON objects unless noted):
+//
+//	status                                  Status
+//	peers          {"filter": expr, "merged": bool}  []PeerSummary (both params optional)
+//	routers                                 []RouterInfo
+//	alerts                                  []Alert, newest first
+//	bindings                                []BindingChange, newest first
+//	ack_alerts     {"category", "source"}   {"acked": n}; empty matches any
+//	get_log_level                           {"level": "info"}
+//	set_log_level  {"level": "debug"}       {"level": "debug"}
+//	save_snapshot                           {"path": file written}
+//	clear_stats                             null (see NDPStats.Clear)
+//
+// The socket is mode 0600, or 0660 with cfg.Group. On top of that, every
+// connection's peer credentials are checked: root, the user running
+// NDPeekr and members of cfg.Group get in, anyone else is disconnected. A
+// socket left behind by a crashed instance is replaced; callers hold the
+// instance's lock on cfg.Path (see LockFile), so it can't be a running
+// one's.
+func ServeControl(ctx context.Context, cfg ControlConfig) error {
+	s, err := NewControlServer(cfg)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx)
+}
+
+// Serve listens on the socket and serves it until ctx is cancelled.
+func (s *ControlServer) Serve(ctx context.Context) error {
+	path := s.cfg.Path
+	if fi, err := os.Lstat(path); err == nil && fi.Mode().Type() == fs.ModeSocket {
+		os.Remove(path)
+	}
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return fmt.Errorf("control: %w", err)
+	}
+	defer l.Close()
+	defer os.Remove(path)
+	mode := os.FileMode(0600)
+	if s.gid >= 0 {
+		if err := os.Chown(path, -1, s.gid); err != nil {
+			return fmt.Errorf("control: %w", err)
+		}
+		mode = 0660
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("control: %w", err)
+	}
+	s.cfg.Logger.Info("serving control socket", "path", path, "group", s.cfg.Group)
+
+	stop := context.AfterFunc(ctx, func() { l.Close() })
+	defer stop()
+	for {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("control: %w", err)
+		}
+		go s.serveConn(ctx, conn)
+	}
+}
+
+// serveConn checks the caller's credentials and answers its requests.
+func (s *ControlServer) serveConn(ctx context.Context, conn *net.UnixConn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	cred, err := s.peerCred(conn)
+	if err != nil {
+		s.cfg.Logger.Warn("control connection refused", "err", err)
+		return
+	}
+	if !s.allowed(cred) {
+		s.cfg.Logger.Warn("control connection refused", "uid", cred.UID, "gid", cred.GID, "pid", cred.PID)
+		return
+	}
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 64*1024), maxControlLine)
+	w := bufio.NewWriter(conn)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		resp, ok := s.handle([]byte(line), cred)
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(resp)
+		if err != nil {
+			data, _ = json.Marshal(rpcResponse{JSONRPC: "2.0", ID: resp.ID, Error: &RPCError{Code: RPCServerError, Message: err.Error()}})
+		}
+		w.Write(append(data, '\n'))
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// allowed reports whether cred may use the socket: root, the user running
+// NDPeekr, or a member (primary or supplementary) of the control group.
+func (s *ControlServer) allowed(cred PeerCred) bool {
+	if cred.UID == 0 || cred.UID == os.Getuid() {
+		return true
+	}
+	if s.gid < 0 {
+		return false
+	}
+	if cred.GID == s.gid {
+		return true
+	}
+	u, err := user.LookupId(strconv.Itoa(cred.UID))
+	if err != nil {
+		return false
+	}
+	gids, err := u.GroupIds()
+	return err == nil && slices.Contains(gids, strconv.Itoa(s.gid))
+}
+
+// handle answers one request line. It returns false for notifications.
+func (s *ControlServer) handle(line []byte, cred PeerCred) (rpcResponse, bool) {
+	resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = &RPCError{Code: RPCParseError, Message: err.Error()}
+		return resp, true
+	}
+	if req.ID != nil {
+		resp.ID = req.ID
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &RPCError{Code: RPCInvalidRequest, Message: `want {"jsonrpc": "2.0", "method": ...}`}
+		return resp, true
+	}
+
+	m, ok := s.methods[req.Method]
+	var result any
+	var err error
+	if !ok {
+		err = &RPCError{Code: RPCMethodNotFound, Message: "unknown method " + req.Method}
+	} else {
+		result, err = m.run(req.Params)
+		if m.mutates && err == nil {
+			s.cfg.Logger.Info("control call", "method", req.Method, "uid", cred.UID, "pid", cred.PID)
+		}
+	}
+	if req.ID == nil {
+		return resp, false
+	}
+	if err != nil {
+		var rerr *RPCError
+		if !errors.As(err, &rerr) {
+			rerr = &RPCError{Code: RPCServerError, Message: err.Error()}
+		}
+		resp.Error = rerr
+		return resp, true
+	}
+	if resp.Result, err = json.Marshal(result); err != nil {
+		resp.Error = &RPCError{Code: RPCServerError, Message: err.Error()}
+	}
+	return resp, true
+}
+
+// decodeParams decodes params into v; absent params leave v as it is.
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &RPCError{Code: RPCInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *ControlServer) controlMethods() map[string]controlMethod {
+	stats := s.cfg.Stats
+	return map[string]controlMethod{
+		"status": {run: func(json.RawMessage) (any, error) {
+			return stats.Status(), nil
+		}},
+		"peers": {run: func(params json.RawMessage) (any, error) {
+			var p struct {
+				Filter string `json:"filter"`
+				Merged bool   `json:"merged"`
+			}
+			if err := decodeParams(params, &p); err != nil {
+				return nil, err
+			}
+			var f *Filter
+			if p.Filter != "" {
+				var err error
+				if f, err = ParseFilter(p.Filter); err != nil {
+					return nil, &RPCError{Code: RPCInvalidParams, Message: err.Error()}
+				}
+			}
+			peers := stats.Shared().Peers
+			if p.Merged {
+				peers = MergePeers(peers)
+			}
+			return nonNil(FilterPeers(peers, f)), nil
+		}},
+		"routers": {run: func(json.RawMessage) (any, error) {
+			return nonNil(stats.Shared().Routers), nil
+		}},
+		"alerts": {run: func(json.RawMessage) (any, error) {
+			return nonNil(stats.Shared().Alerts), nil
+		}},
+		"bindings": {run: func(json.RawMessage) (any, error) {
+			return nonNil(stats.Shared().Bindings), nil
+		}},
+		"ack_alerts": {mutates: true, run: func(params json.RawMessage) (any, error) {
+			var p struct {
+				Category string `json:"category"`
+				Source   string `json:"source"`
+			}
+			if err := decodeParams(params, &p); err != nil {
+				return nil, err
+			}
+			return map[string]int{"acked": stats.AckAlerts(p.Category, p.Source)}, nil
+		}},
+		"get_log_level": {run: func(json.RawMessage) (any, error) {
+			if s.cfg.Level == nil {
+				return nil, errors.New("the log level is fixed")
+			}
+			return map[string]string{"level": strings.ToLower(s.cfg.Level.Level().String())}, nil
+		}},
+		"set_log_level": {mutates: true, run: func(params json.RawMessage) (any, error) {
+			var p struct {
+				Level string `json:"level"`
+			}
+			if err := decodeParams(params, &p); err != nil {
+				return nil, err
+			}
+			var l slog.Level
+			if err := l.UnmarshalText([]byte(p.Level)); err != nil {
+				return nil, &RPCError{Code: RPCInvalidParams, Message: fmt.Sprintf("unknown level %q (want debug, info, warn or error)", p.Level)}
+			}
+			if s.cfg.Level == nil {
+				return nil, errors.New("the log level is fixed")
+			}
+			s.cfg.Level.Set(l)
+			return map[string]string{"level": strings.ToLower(l.String())}, nil
+		}},
+		"save_snapshot": {mutates: true, run: func(json.RawMessage) (any, error) {
+			snap := s.cfg.Privacy.Snapshot(stats.Snapshot())
+			path, err := WriteSnapshotFile(snap, s.cfg.SnapshotDir, s.cfg.Instance, s.cfg.Anonymizer)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"path": path}, nil
+		}},
+		"clear_stats": {mutates: true, run: func(json.RawMessage) (any, error) {
+			stats.Clear()
+			return nil, nil
+		}},
+	}
+}
+
+// ControlClient calls methods on a running instance's control socket.
+type ControlClient struct {
+	path    string
+	timeout time.Duration
+	id      atomic.Int64
+}
+
+// NewControlClient returns a client for the control socket at path. Each
+// call connects anew and gives up after timeout.
+func NewControlClient(path string, timeout time.Duration) *ControlClient {
+	return &ControlClient{path: path, timeout: timeout}
+}
+
+// Call calls method with params (nil for none) and decodes its result into
+// result (nil to discard it). Errors the instance answers with are
+// *RPCError.
+func (c *ControlClient) Call(ctx context.Context, method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := struct {
+		JSONRPC string `json:"jsonrpc"`
+		ID      int64  `json:"id"`
+		Method  string `json:"method"`
+		Params  any    `json:"params,omitempty"`
+	}{"2.0", c.id.Add(1), method, params}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 64*1024), maxControlLine)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return err
+		}
+		return errors.New("connection closed without an answer (not allowed to use the socket?)")
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(sc.Bytes(), &resp); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package lib
+
+import (
+	"errors"
+	"net"
+)
+
+// peerCred is only implemented on Linux (SO_PEERCRED); elsewhere every
+// control connection is refused.
+func peerCred(c *net.UnixConn) (PeerCred, error) {
+	return PeerCred{}, errors.New("control socket peer credentials are only supported on linux")
+}
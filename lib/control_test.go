@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestControlSocket(t *testing.T) {
+	// Unix socket paths are short; t.TempDir can be too long.
+	dir, err := os.MkdirTemp("", "ndpctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "ctl.sock")
+
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1%eth0", "neighbor_solicitation")
+	stats.RecordAlert(Alert{Category: "rogue_ra", Source: "fe80::1"})
+	level := new(slog.LevelVar)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeControl(ctx, ControlConfig{
+			Path: sock, Stats: stats, Level: level, SnapshotDir: dir,
+			Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		})
+	}()
+
+	client := NewControlClient(sock, time.Second)
+	var peers []PeerSummary
+	for i := 0; ; i++ {
+		if err = client.Call(ctx, "peers", nil, &peers); err == nil || i == 50 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil || len(peers) != 1 {
+		t.Fatalf("peers = %+v, %v", peers, err)
+	}
+	if fi, err := os.Stat(sock); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v; want 0600", fi.Mode(), err)
+	}
+
+	var ack struct{ Acked int }
+	if err := client.Call(ctx, "ack_alerts", nil, &ack); err != nil || ack.Acked != 1 {
+		t.Errorf("ack = %+v, %v", ack, err)
+	}
+	if err := client.Call(ctx, "set_log_level", map[string]string{"level": "warn"}, nil); err != nil || level.Level() != slog.LevelWarn {
+		t.Errorf("log level = %v, %v", level.Level(), err)
+	}
+	var rerr *RPCError
+	if err := client.Call(ctx, "set_log_level", map[string]string{"level": "loud"}, nil); !errors.As(err, &rerr) || rerr.Code != RPCInvalidParams {
+		t.Errorf("unknown log level = %v, want invalid params", err)
+	}
+	if err := client.Call(ctx, "reboot", nil, nil); !errors.As(err, &rerr) || rerr.Code != RPCMethodNotFound {
+		t.Errorf("unknown method = %v, want method not found", err)
+	}
+	var snap struct{ Path string }
+	if err := client.Call(ctx, "save_snapshot", nil, &snap); err != nil || filepath.Dir(snap.Path) != dir {
+		t.Errorf("snapshot = %+v, %v", snap, err)
+	}
+
+	// One connection carries several requests; notifications get no answer.
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(time.Second))
+	io.WriteString(conn, "{not json\n"+
+		`{"jsonrpc":"2.0","method":"clear_stats"}`+"\n"+
+		`{"jsonrpc":"2.0","id":"a","method":"get_log_level"}`+"\n")
+	sc := bufio.NewScanner(conn)
+	var answers []rpcResponse
+	for len(answers) < 2 && sc.Scan() {
+		var resp rpcResponse
+		if err := json.Unmarshal(sc.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		answers = append(answers, resp)
+	}
+	conn.Close()
+	if len(answers) != 2 || answers[0].Error == nil || answers[0].Error.Code != RPCParseError || string(answers[0].ID) != "null" {
+		t.Fatalf("answers = %+v, want a parse error first", answers)
+	}
+	if string(answers[1].ID) != `"a"` || string(answers[1].Result) != `{"level":"warn"}` {
+		t.Errorf("get_log_level = %+v", answers[1])
+	}
+	if len(stats.GetStats()) != 0 {
+		t.Error("clear_stats notification wasn't run")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("socket left behind: %v", err)
+	}
+}
+
+func TestControlAllowed(t *testing.T) {
+	s := &ControlServer{gid: -1}
+	me := os.Getuid()
+	if !s.allowed(PeerCred{UID: 0}) || !s.allowed(PeerCred{UID: me}) {
+		t.Error("root or the daemon's own user refused")
+	}
+	other := PeerCred{UID: me + 12345, GID: 54321}
+	if me == 0 {
+		other.UID = 12345
+	}
+	if s.allowed(other) {
+		t.Error("another user allowed without a control group")
+	}
+	s.gid = 54321
+	if !s.allowed(other) {
+		t.Error("member of the control group refused")
+	}
+}
@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultDownsampleSeconds is how long per-second counters are kept
+	// before they are rolled up into minutes.
+	defaultDownsampleSeconds = 24 * time.Hour
+	// defaultDownsampleMinutes is how long per-minute counters are kept
+	// before they are rolled up into hours.
+	defaultDownsampleMinutes = 30 * 24 * time.Hour
+	// maxPendingSeconds caps the per-second counts NDPStats holds for the
+	// recorder; older seconds are dropped if it falls this far behind.
+	maxPendingSeconds = 3600
+)
+
+// counterTiers are the counter resolutions, finest first.
+var counterTiers = []time.Duration{time.Second, time.Minute, time.Hour}
+
+// CounterBucket is the number of messages of one type in one time bucket.
+type CounterBucket struct {
+	Start      time.Time
+	Resolution time.Duration
+	Kind       string
+	Count      int
+}
+
+// DownsampleConfig sets how long each counter resolution is kept before it
+// is rolled up into the next: 1s, then 1m, then 1h, kept until retention
+// drops it.
+type DownsampleConfig struct {
+	Seconds time.Duration `yaml:"seconds"` // keep 1s buckets this long (default 24h)
+	Minutes time.Duration `yaml:"minutes"` // keep 1m buckets this long (default 720h)
+}
+
+// withDefaults fills in the unset ages.
+func (c DownsampleConfig) withDefaults() DownsampleConfig {
+	if c.Seconds <= 0 {
+		c.Seconds = defaultDownsampleSeconds
+	}
+	if c.Minutes <= 0 {
+		c.Minutes = defaultDownsampleMinutes
+	}
+	return c
+}
+
+// EnableCounters starts counting messages per second and type for
+// TakeCounters. A HistoryRecorder enables it.
+func (s *NDPStats) EnableCounters() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seconds == nil {
+		s.seconds = make(map[int64]map[string]int)
+	}
+}
+
+// countSecondLocked counts one message of kind at now, if counting is on.
+// Callers must hold s.mu.
+func (s *NDPStats) countSecondLocked(kind string, now time.Time) {
+	if s.seconds == nil {
+		return
+	}
+	sec := now.Unix()
+	counts, ok := s.seconds[sec]
+	if !ok {
+		if len(s.seconds) >= maxPendingSeconds {
+			for old := range s.seconds {
+				if old < sec-maxPendingSeconds {
+					delete(s.seconds, old)
+				}
+			}
+		}
+		counts = make(map[string]int)
+		s.seconds[sec] = counts
+	}
+	counts[kind]++
+}
+
+// TakeCounters removes and returns the per-second counts of the seconds
+// that ended by before.
+func (s *NDPStats) TakeCounters(before time.Time) []CounterBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []CounterBucket
+	for sec, counts := range s.seconds {
+		if sec >= before.Unix() {
+			continue
+		}
+		for kind, n := range counts {
+			out = append(out, CounterBucket{Start: time.Unix(sec, 0), Resolution: time.Second, Kind: kind, Count: n})
+		}
+		delete(s.seconds, sec)
+	}
+	return out
+}
+
+// RecordCounters adds bs to the counters table, merging with any buckets
+// already stored.
+func (h *HistoryDB) RecordCounters(bs []CounterBucket) error {
+	if len(bs) == 0 {
+		return nil
+	}
+	tx, err := h.db.Begin()
+	if err != nil {
+		return fmt.Errorf("record counters: %w", err)
+	}
+	defer tx.Rollback()
+	for _, b := range bs {
+		_, err := tx.Exec(`INSERT INTO counters (res, ts, kind, count) VALUES (?, ?, ?, ?)
+			ON CONFLICT (res, ts, kind) DO UPDATE SET count = count + excluded.count`,
+			int64(b.Resolution/time.Second), b.Start.UnixNano(), b.Kind, b.Count)
+		if err != nil {
+			return fmt.Errorf("record counters: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("record counters: %w", err)
+	}
+	return nil
+}
+
+// Downsample rolls per-second counters older than cfg.Seconds up into
+// minutes, and per-minute counters older than cfg.Minutes up into hours.
+// Only whole buckets of the coarser resolution are rolled up. It returns
+// the number of rows replaced.
+func (h *HistoryDB) Downsample(now time.Time, cfg DownsampleConfig) (int64, error) {
+	cfg = cfg.withDefaults()
+	var rolled int64
+	for i, age := range []time.Duration{cfg.Seconds, cfg.Minutes} {
+		from, to := counterTiers[i], counterTiers[i+1]
+		cutoff := now.Add(-age).Truncate(to).UnixNano()
+		n, err := h.rollUp(from, to, cutoff)
+		if err != nil {
+			return rolled, err
+		}
+		rolled += n
+	}
+	return rolled, nil
+}
+
+// rollUp replaces the from-resolution counters before cutoff with their
+// sums per to-resolution bucket, in one transaction.
+func (h *HistoryDB) rollUp(from, to time.Duration, cutoff int64) (int64, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("downsample counters: %w", err)
+	}
+	defer tx.Rollback()
+
+	fromRes, toRes := int64(from/time.Second), int64(to/time.Second)
+	step := int64(to)
+	if _, err := tx.Exec(`INSERT INTO counters (res, ts, kind, count)
+		SELECT ?, ts - ts % ?, kind, SUM(count) FROM counters WHERE res = ? AND ts < ? GROUP BY 2, 3
+		ON CONFLICT (res, ts, kind) DO UPDATE SET count = count + excluded.count`,
+		toRes, step, fromRes, cutoff); err != nil {
+		return 0, fmt.Errorf("downsample counters: %w", err)
+	}
+	res, err := tx.Exec(`DELETE FROM counters WHERE res = ? AND ts < ?`, fromRes, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("downsample counters: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("downsample counters: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,81 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNDPStats_TakeCounters(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_advertisement") // not counted yet
+	stats.EnableCounters()
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordMessage("fe80::2", "neighbor_solicitation")
+
+	if bs := stats.TakeCounters(time.Now().Add(-time.Minute)); len(bs) != 0 {
+		t.Errorf("seconds not yet over were taken: %+v", bs)
+	}
+	bs := stats.TakeCounters(time.Now().Add(time.Second))
+	if len(bs) != 1 || bs[0].Kind != "neighbor_solicitation" || bs[0].Count != 2 || bs[0].Resolution != time.Second {
+		t.Fatalf("counters = %+v, want 2 NS in one second", bs)
+	}
+	if bs := stats.TakeCounters(time.Now().Add(time.Second)); len(bs) != 0 {
+		t.Errorf("counters taken twice: %+v", bs)
+	}
+}
+
+func TestHistoryDownsample(t *testing.T) {
+	db, err := OpenHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// One NS every 10 seconds for the last 3 days, stored per second.
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	var bs []CounterBucket
+	for at := now.Add(-72 * time.Hour); at.Before(now); at = at.Add(10 * time.Second) {
+		bs = append(bs, CounterBucket{Start: at, Resolution: time.Second, Kind: "neighbor_solicitation", Count: 1})
+	}
+	if err := db.RecordCounters(bs); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Downsample(now, DownsampleConfig{Seconds: time.Hour, Minutes: 24 * time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	perRes := func() map[int64][2]int64 { // res -> rows, sum
+		t.Helper()
+		rows, err := db.db.Query(`SELECT res, COUNT(*), SUM(count) FROM counters GROUP BY res`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		out := make(map[int64][2]int64)
+		for rows.Next() {
+			var res, n, sum int64
+			if err := rows.Scan(&res, &n, &sum); err != nil {
+				t.Fatal(err)
+			}
+			out[res] = [2]int64{n, sum}
+		}
+		return out
+	}
+	got := perRes()
+	want := map[int64][2]int64{
+		1:    {360, 360},             // the last hour per second
+		60:   {23 * 60, 23 * 60 * 6}, // the 23 hours before per minute
+		3600: {48, 48 * 360},         // the rest per hour
+	}
+	for res, w := range want {
+		if got[res] != w {
+			t.Errorf("res %ds: rows, sum = %v, want %v", res, got[res], w)
+		}
+	}
+
+	// Rolling up again changes nothing; totals are never lost.
+	if n, err := db.Downsample(now, DownsampleConfig{Seconds: time.Hour, Minutes: 24 * time.Hour}); err != nil || n != 0 {
+		t.Errorf("second Downsample = %d, %v; want 0", n, err)
+	}
+}
@@ -0,0 +1,437 @@
+// Package craft builds well-formed NDP and MLD messages, the IPv6 packets that
+// carry them and the Ethernet frames around those, for tests and tools that
+// need to put NDP on the wire without hand-rolling byte slices.
+//
+// The Build functions return ICMPv6 messages with a zero checksum, which is
+// what a raw ICMPv6 socket expects: the kernel fills it in. IPv6 computes the
+// checksum when wrapping a message for packet-level sockets or pcap files.
+package craft
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+)
+
+// ICMPv6 message types.
+const (
+	TypeMLDQuery       = 130
+	TypeMLDv1Report    = 131
+	TypeMLDv1Done      = 132
+	TypeRS             = 133
+	TypeRA             = 134
+	TypeNS             = 135
+	TypeNA             = 136
+	TypeMLDv2Report    = 143
+	nextHeaderICMPv6   = 58
+	nextHeaderHopByHop = 0
+)
+
+// NDP option types (RFC 4861, RFC 4191, RFC 8106).
+const (
+	optSourceLLA  = 1
+	optTargetLLA  = 2
+	optPrefixInfo = 3
+	optMTU        = 5
+	optRouteInfo  = 24
+	optRDNSS      = 25
+	optDNSSL      = 31
+)
+
+// RS describes a Router Solicitation.
+type RS struct {
+	SourceMAC net.HardwareAddr // Source Link-Layer Address option, omitted if nil
+}
+
+// BuildRS builds a Router Solicitation.
+func BuildRS(rs RS) []byte {
+	msg := make([]byte, 8)
+	msg[0] = TypeRS
+	return appendLLA(msg, optSourceLLA, rs.SourceMAC)
+}
+
+// Prefix is a Prefix Information option.
+type Prefix struct {
+	Prefix            net.IPNet
+	OnLink            bool
+	Autonomous        bool
+	ValidLifetime     time.Duration
+	PreferredLifetime time.Duration
+}
+
+// Route is a Route Information option.
+type Route struct {
+	Prefix     net.IPNet
+	Preference int // 1 high, 0 medium, -1 low
+	Lifetime   time.Duration
+}
+
+// RA describes a Router Advertisement. Zero values leave fields unspecified.
+type RA struct {
+	CurHopLimit    uint8
+	Managed        bool
+	Other          bool
+	Preference     int // default router preference: 1 high, 0 medium, -1 low
+	RouterLifetime time.Duration
+	ReachableTime  time.Duration
+	RetransTimer   time.Duration
+
+	SourceMAC     net.HardwareAddr // Source Link-Layer Address option, omitted if nil
+	MTU           uint32           // MTU option, omitted if 0
+	Prefixes      []Prefix
+	Routes        []Route
+	RDNSS         []net.IP // one RDNSS option with all servers, omitted if empty
+	RDNSSLifetime time.Duration
+	DNSSL         []string // one DNSSL option with all domains, omitted if empty
+	DNSSLLifetime time.Duration
+	// Options are appended verbatim, for option types not modelled here.
+	Options [][]byte
+}
+
+// BuildRA builds a Router Advertisement.
+func BuildRA(ra RA) []byte {
+	msg := make([]byte, 16)
+	msg[0] = TypeRA
+	msg[4] = ra.CurHopLimit
+	if ra.Managed {
+		msg[5] |= 0x80
+	}
+	if ra.Other {
+		msg[5] |= 0x40
+	}
+	msg[5] |= preferenceBits(ra.Preference) << 3
+	binary.BigEndian.PutUint16(msg[6:8], uint16(seconds(ra.RouterLifetime)))
+	binary.BigEndian.PutUint32(msg[8:12], uint32(ra.ReachableTime/time.Millisecond))
+	binary.BigEndian.PutUint32(msg[12:16], uint32(ra.RetransTimer/time.Millisecond))
+
+	msg = appendLLA(msg, optSourceLLA, ra.SourceMAC)
+	if ra.MTU != 0 {
+		opt := make([]byte, 8)
+		opt[0], opt[1] = optMTU, 1
+		binary.BigEndian.PutUint32(opt[4:8], ra.MTU)
+		msg = append(msg, opt...)
+	}
+	for _, p := range ra.Prefixes {
+		opt := make([]byte, 32)
+		opt[0], opt[1] = optPrefixInfo, 4
+		opt[2] = prefixLen(p.Prefix)
+		if p.OnLink {
+			opt[3] |= 0x80
+		}
+		if p.Autonomous {
+			opt[3] |= 0x40
+		}
+		binary.BigEndian.PutUint32(opt[4:8], seconds(p.ValidLifetime))
+		binary.BigEndian.PutUint32(opt[8:12], seconds(p.PreferredLifetime))
+		copy(opt[16:32], p.Prefix.IP.To16())
+		msg = append(msg, opt...)
+	}
+	for _, r := range ra.Routes {
+		// The prefix field is truncated to the 8-byte units it needs.
+		n := prefixLen(r.Prefix)
+		size := 8 + (int(n)+63)/64*8
+		opt := make([]byte, size)
+		opt[0], opt[1] = optRouteInfo, byte(size/8)
+		opt[2] = n
+		opt[3] = preferenceBits(r.Preference) << 3
+		binary.BigEndian.PutUint32(opt[4:8], seconds(r.Lifetime))
+		copy(opt[8:], r.Prefix.IP.To16())
+		msg = append(msg, opt...)
+	}
+	if len(ra.RDNSS) > 0 {
+		opt := make([]byte, 8+16*len(ra.RDNSS))
+		opt[0], opt[1] = optRDNSS, byte(len(opt)/8)
+		binary.BigEndian.PutUint32(opt[4:8], seconds(ra.RDNSSLifetime))
+		for i, srv := range ra.RDNSS {
+			copy(opt[8+16*i:], srv.To16())
+		}
+		msg = append(msg, opt...)
+	}
+	if len(ra.DNSSL) > 0 {
+		opt := make([]byte, 8)
+		opt[0] = optDNSSL
+		binary.BigEndian.PutUint32(opt[4:8], seconds(ra.DNSSLLifetime))
+		for _, name := range ra.DNSSL {
+			for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+				opt = append(opt, byte(len(label)))
+				opt = append(opt, label...)
+			}
+			opt = append(opt, 0)
+		}
+		for len(opt)%8 != 0 {
+			opt = append(opt, 0)
+		}
+		opt[1] = byte(len(opt) / 8)
+		msg = append(msg, opt...)
+	}
+	for _, opt := range ra.Options {
+		msg = append(msg, opt...)
+	}
+	return msg
+}
+
+// NS describes a Neighbor Solicitation. For a DAD probe, leave SourceMAC nil
+// and send it from the unspecified address.
+type NS struct {
+	Target    net.IP
+	SourceMAC net.HardwareAddr // Source Link-Layer Address option, omitted if nil
+}
+
+// BuildNS builds a Neighbor Solicitation.
+func BuildNS(ns NS) []byte {
+	msg := make([]byte, 24)
+	msg[0] = TypeNS
+	copy(msg[8:24], ns.Target.To16())
+	return appendLLA(msg, optSourceLLA, ns.SourceMAC)
+}
+
+// NA describes a Neighbor Advertisement.
+type NA struct {
+	Target    net.IP
+	TargetMAC net.HardwareAddr // Target Link-Layer Address option, omitted if nil
+	Router    bool
+	Solicited bool
+	Override  bool
+}
+
+// BuildNA builds a Neighbor Advertisement.
+func BuildNA(na NA) []byte {
+	msg := make([]byte, 24)
+	msg[0] = TypeNA
+	if na.Router {
+		msg[4] |= 0x80
+	}
+	if na.Solicited {
+		msg[4] |= 0x40
+	}
+	if na.Override {
+		msg[4] |= 0x20
+	}
+	copy(msg[8:24], na.Target.To16())
+	return appendLLA(msg, optTargetLLA, na.TargetMAC)
+}
+
+// MLDQuery describes a Multicast Listener Query.
+type MLDQuery struct {
+	Version          int    // 1 or 2; 0 means 2
+	Group            net.IP // nil for a general query
+	MaxResponseDelay time.Duration
+	// MLDv2 only.
+	Sources  []net.IP
+	Suppress bool          // S flag: suppress router-side processing
+	QRV      uint8         // querier's robustness variable, 0-7
+	QQI      time.Duration // querier's query interval
+}
+
+// BuildMLDQuery builds an MLDv1 or MLDv2 query. Send it with hop limit 1 and
+// the Router Alert option (see IPv6Packet).
+func BuildMLDQuery(q MLDQuery) []byte {
+	delay := q.MaxResponseDelay / time.Millisecond
+	if q.Version == 1 {
+		msg := make([]byte, 24)
+		msg[0] = TypeMLDQuery
+		binary.BigEndian.PutUint16(msg[4:6], uint16(min(delay, 0xffff)))
+		copy(msg[8:24], q.Group.To16())
+		return msg
+	}
+
+	msg := make([]byte, 28+16*len(q.Sources))
+	msg[0] = TypeMLDQuery
+	binary.BigEndian.PutUint16(msg[4:6], floatCode16(uint32(delay)))
+	copy(msg[8:24], q.Group.To16())
+	if q.Suppress {
+		msg[24] |= 0x08
+	}
+	msg[24] |= q.QRV & 0x07
+	msg[25] = floatCode8(seconds(q.QQI))
+	binary.BigEndian.PutUint16(msg[26:28], uint16(len(q.Sources)))
+	for i, src := range q.Sources {
+		copy(msg[28+16*i:], src.To16())
+	}
+	return msg
+}
+
+// MLDv2 multicast address record types (RFC 3810 section 5.2.12).
+const (
+	ModeIsInclude       = 1
+	ModeIsExclude       = 2
+	ChangeToIncludeMode = 3
+	ChangeToExcludeMode = 4
+	AllowNewSources     = 5
+	BlockOldSources     = 6
+)
+
+// MLDRecord is one multicast address record of an MLDv2 report.
+type MLDRecord struct {
+	Type    int // ModeIsInclude ... BlockOldSources
+	Group   net.IP
+	Sources []net.IP
+}
+
+// BuildMLDv1Report builds an MLDv1 report for group.
+func BuildMLDv1Report(group net.IP) []byte {
+	msg := make([]byte, 24)
+	msg[0] = TypeMLDv1Report
+	copy(msg[8:24], group.To16())
+	return msg
+}
+
+// BuildMLDv2Report builds an MLDv2 report with the given records.
+func BuildMLDv2Report(records ...MLDRecord) []byte {
+	msg := make([]byte, 8)
+	msg[0] = TypeMLDv2Report
+	binary.BigEndian.PutUint16(msg[6:8], uint16(len(records)))
+	for _, r := range records {
+		rec := make([]byte, 20+16*len(r.Sources))
+		rec[0] = byte(r.Type)
+		binary.BigEndian.PutUint16(rec[2:4], uint16(len(r.Sources)))
+		copy(rec[4:20], r.Group.To16())
+		for i, src := range r.Sources {
+			copy(rec[20+16*i:], src.To16())
+		}
+		msg = append(msg, rec...)
+	}
+	return msg
+}
+
+// routerAlertMLD is a Hop-by-Hop option area holding the Router Alert option
+// with the MLD value, padded with PadN to the 8-byte header size.
+var routerAlertMLD = []byte{5, 2, 0, 0, 1, 0}
+
+// IPv6Packet wraps the ICMPv6 message msg in an IPv6 header and fills in its
+// checksum. routerAlert adds the Hop-by-Hop Router Alert option MLD requires.
+func IPv6Packet(src, dst net.IP, hopLimit uint8, routerAlert bool, msg []byte) []byte {
+	var hbh []byte
+	next := byte(nextHeaderICMPv6)
+	if routerAlert {
+		hbh = append([]byte{nextHeaderICMPv6, 0}, routerAlertMLD...)
+		next = nextHeaderHopByHop
+	}
+	plen := len(hbh) + len(msg)
+	pkt := make([]byte, 40, 40+plen)
+	pkt[0] = 6 << 4
+	binary.BigEndian.PutUint16(pkt[4:6], uint16(plen))
+	pkt[6] = next
+	pkt[7] = hopLimit
+	copy(pkt[8:24], src.To16())
+	copy(pkt[24:40], dst.To16())
+	pkt = append(pkt, hbh...)
+	pkt = append(pkt, msg...)
+
+	if len(msg) >= 4 {
+		m := pkt[40+len(hbh):]
+		m[2], m[3] = 0, 0
+		binary.BigEndian.PutUint16(m[2:4], Checksum(src, dst, m))
+	}
+	return pkt
+}
+
+// Checksum computes the ICMPv6 checksum of msg over the IPv6 pseudo-header
+// (RFC 8200 section 8.1). Computed over a message whose checksum field is
+// filled in, a valid message yields 0.
+func Checksum(src, dst net.IP, msg []byte) uint16 {
+	var sum uint32
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(b[i])<<8 | uint32(b[i+1])
+		}
+		if len(b)%2 == 1 {
+			sum += uint32(b[len(b)-1]) << 8
+		}
+	}
+	add(src.To16())
+	add(dst.To16())
+	sum += uint32(len(msg)>>16) + uint32(len(msg)&0xffff)
+	sum += nextHeaderICMPv6
+	add(msg)
+
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+// MulticastMAC returns the Ethernet address IPv6 multicast group ip maps to
+// (33:33 followed by its low 32 bits).
+func MulticastMAC(ip net.IP) net.HardwareAddr {
+	ip = ip.To16()
+	return net.HardwareAddr{0x33, 0x33, ip[12], ip[13], ip[14], ip[15]}
+}
+
+// EthernetFrame wraps an IPv6 packet in an Ethernet II header. A nil dst is
+// derived from the packet's destination, which must then be multicast.
+func EthernetFrame(dst, src net.HardwareAddr, pkt []byte) []byte {
+	if dst == nil && len(pkt) >= 40 {
+		dst = MulticastMAC(net.IP(pkt[24:40]))
+	}
+	frame := make([]byte, 0, 14+len(pkt))
+	frame = append(frame, dst...)
+	frame = append(frame, src...)
+	frame = append(frame, 0x86, 0xdd)
+	return append(frame, pkt...)
+}
+
+func appendLLA(msg []byte, typ byte, mac net.HardwareAddr) []byte {
+	if mac == nil {
+		return msg
+	}
+	// Type and length, then the address padded to a multiple of 8 bytes.
+	opt := make([]byte, (2+len(mac)+7)/8*8)
+	opt[0], opt[1] = typ, byte(len(opt)/8)
+	copy(opt[2:], mac)
+	return append(msg, opt...)
+}
+
+func prefixLen(n net.IPNet) byte {
+	ones, _ := n.Mask.Size()
+	return byte(ones)
+}
+
+// preferenceBits encodes a router or route preference as its 2-bit field.
+func preferenceBits(pref int) byte {
+	switch {
+	case pref > 0:
+		return 0x01
+	case pref < 0:
+		return 0x03
+	}
+	return 0
+}
+
+// seconds converts d to whole seconds, with negative durations meaning
+// infinity (0xffffffff).
+func seconds(d time.Duration) uint32 {
+	if d < 0 {
+		return 0xffffffff
+	}
+	return uint32(d / time.Second)
+}
+
+// floatCode16 encodes an MLDv2 Maximum Response Code (RFC 3810 section
+// 5.1.3): values from 32768 use a 3-bit exponent and 12-bit mantissa.
+func floatCode16(v uint32) uint16 {
+	if v < 0x8000 {
+		return uint16(v)
+	}
+	for exp := uint32(0); exp < 8; exp++ {
+		if m := v >> (exp + 3); m < 0x2000 {
+			return uint16(0x8000 | exp<<12 | m&0x0fff)
+		}
+	}
+	return 0xffff
+}
+
+// floatCode8 encodes an MLDv2 QQIC (RFC 3810 section 5.1.9): values from 128
+// use a 3-bit exponent and 4-bit mantissa.
+func floatCode8(v uint32) byte {
+	if v < 0x80 {
+		return byte(v)
+	}
+	for exp := uint32(0); exp < 8; exp++ {
+		if m := v >> (exp + 3); m < 0x20 {
+			return byte(0x80 | exp<<4 | m&0x0f)
+		}
+	}
+	return 0xff
+}
@@ -0,0 +1,186 @@
+package craft
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func mustCIDR(s string) net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return *n
+}
+
+func TestBuildRA(t *testing.T) {
+	mac := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0x01}
+	msg := BuildRA(RA{
+		CurHopLimit:    64,
+		Managed:        true,
+		Preference:     1,
+		RouterLifetime: 1800 * time.Second,
+		ReachableTime:  30 * time.Second,
+		SourceMAC:      mac,
+		MTU:            1500,
+		Prefixes: []Prefix{{Prefix: mustCIDR("2001:db8:1::/64"), OnLink: true, Autonomous: true,
+			ValidLifetime: -1, PreferredLifetime: time.Hour}},
+		Routes:        []Route{{Prefix: mustCIDR("2001:db8:2::/48"), Preference: -1, Lifetime: time.Minute}},
+		RDNSS:         []net.IP{net.ParseIP("2001:db8::53")},
+		RDNSSLifetime: 10 * time.Minute,
+	})
+
+	if msg[0] != TypeRA || msg[4] != 64 || msg[5] != 0x88 {
+		t.Errorf("header = % x", msg[:8])
+	}
+	if got := binary.BigEndian.Uint16(msg[6:8]); got != 1800 {
+		t.Errorf("router lifetime = %d, want 1800", got)
+	}
+	if got := binary.BigEndian.Uint32(msg[8:12]); got != 30000 {
+		t.Errorf("reachable time = %d, want 30000", got)
+	}
+
+	// Walk the options, checking each and recording the order.
+	var types []byte
+	opts := msg[16:]
+	for len(opts) >= 8 {
+		n := int(opts[1]) * 8
+		if n == 0 || n > len(opts) {
+			t.Fatalf("bad option length %d", opts[1])
+		}
+		switch opts[0] {
+		case optSourceLLA:
+			if !bytes.Equal(opts[2:8], mac) {
+				t.Errorf("source LLA = % x", opts[2:8])
+			}
+		case optPrefixInfo:
+			if opts[2] != 64 || opts[3] != 0xc0 || binary.BigEndian.Uint32(opts[4:8]) != 0xffffffff {
+				t.Errorf("prefix option = % x", opts[:8])
+			}
+		case optRouteInfo:
+			if n != 16 || opts[2] != 48 || opts[3] != 0x18 {
+				t.Errorf("route option = % x", opts[:n])
+			}
+		case optRDNSS:
+			if n != 24 || !net.IP(opts[8:24]).Equal(net.ParseIP("2001:db8::53")) {
+				t.Errorf("rdnss option = % x", opts[:n])
+			}
+		}
+		types = append(types, opts[0])
+		opts = opts[n:]
+	}
+	if want := []byte{optSourceLLA, optMTU, optPrefixInfo, optRouteInfo, optRDNSS}; !bytes.Equal(types, want) || len(opts) != 0 {
+		t.Errorf("options = %v (%d bytes left), want %v", types, len(opts), want)
+	}
+}
+
+func TestBuildNSAndNA(t *testing.T) {
+	target := net.ParseIP("fe80::1")
+	mac := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0x01}
+
+	ns := BuildNS(NS{Target: target})
+	if len(ns) != 24 || ns[0] != TypeNS || !net.IP(ns[8:24]).Equal(target) {
+		t.Errorf("DAD probe = % x", ns)
+	}
+	na := BuildNA(NA{Target: target, TargetMAC: mac, Solicited: true, Override: true})
+	if len(na) != 32 || na[4] != 0x60 || na[24] != optTargetLLA || !bytes.Equal(na[26:32], mac) {
+		t.Errorf("NA = % x", na)
+	}
+}
+
+func TestBuildMLDQuery(t *testing.T) {
+	v1 := BuildMLDQuery(MLDQuery{Version: 1, Group: net.ParseIP("ff02::fb"), MaxResponseDelay: time.Second})
+	if len(v1) != 24 || binary.BigEndian.Uint16(v1[4:6]) != 1000 || !net.IP(v1[8:24]).Equal(net.ParseIP("ff02::fb")) {
+		t.Errorf("v1 query = % x", v1)
+	}
+
+	v2 := BuildMLDQuery(MLDQuery{
+		MaxResponseDelay: 65536 * time.Millisecond,
+		Sources:          []net.IP{net.ParseIP("2001:db8::1")},
+		QRV:              2,
+		QQI:              125 * time.Second,
+	})
+	if len(v2) != 44 || !net.IP(v2[8:24]).IsUnspecified() || v2[24] != 2 || v2[25] != 125 ||
+		binary.BigEndian.Uint16(v2[26:28]) != 1 {
+		t.Errorf("v2 query = % x", v2)
+	}
+	// exp 1, mant 0: (0x1000) << 4 = 65536 ms
+	if got := binary.BigEndian.Uint16(v2[4:6]); got != 0x9000 {
+		t.Errorf("max response code = %#x, want 0x9000", got)
+	}
+}
+
+func TestFloatCodes(t *testing.T) {
+	for _, v := range []uint32{0, 1000, 32767, 32768, 65536, 100000, 8387584} {
+		code := floatCode16(v)
+		got := uint32(code)
+		if code >= 0x8000 {
+			got = (uint32(code)&0x0fff | 0x1000) << ((code>>12)&7 + 3)
+		}
+		// The mantissa keeps 13 significant bits, so large values round down.
+		if got > v || v-got > v>>12 {
+			t.Errorf("floatCode16(%d) = %#x, decodes to %d", v, code, got)
+		}
+	}
+	if got := floatCode8(125); got != 125 {
+		t.Errorf("floatCode8(125) = %d", got)
+	}
+	// exp 1, mant 0: 0x10 << 4 = 256
+	if got := floatCode8(256); got != 0x90 {
+		t.Errorf("floatCode8(256) = %#x, want 0x90", got)
+	}
+}
+
+func TestIPv6PacketAndFrame(t *testing.T) {
+	src, dst := net.ParseIP("fe80::10"), net.ParseIP("ff02::16")
+	report := BuildMLDv2Report(MLDRecord{Type: ChangeToExcludeMode, Group: net.ParseIP("ff02::fb")})
+	pkt := IPv6Packet(src, dst, 1, true, report)
+
+	if pkt[0]>>4 != 6 || pkt[6] != nextHeaderHopByHop || pkt[7] != 1 || pkt[40] != nextHeaderICMPv6 {
+		t.Fatalf("header = % x", pkt[:48])
+	}
+	if got := int(binary.BigEndian.Uint16(pkt[4:6])); got != 8+len(report) {
+		t.Errorf("payload length = %d, want %d", got, 8+len(report))
+	}
+	if sum := Checksum(src, dst, pkt[48:]); sum != 0 {
+		t.Errorf("checksum does not verify: %#x", sum)
+	}
+
+	mac := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	frame := EthernetFrame(nil, mac, pkt)
+	if want := (net.HardwareAddr{0x33, 0x33, 0, 0, 0, 0x16}); !bytes.Equal(frame[:6], want) {
+		t.Errorf("frame dst = %v, want %v", net.HardwareAddr(frame[:6]), want)
+	}
+	if !bytes.Equal(frame[6:12], mac) || frame[12] != 0x86 || frame[13] != 0xdd || !bytes.Equal(frame[14:], pkt) {
+		t.Errorf("frame = % x", frame[:14])
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	names := make(map[string]bool)
+	for _, f := range Fixtures() {
+		if names[f.Name] {
+			t.Errorf("duplicate fixture name %s", f.Name)
+		}
+		names[f.Name] = true
+		if f.SrcMAC == nil || f.Description == "" {
+			t.Errorf("%s: missing source MAC or description", f.Name)
+		}
+
+		pkt := f.Packet
+		if got := int(binary.BigEndian.Uint16(pkt[4:6])); got != len(pkt)-40 {
+			t.Errorf("%s: payload length %d, packet carries %d", f.Name, got, len(pkt)-40)
+		}
+		msg := pkt[40:]
+		if pkt[6] != nextHeaderICMPv6 {
+			msg = pkt[48:] // the fixtures use at most one 8-byte extension header
+		}
+		src, dst := net.IP(pkt[8:24]), net.IP(pkt[24:40])
+		if verifies := Checksum(src, dst, msg) == 0; verifies != (f.Name != "bad-checksum") {
+			t.Errorf("%s: checksum verifies = %v", f.Name, verifies)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package craft
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// Fixture is one packet of the test-lab corpus: a named NDP or MLD message
+// in its IPv6 packet, well-formed or deliberately broken.
+type Fixture struct {
+	Name        string
+	Description string
+	// Malformed fixtures violate RFC 4861, RFC 3810 or RFC 8200; a
+	// conforming receiver drops or flags them.
+	Malformed bool
+	Packet    []byte           // the IPv6 packet
+	SrcMAC    net.HardwareAddr // Ethernet source
+	DstMAC    net.HardwareAddr // Ethernet destination; nil derives it from a multicast destination
+}
+
+// Frame returns the fixture in an Ethernet II frame.
+func (f Fixture) Frame() []byte {
+	return EthernetFrame(f.DstMAC, f.SrcMAC, f.Packet)
+}
+
+// Addresses the fixtures are exchanged between.
+var (
+	fixRouter     = net.ParseIP("fe80::1")
+	fixRouterMAC  = net.HardwareAddr{0x02, 0x00, 0x5e, 0x00, 0x00, 0x01}
+	fixHost       = net.ParseIP("fe80::200:5eff:fe00:10")
+	fixHostMAC    = net.HardwareAddr{0x02, 0x00, 0x5e, 0x00, 0x00, 0x10}
+	fixHostGlobal = net.ParseIP("2001:db8:1::10")
+	fixAllNodes   = net.ParseIP("ff02::1")
+	fixAllRouters = net.ParseIP("ff02::2")
+	fixMLDv2      = net.ParseIP("ff02::16")
+	fixGroup      = net.ParseIP("ff05::1:3")
+	fixSolicited  = net.ParseIP("ff02::1:ff00:10")
+	fixSource     = net.ParseIP("2001:db8:2::53")
+)
+
+// Fixtures returns the corpus: every message type NDPeekr decodes with each
+// option it understands, followed by the malformed cases. Names are stable
+// and unique, so tools can refer to single fixtures.
+func Fixtures() []Fixture {
+	_, prefix, _ := net.ParseCIDR("2001:db8:1::/64")
+	_, prefix2, _ := net.ParseCIDR("2001:db8:3::/64")
+	_, route, _ := net.ParseCIDR("2001:db8:100::/48")
+	_, route2, _ := net.ParseCIDR("::/0")
+
+	fromRouter := func(dst net.IP, msg []byte) []byte { return IPv6Packet(fixRouter, dst, 255, false, msg) }
+	fromHost := func(dst net.IP, msg []byte) []byte { return IPv6Packet(fixHost, dst, 255, false, msg) }
+	mld := func(dst net.IP, msg []byte) []byte { return IPv6Packet(fixHost, dst, 1, true, msg) }
+	query := func(dst net.IP, msg []byte) []byte { return IPv6Packet(fixRouter, dst, 1, true, msg) }
+
+	fullRA := RA{
+		CurHopLimit:    64,
+		Managed:        true,
+		Other:          true,
+		Preference:     1,
+		RouterLifetime: 30 * time.Minute,
+		ReachableTime:  30 * time.Second,
+		RetransTimer:   time.Second,
+		SourceMAC:      fixRouterMAC,
+		MTU:            1500,
+		Prefixes: []Prefix{
+			{Prefix: *prefix, OnLink: true, Autonomous: true, ValidLifetime: 24 * time.Hour, PreferredLifetime: 4 * time.Hour},
+			{Prefix: *prefix2, OnLink: true, ValidLifetime: -1, PreferredLifetime: -1},
+		},
+		Routes:        []Route{{Prefix: *route, Preference: 1, Lifetime: time.Hour}, {Prefix: *route2, Preference: -1, Lifetime: time.Hour}},
+		RDNSS:         []net.IP{net.ParseIP("2001:db8:1::53"), net.ParseIP("2001:db8:2::53")},
+		RDNSSLifetime: time.Hour,
+	}
+
+	fixtures := []Fixture{
+		{Name: "rs", Description: "Router Solicitation with source link-layer address",
+			Packet: fromHost(fixAllRouters, BuildRS(RS{SourceMAC: fixHostMAC})), SrcMAC: fixHostMAC},
+		{Name: "rs-unspecified", Description: "Router Solicitation from :: without options, as sent before an address is configured",
+			Packet: IPv6Packet(net.IPv6unspecified, fixAllRouters, 255, false, BuildRS(RS{})), SrcMAC: fixHostMAC},
+		{Name: "ra-minimal", Description: "Router Advertisement without options",
+			Packet: fromRouter(fixAllNodes, BuildRA(RA{CurHopLimit: 64, RouterLifetime: 30 * time.Minute})), SrcMAC: fixRouterMAC},
+		{Name: "ra-full", Description: "Router Advertisement with M/O flags, high preference, link-layer address, MTU, two prefixes, two routes and RDNSS",
+			Packet: fromRouter(fixAllNodes, BuildRA(fullRA)), SrcMAC: fixRouterMAC},
+		{Name: "ra-low-preference", Description: "Router Advertisement with low default router preference",
+			Packet: fromRouter(fixAllNodes, BuildRA(RA{CurHopLimit: 64, Preference: -1, RouterLifetime: 30 * time.Minute, SourceMAC: fixRouterMAC})), SrcMAC: fixRouterMAC},
+		{Name: "ra-zero-lifetime", Description: "Router Advertisement with router lifetime 0: not a default router, or one shutting down",
+			Packet: fromRouter(fixAllNodes, BuildRA(RA{CurHopLimit: 64, SourceMAC: fixRouterMAC})), SrcMAC: fixRouterMAC},
+		{Name: "ra-unicast", Description: "Router Advertisement answering a solicitation, unicast to the host",
+			Packet: fromRouter(fixHost, BuildRA(RA{CurHopLimit: 64, RouterLifetime: 30 * time.Minute, SourceMAC: fixRouterMAC})), SrcMAC: fixRouterMAC, DstMAC: fixHostMAC},
+		{Name: "ns-address-resolution", Description: "Neighbor Solicitation to a solicited-node group with source link-layer address",
+			Packet: IPv6Packet(fixRouter, fixSolicited, 255, false, BuildNS(NS{Target: fixHost, SourceMAC: fixRouterMAC})), SrcMAC: fixRouterMAC},
+		{Name: "ns-unreachability", Description: "Unicast Neighbor Solicitation checking reachability",
+			Packet: fromRouter(fixHost, BuildNS(NS{Target: fixHost})), SrcMAC: fixRouterMAC, DstMAC: fixHostMAC},
+		{Name: "ns-dad", Description: "Duplicate Address Detection probe: from :: without options",
+			Packet: IPv6Packet(net.IPv6unspecified, fixSolicited, 255, false, BuildNS(NS{Target: fixHostGlobal})), SrcMAC: fixHostMAC},
+		{Name: "na-solicited", Description: "Solicited Neighbor Advertisement with target link-layer address",
+			Packet: fromHost(fixRouter, BuildNA(NA{Target: fixHost, TargetMAC: fixHostMAC, Solicited: true, Override: true})), SrcMAC: fixHostMAC, DstMAC: fixRouterMAC},
+		{Name: "na-unsolicited", Description: "Unsolicited Neighbor Advertisement to all nodes, as after a link-layer address change",
+			Packet: fromHost(fixAllNodes, BuildNA(NA{Target: fixHost, TargetMAC: fixHostMAC, Override: true})), SrcMAC: fixHostMAC},
+		{Name: "na-router", Description: "Neighbor Advertisement with the router flag",
+			Packet: fromRouter(fixAllNodes, BuildNA(NA{Target: fixRouter, TargetMAC: fixRouterMAC, Router: true, Override: true})), SrcMAC: fixRouterMAC},
+		{Name: "na-dad-defence", Description: "Neighbor Advertisement defending an address a DAD probe asked for",
+			Packet: fromHost(fixAllNodes, BuildNA(NA{Target: fixHostGlobal, TargetMAC: fixHostMAC, Override: true})), SrcMAC: fixHostMAC},
+		{Name: "redirect", Description: "Redirect pointing the host to a better first hop",
+			Packet: fromRouter(fixHost, buildRedirect(fixRouter, net.ParseIP("2001:db8:100::1"))), SrcMAC: fixRouterMAC, DstMAC: fixHostMAC},
+		{Name: "mld-query-v1", Description: "MLDv1 general query",
+			Packet: query(fixAllNodes, BuildMLDQuery(MLDQuery{Version: 1, MaxResponseDelay: 10 * time.Second})), SrcMAC: fixRouterMAC},
+		{Name: "mld-query-v2-general", Description: "MLDv2 general query with robustness variable and query interval",
+			Packet: query(fixAllNodes, BuildMLDQuery(MLDQuery{MaxResponseDelay: 10 * time.Second, QRV: 2, QQI: 125 * time.Second})), SrcMAC: fixRouterMAC},
+		{Name: "mld-query-v2-group", Description: "MLDv2 group-specific query",
+			Packet: query(fixGroup, BuildMLDQuery(MLDQuery{Group: fixGroup, MaxResponseDelay: time.Second, QRV: 2, QQI: 125 * time.Second})), SrcMAC: fixRouterMAC},
+		{Name: "mld-query-v2-source", Description: "MLDv2 group-and-source-specific query with suppressed router-side processing",
+			Packet: query(fixGroup, BuildMLDQuery(MLDQuery{Group: fixGroup, Sources: []net.IP{fixSource}, Suppress: true, MaxResponseDelay: time.Second, QRV: 2, QQI: 125 * time.Second})), SrcMAC: fixRouterMAC},
+		{Name: "mldv1-report", Description: "MLDv1 report joining a group",
+			Packet: mld(fixGroup, BuildMLDv1Report(fixGroup)), SrcMAC: fixHostMAC},
+		{Name: "mldv1-done", Description: "MLDv1 done leaving a group",
+			Packet: mld(fixAllRouters, buildMLDv1Done(fixGroup)), SrcMAC: fixHostMAC},
+		{Name: "mldv2-report-join", Description: "MLDv2 report joining two groups",
+			Packet: mld(fixMLDv2, BuildMLDv2Report(MLDRecord{Type: ChangeToExcludeMode, Group: fixGroup}, MLDRecord{Type: ChangeToExcludeMode, Group: fixSolicited})), SrcMAC: fixHostMAC},
+		{Name: "mldv2-report-leave", Description: "MLDv2 report leaving a group",
+			Packet: mld(fixMLDv2, BuildMLDv2Report(MLDRecord{Type: ChangeToIncludeMode, Group: fixGroup})), SrcMAC: fixHostMAC},
+		{Name: "mldv2-report-current", Description: "MLDv2 current-state report answering a query",
+			Packet: mld(fixMLDv2, BuildMLDv2Report(MLDRecord{Type: ModeIsExclude, Group: fixGroup}, MLDRecord{Type: ModeIsInclude, Group: fixSolicited, Sources: []net.IP{fixSource}})), SrcMAC: fixHostMAC},
+		{Name: "mldv2-report-sources", Description: "MLDv2 report allowing and blocking sources",
+			Packet: mld(fixMLDv2, BuildMLDv2Report(MLDRecord{Type: AllowNewSources, Group: fixGroup, Sources: []net.IP{fixSource}}, MLDRecord{Type: BlockOldSources, Group: fixGroup, Sources: []net.IP{fixSource}})), SrcMAC: fixHostMAC},
+		{Name: "ra-destination-options", Description: "Router Advertisement behind a Destination Options header, which receivers must skip",
+			Packet: withExtHeader(fixRouter, fixAllNodes, 255, extDestOpts, make([]byte, 6), BuildRA(RA{CurHopLimit: 64, RouterLifetime: 30 * time.Minute, SourceMAC: fixRouterMAC})), SrcMAC: fixRouterMAC},
+	}
+
+	malformed := []Fixture{
+		{Name: "bad-checksum", Description: "Neighbor Solicitation with a corrupted ICMPv6 checksum",
+			Packet: corruptChecksum(IPv6Packet(fixRouter, fixSolicited, 255, false, BuildNS(NS{Target: fixHost, SourceMAC: fixRouterMAC})))},
+		{Name: "ra-hop-limit-64", Description: "Router Advertisement with IPv6 hop limit 64, so it was routed rather than sent on-link",
+			Packet: IPv6Packet(fixRouter, fixAllNodes, 64, false, BuildRA(RA{CurHopLimit: 64, RouterLifetime: 30 * time.Minute, SourceMAC: fixRouterMAC}))},
+		{Name: "ra-global-source", Description: "Router Advertisement from a global address; RAs must come from a link-local one",
+			Packet: IPv6Packet(net.ParseIP("2001:db8:1::1"), fixAllNodes, 255, false, BuildRA(RA{CurHopLimit: 64, RouterLifetime: 30 * time.Minute, SourceMAC: fixRouterMAC}))},
+		{Name: "ns-truncated", Description: "Neighbor Solicitation cut off inside the target address",
+			Packet: fromRouter(fixSolicited, BuildNS(NS{Target: fixHost})[:16])},
+		{Name: "ns-zero-length-option", Description: "Neighbor Solicitation with an option of length 0",
+			Packet: fromRouter(fixSolicited, append(BuildNS(NS{Target: fixHost}), optSourceLLA, 0, 0, 0, 0, 0, 0, 0))},
+		{Name: "ns-option-overrun", Description: "Neighbor Solicitation whose option claims more bytes than remain",
+			Packet: fromRouter(fixSolicited, append(BuildNS(NS{Target: fixHost}), optSourceLLA, 4, 0x02, 0, 0x5e, 0, 0, 1))},
+		{Name: "ns-dad-with-slla", Description: "DAD probe from :: carrying a source link-layer address option",
+			Packet: IPv6Packet(net.IPv6unspecified, fixSolicited, 255, false, BuildNS(NS{Target: fixHostGlobal, SourceMAC: fixHostMAC}))},
+		{Name: "na-multicast-target", Description: "Neighbor Advertisement for a multicast target",
+			Packet: fromHost(fixAllNodes, BuildNA(NA{Target: fixGroup, TargetMAC: fixHostMAC}))},
+		{Name: "ra-prefix-bad-length", Description: "Router Advertisement with a Prefix Information option of length 3 instead of 4",
+			Packet: fromRouter(fixAllNodes, BuildRA(RA{CurHopLimit: 64, RouterLifetime: 30 * time.Minute, Options: [][]byte{badPrefixOption(*prefix)}}))},
+		{Name: "mld-no-router-alert", Description: "MLDv2 report without the Hop-by-Hop Router Alert option",
+			Packet: IPv6Packet(fixHost, fixMLDv2, 1, false, BuildMLDv2Report(MLDRecord{Type: ChangeToExcludeMode, Group: fixGroup}))},
+		{Name: "mld-hop-limit-255", Description: "MLDv1 report with hop limit 255; MLD must be sent with hop limit 1",
+			Packet: IPv6Packet(fixHost, fixGroup, 255, true, BuildMLDv1Report(fixGroup))},
+		{Name: "mldv2-record-overrun", Description: "MLDv2 report announcing three records but carrying one",
+			Packet: mld(fixMLDv2, overstateRecords(BuildMLDv2Report(MLDRecord{Type: ChangeToExcludeMode, Group: fixGroup}), 3))},
+		{Name: "ra-fragment", Description: "Router Advertisement in an atomic fragment (RFC 6980 forbids fragmented NDP)",
+			Packet: withExtHeader(fixRouter, fixAllNodes, 255, extFragment, make([]byte, 6), BuildRA(RA{CurHopLimit: 64, RouterLifetime: 30 * time.Minute, SourceMAC: fixRouterMAC}))},
+		{Name: "ra-routing-header", Description: "Router Advertisement behind a Routing header",
+			Packet: withExtHeader(fixRouter, fixAllNodes, 255, extRouting, make([]byte, 6), BuildRA(RA{CurHopLimit: 64, RouterLifetime: 30 * time.Minute, SourceMAC: fixRouterMAC}))},
+	}
+	for i := range malformed {
+		malformed[i].Malformed = true
+		malformed[i].SrcMAC = fixHostMAC
+		if net.IP(malformed[i].Packet[8:24]).Equal(fixRouter) {
+			malformed[i].SrcMAC = fixRouterMAC
+		}
+	}
+	return append(fixtures, malformed...)
+}
+
+// IPv6 extension header types used by the fixtures.
+const (
+	extRouting   = 43
+	extFragment  = 44
+	extDestOpts  = 60
+	typeRedirect = 137
+)
+
+// withExtHeader is IPv6Packet with one 8-byte extension header of type ext
+// and body (6 bytes) in front of msg.
+func withExtHeader(src, dst net.IP, hopLimit uint8, ext byte, body, msg []byte) []byte {
+	pkt := IPv6Packet(src, dst, hopLimit, false, msg)
+	hdr := append([]byte{nextHeaderICMPv6, 0}, body...)
+	out := append(pkt[:40:40], hdr...)
+	out = append(out, pkt[40:]...)
+	out[6] = ext
+	binary.BigEndian.PutUint16(out[4:6], uint16(len(out)-40))
+	return out
+}
+
+// buildRedirect builds a Redirect telling the receiver to reach dst through
+// target.
+func buildRedirect(target, dst net.IP) []byte {
+	msg := make([]byte, 40)
+	msg[0] = typeRedirect
+	copy(msg[8:24], target.To16())
+	copy(msg[24:40], dst.To16())
+	return msg
+}
+
+// buildMLDv1Done builds an MLDv1 done for group.
+func buildMLDv1Done(group net.IP) []byte {
+	msg := BuildMLDv1Report(group)
+	msg[0] = TypeMLDv1Done
+	return msg
+}
+
+// corruptChecksum flips the ICMPv6 checksum of an IPv6Packet without
+// extension headers.
+func corruptChecksum(pkt []byte) []byte {
+	pkt[42] ^= 0xff
+	return pkt
+}
+
+// badPrefixOption is a Prefix Information option truncated to 24 bytes.
+func badPrefixOption(p net.IPNet) []byte {
+	opt := make([]byte, 24)
+	opt[0], opt[1] = optPrefixInfo, 3
+	opt[2] = prefixLen(p)
+	copy(opt[8:24], p.IP.To16())
+	return opt
+}
+
+// overstateRecords sets the record count of an MLDv2 report to n.
+func overstateRecords(msg []byte, n uint16) []byte {
+	binary.BigEndian.PutUint16(msg[6:8], n)
+	return msg
+}
@@ -0,0 +1,242 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// dadRetransTimer is the default RetransTimer: with the default
+	// DupAddrDetectTransmits of 1, a node waits this long after its probe
+	// before using the address (RFC 4861 and RFC 4862).
+	dadRetransTimer = time.Second
+	// dadTimeout is how long after the last probe an unanswered DAD
+	// transaction is considered to have passed. It leaves room for hosts
+	// configured with a longer RetransTimer.
+	dadTimeout = 2 * dadRetransTimer
+	// dadConflictWindow is how long after a transaction's last probe NAs
+	// for its address still count toward a conflict: the defence, and the
+	// requester announcing the address once DAD passed.
+	dadConflictWindow = 10 * time.Second
+	// maxDADTransactions caps the DAD transactions kept.
+	maxDADTransactions = 256
+)
+
+// DAD outcomes.
+const (
+	DADPending   = "pending"   // probing, or waiting for a defending NA
+	DADPassed    = "passed"    // no NA within dadTimeout; the address is assumed in use by the requester
+	DADDuplicate = "duplicate" // another node defended the address
+	DADConflict  = "conflict"  // more than one MAC claimed the address; probably two nodes using it
+)
+
+// dadTransaction is one Duplicate Address Detection run for a tentative
+// address: the unspecified-source NS probes and the NA defending it, if any.
+type dadTransaction struct {
+	target    string
+	start     time.Time
+	lastProbe time.Time
+	probes    int
+	defender  string // source of the defending NA
+	defended  time.Time
+	// requesterMAC is the probes' Ethernet source, when the capture shows
+	// it (mirror mode); the probes themselves carry no link-layer address.
+	requesterMAC string
+	// advertMACs are the distinct MACs the NAs for target came from within
+	// dadConflictWindow, in order.
+	advertMACs []string
+}
+
+// contenders returns the distinct MACs that claimed t's address: the
+// requester's first when known, then the advertisers'.
+func (t *dadTransaction) contenders() []string {
+	var macs []string
+	if t.requesterMAC != "" {
+		macs = append(macs, t.requesterMAC)
+	}
+	for _, mac := range t.advertMACs {
+		if !slices.Contains(macs, mac) {
+			macs = append(macs, mac)
+		}
+	}
+	return macs
+}
+
+// DADTransaction is a DAD run as reported by GetDADTransactions.
+type DADTransaction struct {
+	Target string    `json:"target"` // tentative address
+	Start  time.Time `json:"start"`  // first probe
+	Probes int       `json:"probes"`
+	// Requester is the probes' Ethernet source in mirror mode, otherwise
+	// the link-layer address the tentative address was later seen with, if
+	// it was; DAD probes carry no Source Link-Layer Address.
+	Requester string `json:"requester,omitempty"`
+	Outcome   string `json:"outcome"`            // DADPending, DADPassed, DADDuplicate or DADConflict
+	Defender  string `json:"defender,omitempty"` // node that answered, for DADDuplicate and DADConflict
+	// MACs are the contending MACs, for DADConflict: the requester's first
+	// when known, then those of the NAs for the address.
+	MACs []string `json:"macs,omitempty"`
+	// Duration is from the first probe until the defending NA, until the
+	// requester could start using the address, or until now while pending.
+	Duration time.Duration `json:"duration"`
+}
+
+// RecordDADProbe notes a DAD probe (an NS from the unspecified address) for
+// target, sent from mac if the capture shows it ("" otherwise).
+func (s *NDPStats) RecordDADProbe(target, mac string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t := s.openDADLocked(target, now); t != nil {
+		t.lastProbe = now
+		t.probes++
+		if t.requesterMAC == "" {
+			t.requesterMAC = mac
+		}
+		return
+	}
+	s.dad = append(s.dad, &dadTransaction{target: target, start: now, lastProbe: now, probes: 1, requesterMAC: mac})
+	if len(s.dad) > maxDADTransactions {
+		s.dad = s.dad[len(s.dad)-maxDADTransactions:]
+	}
+}
+
+// RecordDADResponse checks whether an NA from ip for target answers a DAD
+// probe in progress and reports whether it did, i.e. whether the tentative
+// address turned out to be a duplicate. A defender answers at once, so NAs
+// later than dadRetransTimer after the last probe don't count: they are
+// usually the requester announcing the address it just acquired.
+func (s *NDPStats) RecordDADResponse(ip, target string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.openDADLocked(target, now)
+	if t == nil || now.Sub(t.lastProbe) >= dadRetransTimer {
+		return false
+	}
+	t.defender = ip
+	t.defended = now
+	return true
+}
+
+// RecordDADAdvert notes an NA from mac for target and, if it makes target
+// claimed by a second MAC around a DAD run (see DADConflict), returns that
+// run. It returns each conflict once, when its second MAC shows up. NAs
+// from virtual router MACs don't count.
+func (s *NDPStats) RecordDADAdvert(target, mac string, now time.Time) (DADTransaction, bool) {
+	if mac == "" || isVirtualMAC(mac) {
+		return DADTransaction{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var t *dadTransaction
+	for i := len(s.dad) - 1; i >= 0; i-- {
+		if s.dad[i].target == target {
+			t = s.dad[i]
+			break
+		}
+	}
+	if t == nil || now.Sub(t.lastProbe) >= dadConflictWindow || slices.Contains(t.advertMACs, mac) {
+		return DADTransaction{}, false
+	}
+	before := len(t.contenders())
+	t.advertMACs = append(t.advertMACs, mac)
+	if before >= 2 || len(t.contenders()) < 2 {
+		return DADTransaction{}, false
+	}
+	return s.dadTransactionLocked(t, now), true
+}
+
+// checkDADConflict raises dad_conflict when an NA from src at mac makes
+// target claimed by a second MAC around a DAD run (see RecordDADAdvert),
+// once per address per window. ND proxies answer for addresses that aren't
+// theirs, so their NAs don't count.
+func (l *NDPListener) checkDADConflict(src, target, mac string, now time.Time, port string) {
+	if l.cfg.Stats.IsNDProxy(src) {
+		return
+	}
+	d, ok := l.cfg.Stats.RecordDADAdvert(target, mac, now)
+	if !ok {
+		return
+	}
+	l.raiseAlertOnce("dad_conflict|"+target, Alert{
+		Severity: SeverityWarning,
+		Category: "dad_conflict",
+		Source:   target,
+		Message:  fmt.Sprintf("probable address conflict: %s claimed by %s", target, strings.Join(d.MACs, ", ")),
+		Port:     port,
+		DAD:      &d,
+	})
+}
+
+// openDADLocked returns the newest undecided transaction for target that is
+// still within dadTimeout of its last probe, or nil. Callers must hold s.mu.
+func (s *NDPStats) openDADLocked(target string, now time.Time) *dadTransaction {
+	for i := len(s.dad) - 1; i >= 0; i-- {
+		t := s.dad[i]
+		if t.target != target {
+			continue
+		}
+		if t.defender == "" && now.Sub(t.lastProbe) < dadTimeout {
+			return t
+		}
+		return nil
+	}
+	return nil
+}
+
+// GetDADTransactions returns recent DAD transactions, newest first.
+func (s *NDPStats) GetDADTransactions() []DADTransaction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dadLocked(s.now())
+}
+
+// dadLocked reports the DAD transactions, newest first. Callers must hold s.mu.
+func (s *NDPStats) dadLocked(now time.Time) []DADTransaction {
+	result := make([]DADTransaction, 0, len(s.dad))
+	for i := len(s.dad) - 1; i >= 0; i-- {
+		result = append(result, s.dadTransactionLocked(s.dad[i], now))
+	}
+	return result
+}
+
+// dadTransactionLocked reports t as of now. Callers must hold s.mu.
+func (s *NDPStats) dadTransactionLocked(t *dadTransaction, now time.Time) DADTransaction {
+	d := DADTransaction{Target: t.target, Start: t.start, Probes: t.probes, Requester: t.requesterMAC, Defender: t.defender}
+	switch {
+	case t.defender != "":
+		d.Outcome = DADDuplicate
+		d.Duration = t.defended.Sub(t.start)
+	case now.Sub(t.lastProbe) >= dadTimeout:
+		d.Outcome = DADPassed
+		d.Duration = t.lastProbe.Add(dadRetransTimer).Sub(t.start)
+		if peer, ok := s.peers[t.target]; ok && d.Requester == "" {
+			d.Requester = peer.MAC
+		}
+	default:
+		d.Outcome = DADPending
+		d.Duration = now.Sub(t.start)
+	}
+	if macs := t.contenders(); len(macs) >= 2 {
+		d.Outcome = DADConflict
+		d.MACs = macs
+	}
+	return d
+}
+
+// pruneDADLocked forgets transactions whose last activity is before cutoff.
+// Callers must hold s.mu.
+func (s *NDPStats) pruneDADLocked(cutoff time.Time) {
+	kept := s.dad[:0]
+	for _, t := range s.dad {
+		if t.lastProbe.After(cutoff) || t.defended.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	clear(s.dad[len(kept):])
+	s.dad = kept
+}
@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDADTransactions(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	start := time.Now().Add(-10 * time.Second)
+
+	// fe80::a probes twice and nobody answers; it then uses the address.
+	stats.RecordDADProbe("fe80::a", "", start)
+	stats.RecordDADProbe("fe80::a", "", start.Add(time.Second))
+	stats.RecordMAC("fe80::a", "02:00:00:00:00:0a")
+
+	// fe80::b is already taken by fe80::b's owner, which defends it at once.
+	stats.RecordDADProbe("fe80::b", "", start.Add(2*time.Second))
+	if !stats.RecordDADResponse("fe80::b", "fe80::b", start.Add(2*time.Second+5*time.Millisecond)) {
+		t.Error("defending NA not matched to the DAD probe")
+	}
+
+	// The requester's own announcement after DAD is not a defence.
+	stats.RecordDADProbe("fe80::c", "", start.Add(3*time.Second))
+	if stats.RecordDADResponse("fe80::c", "fe80::c", start.Add(4500*time.Millisecond)) {
+		t.Error("late NA treated as a defence")
+	}
+
+	// fe80::d is still probing.
+	stats.RecordDADProbe("fe80::d", "", time.Now())
+
+	got := stats.GetDADTransactions()
+	if len(got) != 4 {
+		t.Fatalf("got %d transactions, want 4: %+v", len(got), got)
+	}
+	want := []struct {
+		target, outcome string
+		probes          int
+	}{
+		{"fe80::d", DADPending, 1},
+		{"fe80::c", DADPassed, 1},
+		{"fe80::b", DADDuplicate, 1},
+		{"fe80::a", DADPassed, 2},
+	}
+	for i, w := range want {
+		if got[i].Target != w.target || got[i].Outcome != w.outcome || got[i].Probes != w.probes {
+			t.Errorf("transaction %d = %+v, want %s %s with %d probes", i, got[i], w.target, w.outcome, w.probes)
+		}
+	}
+	if got[3].Requester != "02:00:00:00:00:0a" || got[3].Duration != 2*time.Second {
+		t.Errorf("fe80::a requester %q duration %v, want 02:00:00:00:00:0a 2s", got[3].Requester, got[3].Duration)
+	}
+	if got[2].Defender != "fe80::b" || got[2].Duration != 5*time.Millisecond {
+		t.Errorf("fe80::b defender %q duration %v", got[2].Defender, got[2].Duration)
+	}
+}
+
+func TestHandle_DADDuplicateAlert(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	target := net.ParseIP("fe80::5")
+	l.handle(received{src: netip.MustParseAddr("::"), dst: netip.MustParseAddr("ff02::1:ff00:5"), payload: buildNS(target, nil)})
+	l.handle(received{src: netip.MustParseAddr("fe80::5"), dst: netip.MustParseAddr("ff02::1"), payload: buildNA(target, nil)})
+
+	if dad := stats.GetDADTransactions(); len(dad) != 1 || dad[0].Outcome != DADDuplicate {
+		t.Errorf("dad = %+v, want one duplicate", dad)
+	}
+	// DAD probes are not ordinary solicitations.
+	if n := len(stats.nsTargets); n != 0 {
+		t.Errorf("nsTargets has %d entries, want 0", n)
+	}
+	alerts := stats.GetAlerts()
+	if len(alerts) != 1 || alerts[0].Category != "dad_duplicate" {
+		t.Errorf("alerts = %+v, want one dad_duplicate", alerts)
+	}
+}
+
+func TestRecordDADAdvert_Conflict(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	start := time.Now().Add(-10 * time.Second)
+	a, b := "02:00:00:00:00:0a", "02:00:00:00:00:0b"
+
+	// The requester's own announcement after DAD is no conflict.
+	stats.RecordDADProbe("fe80::a", "", start)
+	if _, ok := stats.RecordDADAdvert("fe80::a", a, start.Add(1500*time.Millisecond)); ok {
+		t.Error("single MAC reported as a conflict")
+	}
+	// A second MAC advertising the address is, once.
+	d, ok := stats.RecordDADAdvert("fe80::a", b, start.Add(2*time.Second))
+	if !ok || d.Outcome != DADConflict || len(d.MACs) != 2 || d.MACs[0] != a || d.MACs[1] != b {
+		t.Fatalf("conflict = %+v %v, want both MACs", d, ok)
+	}
+	if _, ok := stats.RecordDADAdvert("fe80::a", b, start.Add(3*time.Second)); ok {
+		t.Error("conflict reported twice")
+	}
+
+	// Known from mirror mode, the requester's MAC counts as a claim.
+	stats.RecordDADProbe("fe80::b", a, start)
+	if d, ok := stats.RecordDADAdvert("fe80::b", b, start.Add(5*time.Millisecond)); !ok || d.Requester != a {
+		t.Errorf("defence from another MAC = %+v %v, want a conflict", d, ok)
+	}
+
+	// NAs long after the probes, and virtual router MACs, don't count.
+	stats.RecordDADProbe("fe80::c", "", start)
+	stats.RecordDADAdvert("fe80::c", a, start.Add(time.Second))
+	if _, ok := stats.RecordDADAdvert("fe80::c", b, start.Add(dadConflictWindow+time.Second)); ok {
+		t.Error("NA after the conflict window counted")
+	}
+	if _, ok := stats.RecordDADAdvert("fe80::c", "00:00:5e:00:02:01", start.Add(2*time.Second)); ok {
+		t.Error("virtual MAC counted")
+	}
+
+	got := stats.GetDADTransactions()
+	if len(got) != 3 || got[0].Outcome != DADPassed || got[1].Outcome != DADConflict || got[2].Outcome != DADConflict {
+		t.Errorf("transactions = %+v", got)
+	}
+}
+
+func TestHandle_DADConflictAlert(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	target := net.ParseIP("2001:db8::5")
+	l.handle(received{src: netip.MustParseAddr("::"), dst: netip.MustParseAddr("ff02::1:ff00:5"), payload: buildNS(target, nil)})
+	for _, mac := range []net.HardwareAddr{{0x02, 0, 0, 0, 0, 1}, {0x02, 0, 0, 0, 0, 2}} {
+		l.handle(received{src: netip.MustParseAddr("2001:db8::5"), dst: netip.MustParseAddr("ff02::1"), payload: buildNA(target, mac)})
+	}
+
+	var conflict *Alert
+	for _, a := range stats.GetAlerts() {
+		if a.Category == "dad_conflict" {
+			conflict = &a
+		}
+	}
+	if conflict == nil || conflict.DAD == nil || len(conflict.DAD.MACs) != 2 || !strings.Contains(conflict.Message, "02:00:00:00:00:02") {
+		t.Fatalf("alerts = %+v, want a dad_conflict with both MACs", stats.GetAlerts())
+	}
+	masked := (&PrivacyConfig{DropMACs: true}).Alert(*conflict)
+	if masked.DAD.MACs[0] != macPlaceholder || conflict.DAD.MACs[0] == macPlaceholder {
+		t.Errorf("masked MACs = %v, original %v", masked.DAD.MACs, conflict.DAD.MACs)
+	}
+}
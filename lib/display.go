@@ -1,14 +1,20 @@
 package lib
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
 )
 
 // Lipgloss styles
@@ -18,14 +24,48 @@ var (
 	inactiveTabStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
 	detailLabel      = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("4"))
 	footerStyle      = lipgloss.NewStyle().Faint(true)
+	staleStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	totalsStyle      = lipgloss.NewStyle().Bold(true)
+	warnStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+
+	toastStyles = map[Severity]lipgloss.Style{
+		SeverityWarning:  lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("0")).Background(lipgloss.Color("3")).Padding(0, 1),
+		SeverityCritical: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("15")).Background(lipgloss.Color("1")).Padding(0, 1),
+	}
 )
 
+// toastDurations is how long an alert stays on screen as a toast, by
+// severity. Info alerts get no toast.
+var toastDurations = map[Severity]time.Duration{
+	SeverityWarning:  5 * time.Second,
+	SeverityCritical: 10 * time.Second,
+}
+
+// maxToasts caps the toasts on screen; newer ones push out older ones.
+const maxToasts = 3
+
+// toast is an alert shown over the current view until it expires.
+type toast struct {
+	alert Alert
+	until time.Time
+}
+
 // Tab indices
 const (
 	tabPeers   = 0
 	tabRouters = 1
+	tabDAD     = 2
+	tabSizes   = 3
+	tabRules   = 4
+	tabGraph   = 5
+	tabStatus  = 6
+	tabAlerts  = 7
+	tabCompare = 8 // only with ModelConfig.CompareStats
 )
 
+// Tab bar labels, indexed by tab constant
+var tabNames = []string{"NDP/MLD Peers", "Routers", "DAD", "Sizes", "Rules", "Graph", "Status", "Alerts", "Compare"}
+
 // Message type short names for table columns
 var msgShortNames = map[string]string{
 	"router_solicitation":            "RS",
@@ -54,6 +94,21 @@ var msgColumnOrder = []string{
 	"mld_done",
 }
 
+// quickFilterKeys maps number keys to message types, in column order:
+// 1=RS, 2=RA, 3=NS ... 9=MR, 0=MD.
+var quickFilterKeys = map[string]string{
+	"1": "router_solicitation",
+	"2": "router_advertisement",
+	"3": "neighbor_solicitation",
+	"4": "neighbor_advertisement",
+	"5": "redirect",
+	"6": "duplicate_address_request",
+	"7": "duplicate_address_confirmation",
+	"8": "mld_query",
+	"9": "mld_report",
+	"0": "mld_done",
+}
+
 // Well-known IPv6 multicast groups and what they indicate
 var knownMulticastGroups = map[string]string{
 	"ff02::1":            "All Nodes",
@@ -84,54 +139,361 @@ func tickCmd(d time.Duration) tea.Cmd {
 	})
 }
 
+// snapshotSavedMsg reports the outcome of a background snapshot write.
+type snapshotSavedMsg struct {
+	path string
+	err  error
+}
+
+// ringSavedMsg reports the outcome of a background packet ring dump.
+type ringSavedMsg struct {
+	path string
+	err  error
+}
+
+// graphSavedMsg reports the outcome of a background graph export.
+type graphSavedMsg struct {
+	path string
+	err  error
+}
+
+// macLocatedMsg carries the switch ports a MAC was found on.
+type macLocatedMsg struct {
+	mac       string
+	locations []MACLocation
+	err       error
+}
+
+// macLookup is the state of one MAC's switch port lookup.
+type macLookup struct {
+	pending   bool
+	locations []MACLocation
+	err       error
+}
+
+// historySampleMsg delivers a sample loaded for time travel. end is set
+// when there is no sample in the requested direction.
+type historySampleMsg struct {
+	sample HistorySample
+	end    bool
+	err    error
+}
+
+// timelineMsg delivers the timeline loaded around a time travel sample.
+type timelineMsg struct {
+	timeline Timeline
+	err      error
+}
+
+// statusDuration is how long a footer status message stays visible.
+const statusDuration = 5 * time.Second
+
+// ModelConfig configures the TUI model.
+type ModelConfig struct {
+	Stats       *NDPStats     // required
+	Window      time.Duration // sliding window shown in the header
+	Refresh     time.Duration // table refresh interval
+	SnapshotDir string        // directory for freeze snapshots (default ".")
+	// Instance names the files the TUI writes (see InstanceName).
+	Instance   string
+	SortByIdle bool // start with the most recently active peers on top
+	// MulticastLabels are site-specific group labels from the config file.
+	MulticastLabels []MulticastGroupLabel
+	// CompareStats, when set, adds a Compare tab listing the peers and
+	// routers seen by only one of Stats and CompareStats.
+	CompareStats *NDPStats
+	// CompareLabels name the Stats and CompareStats inputs (default "A", "B").
+	CompareLabels [2]string
+	// History, when set, enables time travel ('t') through recorded samples.
+	History *HistoryDB
+	// Ring, when set, enables writing recent raw packets to a pcap ('w').
+	Ring *PacketRing
+	// Rules, when set, fills the Rules tab and receives toast
+	// acknowledgements for its alerts.
+	Rules *RuleEngine
+	// Locator, when set, looks up the switch port of the MAC shown in the
+	// peer detail view.
+	Locator *MACLocator
+	// UIState, when set, restores the view state of an earlier run (see
+	// LoadUIState). SortByIdle still applies on top of it.
+	UIState *UIState
+	// Keys remaps keys (see NewKeymap); nil keeps the defaults.
+	Keys Keymap
+	// Actions are the external commands 'x' offers for the selected peer.
+	Actions []*PeerAction
+	// Federation lists the other instances 'p' can switch to, or nil.
+	Federation *Federation
+	// Anonymizer, when set, anonymizes the snapshots and graphs written
+	// with 'f' and 'e'.
+	Anonymizer *Anonymizer
+	// Privacy, when set, drops identifiers from the snapshots written with 'f'.
+	Privacy *PrivacyConfig
+	// Hostnames adds a Hostname column to the peer table (--resolve).
+	Hostnames bool
+}
+
 // Model is the Bubble Tea model for the NDPeekr TUI.
 type Model struct {
-	stats   *NDPStats
-	window  time.Duration
-	refresh time.Duration
+	stats       *NDPStats
+	window      time.Duration
+	refresh     time.Duration
+	snapshotDir string
+	instance    string
+	anon        *Anonymizer
+	privacy     *PrivacyConfig
+	hostnames   bool // the peer table has a Hostname column
+
+	// multicastLabels extend knownMulticastGroups
+	multicastLabels []MulticastGroupLabel
+
+	// compareStats is the second input in comparison mode, or nil
+	compareStats  *NDPStats
+	compareLabels [2]string
+	comparison    Comparison
+
+	// Time travel: while travelling, the peer and router tables show the
+	// history sample taken at travelAt instead of live stats.
+	history    *HistoryDB
+	travelling bool
+	travelAt   time.Time
+	// timeline is the traffic and alerts around travelAt, or nil
+	timeline *Timeline
+
+	// ring holds recent raw packets for 'w', or nil
+	ring *PacketRing
+
+	// keys translates remapped keys into the defaults handleKey expects
+	keys Keymap
+
+	// Peer actions: the "actions" view is the menu for actionPeer, the
+	// "output" view shows actionRun in actionPane. Esc returns to
+	// actionReturn, the view the menu was opened from.
+	actions      []*PeerAction
+	actionPeer   *PeerSummary
+	actionRun    *ActionRun
+	actionPane   viewport.Model
+	actionReturn string
+
+	// Segments: the "segments" view picks from segments, the instances
+	// federation knows. While segment is set, the peer and router tables
+	// show that instance's data instead of live stats.
+	federation    *Federation
+	segments      []Instance
+	segmentCursor int
+	segment       *Instance
+
+	// Rules tab: ruleEngine is nil without a rules section. editingRule
+	// names the rule whose match expression ruleInput is editing.
+	ruleEngine  *RuleEngine
+	rules       []RuleStatus
+	ruleTable   table.Model
+	ruleInput   textinput.Model
+	editingRule string
 
 	// View state
-	activeTab  int    // tabPeers or tabRouters
-	activeView string // "table" or "detail"
+	activeTab  int    // one of the tab* constants
+	activeView string // "table", "detail", "story", "batch", "actions", "output" or "segments"
 
 	// Tables
 	peerTable   table.Model
 	routerTable table.Model
+	goneTable   table.Model
+	dadTable    table.Model
+	alertTable  table.Model
+
+	// sortByIdle orders peers by time since last activity instead of total count.
+	sortByIdle bool
+
+	// quickFilters holds the message types toggled with the number keys.
+	// When non-empty, the peer table only shows peers active in any of them.
+	quickFilters map[string]bool
+
+	// Filter bar ("/"): filter is the applied expression, filtering is true
+	// while the input has focus.
+	filterInput textinput.Model
+	filter      *Filter
+	filtering   bool
+
+	// Marked peers, by the address shown in the table, for batch actions;
+	// tagging is true while tagInput takes the tag for them.
+	marked   map[string]bool
+	tagInput textinput.Model
+	tagging  bool
+
+	// showGone switches the Routers tab to previously seen routers
+	showGone bool
 
 	// Detail view
-	selectedPeer   *PeerSummary
+	selectedPeer *PeerSummary
+	// locator finds switch ports for MACs; macLookups holds its answers by MAC
+	locator    *MACLocator
+	macLookups map[string]macLookup
+	// mergeLinks shows one row per address across links (see MergePeers)
+	mergeLinks     bool
 	selectedRouter *RouterInfo
+	selectedGoneAt time.Time // zero unless selectedRouter came from the history
+
+	// Story view: story is the host being investigated, storyErr the error
+	// reading the kernel neighbor cache, storyFrom the view Esc returns to.
+	story     *PeerStory
+	storyErr  error
+	storyFrom string
 
 	// Data snapshots
 	peers   []PeerSummary
 	routers []RouterInfo
+	gone    []GoneRouter
+	dad     []DADTransaction
+	alerts  []Alert // newest first, for the Alerts tab
+	sizes   map[string]SizeHistogram
+	// options is the NDP option usage matrix, for the Sizes tab
+	options []OptionUsage
+	// nud is each host's NUD behavior, by address
+	nud map[string]NUDBehavior
+	// graph is who solicited whom, for the Graph tab
+	graph SolicitGraph
+	// about is how this instance runs, for the Status tab
+	about Status
+	// extAnomalies counts unexpected extension header chains by reason
+	extAnomalies map[string]int
+	// routerAlert counts MLD Router Alert violations by reason
+	routerAlert map[string]int
+	// checksumFailures counts messages dropped for a bad ICMPv6 checksum
+	checksumFailures int
+	// duplicates counts duplicated packets per capture interface
+	duplicates []InterfaceDuplicates
+	// sampling is the capture's adaptive sampling state
+	sampling SamplingStats
+	// fastPath is the capture's fast path state, for the Status tab
+	fastPath FastPathStats
+	// raGuard is the RA guard canary state, for the Status tab
+	raGuard RAGuardStats
+	// rogueRA is the rogue RA detection state, for the Status tab
+	rogueRA RogueRAStats
+	// dedup is the state of duplicate dropping, for the Sizes tab
+	dedup DedupStats
+	// mldSnoop is the MLD snooping verification state, for the Status tab
+	mldSnoop MLDSnoopStats
+	// mtu is what Packet Too Big messages have shown, for the Status tab
+	mtu MTUStats
+	// interfaces summarises each capture interface, for the Status tab
+	interfaces []InterfaceSummary
+	// maintenance names the maintenance windows currently open
+	maintenance []string
+	// mldLatency is the MLD query response latency, keyed by group
+	mldLatency map[string]MLDGroupLatency
+	// mcastSanity holds Done-without-Join and silent multicast groups
+	mcastSanity MulticastSanity
+	// virtualRouters are the VRRP/HSRP groups seen in adverts
+	virtualRouters []VirtualRouter
+	// rsLatency is how quickly routers answered our RSs, keyed by router
+	rsLatency map[string]RouterRSLatency
+	// reachability is how routers answered our NS probes, keyed by router
+	reachability map[string]RouterReachability
+	// switchNeighbors is the LLDP/CDP switch port per interface, keyed by interface
+	switchNeighbors map[string]SwitchNeighbor
+	// health is the IPv6 health score per segment.
+	health []SegmentHealth
+
+	// Footer status line (e.g. snapshot saved) and when it expires
+	status      string
+	statusUntil time.Time
+
+	// toasts are recent warning and critical alerts shown over the view;
+	// toastSeq is the alert sequence number already turned into toasts
+	toasts   []toast
+	toastSeq uint64
+	// width is the terminal width, 0 until the first resize
+	width int
 
 	quitting bool
 }
 
 // NewModel creates a new Bubble Tea model for the NDPeekr TUI.
-func NewModel(stats *NDPStats, window, refresh time.Duration) Model {
+func NewModel(cfg ModelConfig) Model {
+	if cfg.SnapshotDir == "" {
+		cfg.SnapshotDir = "."
+	}
+	stats := cfg.Stats
 	m := Model{
-		stats:      stats,
-		window:     window,
-		refresh:    refresh,
-		activeTab:  tabPeers,
-		activeView: "table",
+		stats:       stats,
+		window:      cfg.Window,
+		refresh:     cfg.Refresh,
+		snapshotDir: cfg.SnapshotDir,
+		instance:    cfg.Instance,
+		anon:        cfg.Anonymizer,
+		privacy:     cfg.Privacy,
+		hostnames:   cfg.Hostnames,
+		activeTab:   tabPeers,
+
+		multicastLabels: cfg.MulticastLabels,
+		activeView:      "table",
+
+		compareStats:  cfg.CompareStats,
+		compareLabels: cfg.CompareLabels,
+		history:       cfg.History,
+		ring:          cfg.Ring,
+		keys:          cfg.Keys,
+		actions:       cfg.Actions,
+		federation:    cfg.Federation,
+		ruleEngine:    cfg.Rules,
+		locator:       cfg.Locator,
+		macLookups:    make(map[string]macLookup),
+
+		sortByIdle:   cfg.SortByIdle,
+		quickFilters: make(map[string]bool),
+		marked:       make(map[string]bool),
 	}
 
-	m.peerTable = newPeerTable()
+	m.peerTable = newPeerTable(cfg.Hostnames)
 	m.routerTable = newRouterTable()
 	m.routerTable.Blur()
+	m.filterInput = textinput.New()
+	m.filterInput.Prompt = "/ "
+	m.filterInput.Placeholder = `iface == "eth0" && counts.ra > 0`
+	m.tagInput = textinput.New()
+	m.tagInput.Prompt = "tag: "
+	m.goneTable = newGoneRouterTable()
+	m.goneTable.Blur()
+	m.dadTable = newDADTable()
+	m.alertTable = newAlertTable()
+	m.ruleTable = newRuleTable()
+	m.ruleInput = textinput.New()
+	m.ruleInput.Prompt = "match: "
+	m.actionPane = newActionPane()
+	if cfg.UIState != nil {
+		m.applyUIState(*cfg.UIState)
+	}
 
 	// Load initial data
-	m.peers = stats.GetStats()
-	m.peerTable.SetRows(peerRows(m.peers))
-	m.routers = stats.GetRouters()
-	m.routerTable.SetRows(routerRows(m.routers))
+	snap := stats.Shared()
+	m.loadPeersAndRouters(snap)
+	m.loadShared(snap)
+	m.refreshRules()
+	m.about = stats.Status()
+	m.maintenance = stats.ActiveMaintenance(time.Now())
+	_, m.toastSeq = stats.AlertsSince(0)
+	if m.compareLabels[0] == "" {
+		m.compareLabels[0] = "A"
+	}
+	if m.compareLabels[1] == "" {
+		m.compareLabels[1] = "B"
+	}
+	if m.compareStats != nil {
+		m.comparison = Compare(stats, m.compareStats)
+	}
 
 	return m
 }
 
+// tabCount is the number of tabs shown; Compare only exists in comparison mode.
+func (m Model) tabCount() int {
+	if m.compareStats != nil {
+		return len(tabNames)
+	}
+	return tabCompare
+}
+
 // Init starts the tick cycle.
 func (m Model) Init() tea.Cmd {
 	return tickCmd(m.refresh)
@@ -148,15 +510,115 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.peerTable.SetHeight(tableHeight)
 		m.routerTable.SetHeight(tableHeight)
+		m.goneTable.SetHeight(tableHeight)
+		m.dadTable.SetHeight(tableHeight)
+		m.alertTable.SetHeight(tableHeight)
+		m.ruleTable.SetHeight(tableHeight)
+		m.actionPane.Width = msg.Width
+		m.actionPane.Height = tableHeight
+		m.width = msg.Width
 		return m, nil
 
 	case tickMsg:
-		m.peers = m.stats.GetStats()
-		m.stats.Prune()
-		m.peerTable.SetRows(peerRows(m.peers))
-		m.routers = m.stats.GetRouters()
-		m.routerTable.SetRows(routerRows(m.routers))
-		return m, tickCmd(m.refresh)
+		var fetch tea.Cmd
+		snap := m.stats.Shared()
+		if m.segment != nil {
+			fetch = m.fetchSegment()
+		} else if !m.travelling {
+			m.loadPeersAndRouters(snap)
+		}
+		m.loadShared(snap)
+		m.about = m.stats.Status()
+		m.maintenance = m.stats.ActiveMaintenance(time.Now())
+		m.updateToasts(time.Now())
+		m.refreshRules()
+		if m.activeView == "story" {
+			m.refreshStory()
+		}
+		if m.compareStats != nil {
+			m.comparison = Compare(m.stats, m.compareStats)
+		}
+		return m, tea.Batch(tickCmd(m.refresh), fetch)
+
+	case historySampleMsg:
+		if !m.travelling {
+			return m, nil // returned to live while loading
+		}
+		switch {
+		case msg.err != nil:
+			m.setStatus("History: " + msg.err.Error())
+		case msg.end && m.travelAt.IsZero():
+			m.travelling = false
+			m.setStatus("No history recorded yet")
+		case msg.end:
+			m.setStatus("No more samples; t returns to live")
+		default:
+			m.travelAt = msg.sample.Taken
+			m.peers = msg.sample.Peers
+			m.setPeerRows()
+			m.routers = msg.sample.Routers
+			m.routerTable.SetRows(routerRows(m.routers, nil))
+			return m, m.loadTimeline()
+		}
+		return m, nil
+
+	case timelineMsg:
+		if !m.travelling {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.setStatus("Timeline: " + msg.err.Error())
+			return m, nil
+		}
+		m.timeline = &msg.timeline
+		return m, nil
+
+	case peersSavedMsg:
+		if msg.err != nil {
+			m.setStatus("Export failed: " + msg.err.Error())
+		} else {
+			m.setStatus(fmt.Sprintf("%d peers saved: %s", msg.n, msg.path))
+		}
+		return m, nil
+
+	case snapshotSavedMsg:
+		if msg.err != nil {
+			m.setStatus("Snapshot failed: " + msg.err.Error())
+		} else {
+			m.setStatus("Snapshot saved: " + msg.path)
+		}
+		return m, nil
+
+	case ringSavedMsg:
+		if msg.err != nil {
+			m.setStatus("Packet dump failed: " + msg.err.Error())
+		} else {
+			m.setStatus("Packets saved: " + msg.path)
+		}
+		return m, nil
+
+	case graphSavedMsg:
+		if msg.err != nil {
+			m.setStatus("Graph export failed: " + msg.err.Error())
+		} else {
+			m.setStatus("Graph saved: " + msg.path + " (and .graphml)")
+		}
+		return m, nil
+
+	case actionOutputMsg:
+		return m, m.updateActionOutput(msg)
+
+	case segmentDataMsg:
+		m.applySegmentData(msg)
+		return m, nil
+
+	case macLocatedMsg:
+		m.macLookups[msg.mac] = macLookup{locations: msg.locations, err: msg.err}
+		return m, nil
+
+	case storyNeighborsMsg:
+		m.applyStoryNeighbors(msg)
+		return m, nil
 
 	case tea.KeyMsg:
 		return m.handleKey(msg)
@@ -174,9 +636,85 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, tea.Quit
 	}
 
-	// Detail view: only Esc and q are handled
+	// The filter bar takes all keys while it is open
+	if m.filtering {
+		return m.handleFilterKey(msg)
+	}
+	if m.editingRule != "" {
+		return m.handleRuleEditKey(msg)
+	}
+	if m.tagging {
+		return m.handleTagKey(msg)
+	}
+
+	// Translate remapped keys into the defaults handled below
+	if to := m.keys.translate(key); to != key {
+		if to == "" {
+			return m, nil
+		}
+		key, msg = to, keyMsg(to)
+	}
+
+	// Acknowledge the newest toast from any view
+	if key == "a" && len(m.toasts) > 0 {
+		m.ackToast()
+		return m, nil
+	}
+
+	// Freeze a snapshot from any view
+	if key == "f" {
+		return m, m.freezeSnapshot()
+	}
+
+	// Dump the packet ring from any view
+	if key == "w" && m.ring != nil {
+		return m, m.dumpRing()
+	}
+
+	// Switch segments from any view
+	if key == "p" && m.activeView != "segments" {
+		m.openSegments()
+		return m, nil
+	}
+	if m.activeView == "segments" {
+		return m.handleSegmentKey(key)
+	}
+
+	if m.activeView == "batch" {
+		return m.handleBatchKey(key)
+	}
+
+	// The action menu and output pane take the remaining keys
+	if m.activeView == "actions" || m.activeView == "output" {
+		return m.handleActionKey(key, msg)
+	}
+
+	// Story view: Esc returns to where it was opened from
+	if m.activeView == "story" {
+		switch key {
+		case "x":
+			m.openActions(m.selectedPeer)
+		case "esc":
+			m.activeView = m.storyFrom
+			m.story = nil
+		case "q":
+			m.quitting = true
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	// Detail view: only Esc, x, i and q are handled
 	if m.activeView == "detail" {
 		switch key {
+		case "x":
+			if m.activeTab == tabPeers {
+				m.openActions(m.selectedPeer)
+			}
+		case "i":
+			if m.activeTab == tabPeers {
+				return m, m.openStory(m.selectedPeer)
+			}
 		case "esc":
 			m.activeView = "table"
 		case "q":
@@ -186,6 +724,12 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	if m.activeTab == tabRules && m.ruleEngine != nil {
+		if cmd, ok := m.handleRuleKey(key); ok {
+			return m, cmd
+		}
+	}
+
 	// Table view key handling
 	switch key {
 	case "q":
@@ -193,23 +737,146 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, tea.Quit
 
 	case "tab":
-		m.switchTab((m.activeTab + 1) % 2)
+		m.switchTab((m.activeTab + 1) % m.tabCount())
 
 	case "shift+tab":
-		m.switchTab((m.activeTab + 1) % 2)
+		m.switchTab((m.activeTab + m.tabCount() - 1) % m.tabCount())
+
+	case "s":
+		if m.activeTab == tabPeers {
+			m.sortByIdle = !m.sortByIdle
+			m.setPeerRows()
+		}
+
+	case "D":
+		if m.activeTab == tabSizes {
+			m.dedup.Enabled = !m.dedup.Enabled
+			m.stats.SetDedup(m.dedup.Enabled)
+			if m.dedup.Enabled {
+				m.setStatus("Dropping duplicate packets before recording")
+			} else {
+				m.setStatus("Recording duplicate packets")
+			}
+		}
+
+	case "m":
+		if m.activeTab == tabPeers {
+			m.mergeLinks = !m.mergeLinks
+			m.setPeerRows()
+			if m.mergeLinks {
+				m.setStatus("Peers merged across links")
+			} else {
+				m.setStatus("Peers shown per link")
+			}
+		}
+
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9", "0":
+		if m.activeTab == tabPeers {
+			kind := quickFilterKeys[key]
+			if m.quickFilters[kind] {
+				delete(m.quickFilters, kind)
+			} else {
+				m.quickFilters[kind] = true
+			}
+			m.setPeerRows()
+		}
+
+	case "/":
+		if m.activeTab == tabPeers {
+			m.filtering = true
+			m.filterInput.SetValue(m.filter.String())
+			m.filterInput.CursorEnd()
+			return m, m.filterInput.Focus()
+		}
+
+	case "esc":
+		if m.activeTab == tabPeers && (len(m.quickFilters) > 0 || m.filter != nil) {
+			clear(m.quickFilters)
+			m.filter = nil
+			m.setPeerRows()
+		} else if m.activeTab == tabPeers && len(m.marked) > 0 {
+			clear(m.marked)
+			m.setPeerRows()
+		}
+
+	case " ":
+		if m.activeTab == tabPeers {
+			m.toggleMark()
+		}
+
+	case "ctrl+a":
+		if m.activeTab == tabPeers {
+			m.toggleMarkAll()
+		}
+
+	case "t":
+		if m.segment != nil {
+			m.setStatus("Time travel only covers this instance; p switches back")
+			return m, nil
+		}
+		if m.history == nil {
+			m.setStatus("Time travel needs a history database (history.path in the config)")
+			return m, nil
+		}
+		if m.travelling {
+			m.travelling = false
+			m.travelAt = time.Time{}
+			m.timeline = nil
+			m.loadLive()
+			return m, nil
+		}
+		m.travelling = true
+		return m, m.scrub(m.history.PrevSample, time.Now())
+
+	case "left", "right":
+		if m.travelling && !m.travelAt.IsZero() {
+			step := m.history.PrevSample
+			if key == "right" {
+				step = m.history.NextSample
+			}
+			return m, m.scrub(step, m.travelAt)
+		}
+
+	case "e":
+		if m.activeTab == tabGraph {
+			return m, m.exportGraph()
+		}
+
+	case "h":
+		if m.activeTab == tabRouters {
+			m.showGone = !m.showGone
+			m.switchTab(tabRouters)
+		}
+
+	case "x":
+		if m.activeTab == tabPeers && len(m.marked) > 0 {
+			m.openBatch()
+		} else if m.activeTab == tabPeers {
+			m.openActions(m.cursorPeer())
+		}
+
+	case "i":
+		if m.activeTab == tabPeers {
+			if p := m.cursorPeer(); p != nil {
+				m.selectedPeer = p
+				return m, m.openStory(p)
+			}
+		}
 
 	case "enter":
 		if m.activeTab == tabPeers {
-			row := m.peerTable.SelectedRow()
-			if row != nil {
-				addr := row[0]
-				for i := range m.peers {
-					if m.peers[i].Address == addr {
-						m.selectedPeer = &m.peers[i]
-						m.activeView = "detail"
-						break
-					}
-				}
+			if p := m.cursorPeer(); p != nil {
+				m.selectedPeer = p
+				m.activeView = "detail"
+				return m, m.locateMAC(p.MAC)
+			}
+		} else if m.activeTab == tabRouters && m.showGone {
+			// History rows are not unique by address, so select by index
+			i := m.goneTable.Cursor()
+			if i >= 0 && i < len(m.gone) {
+				m.selectedRouter = &m.gone[i].RouterInfo
+				m.selectedGoneAt = m.gone[i].GoneAt
+				m.activeView = "detail"
 			}
 		} else if m.activeTab == tabRouters {
 			row := m.routerTable.SelectedRow()
@@ -218,6 +885,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				for i := range m.routers {
 					if m.routers[i].Address == addr {
 						m.selectedRouter = &m.routers[i]
+						m.selectedGoneAt = time.Time{}
 						m.activeView = "detail"
 						break
 					}
@@ -229,10 +897,21 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	default:
 		// Delegate navigation keys to the active table
 		var cmd tea.Cmd
-		if m.activeTab == tabPeers {
+		switch m.activeTab {
+		case tabPeers:
 			m.peerTable, cmd = m.peerTable.Update(msg)
-		} else {
-			m.routerTable, cmd = m.routerTable.Update(msg)
+		case tabRouters:
+			if m.showGone {
+				m.goneTable, cmd = m.goneTable.Update(msg)
+			} else {
+				m.routerTable, cmd = m.routerTable.Update(msg)
+			}
+		case tabDAD:
+			m.dadTable, cmd = m.dadTable.Update(msg)
+		case tabAlerts:
+			m.alertTable, cmd = m.alertTable.Update(msg)
+		case tabRules:
+			m.ruleTable, cmd = m.ruleTable.Update(msg)
 		}
 		return m, cmd
 	}
@@ -240,14 +919,321 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updateToasts turns new warning and critical alerts into toasts and drops
+// the ones that have expired.
+func (m *Model) updateToasts(now time.Time) {
+	alerts, seq := m.stats.AlertsSince(m.toastSeq)
+	m.toastSeq = seq
+	for _, a := range alerts {
+		if d, ok := toastDurations[a.Severity]; ok {
+			m.toasts = append(m.toasts, toast{alert: a, until: now.Add(d)})
+		}
+	}
+	m.toasts = slices.DeleteFunc(m.toasts, func(t toast) bool {
+		if now.Before(t.until) {
+			return false
+		}
+		m.toastFeedback(t.alert, false)
+		return true
+	})
+	if len(m.toasts) > maxToasts {
+		for _, t := range m.toasts[:len(m.toasts)-maxToasts] {
+			m.toastFeedback(t.alert, false)
+		}
+		m.toasts = m.toasts[len(m.toasts)-maxToasts:]
+	}
+}
+
+// ackToast dismisses the newest toast and counts it as acknowledged.
+func (m *Model) ackToast() {
+	t := m.toasts[len(m.toasts)-1]
+	m.toasts = m.toasts[:len(m.toasts)-1]
+	m.toastFeedback(t.alert, true)
+	m.refreshRules()
+}
+
+// toastFeedback tells the rule engine whether a toast was acknowledged or
+// left to expire, for the Rules tab's tuning statistics.
+func (m *Model) toastFeedback(a Alert, acked bool) {
+	if m.ruleEngine != nil {
+		m.ruleEngine.RecordFeedback(a.Category, acked)
+	}
+}
+
+// renderToasts renders one line per toast, newest first.
+func (m Model) renderToasts() []string {
+	lines := make([]string, 0, len(m.toasts))
+	for i := len(m.toasts) - 1; i >= 0; i-- {
+		a := m.toasts[i].alert
+		text := fmt.Sprintf("%s %s: %s", strings.ToUpper(a.Severity.String()), a.Category, a.Message)
+		if a.Port != "" {
+			text += " (port " + a.Port + ")"
+		}
+		if m.width > 4 {
+			text = truncate(text, m.width-4)
+		}
+		lines = append(lines, toastStyles[a.Severity].Render(text))
+	}
+	return lines
+}
+
+// overlayLines replaces the first lines of body with over, so toasts cover
+// the top of the current view instead of pushing it down.
+func overlayLines(body string, over []string) string {
+	if len(over) == 0 {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	for i, line := range over {
+		if i < len(lines) {
+			lines[i] = line
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loadLive refreshes the peer and router tables from live stats.
+func (m *Model) loadLive() {
+	m.loadPeersAndRouters(m.stats.Shared())
+}
+
+// loadPeersAndRouters fills the peer and router tables from snap.
+func (m *Model) loadPeersAndRouters(snap *Snapshot) {
+	m.peers = snap.Peers
+	m.setPeerRows()
+	m.routers = snap.Routers
+	m.reachability = reachabilityByRouter(snap.Reachability)
+	m.routerTable.SetRows(routerRows(m.routers, m.reachability))
+}
+
+// loadShared refreshes every view but the peer and router tables from the
+// shared snapshot. The views only read what they are given.
+func (m *Model) loadShared(snap *Snapshot) {
+	m.gone = snap.Gone
+	m.goneTable.SetRows(goneRouterRows(m.gone))
+	m.dad = snap.DAD
+	m.dadTable.SetRows(dadRows(m.dad))
+	m.alerts = snap.Alerts
+	m.alertTable.SetRows(alertRows(m.alerts))
+	m.sizes = snap.Sizes
+	m.options = snap.OptionUsage
+	m.nud = nudByHost(snap.NUD)
+	m.graph = snap.Graph()
+	m.extAnomalies = snap.ExtHeaders
+	m.routerAlert = snap.RouterAlert
+	m.checksumFailures = snap.ChecksumFailures
+	m.duplicates = snap.Duplicates
+	m.sampling = snap.Sampling
+	m.fastPath = snap.FastPath
+	m.raGuard = snap.RAGuard
+	m.rogueRA = snap.RogueRA
+	m.dedup = snap.Dedup
+	m.mldSnoop = snap.MLDSnoop
+	m.mtu = snap.MTU
+	m.interfaces = SummarizeInterfaces(snap.Peers, snap.Routers)
+	m.mldLatency = mldLatencyByGroup(snap.MLDLatency)
+	m.mcastSanity = snap.Multicast
+	m.virtualRouters = snap.VirtualRouters
+	m.rsLatency = rsLatencyByRouter(snap.RSLatency)
+	m.switchNeighbors = switchNeighborsByInterface(snap.SwitchNeighbors)
+	m.health = snap.IPv6Health
+}
+
+// scrub returns a command that loads the history sample step finds from t.
+func (m Model) scrub(step func(time.Time) (time.Time, bool, error), t time.Time) tea.Cmd {
+	db := m.history
+	return func() tea.Msg {
+		at, ok, err := step(t)
+		if err != nil || !ok {
+			return historySampleMsg{end: !ok, err: err}
+		}
+		sample, err := db.LoadSample(at)
+		return historySampleMsg{sample: sample, err: err}
+	}
+}
+
+// loadTimeline returns a command that loads the timeline around travelAt,
+// one bucket per column.
+func (m Model) loadTimeline() tea.Cmd {
+	db, at, width := m.history, m.travelAt, m.width
+	if width <= 0 {
+		width = 80
+	}
+	return func() tea.Msg {
+		from, to := timelineRange(at, time.Now())
+		tl, err := db.Timeline(from, to, width)
+		return timelineMsg{timeline: tl, err: err}
+	}
+}
+
+// freezeSnapshot copies the current stats immediately and returns a command
+// that writes the copy to disk in the background, so capture and rendering
+// continue while the file is written.
+func (m Model) freezeSnapshot() tea.Cmd {
+	snap := m.privacy.Snapshot(m.stats.Snapshot())
+	dir, instance, anon := m.snapshotDir, m.instance, m.anon
+	return func() tea.Msg {
+		path, err := WriteSnapshotFile(snap, dir, instance, anon)
+		return snapshotSavedMsg{path: path, err: err}
+	}
+}
+
+// exportGraph returns a command that writes the solicitation graph as DOT
+// and GraphML to the snapshot directory.
+func (m Model) exportGraph() tea.Cmd {
+	g, dir, instance, anon := m.graph, m.snapshotDir, m.instance, m.anon
+	return func() tea.Msg {
+		path, err := WriteGraphFiles(anon.Graph(g), dir, instance, time.Now())
+		return graphSavedMsg{path: path, err: err}
+	}
+}
+
+// dumpRing returns a command that writes the packet ring to a pcap file in
+// the snapshot directory.
+func (m Model) dumpRing() tea.Cmd {
+	ring, dir, instance := m.ring, m.snapshotDir, m.instance
+	return func() tea.Msg {
+		path, err := ring.WritePcapFile(dir, instance, time.Now())
+		return ringSavedMsg{path: path, err: err}
+	}
+}
+
+// locateMAC returns a command that asks the switches where mac is, or nil
+// without a locator or when a lookup is already under way. The locator
+// caches answers, so reopening a peer is cheap.
+func (m Model) locateMAC(mac string) tea.Cmd {
+	if m.locator == nil || mac == "" || m.macLookups[mac].pending {
+		return nil
+	}
+	prev := m.macLookups[mac]
+	prev.pending = true
+	m.macLookups[mac] = prev
+	locator := m.locator
+	return func() tea.Msg {
+		locations, err := locator.Locate(context.Background(), mac)
+		return macLocatedMsg{mac: mac, locations: locations, err: err}
+	}
+}
+
+// handleFilterKey edits the filter bar. Enter applies the expression (an
+// empty one clears the filter), Esc closes the bar without changes.
+func (m Model) handleFilterKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.filtering = false
+		m.filterInput.Blur()
+		return m, nil
+	case "enter":
+		expr := strings.TrimSpace(m.filterInput.Value())
+		if expr == "" {
+			m.filter = nil
+		} else {
+			f, err := ParseFilter(expr)
+			if err != nil {
+				m.setStatus(err.Error())
+				return m, nil
+			}
+			m.filter = f
+		}
+		m.filtering = false
+		m.filterInput.Blur()
+		m.setPeerRows()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.filterInput, cmd = m.filterInput.Update(msg)
+	return m, cmd
+}
+
+// visiblePeers applies the link merge, filter expression, quick filters and
+// sort order to m.peers. m.peers arrives sorted by total; the idle sort
+// reorders a copy.
+func (m Model) visiblePeers() []PeerSummary {
+	peers := m.peers
+	if m.mergeLinks {
+		peers = MergePeers(peers)
+	}
+	peers = filterPeersByKind(FilterPeers(peers, m.filter), m.quickFilters)
+	if m.sortByIdle {
+		peers = slices.Clone(peers)
+		sort.SliceStable(peers, func(i, j int) bool {
+			return peers[i].LastSeen.After(peers[j].LastSeen)
+		})
+	}
+	return peers
+}
+
+// setPeerRows refreshes the peer table from m.peers, applying the filters.
+func (m *Model) setPeerRows() {
+	rows := peerRows(m.visiblePeers(), m.window, m.hostnames)
+	markRows(rows, m.marked)
+	m.peerTable.SetRows(rows)
+	if c := m.peerTable.Cursor(); c >= len(rows) {
+		m.peerTable.SetCursor(max(len(rows)-1, 0))
+	}
+}
+
+// filterPeersByKind returns the peers with window activity in any of kinds.
+// An empty set returns peers unchanged.
+func filterPeersByKind(peers []PeerSummary, kinds map[string]bool) []PeerSummary {
+	if len(kinds) == 0 {
+		return peers
+	}
+	result := make([]PeerSummary, 0, len(peers))
+	for _, p := range peers {
+		for kind := range kinds {
+			if p.Counts[kind] > 0 {
+				result = append(result, p)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// quickFilterLabel lists the active quick filters in column order, e.g. "RA, NS".
+func (m Model) quickFilterLabel() string {
+	var names []string
+	for _, kind := range msgColumnOrder {
+		if m.quickFilters[kind] {
+			names = append(names, msgShortNames[kind])
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// setStatus shows msg in the footer for statusDuration.
+func (m *Model) setStatus(msg string) {
+	m.status = msg
+	m.statusUntil = time.Now().Add(statusDuration)
+}
+
 func (m *Model) switchTab(tab int) {
 	m.activeTab = tab
-	if tab == tabPeers {
+	m.peerTable.Blur()
+	m.routerTable.Blur()
+	m.goneTable.Blur()
+	m.dadTable.Blur()
+	m.alertTable.Blur()
+	m.ruleTable.Blur()
+	switch tab {
+	case tabPeers:
 		m.peerTable.Focus()
-		m.routerTable.Blur()
-	} else {
-		m.peerTable.Blur()
-		m.routerTable.Focus()
+	case tabRouters:
+		if m.showGone {
+			m.goneTable.Focus()
+		} else {
+			m.routerTable.Focus()
+		}
+	case tabDAD:
+		m.dadTable.Focus()
+	case tabAlerts:
+		m.alertTable.Focus()
+	case tabRules:
+		m.ruleTable.Focus()
 	}
 }
 
@@ -265,28 +1251,110 @@ func (m Model) View() string {
 		formatDuration(m.window),
 		time.Now().Format("15:04:05"),
 	)))
-	b.WriteString("\n\n")
+	if m.travelling && !m.travelAt.IsZero() {
+		b.WriteString("  ")
+		b.WriteString(detailLabel.Render(fmt.Sprintf("Peers and routers as of %s (←/→: scrub, t: live)",
+			m.travelAt.Format("2006-01-02 15:04:05"))))
+	}
+	if m.segment != nil {
+		b.WriteString("  ")
+		b.WriteString(detailLabel.Render(fmt.Sprintf("Peers and routers from %s (%s)", m.segment.Name, m.segment.URL)))
+	}
+	if len(m.maintenance) > 0 {
+		b.WriteString("  ")
+		b.WriteString(detailLabel.Render("Maintenance: " + strings.Join(m.maintenance, ", ")))
+	}
+	if m.sampling.Rate > 1 {
+		b.WriteString("  ")
+		b.WriteString(toastStyles[SeverityWarning].Render(fmt.Sprintf("Overloaded: parsing 1 in %d (%d counted only)", m.sampling.Rate, m.sampling.Shed)))
+	}
+	b.WriteString("\n")
+	for _, iface := range slices.Sorted(maps.Keys(m.switchNeighbors)) {
+		n := m.switchNeighbors[iface]
+		b.WriteString(fmt.Sprintf("%s %s (%s)\n", detailLabel.Render(iface+":"), n, strings.ToUpper(n.Protocol)))
+	}
+	if m.travelling && m.timeline != nil {
+		b.WriteString(renderTimeline(*m.timeline, m.travelAt))
+	}
+	if len(m.health) > 0 {
+		b.WriteString(detailLabel.Render("IPv6 health:"))
+		for _, h := range m.health {
+			b.WriteString(" " + segmentName(h.Interface) + " " + healthScoreStyle(h.Score).Render(fmt.Sprint(h.Score)))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
 
 	// Tab bar
 	b.WriteString(m.renderTabBar())
 	b.WriteString("\n\n")
 
-	if m.activeView == "detail" {
+	var body string
+	if m.activeView == "segments" {
+		body = m.renderSegments()
+	} else if m.activeView == "actions" {
+		body = m.renderActionMenu()
+	} else if m.activeView == "batch" {
+		body = m.renderBatchMenu()
+	} else if m.activeView == "output" {
+		body = m.renderActionOutput()
+	} else if m.activeView == "story" {
+		body = m.renderStory()
+	} else if m.activeView == "detail" {
 		if m.activeTab == tabRouters && m.selectedRouter != nil {
-			b.WriteString(m.renderRouterDetail())
+			body = m.renderRouterDetail()
 		} else {
-			b.WriteString(m.renderDetail())
+			body = m.renderDetail()
 		}
 	} else {
-		b.WriteString(m.renderTableView())
+		body = m.renderTableView()
 	}
+	b.WriteString(overlayLines(body, m.renderToasts()))
 
 	// Footer
 	b.WriteString("\n")
-	if m.activeView == "detail" {
-		b.WriteString(footerStyle.Render("Esc: back  q: quit"))
+	if m.status != "" && time.Now().Before(m.statusUntil) {
+		b.WriteString(detailLabel.Render(m.status))
+		b.WriteString("\n")
+	}
+	if m.filtering {
+		b.WriteString(m.filterInput.View())
+		b.WriteString("\n")
+		b.WriteString(footerStyle.Render("Enter: apply  Esc: cancel"))
+	} else if m.editingRule != "" {
+		b.WriteString(m.ruleInput.View())
+		b.WriteString("\n")
+		b.WriteString(footerStyle.Render("Enter: apply to " + m.editingRule + "  Esc: cancel"))
+	} else if m.tagging {
+		b.WriteString(m.tagInput.View())
+		b.WriteString("\n")
+		b.WriteString(footerStyle.Render(fmt.Sprintf("Enter: tag %d peers  Esc: cancel", len(m.markedPeers()))))
+	} else if m.activeView == "segments" {
+		b.WriteString(footerStyle.Render(m.keys.hints("navigate", "navigate", "select", "switch", "back", "back", "quit", "quit")))
+	} else if m.activeView == "actions" || m.activeView == "batch" {
+		b.WriteString(footerStyle.Render(m.keys.hints("1-9", "run", "back", "back", "quit", "quit")))
+	} else if m.activeView == "output" {
+		b.WriteString(footerStyle.Render(m.keys.hints("navigate", "scroll", "back", "stop and close", "quit", "quit")))
+	} else if m.activeView == "story" {
+		b.WriteString(footerStyle.Render(m.keys.hints("back", "back", "actions", "actions", "freeze", "freeze snapshot", "quit", "quit")))
+	} else if m.activeView == "detail" && m.activeTab == tabPeers {
+		b.WriteString(footerStyle.Render(m.keys.hints("back", "back", "story", "story", "actions", "actions", "freeze", "freeze snapshot", "quit", "quit")))
+	} else if m.activeView == "detail" {
+		b.WriteString(footerStyle.Render(m.keys.hints("back", "back", "actions", "actions", "freeze", "freeze snapshot", "quit", "quit")))
+	} else if m.activeTab == tabPeers {
+		b.WriteString(footerStyle.Render(m.keys.hints("navigate", "navigate", "select", "details", "story", "story", "mark", "mark", "actions", "actions", "next_tab", "switch view", "filter", "filter", "1-0", "filter by type", "sort", "sort", "merge", "merge links", "freeze", "freeze snapshot", "quit", "quit")))
+	} else if m.activeTab == tabRouters {
+		b.WriteString(footerStyle.Render(m.keys.hints("navigate", "navigate", "select", "details", "next_tab", "switch view", "history", "history", "freeze", "freeze snapshot", "quit", "quit")))
+	} else if m.activeTab == tabRules {
+		b.WriteString(footerStyle.Render(m.keys.hints("navigate", "navigate", "toggle_rule", "on/off", "edit_rule", "edit match", "ack", "ack alert", "next_tab", "switch view", "quit", "quit")))
+	} else if m.activeTab == tabGraph {
+		b.WriteString(footerStyle.Render(m.keys.hints("next_tab", "switch view", "export_graph", "export DOT/GraphML", "freeze", "freeze snapshot", "quit", "quit")))
+	} else if m.activeTab == tabCompare || m.activeTab == tabStatus {
+		b.WriteString(footerStyle.Render(m.keys.hints("next_tab", "switch view", "freeze", "freeze snapshot", "quit", "quit")))
+	} else if m.activeTab == tabDAD || m.activeTab == tabAlerts {
+		b.WriteString(footerStyle.Render(m.keys.hints("navigate", "navigate", "next_tab", "switch view", "freeze", "freeze snapshot", "quit", "quit")))
 	} else {
-		b.WriteString(footerStyle.Render("↑/↓: navigate  Enter: details  Tab: switch view  q: quit"))
+		b.WriteString(footerStyle.Render(m.keys.hints("navigate", "navigate", "select", "details", "next_tab", "switch view", "dedup", "dedup", "freeze", "freeze snapshot", "quit", "quit")))
 	}
 	b.WriteString("\n")
 
@@ -294,9 +1362,8 @@ func (m Model) View() string {
 }
 
 func (m Model) renderTabBar() string {
-	tabs := []string{"NDP/MLD Peers", "Routers"}
 	var parts []string
-	for i, name := range tabs {
+	for i, name := range tabNames[:m.tabCount()] {
 		if i == m.activeTab {
 			parts = append(parts, activeTabStyle.Render("[ "+name+" ]"))
 		} else {
@@ -316,26 +1383,98 @@ func (m Model) renderTableView() string {
 		}
 
 		b.WriteString(m.peerTable.View())
+		b.WriteString("\n")
+		b.WriteString(totalsStyle.Render(totalsRow(m.peerTable.Columns(), m.visiblePeers(), m.window)))
 		b.WriteString("\n\n")
-		b.WriteString(fmt.Sprintf("Total peers: %d\n", len(m.peers)))
+		stale := 0
+		for _, p := range m.peers {
+			if p.Stale {
+				stale++
+			}
+		}
+		if stale > 0 {
+			b.WriteString(fmt.Sprintf("Total peers: %d (%d stale)\n", len(m.peers), stale))
+		} else {
+			b.WriteString(fmt.Sprintf("Total peers: %d\n", len(m.peers)))
+		}
+		if line := networkRateLine(SummarizeMessages(m.peers, m.window)); line != "" {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+		if line := nudFlaggedLine(m.nud); line != "" {
+			b.WriteString(warnStyle.Render(line))
+			b.WriteString("\n")
+		}
+		if m.sortByIdle {
+			b.WriteString("Sorted by idle time (s: sort by total)\n")
+		}
+		if len(m.quickFilters) > 0 || m.filter != nil {
+			var parts []string
+			if m.filter != nil {
+				parts = append(parts, m.filter.String())
+			}
+			if len(m.quickFilters) > 0 {
+				parts = append(parts, m.quickFilterLabel())
+			}
+			shown := len(m.visiblePeers())
+			b.WriteString(fmt.Sprintf("Filter: %s (showing %d, Esc clears)\n", strings.Join(parts, " + "), shown))
+		}
 
 		// Multicast group summary
 		groupMembers := aggregateMulticastGroups(m.peers)
+		silent := make(map[string]bool)
+		for _, g := range m.mcastSanity.SilentGroups {
+			silent[g.Group] = true
+		}
 		if len(groupMembers) > 0 {
 			b.WriteString("\n")
 			b.WriteString(headerStyle.Render("Multicast Groups:"))
 			b.WriteString("\n")
-			for _, gm := range groupMembers {
-				label := multicastLabel(gm.Group)
+			for i, gm := range groupMembers {
+				if i == 0 || gm.Scope != groupMembers[i-1].Scope {
+					b.WriteString(fmt.Sprintf("  %s\n", detailLabel.Render(multicastScopeName(gm.Scope)+":")))
+				}
+				label := multicastLabel(gm.Group, m.multicastLabels)
 				noun := "hosts"
 				if gm.Members == 1 {
 					noun = "host"
 				}
-				b.WriteString(fmt.Sprintf("  %-40s %-16s %d %s\n",
-					truncate(gm.Group, 40), label, gm.Members, noun))
+				latency := ""
+				if l, ok := m.mldLatency[gm.Group]; ok {
+					latency = fmt.Sprintf("  resp avg %s max %s", formatLatency(l.Mean), formatLatency(l.Max))
+					if l.Late > 0 {
+						latency += fmt.Sprintf(", %d late", l.Late)
+					}
+				}
+				if silent[gm.Group] {
+					latency += "  " + staleStyle.Render("no traffic")
+				}
+				b.WriteString(fmt.Sprintf("    %-40s %-16s %d %s%s\n",
+					truncate(gm.Group, 40), label, gm.Members, noun, latency))
 			}
 		}
-	} else {
+		if dones := m.mcastSanity.DoneWithoutJoin; len(dones) > 0 {
+			b.WriteString(fmt.Sprintf("  %s\n", detailLabel.Render("MLD Done without Join:")))
+			for i, d := range dones {
+				if i == maxOrphanDonesShown {
+					b.WriteString(fmt.Sprintf("    ... and %d more\n", len(dones)-i))
+					break
+				}
+				b.WriteString(fmt.Sprintf("    %-40s left %-24s %s\n",
+					truncate(d.Peer, 40), d.Group, d.At.Format("15:04:05")))
+			}
+		}
+	} else if m.activeTab == tabRouters && m.showGone {
+		b.WriteString(headerStyle.Render("Previously Seen Routers"))
+		b.WriteString("\n")
+		if len(m.gone) == 0 {
+			b.WriteString("No routers have disappeared.\n")
+		} else {
+			b.WriteString(m.goneTable.View())
+			b.WriteString("\n\n")
+			b.WriteString(fmt.Sprintf("Disappeared routers: %d\n", len(m.gone)))
+		}
+	} else if m.activeTab == tabRouters {
 		if len(m.routers) == 0 {
 			b.WriteString("No routers observed yet...\n")
 		} else {
@@ -343,6 +1482,353 @@ func (m Model) renderTableView() string {
 			b.WriteString("\n\n")
 			b.WriteString(fmt.Sprintf("Total routers: %d\n", len(m.routers)))
 		}
+	} else if m.activeTab == tabDAD {
+		b.WriteString(headerStyle.Render("Duplicate Address Detection"))
+		b.WriteString("\n")
+		if len(m.dad) == 0 {
+			b.WriteString("No DAD probes observed yet...\n")
+		} else {
+			b.WriteString(m.dadTable.View())
+			b.WriteString("\n\n")
+			duplicates := 0
+			for _, d := range m.dad {
+				if d.Outcome == DADDuplicate {
+					duplicates++
+				}
+			}
+			b.WriteString(fmt.Sprintf("DAD transactions: %d (%d duplicate)\n", len(m.dad), duplicates))
+		}
+	} else if m.activeTab == tabRules {
+		b.WriteString(m.renderRules())
+	} else if m.activeTab == tabGraph {
+		b.WriteString(m.renderGraph())
+	} else if m.activeTab == tabStatus {
+		b.WriteString(m.renderStatus())
+	} else if m.activeTab == tabAlerts {
+		b.WriteString(headerStyle.Render("Alerts"))
+		b.WriteString("\n")
+		if len(m.alerts) == 0 {
+			b.WriteString("No alerts raised yet...\n")
+		} else {
+			b.WriteString(m.alertTable.View())
+			b.WriteString("\n\n")
+			counts := make(map[Severity]int)
+			for _, a := range m.alerts {
+				counts[a.Severity]++
+			}
+			b.WriteString(fmt.Sprintf("Alerts: %d (%d critical, %d warning)\n",
+				len(m.alerts), counts[SeverityCritical], counts[SeverityWarning]))
+		}
+	} else if m.activeTab == tabCompare {
+		b.WriteString(m.renderComparison())
+	} else {
+		b.WriteString(m.renderSizes())
+	}
+
+	return b.String()
+}
+
+// renderComparison lists the peers and routers only one input has seen.
+func (m Model) renderComparison() string {
+	var b strings.Builder
+	c := m.comparison
+	a, bl := m.compareLabels[0], m.compareLabels[1]
+
+	b.WriteString(headerStyle.Render(fmt.Sprintf("Comparing %s (A) with %s (B)", a, bl)))
+	b.WriteString("\n")
+	b.WriteString(fmt.Sprintf("In both: %d peers, %d routers\n", c.CommonPeers, c.CommonRouters))
+
+	routerSection := func(title string, routers []RouterInfo) {
+		b.WriteString("\n")
+		b.WriteString(headerStyle.Render(fmt.Sprintf("%s (%d):", title, len(routers))))
+		b.WriteString("\n")
+		for _, r := range routers {
+			b.WriteString(fmt.Sprintf("  %-40s %-17s lifetime %s, %d prefix(es)\n",
+				truncate(r.Address, 40), r.MAC, formatDuration(r.Lifetime), len(r.Prefixes)))
+		}
+	}
+	peerSection := func(title string, peers []PeerSummary) {
+		b.WriteString("\n")
+		b.WriteString(headerStyle.Render(fmt.Sprintf("%s (%d):", title, len(peers))))
+		b.WriteString("\n")
+		for _, p := range peers {
+			var kinds []string
+			for _, kind := range msgColumnOrder {
+				if p.Counts[kind] > 0 {
+					kinds = append(kinds, fmt.Sprintf("%s %d", msgShortNames[kind], p.Counts[kind]))
+				}
+			}
+			b.WriteString(fmt.Sprintf("  %-40s %-17s %s\n", truncate(p.Address, 40), p.MAC, strings.Join(kinds, ", ")))
+		}
+	}
+
+	routerSection("Routers only in "+a, c.OnlyARouters)
+	routerSection("Routers only in "+bl, c.OnlyBRouters)
+	peerSection("Peers only in "+a, c.OnlyAPeers)
+	peerSection("Peers only in "+bl, c.OnlyBPeers)
+	return b.String()
+}
+
+// renderGraph lists who solicited whom, most connected source first.
+func (m Model) renderGraph() string {
+	if len(m.graph.Edges) == 0 {
+		return "No Neighbor Solicitations observed yet...\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(headerStyle.Render(fmt.Sprintf("Who solicits whom (%d addresses, %d edges):",
+		len(m.graph.Nodes), len(m.graph.Edges))))
+	b.WriteString("\n\n")
+	for _, edges := range m.graph.Neighbors() {
+		b.WriteString(fmt.Sprintf("  %s\n", edges[0].Source))
+		for _, e := range edges {
+			b.WriteString(fmt.Sprintf("    → %-40s %5d NS  last %s\n",
+				truncate(e.Target, 40), e.Solicitations, formatTimestamp(e.LastSolicited)))
+		}
+	}
+	return b.String()
+}
+
+// renderStatus shows how this instance was built, started and configured.
+func (m Model) renderStatus() string {
+	st := m.about
+	var b strings.Builder
+	line := func(label, value string) {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render(fmt.Sprintf("%-11s", label+":")), value))
+	}
+
+	b.WriteString(headerStyle.Render("Status:"))
+	b.WriteString("\n\n")
+	build := st.Build.Version
+	if st.Build.Revision != "" {
+		build += " (" + truncate(st.Build.Revision, 12)
+		if st.Build.Modified {
+			build += ", modified"
+		}
+		build += ")"
+	}
+	line("Version", build+" built with "+st.Build.GoVersion)
+	if !st.Started.IsZero() {
+		line("Started", fmt.Sprintf("%s (up %s)", st.Started.Format("2006-01-02 15:04:05"), formatDuration(st.Uptime)))
+	}
+	capture := st.Capture
+	switch {
+	case st.PcapFile != "":
+		capture += " replaying " + st.PcapFile
+	case st.Interface != "":
+		capture += " on " + st.Interface
+	case capture != "":
+		capture += " on all interfaces"
+	}
+	if st.Mirror {
+		capture += " (mirror port)"
+	}
+	line("Capture", capture)
+	if len(m.interfaces) > 1 {
+		for _, s := range m.interfaces {
+			name := s.Interface
+			if name == "" {
+				name = "(unknown)"
+			}
+			line("  "+name, fmt.Sprintf("%d peers, %d routers, %d messages", s.Peers, s.Routers, s.Total))
+		}
+	}
+	if st.Node != "" {
+		line("Node", st.Node)
+	}
+	line("Window", formatDuration(m.window))
+	if pr := st.Prune; pr.Total > 0 {
+		line("Pruned", fmt.Sprintf("%s ago in %s (%d runs)", formatDuration(time.Since(pr.Last).Truncate(time.Second)), pr.Duration.Round(time.Microsecond), pr.Total))
+	}
+	sinks := strings.Join(st.Sinks, ", ")
+	if sinks == "" {
+		sinks = "none"
+	}
+	line("Sinks", sinks)
+	if m.fastPath.Enabled {
+		hot := "no type dominates"
+		if len(m.fastPath.Hot) > 0 {
+			hot = strings.Join(m.fastPath.Hot, ", ")
+		}
+		line("Fast path", fmt.Sprintf("%s (%d messages)", hot, m.fastPath.Fast))
+	}
+	if m.raGuard.Enabled {
+		if m.raGuard.Leaks == 0 {
+			line("RA guard", "no RA got past")
+		} else {
+			line("RA guard", warnStyle.Render(fmt.Sprintf("%d RAs got past, last from %s at %s",
+				m.raGuard.Leaks, m.raGuard.LastSource, m.raGuard.LastLeak.Format("15:04:05"))))
+		}
+	}
+	if s := m.rogueRA; s.Enabled {
+		switch {
+		case s.RogueRAs > 0:
+			line("Rogue RAs", warnStyle.Render(fmt.Sprintf("%d RAs from untrusted routers, last from %s at %s",
+				s.RogueRAs, s.LastSource, s.LastRogue.Format("15:04:05"))))
+		case s.Trusted == 0:
+			line("Rogue RAs", "no trusted routers listed; checking lifetimes and prefixes")
+		default:
+			line("Rogue RAs", fmt.Sprintf("none (%d trusted routers)", s.Trusted))
+		}
+	}
+	if s := m.mldSnoop; s.Enabled {
+		switch {
+		case s.QueriesMissing:
+			line("MLD snooping", warnStyle.Render("general queries missing"))
+		case s.Unanswered > 0:
+			line("MLD snooping", warnStyle.Render(fmt.Sprintf("%d queries in a row unanswered by %d members", s.Unanswered, s.Members)))
+		case s.LastQuery.IsZero():
+			line("MLD snooping", "waiting for a general query")
+		default:
+			line("MLD snooping", fmt.Sprintf("last query %s, answered by %d of %d members",
+				s.LastQuery.Format("15:04:05"), s.Answered, s.Members))
+		}
+	}
+	if m.mtu.ICMPErrors {
+		if m.mtu.PacketTooBig == 0 {
+			line("Packet Too Big", "none seen")
+		} else {
+			line("Packet Too Big", fmt.Sprintf("%d seen, smallest MTU %d, last %d from %s",
+				m.mtu.PacketTooBig, m.mtu.SmallestMTU, m.mtu.LastMTU, m.mtu.LastSource))
+		}
+	}
+	if len(st.RulePacks) > 0 {
+		var packs []string
+		for _, p := range st.RulePacks {
+			pack := p.Name
+			if p.Version != "" {
+				pack += " " + p.Version
+			}
+			packs = append(packs, fmt.Sprintf("%s (%s, %d rules)", pack, p.Source, p.Rules))
+		}
+		line("Rule packs", strings.Join(packs, "; "))
+	}
+	for i, h := range m.health {
+		label := ""
+		if i == 0 {
+			label = "IPv6 health:"
+		}
+		b.WriteString(fmt.Sprintf("  %s  %s %s/100\n", detailLabel.Render(fmt.Sprintf("%-11s", label)), segmentName(h.Interface), healthScoreStyle(h.Score).Render(fmt.Sprint(h.Score))))
+		for _, sig := range h.Signals {
+			b.WriteString(fmt.Sprintf("  %11s    %-22s %2d/%-2d  %s\n", "", sig.Name, sig.Points, sig.Max, sig.Detail))
+		}
+	}
+	if st.ConfigPath == "" {
+		line("Config", "none")
+		return b.String()
+	}
+	line("Config", st.ConfigPath+" (secrets redacted)")
+	set := make(map[string]any)
+	for k, v := range st.Config {
+		if v != nil {
+			set[k] = v
+		}
+	}
+	data, err := yaml.Marshal(set)
+	if err != nil {
+		return b.String()
+	}
+	b.WriteString("\n")
+	for _, l := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		b.WriteString("    " + l + "\n")
+	}
+	return b.String()
+}
+
+// renderSizes renders the per-type message size histograms.
+func (m Model) renderSizes() string {
+	if len(m.sizes) == 0 {
+		return "No NDP/MLD traffic observed yet...\n"
+	}
+
+	var b strings.Builder
+
+	b.WriteString(headerStyle.Render("Message Sizes (bytes, since start):"))
+	b.WriteString("\n\n")
+	b.WriteString(fmt.Sprintf("  %-5s", "Type"))
+	for _, label := range sizeBucketLabels {
+		b.WriteString(fmt.Sprintf(" %7s", label))
+	}
+	b.WriteString(fmt.Sprintf(" %7s %7s\n", "Max", "Over"))
+
+	oversized := 0
+	for _, kind := range msgColumnOrder {
+		h, ok := m.sizes[kind]
+		if !ok {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  %-5s", msgShortNames[kind]))
+		for _, n := range h.Buckets {
+			b.WriteString(fmt.Sprintf(" %7d", n))
+		}
+		b.WriteString(fmt.Sprintf(" %7d %7d\n", h.Max, h.Oversized))
+		oversized += h.Oversized
+	}
+
+	if oversized > 0 {
+		b.WriteString("\n")
+		b.WriteString(detailLabel.Render(fmt.Sprintf("%d oversized message(s) flagged; see alerts in the log.", oversized)))
+		b.WriteString("\n")
+	}
+
+	if rows := optionUsageRows(m.options); len(rows) > 0 {
+		b.WriteString("\n")
+		b.WriteString(headerStyle.Render("NDP Option Usage (share of messages, by stack):"))
+		b.WriteString("\n")
+		for _, row := range rows {
+			b.WriteString(row)
+			b.WriteString("\n")
+		}
+	}
+
+	if m.checksumFailures > 0 {
+		b.WriteString("\n")
+		b.WriteString(detailLabel.Render(fmt.Sprintf("%d ICMPv6 message(s) dropped for a bad checksum.", m.checksumFailures)))
+		b.WriteString("\n")
+	}
+
+	if dups := duplicateRows(m.duplicates); len(dups) > 0 {
+		b.WriteString("\n")
+		b.WriteString(headerStyle.Render("Duplicate Packets:"))
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprintf("  %-16s %9s %9s %7s %9s\n", "Interface", "Messages", "Dups", "Ratio", "InWindow"))
+		for _, row := range dups {
+			b.WriteString(row)
+			b.WriteString("\n")
+		}
+	}
+	if m.dedup.Enabled {
+		b.WriteString("\n")
+		b.WriteString(detailLabel.Render(fmt.Sprintf("Dedup on: %d duplicate(s) dropped before recording.", m.dedup.Dropped)))
+		b.WriteString("\n")
+	}
+
+	if len(m.routerAlert) > 0 {
+		b.WriteString("\n")
+		b.WriteString(headerStyle.Render("MLD Without Valid Router Alert:"))
+		b.WriteString("\n")
+		reasons := make([]string, 0, len(m.routerAlert))
+		for reason := range m.routerAlert {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			b.WriteString(fmt.Sprintf("  %-24s %7d\n", reason, m.routerAlert[reason]))
+		}
+	}
+
+	if len(m.extAnomalies) > 0 {
+		b.WriteString("\n")
+		b.WriteString(headerStyle.Render("Unexpected Extension Header Chains (ICMPv6 packets):"))
+		b.WriteString("\n")
+		reasons := make([]string, 0, len(m.extAnomalies))
+		for reason := range m.extAnomalies {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			b.WriteString(fmt.Sprintf("  %-24s %7d\n", reason, m.extAnomalies[reason]))
+		}
 	}
 
 	return b.String()
@@ -376,12 +1862,87 @@ func (m Model) renderDetail() string {
 	if osType == "" {
 		osType = "Unknown"
 	}
+	if label := virtualMACLabel(p.MAC); label != "" {
+		mac += "  (" + label + " virtual MAC)"
+	}
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("MAC:"), mac))
+	if len(p.MACHistory) > 1 {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("MAC Changes:"), warnStyle.Render(formatMACHistory(p.MACHistory))))
+	}
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Hop Limit:"), hl))
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Interface:"), iface))
+	if p.Port != "" {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Port:"), p.Port))
+	}
+	if n, ok := m.upstream(p.Interface, p.Port); ok {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Switch Port:"), n))
+	}
+	if lookup, ok := m.macLookups[p.MAC]; ok && m.locator != nil {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Located At:"), formatMACLookup(lookup)))
+	}
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("OS/Type:"), osType))
+	if p.Name != "" {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Name:"), p.Name))
+	}
+	if p.Hostname != "" {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Hostname:"), p.Hostname))
+	}
+	if p.Vendor != "" {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Vendor:"), p.Vendor))
+	}
+	if p.DeviceType != "" {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Device Type:"), p.DeviceType))
+	}
+	if p.Trusted {
+		b.WriteString(fmt.Sprintf("  %s  yes\n", detailLabel.Render("Trusted:")))
+	}
+	if len(p.Tags) > 0 {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Tags:"), strings.Join(p.Tags, ", ")))
+	}
+	for _, v := range p.Reputation {
+		line := v.Verdict + " (" + v.Source
+		if v.Reason != "" {
+			line += ": " + v.Reason
+		}
+		line += ")"
+		if v.Verdict != ReputationClean {
+			line = warnStyle.Render(line)
+		}
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Reputation:"), line))
+	}
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("First Seen:"), formatTimestamp(p.FirstSeen)))
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Last Seen:"), formatTimestamp(p.LastSeen)))
+	if p.Stale {
+		idle := time.Since(p.LastSeen)
+		remaining := m.window + m.stats.Grace() - idle
+		if remaining < 0 {
+			remaining = 0
+		}
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("State:"),
+			staleStyle.Render(fmt.Sprintf("stale (quiet %s, removed in %s)", formatDuration(idle), formatDuration(remaining)))))
+	}
+	if p.Activity != "" {
+		activity := p.Activity
+		if p.WakeInterval > 0 {
+			activity += fmt.Sprintf(" (wakes every ~%s)", formatDuration(p.WakeInterval))
+		}
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Activity:"), activity))
+	}
+	if p.NDProxy != "" {
+		b.WriteString(fmt.Sprintf("  %s  answers for %d addresses, mostly in %s\n",
+			detailLabel.Render("ND proxy:"), p.ProxiedTargets, p.NDProxy))
+	}
+	if pop := p.Popularity; pop != nil {
+		line := fmt.Sprintf("%d NS from %d peers", pop.Solicitations, len(pop.Solicitors))
+		if pop.Solicitations > 1 {
+			line += fmt.Sprintf(" (every %s median, p90 %s, p99 %s)",
+				formatInterval(pop.IntervalP50), formatInterval(pop.IntervalP90), formatInterval(pop.IntervalP99))
+		}
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Solicited:"), line))
+	}
+	if nud, ok := m.nud[p.Address]; ok {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("NUD:"), nudLine(nud)))
+	}
 
 	// Message counts
 	b.WriteString("\n")
@@ -403,19 +1964,49 @@ func (m Model) renderDetail() string {
 	}
 	b.WriteString("\n")
 
+	if p.TotalBytes > 0 {
+		b.WriteString(fmt.Sprintf("  %s\n", detailLabel.Render("Bytes:")))
+		for _, kinds := range [][]string{msgColumnOrder[:7], msgColumnOrder[7:]} {
+			b.WriteString("    ")
+			for _, kind := range kinds {
+				b.WriteString(fmt.Sprintf("%-5s %4s    ", msgShortNames[kind], formatBytes(float64(p.Bytes[kind]))))
+			}
+			b.WriteString("\n")
+		}
+	}
+
 	b.WriteString(fmt.Sprintf("\n  %s  %d\n", detailLabel.Render("Total:"), p.Total))
+	if p.TotalBytes > 0 {
+		b.WriteString(fmt.Sprintf("  %s  %s (%s B/s)\n", detailLabel.Render("Total bytes:"),
+			formatBytes(float64(p.TotalBytes)), formatBytes(byteRate(p.TotalBytes, m.window))))
+	}
+	if p.NoRouterAlert > 0 {
+		b.WriteString(fmt.Sprintf("  %s  %d\n", detailLabel.Render("MLD w/o Router Alert:"), p.NoRouterAlert))
+	}
+	if p.Oversized > 0 {
+		b.WriteString(fmt.Sprintf("  %s  %d\n", detailLabel.Render("Oversized:"), p.Oversized))
+	}
 
 	// Multicast groups
 	if len(p.Groups) > 0 {
 		b.WriteString("\n")
 		b.WriteString(fmt.Sprintf("  %s\n", detailLabel.Render("Multicast Groups:")))
 		for _, group := range p.Groups {
-			label := multicastLabel(group)
-			if label != "" {
-				b.WriteString(fmt.Sprintf("    %-40s %s\n", group, label))
-			} else {
-				b.WriteString(fmt.Sprintf("    %s\n", group))
+			label := multicastLabel(group, m.multicastLabels)
+			line := fmt.Sprintf("    %-40s %-18s %-16s", group, multicastScopeName(multicastScope(group)), label)
+			if d, ok := p.MLDLatency[group]; ok {
+				line += " responded in " + formatLatency(d)
 			}
+			b.WriteString(strings.TrimRight(line, " ") + "\n")
+		}
+	}
+
+	// Addresses its solicited-node groups claim but it never answered NS for
+	if len(p.Undefended) > 0 {
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprintf("  %s\n", detailLabel.Render("Joined but not answering NS for:")))
+		for _, target := range p.Undefended {
+			b.WriteString(fmt.Sprintf("    %s\n", staleStyle.Render(target)))
 		}
 	}
 
@@ -424,7 +2015,9 @@ func (m Model) renderDetail() string {
 
 // --- Table constructors ---
 
-func newPeerTable() table.Model {
+// newPeerTable returns the peer table, with the Hostname column last when
+// hostnames is set.
+func newPeerTable(hostnames bool) table.Model {
 	columns := []table.Column{
 		{Title: "IPv6 Address", Width: 40},
 		{Title: "MAC", Width: 17},
@@ -442,8 +2035,13 @@ func newPeerTable() table.Model {
 		{Title: "MR", Width: 4},
 		{Title: "MD", Width: 4},
 		{Title: "Total", Width: 5},
+		{Title: "B/s", Width: 5},
 		{Title: "First", Width: 8},
 		{Title: "Last", Width: 8},
+		{Title: "Idle", Width: 7},
+	}
+	if hostnames {
+		columns = append(columns, table.Column{Title: "Hostname", Width: 30})
 	}
 
 	s := table.DefaultStyles()
@@ -503,8 +2101,44 @@ func newRouterTable() table.Model {
 	return t
 }
 
-// peerRows converts PeerSummary data into table rows.
-func peerRows(peers []PeerSummary) []table.Row {
+func newGoneRouterTable() table.Model {
+	columns := []table.Column{
+		{Title: "Router Address", Width: 40},
+		{Title: "MAC", Width: 17},
+		{Title: "Life", Width: 6},
+		{Title: "Pfx", Width: 3},
+		{Title: "MTU", Width: 5},
+		{Title: "Iface", Width: 10},
+		{Title: "First Seen", Width: 10},
+		{Title: "Last Seen", Width: 9},
+		{Title: "Gone", Width: 8},
+	}
+
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(true)
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57")).
+		Bold(false)
+
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithFocused(false),
+		table.WithHeight(20),
+		table.WithStyles(s),
+	)
+
+	return t
+}
+
+// peerRows converts PeerSummary data into table rows. Byte rates are
+// averaged over window.
+func peerRows(peers []PeerSummary, window time.Duration, hostnames bool) []table.Row {
+	now := time.Now()
 	rows := make([]table.Row, 0, len(peers))
 	for _, p := range peers {
 		mac := p.MAC
@@ -515,10 +2149,7 @@ func peerRows(peers []PeerSummary) []table.Row {
 		if p.HopLimit != 0 {
 			hl = fmt.Sprintf("%d", p.HopLimit)
 		}
-		iface := p.Interface
-		if iface == "" {
-			iface = "-"
-		}
+		iface := ifaceLabel(p.Interface, p.Port)
 		osType := p.GuessedOS
 		if osType == "" {
 			osType = "-"
@@ -535,18 +2166,168 @@ func peerRows(peers []PeerSummary) []table.Row {
 		}
 		row = append(row,
 			fmt.Sprintf("%d", p.Total),
+			formatBytes(byteRate(p.TotalBytes, window)),
 			formatTimestamp(p.FirstSeen),
 			formatTimestamp(p.LastSeen),
+			idleCell(p, now),
 		)
+		if hostnames {
+			hostname := p.Hostname
+			if hostname == "" {
+				hostname = "-"
+			}
+			row = append(row, hostname)
+		}
 		rows = append(rows, row)
 	}
 	return rows
 }
 
+// totalsRow sums each message-type column over peers and lays the sums out
+// under the peer table's columns (same one-space cell padding as the table).
+func totalsRow(columns []table.Column, peers []PeerSummary, window time.Duration) string {
+	sum := SummarizeMessages(peers, window)
+
+	cells := make([]string, len(columns))
+	cells[0] = fmt.Sprintf("Totals (%d peers)", sum.Peers)
+	for i, kind := range msgColumnOrder {
+		cells[5+i] = fmt.Sprintf("%d", sum.Type(kind).Count)
+	}
+	cells[5+len(msgColumnOrder)] = fmt.Sprintf("%d", sum.Total)
+	cells[6+len(msgColumnOrder)] = formatBytes(sum.ByteRate)
+
+	var b strings.Builder
+	for i, col := range columns {
+		if col.Width <= 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf(" %-*s ", col.Width, truncate(cells[i], col.Width)))
+	}
+	return b.String()
+}
+
+// duplicateRows formats the interfaces that saw duplicated packets for the
+// Sizes tab.
+func duplicateRows(dups []InterfaceDuplicates) []string {
+	var rows []string
+	for _, d := range dups {
+		if d.Duplicates == 0 {
+			continue
+		}
+		name := d.Interface
+		if name == "" {
+			name = "-"
+		}
+		rows = append(rows, fmt.Sprintf("  %-16s %9d %9d %6.1f%% %9d", truncate(name, 16), d.Messages, d.Duplicates, 100*d.Ratio, d.Recent))
+	}
+	return rows
+}
+
+// optionUsageRows formats the option usage matrix for the Sizes tab: a
+// header, then one row per stack and message type with the share of
+// messages carrying each option that was seen anywhere.
+func optionUsageRows(usage []OptionUsage) []string {
+	if len(usage) == 0 {
+		return nil
+	}
+	cols := optionColumns(usage)
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("  %-12s %-5s %5s %7s", "Stack", "Type", "Peers", "Msgs"))
+	for _, name := range cols {
+		label := ndpOptionShortNames[name]
+		if label == "" {
+			label = name
+		}
+		b.WriteString(fmt.Sprintf(" %6s", truncate(label, 6)))
+	}
+	rows := []string{b.String()}
+	for _, u := range usage {
+		b.Reset()
+		b.WriteString(fmt.Sprintf("  %-12s %-5s %5d %7d", truncate(u.Stack, 12), msgShortNames[u.Kind], u.Peers, u.Messages))
+		for _, name := range cols {
+			cell := "-"
+			if u.Options[name] > 0 {
+				cell = fmt.Sprintf("%.0f%%", 100*u.Share(name))
+			}
+			b.WriteString(fmt.Sprintf(" %6s", cell))
+		}
+		rows = append(rows, b.String())
+	}
+	return rows
+}
+
+// nudByHost indexes NUD behavior by host.
+func nudByHost(behavior []NUDBehavior) map[string]NUDBehavior {
+	byHost := make(map[string]NUDBehavior, len(behavior))
+	for _, b := range behavior {
+		byHost[b.Host] = b
+	}
+	return byHost
+}
+
+// nudLine describes a host's NUD probing for the peer detail view.
+func nudLine(b NUDBehavior) string {
+	line := fmt.Sprintf("%d probes to %s in %d bursts, up to %d NS each", b.Probes, nudTargets(b.Targets), b.Bursts, b.MaxProbes)
+	if b.RetransInterval > 0 {
+		line += ", retransmit " + formatLatency(b.RetransInterval)
+	}
+	if b.ProbeInterval > 0 {
+		line += ", every " + formatInterval(b.ProbeInterval)
+	}
+	if b.Verdict != "" {
+		line += fmt.Sprintf(" — %s: %s", b.Verdict, b.Reason)
+	}
+	return line
+}
+
+// nudFlaggedLine counts the hosts with aggressive or broken NUD timers for
+// the peers view, or "" if there are none.
+func nudFlaggedLine(nud map[string]NUDBehavior) string {
+	counts := make(map[string]int)
+	for _, b := range nud {
+		if b.Verdict != "" {
+			counts[b.Verdict]++
+		}
+	}
+	if len(counts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("NUD: %d aggressive, %d broken host(s); see peer details", counts[NUDAggressive], counts[NUDBroken])
+}
+
+// networkRateLine describes the network-wide message rate over the window,
+// with a breakdown by type, or "" if nothing was seen.
+func networkRateLine(sum NetworkSummary) string {
+	if sum.Total == 0 {
+		return ""
+	}
+	var parts []string
+	for _, t := range sum.Types {
+		if t.Count == 0 {
+			continue
+		}
+		name := msgShortNames[t.Kind]
+		if name == "" {
+			name = t.Kind
+		}
+		parts = append(parts, fmt.Sprintf("%s %.2f", name, t.Rate))
+	}
+	rate := fmt.Sprintf("%.2f msg/s", sum.Rate)
+	if sum.Bytes > 0 {
+		rate += fmt.Sprintf(", %s B/s", formatBytes(sum.ByteRate))
+	}
+	return fmt.Sprintf("Network: %s over %s (%s)", rate, formatDuration(sum.Window), strings.Join(parts, ", "))
+}
+
 // routerRows converts RouterInfo data into table rows.
-func routerRows(routers []RouterInfo) []table.Row {
+// Routers that stopped answering our NS probes are marked with ✗.
+func routerRows(routers []RouterInfo, reach map[string]RouterReachability) []table.Row {
 	rows := make([]table.Row, 0, len(routers))
 	for _, r := range routers {
+		addr := r.Address
+		if reach[r.Address].Unreachable {
+			addr = "✗ " + addr
+		}
 		mac := r.MAC
 		if mac == "" {
 			mac = "-"
@@ -567,12 +2348,9 @@ func routerRows(routers []RouterInfo) []table.Row {
 		if r.MTU != 0 {
 			mtu = fmt.Sprintf("%d", r.MTU)
 		}
-		iface := r.Interface
-		if iface == "" {
-			iface = "-"
-		}
+		iface := ifaceLabel(r.Interface, r.Port)
 		rows = append(rows, table.Row{
-			r.Address,
+			addr,
 			mac,
 			formatDuration(r.Lifetime),
 			hop,
@@ -588,6 +2366,145 @@ func routerRows(routers []RouterInfo) []table.Row {
 	return rows
 }
 
+// goneRouterRows converts the router history into table rows.
+func goneRouterRows(gone []GoneRouter) []table.Row {
+	rows := make([]table.Row, 0, len(gone))
+	for _, g := range gone {
+		mac := g.MAC
+		if mac == "" {
+			mac = "-"
+		}
+		mtu := "-"
+		if g.MTU != 0 {
+			mtu = fmt.Sprintf("%d", g.MTU)
+		}
+		iface := g.Interface
+		if iface == "" {
+			iface = "-"
+		}
+		rows = append(rows, table.Row{
+			g.Address,
+			mac,
+			formatDuration(g.Lifetime),
+			fmt.Sprintf("%d", len(g.Prefixes)),
+			mtu,
+			iface,
+			formatTimestamp(g.FirstSeen),
+			formatTimestamp(g.LastSeen),
+			formatTimestamp(g.GoneAt),
+		})
+	}
+	return rows
+}
+
+func newDADTable() table.Model {
+	columns := []table.Column{
+		{Title: "Started", Width: 8},
+		{Title: "Tentative Address", Width: 40},
+		{Title: "Requester", Width: 17},
+		{Title: "Probes", Width: 6},
+		{Title: "Outcome", Width: 9},
+		{Title: "Took", Width: 6},
+		{Title: "Defended By", Width: 40},
+	}
+
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(true)
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57")).
+		Bold(false)
+
+	return table.New(
+		table.WithColumns(columns),
+		table.WithFocused(false),
+		table.WithHeight(20),
+		table.WithStyles(s),
+	)
+}
+
+func newAlertTable() table.Model {
+	columns := []table.Column{
+		{Title: "Time", Width: 8},
+		{Title: "Severity", Width: 8},
+		{Title: "Category", Width: 20},
+		{Title: "Source", Width: 40},
+		{Title: "Message", Width: 80},
+	}
+
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(true)
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57")).
+		Bold(false)
+
+	return table.New(
+		table.WithColumns(columns),
+		table.WithFocused(false),
+		table.WithHeight(20),
+		table.WithStyles(s),
+	)
+}
+
+// alertRows converts stored alerts, newest first, into table rows.
+func alertRows(alerts []Alert) []table.Row {
+	rows := make([]table.Row, 0, len(alerts))
+	for _, a := range alerts {
+		source := a.Source
+		if source == "" {
+			source = "-"
+		}
+		message := a.Message
+		if a.Acked {
+			message = "[acked] " + message
+		}
+		rows = append(rows, table.Row{
+			formatTimestamp(a.Time),
+			a.Severity.String(),
+			a.Category,
+			source,
+			message,
+		})
+	}
+	return rows
+}
+
+// dadRows converts DAD transactions into table rows.
+func dadRows(dad []DADTransaction) []table.Row {
+	rows := make([]table.Row, 0, len(dad))
+	for _, d := range dad {
+		requester := d.Requester
+		if requester == "" {
+			requester = "-"
+		}
+		defender := d.Defender
+		if d.Outcome == DADConflict {
+			defender = strings.Join(d.MACs, ", ")
+		} else if defender == "" {
+			defender = "-"
+		}
+		rows = append(rows, table.Row{
+			formatTimestamp(d.Start),
+			d.Target,
+			requester,
+			fmt.Sprintf("%d", d.Probes),
+			d.Outcome,
+			formatLatency(d.Duration),
+			defender,
+		})
+	}
+	return rows
+}
+
 func (m Model) renderRouterDetail() string {
 	r := m.selectedRouter
 	if r == nil {
@@ -613,10 +2530,33 @@ func (m Model) renderRouterDetail() string {
 		iface = "-"
 	}
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("MAC:"), mac))
+	if proto, group, ok := VirtualMAC(r.MAC); ok {
+		master := "unknown (needs --capture packet to see adverts)"
+		for _, vr := range m.virtualRouters {
+			if vr.Protocol == proto && vr.Group == group {
+				master = fmt.Sprintf("%s (%s), priority %d, since %s", vr.Master, orDash(vr.MasterMAC), vr.Priority, formatTimestamp(vr.Since))
+				if vr.Changes > 0 {
+					master += fmt.Sprintf(", %d change(s), previously %s", vr.Changes, vr.PrevMaster)
+				}
+			}
+		}
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Virtual:"), virtualMACLabel(r.MAC)))
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Master:"), master))
+	}
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Interface:"), iface))
+	if r.Port != "" {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Port:"), r.Port))
+	}
+	if n, ok := m.upstream(r.Interface, r.Port); ok {
+		b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Switch Port:"), n))
+	}
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Hop Limit:"), hop))
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("First Seen:"), formatTimestamp(r.FirstSeen)))
 	b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render("Last Seen:"), formatTimestamp(r.LastSeen)))
+	if !m.selectedGoneAt.IsZero() {
+		b.WriteString(fmt.Sprintf("  %s  %s (last advertised configuration below)\n",
+			detailLabel.Render("Gone:"), formatTimestamp(m.selectedGoneAt)))
+	}
 
 	// Flags and Lifetime
 	b.WriteString("\n")
@@ -632,6 +2572,7 @@ func (m Model) renderRouterDetail() string {
 	}
 	b.WriteString(fmt.Sprintf("    Managed (M):   %s\n", managed))
 	b.WriteString(fmt.Sprintf("    Other (O):     %s\n", other))
+	b.WriteString(fmt.Sprintf("    Preference:    %s\n", routePreferenceName(r.Preference)))
 	if r.MTU != 0 {
 		b.WriteString(fmt.Sprintf("    MTU:           %d\n", r.MTU))
 	}
@@ -670,39 +2611,121 @@ func (m Model) renderRouterDetail() string {
 		}
 	}
 
+	// DNS search list
+	if len(r.DNSSL) > 0 {
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprintf("  %s\n", detailLabel.Render("DNS Search List (DNSSL):")))
+		for _, domain := range r.DNSSL {
+			b.WriteString(fmt.Sprintf("    %s\n", domain))
+		}
+	}
+
 	// Routes
 	if len(r.Routes) > 0 {
 		b.WriteString("\n")
 		b.WriteString(fmt.Sprintf("  %s\n", detailLabel.Render("Routes:")))
 		b.WriteString(fmt.Sprintf("    %-40s  %-8s  %s\n", "Prefix", "Lifetime", "Pref"))
 		for _, rt := range r.Routes {
-			pref := "med"
-			switch rt.Preference {
-			case 1:
-				pref = "high"
-			case 3:
-				pref = "low"
-			}
 			b.WriteString(fmt.Sprintf("    %-40s  %-8s  %s\n",
 				rt.Prefix,
 				formatDuration(rt.Lifetime),
-				pref,
+				routePreferenceName(rt.Preference),
 			))
 		}
 	}
 
+	// Answers to our unicast NS probes (--probe-routers)
+	if reach, ok := m.reachability[r.Address]; ok && m.selectedGoneAt.IsZero() {
+		status := "reachable"
+		if reach.Unreachable {
+			status = fmt.Sprintf("UNREACHABLE (%d probes unanswered)", reach.Failures)
+		} else if reach.Failures > 0 {
+			status = fmt.Sprintf("reachable, %d probe(s) unanswered", reach.Failures)
+		}
+		last := "never"
+		if !reach.LastAnswer.IsZero() {
+			last = fmt.Sprintf("%s (%s)", formatTimestamp(reach.LastAnswer), formatLatency(reach.RTT))
+		}
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprintf("  %s\n", detailLabel.Render("Neighbor Resolution:")))
+		b.WriteString(fmt.Sprintf("    Status:        %s\n", status))
+		b.WriteString(fmt.Sprintf("    Answered:      %d of %d probes\n", reach.Answers, reach.Probes))
+		b.WriteString(fmt.Sprintf("    Last Answer:   %s\n", last))
+	}
+
+	// Responsiveness to our Router Solicitations (--solicit)
+	if l, ok := m.rsLatency[r.Address]; ok {
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprintf("  %s\n", detailLabel.Render("RS → RA latency:")))
+		b.WriteString(fmt.Sprintf("    Last:          %s\n", formatLatency(l.Last)))
+		b.WriteString(fmt.Sprintf("    Min/Avg/Max:   %s / %s / %s\n", formatLatency(l.Min), formatLatency(l.Mean), formatLatency(l.Max)))
+		b.WriteString(fmt.Sprintf("    Answered:      %d (%d missed)\n", l.Responses, l.Missed))
+		recent := l.Samples[max(0, len(l.Samples)-rsRecentSamples):]
+		parts := make([]string, len(recent))
+		for i, s := range recent {
+			parts[i] = formatLatency(s.Latency)
+		}
+		b.WriteString(fmt.Sprintf("    Recent:        %s\n", strings.Join(parts, " ")))
+	}
+
 	return b.String()
 }
 
+// reachabilityByRouter indexes NS probe results by router address.
+func reachabilityByRouter(results []RouterReachability) map[string]RouterReachability {
+	result := make(map[string]RouterReachability, len(results))
+	for _, r := range results {
+		result[r.Router] = r
+	}
+	return result
+}
+
+// formatMACLookup renders a switch port lookup for the peer detail view.
+func formatMACLookup(l macLookup) string {
+	var parts []string
+	for _, loc := range l.locations {
+		parts = append(parts, loc.String())
+	}
+	s := strings.Join(parts, ", ")
+	switch {
+	case l.pending && s == "":
+		return "looking up..."
+	case l.err != nil && s == "":
+		return staleStyle.Render("lookup failed: " + strings.ReplaceAll(l.err.Error(), "\n", "; "))
+	case l.err != nil:
+		s += staleStyle.Render("  (some switches failed)")
+	case s == "":
+		return "not in any switch's MAC table"
+	}
+	return s
+}
+
+// maxOrphanDonesShown caps the MLD Done-without-Join lines on the Peers tab.
+const maxOrphanDonesShown = 5
+
+// rsRecentSamples is how many RS → RA round trips the router detail lists.
+const rsRecentSamples = 10
+
+// rsLatencyByRouter indexes RS → RA latency summaries by router address.
+func rsLatencyByRouter(latencies []RouterRSLatency) map[string]RouterRSLatency {
+	result := make(map[string]RouterRSLatency, len(latencies))
+	for _, l := range latencies {
+		result[l.Router] = l
+	}
+	return result
+}
+
 // --- Helper functions (unchanged) ---
 
 type multicastGroupEntry struct {
 	Group   string
+	Scope   int // see multicastScope
 	Members int
 }
 
 // aggregateMulticastGroups collects all multicast groups across peers,
-// counts unique members, and returns them sorted by member count descending.
+// counts unique members, and returns them ordered by scope (narrowest
+// first), then by member count descending.
 func aggregateMulticastGroups(stats []PeerSummary) []multicastGroupEntry {
 	counts := make(map[string]int)
 	for _, peer := range stats {
@@ -716,9 +2739,12 @@ func aggregateMulticastGroups(stats []PeerSummary) []multicastGroupEntry {
 
 	entries := make([]multicastGroupEntry, 0, len(counts))
 	for group, members := range counts {
-		entries = append(entries, multicastGroupEntry{Group: group, Members: members})
+		entries = append(entries, multicastGroupEntry{Group: group, Scope: multicastScope(group), Members: members})
 	}
 	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Scope != entries[j].Scope {
+			return entries[i].Scope < entries[j].Scope
+		}
 		if entries[i].Members != entries[j].Members {
 			return entries[i].Members > entries[j].Members
 		}
@@ -727,8 +2753,12 @@ func aggregateMulticastGroups(stats []PeerSummary) []multicastGroupEntry {
 	return entries
 }
 
-// multicastLabel returns a human-readable label for well-known multicast groups.
-func multicastLabel(group string) string {
+// multicastLabel returns a human-readable label for a multicast group,
+// checking the configured labels before the well-known groups.
+func multicastLabel(group string, custom []MulticastGroupLabel) string {
+	if label := customMulticastLabel(custom, group); label != "" {
+		return label
+	}
 	if label, ok := knownMulticastGroups[group]; ok {
 		return label
 	}
@@ -746,10 +2776,132 @@ func truncate(s string, maxLen int) string {
 	return s[:maxLen-3] + "..."
 }
 
+// idleCell renders the Idle column: time since last activity, with an
+// hourglass for stale peers.
+func idleCell(p PeerSummary, now time.Time) string {
+	idle := formatDuration(max(now.Sub(p.LastSeen), 0))
+	if p.Stale {
+		return "⌛" + idle
+	}
+	return idle
+}
+
+// formatMACHistory renders the MACs an address has used, oldest first, with
+// when each took over: "aa:.. → bb:.. at 14:02:03".
+func formatMACHistory(history []MACSighting) string {
+	var b strings.Builder
+	for i, h := range history {
+		if i == 0 {
+			b.WriteString(h.MAC)
+			continue
+		}
+		fmt.Fprintf(&b, " → %s at %s", h.MAC, formatTimestamp(h.Since))
+	}
+	return b.String()
+}
+
 func formatTimestamp(t time.Time) string {
 	return t.Format("15:04:05")
 }
 
+// byteRate averages n bytes over window, zero if window is.
+func byteRate(n int, window time.Duration) float64 {
+	if window <= 0 {
+		return 0
+	}
+	return float64(n) / window.Seconds()
+}
+
+// formatBytes renders a byte count or rate in at most four characters:
+// 512, 9.5, 1.2k, 34M.
+func formatBytes(v float64) string {
+	switch {
+	case v >= 10e6:
+		return fmt.Sprintf("%.0fM", v/1e6)
+	case v >= 1e6:
+		return fmt.Sprintf("%.1fM", v/1e6)
+	case v >= 10e3:
+		return fmt.Sprintf("%.0fk", v/1e3)
+	case v >= 1e3:
+		return fmt.Sprintf("%.1fk", v/1e3)
+	case v >= 10 || v == 0:
+		return fmt.Sprintf("%.0f", v)
+	default:
+		return fmt.Sprintf("%.1f", v)
+	}
+}
+
+// formatLatency renders a short latency with millisecond precision below one second.
+func formatLatency(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+// formatInterval formats a time between messages: as a latency under a
+// minute, where retransmissions a second apart matter, else as a duration.
+func formatInterval(d time.Duration) string {
+	if d < time.Minute {
+		return formatLatency(d)
+	}
+	return formatDuration(d)
+}
+
+// mldLatencyByGroup indexes latency summaries by group.
+func mldLatencyByGroup(latencies []MLDGroupLatency) map[string]MLDGroupLatency {
+	byGroup := make(map[string]MLDGroupLatency, len(latencies))
+	for _, l := range latencies {
+		byGroup[l.Group] = l
+	}
+	return byGroup
+}
+
+// switchNeighborsByInterface indexes LLDP/CDP neighbors by our interface.
+func switchNeighborsByInterface(neighbors []SwitchNeighbor) map[string]SwitchNeighbor {
+	byIface := make(map[string]SwitchNeighbor, len(neighbors))
+	for _, n := range neighbors {
+		byIface[n.Interface] = n
+	}
+	return byIface
+}
+
+// upstream returns the switch port a peer or router seen on iface (and
+// member port, if attributed) is behind, from LLDP/CDP.
+func (m Model) upstream(iface, port string) (SwitchNeighbor, bool) {
+	if n, ok := m.switchNeighbors[port]; ok && port != "" {
+		return n, true
+	}
+	n, ok := m.switchNeighbors[iface]
+	return n, ok && iface != ""
+}
+
+// ifaceLabel renders an interface for the tables, with the member port
+// appended when --member-ports attributed one (e.g. "br0/eth1").
+func ifaceLabel(iface, port string) string {
+	switch {
+	case iface == "":
+		return "-"
+	case port != "":
+		return iface + "/" + port
+	default:
+		return iface
+	}
+}
+
+// routePreferenceName names an RFC 4191 preference value; the reserved
+// value 2 is treated as medium, as the RFC requires.
+func routePreferenceName(p int) string {
+	switch p {
+	case 1:
+		return "high"
+	case 3:
+		return "low"
+	default:
+		return "med"
+	}
+}
+
 func formatDuration(d time.Duration) string {
 	if d >= time.Hour {
 		hours := d / time.Hour
@@ -769,3 +2921,24 @@ func formatDuration(d time.Duration) string {
 	}
 	return fmt.Sprintf("%ds", d/time.Second)
 }
+
+// segmentName labels a SegmentHealth interface.
+func segmentName(iface string) string {
+	if iface == "" {
+		return "(all)"
+	}
+	return iface
+}
+
+// healthScoreStyle colors an IPv6 health score: warning below 80,
+// critical below 50.
+func healthScoreStyle(score int) lipgloss.Style {
+	switch {
+	case score < 50:
+		return toastStyles[SeverityCritical]
+	case score < 80:
+		return toastStyles[SeverityWarning]
+	default:
+		return totalsStyle
+	}
+}
@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// rdnssSet is the order-independent form of an RDNSS server list.
+func rdnssSet(servers []string) string {
+	return strings.Join(slices.Sorted(slices.Values(servers)), ", ")
+}
+
+// rdnssConflicts returns the other routers on ri's interface that advertise
+// a different set of RDNSS servers than ri. Routers advertising none don't
+// conflict: hosts simply don't learn DNS servers from them.
+func (s *NDPStats) rdnssConflicts(ri RouterInfo) []RouterInfo {
+	if len(ri.RDNSS) == 0 {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	addr := canonicalAddr(ri.Address)
+	set := rdnssSet(ri.RDNSS)
+	var conflicts []RouterInfo
+	for _, r := range s.routers {
+		if r.Address == addr || r.Interface != ri.Interface || len(r.RDNSS) == 0 || rdnssSet(r.RDNSS) == set {
+			continue
+		}
+		conflicts = append(conflicts, *r)
+	}
+	slices.SortFunc(conflicts, func(a, b RouterInfo) int { return strings.Compare(a.Address, b.Address) })
+	return conflicts
+}
+
+// checkRDNSS raises dns_disagreement, once per pair of routers within the
+// window, when ri advertises different DNS servers than another router on
+// the same link. Hosts pick up either set depending on which RA they saw
+// last, or merge them in an order of their own, so names that only one set
+// resolves (split horizon, a dead server) fail intermittently.
+func (l *NDPListener) checkRDNSS(ri RouterInfo, port string) {
+	for _, other := range l.cfg.Stats.rdnssConflicts(ri) {
+		a, b := ri.Address, other.Address
+		if b < a {
+			a, b = b, a
+		}
+		l.raiseAlertOnce("dns_disagreement|"+a+"|"+b, Alert{
+			Severity: SeverityWarning,
+			Category: "dns_disagreement",
+			Source:   ri.Address,
+			Message: fmt.Sprintf("routers %s and %s advertise different DNS servers (%s vs %s): hosts may use either",
+				ri.Address, other.Address, rdnssSet(ri.RDNSS), rdnssSet(other.RDNSS)),
+			Port: port,
+		})
+	}
+}
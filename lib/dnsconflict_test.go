@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestDNSDisagreement(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	ra := func(src string, servers ...string) {
+		var ips []net.IP
+		for _, s := range servers {
+			ips = append(ips, net.ParseIP(s))
+		}
+		l.handle(received{src: netip.MustParseAddr(src), hopLimit: 255,
+			payload: buildRAFull(64, false, false, 1800, nil, buildRDNSSOption(3600, ips...))})
+	}
+	disagreements := func() int {
+		n := 0
+		for _, a := range stats.GetAlerts() {
+			if a.Category == "dns_disagreement" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The same servers in another order agree.
+	ra("fe80::1", "2001:db8::53", "2001:db8::54")
+	ra("fe80::2", "2001:db8::54", "2001:db8::53")
+	l.handle(received{src: netip.MustParseAddr("fe80::3"), hopLimit: 255, payload: buildRAFull(64, false, false, 1800, nil)})
+	if n := disagreements(); n != 0 {
+		t.Fatalf("%d alerts for agreeing routers", n)
+	}
+
+	// A third set conflicts with both, once per pair however often it repeats.
+	ra("fe80::4", "2001:db8::99")
+	ra("fe80::4", "2001:db8::99")
+	if n := disagreements(); n != 2 {
+		t.Errorf("%d alerts, want one per conflicting pair", n)
+	}
+}
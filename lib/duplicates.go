@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"hash/fnv"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+const (
+	// duplicateWindow is how close together two identical messages must
+	// arrive to count as one packet seen twice. Hosts never legitimately
+	// repeat an NDP or MLD message this quickly; a loop or a bridge
+	// forwarding back onto a segment does.
+	duplicateWindow = 20 * time.Millisecond
+	// duplicateAlertMin is how many duplicates an interface must see within
+	// the window before a duplicate_packets alert is raised.
+	duplicateAlertMin = 10
+)
+
+// InterfaceDuplicates is how many of the NDP/MLD messages captured on one
+// interface were copies of a message seen moments before, on that interface
+// or another.
+type InterfaceDuplicates struct {
+	Interface  string  `json:"interface"` // "" if unknown
+	Messages   int     `json:"messages"`  // since startup
+	Duplicates int     `json:"duplicates"`
+	Ratio      float64 `json:"ratio"`  // Duplicates / Messages
+	Recent     int     `json:"recent"` // duplicates within the window
+}
+
+// duplicateArrival is one message fingerprint awaiting copies.
+type duplicateArrival struct {
+	hash uint64
+	at   time.Time
+}
+
+// ifaceDuplicates accumulates InterfaceDuplicates for one interface.
+type ifaceDuplicates struct {
+	messages   int
+	duplicates int
+	recent     []time.Time // duplicate arrivals, oldest first
+}
+
+// messageHash fingerprints a message by its addresses and ICMPv6 bytes. The
+// hop limit and interface are left out: copies differ in where they arrive,
+// not in what they carry.
+func messageHash(src, dst netip.Addr, payload []byte) uint64 {
+	h := fnv.New64a()
+	a := src.As16()
+	h.Write(a[:])
+	if dst.IsValid() {
+		a = dst.As16()
+		h.Write(a[:])
+	}
+	h.Write(payload)
+	return h.Sum64()
+}
+
+// RecordArrival counts a message with fingerprint hash captured on iface at
+// now and reports whether the same message arrived within duplicateWindow
+// before, along with how many duplicates iface has seen within the window.
+func (s *NDPStats) RecordArrival(iface string, hash uint64, now time.Time) (duplicate bool, recent int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := now.Add(-duplicateWindow)
+	drop := 0
+	for drop < len(s.arrivals) && !s.arrivals[drop].at.After(cutoff) {
+		drop++
+	}
+	s.arrivals = s.arrivals[drop:]
+	for _, a := range s.arrivals {
+		if a.hash == hash {
+			duplicate = true
+			break
+		}
+	}
+	s.arrivals = append(s.arrivals, duplicateArrival{hash: hash, at: now})
+
+	d, ok := s.duplicates[iface]
+	if !ok {
+		d = &ifaceDuplicates{}
+		s.duplicates[iface] = d
+	}
+	d.messages++
+	windowCutoff := now.Add(-s.window)
+	drop = 0
+	for drop < len(d.recent) && !d.recent[drop].After(windowCutoff) {
+		drop++
+	}
+	d.recent = d.recent[drop:]
+	if duplicate {
+		d.duplicates++
+		d.recent = append(d.recent, now)
+	}
+	return duplicate, len(d.recent)
+}
+
+// DedupStats is the state of duplicate dropping (see SetDedup).
+type DedupStats struct {
+	Enabled bool `json:"enabled"`
+	// Dropped counts the copies dropped since startup.
+	Dropped int `json:"dropped"`
+}
+
+// SetDedup turns dropping of duplicate packets on or off. When on, a
+// message that repeats one captured within duplicateWindow before, on any
+// interface, is counted as a duplicate and then dropped, so bridged or
+// mirrored captures don't count every message twice.
+func (s *NDPStats) SetDedup(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dedup.Enabled = on
+}
+
+// Dedup returns the state of duplicate dropping.
+func (s *NDPStats) Dedup() DedupStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dedup
+}
+
+// dropDuplicate reports whether a duplicate is to be dropped, counting it
+// if so.
+func (s *NDPStats) dropDuplicate() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dedup.Enabled {
+		s.dedup.Dropped++
+	}
+	return s.dedup.Enabled
+}
+
+// GetDuplicates returns the duplicate counts of every interface messages
+// were captured on, sorted by interface.
+func (s *NDPStats) GetDuplicates() []InterfaceDuplicates {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.duplicatesLocked(s.now())
+}
+
+// duplicatesLocked computes GetDuplicates. Callers must hold s.mu.
+func (s *NDPStats) duplicatesLocked(now time.Time) []InterfaceDuplicates {
+	cutoff := now.Add(-s.window)
+	result := make([]InterfaceDuplicates, 0, len(s.duplicates))
+	for iface, d := range s.duplicates {
+		id := InterfaceDuplicates{Interface: iface, Messages: d.messages, Duplicates: d.duplicates}
+		if d.messages > 0 {
+			id.Ratio = float64(d.duplicates) / float64(d.messages)
+		}
+		for _, t := range d.recent {
+			if t.After(cutoff) {
+				id.Recent++
+			}
+		}
+		result = append(result, id)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Interface < result[j].Interface })
+	return result
+}
@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordArrival(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	now := time.Now()
+	if dup, _ := stats.RecordArrival("eth0", 1, now); dup {
+		t.Error("first arrival reported as duplicate")
+	}
+	if dup, recent := stats.RecordArrival("eth1", 1, now.Add(2*time.Millisecond)); !dup || recent != 1 {
+		t.Errorf("copy on another interface = %v, %d; want duplicate, 1", dup, recent)
+	}
+	if dup, _ := stats.RecordArrival("eth0", 2, now.Add(3*time.Millisecond)); dup {
+		t.Error("different message reported as duplicate")
+	}
+	if dup, _ := stats.RecordArrival("eth0", 1, now.Add(time.Second)); dup {
+		t.Error("repeat a second later reported as duplicate")
+	}
+
+	got := stats.GetDuplicates()
+	if len(got) != 2 || got[0].Interface != "eth0" || got[0].Messages != 3 || got[0].Duplicates != 0 {
+		t.Fatalf("duplicates = %+v", got)
+	}
+	if d := got[1]; d.Interface != "eth1" || d.Messages != 1 || d.Duplicates != 1 || d.Ratio != 1 || d.Recent != 1 {
+		t.Errorf("eth1 = %+v", d)
+	}
+}
+
+func TestHandlePacket_DuplicateAlert(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	for i := 0; i < duplicateAlertMin; i++ {
+		target := net.ParseIP(fmt.Sprintf("2001:db8::%x", i+1)) // a different message each round
+		pkt := buildIPv6Packet("fe80::1", "ff02::1", 255, nil, nhICMPv6, buildNA(target, mac))
+		l.handlePacket(pkt, 0, "eth1")
+		l.handlePacket(pkt, 0, "eth2")
+	}
+
+	dups := stats.GetDuplicates()
+	if len(dups) != 2 || dups[1].Interface != "eth2" || dups[1].Duplicates != duplicateAlertMin || dups[0].Duplicates != 0 {
+		t.Fatalf("duplicates = %+v, want every eth2 copy counted", dups)
+	}
+	alerts := stats.GetAlerts()
+	if len(alerts) != 1 || alerts[0].Category != "duplicate_packets" || !strings.Contains(alerts[0].Message, "on eth2") {
+		t.Errorf("alerts = %+v, want one duplicate_packets alert for eth2", alerts)
+	}
+}
+
+func TestHandlePacket_Dedup(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.SetDedup(true)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	pkt := buildIPv6Packet("fe80::1", "ff02::1", 255, nil, nhICMPv6, buildNA(net.ParseIP("2001:db8::1"), mac))
+	l.handlePacket(pkt, 0, "eth1")
+	l.handlePacket(pkt, 0, "eth2")
+
+	if d := stats.Dedup(); !d.Enabled || d.Dropped != 1 {
+		t.Errorf("dedup = %+v, want the copy dropped", d)
+	}
+	peers := stats.GetStats()
+	if len(peers) != 1 || peers[0].Total != 1 {
+		t.Fatalf("peers = %+v, want the message counted once", peers)
+	}
+	if dups := stats.GetDuplicates(); len(dups) != 2 || dups[1].Duplicates != 1 {
+		t.Errorf("duplicates = %+v, want the copy still counted as a duplicate", dups)
+	}
+
+	stats.SetDedup(false)
+	l.handlePacket(pkt, 0, "eth2")
+	if peers := stats.GetStats(); peers[0].Total != 2 {
+		t.Errorf("total = %d with dedup off, want the copy recorded", peers[0].Total)
+	}
+}
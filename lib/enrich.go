@@ -0,0 +1,270 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEnrichInterval = time.Minute
+	defaultEnrichTTL      = 30 * time.Minute
+
+	// dnsLookupTimeout bounds a single reverse lookup.
+	dnsLookupTimeout = 2 * time.Second
+	// maxDNSLookupsPerCycle caps resolver load; the rest wait for the next cycle.
+	maxDNSLookupsPerCycle = 32
+)
+
+// Enrichment is extra context about a peer from slower sources than the
+// packets themselves: reverse DNS, the MAC vendor and the operator's inventory.
+// It is attached to the peer everywhere the peer is reported, and to the
+// events and alerts the peer causes.
+type Enrichment struct {
+	Hostname   string   `json:"hostname,omitempty"`    // reverse DNS (PTR)
+	Vendor     string   `json:"vendor,omitempty"`      // from the OUI database, or "(random)"
+	Name       string   `json:"name,omitempty"`        // from the config inventory
+	DeviceType string   `json:"device_type,omitempty"` // from the config inventory
+	Trusted    bool     `json:"trusted,omitempty"`     // from the config inventory
+	Tags       []string `json:"tags,omitempty"`        // from the config inventory
+	// Reputation is what the reputation sources that know the peer say.
+	Reputation []ReputationVerdict `json:"reputation,omitempty"`
+}
+
+// IsZero reports whether nothing is known about the peer.
+func (e Enrichment) IsZero() bool {
+	return e.Hostname == "" && e.Vendor == "" && e.Name == "" && e.DeviceType == "" && !e.Trusted && len(e.Tags) == 0 && len(e.Reputation) == 0
+}
+
+// Enricher refreshes enrichments for all known peers on its own cadence,
+// separate from the table refresh, and caches results so resolvers are only
+// asked again once an entry is older than the TTL.
+type Enricher struct {
+	stats      *NDPStats
+	logger     *slog.Logger
+	interval   time.Duration
+	ttl        time.Duration
+	dns        bool
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+	oui        map[string]string         // key: first three MAC octets, "aa:bb:cc"
+	inventory  map[string]InventoryEntry // key: lower-case MAC or address
+	sinks      []Sink                    // for reputation alerts
+
+	cache map[string]dnsCacheEntry // key: peer address; only touched by Run
+
+	reputationSources []ReputationSource
+	repCache          map[string]reputationCacheEntry // key: source|address; only touched by Run
+}
+
+type dnsCacheEntry struct {
+	hostname string // "" for a cached negative answer
+	fetched  time.Time
+}
+
+// EnricherConfig configures an Enricher.
+type EnricherConfig struct {
+	Stats  *NDPStats
+	Logger *slog.Logger
+	Config EnrichmentConfig
+	// Sinks receive reputation alerts.
+	Sinks []Sink
+	// Reputation are extra reputation sources, looked up after the ones
+	// in Config.
+	Reputation []ReputationSource
+}
+
+// NewEnricher loads the OUI database and reputation sources (if configured)
+// and returns an Enricher.
+func NewEnricher(cfg EnricherConfig) (*Enricher, error) {
+	e := &Enricher{
+		stats:      cfg.Stats,
+		logger:     cfg.Logger,
+		interval:   cfg.Config.Interval,
+		ttl:        cfg.Config.TTL,
+		dns:        cfg.Config.DNS,
+		lookupAddr: net.DefaultResolver.LookupAddr,
+		inventory:  make(map[string]InventoryEntry),
+		sinks:      cfg.Sinks,
+		cache:      make(map[string]dnsCacheEntry),
+		repCache:   make(map[string]reputationCacheEntry),
+	}
+	if e.interval <= 0 {
+		e.interval = defaultEnrichInterval
+	}
+	if e.ttl <= 0 {
+		e.ttl = defaultEnrichTTL
+	}
+	for k, v := range cfg.Config.Inventory {
+		e.inventory[canonicalAddr(strings.ToLower(k))] = v
+	}
+	if cfg.Config.OUIFile != "" {
+		oui, err := loadOUIFile(cfg.Config.OUIFile)
+		if err != nil {
+			return nil, err
+		}
+		e.oui = oui
+	}
+	for i, rc := range cfg.Config.Reputation {
+		src, err := NewReputationSource(rc)
+		if err != nil {
+			return nil, fmt.Errorf("reputation[%d]: %w", i, err)
+		}
+		e.reputationSources = append(e.reputationSources, src)
+	}
+	e.reputationSources = append(e.reputationSources, cfg.Reputation...)
+	return e, nil
+}
+
+// Run enriches immediately and then every interval until ctx is cancelled.
+func (e *Enricher) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.refresh(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh computes enrichments for every current peer and stores them in stats.
+func (e *Enricher) refresh(ctx context.Context, now time.Time) {
+	peers := e.stats.GetStats()
+	lookups := 0
+	repLookups := make(map[string]int)
+	seen := make(map[string]bool, len(peers))
+
+	for _, p := range peers {
+		seen[p.Address] = true
+		inv := e.inventoryEntry(p)
+		en := Enrichment{
+			Vendor:     e.vendor(p.MAC),
+			Name:       inv.Name,
+			DeviceType: inv.Type,
+			Trusted:    inv.Trusted,
+			Tags:       inv.Tags,
+		}
+
+		if e.dns {
+			entry, ok := e.cache[p.Address]
+			if (!ok || now.Sub(entry.fetched) >= e.ttl) && lookups < maxDNSLookupsPerCycle && ctx.Err() == nil {
+				lookups++
+				entry = dnsCacheEntry{hostname: e.lookupPTR(ctx, unzoned(p.Address)), fetched: now}
+				e.cache[p.Address] = entry
+			}
+			en.Hostname = entry.hostname
+		}
+
+		en.Reputation = e.reputation(ctx, p, now, repLookups)
+
+		e.stats.SetEnrichment(p.Address, en)
+		e.alertReputation(p, en.Reputation)
+	}
+
+	// Forget cached answers for peers that are gone and have expired anyway.
+	for addr, entry := range e.cache {
+		if !seen[addr] && now.Sub(entry.fetched) >= e.ttl {
+			delete(e.cache, addr)
+		}
+	}
+	for key, entry := range e.repCache {
+		_, addr, _ := strings.Cut(key, "|")
+		if !seen[addr] && now.Sub(entry.fetched) >= e.ttl {
+			delete(e.repCache, key)
+		}
+	}
+
+	if lookups > 0 {
+		e.logger.Debug("enrichment refreshed", "peers", len(peers), "dns_lookups", lookups)
+	}
+}
+
+func (e *Enricher) lookupPTR(ctx context.Context, addr string) string {
+	ctx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+	defer cancel()
+
+	names, err := e.lookupAddr(ctx, addr)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// vendor returns the OUI vendor for mac, "(random)" for a locally
+// administered address, or "" if unknown.
+func (e *Enricher) vendor(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) < 3 {
+		return ""
+	}
+	if hw[0]&0x02 != 0 {
+		return "(random)"
+	}
+	return e.oui[fmt.Sprintf("%02x:%02x:%02x", hw[0], hw[1], hw[2])]
+}
+
+// inventoryEntry looks the peer up by address first, then by MAC. A
+// link-local peer matches an entry for its address on a given link
+// ("fe80::1%eth0") before one for the address on any link.
+func (e *Enricher) inventoryEntry(p PeerSummary) InventoryEntry {
+	if entry, ok := e.inventory[strings.ToLower(p.Address)]; ok {
+		return entry
+	}
+	if entry, ok := e.inventory[strings.ToLower(unzoned(p.Address))]; ok {
+		return entry
+	}
+	if p.MAC != "" {
+		return e.inventory[strings.ToLower(p.MAC)]
+	}
+	return InventoryEntry{}
+}
+
+// loadOUIFile reads a Wireshark "manuf" file or an IEEE oui.txt file.
+// Only 24-bit prefixes are used; longer MA-M/MA-S assignments are skipped.
+func loadOUIFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open oui file: %w", err)
+	}
+	defer f.Close()
+
+	oui := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		prefix := strings.ToLower(strings.ReplaceAll(fields[0], "-", ":"))
+		if len(prefix) != 8 || prefix[2] != ':' || prefix[5] != ':' || len(fields) < 2 {
+			continue
+		}
+
+		var vendor string
+		if i := strings.Index(line, "(hex)"); i >= 0 {
+			// oui.txt: "00-00-0C   (hex)		CISCO SYSTEMS, INC."
+			vendor = strings.TrimSpace(line[i+len("(hex)"):])
+		} else if parts := strings.Split(line, "\t"); len(parts) >= 3 {
+			// manuf: "00:00:0C	Cisco	Cisco Systems, Inc" — prefer the long name
+			vendor = strings.TrimSpace(parts[2])
+		} else {
+			vendor = strings.TrimSpace(strings.Join(fields[1:], " "))
+		}
+		if vendor != "" {
+			oui[prefix] = vendor
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read oui file: %w", err)
+	}
+	return oui, nil
+}
@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadOUIFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oui")
+	body := "# comment\n" +
+		"00:00:0C\tCisco\tCisco Systems, Inc\n" +
+		"DC:A6:32\tRaspberr\tRaspberry Pi Trading Ltd\n" +
+		"00:1B:C5:00:00:00/36\tConvergi\tConverging Systems Inc.\n" +
+		"3C-22-FB   (hex)\t\tApple, Inc.\n" +
+		"3C22FB     (base 16)\t\tApple, Inc.\n"
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oui, err := loadOUIFile(path)
+	if err != nil {
+		t.Fatalf("loadOUIFile: %v", err)
+	}
+	want := map[string]string{
+		"00:00:0c": "Cisco Systems, Inc",
+		"dc:a6:32": "Raspberry Pi Trading Ltd",
+		"3c:22:fb": "Apple, Inc.",
+	}
+	if len(oui) != len(want) {
+		t.Errorf("loaded %d entries, want %d: %v", len(oui), len(want), oui)
+	}
+	for k, v := range want {
+		if oui[k] != v {
+			t.Errorf("oui[%s] = %q, want %q", k, oui[k], v)
+		}
+	}
+}
+
+func TestEnricher_Refresh(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_advertisement")
+	stats.RecordMAC("fe80::1", "dc:a6:32:01:02:03")
+	stats.RecordMessage("fe80::2", "neighbor_solicitation")
+	stats.RecordMAC("fe80::2", "02:42:ac:11:00:02")
+
+	e, err := NewEnricher(EnricherConfig{
+		Stats:  stats,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Config: EnrichmentConfig{
+			DNS:       true,
+			TTL:       time.Minute,
+			Inventory: map[string]InventoryEntry{"DC:A6:32:01:02:03": {Name: "pi-kitchen", Type: "sensor", Trusted: true}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.oui = map[string]string{"dc:a6:32": "Raspberry Pi Trading Ltd"}
+	lookups := 0
+	e.lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		lookups++
+		return []string{addr + ".example.net."}, nil
+	}
+
+	now := time.Now()
+	e.refresh(context.Background(), now)
+
+	byAddr := make(map[string]PeerSummary)
+	for _, p := range stats.GetStats() {
+		byAddr[p.Address] = p
+	}
+	p1 := byAddr["fe80::1"]
+	if p1.Vendor != "Raspberry Pi Trading Ltd" || p1.Name != "pi-kitchen" || p1.Hostname != "fe80::1.example.net" || p1.DeviceType != "sensor" || !p1.Trusted {
+		t.Errorf("fe80::1 enrichment = %+v", p1.Enrichment)
+	}
+	if v := byAddr["fe80::2"].Vendor; v != "(random)" {
+		t.Errorf("fe80::2 vendor = %q, want (random)", v)
+	}
+	if lookups != 2 {
+		t.Errorf("lookups = %d, want 2", lookups)
+	}
+
+	// Within the TTL answers come from the cache.
+	e.refresh(context.Background(), now.Add(30*time.Second))
+	if lookups != 2 {
+		t.Errorf("lookups after cached refresh = %d, want 2", lookups)
+	}
+
+	// After the TTL they are refreshed.
+	e.refresh(context.Background(), now.Add(2*time.Minute))
+	if lookups != 4 {
+		t.Errorf("lookups after TTL = %d, want 4", lookups)
+	}
+}
+
+func TestEnrichmentPropagates(t *testing.T) {
+	dir := t.TempDir()
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_solicitation")
+	stats.SetEnrichment("fe80::1", Enrichment{Name: "pi-kitchen", DeviceType: "sensor", Trusted: true, Tags: []string{"iot"}})
+
+	sink, err := NewNDJSONSink(filepath.Join(dir, "events.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewNDPListener(NDPListenerConfig{
+		Stats:  stats,
+		Sinks:  []Sink{sink},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	l.handle(received{src: netip.MustParseAddr("fe80::1"), dst: netip.MustParseAddr("ff02::2"), hopLimit: 255, payload: buildRS(nil)})
+	l.raiseAlert(Alert{Severity: SeverityWarning, Category: "test", Source: "fe80::1"})
+	sink.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, "events.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), `"peer":{"name":"pi-kitchen","device_type":"sensor","trusted":true,"tags":["iot"]}`); n != 2 {
+		t.Errorf("event and alert carry the enrichment %d times, want 2:\n%s", n, data)
+	}
+
+	var metrics strings.Builder
+	writeMetrics(&metrics, stats.Snapshot(), nil)
+	if want := `ndpeekr_peer_info{address="fe80::1",mac="",hostname="",vendor="",name="pi-kitchen",device_type="sensor",trusted="true",tags="iot"} 1`; !strings.Contains(metrics.String(), want) {
+		t.Errorf("metrics missing %s", want)
+	}
+
+	db, err := OpenHistoryDB(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	snap := stats.Snapshot()
+	if err := db.Record(snap); err != nil {
+		t.Fatal(err)
+	}
+	sample, err := db.LoadSample(snap.Taken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sample.Peers) != 1 || sample.Peers[0].DeviceType != "sensor" || !sample.Peers[0].Trusted || len(sample.Peers[0].Tags) != 1 {
+		t.Errorf("history peers = %+v, want the inventory fields", sample.Peers)
+	}
+}
@@ -0,0 +1,39 @@
+package lib
+
+import "time"
+
+// Event is a single decoded NDP/MLD message as delivered to sinks.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"` // e.g. "router_advertisement"
+	Type      int       `json:"type"` // ICMPv6 type
+	Code      int       `json:"code"`
+	Src       string    `json:"src"`
+	Dst       string    `json:"dst,omitempty"`
+	Interface string    `json:"interface,omitempty"`
+	Port      string    `json:"port,omitempty"` // bridge or bond member port (--member-ports)
+	HopLimit  int       `json:"hop_limit,omitempty"`
+	Length    int       `json:"length"` // ICMPv6 payload bytes
+	MAC       string    `json:"mac,omitempty"`
+	Target    string    `json:"target,omitempty"` // NS/NA target address
+	Groups    []string  `json:"groups,omitempty"` // MLD report/done group addresses
+	// Options names the NDP options the message carried, in order of first
+	// appearance (e.g. "slla", "prefix", "type-99"); unset on the fast path.
+	Options []string `json:"options,omitempty"`
+	// MLDRecords are the records of an MLDv2 Report, one per entry in Groups.
+	MLDRecords []MLDRecord `json:"mld_records,omitempty"`
+	Router     *RouterInfo `json:"router,omitempty"` // decoded RA contents
+	// ExtHeaders lists IPv6 extension header types (e.g. 0 = Hop-by-Hop) in
+	// front of the message; only packet-level capture sees them.
+	ExtHeaders []int `json:"ext_headers,omitempty"`
+	// RouterAlert is the Router Alert check for MLD at packet level: "ok",
+	// or the reason the message failed (e.g. "no_router_alert").
+	RouterAlert string `json:"router_alert,omitempty"`
+	// Peer is what enrichment knows about Src, if anything.
+	Peer *Enrichment `json:"peer,omitempty"`
+	// Node is the --node-name of the instance that saw the message.
+	Node string `json:"node,omitempty"`
+	// Via is ViaMirror for messages observed on a mirror port rather than
+	// sent to or through this host.
+	Via string `json:"via,omitempty"`
+}
@@ -0,0 +1,244 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultEvidenceEvents is how many recent events are kept to draw on.
+	defaultEvidenceEvents = 2000
+	// defaultEvidenceCooldown limits bundles per alert category and source.
+	defaultEvidenceCooldown = 5 * time.Minute
+)
+
+// PacketSource is a store of recently captured raw packets, such as the pcap
+// ring, that evidence bundles draw on.
+type PacketSource interface {
+	// WritePcap writes the stored packets to or from addr to w as a pcap
+	// file and returns how many it wrote.
+	WritePcap(w io.Writer, addr string) (int, error)
+}
+
+// EvidenceRecorderConfig configures an EvidenceRecorder.
+type EvidenceRecorderConfig struct {
+	Stats   *NDPStats    // required; supplies the peer and router state
+	Logger  *slog.Logger // required
+	Config  EvidenceConfig
+	Packets PacketSource // optional; adds the raw packets to bundles
+	// Privacy, when set, drops identifiers from the peer and router state,
+	// and with DropMACs leaves out the raw packets. Events and alerts are
+	// expected to arrive through PrivateSinks already.
+	Privacy *PrivacyConfig
+}
+
+// EvidenceRecorder writes an evidence bundle when a high-severity alert
+// fires: the alert, the state of the peer or router it is about, recent
+// events involving it and, with a PacketSource, its raw packets. It is a
+// Sink so it sees the same events and alerts as the other outputs.
+type EvidenceRecorder struct {
+	cfg EvidenceRecorderConfig
+
+	mu     sync.Mutex
+	events []Event // ring of recent events, next is the oldest once full
+	next   int
+	last   map[string]time.Time // last bundle per category and source
+	wg     sync.WaitGroup
+}
+
+// NewEvidenceRecorder creates the bundle directory and returns a recorder.
+func NewEvidenceRecorder(cfg EvidenceRecorderConfig) (*EvidenceRecorder, error) {
+	if cfg.Config.Events <= 0 {
+		cfg.Config.Events = defaultEvidenceEvents
+	}
+	if cfg.Config.Cooldown <= 0 {
+		cfg.Config.Cooldown = defaultEvidenceCooldown
+	}
+	if cfg.Config.MinSeverity == nil {
+		sev := SeverityCritical
+		cfg.Config.MinSeverity = &sev
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if err := os.MkdirAll(cfg.Config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("evidence dir: %w", err)
+	}
+	return &EvidenceRecorder{
+		cfg:    cfg,
+		events: make([]Event, 0, cfg.Config.Events),
+		last:   make(map[string]time.Time),
+	}, nil
+}
+
+// WriteEvent remembers ev for later bundles.
+func (r *EvidenceRecorder) WriteEvent(ev Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.events) < cap(r.events) {
+		r.events = append(r.events, ev)
+		return nil
+	}
+	r.events[r.next] = ev
+	r.next = (r.next + 1) % len(r.events)
+	return nil
+}
+
+// WriteAlert starts writing a bundle for a if it is severe enough and no
+// bundle for the same category and source was written within the cooldown.
+func (r *EvidenceRecorder) WriteAlert(a Alert) error {
+	if a.Severity < *r.cfg.Config.MinSeverity {
+		return nil
+	}
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+
+	r.mu.Lock()
+	key := a.Category + "|" + a.Source
+	if last, ok := r.last[key]; ok && a.Time.Sub(last) < r.cfg.Config.Cooldown {
+		r.mu.Unlock()
+		return nil
+	}
+	r.last[key] = a.Time
+	events := r.relatedLocked(a.Source)
+	r.mu.Unlock()
+
+	// The state is copied now, before it moves on, but written in the
+	// background so capture never waits on the disk.
+	var peer *PeerSummary
+	for _, p := range r.cfg.Stats.GetStats() {
+		if p.Address == a.Source {
+			p = r.cfg.Privacy.Peer(p)
+			peer = &p
+			break
+		}
+	}
+	var router *RouterInfo
+	for _, ri := range r.cfg.Stats.GetRouters() {
+		if ri.Address == a.Source {
+			ri = r.cfg.Privacy.Router(ri)
+			router = &ri
+			break
+		}
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		dir, err := r.writeBundle(a, peer, router, events)
+		if err != nil {
+			r.cfg.Logger.Warn("failed to write evidence bundle", "category", a.Category, "src", a.Source, "err", err)
+			return
+		}
+		r.cfg.Logger.Info("wrote evidence bundle", "category", a.Category, "src", a.Source, "dir", dir)
+	}()
+	return nil
+}
+
+// relatedLocked returns the remembered events sent by or about addr, oldest
+// first. Callers must hold r.mu.
+func (r *EvidenceRecorder) relatedLocked(addr string) []Event {
+	var related []Event
+	for i := range r.events {
+		ev := r.events[(r.next+i)%len(r.events)]
+		if ev.Src == addr || ev.Target == addr || ev.Dst == addr {
+			related = append(related, ev)
+		}
+	}
+	return related
+}
+
+// writeBundle writes the bundle to a timestamped directory in Dir and
+// returns its path. The directory is assembled under a temporary name and
+// renamed into place, so a bundle that exists is complete.
+func (r *EvidenceRecorder) writeBundle(a Alert, peer *PeerSummary, router *RouterInfo, events []Event) (string, error) {
+	name := fmt.Sprintf("ndpeekr-evidence-%s-%s-%s", a.Time.Format("20060102-150405.000"), a.Category,
+		strings.NewReplacer(":", "_", "/", "_").Replace(a.Source))
+	dir := filepath.Join(r.cfg.Config.Dir, name)
+	tmp := dir + ".tmp"
+	if err := os.Mkdir(tmp, 0755); err != nil {
+		return "", err
+	}
+	if err := r.fillBundle(tmp, a, peer, router, events); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return dir, nil
+}
+
+func (r *EvidenceRecorder) fillBundle(dir string, a Alert, peer *PeerSummary, router *RouterInfo, events []Event) error {
+	writeJSON := func(file string, v any) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", file, err)
+		}
+		return os.WriteFile(filepath.Join(dir, file), data, 0644)
+	}
+
+	if err := writeJSON("alert.json", a); err != nil {
+		return err
+	}
+	if peer != nil {
+		if err := writeJSON("peer.json", peer); err != nil {
+			return err
+		}
+	}
+	if router != nil {
+		if err := writeJSON("router.json", router); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(filepath.Join(dir, "events.ndjson"))
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if r.cfg.Packets == nil || a.Source == "" || (r.cfg.Privacy != nil && r.cfg.Privacy.DropMACs) {
+		return nil
+	}
+	f, err = os.Create(filepath.Join(dir, "packets.pcap"))
+	if err != nil {
+		return err
+	}
+	n, err := r.cfg.Packets.WritePcap(f, a.Source)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("write packets: %w", err)
+	}
+	if n == 0 {
+		return os.Remove(filepath.Join(dir, "packets.pcap"))
+	}
+	return nil
+}
+
+// Close waits for bundles being written.
+func (r *EvidenceRecorder) Close() error {
+	r.wg.Wait()
+	return nil
+}
@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePackets is a PacketSource that writes one line per call.
+type fakePackets struct{}
+
+func (fakePackets) WritePcap(w io.Writer, addr string) (int, error) {
+	_, err := fmt.Fprintf(w, "packets for %s", addr)
+	return 1, err
+}
+
+func TestEvidenceRecorder(t *testing.T) {
+	dir := t.TempDir()
+	stats := NewNDPStats(time.Minute)
+	stats.RecordMessage("fe80::2", "router_advertisement")
+	stats.RecordRouter(RouterInfo{Address: "fe80::2", MTU: 1500})
+
+	r, err := NewEvidenceRecorder(EvidenceRecorderConfig{
+		Stats:   stats,
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Config:  EvidenceConfig{Dir: dir, Events: 3},
+		Packets: fakePackets{},
+	})
+	if err != nil {
+		t.Fatalf("NewEvidenceRecorder: %v", err)
+	}
+
+	now := time.Now()
+	for i, ev := range []Event{
+		{Kind: "router_advertisement", Src: "fe80::2"}, // pushed out of the ring
+		{Kind: "neighbor_solicitation", Src: "fe80::9", Target: "fe80::2"},
+		{Kind: "neighbor_solicitation", Src: "fe80::9", Target: "fe80::9"},
+		{Kind: "router_advertisement", Src: "fe80::2"},
+	} {
+		ev.Time = now.Add(time.Duration(i) * time.Second)
+		r.WriteEvent(ev)
+	}
+	for _, a := range []Alert{
+		{Time: now, Severity: SeverityWarning, Category: "oversized_ra", Source: "fe80::2"}, // below min_severity
+		{Time: now, Severity: SeverityCritical, Category: "rogue_ra", Source: "fe80::2"},
+		{Time: now.Add(time.Minute), Severity: SeverityCritical, Category: "rogue_ra", Source: "fe80::2"}, // cooldown
+	} {
+		if err := r.WriteAlert(a); err != nil {
+			t.Fatalf("WriteAlert: %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), "-rogue_ra-fe80__2") {
+		t.Fatalf("bundles = %v, want one rogue_ra bundle", entries)
+	}
+	bundle := filepath.Join(dir, entries[0].Name())
+
+	for _, name := range []string{"alert.json", "peer.json", "router.json", "packets.pcap"} {
+		if _, err := os.Stat(filepath.Join(bundle, name)); err != nil {
+			t.Errorf("bundle is missing %s: %v", name, err)
+		}
+	}
+	var router RouterInfo
+	if data, err := os.ReadFile(filepath.Join(bundle, "router.json")); err != nil || json.Unmarshal(data, &router) != nil || router.MTU != 1500 {
+		t.Errorf("router.json = %+v, want the router's state", router)
+	}
+
+	f, err := os.Open(filepath.Join(bundle, "events.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var kinds []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var ev Event
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatal(err)
+		}
+		kinds = append(kinds, ev.Kind)
+	}
+	if want := []string{"neighbor_solicitation", "router_advertisement"}; strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", kinds, want)
+	}
+}
@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportTables are the history tables Export can dump, in output order.
+var ExportTables = []string{"peers", "routers", "alerts", "counters"}
+
+// Export writes the rows of table recorded in [from, to] as CSV with a header
+// row. Times are RFC 3339 in UTC; peer message counts get one column per type.
+// A non-nil anon anonymizes the addresses and MACs in every field.
+func (h *HistoryDB) Export(w io.Writer, table string, from, to time.Time, anon *Anonymizer) error {
+	var (
+		header []string
+		query  string
+		row    func(*sql.Rows) ([]string, error)
+	)
+	switch table {
+	case "peers":
+		header = []string{"time", "address", "mac", "interface", "first_seen", "last_seen", "total"}
+		for _, kind := range msgColumnOrder {
+			header = append(header, strings.ToLower(msgShortNames[kind]))
+		}
+		header = append(header, "groups", "os", "hostname", "vendor", "name", "stale", "device_type", "trusted", "tags")
+		query = `SELECT ts, address, mac, iface, first_seen, last_seen, total, counts, groups, os, hostname, vendor, name, stale,
+			device_type, trusted, tags
+			FROM peers WHERE ts BETWEEN ? AND ? ORDER BY ts, address`
+		row = exportPeerRow
+	case "routers":
+		header = []string{"time", "address", "mac", "interface", "lifetime_s", "managed", "other", "mtu", "prefixes", "rdnss", "first_seen", "last_seen",
+			"preference", "dnssl"}
+		query = `SELECT ts, address, mac, iface, lifetime, managed, other, mtu, prefixes, rdnss, first_seen, last_seen,
+			preference, dnssl
+			FROM routers WHERE ts BETWEEN ? AND ? ORDER BY ts, address`
+		row = exportRouterRow
+	case "alerts":
+		header = []string{"time", "severity", "category", "source", "message"}
+		query = `SELECT ts, severity, category, source, message FROM alerts WHERE ts BETWEEN ? AND ? ORDER BY ts`
+		row = exportAlertRow
+	case "counters":
+		header = []string{"time", "resolution_s", "type", "count"}
+		query = `SELECT ts, res, kind, count FROM counters WHERE ts BETWEEN ? AND ? ORDER BY ts, res, kind`
+		row = exportCounterRow
+	default:
+		return fmt.Errorf("unknown table %q (want %s)", table, strings.Join(ExportTables, ", "))
+	}
+
+	rows, err := h.db.Query(query, from.UnixNano(), to.UnixNano())
+	if err != nil {
+		return fmt.Errorf("export %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for rows.Next() {
+		rec, err := row(rows)
+		if err != nil {
+			return fmt.Errorf("export %s: %w", table, err)
+		}
+		for i := range rec {
+			rec[i] = anon.Text(rec[i])
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("export %s: %w", table, err)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportPeerRow(rows *sql.Rows) ([]string, error) {
+	var (
+		ts, first, last, total                   int64
+		addr, mac, iface, counts, groups, osType string
+		hostname, vendor, name                   string
+		deviceType, tags                         string
+		stale, trusted                           bool
+	)
+	if err := rows.Scan(&ts, &addr, &mac, &iface, &first, &last, &total, &counts, &groups, &osType, &hostname, &vendor, &name, &stale,
+		&deviceType, &trusted, &tags); err != nil {
+		return nil, err
+	}
+	var c map[string]int
+	var g, tg []string
+	if err := json.Unmarshal([]byte(counts), &c); err != nil {
+		return nil, fmt.Errorf("peer %s counts: %w", addr, err)
+	}
+	if err := json.Unmarshal([]byte(groups), &g); err != nil {
+		return nil, fmt.Errorf("peer %s groups: %w", addr, err)
+	}
+	if err := json.Unmarshal([]byte(tags), &tg); err != nil {
+		return nil, fmt.Errorf("peer %s tags: %w", addr, err)
+	}
+
+	rec := []string{exportTime(ts), addr, mac, iface, exportTime(first), exportTime(last), strconv.FormatInt(total, 10)}
+	for _, kind := range msgColumnOrder {
+		rec = append(rec, strconv.Itoa(c[kind]))
+	}
+	return append(rec, strings.Join(g, " "), osType, hostname, vendor, name, strconv.FormatBool(stale),
+		deviceType, strconv.FormatBool(trusted), strings.Join(tg, " ")), nil
+}
+
+func exportRouterRow(rows *sql.Rows) ([]string, error) {
+	var (
+		ts, lifetime, mtu, first, last, pref int64
+		addr, mac, iface, pfx, rdnss, dnssl  string
+		managed, other                       bool
+	)
+	if err := rows.Scan(&ts, &addr, &mac, &iface, &lifetime, &managed, &other, &mtu, &pfx, &rdnss, &first, &last,
+		&pref, &dnssl); err != nil {
+		return nil, err
+	}
+	var prefixes []PrefixInfo
+	var servers, domains []string
+	if err := json.Unmarshal([]byte(pfx), &prefixes); err != nil {
+		return nil, fmt.Errorf("router %s prefixes: %w", addr, err)
+	}
+	if err := json.Unmarshal([]byte(rdnss), &servers); err != nil {
+		return nil, fmt.Errorf("router %s rdnss: %w", addr, err)
+	}
+	if err := json.Unmarshal([]byte(dnssl), &domains); err != nil {
+		return nil, fmt.Errorf("router %s dnssl: %w", addr, err)
+	}
+	names := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		names[i] = p.Prefix
+	}
+	return []string{
+		exportTime(ts), addr, mac, iface, strconv.FormatInt(lifetime, 10),
+		strconv.FormatBool(managed), strconv.FormatBool(other), strconv.FormatInt(mtu, 10),
+		strings.Join(names, " "), strings.Join(servers, " "), exportTime(first), exportTime(last),
+		routePreferenceName(int(pref)), strings.Join(domains, " "),
+	}, nil
+}
+
+func exportAlertRow(rows *sql.Rows) ([]string, error) {
+	var ts int64
+	var severity, category, source, message string
+	if err := rows.Scan(&ts, &severity, &category, &source, &message); err != nil {
+		return nil, err
+	}
+	return []string{exportTime(ts), severity, category, source, message}, nil
+}
+
+func exportCounterRow(rows *sql.Rows) ([]string, error) {
+	var ts, res, count int64
+	var kind string
+	if err := rows.Scan(&ts, &res, &kind, &count); err != nil {
+		return nil, err
+	}
+	return []string{exportTime(ts), strconv.FormatInt(res, 10), kind, strconv.FormatInt(count, 10)}, nil
+}
+
+func exportTime(ns int64) string {
+	return time.Unix(0, ns).UTC().Format(time.RFC3339Nano)
+}
+
+// ParseExportTime parses an export range bound: an RFC 3339 timestamp, a
+// local date ("2024-05-01") or date and time ("2024-05-01 14:30"), or a
+// duration meaning that long before now ("24h").
+func ParseExportTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want RFC 3339, YYYY-MM-DD[ HH:MM[:SS]] or a duration ago such as 24h", s)
+}
@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"slices"
+
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// fastPathRound is how many messages the profiler counts before it
+	// picks the hot types again.
+	fastPathRound = 1024
+	// fastPathShare is the share of a round a type needs to be hot.
+	fastPathShare = 4 // one in four
+)
+
+// fastPathKinds are the types that may take the fast path: everything done
+// with them needs only the fixed header, the target and the link-layer
+// address option. RAs and MLD always take the full path.
+var fastPathKinds = map[string]bool{
+	"router_solicitation":    true,
+	"neighbor_solicitation":  true,
+	"neighbor_advertisement": true,
+}
+
+// FastPathStats describes the profile-guided fast path (see
+// NDPListenerConfig.FastPath).
+type FastPathStats struct {
+	Enabled bool `json:"enabled"`
+	// Hot lists the types currently on the fast path.
+	Hot []string `json:"hot,omitempty"`
+	// Fast counts messages handled on the fast path since startup, updated
+	// once per profiling round.
+	Fast uint64 `json:"fast"`
+}
+
+// fastProfiler learns which eligible types dominate the segment. Each round
+// of fastPathRound messages, the eligible types that made up at least one
+// in fastPathShare of it become the hot set for the next round. Not safe
+// for concurrent use; each listener has its own.
+type fastProfiler struct {
+	counts map[string]int
+	seen   int
+	hot    map[string]bool
+	fast   uint64
+}
+
+func newFastProfiler() *fastProfiler {
+	return &fastProfiler{counts: make(map[string]int), hot: make(map[string]bool)}
+}
+
+// observe counts a message of kind and reports whether it takes the fast
+// path. done is set at the end of a round, when the stats should be updated.
+func (p *fastProfiler) observe(kind string) (fast, done bool) {
+	p.counts[kind]++
+	p.seen++
+	fast = p.hot[kind]
+	if fast {
+		p.fast++
+	}
+	if p.seen < fastPathRound {
+		return fast, false
+	}
+	clear(p.hot)
+	for k, n := range p.counts {
+		if fastPathKinds[k] && n*fastPathShare >= p.seen {
+			p.hot[k] = true
+		}
+	}
+	clear(p.counts)
+	p.seen = 0
+	return fast, true
+}
+
+// hotKinds returns the hot types, sorted.
+func (p *fastProfiler) hotKinds() []string {
+	out := make([]string, 0, len(p.hot))
+	for k := range p.hot {
+		out = append(out, k)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// onFastPath profiles a message and reports whether it takes the fast path.
+func (l *NDPListener) onFastPath(buf []byte) bool {
+	if l.profile == nil || len(buf) < 4 {
+		return false
+	}
+	fast, done := l.profile.observe(classifyICMPv6(ipv6.ICMPType(buf[0])))
+	if done {
+		hot := l.profile.hotKinds()
+		if prev := l.cfg.Stats.FastPath().Hot; !slices.Equal(prev, hot) {
+			l.cfg.Logger.Info("fast path types changed", "hot", hot)
+		}
+		l.cfg.Stats.setFastPath(hot, l.profile.fast)
+	}
+	return fast
+}
+
+// setFastPath records the fast path's hot types and message count.
+func (s *NDPStats) setFastPath(hot []string, fast uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fastPath = FastPathStats{Enabled: true, Hot: hot, Fast: fast}
+}
+
+// enableFastPath marks the fast path as on before its first round ends.
+func (s *NDPStats) enableFastPath() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fastPath.Enabled = true
+}
+
+// FastPath returns the fast path's state.
+func (s *NDPStats) FastPath() FastPathStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fastPathLocked()
+}
+
+func (s *NDPStats) fastPathLocked() FastPathStats {
+	st := s.fastPath
+	st.Hot = slices.Clone(st.Hot)
+	return st
+}
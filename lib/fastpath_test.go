@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFastProfiler(t *testing.T) {
+	p := newFastProfiler()
+	// A round of 90% NS, 5% RA and 5% NA: only NS becomes hot.
+	for i := 0; i < fastPathRound; i++ {
+		kind := "neighbor_solicitation"
+		switch i % 20 {
+		case 0:
+			kind = "router_advertisement"
+		case 1:
+			kind = "neighbor_advertisement"
+		}
+		if fast, done := p.observe(kind); fast || done != (i == fastPathRound-1) {
+			t.Fatalf("message %d: fast %v done %v before the first round ended", i, fast, done)
+		}
+	}
+	if got := p.hotKinds(); !slices.Equal(got, []string{"neighbor_solicitation"}) {
+		t.Fatalf("hot = %v, want NS only", got)
+	}
+	if fast, _ := p.observe("neighbor_solicitation"); !fast {
+		t.Error("NS should take the fast path")
+	}
+
+	// An RA flood never makes RAs hot.
+	for i := 0; i < fastPathRound; i++ {
+		p.observe("router_advertisement")
+	}
+	if got := p.hotKinds(); len(got) != 0 {
+		t.Errorf("hot = %v after an RA-only round, want none", got)
+	}
+}
+
+func TestHandle_FastPath(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:    stats,
+		FastPath: true,
+	})
+
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	for i := 0; i < 2*fastPathRound; i++ {
+		l.handle(received{src: netip.MustParseAddr("fe80::1"), payload: buildNS(net.ParseIP("fe80::2"), mac)})
+	}
+
+	peers := stats.GetStats()
+	if len(peers) != 1 || peers[0].Counts["neighbor_solicitation"] != 2*fastPathRound || peers[0].MAC != mac.String() {
+		t.Fatalf("peers = %+v, want fe80::1 with its MAC and every NS", peers)
+	}
+	if got := stats.GetSolicitedTargets(); len(got) != 1 || got[0].Solicitations != 2*fastPathRound {
+		t.Errorf("solicited targets = %+v, want every NS", got)
+	}
+	fp := stats.FastPath()
+	if !fp.Enabled || !slices.Equal(fp.Hot, []string{"neighbor_solicitation"}) || fp.Fast != fastPathRound {
+		t.Errorf("fast path = %+v, want NS hot and the second round counted", fp)
+	}
+	// Only the first round, before NS was hot, reached the option matrix.
+	for _, u := range stats.GetOptionUsage() {
+		if u.Kind == "neighbor_solicitation" && u.Messages != fastPathRound {
+			t.Errorf("option usage counted %d NSs, want %d", u.Messages, fastPathRound)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultFederationInterval is how often instances register and are polled.
+	defaultFederationInterval = 30 * time.Second
+	// federationTimeout bounds each request to another instance.
+	federationTimeout = 5 * time.Second
+	// federationExpiry is how many intervals a registered or learned
+	// instance stays listed without being heard of again.
+	federationExpiry = 3
+	// maxRegisteredInstances caps how many instances a collector keeps
+	// registered at once.
+	maxRegisteredInstances = 256
+)
+
+// FederationConfig lists the other NDPeekr instances on a site, statically
+// or by registration with a collector, so the TUI can switch between
+// segments. Any instance with the API enabled can be a collector.
+type FederationConfig struct {
+	Name      string           `yaml:"name"`      // how this instance is listed (default: --instance, --node-name or the hostname)
+	Advertise string           `yaml:"advertise"` // this instance's API URL as others reach it
+	Register  string           `yaml:"register"`  // collector API URL to register with
+	Accept    bool             `yaml:"accept"`    // accept registrations: act as a collector
+	Instances []InstanceConfig `yaml:"instances"` // statically listed instances
+	Interval  time.Duration    `yaml:"interval"`  // registration and poll interval (default 30s)
+	// RegisterToken is sent when registering, to the register URL only:
+	// the collector's admin_token.
+	RegisterToken string `yaml:"register_token"`
+	// ReadToken is sent when polling the collector and the static
+	// instances: their read_token. Registered and learned instances, whose
+	// URLs anyone allowed to register could have made up, get no token.
+	ReadToken string `yaml:"read_token"`
+}
+
+// InstanceConfig is a statically listed instance.
+type InstanceConfig struct {
+	Name string `yaml:"name" json:"name"`
+	URL  string `yaml:"url" json:"url"` // API base URL, e.g. http://10.0.30.5:9311
+}
+
+// Instance is another NDPeekr instance as the federation knows it.
+type Instance struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Source is how the instance is known: "static", "registered" (it
+	// registered here) or "learned" (from the collector's list).
+	Source string    `json:"source"`
+	Heard  time.Time `json:"heard"` // last registration or listing; zero for static instances
+	// Up reports whether the last poll of its status succeeded.
+	Up        bool          `json:"up"`
+	Polled    time.Time     `json:"polled,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Node      string        `json:"node,omitempty"`
+	Interface string        `json:"interface,omitempty"`
+	Uptime    time.Duration `json:"uptime,omitempty"`
+	// Health is the IPv6 health score per segment it captures on.
+	Health map[string]int `json:"health,omitempty"`
+}
+
+// Federation keeps the list of instances up to date: it registers this
+// instance with the collector, learns the collector's list, accepts
+// registrations and polls every instance's status.
+type Federation struct {
+	cfg    FederationConfig
+	logger *slog.Logger
+	client *http.Client
+
+	// trusted are the base URLs ReadToken may be sent to: the collector's
+	// and the static instances'.
+	trusted map[string]bool
+
+	mu        sync.Mutex
+	instances map[string]*Instance // by name
+}
+
+// NewFederation validates cfg. name is the fallback for cfg.Name.
+func NewFederation(cfg FederationConfig, name string, logger *slog.Logger) (*Federation, error) {
+	if cfg.Name == "" {
+		cfg.Name = name
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultFederationInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	f := &Federation{
+		cfg:       cfg,
+		logger:    logger,
+		client:    &http.Client{Timeout: federationTimeout},
+		trusted:   make(map[string]bool),
+		instances: make(map[string]*Instance),
+	}
+	if cfg.Register != "" {
+		u, err := instanceURL(cfg.Register)
+		if err != nil {
+			return nil, fmt.Errorf("federation.register: %w", err)
+		}
+		f.cfg.Register = u
+		f.trusted[u] = true
+	}
+	for i, ic := range cfg.Instances {
+		u, err := instanceURL(ic.URL)
+		if err != nil {
+			return nil, fmt.Errorf("federation.instances[%d]: %w", i, err)
+		}
+		if ic.Name == "" {
+			return nil, fmt.Errorf("federation.instances[%d]: name is required", i)
+		}
+		f.instances[ic.Name] = &Instance{Name: ic.Name, URL: u, Source: "static"}
+		f.trusted[u] = true
+	}
+	return f, nil
+}
+
+// instanceURL checks an instance's API base URL and strips trailing slashes.
+func instanceURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("url %q: want http(s)://host:port", raw)
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// Name returns the name this instance is listed under.
+func (f *Federation) Name() string {
+	return f.cfg.Name
+}
+
+// Register adds or refreshes an instance that registered itself.
+func (f *Federation) Register(name, rawURL string, now time.Time) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	u, err := instanceURL(rawURL)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if in, ok := f.instances[name]; ok && in.Source == "static" {
+		return fmt.Errorf("%s is listed statically", name)
+	}
+	in, ok := f.instances[name]
+	if !ok || in.Source != "registered" {
+		f.expireLocked(now)
+		if f.registeredLocked() >= maxRegisteredInstances {
+			return fmt.Errorf("%d instances are registered already", maxRegisteredInstances)
+		}
+	}
+	if !ok || in.URL != u {
+		in = &Instance{Name: name, URL: u}
+		f.instances[name] = in
+	}
+	in.Source, in.Heard = "registered", now
+	return nil
+}
+
+// Instances returns the known instances other than this one, by name.
+func (f *Federation) Instances() []Instance {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireLocked(time.Now())
+	out := make([]Instance, 0, len(f.instances))
+	for _, in := range f.instances {
+		c := *in
+		c.Health = make(map[string]int, len(in.Health))
+		for k, v := range in.Health {
+			c.Health[k] = v
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// registeredLocked counts the registered instances. Callers must hold f.mu.
+func (f *Federation) registeredLocked() int {
+	n := 0
+	for _, in := range f.instances {
+		if in.Source == "registered" {
+			n++
+		}
+	}
+	return n
+}
+
+// expireLocked drops registered and learned instances not heard of for
+// federationExpiry intervals. Callers must hold f.mu.
+func (f *Federation) expireLocked(now time.Time) {
+	for name, in := range f.instances {
+		if in.Source != "static" && now.Sub(in.Heard) > federationExpiry*f.cfg.Interval {
+			delete(f.instances, name)
+		}
+	}
+}
+
+// Run registers, learns and polls immediately and then every interval
+// until ctx is cancelled.
+func (f *Federation) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		f.cycle(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cycle runs one round of registration, learning and polling.
+func (f *Federation) cycle(ctx context.Context, now time.Time) {
+	if f.cfg.Register != "" {
+		if err := f.register(ctx); err != nil {
+			f.logger.Warn("federation registration failed", "collector", f.cfg.Register, "err", err)
+		}
+		if err := f.learn(ctx, now); err != nil {
+			f.logger.Debug("federation listing failed", "collector", f.cfg.Register, "err", err)
+		}
+	}
+	for _, in := range f.Instances() {
+		f.poll(ctx, in.Name, in.URL, now)
+	}
+}
+
+// register announces this instance to the collector.
+func (f *Federation) register(ctx context.Context) error {
+	if f.cfg.Advertise == "" {
+		return fmt.Errorf("federation.advertise is required to register")
+	}
+	body, err := json.Marshal(InstanceConfig{Name: f.cfg.Name, URL: f.cfg.Advertise})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(f.cfg.Register, "/")+"/api/v1/instances", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.cfg.RegisterToken != "" {
+		req.Header.Set("Authorization", "Bearer "+f.cfg.RegisterToken)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// learn merges the collector's list of instances into ours.
+func (f *Federation) learn(ctx context.Context, now time.Time) error {
+	var listed []Instance
+	if err := f.getJSON(ctx, strings.TrimRight(f.cfg.Register, "/"), "/api/v1/instances", &listed); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, l := range listed {
+		if l.Name == f.cfg.Name {
+			continue
+		}
+		u, err := instanceURL(l.URL)
+		if err != nil {
+			continue
+		}
+		in, ok := f.instances[l.Name]
+		if ok && in.Source != "learned" {
+			continue // our own static or registered entry wins
+		}
+		if !ok || in.URL != u {
+			in = &Instance{Name: l.Name, URL: u, Source: "learned"}
+			f.instances[l.Name] = in
+		}
+		in.Heard = now
+	}
+	return nil
+}
+
+// poll fetches an instance's status and health.
+func (f *Federation) poll(ctx context.Context, name, base string, now time.Time) {
+	var st Status
+	err := f.getJSON(ctx, base, "/api/v1/status", &st)
+	var health []SegmentHealth
+	if err == nil {
+		err = f.getJSON(ctx, base, "/api/v1/ipv6-health", &health)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	in, ok := f.instances[name]
+	if !ok || in.URL != base {
+		return // replaced while polling
+	}
+	in.Polled, in.Up = now, err == nil
+	if err != nil {
+		in.Error = err.Error()
+		return
+	}
+	in.Error = ""
+	in.Node, in.Interface, in.Uptime = st.Node, st.Interface, st.Uptime
+	in.Health = make(map[string]int, len(health))
+	for _, h := range health {
+		in.Health[h.Interface] = h.Score
+	}
+}
+
+// getJSON decodes the JSON at path on the instance at base into v. Only
+// trusted instances get the read token.
+func (f *Federation) getJSON(ctx context.Context, base, path string, v any) error {
+	u := base + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if f.cfg.ReadToken != "" && f.trusted[base] {
+		req.Header.Set("Authorization", "Bearer "+f.cfg.ReadToken)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: %w", u, err)
+	}
+	return nil
+}
+
+// FetchSegment loads the peers and routers of the instance at base, for
+// the TUI's segment picker.
+func (f *Federation) FetchSegment(ctx context.Context, base string) ([]PeerSummary, []RouterInfo, error) {
+	var peers []PeerSummary
+	if err := f.getJSON(ctx, base, "/api/v1/peers", &peers); err != nil {
+		return nil, nil, err
+	}
+	var routers []RouterInfo
+	if err := f.getJSON(ctx, base, "/api/v1/routers", &routers); err != nil {
+		return nil, nil, err
+	}
+	return peers, routers, nil
+}
+
+// Handler serves the federation's routes in front of next:
+//
+//	GET  /api/v1/instances  the known instances (see Instance)
+//	POST /api/v1/instances  register {"name": ..., "url": ...}; needs accept: true
+func (f *Federation) Handler(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", next)
+	mux.HandleFunc("GET /api/v1/instances", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, f.Instances())
+	})
+	mux.HandleFunc("POST /api/v1/instances", func(w http.ResponseWriter, r *http.Request) {
+		if !f.cfg.Accept {
+			writeAPIError(w, http.StatusForbidden, fmt.Errorf("this instance does not accept registrations (federation.accept)"))
+			return
+		}
+		var ic InstanceConfig
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&ic); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := f.Register(ic.Name, ic.URL, time.Now()); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		f.logger.Debug("instance registered", "name", ic.Name, "url", ic.URL)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
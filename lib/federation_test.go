@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFederation_RegisterAndList(t *testing.T) {
+	f, err := NewFederation(FederationConfig{
+		Accept:    true,
+		Instances: []InstanceConfig{{Name: "core", URL: "http://10.0.0.1:9311/"}},
+	}, "collector", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(f.Handler(http.NotFoundHandler()))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/v1/instances", "application/json", strings.NewReader(`{"name":"lab","url":"http://10.0.30.5:9311"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("register status = %d, want 204", resp.StatusCode)
+	}
+	if err := f.Register("core", "http://10.0.0.9:9311", time.Now()); err == nil {
+		t.Error("registering over a static instance should fail")
+	}
+
+	for i := 0; i < maxRegisteredInstances; i++ {
+		f.Register(fmt.Sprintf("seg%d", i), "http://10.0.31.1:9311", time.Now())
+	}
+	if err := f.Register("one-too-many", "http://10.0.32.1:9311", time.Now()); err == nil {
+		t.Error("registration beyond the cap accepted")
+	}
+	if err := f.Register("lab", "http://10.0.30.5:9311", time.Now()); err != nil {
+		t.Errorf("refreshing a registration at the cap = %v", err)
+	}
+	f.mu.Lock()
+	for i := 0; i < maxRegisteredInstances; i++ {
+		delete(f.instances, fmt.Sprintf("seg%d", i))
+	}
+	f.mu.Unlock()
+
+	ins := f.Instances()
+	if len(ins) != 2 || ins[0].Name != "core" || ins[0].URL != "http://10.0.0.1:9311" || ins[1].Source != "registered" {
+		t.Fatalf("instances = %+v", ins)
+	}
+
+	// Registered instances expire; static ones stay.
+	f.mu.Lock()
+	f.instances["lab"].Heard = time.Now().Add(-federationExpiry*defaultFederationInterval - time.Second)
+	f.mu.Unlock()
+	if ins := f.Instances(); len(ins) != 1 || ins[0].Name != "core" {
+		t.Errorf("after expiry instances = %+v, want only core", ins)
+	}
+}
+
+func TestFederation_RejectsWithoutAccept(t *testing.T) {
+	f, err := NewFederation(FederationConfig{}, "solo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	f.Handler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/instances", strings.NewReader(`{"name":"x","url":"http://h:1"}`)))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", rec.Code)
+	}
+}
+
+func TestFederation_PollAndFetch(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_advertisement")
+	remote := httptest.NewServer(APIHandler(stats))
+	defer remote.Close()
+
+	f, err := NewFederation(FederationConfig{Instances: []InstanceConfig{{Name: "lab", URL: remote.URL}}}, "here", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.cycle(context.Background(), time.Now())
+	if ins := f.Instances(); len(ins) != 1 || !ins[0].Up || ins[0].Error != "" {
+		t.Fatalf("instances = %+v, want lab up", ins)
+	}
+
+	peers, _, err := f.FetchSegment(context.Background(), remote.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || peers[0].Address != "fe80::1" {
+		t.Errorf("peers = %+v, want fe80::1", peers)
+	}
+
+	remote.Close()
+	f.cycle(context.Background(), time.Now())
+	if ins := f.Instances(); ins[0].Up || ins[0].Error == "" {
+		t.Errorf("instance = %+v, want down with an error", ins[0])
+	}
+}
+
+func TestFederation_TokensOnlyToTrustedURLs(t *testing.T) {
+	var static, registered string
+	remote := func(auth *string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*auth = r.Header.Get("Authorization")
+			w.Write([]byte("{}"))
+		}))
+	}
+	s, r := remote(&static), remote(&registered)
+	defer s.Close()
+	defer r.Close()
+
+	f, err := NewFederation(FederationConfig{
+		Accept:        true,
+		Instances:     []InstanceConfig{{Name: "core", URL: s.URL}},
+		RegisterToken: "admin",
+		ReadToken:     "read",
+	}, "collector", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Register("lab", r.URL, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	f.cycle(context.Background(), time.Now())
+	if static != "Bearer read" {
+		t.Errorf("static instance got %q, want the read token", static)
+	}
+	if registered != "" {
+		t.Errorf("registered instance got %q, want no token", registered)
+	}
+}
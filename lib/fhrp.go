@@ -0,0 +1,256 @@
+package lib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// First-hop redundancy protocols. Both send their adverts from the physical
+// link-local address of the current master (VRRP) or active router (HSRP),
+// while RAs and NAs for the virtual address carry the virtual MAC.
+const (
+	FHRPVRRP = "VRRP"
+	FHRPHSRP = "HSRP"
+)
+
+const (
+	nhVRRP   = 112
+	nhUDP    = 17
+	hsrpPort = 2029 // HSRP for IPv6
+
+	hsrpTLVGroupState = 1
+	hsrpStateActive   = 6
+)
+
+// VirtualMAC identifies a VRRP or HSRP virtual MAC address and returns the
+// protocol and the virtual router ID or group number.
+func VirtualMAC(mac string) (proto string, group int, ok bool) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 {
+		return "", 0, false
+	}
+	switch {
+	case hw[0] == 0x00 && hw[1] == 0x00 && hw[2] == 0x5e && hw[3] == 0x00 && (hw[4] == 0x01 || hw[4] == 0x02):
+		// 00:00:5e:00:01:XX (IPv4) and 00:00:5e:00:02:XX (IPv6), RFC 5798
+		return FHRPVRRP, int(hw[5]), true
+	case hw[0] == 0x00 && hw[1] == 0x05 && hw[2] == 0x73 && hw[3] == 0xa0 && hw[4]&0xf0 == 0:
+		// 00:05:73:a0:0X:XX, HSRP for IPv6
+		return FHRPHSRP, int(hw[4])<<8 | int(hw[5]), true
+	case hw[0] == 0x00 && hw[1] == 0x00 && hw[2] == 0x0c && hw[3] == 0x07 && hw[4] == 0xac:
+		// 00:00:0c:07:ac:XX, HSRPv1
+		return FHRPHSRP, int(hw[5]), true
+	case hw[0] == 0x00 && hw[1] == 0x00 && hw[2] == 0x0c && hw[3] == 0x9f && hw[4]&0xf0 == 0xf0:
+		// 00:00:0c:9f:fX:XX, HSRPv2 for IPv4
+		return FHRPHSRP, int(hw[4]&0x0f)<<8 | int(hw[5]), true
+	}
+	return "", 0, false
+}
+
+// virtualMACLabel describes a virtual MAC, e.g. "VRRP VRID 5", or returns "".
+func virtualMACLabel(mac string) string {
+	proto, group, ok := VirtualMAC(mac)
+	if !ok {
+		return ""
+	}
+	if proto == FHRPVRRP {
+		return fmt.Sprintf("VRRP VRID %d", group)
+	}
+	return fmt.Sprintf("HSRP group %d", group)
+}
+
+// fhrpAdvert is the part of a VRRP or HSRP advert needed to track the master.
+type fhrpAdvert struct {
+	proto     string
+	group     int
+	priority  int
+	addresses []string // virtual addresses
+}
+
+// parseVRRPv3 decodes a VRRPv3 advertisement (RFC 5798) carrying IPv6 addresses.
+func parseVRRPv3(buf []byte) (fhrpAdvert, bool) {
+	if len(buf) < 8 || buf[0] != 0x31 { // version 3, type 1 (advertisement)
+		return fhrpAdvert{}, false
+	}
+	a := fhrpAdvert{proto: FHRPVRRP, group: int(buf[1]), priority: int(buf[2])}
+	count := int(buf[3])
+	if len(buf) < 8+16*count {
+		return fhrpAdvert{}, false
+	}
+	for i := 0; i < count; i++ {
+		a.addresses = append(a.addresses, ip16String(buf[8+16*i:24+16*i]))
+	}
+	return a, true
+}
+
+// parseHSRPv6 decodes the Group State TLV of an HSRP for IPv6 hello sent by
+// the active router. The TLV's identifier is that router's physical MAC.
+func parseHSRPv6(udp []byte) (fhrpAdvert, string, bool) {
+	if len(udp) < 8 || binary.BigEndian.Uint16(udp[2:4]) != hsrpPort {
+		return fhrpAdvert{}, "", false
+	}
+	tlvs := udp[8:]
+	for len(tlvs) >= 2 {
+		typ, n := tlvs[0], int(tlvs[1])
+		if len(tlvs) < 2+n {
+			break
+		}
+		v := tlvs[2 : 2+n]
+		tlvs = tlvs[2+n:]
+		// version, opcode, state, ip version, group(2), identifier(6),
+		// priority(4), hello(4), hold(4), virtual address(16)
+		if typ != hsrpTLVGroupState || n < 40 || v[3] != 6 {
+			continue
+		}
+		if v[2] != hsrpStateActive {
+			return fhrpAdvert{}, "", false
+		}
+		return fhrpAdvert{
+			proto:     FHRPHSRP,
+			group:     int(binary.BigEndian.Uint16(v[4:6])),
+			priority:  int(binary.BigEndian.Uint32(v[12:16])),
+			addresses: []string{ip16String(v[24:40])},
+		}, net.HardwareAddr(v[6:12]).String(), true
+	}
+	return fhrpAdvert{}, "", false
+}
+
+// VirtualRouter is a VRRP virtual router or HSRP group and its current master.
+type VirtualRouter struct {
+	Protocol   string    `json:"protocol"` // FHRPVRRP or FHRPHSRP
+	Group      int       `json:"group"`    // VRRP VRID or HSRP group number
+	Addresses  []string  `json:"addresses"`
+	Master     string    `json:"master"`               // physical link-local address of the master/active router
+	MasterMAC  string    `json:"master_mac,omitempty"` // its physical MAC, if known
+	Priority   int       `json:"priority"`
+	Since      time.Time `json:"since"` // when Master took over (or was first seen)
+	LastSeen   time.Time `json:"last_seen"`
+	Changes    int       `json:"changes"` // master changes observed
+	PrevMaster string    `json:"prev_master,omitempty"`
+}
+
+// fhrpKey identifies a virtual router; VRIDs and HSRP groups are separate spaces.
+func fhrpKey(proto string, group int) string {
+	return fmt.Sprintf("%s|%d", proto, group)
+}
+
+// recordFHRPAdvert notes an advert from master. mac is the master's physical
+// MAC when the advert carries it (HSRP); otherwise the MAC last seen for
+// master is used. It returns the virtual router before and after the advert,
+// and whether the advert came from a new master.
+func (s *NDPStats) recordFHRPAdvert(a fhrpAdvert, master, mac string, now time.Time) (prev, cur VirtualRouter, changed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if mac == "" {
+		if peer, ok := s.peers[master]; ok {
+			mac = peer.MAC
+		}
+	}
+	key := fhrpKey(a.proto, a.group)
+	vr, ok := s.fhrp[key]
+	if !ok {
+		vr = &VirtualRouter{Protocol: a.proto, Group: a.group, Master: master, Since: now}
+		s.fhrp[key] = vr
+	}
+	changed = vr.Master != master
+	prev = *vr
+	if changed {
+		vr.PrevMaster = vr.Master
+		vr.Master = master
+		vr.Since = now
+		vr.Changes++
+	}
+	vr.MasterMAC = mac
+	vr.Priority = a.priority
+	vr.Addresses = a.addresses
+	vr.LastSeen = now
+	return prev, *vr, changed
+}
+
+// GetVirtualRouters returns the virtual routers seen within the window,
+// ordered by protocol and group.
+func (s *NDPStats) GetVirtualRouters() []VirtualRouter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.virtualRoutersLocked()
+}
+
+// virtualRoutersLocked copies the virtual routers. Callers must hold s.mu.
+func (s *NDPStats) virtualRoutersLocked() []VirtualRouter {
+	result := make([]VirtualRouter, 0, len(s.fhrp))
+	for _, vr := range s.fhrp {
+		c := *vr
+		c.Addresses = append([]string(nil), vr.Addresses...)
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Protocol != result[j].Protocol {
+			return result[i].Protocol < result[j].Protocol
+		}
+		return result[i].Group < result[j].Group
+	})
+	return result
+}
+
+// pruneFHRPLocked forgets virtual routers not advertised since cutoff.
+// Callers must hold s.mu.
+func (s *NDPStats) pruneFHRPLocked(cutoff time.Time) {
+	for key, vr := range s.fhrp {
+		if !vr.LastSeen.After(cutoff) {
+			delete(s.fhrp, key)
+		}
+	}
+}
+
+// handleFHRP records VRRPv3 and HSRP for IPv6 adverts seen by packet-level
+// backends and alerts when a virtual router's master changes. ifIndex is the
+// capture interface, 0 if unknown.
+func (l *NDPListener) handleFHRP(p ipv6Packet, ifIndex int) {
+	if l.cfg.Stats == nil {
+		return
+	}
+	var (
+		a   fhrpAdvert
+		mac string
+		ok  bool
+	)
+	switch p.nextHeader {
+	case nhVRRP:
+		a, ok = parseVRRPv3(p.payload)
+	case nhUDP:
+		a, mac, ok = parseHSRPv6(p.payload)
+	}
+	if !ok {
+		return
+	}
+
+	link := ""
+	if ifIndex != 0 {
+		if ifi, err := net.InterfaceByIndex(ifIndex); err == nil {
+			link = ifi.Name
+		}
+	}
+	master := l.peerAddr(netip.AddrFrom16([16]byte(p.src)), link).String()
+	prev, cur, changed := l.cfg.Stats.recordFHRPAdvert(a, master, mac, l.now())
+	if !changed {
+		return
+	}
+	l.raiseAlert(Alert{
+		Severity: SeverityWarning,
+		Category: "fhrp_master_change",
+		Source:   master,
+		Message: fmt.Sprintf("%s group %d master changed from %s (%s) to %s (%s)",
+			a.proto, a.group, prev.Master, orDash(prev.MasterMAC), master, orDash(cur.MasterMAC)),
+	})
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestVirtualMAC(t *testing.T) {
+	tests := []struct {
+		mac   string
+		proto string
+		group int
+	}{
+		{"00:00:5e:00:02:05", FHRPVRRP, 5},
+		{"00:00:5e:00:01:0a", FHRPVRRP, 10},
+		{"00:05:73:a0:01:02", FHRPHSRP, 258},
+		{"00:00:0c:07:ac:01", FHRPHSRP, 1},
+		{"00:00:0c:9f:f0:2a", FHRPHSRP, 42},
+		{"00:00:5e:00:03:05", "", 0},
+		{"aa:bb:cc:dd:ee:ff", "", 0},
+	}
+	for _, tt := range tests {
+		proto, group, ok := VirtualMAC(tt.mac)
+		if ok != (tt.proto != "") || proto != tt.proto || group != tt.group {
+			t.Errorf("VirtualMAC(%s) = %q, %d, %v; want %q, %d", tt.mac, proto, group, ok, tt.proto, tt.group)
+		}
+	}
+}
+
+// buildVRRPv3 constructs a VRRPv3 advertisement for vrid with the given virtual addresses.
+func buildVRRPv3(vrid, priority byte, addrs ...string) []byte {
+	buf := []byte{0x31, vrid, priority, byte(len(addrs)), 0x00, 0x64, 0, 0}
+	for _, a := range addrs {
+		buf = append(buf, net.ParseIP(a).To16()...)
+	}
+	return buf
+}
+
+// buildHSRPv6 constructs a UDP datagram carrying an HSRP for IPv6 Group State TLV.
+func buildHSRPv6(group uint16, state byte, id net.HardwareAddr, priority uint32, vip string) []byte {
+	tlv := []byte{hsrpTLVGroupState, 40, 2, 0, state, 6}
+	tlv = binary.BigEndian.AppendUint16(tlv, group)
+	tlv = append(tlv, id...)
+	tlv = binary.BigEndian.AppendUint32(tlv, priority)
+	tlv = binary.BigEndian.AppendUint32(tlv, 3000)
+	tlv = binary.BigEndian.AppendUint32(tlv, 10000)
+	tlv = append(tlv, net.ParseIP(vip).To16()...)
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:2], hsrpPort)
+	binary.BigEndian.PutUint16(udp[2:4], hsrpPort)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(tlv)))
+	return append(udp, tlv...)
+}
+
+func TestHandlePacket_VRRPMasterChange(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	stats.RecordMAC("fe80::a", "02:00:00:00:00:0a")
+	stats.RecordMAC("fe80::b", "02:00:00:00:00:0b")
+
+	advert := buildVRRPv3(5, 200, "fe80::1")
+	l.handlePacket(buildIPv6Packet("fe80::a", "ff02::12", 255, nil, nhVRRP, advert), 0, "")
+	l.handlePacket(buildIPv6Packet("fe80::a", "ff02::12", 255, nil, nhVRRP, advert), 0, "")
+	if alerts := stats.GetAlerts(); len(alerts) != 0 {
+		t.Fatalf("alerts before failover: %+v", alerts)
+	}
+
+	l.handlePacket(buildIPv6Packet("fe80::b", "ff02::12", 255, nil, nhVRRP, buildVRRPv3(5, 100, "fe80::1")), 0, "")
+
+	vrs := stats.GetVirtualRouters()
+	if len(vrs) != 1 {
+		t.Fatalf("virtual routers = %+v, want one", vrs)
+	}
+	vr := vrs[0]
+	if vr.Protocol != FHRPVRRP || vr.Group != 5 || vr.Master != "fe80::b" || vr.MasterMAC != "02:00:00:00:00:0b" ||
+		vr.PrevMaster != "fe80::a" || vr.Changes != 1 || vr.Priority != 100 || vr.Addresses[0] != "fe80::1" {
+		t.Errorf("virtual router = %+v", vr)
+	}
+	alerts := stats.GetAlerts()
+	if len(alerts) != 1 || alerts[0].Category != "fhrp_master_change" || alerts[0].Source != "fe80::b" {
+		t.Errorf("alerts = %+v, want one fhrp_master_change from fe80::b", alerts)
+	}
+}
+
+func TestHandlePacket_HSRPActive(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	id := net.HardwareAddr{0x00, 0x1b, 0x2c, 0x3d, 0x4e, 0x5f}
+
+	// Standby hellos don't name the active router.
+	l.handlePacket(buildIPv6Packet("fe80::b", "ff02::66", 255, nil, nhUDP, buildHSRPv6(1, 5, id, 90, "fe80::5:73ff:fea0:1")), 0, "")
+	l.handlePacket(buildIPv6Packet("fe80::a", "ff02::66", 255, nil, nhUDP, buildHSRPv6(1, hsrpStateActive, id, 110, "fe80::5:73ff:fea0:1")), 0, "")
+
+	vrs := stats.GetVirtualRouters()
+	if len(vrs) != 1 || vrs[0].Protocol != FHRPHSRP || vrs[0].Group != 1 || vrs[0].Master != "fe80::a" ||
+		vrs[0].MasterMAC != id.String() || vrs[0].Priority != 110 {
+		t.Errorf("virtual routers = %+v", vrs)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package lib
+
+import "os"
+
+// tryLock does nothing: files are only locked where flock exists.
+func tryLock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,504 @@
+package lib
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a compiled filter expression matched against peer summaries.
+// The same syntax is used by the TUI filter bar, the API's filter query
+// parameter and the config file's ignore rules, for example:
+//
+//	iface == "eth0" && counts.ra > 0 && mac =~ "^dc:a6"
+//
+// Fields:
+//
+//	addr                        address; == and != compare addresses, so
+//	                            "fe80::01" finds fe80::1, an address without
+//	                            a zone matches it on every link and a prefix
+//	                            such as "2001:db8::/32" matches addresses in it
+//	mac, iface, os              strings
+//	hostname, vendor, name      strings from enrichment (empty if unknown)
+//	device_type                 string from the inventory
+//	trusted                     boolean from the inventory
+//	tags                        list of inventory tags
+//	hop_limit, total, oversized numbers
+//	no_router_alert             number of MLD messages failing Router Alert validation
+//	counts.<type>               number; type is a short name (rs, ra, ns, na,
+//	                            rdr, dar, dac, mq, mr, md) or a full kind name
+//	stale                       boolean
+//	groups                      list of multicast groups; == and =~ match if
+//	                            any member matches, != and !~ if none does
+//
+// Operators are == != < <= > >= =~ (regexp match) !~ (regexp non-match),
+// combined with &&, || and !, grouped with parentheses. A bare boolean field
+// such as "stale" is shorthand for "stale == true". Strings are double-quoted
+// with Go escapes, or backquoted raw strings.
+type Filter struct {
+	src  string
+	root filterNode
+}
+
+// ParseFilter compiles a filter expression.
+func ParseFilter(src string) (*Filter, error) {
+	toks, err := lexFilter(src)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	p := &filterParser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("filter: unexpected %q at offset %d", t.text, t.pos)
+	}
+	return &Filter{src: src, root: root}, nil
+}
+
+// Match reports whether p satisfies the filter. A nil filter matches everything.
+func (f *Filter) Match(p PeerSummary) bool {
+	if f == nil {
+		return true
+	}
+	return f.root.eval(&p)
+}
+
+// String returns the source expression.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.src
+}
+
+// FilterPeers returns the peers matching f. A nil filter returns peers unchanged.
+func FilterPeers(peers []PeerSummary, f *Filter) []PeerSummary {
+	if f == nil {
+		return peers
+	}
+	result := make([]PeerSummary, 0, len(peers))
+	for _, p := range peers {
+		if f.Match(p) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// --- fields ---
+
+type fieldType int
+
+const (
+	fieldString fieldType = iota
+	fieldAddr
+	fieldNumber
+	fieldBool
+	fieldList
+)
+
+type filterField struct {
+	typ fieldType
+	str func(*PeerSummary) string
+	num func(*PeerSummary) float64
+	bl  func(*PeerSummary) bool
+	lst func(*PeerSummary) []string
+}
+
+var filterFields = map[string]filterField{
+	"addr":            {typ: fieldAddr, str: func(p *PeerSummary) string { return p.Address }},
+	"mac":             {typ: fieldString, str: func(p *PeerSummary) string { return p.MAC }},
+	"iface":           {typ: fieldString, str: func(p *PeerSummary) string { return p.Interface }},
+	"port":            {typ: fieldString, str: func(p *PeerSummary) string { return p.Port }},
+	"os":              {typ: fieldString, str: func(p *PeerSummary) string { return p.GuessedOS }},
+	"hop_limit":       {typ: fieldNumber, num: func(p *PeerSummary) float64 { return float64(p.HopLimit) }},
+	"total":           {typ: fieldNumber, num: func(p *PeerSummary) float64 { return float64(p.Total) }},
+	"bytes":           {typ: fieldNumber, num: func(p *PeerSummary) float64 { return float64(p.TotalBytes) }},
+	"oversized":       {typ: fieldNumber, num: func(p *PeerSummary) float64 { return float64(p.Oversized) }},
+	"no_router_alert": {typ: fieldNumber, num: func(p *PeerSummary) float64 { return float64(p.NoRouterAlert) }},
+	"undefended":      {typ: fieldNumber, num: func(p *PeerSummary) float64 { return float64(len(p.Undefended)) }},
+	"solicited":       {typ: fieldNumber, num: func(p *PeerSummary) float64 { return float64(p.Popularity.solicitations()) }},
+	"solicitors":      {typ: fieldNumber, num: func(p *PeerSummary) float64 { return float64(len(p.Popularity.solicitors())) }},
+	"stale":           {typ: fieldBool, bl: func(p *PeerSummary) bool { return p.Stale }},
+	"groups":          {typ: fieldList, lst: func(p *PeerSummary) []string { return p.Groups }},
+	"activity":        {typ: fieldString, str: func(p *PeerSummary) string { return p.Activity }},
+	"nd_proxy":        {typ: fieldBool, bl: func(p *PeerSummary) bool { return p.NDProxy != "" }},
+	"hostname":        {typ: fieldString, str: func(p *PeerSummary) string { return p.Hostname }},
+	"vendor":          {typ: fieldString, str: func(p *PeerSummary) string { return p.Vendor }},
+	"name":            {typ: fieldString, str: func(p *PeerSummary) string { return p.Name }},
+	"device_type":     {typ: fieldString, str: func(p *PeerSummary) string { return p.DeviceType }},
+	"trusted":         {typ: fieldBool, bl: func(p *PeerSummary) bool { return p.Trusted }},
+	"tags":            {typ: fieldList, lst: func(p *PeerSummary) []string { return p.Tags }},
+	"reputation":      {typ: fieldString, str: func(p *PeerSummary) string { return WorstReputation(p.Reputation) }},
+}
+
+// lookupFilterField resolves a field name, including counts.<type>.
+func lookupFilterField(name string) (filterField, bool) {
+	if f, ok := filterFields[name]; ok {
+		return f, true
+	}
+	short, ok := strings.CutPrefix(name, "counts.")
+	if !ok {
+		return filterField{}, false
+	}
+	for _, kind := range msgColumnOrder {
+		if short == kind || short == strings.ToLower(msgShortNames[kind]) {
+			return filterField{typ: fieldNumber, num: func(p *PeerSummary) float64 {
+				return float64(p.Counts[kind])
+			}}, true
+		}
+	}
+	return filterField{}, false
+}
+
+// --- evaluation ---
+
+type filterNode interface {
+	eval(p *PeerSummary) bool
+}
+
+type andNode struct{ l, r filterNode }
+type orNode struct{ l, r filterNode }
+type notNode struct{ x filterNode }
+
+func (n andNode) eval(p *PeerSummary) bool { return n.l.eval(p) && n.r.eval(p) }
+func (n orNode) eval(p *PeerSummary) bool  { return n.l.eval(p) || n.r.eval(p) }
+func (n notNode) eval(p *PeerSummary) bool { return !n.x.eval(p) }
+
+type cmpNode struct {
+	field  filterField
+	op     string
+	str    string
+	num    float64
+	bl     bool
+	re     *regexp.Regexp
+	addr   netip.Addr   // fieldAddr == and != against an address
+	prefix netip.Prefix // fieldAddr == and != against a prefix
+}
+
+func (n cmpNode) eval(p *PeerSummary) bool {
+	switch n.field.typ {
+	case fieldString:
+		return n.matchString(n.field.str(p))
+	case fieldAddr:
+		if !n.addr.IsValid() && !n.prefix.IsValid() {
+			return n.matchString(n.field.str(p))
+		}
+		a := p.Addr
+		if !a.IsValid() {
+			a = addrKey(p.Address)
+		}
+		var match bool
+		if n.prefix.IsValid() {
+			match = n.prefix.Contains(a.WithZone(""))
+		} else {
+			match = addrMatches(a, n.addr)
+		}
+		return match == (n.op == "==")
+	case fieldNumber:
+		v := n.field.num(p)
+		switch n.op {
+		case "==":
+			return v == n.num
+		case "!=":
+			return v != n.num
+		case "<":
+			return v < n.num
+		case "<=":
+			return v <= n.num
+		case ">":
+			return v > n.num
+		case ">=":
+			return v >= n.num
+		}
+	case fieldBool:
+		v := n.field.bl(p)
+		if n.op == "!=" {
+			return v != n.bl
+		}
+		return v == n.bl
+	case fieldList:
+		// Negated operators hold when no element matches the positive form.
+		pos := n
+		switch n.op {
+		case "!=":
+			pos.op = "=="
+		case "!~":
+			pos.op = "=~"
+		}
+		matched := false
+		for _, s := range n.field.lst(p) {
+			if pos.matchString(s) {
+				matched = true
+				break
+			}
+		}
+		if pos.op != n.op {
+			return !matched
+		}
+		return matched
+	}
+	return false
+}
+
+func (n cmpNode) matchString(s string) bool {
+	switch n.op {
+	case "==":
+		return s == n.str
+	case "!=":
+		return s != n.str
+	case "=~":
+		return n.re.MatchString(s)
+	case "!~":
+		return !n.re.MatchString(s)
+	}
+	return false
+}
+
+// --- lexer ---
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type filterToken struct {
+	kind tokKind
+	text string // identifier, operator, or unquoted string literal
+	pos  int
+}
+
+var filterOps = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!"}
+
+func lexFilter(src string) ([]filterToken, error) {
+	var toks []filterToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			toks = append(toks, filterToken{tokLParen, "(", i})
+			i++
+		case c == ')':
+			toks = append(toks, filterToken{tokRParen, ")", i})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			s, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("bad string at offset %d: %w", i, err)
+			}
+			toks = append(toks, filterToken{tokString, s, i})
+			i = end + 1
+		case c == '`':
+			// Raw string, handy for regexps: mac =~ `^dc:a6`
+			end := strings.IndexByte(src[i+1:], '`')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			toks = append(toks, filterToken{tokString, src[i+1 : i+1+end], i})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '-':
+			end := i + 1
+			for end < len(src) && (src[end] >= '0' && src[end] <= '9' || src[end] == '.') {
+				end++
+			}
+			toks = append(toks, filterToken{tokNumber, src[i:end], i})
+			i = end
+		case isIdentRune(rune(c)):
+			end := i
+			for end < len(src) && (isIdentRune(rune(src[end])) || src[end] == '.' || src[end] >= '0' && src[end] <= '9') {
+				end++
+			}
+			toks = append(toks, filterToken{tokIdent, src[i:end], i})
+			i = end
+		default:
+			op := ""
+			for _, o := range filterOps {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			toks = append(toks, filterToken{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, filterToken{tokEOF, "end of expression", len(src)}), nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// --- parser ---
+
+type filterParser struct {
+	toks []filterToken
+	i    int
+}
+
+func (p *filterParser) peek() filterToken { return p.toks[p.i] }
+
+func (p *filterParser) next() filterToken {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "||" {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "&&" {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if t := p.peek(); t.kind == tokOp && t.text == "!" {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tokRParen {
+			return nil, fmt.Errorf("expected ) at offset %d, got %q", c.pos, c.text)
+		}
+		return x, nil
+	case tokIdent:
+		return p.parseComparison(t)
+	default:
+		return nil, fmt.Errorf("expected field name at offset %d, got %q", t.pos, t.text)
+	}
+}
+
+func (p *filterParser) parseComparison(name filterToken) (filterNode, error) {
+	field, ok := lookupFilterField(name.text)
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q at offset %d", name.text, name.pos)
+	}
+
+	op := p.peek()
+	if op.kind != tokOp || op.text == "&&" || op.text == "||" || op.text == "!" {
+		// Bare boolean field: "stale" means "stale == true".
+		if field.typ == fieldBool {
+			return cmpNode{field: field, op: "==", bl: true}, nil
+		}
+		return nil, fmt.Errorf("expected operator after %q at offset %d", name.text, op.pos)
+	}
+	p.next()
+	val := p.next()
+	n := cmpNode{field: field, op: op.text}
+
+	switch field.typ {
+	case fieldString, fieldAddr, fieldList:
+		if val.kind != tokString {
+			return nil, fmt.Errorf("%s needs a quoted string at offset %d", name.text, val.pos)
+		}
+		switch op.text {
+		case "==", "!=":
+			n.str = val.text
+			if field.typ == fieldAddr {
+				if prefix, err := netip.ParsePrefix(val.text); err == nil {
+					n.prefix = prefix.Masked()
+				} else if addr, err := netip.ParseAddr(val.text); err == nil {
+					n.addr = addr
+				}
+			}
+		case "=~", "!~":
+			re, err := regexp.Compile(val.text)
+			if err != nil {
+				return nil, fmt.Errorf("bad regexp at offset %d: %w", val.pos, err)
+			}
+			n.re = re
+		default:
+			return nil, fmt.Errorf("operator %s not supported for %s", op.text, name.text)
+		}
+	case fieldNumber:
+		if val.kind != tokNumber {
+			return nil, fmt.Errorf("%s needs a number at offset %d", name.text, val.pos)
+		}
+		if op.text == "=~" || op.text == "!~" {
+			return nil, fmt.Errorf("operator %s not supported for %s", op.text, name.text)
+		}
+		f, err := strconv.ParseFloat(val.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at offset %d", val.text, val.pos)
+		}
+		n.num = f
+	case fieldBool:
+		if val.kind != tokIdent || (val.text != "true" && val.text != "false") {
+			return nil, fmt.Errorf("%s needs true or false at offset %d", name.text, val.pos)
+		}
+		if op.text != "==" && op.text != "!=" {
+			return nil, fmt.Errorf("operator %s not supported for %s", op.text, name.text)
+		}
+		n.bl = val.text == "true"
+	}
+	return n, nil
+}
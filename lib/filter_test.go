@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilter_Match(t *testing.T) {
+	peer := PeerSummary{
+		Address:   "fe80::dea6:32ff:fe01:203",
+		MAC:       "dc:a6:32:01:02:03",
+		Interface: "eth0",
+		HopLimit:  255,
+		Counts:    map[string]int{"router_advertisement": 4, "neighbor_solicitation": 1},
+		Total:     5,
+		Groups:    []string{"ff02::1", "ff02::fb"},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`iface == "eth0" && counts.ra > 0 && mac =~ "^dc:a6"`, true},
+		{`iface == "eth1"`, false},
+		{`iface != "eth1"`, true},
+		{`counts.ns >= 1 && counts.na == 0`, true},
+		{`counts.router_advertisement == 4`, true},
+		{`total < 5 || hop_limit == 255`, true},
+		{`!(total > 3)`, false},
+		{`mac !~ "^dc:a6"`, false},
+		{"mac =~ `^DC`", false},
+		{`stale`, false},
+		{`!stale`, true},
+		{`stale == false`, true},
+		{`groups == "ff02::fb"`, true},
+		{`groups != "ff02::fb"`, false},
+		{`groups =~ "^ff02::1:ff"`, false},
+		{`groups !~ "^ff02::1:ff"`, true},
+		{`addr =~ "^fe80:" && (os == "" || os == "Router")`, true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(peer); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestFilter_ParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`bogus == 1`,
+		`counts.xx > 0`,
+		`iface == eth0`,
+		`iface > "a"`,
+		`total == "5"`,
+		`total =~ "5"`,
+		`stale == yes`,
+		`mac =~ "("`,
+		`(total > 1`,
+		`total > 1)`,
+		`iface == "eth0`,
+		`total`,
+		`total > 1 &&`,
+		`iface == "a" # x`,
+	} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestFilterPeers(t *testing.T) {
+	peers := []PeerSummary{
+		{Address: "fe80::1", Total: 3},
+		{Address: "fe80::2", Total: 1},
+	}
+	f, err := ParseFilter("total > 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := FilterPeers(peers, f)
+	if len(got) != 1 || got[0].Address != "fe80::1" {
+		t.Errorf("FilterPeers = %+v, want only fe80::1", got)
+	}
+	if got := FilterPeers(peers, nil); len(got) != 2 {
+		t.Errorf("FilterPeers(nil) returned %d peers, want 2", len(got))
+	}
+}
+
+func TestSetIgnore(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_advertisement")
+	stats.RecordMessage("fe80::2", "neighbor_solicitation")
+
+	f, err := ParseFilter("counts.ra > 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats.SetIgnore([]*Filter{f})
+
+	got := stats.GetStats()
+	if len(got) != 1 || got[0].Address != "fe80::2" {
+		t.Errorf("GetStats = %+v, want only fe80::2", got)
+	}
+}
@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"NDPeekr/lib/craft"
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// fixtureSpacing separates consecutive packets in the generated pcaps, well
+// beyond duplicateWindow so identical fixtures never look like a loop.
+const fixtureSpacing = 100 * time.Millisecond
+
+// GenPcapConfig configures WriteFixturePcaps.
+type GenPcapConfig struct {
+	Dir      string // output directory, which must exist
+	Ethernet bool   // write Ethernet frames instead of raw IPv6 packets
+	// Only selects fixtures by name with path.Match patterns; empty
+	// selects every fixture.
+	Only []string
+	// Start is the timestamp of the first packet.
+	Start time.Time
+}
+
+// SelectFixtures returns the fixtures whose names match any of patterns, all
+// of them if there are none. A pattern matching nothing is an error, so a
+// typo doesn't silently produce an empty corpus.
+func SelectFixtures(patterns []string) ([]craft.Fixture, error) {
+	all := craft.Fixtures()
+	if len(patterns) == 0 {
+		return all, nil
+	}
+	var selected []craft.Fixture
+	matched := make(map[string]bool)
+	for _, f := range all {
+		for _, p := range patterns {
+			ok, err := path.Match(p, f.Name)
+			if err != nil {
+				return nil, fmt.Errorf("fixture pattern %q: %w", p, err)
+			}
+			if ok {
+				selected = append(selected, f)
+				matched[p] = true
+				break
+			}
+		}
+	}
+	for _, p := range patterns {
+		if !matched[p] {
+			return nil, fmt.Errorf("no fixture matches %q", p)
+		}
+	}
+	return selected, nil
+}
+
+// WriteFixturePcaps writes the test-lab corpus (see craft.Fixtures) to
+// cfg.Dir: one <name>.pcap per fixture, valid.pcap and malformed.pcap with
+// each kind, and all.pcap with every selected fixture in order. It returns
+// the paths written.
+func WriteFixturePcaps(cfg GenPcapConfig) ([]string, error) {
+	fixtures, err := SelectFixtures(cfg.Only)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Start.IsZero() {
+		cfg.Start = time.Now()
+	}
+
+	var valid, malformed []craft.Fixture
+	for _, f := range fixtures {
+		if f.Malformed {
+			malformed = append(malformed, f)
+		} else {
+			valid = append(valid, f)
+		}
+	}
+
+	var paths []string
+	write := func(name string, set []craft.Fixture) error {
+		if len(set) == 0 {
+			return nil
+		}
+		p := filepath.Join(cfg.Dir, name+".pcap")
+		if err := writeFileAtomic(p, fixturePcap(set, cfg.Ethernet, cfg.Start)); err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	}
+	for _, f := range fixtures {
+		if err := write(f.Name, []craft.Fixture{f}); err != nil {
+			return paths, err
+		}
+	}
+	for _, set := range []struct {
+		name     string
+		fixtures []craft.Fixture
+	}{{"valid", valid}, {"malformed", malformed}, {"all", fixtures}} {
+		if err := write(set.name, set.fixtures); err != nil {
+			return paths, err
+		}
+	}
+	return paths, nil
+}
+
+// fixturePcap encodes fixtures as a pcap, fixtureSpacing apart from start.
+func fixturePcap(fixtures []craft.Fixture, ethernet bool, start time.Time) []byte {
+	var b bytes.Buffer
+	linkType := uint32(linkTypeRaw)
+	if ethernet {
+		linkType = linkTypeEthernet
+	}
+	// Writes to a bytes.Buffer don't fail.
+	_ = writePcapHeader(&b, linkType)
+	for i, f := range fixtures {
+		data := f.Packet
+		if ethernet {
+			data = f.Frame()
+		}
+		_ = writePcapRecord(&b, start.Add(time.Duration(i)*fixtureSpacing), data)
+	}
+	return b.Bytes()
+}
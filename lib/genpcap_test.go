@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteFixturePcaps_Replay(t *testing.T) {
+	for _, ethernet := range []bool{false, true} {
+		dir := t.TempDir()
+		paths, err := WriteFixturePcaps(GenPcapConfig{Dir: dir, Ethernet: ethernet})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fixtures, _ := SelectFixtures(nil)
+		if want := len(fixtures) + 3; len(paths) != want {
+			t.Errorf("wrote %d files, want %d", len(paths), want)
+		}
+
+		stats := NewNDPStats(5 * time.Minute)
+		l := NewNDPListener(NDPListenerConfig{
+			Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+			Stats:    stats,
+			Capture:  CapturePcap,
+			PcapFile: filepath.Join(dir, "all.pcap"),
+		})
+		if err := l.Run(context.Background()); err != nil {
+			t.Fatalf("ethernet=%v: Run: %v", ethernet, err)
+		}
+
+		seen := stats.MessageSummary()
+		for _, kind := range []string{"router_solicitation", "router_advertisement", "neighbor_solicitation",
+			"neighbor_advertisement", "redirect", "mld_query", "mld_report", "mld_done"} {
+			if seen.Type(kind).Count == 0 {
+				t.Errorf("ethernet=%v: no %s replayed", ethernet, kind)
+			}
+		}
+		if got := stats.ChecksumFailures(); got != 1 {
+			t.Errorf("ethernet=%v: checksum failures = %d, want 1", ethernet, got)
+		}
+		anomalies := stats.GetExtHeaderAnomalies()
+		if anomalies["fragment"] != 1 || anomalies["routing_header"] != 1 {
+			t.Errorf("ethernet=%v: extension header anomalies = %v", ethernet, anomalies)
+		}
+		if got := stats.GetMLDRouterAlertViolations(); got[raNoHopByHop] != 1 {
+			t.Errorf("ethernet=%v: router alert violations = %v", ethernet, got)
+		}
+		if dups := stats.GetDuplicates(); len(dups) > 0 && dups[0].Duplicates != 0 {
+			t.Errorf("ethernet=%v: fixtures counted as duplicates: %+v", ethernet, dups)
+		}
+	}
+}
+
+func TestSelectFixtures(t *testing.T) {
+	got, err := SelectFixtures([]string{"ra-*", "ns-dad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range got {
+		if f.Name != "ns-dad" && f.Name[:3] != "ra-" {
+			t.Errorf("selected %s", f.Name)
+		}
+	}
+	if len(got) < 5 {
+		t.Errorf("selected %d fixtures, want every RA and ns-dad", len(got))
+	}
+	if _, err := SelectFixtures([]string{"nope-*"}); err == nil {
+		t.Error("pattern matching nothing: expected error")
+	}
+}
@@ -0,0 +1,751 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// gNMI target (https://github.com/openconfig/reference/tree/master/rpc/gnmi)
+// publishing the "ndpeekr" YANG tree (ndpeekr.yang) to telemetry
+// collectors. It implements the Capabilities and Subscribe RPCs over gRPC,
+// with the few protobuf messages involved encoded by hand:
+//
+//	/ndpeekr/routers/router[address=<addr>]/...
+//	/ndpeekr/neighbors/neighbor[address=<addr>]/...
+//	/ndpeekr/alerts/alert[time=<t>][category=<c>][source=<addr>]/...
+//
+// STREAM subscriptions get the routers and neighbors every sample interval
+// (only the changed leaves if every subscription is ON_CHANGE), deletes for
+// entries that left the window, and alerts as they are raised.
+
+const (
+	defaultGNMIInterval = 10 * time.Second
+	minGNMIInterval     = time.Second
+	gnmiAlertPoll       = time.Second
+	gnmiVersion         = "0.10.0"
+	grpcMaxMessage      = 4 << 20
+)
+
+// gRPC status codes.
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcUnimplemented   = 12
+)
+
+// gNMI enums.
+const (
+	gnmiModeStream = 0 // SubscriptionList.Mode
+	gnmiModeOnce   = 1
+	gnmiModePoll   = 2
+
+	gnmiOnChange = 1 // SubscriptionMode
+
+	gnmiEncodingJSON     = 0
+	gnmiEncodingProto    = 2
+	gnmiEncodingJSONIETF = 4
+)
+
+// gnmiListKeys names the key leaf of the YANG lists nested in entries.
+var gnmiListKeys = map[string]string{
+	"message-count": "type",
+	"prefix":        "prefix",
+}
+
+type gnmiElem struct {
+	name string
+	keys map[string]string
+}
+
+// gnmiPath is a gNMI path as a list of elements.
+type gnmiPath []gnmiElem
+
+func (p gnmiPath) String() string {
+	var b strings.Builder
+	for _, e := range p {
+		b.WriteString("/" + e.name)
+		for _, k := range slices.Sorted(maps.Keys(e.keys)) {
+			fmt.Fprintf(&b, "[%s=%s]", k, e.keys[k])
+		}
+	}
+	if b.Len() == 0 {
+		return "/"
+	}
+	return b.String()
+}
+
+func (p gnmiPath) child(name string, keys map[string]string) gnmiPath {
+	return append(p[:len(p):len(p)], gnmiElem{name: name, keys: keys})
+}
+
+// matches reports whether p lies under pattern, which may use "*" for an
+// element name or key value and end in "...".
+func (p gnmiPath) matches(pattern gnmiPath) bool {
+	for i, pe := range pattern {
+		if pe.name == "..." {
+			return true
+		}
+		if i >= len(p) {
+			return false
+		}
+		if pe.name != "*" && pe.name != p[i].name {
+			return false
+		}
+		for k, v := range pe.keys {
+			got, ok := p[i].keys[k]
+			switch {
+			case v == "*":
+			case !ok:
+				return false
+			case k == "address" && sameAddress(got, v):
+			case got != v:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// encode returns the Path message.
+func (p gnmiPath) encode() []byte {
+	var b []byte
+	for _, e := range p {
+		eb := pbAppendString(nil, 1, e.name)
+		for _, k := range slices.Sorted(maps.Keys(e.keys)) {
+			eb = pbAppendBytes(eb, 2, pbAppendString(pbAppendString(nil, 1, k), 2, e.keys[k]))
+		}
+		b = pbAppendBytes(b, 3, eb)
+	}
+	return b
+}
+
+func parseGNMIPath(b []byte) (gnmiPath, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, err
+	}
+	var p gnmiPath
+	for _, f := range fields {
+		switch {
+		case f.num == 2 && f.wire == pbBytes:
+			if origin := string(f.data); origin != "" && origin != yangModuleName {
+				return nil, fmt.Errorf("unknown origin %q", origin)
+			}
+		case f.num == 3 && f.wire == pbBytes:
+			e, err := parseGNMIElem(f.data)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, e)
+		}
+	}
+	return p, nil
+}
+
+func parseGNMIElem(b []byte) (gnmiElem, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return gnmiElem{}, err
+	}
+	var e gnmiElem
+	for _, f := range fields {
+		switch {
+		case f.num == 1 && f.wire == pbBytes:
+			// Clients may qualify the top element with its module name.
+			e.name = strings.TrimPrefix(string(f.data), yangModuleName+":")
+		case f.num == 2 && f.wire == pbBytes:
+			kv, err := pbParse(f.data)
+			if err != nil {
+				return gnmiElem{}, err
+			}
+			var k, v string
+			for _, f := range kv {
+				switch f.num {
+				case 1:
+					k = string(f.data)
+				case 2:
+					v = string(f.data)
+				}
+			}
+			if e.keys == nil {
+				e.keys = make(map[string]string)
+			}
+			e.keys[k] = v
+		}
+	}
+	return e, nil
+}
+
+// gnmiLeaf is a value at a path relative to its entry.
+type gnmiLeaf struct {
+	path gnmiPath
+	val  any // string, bool, json.Number or []any of those
+}
+
+// gnmiEntry is one list entry: a router, neighbor or alert.
+type gnmiEntry struct {
+	prefix gnmiPath
+	leaves []gnmiLeaf
+}
+
+var gnmiRoot = gnmiPath{{name: yangModuleName}}
+
+// gnmiStateEntries returns the routers and neighbors in snap.
+func gnmiStateEntries(snap Snapshot) []gnmiEntry {
+	var out []gnmiEntry
+	routers := gnmiRoot.child("routers", nil)
+	for _, r := range snap.Routers {
+		out = append(out, gnmiListEntry(routers.child("router", map[string]string{"address": r.Address}), toYANGRouter(r)))
+	}
+	neighbors := gnmiRoot.child("neighbors", nil)
+	for _, p := range snap.Peers {
+		out = append(out, gnmiListEntry(neighbors.child("neighbor", map[string]string{"address": p.Address}), toYANGNeighbor(p)))
+	}
+	return out
+}
+
+// gnmiAlertEntry returns a as an alert list entry.
+func gnmiAlertEntry(a Alert) gnmiEntry {
+	prefix := gnmiRoot.child("alerts", nil).child("alert", map[string]string{
+		"time":     yangTime(a.Time),
+		"category": a.Category,
+		"source":   a.Source,
+	})
+	leaves := []gnmiLeaf{
+		{gnmiPath{{name: "time"}}, yangTime(a.Time)},
+		{gnmiPath{{name: "severity"}}, a.Severity.String()},
+		{gnmiPath{{name: "category"}}, a.Category},
+		{gnmiPath{{name: "source"}}, a.Source},
+		{gnmiPath{{name: "message"}}, a.Message},
+	}
+	if a.Port != "" {
+		leaves = append(leaves, gnmiLeaf{gnmiPath{{name: "port"}}, a.Port})
+	}
+	if a.Maintenance != "" {
+		leaves = append(leaves, gnmiLeaf{gnmiPath{{name: "maintenance"}}, a.Maintenance})
+	}
+	return gnmiEntry{prefix: prefix, leaves: leaves}
+}
+
+// gnmiListEntry flattens v's RFC 7951 JSON encoding into leaves.
+func gnmiListEntry(prefix gnmiPath, v any) gnmiEntry {
+	e := gnmiEntry{prefix: prefix}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return e
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return e
+	}
+	gnmiFlatten(nil, tree, &e.leaves)
+	return e
+}
+
+func gnmiFlatten(path gnmiPath, v any, out *[]gnmiLeaf) {
+	switch x := v.(type) {
+	case map[string]any:
+		for _, k := range slices.Sorted(maps.Keys(x)) {
+			gnmiFlatten(path.child(k, nil), x[k], out)
+		}
+	case []any:
+		if len(x) == 0 {
+			return
+		}
+		if _, ok := x[0].(map[string]any); !ok {
+			*out = append(*out, gnmiLeaf{path, x}) // leaf-list
+			return
+		}
+		keyLeaf := gnmiListKeys[path[len(path)-1].name]
+		parent, name := path[:len(path)-1], path[len(path)-1].name
+		for _, item := range x {
+			obj, _ := item.(map[string]any)
+			key := fmt.Sprint(obj[keyLeaf])
+			gnmiFlatten(parent.child(name, map[string]string{keyLeaf: key}), obj, out)
+		}
+	default:
+		*out = append(*out, gnmiLeaf{path, x})
+	}
+}
+
+// gnmiTypedValue encodes a TypedValue.
+func gnmiTypedValue(v any) []byte {
+	switch x := v.(type) {
+	case string:
+		return pbAppendString(nil, 1, x)
+	case bool:
+		return pbAppendBool(nil, 4, x)
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return pbAppendUint(nil, 2, uint64(i))
+		}
+		f, _ := x.Float64()
+		return pbAppendDouble(nil, 14, f)
+	case []any:
+		var arr []byte
+		for _, e := range x {
+			arr = pbAppendBytes(arr, 1, gnmiTypedValue(e))
+		}
+		return pbAppendBytes(nil, 8, arr)
+	}
+	return pbAppendString(nil, 1, fmt.Sprint(v))
+}
+
+// gnmiNotification encodes a SubscribeResponse carrying one Notification.
+func gnmiNotification(ts time.Time, prefix gnmiPath, leaves []gnmiLeaf, deletes []gnmiPath) []byte {
+	n := pbAppendUint(nil, 1, uint64(ts.UnixNano()))
+	if len(prefix) > 0 {
+		n = pbAppendBytes(n, 2, prefix.encode())
+	}
+	for _, l := range leaves {
+		u := pbAppendBytes(nil, 1, l.path.encode())
+		u = pbAppendBytes(u, 3, gnmiTypedValue(l.val))
+		n = pbAppendBytes(n, 4, u)
+	}
+	for _, d := range deletes {
+		n = pbAppendBytes(n, 5, d.encode())
+	}
+	return pbAppendBytes(nil, 1, n)
+}
+
+// gnmiSyncResponse marks the end of the initial updates.
+var gnmiSyncResponse = pbAppendBool(nil, 3, true)
+
+// gnmiSubscription is one Subscription of a SubscriptionList.
+type gnmiSubscription struct {
+	path     gnmiPath // including the list prefix
+	mode     int
+	interval time.Duration
+}
+
+// gnmiSubscribeList is a decoded SubscriptionList.
+type gnmiSubscribeList struct {
+	subs        []gnmiSubscription
+	mode        int
+	encoding    int
+	updatesOnly bool
+}
+
+// parseGNMISubscribeRequest decodes a SubscribeRequest: either a
+// subscription list or, for POLL mode, a poll trigger.
+func parseGNMISubscribeRequest(b []byte) (list *gnmiSubscribeList, poll bool, err error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, f := range fields {
+		switch {
+		case f.num == 1 && f.wire == pbBytes:
+			if list, err = parseGNMISubscriptionList(f.data); err != nil {
+				return nil, false, err
+			}
+		case f.num == 3 && f.wire == pbBytes:
+			poll = true
+		}
+	}
+	return list, poll, nil
+}
+
+func parseGNMISubscriptionList(b []byte) (*gnmiSubscribeList, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, err
+	}
+	list := &gnmiSubscribeList{}
+	var prefix gnmiPath
+	var subs [][]byte
+	for _, f := range fields {
+		switch {
+		case f.num == 1 && f.wire == pbBytes:
+			if prefix, err = parseGNMIPath(f.data); err != nil {
+				return nil, fmt.Errorf("prefix: %w", err)
+			}
+		case f.num == 2 && f.wire == pbBytes:
+			subs = append(subs, f.data)
+		case f.num == 5 && f.wire == pbVarint:
+			list.mode = int(f.v)
+		case f.num == 8 && f.wire == pbVarint:
+			list.encoding = int(f.v)
+		case f.num == 9 && f.wire == pbVarint:
+			list.updatesOnly = f.v != 0
+		}
+	}
+	for _, data := range subs {
+		fields, err := pbParse(data)
+		if err != nil {
+			return nil, err
+		}
+		sub := gnmiSubscription{path: prefix}
+		for _, f := range fields {
+			switch {
+			case f.num == 1 && f.wire == pbBytes:
+				p, err := parseGNMIPath(f.data)
+				if err != nil {
+					return nil, fmt.Errorf("subscription path: %w", err)
+				}
+				sub.path = append(prefix[:len(prefix):len(prefix)], p...)
+			case f.num == 2 && f.wire == pbVarint:
+				sub.mode = int(f.v)
+			case f.num == 3 && f.wire == pbVarint:
+				sub.interval = time.Duration(min(f.v, math.MaxInt64))
+			}
+		}
+		list.subs = append(list.subs, sub)
+	}
+	if len(list.subs) == 0 {
+		list.subs = append(list.subs, gnmiSubscription{path: prefix})
+	}
+	return list, nil
+}
+
+// gnmiSession tracks what one subscription has been sent.
+type gnmiSession struct {
+	list     *gnmiSubscribeList
+	onChange bool                // only send leaves whose value changed
+	sent     map[string]gnmiPath // state entries sent, by path
+	values   map[string]string   // last value sent per leaf, for onChange
+}
+
+func newGNMISession(list *gnmiSubscribeList) *gnmiSession {
+	s := &gnmiSession{
+		list:     list,
+		onChange: list.mode == gnmiModeStream,
+		sent:     make(map[string]gnmiPath),
+		values:   make(map[string]string),
+	}
+	for _, sub := range list.subs {
+		if sub.mode != gnmiOnChange {
+			s.onChange = false
+		}
+	}
+	return s
+}
+
+// interval returns the shortest sample interval asked for, or def.
+func (s *gnmiSession) interval(def time.Duration) time.Duration {
+	d := time.Duration(0)
+	for _, sub := range s.list.subs {
+		if sub.interval > 0 && (d == 0 || sub.interval < d) {
+			d = sub.interval
+		}
+	}
+	if d == 0 {
+		d = def
+	}
+	return max(d, minGNMIInterval)
+}
+
+// selected returns the leaves of e that some subscription covers.
+func (s *gnmiSession) selected(e gnmiEntry) []gnmiLeaf {
+	var out []gnmiLeaf
+	for _, l := range e.leaves {
+		full := append(e.prefix[:len(e.prefix):len(e.prefix)], l.path...)
+		for _, sub := range s.list.subs {
+			if full.matches(sub.path) {
+				out = append(out, l)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// state returns the notifications for the routers and neighbors in snap:
+// one per entry, then one deleting entries sent before that are gone.
+func (s *gnmiSession) state(snap Snapshot) [][]byte {
+	var msgs [][]byte
+	current := make(map[string]bool)
+	for _, e := range gnmiStateEntries(snap) {
+		leaves := s.selected(e)
+		if len(leaves) == 0 {
+			continue
+		}
+		key := e.prefix.String()
+		current[key] = true
+		s.sent[key] = e.prefix
+		if s.onChange {
+			changed := leaves[:0:0]
+			for _, l := range leaves {
+				lk, v := key+l.path.String(), fmt.Sprint(l.val)
+				if s.values[lk] != v {
+					s.values[lk] = v
+					changed = append(changed, l)
+				}
+			}
+			leaves = changed
+		}
+		if len(leaves) > 0 {
+			msgs = append(msgs, gnmiNotification(snap.Taken, e.prefix, leaves, nil))
+		}
+	}
+	var deletes []gnmiPath
+	for key, p := range s.sent {
+		if !current[key] {
+			deletes = append(deletes, p)
+			delete(s.sent, key)
+			for lk := range s.values {
+				if strings.HasPrefix(lk, key+"/") {
+					delete(s.values, lk)
+				}
+			}
+		}
+	}
+	if len(deletes) > 0 {
+		sort.Slice(deletes, func(i, j int) bool { return deletes[i].String() < deletes[j].String() })
+		msgs = append(msgs, gnmiNotification(snap.Taken, nil, nil, deletes))
+	}
+	return msgs
+}
+
+// alerts returns the notifications for alerts, oldest first.
+func (s *gnmiSession) alerts(alerts []Alert) [][]byte {
+	var msgs [][]byte
+	for _, a := range alerts {
+		e := gnmiAlertEntry(a)
+		if leaves := s.selected(e); len(leaves) > 0 {
+			msgs = append(msgs, gnmiNotification(a.Time, e.prefix, leaves, nil))
+		}
+	}
+	return msgs
+}
+
+// gnmiTarget serves the gNMI RPCs.
+type gnmiTarget struct {
+	stats    *NDPStats
+	logger   *slog.Logger
+	interval time.Duration
+}
+
+// GNMIHandler serves the gNMI Capabilities and Subscribe RPCs over gRPC,
+// on HTTP/2 with or without TLS. interval is the default sample interval
+// of STREAM subscriptions (default 10s).
+func GNMIHandler(stats *NDPStats, interval time.Duration, logger *slog.Logger) http.Handler {
+	if interval <= 0 {
+		interval = defaultGNMIInterval
+	}
+	g := &gnmiTarget{stats: stats, logger: logger, interval: interval}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /gnmi.gNMI/Capabilities", g.capabilities)
+	mux.HandleFunc("POST /gnmi.gNMI/Subscribe", g.subscribe)
+	mux.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		grpcStart(w)
+		grpcFinish(w, grpcUnimplemented, "method "+r.URL.Path+" not implemented")
+	})
+	return h2c.NewHandler(mux, &http2.Server{})
+}
+
+// ServeGNMI runs the gNMI target until ctx is cancelled.
+func ServeGNMI(ctx context.Context, cfg GNMISinkConfig, stats *NDPStats, logger *slog.Logger) error {
+	srv := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           GNMIHandler(stats, cfg.Interval, logger),
+		ReadHeaderTimeout: 5 * time.Second,
+		// Streams end with the context, so Shutdown doesn't wait for them.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("serving gnmi", "listen", cfg.Listen, "tls", cfg.TLSCert != "")
+	var err error
+	if cfg.TLSCert != "" {
+		err = srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("gnmi sink: %w", err)
+	}
+	return nil
+}
+
+func (g *gnmiTarget) capabilities(w http.ResponseWriter, r *http.Request) {
+	grpcStart(w)
+	if _, err := grpcRead(r.Body); err != nil {
+		grpcFinish(w, grpcInvalidArgument, err.Error())
+		return
+	}
+	model := pbAppendString(nil, 1, yangModuleName)
+	model = pbAppendString(model, 2, "NDPeekr")
+	model = pbAppendString(model, 3, yangModuleRevision)
+	resp := pbAppendBytes(nil, 1, model)
+	var encodings []byte
+	for _, e := range []uint64{gnmiEncodingJSON, gnmiEncodingProto, gnmiEncodingJSONIETF} {
+		encodings = pbAppendVarint(encodings, e)
+	}
+	resp = pbAppendBytes(resp, 2, encodings)
+	resp = pbAppendString(resp, 3, gnmiVersion)
+	if err := grpcWrite(w, resp); err != nil {
+		return
+	}
+	grpcFinish(w, grpcOK, "")
+}
+
+func (g *gnmiTarget) subscribe(w http.ResponseWriter, r *http.Request) {
+	grpcStart(w)
+	msg, err := grpcRead(r.Body)
+	if err != nil {
+		grpcFinish(w, grpcInvalidArgument, err.Error())
+		return
+	}
+	list, _, err := parseGNMISubscribeRequest(msg)
+	switch {
+	case err != nil:
+		grpcFinish(w, grpcInvalidArgument, err.Error())
+		return
+	case list == nil:
+		grpcFinish(w, grpcInvalidArgument, "first SubscribeRequest must carry a subscription list")
+		return
+	case list.encoding != gnmiEncodingJSON && list.encoding != gnmiEncodingProto && list.encoding != gnmiEncodingJSONIETF:
+		grpcFinish(w, grpcUnimplemented, fmt.Sprintf("encoding %d not supported", list.encoding))
+		return
+	case list.mode > gnmiModePoll:
+		grpcFinish(w, grpcInvalidArgument, fmt.Sprintf("unknown subscription mode %d", list.mode))
+		return
+	}
+
+	sess := newGNMISession(list)
+	_, seq := g.stats.AlertsSince(math.MaxUint64)
+	initial := func() error {
+		snap := g.stats.Snapshot()
+		state := sess.state(snap)
+		if !list.updatesOnly {
+			slices.Reverse(snap.Alerts)
+			if err := grpcWriteAll(w, append(state, sess.alerts(snap.Alerts)...)); err != nil {
+				return err
+			}
+		}
+		return grpcWrite(w, gnmiSyncResponse)
+	}
+	if err := initial(); err != nil {
+		return
+	}
+
+	switch list.mode {
+	case gnmiModeOnce:
+		grpcFinish(w, grpcOK, "")
+	case gnmiModePoll:
+		for {
+			msg, err := grpcRead(r.Body)
+			if errors.Is(err, io.EOF) {
+				grpcFinish(w, grpcOK, "")
+				return
+			}
+			if err != nil {
+				return
+			}
+			if _, poll, err := parseGNMISubscribeRequest(msg); err != nil || !poll {
+				grpcFinish(w, grpcInvalidArgument, "only Poll requests may follow a POLL subscription")
+				return
+			}
+			// Every poll is answered with the complete state.
+			sess.sent, sess.values = make(map[string]gnmiPath), make(map[string]string)
+			list.updatesOnly = false
+			if err := initial(); err != nil {
+				return
+			}
+		}
+	case gnmiModeStream:
+		go func() { _, _ = io.Copy(io.Discard, r.Body) }()
+		sample := time.NewTicker(sess.interval(g.interval))
+		defer sample.Stop()
+		alertTick := time.NewTicker(gnmiAlertPoll)
+		defer alertTick.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-sample.C:
+				if err := grpcWriteAll(w, sess.state(*g.stats.Shared())); err != nil {
+					return
+				}
+			case <-alertTick.C:
+				var alerts []Alert
+				alerts, seq = g.stats.AlertsSince(seq)
+				if err := grpcWriteAll(w, sess.alerts(alerts)); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// grpcStart sends the response headers of a gRPC call.
+func grpcStart(w http.ResponseWriter) {
+	h := w.Header()
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcFinish sets the status trailers that end a gRPC call.
+func grpcFinish(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set("Grpc-Message", url.PathEscape(msg))
+	}
+}
+
+// grpcWrite sends one length-prefixed message.
+func grpcWrite(w http.ResponseWriter, msg []byte) error {
+	var hdr [5]byte
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(msg)))
+	if _, err := w.Write(append(hdr[:], msg...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+func grpcWriteAll(w http.ResponseWriter, msgs [][]byte) error {
+	for _, m := range msgs {
+		if err := grpcWrite(w, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grpcRead reads one length-prefixed message.
+func grpcRead(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if hdr[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > grpcMaxMessage {
+		return nil, fmt.Errorf("message of %d bytes exceeds %d", n, grpcMaxMessage)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,309 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// gnmiResponse is a decoded SubscribeResponse.
+type gnmiResponse struct {
+	sync    bool
+	prefix  string
+	updates map[string]string // full path -> value
+	deletes []string
+}
+
+func decodeGNMIResponse(t *testing.T, msg []byte) gnmiResponse {
+	t.Helper()
+	fields, err := pbParse(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gnmiResponse{updates: make(map[string]string)}
+	for _, f := range fields {
+		switch f.num {
+		case 3:
+			r.sync = f.v != 0
+		case 1:
+			n, err := pbParse(f.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var prefix gnmiPath
+			for _, f := range n {
+				switch f.num {
+				case 2:
+					prefix, _ = parseGNMIPath(f.data)
+					r.prefix = prefix.String()
+				case 4:
+					u, _ := pbParse(f.data)
+					var path gnmiPath
+					var val string
+					for _, f := range u {
+						switch f.num {
+						case 1:
+							path, _ = parseGNMIPath(f.data)
+						case 3:
+							val = decodeTypedValue(t, f.data)
+						}
+					}
+					r.updates[append(prefix[:len(prefix):len(prefix)], path...).String()] = val
+				case 5:
+					p, _ := parseGNMIPath(f.data)
+					r.deletes = append(r.deletes, p.String())
+				}
+			}
+		}
+	}
+	return r
+}
+
+func decodeTypedValue(t *testing.T, b []byte) string {
+	t.Helper()
+	fields, err := pbParse(b)
+	if err != nil || len(fields) != 1 {
+		t.Fatalf("typed value %x: %v", b, err)
+	}
+	switch f := fields[0]; f.num {
+	case 1:
+		return string(f.data)
+	case 2:
+		return strconv.FormatInt(int64(f.v), 10)
+	case 4:
+		if f.v != 0 {
+			return "true"
+		}
+		return "false"
+	case 8:
+		elems, _ := pbParse(f.data)
+		var parts []string
+		for _, e := range elems {
+			parts = append(parts, decodeTypedValue(t, e.data))
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+	t.Fatalf("unexpected TypedValue field %d", fields[0].num)
+	return ""
+}
+
+func gnmiPathMsg(elems ...string) []byte {
+	var p gnmiPath
+	for _, e := range elems {
+		p = append(p, gnmiElem{name: e})
+	}
+	return p.encode()
+}
+
+func gnmiSubscribeMsg(mode int, path []byte, subMode int) []byte {
+	sub := pbAppendBytes(nil, 1, path)
+	sub = pbAppendUint(sub, 2, uint64(subMode))
+	list := pbAppendBytes(nil, 2, sub)
+	list = pbAppendUint(list, 5, uint64(mode))
+	return pbAppendBytes(nil, 1, list)
+}
+
+func grpcFrame(msg []byte) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg))), msg...)
+}
+
+// gnmiCall starts an RPC against srv and returns the response.
+func gnmiCall(t *testing.T, ctx context.Context, srv *httptest.Server, method string, body io.Reader) *http.Response {
+	t.Helper()
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/gnmi.gNMI/"+method, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func newGNMITestServer(t *testing.T, stats *NDPStats) *httptest.Server {
+	srv := httptest.NewServer(GNMIHandler(stats, 0, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGNMICapabilities(t *testing.T) {
+	srv := newGNMITestServer(t, NewNDPStats(5*time.Minute))
+	resp := gnmiCall(t, context.Background(), srv, "Capabilities", bytes.NewReader(grpcFrame(nil)))
+	defer resp.Body.Close()
+
+	msg, err := grpcRead(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.Trailer.Get("Grpc-Status") != "0" {
+		t.Fatalf("grpc-status = %q", resp.Trailer.Get("Grpc-Status"))
+	}
+	fields, _ := pbParse(msg)
+	var version string
+	var model []pbField
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			model, _ = pbParse(f.data)
+		case 3:
+			version = string(f.data)
+		}
+	}
+	if version != gnmiVersion || len(model) < 1 || string(model[0].data) != "ndpeekr" {
+		t.Errorf("capabilities: version %q, model %v", version, model)
+	}
+}
+
+func TestGNMISubscribeOnce(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordMAC("fe80::1", "02:00:00:00:00:01")
+	stats.RecordRouter(RouterInfo{Address: "fe80::ff", LastSeen: time.Now()})
+	srv := newGNMITestServer(t, stats)
+
+	req := grpcFrame(gnmiSubscribeMsg(gnmiModeOnce, gnmiPathMsg("ndpeekr:ndpeekr", "neighbors"), 0))
+	resp := gnmiCall(t, context.Background(), srv, "Subscribe", bytes.NewReader(req))
+	defer resp.Body.Close()
+
+	var got []gnmiResponse
+	for {
+		msg, err := grpcRead(resp.Body)
+		if err != nil {
+			break
+		}
+		got = append(got, decodeGNMIResponse(t, msg))
+	}
+	if resp.Trailer.Get("Grpc-Status") != "0" {
+		t.Fatalf("grpc-status = %q (%s)", resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message"))
+	}
+	if len(got) != 2 || !got[1].sync {
+		t.Fatalf("responses = %+v, want one notification and a sync", got)
+	}
+	n := got[0]
+	if n.prefix != "/ndpeekr/neighbors/neighbor[address=fe80::1]" {
+		t.Errorf("prefix = %s", n.prefix)
+	}
+	for path, want := range map[string]string{
+		n.prefix + "/mac-address":                                     "02:00:00:00:00:01",
+		n.prefix + "/total-messages":                                  "1",
+		n.prefix + "/message-count[type=neighbor_solicitation]/count": "1",
+		n.prefix + "/stale":                                           "false",
+	} {
+		if n.updates[path] != want {
+			t.Errorf("%s = %q, want %q (updates %v)", path, n.updates[path], want, n.updates)
+		}
+	}
+}
+
+func TestGNMISubscribeStreamAlerts(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	srv := newGNMITestServer(t, stats)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		_, _ = pw.Write(grpcFrame(gnmiSubscribeMsg(gnmiModeStream, gnmiPathMsg("ndpeekr", "alerts"), gnmiOnChange)))
+	}()
+	resp := gnmiCall(t, ctx, srv, "Subscribe", pr)
+	defer resp.Body.Close()
+
+	msg, err := grpcRead(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := decodeGNMIResponse(t, msg); !r.sync {
+		t.Fatalf("first response = %+v, want sync with nothing to report", r)
+	}
+
+	stats.RecordAlert(Alert{Time: time.Now(), Severity: SeverityWarning, Category: "rogue_ra", Source: "fe80::66", Message: "rogue"})
+	msg, err = grpcRead(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := decodeGNMIResponse(t, msg)
+	if !strings.HasPrefix(r.prefix, "/ndpeekr/alerts/alert[category=rogue_ra][source=fe80::66][time=") {
+		t.Errorf("alert prefix = %s", r.prefix)
+	}
+	if r.updates[r.prefix+"/severity"] != "warning" || r.updates[r.prefix+"/message"] != "rogue" {
+		t.Errorf("alert updates = %v", r.updates)
+	}
+}
+
+func TestGNMISessionOnChangeAndDeletes(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordMessage("fe80::2", "neighbor_solicitation")
+	sess := newGNMISession(&gnmiSubscribeList{subs: []gnmiSubscription{{path: gnmiPath{{name: "ndpeekr"}}, mode: gnmiOnChange}}})
+
+	if msgs := sess.state(stats.Snapshot()); len(msgs) != 2 {
+		t.Fatalf("initial state: %d notifications, want 2", len(msgs))
+	}
+	if msgs := sess.state(stats.Snapshot()); len(msgs) != 0 {
+		t.Errorf("unchanged state: %d notifications, want none", len(msgs))
+	}
+
+	stats.RecordMessage("fe80::1", "neighbor_advertisement")
+	snap := stats.Snapshot()
+	snap.Peers = snap.Peers[:0]
+	for _, p := range stats.GetStats() {
+		if p.Address == "fe80::1" {
+			snap.Peers = append(snap.Peers, p)
+		}
+	}
+	msgs := sess.state(snap)
+	if len(msgs) != 2 {
+		t.Fatalf("after change: %d notifications, want an update and a delete", len(msgs))
+	}
+	upd := decodeGNMIResponse(t, msgs[0])
+	if upd.updates[upd.prefix+"/total-messages"] != "2" || upd.updates[upd.prefix+"/mac-address"] != "" {
+		t.Errorf("update = %v, want only the changed leaves", upd.updates)
+	}
+	if del := decodeGNMIResponse(t, msgs[1]); len(del.deletes) != 1 || del.deletes[0] != "/ndpeekr/neighbors/neighbor[address=fe80::2]" {
+		t.Errorf("deletes = %v", del.deletes)
+	}
+}
+
+func TestGNMIPathMatches(t *testing.T) {
+	p := gnmiPath{{name: "ndpeekr"}, {name: "neighbors"}, {name: "neighbor", keys: map[string]string{"address": "fe80::1"}}, {name: "mac-address"}}
+	tests := []struct {
+		pattern gnmiPath
+		want    bool
+	}{
+		{nil, true},
+		{gnmiPath{{name: "ndpeekr"}}, true},
+		{gnmiPath{{name: "ndpeekr"}, {name: "routers"}}, false},
+		{gnmiPath{{name: "*"}, {name: "neighbors"}, {name: "neighbor", keys: map[string]string{"address": "*"}}}, true},
+		{gnmiPath{{name: "ndpeekr"}, {name: "neighbors"}, {name: "neighbor", keys: map[string]string{"address": "fe80:0::1"}}}, true},
+		{gnmiPath{{name: "ndpeekr"}, {name: "neighbors"}, {name: "neighbor", keys: map[string]string{"address": "fe80::2"}}}, false},
+		{gnmiPath{{name: "ndpeekr"}, {name: "..."}}, true},
+	}
+	for _, tt := range tests {
+		if got := p.matches(tt.pattern); got != tt.want {
+			t.Errorf("%s matches %s = %v, want %v", p, tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package lib
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SolicitEdge is one peer resolving another: Neighbor Solicitations from
+// Source for Target within the window. Hosts only resolve addresses they
+// are about to send to, so the edges are a passive map of who talks to whom.
+type SolicitEdge struct {
+	Source        string    `json:"source"`
+	Target        string    `json:"target"`
+	Solicitations int       `json:"solicitations"`
+	LastSolicited time.Time `json:"last_solicited"`
+}
+
+// SolicitGraph is the neighbor-resolution graph: every address that
+// solicited or was solicited within the window, and the edges between them.
+type SolicitGraph struct {
+	Nodes []string      `json:"nodes"` // sorted
+	Edges []SolicitEdge `json:"edges"` // by source, then target
+}
+
+// GetSolicitGraph returns who solicited whom within the window.
+func (s *NDPStats) GetSolicitGraph() SolicitGraph {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.solicitGraphLocked(s.now())
+}
+
+// solicitGraphLocked builds the graph from the NS target arrivals. Callers
+// must hold s.mu.
+func (s *NDPStats) solicitGraphLocked(now time.Time) SolicitGraph {
+	cutoff := now.Add(-s.window)
+	type key struct{ source, target string }
+	edges := make(map[key]*SolicitEdge)
+	nodes := make(map[string]bool)
+	for target, ns := range s.nsTargets {
+		for _, a := range ns.arrivals {
+			if !a.at.After(cutoff) {
+				continue
+			}
+			k := key{a.from, target}
+			e, ok := edges[k]
+			if !ok {
+				e = &SolicitEdge{Source: a.from, Target: target}
+				edges[k] = e
+				nodes[a.from] = true
+				nodes[target] = true
+			}
+			e.Solicitations++
+			if a.at.After(e.LastSolicited) {
+				e.LastSolicited = a.at
+			}
+		}
+	}
+
+	g := SolicitGraph{Nodes: make([]string, 0, len(nodes)), Edges: make([]SolicitEdge, 0, len(edges))}
+	for n := range nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Strings(g.Nodes)
+	for _, e := range edges {
+		g.Edges = append(g.Edges, *e)
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Target < b.Target
+	})
+	return g
+}
+
+// Neighbors returns the edges out of each source, busiest source first.
+func (g SolicitGraph) Neighbors() [][]SolicitEdge {
+	var out [][]SolicitEdge
+	for i := 0; i < len(g.Edges); {
+		j := i
+		for j < len(g.Edges) && g.Edges[j].Source == g.Edges[i].Source {
+			j++
+		}
+		out = append(out, g.Edges[i:j])
+		i = j
+	}
+	sort.SliceStable(out, func(i, j int) bool { return len(out[i]) > len(out[j]) })
+	return out
+}
+
+// WriteDOT writes g as a Graphviz digraph, edges labelled with their
+// solicitation counts.
+func (g SolicitGraph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph ndpeekr {\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "  %s;\n", strconv.Quote(n))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=\"%d\"];\n", strconv.Quote(e.Source), strconv.Quote(e.Target), e.Solicitations)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// graphML is the GraphML document WriteGraphML encodes.
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID string `xml:"id,attr"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes g as a directed GraphML graph, with the solicitation
+// count and last solicitation time of each edge as data.
+func (g SolicitGraph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "solicitations", For: "edge", Name: "solicitations", Type: "int"},
+			{ID: "last_solicited", For: "edge", Name: "last_solicited", Type: "string"},
+		},
+		Graph: graphMLGraph{EdgeDefault: "directed"},
+	}
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: n})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.Source,
+			Target: e.Target,
+			Data: []graphMLData{
+				{Key: "solicitations", Value: strconv.Itoa(e.Solicitations)},
+				{Key: "last_solicited", Value: e.LastSolicited.UTC().Format(time.RFC3339)},
+			},
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encode graphml: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteGraphFiles writes g as ndpeekr-graph-<time>.dot and .graphml in dir
+// (ndpeekr-<instance>-graph-<time> for a named instance) and returns the
+// DOT path.
+func WriteGraphFiles(g SolicitGraph, dir, instance string, now time.Time) (string, error) {
+	base := filepath.Join(dir, instanceFileName("graph", instance, now.Format("20060102-150405.000"), ""))
+	for _, f := range []struct {
+		ext   string
+		write func(io.Writer) error
+	}{{".dot", g.WriteDOT}, {".graphml", g.WriteGraphML}} {
+		var b strings.Builder
+		if err := f.write(&b); err != nil {
+			return "", err
+		}
+		if err := writeFileAtomic(base+f.ext, []byte(b.String())); err != nil {
+			return "", err
+		}
+	}
+	return base + ".dot", nil
+}
@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSolicitGraph(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	now := time.Now()
+	stats.RecordNSTarget("fe80::a", "fe80::1", now.Add(-time.Second))
+	stats.RecordNSTarget("fe80::a", "fe80::1", now)
+	stats.RecordNSTarget("fe80::a", "fe80::b", now)
+	stats.RecordNSTarget("fe80::b", "fe80::1", now)
+	stats.RecordNSTarget("fe80::c", "fe80::1", now.Add(-10*time.Minute))
+
+	g := stats.GetSolicitGraph()
+	if want := []string{"fe80::1", "fe80::a", "fe80::b"}; strings.Join(g.Nodes, " ") != strings.Join(want, " ") {
+		t.Errorf("nodes = %v, want %v", g.Nodes, want)
+	}
+	if len(g.Edges) != 3 {
+		t.Fatalf("edges = %+v, want 3", g.Edges)
+	}
+	if e := g.Edges[0]; e.Source != "fe80::a" || e.Target != "fe80::1" || e.Solicitations != 2 || !e.LastSolicited.Equal(now) {
+		t.Errorf("first edge = %+v", e)
+	}
+	if n := g.Neighbors(); len(n) != 2 || n[0][0].Source != "fe80::a" || len(n[0]) != 2 {
+		t.Errorf("neighbors = %+v, want fe80::a with two targets first", n)
+	}
+
+	var dot strings.Builder
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot.String(), `"fe80::a" -> "fe80::1" [label="2"];`) {
+		t.Errorf("DOT output:\n%s", dot.String())
+	}
+
+	var gml strings.Builder
+	if err := g.WriteGraphML(&gml); err != nil {
+		t.Fatal(err)
+	}
+	var doc graphML
+	if err := xml.Unmarshal([]byte(gml.String()), &doc); err != nil {
+		t.Fatalf("GraphML does not parse: %v\n%s", err, gml.String())
+	}
+	if len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 3 || doc.Graph.Edges[0].Data[0].Value != "2" {
+		t.Errorf("GraphML graph = %+v", doc.Graph)
+	}
+}
+
+func TestAPIHandler_GraphFormats(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordNSTarget("fe80::a", "fe80::1", time.Now())
+
+	for format, want := range map[string]string{
+		"":        `"source":"fe80::a"`,
+		"dot":     `digraph`,
+		"graphml": `<graphml`,
+	} {
+		rec := httptest.NewRecorder()
+		APIHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/graph?format="+format, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("format %q: status %d, body %s", format, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	APIHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/graph?format=svg", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("format svg: status = %d, want 400", rec.Code)
+	}
+}
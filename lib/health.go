@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Health tracks whether the components of a headless instance are up, for
+// Kubernetes liveness and readiness probes. Components that must start
+// before the instance is useful are registered with Expect and report with
+// Ready; any component that stops for good reports with Fail.
+type Health struct {
+	mu      sync.Mutex
+	pending map[string]bool  // expected, not ready yet
+	failed  map[string]error // stopped with an error
+}
+
+// NewHealth returns a Health with nothing expected: ready and live.
+func NewHealth() *Health {
+	return &Health{pending: make(map[string]bool), failed: make(map[string]error)}
+}
+
+// Expect registers a component that has to call Ready before the instance
+// is ready.
+func (h *Health) Expect(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pending[name] = true
+}
+
+// Ready marks name as started.
+func (h *Health) Ready(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.pending, name)
+}
+
+// Fail marks name as stopped, which fails both probes.
+func (h *Health) Fail(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failed[name] = err
+}
+
+// Live returns an error naming the components that stopped.
+func (h *Health) Live() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.failed) == 0 {
+		return nil
+	}
+	var parts []string
+	for _, name := range slices.Sorted(maps.Keys(h.failed)) {
+		parts = append(parts, fmt.Sprintf("%s: %v", name, h.failed[name]))
+	}
+	return fmt.Errorf("stopped: %s", strings.Join(parts, "; "))
+}
+
+// CheckReady returns an error if a component stopped or has not started.
+func (h *Health) CheckReady() error {
+	if err := h.Live(); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.pending) > 0 {
+		return fmt.Errorf("starting: %s", strings.Join(slices.Sorted(maps.Keys(h.pending)), ", "))
+	}
+	return nil
+}
+
+// HealthHandler serves the probes:
+//
+//	GET /livez    200 unless a component stopped
+//	GET /readyz   200 once every expected component started
+//	GET /healthz  same as /readyz, for container HEALTHCHECKs
+func HealthHandler(h *Health) http.Handler {
+	probe := func(check func() error) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			if err := check(); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprintln(w, "ok")
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /livez", probe(h.Live))
+	mux.Handle("GET /readyz", probe(h.CheckReady))
+	mux.Handle("GET /healthz", probe(h.CheckReady))
+	return mux
+}
+
+// ServeHealth serves HealthHandler on addr until ctx is cancelled.
+func ServeHealth(ctx context.Context, addr string, h *Health, logger *slog.Logger) error {
+	logger.Info("serving health probes", "listen", addr)
+	if err := serveHTTP(ctx, addr, HealthHandler(h)); err != nil {
+		return fmt.Errorf("health probes: %w", err)
+	}
+	return nil
+}
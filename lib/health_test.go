@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthHandler(t *testing.T) {
+	h := NewHealth()
+	h.Expect("capture eth0")
+	h.Expect("capture eth1")
+	handler := HealthHandler(h)
+
+	probe := func(path string) (int, string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, _ := probe("/livez"); code != http.StatusOK {
+		t.Errorf("livez while starting = %d, want 200", code)
+	}
+	if code, body := probe("/readyz"); code != http.StatusServiceUnavailable || !strings.Contains(body, "capture eth0, capture eth1") {
+		t.Errorf("readyz while starting = %d %q, want 503 naming both captures", code, body)
+	}
+
+	h.Ready("capture eth0")
+	h.Ready("capture eth1")
+	for _, path := range []string{"/readyz", "/healthz"} {
+		if code, _ := probe(path); code != http.StatusOK {
+			t.Errorf("%s once started = %d, want 200", path, code)
+		}
+	}
+
+	h.Fail("gnmi", errors.New("address in use"))
+	for _, path := range []string{"/livez", "/readyz", "/healthz"} {
+		if code, body := probe(path); code != http.StatusServiceUnavailable || !strings.Contains(body, "gnmi: address in use") {
+			t.Errorf("%s after failure = %d %q, want 503 naming gnmi", path, code, body)
+		}
+	}
+}
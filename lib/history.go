@@ -0,0 +1,337 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+const defaultHistoryInterval = time.Minute
+
+// historySchema holds periodic samples of the peer and router tables plus
+// every alert. Times are Unix nanoseconds.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS samples (
+	ts INTEGER PRIMARY KEY -- one row per Record, even when the tables were empty
+);
+
+CREATE TABLE IF NOT EXISTS peers (
+	ts         INTEGER NOT NULL,
+	address    TEXT    NOT NULL,
+	mac        TEXT    NOT NULL DEFAULT '',
+	iface      TEXT    NOT NULL DEFAULT '',
+	first_seen INTEGER NOT NULL,
+	last_seen  INTEGER NOT NULL,
+	total      INTEGER NOT NULL,
+	counts     TEXT    NOT NULL DEFAULT '{}', -- JSON: ndpKind -> count
+	groups     TEXT    NOT NULL DEFAULT '[]', -- JSON array
+	os         TEXT    NOT NULL DEFAULT '',
+	hostname   TEXT    NOT NULL DEFAULT '',
+	vendor     TEXT    NOT NULL DEFAULT '',
+	name       TEXT    NOT NULL DEFAULT '',
+	stale      INTEGER NOT NULL DEFAULT 0,
+	device_type TEXT   NOT NULL DEFAULT '',
+	trusted    INTEGER NOT NULL DEFAULT 0,
+	tags       TEXT    NOT NULL DEFAULT '[]' -- JSON array
+);
+CREATE INDEX IF NOT EXISTS peers_ts ON peers (ts);
+
+CREATE TABLE IF NOT EXISTS routers (
+	ts         INTEGER NOT NULL,
+	address    TEXT    NOT NULL,
+	mac        TEXT    NOT NULL DEFAULT '',
+	iface      TEXT    NOT NULL DEFAULT '',
+	lifetime   INTEGER NOT NULL, -- seconds
+	managed    INTEGER NOT NULL,
+	other      INTEGER NOT NULL,
+	mtu        INTEGER NOT NULL,
+	prefixes   TEXT    NOT NULL DEFAULT '[]', -- JSON array of PrefixInfo
+	rdnss      TEXT    NOT NULL DEFAULT '[]', -- JSON array
+	first_seen INTEGER NOT NULL,
+	last_seen  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS routers_ts ON routers (ts);
+
+CREATE TABLE IF NOT EXISTS alerts (
+	ts       INTEGER NOT NULL,
+	severity TEXT    NOT NULL,
+	category TEXT    NOT NULL,
+	source   TEXT    NOT NULL,
+	message  TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS alerts_ts ON alerts (ts);
+
+CREATE TABLE IF NOT EXISTS counters (
+	res   INTEGER NOT NULL, -- bucket length in seconds: 1, 60 or 3600
+	ts    INTEGER NOT NULL, -- bucket start
+	kind  TEXT    NOT NULL,
+	count INTEGER NOT NULL,
+	PRIMARY KEY (res, ts, kind)
+);
+CREATE INDEX IF NOT EXISTS counters_ts ON counters (ts);
+`
+
+// historyPeerColumns are peers columns added after the first release, with
+// their definitions, in the order they were added.
+var historyPeerColumns = [][2]string{
+	{"device_type", "TEXT NOT NULL DEFAULT ''"},
+	{"trusted", "INTEGER NOT NULL DEFAULT 0"},
+	{"tags", "TEXT NOT NULL DEFAULT '[]'"},
+}
+
+// historyRouterColumns are routers columns added after the first release.
+var historyRouterColumns = [][2]string{
+	{"preference", "INTEGER NOT NULL DEFAULT 0"},
+	{"dnssl", "TEXT NOT NULL DEFAULT '[]'"},
+}
+
+// addHistoryColumns adds any of cols that table doesn't have yet.
+func addHistoryColumns(db *sql.DB, table string, cols [][2]string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		have[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, c := range cols {
+		if have[c[0]] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, c[0], c[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HistoryDB is the SQLite history database.
+type HistoryDB struct {
+	db *sql.DB
+	// lastAlert is the newest alert already stored; alerts are only
+	// appended once.
+	lastAlert time.Time
+}
+
+// OpenHistoryDB opens (creating if needed) the history database at path.
+func OpenHistoryDB(path string) (*HistoryDB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open history db: %w", err)
+	}
+	// One writer at a time; SQLite serialises writes anyway.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create history schema in %s: %w", path, err)
+	}
+
+	// Databases written before the inventory columns existed lack them.
+	if err := errors.Join(addHistoryColumns(db, "peers", historyPeerColumns),
+		addHistoryColumns(db, "routers", historyRouterColumns)); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("upgrade history schema in %s: %w", path, err)
+	}
+
+	// Databases written before the samples table existed only have the
+	// sample times implied by their rows.
+	if _, err := db.Exec(`INSERT OR IGNORE INTO samples (ts)
+		SELECT ts FROM (SELECT ts FROM peers UNION SELECT ts FROM routers)
+		WHERE NOT EXISTS (SELECT 1 FROM samples)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("backfill history samples in %s: %w", path, err)
+	}
+
+	h := &HistoryDB{db: db}
+	var last sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(ts) FROM alerts`).Scan(&last); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("read history db: %w", err)
+	}
+	if last.Valid {
+		h.lastAlert = time.Unix(0, last.Int64)
+	}
+	return h, nil
+}
+
+// Close closes the database.
+func (h *HistoryDB) Close() error {
+	return h.db.Close()
+}
+
+// Record stores one sample of snap's peers and routers, and any alerts newer
+// than those already stored, in a single transaction.
+func (h *HistoryDB) Record(snap Snapshot) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return fmt.Errorf("record history: %w", err)
+	}
+	defer tx.Rollback()
+
+	ts := snap.Taken.UnixNano()
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO samples (ts) VALUES (?)`, ts); err != nil {
+		return fmt.Errorf("record sample: %w", err)
+	}
+	for _, p := range snap.Peers {
+		counts, _ := json.Marshal(p.Counts)
+		groups, _ := json.Marshal(nonNil(p.Groups))
+		tags, _ := json.Marshal(nonNil(p.Tags))
+		_, err := tx.Exec(`INSERT INTO peers
+			(ts, address, mac, iface, first_seen, last_seen, total, counts, groups, os, hostname, vendor, name, stale,
+			 device_type, trusted, tags)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ts, p.Address, p.MAC, p.Interface, p.FirstSeen.UnixNano(), p.LastSeen.UnixNano(), p.Total,
+			string(counts), string(groups), p.GuessedOS, p.Hostname, p.Vendor, p.Name, p.Stale,
+			p.DeviceType, p.Trusted, string(tags))
+		if err != nil {
+			return fmt.Errorf("record peer %s: %w", p.Address, err)
+		}
+	}
+	for _, r := range snap.Routers {
+		prefixes, _ := json.Marshal(nonNil(r.Prefixes))
+		rdnss, _ := json.Marshal(nonNil(r.RDNSS))
+		dnssl, _ := json.Marshal(nonNil(r.DNSSL))
+		_, err := tx.Exec(`INSERT INTO routers
+			(ts, address, mac, iface, lifetime, managed, other, mtu, prefixes, rdnss, first_seen, last_seen,
+			preference, dnssl)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ts, r.Address, r.MAC, r.Interface, int64(r.Lifetime/time.Second), r.Managed, r.Other, r.MTU,
+			string(prefixes), string(rdnss), r.FirstSeen.UnixNano(), r.LastSeen.UnixNano(),
+			r.Preference, string(dnssl))
+		if err != nil {
+			return fmt.Errorf("record router %s: %w", r.Address, err)
+		}
+	}
+
+	lastAlert := h.lastAlert
+	for _, a := range snap.Alerts {
+		if !a.Time.After(h.lastAlert) {
+			continue
+		}
+		_, err := tx.Exec(`INSERT INTO alerts (ts, severity, category, source, message) VALUES (?, ?, ?, ?, ?)`,
+			a.Time.UnixNano(), a.Severity.String(), a.Category, a.Source, a.Message)
+		if err != nil {
+			return fmt.Errorf("record alert: %w", err)
+		}
+		if a.Time.After(lastAlert) {
+			lastAlert = a.Time
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("record history: %w", err)
+	}
+	h.lastAlert = lastAlert
+	return nil
+}
+
+// nonNil turns a nil slice into an empty one so it encodes as [] rather than null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// HistoryRecorderConfig configures a HistoryRecorder.
+type HistoryRecorderConfig struct {
+	DB       *HistoryDB
+	Stats    *NDPStats
+	Logger   *slog.Logger
+	Interval time.Duration // default 1m
+	// MaxAge and MaxBytes are the retention limits (see HistoryDB.Retain);
+	// zero means no limit.
+	MaxAge   time.Duration
+	MaxBytes int64
+	// Downsample sets when counters are rolled up to coarser resolutions.
+	Downsample DownsampleConfig
+	// Privacy, when set, drops identifiers before samples are stored.
+	Privacy *PrivacyConfig
+}
+
+// HistoryRecorder samples stats and per-second message counters into the
+// history database on a fixed cadence. At startup and every
+// historyRetainInterval after, it downsamples counters and applies the
+// retention limits.
+type HistoryRecorder struct {
+	cfg      HistoryRecorderConfig
+	lastTidy time.Time
+}
+
+// NewHistoryRecorder returns a recorder and starts per-second counting in
+// cfg.Stats; call Run to start recording.
+func NewHistoryRecorder(cfg HistoryRecorderConfig) *HistoryRecorder {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultHistoryInterval
+	}
+	cfg.Stats.EnableCounters()
+	return &HistoryRecorder{cfg: cfg}
+}
+
+// Run records every interval until ctx is cancelled, then records a final
+// sample so the state at shutdown is kept.
+func (r *HistoryRecorder) Run(ctx context.Context) {
+	r.tidy(time.Now())
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.record()
+			return
+		case <-ticker.C:
+			r.record()
+		}
+	}
+}
+
+func (r *HistoryRecorder) record() {
+	now := time.Now()
+	if err := r.cfg.DB.Record(r.cfg.Privacy.Snapshot(*r.cfg.Stats.Shared())); err != nil {
+		r.cfg.Logger.Warn("history write failed", "err", err)
+	}
+	if err := r.cfg.DB.RecordCounters(r.cfg.Stats.TakeCounters(now)); err != nil {
+		r.cfg.Logger.Warn("history counters write failed", "err", err)
+	}
+	r.tidy(now)
+}
+
+// tidy downsamples counters and applies the retention limits, if due.
+func (r *HistoryRecorder) tidy(now time.Time) {
+	if !r.lastTidy.IsZero() && now.Sub(r.lastTidy) < historyRetainInterval {
+		return
+	}
+	r.lastTidy = now
+	if _, err := r.cfg.DB.Downsample(now, r.cfg.Downsample); err != nil {
+		r.cfg.Logger.Warn("history downsampling failed", "err", err)
+	}
+	if r.cfg.MaxAge <= 0 && r.cfg.MaxBytes <= 0 {
+		return
+	}
+	res, err := r.cfg.DB.Retain(now, r.cfg.MaxAge, r.cfg.MaxBytes)
+	if err != nil {
+		r.cfg.Logger.Warn("history retention failed", "err", err)
+		return
+	}
+	if res.Samples > 0 || res.Alerts > 0 {
+		r.cfg.Logger.Info("history retention", "samples_dropped", res.Samples, "alerts_dropped", res.Alerts,
+			"compacted", res.Compacted, "bytes", res.Bytes)
+	}
+}
@@ -0,0 +1,218 @@
+package lib
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryRecordAndExport(t *testing.T) {
+	db, err := OpenHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("OpenHistoryDB: %v", err)
+	}
+	defer db.Close()
+
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordMAC("fe80::1", "aa:bb:cc:dd:ee:ff")
+	stats.RecordMLDMembership("fe80::1", "ff02::fb")
+	stats.RecordRouter(RouterInfo{Address: "fe80::fe", Lifetime: 30 * time.Minute, Prefixes: []PrefixInfo{{Prefix: "2001:db8::/64"}},
+		Preference: 1, DNSSL: []string{"example.com"}})
+	stats.RecordAlert(Alert{Time: time.Now(), Severity: SeverityWarning, Category: "test", Source: "fe80::1", Message: "hello, world"})
+
+	// Recording twice samples the tables twice but stores the alert once.
+	for i := 0; i < 2; i++ {
+		if err := db.Record(stats.Snapshot()); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	export := func(table string) [][]string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := db.Export(&buf, table, from, to, nil); err != nil {
+			t.Fatalf("Export(%s): %v", table, err)
+		}
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: invalid CSV: %v", table, err)
+		}
+		return records
+	}
+
+	peers := export("peers")
+	if len(peers) != 3 {
+		t.Fatalf("peers: got %d rows, want header + 2 samples", len(peers))
+	}
+	col := func(name string) int {
+		for i, h := range peers[0] {
+			if h == name {
+				return i
+			}
+		}
+		t.Fatalf("peers: no %q column in %v", name, peers[0])
+		return -1
+	}
+	if row := peers[1]; row[col("address")] != "fe80::1" || row[col("mac")] != "aa:bb:cc:dd:ee:ff" ||
+		row[col("ns")] != "1" || row[col("groups")] != "ff02::fb" {
+		t.Errorf("peer row = %v", row)
+	}
+
+	routers := export("routers")
+	if len(routers) != 3 || routers[1][1] != "fe80::fe" || routers[1][4] != "1800" || routers[1][8] != "2001:db8::/64" ||
+		routers[1][12] != "high" || routers[1][13] != "example.com" {
+		t.Errorf("routers = %v", routers)
+	}
+
+	alerts := export("alerts")
+	if len(alerts) != 2 || alerts[1][1] != "warning" || alerts[1][4] != "hello, world" {
+		t.Errorf("alerts = %v, want the alert exactly once", alerts)
+	}
+
+	// Outside the range there is only the header.
+	var buf bytes.Buffer
+	if err := db.Export(&buf, "peers", to, to.Add(time.Hour), nil); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := csv.NewReader(&buf).ReadAll(); len(records) != 1 {
+		t.Errorf("out-of-range export has %d rows, want header only", len(records))
+	}
+
+	if err := db.Export(&buf, "bogus", from, to, nil); err == nil {
+		t.Error("unknown table: expected error")
+	}
+}
+
+func TestHistoryDB_ReopenKeepsAlertWatermark(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordAlert(Alert{Time: time.Now(), Category: "test", Source: "fe80::1", Message: "once"})
+
+	for i := 0; i < 2; i++ {
+		db, err := OpenHistoryDB(path)
+		if err != nil {
+			t.Fatalf("OpenHistoryDB: %v", err)
+		}
+		if err := db.Record(stats.Snapshot()); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+		db.Close()
+	}
+
+	db, err := OpenHistoryDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var buf bytes.Buffer
+	if err := db.Export(&buf, "alerts", time.Unix(0, 0), time.Now().Add(time.Hour), nil); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := csv.NewReader(&buf).ReadAll(); len(records) != 2 {
+		t.Errorf("got %d alert rows after reopening, want 1", len(records)-1)
+	}
+}
+
+func TestHistoryDB_AddsInventoryColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	old, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`CREATE TABLE peers (ts INTEGER NOT NULL, address TEXT NOT NULL, mac TEXT NOT NULL DEFAULT '',
+		iface TEXT NOT NULL DEFAULT '', first_seen INTEGER NOT NULL, last_seen INTEGER NOT NULL, total INTEGER NOT NULL,
+		counts TEXT NOT NULL DEFAULT '{}', groups TEXT NOT NULL DEFAULT '[]', os TEXT NOT NULL DEFAULT '',
+		hostname TEXT NOT NULL DEFAULT '', vendor TEXT NOT NULL DEFAULT '', name TEXT NOT NULL DEFAULT '',
+		stale INTEGER NOT NULL DEFAULT 0);
+		INSERT INTO peers (ts, address, first_seen, last_seen, total) VALUES (1, 'fe80::1', 1, 1, 3)`); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	db, err := OpenHistoryDB(path)
+	if err != nil {
+		t.Fatalf("OpenHistoryDB on an old database: %v", err)
+	}
+	defer db.Close()
+	sample, err := db.LoadSample(time.Unix(0, 1))
+	if err != nil {
+		t.Fatalf("LoadSample: %v", err)
+	}
+	if len(sample.Peers) != 1 || sample.Peers[0].Total != 3 {
+		t.Errorf("peers = %+v, want the old row", sample.Peers)
+	}
+}
+
+func TestParseExportTime(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"2024-05-01T10:00:00Z": time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		"2024-05-01":           time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
+		"2024-05-01 14:30":     time.Date(2024, 5, 1, 14, 30, 0, 0, time.Local),
+		"24h":                  now.Add(-24 * time.Hour),
+		"0s":                   now,
+	}
+	for in, want := range tests {
+		got, err := ParseExportTime(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseExportTime(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseExportTime("yesterday", now); err == nil {
+		t.Error("expected error for an unparseable time")
+	}
+}
+
+func TestHistoryScrub(t *testing.T) {
+	db, err := OpenHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("OpenHistoryDB: %v", err)
+	}
+	defer db.Close()
+
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	samples := []Snapshot{
+		{Taken: t0, Peers: []PeerSummary{{Address: "fe80::1", Total: 1, Counts: map[string]int{"neighbor_solicitation": 1}}}},
+		{Taken: t0.Add(time.Minute)}, // nothing on the link
+		{Taken: t0.Add(2 * time.Minute), Routers: []RouterInfo{{Address: "fe80::fe", Lifetime: 30 * time.Minute, RDNSS: []string{"2001:db8::53"}}}},
+	}
+	for _, s := range samples {
+		if err := db.Record(s); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	// Step back from the newest sample through the empty one.
+	at, ok, err := db.PrevSample(t0.Add(time.Hour))
+	if err != nil || !ok || !at.Equal(t0.Add(2*time.Minute)) {
+		t.Fatalf("PrevSample(latest) = %v, %v, %v", at, ok, err)
+	}
+	s, err := db.LoadSample(at)
+	if err != nil {
+		t.Fatalf("LoadSample: %v", err)
+	}
+	if len(s.Peers) != 0 || len(s.Routers) != 1 || s.Routers[0].Lifetime != 30*time.Minute || s.Routers[0].RDNSS[0] != "2001:db8::53" {
+		t.Errorf("sample at %v = %+v", at, s)
+	}
+
+	if at, ok, _ = db.PrevSample(at); !ok || !at.Equal(t0.Add(time.Minute)) {
+		t.Fatalf("PrevSample = %v, %v, want the empty sample", at, ok)
+	}
+	if at, ok, _ = db.PrevSample(at); !ok || !at.Equal(t0) {
+		t.Fatalf("PrevSample = %v, %v, want the first sample", at, ok)
+	}
+	if s, err = db.LoadSample(at); err != nil || len(s.Peers) != 1 || s.Peers[0].Counts["neighbor_solicitation"] != 1 {
+		t.Errorf("first sample = %+v, %v", s, err)
+	}
+	if _, ok, _ = db.PrevSample(at); ok {
+		t.Error("PrevSample before the oldest sample found one")
+	}
+	if at, ok, _ = db.NextSample(at); !ok || !at.Equal(t0.Add(time.Minute)) {
+		t.Errorf("NextSample = %v, %v", at, ok)
+	}
+}
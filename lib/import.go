@@ -0,0 +1,206 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+// ImportConfig configures ImportPcaps.
+type ImportConfig struct {
+	DB     *HistoryDB
+	Files  []string
+	Logger *slog.Logger
+	// Window is the stats window the captures are replayed with, as
+	// --window live (default 15m).
+	Window time.Duration
+	// Interval is the capture time between samples, as history.interval
+	// live (default 1m).
+	Interval time.Duration
+	// Mirror replays Ethernet captures as taken on a SPAN or mirror port
+	// (see NDPListenerConfig.Mirror).
+	Mirror bool
+	// Privacy, when set, drops identifiers before samples are stored, as
+	// HistoryRecorderConfig.Privacy does live.
+	Privacy *PrivacyConfig
+
+	stats *NDPStats // replayed into instead of fresh stats, for tests
+}
+
+// ImportResult summarises an import.
+type ImportResult struct {
+	Files   int
+	Packets int
+	Samples int
+	// First and Last are the capture times of the first and last packet.
+	First time.Time
+	Last  time.Time
+}
+
+// ImportPcaps replays pcap files into the history database as if NDPeekr
+// had captured them live with a HistoryRecorder running: peers, routers,
+// alerts and message counters are recorded at the packets' capture times,
+// with a sample every cfg.Interval of capture time and one after the last
+// packet. The files are replayed oldest first into the same stats, so
+// consecutive captures (a rotated tcpdump, say) continue each other.
+// Stretches without traffic longer than the window get no samples.
+//
+// Samples are taken on whole intervals, so importing the same captures
+// twice is noticed and refused at the first sample the database already
+// holds.
+func ImportPcaps(ctx context.Context, cfg ImportConfig) (ImportResult, error) {
+	var res ImportResult
+	if cfg.Window <= 0 {
+		cfg.Window = 15 * time.Minute
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultHistoryInterval
+	}
+
+	type pcapStart struct {
+		path  string
+		first time.Time
+	}
+	var files []pcapStart
+	for _, path := range cfg.Files {
+		first, ok, err := pcapFirstPacket(path)
+		if err != nil {
+			return res, err
+		}
+		if !ok {
+			cfg.Logger.Warn("pcap has no packets", "file", path)
+			continue
+		}
+		files = append(files, pcapStart{path, first})
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].first.Before(files[j].first) })
+
+	var clock time.Time
+	stats := cfg.stats
+	if stats == nil {
+		stats = NewNDPStats(cfg.Window)
+	}
+	stats.SetClock(func() time.Time { return clock })
+	stats.EnableCounters()
+	l := NewNDPListener(NDPListenerConfig{
+		Logger:  cfg.Logger,
+		Stats:   stats,
+		Capture: CapturePcap,
+		Mirror:  cfg.Mirror,
+	})
+
+	// Alerts older than the newest stored one are normally skipped as
+	// already recorded; imported ones are older by nature.
+	lastAlert := cfg.DB.lastAlert
+	cfg.DB.lastAlert = time.Time{}
+	defer func() {
+		if lastAlert.After(cfg.DB.lastAlert) {
+			cfg.DB.lastAlert = lastAlert
+		}
+	}()
+
+	sample := func(at time.Time) error {
+		clock = at
+		var dup bool
+		if err := cfg.DB.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM samples WHERE ts = ?)`, at.UnixNano()).Scan(&dup); err != nil {
+			return fmt.Errorf("find history sample: %w", err)
+		}
+		if dup {
+			return fmt.Errorf("the database already holds a sample at %s; were these captures imported before?", at.Format(time.RFC3339Nano))
+		}
+		stats.Prune()
+		if err := cfg.DB.Record(cfg.Privacy.Snapshot(stats.Snapshot())); err != nil {
+			return err
+		}
+		if err := cfg.DB.RecordCounters(stats.TakeCounters(at)); err != nil {
+			return err
+		}
+		res.Samples++
+		return nil
+	}
+
+	var next time.Time // the next sample's time
+	tick := func(ts time.Time) error {
+		if next.IsZero() {
+			next = ts.Truncate(cfg.Interval).Add(cfg.Interval)
+		}
+		for !ts.Before(next) {
+			if err := sample(next); err != nil {
+				return err
+			}
+			if len(stats.GetStats()) == 0 && len(stats.GetRouters()) == 0 {
+				// Quiet until ts: skip ahead rather than record empty samples.
+				next = ts.Truncate(cfg.Interval)
+			}
+			next = next.Add(cfg.Interval)
+		}
+		// Files may overlap a little; time never runs backwards.
+		if ts.After(clock) {
+			clock = ts
+		}
+		if res.First.IsZero() {
+			res.First = ts
+		}
+		res.Last = clock
+		return nil
+	}
+
+	for _, file := range files {
+		f, err := os.Open(file.path)
+		if err != nil {
+			return res, err
+		}
+		pr, err := newPcapReader(f)
+		if err != nil {
+			f.Close()
+			return res, fmt.Errorf("%s: %w", file.path, err)
+		}
+		packets, err := l.replay(ctx, pr, tick)
+		f.Close()
+		res.Packets += packets
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", file.path, err)
+		}
+		res.Files++
+		cfg.Logger.Info("pcap imported", "file", file.path, "packets", packets, "from", file.first, "to", clock)
+	}
+	if res.Last.IsZero() {
+		return res, nil
+	}
+	if res.Last.After(next.Add(-cfg.Interval)) {
+		if err := sample(res.Last); err != nil {
+			return res, err
+		}
+	}
+	if _, err := cfg.DB.Downsample(time.Now(), DownsampleConfig{}); err != nil {
+		cfg.Logger.Warn("history downsampling failed", "err", err)
+	}
+	return res, nil
+}
+
+// pcapFirstPacket returns the capture time of the first packet in the pcap
+// at path, and false if it has none.
+func pcapFirstPacket(path string) (time.Time, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	defer f.Close()
+	pr, err := newPcapReader(f)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s: %w", path, err)
+	}
+	ts, _, err := pr.next()
+	if errors.Is(err, io.EOF) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s: %w", path, err)
+	}
+	return ts, true, nil
+}
@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportPcaps(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 10, 0, 30, 0, time.UTC)
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	first := filepath.Join(dir, "a.pcap")
+	second := filepath.Join(dir, "b.pcap")
+	if err := os.WriteFile(first, writePcap(linkTypeRaw, start,
+		buildIPv6Packet("fe80::1", "ff02::1:ff00:2", 255, nil, nhICMPv6, buildNS(net.ParseIP("fe80::2"), mac)),
+		buildIPv6Packet("fe80::a", "ff02::1", 255, nil, nhICMPv6, buildRA(net.HardwareAddr{0x02, 0, 0, 0, 0, 0x0a})),
+	), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, writePcap(linkTypeRaw, start.Add(3*time.Minute),
+		buildIPv6Packet("fe80::2", "ff02::1:ff00:1", 255, nil, nhICMPv6, buildNS(net.ParseIP("fe80::1"), nil)),
+	), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenHistoryDB(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cfg := ImportConfig{
+		DB:     db,
+		Files:  []string{second, first}, // replayed oldest first anyway
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	res, err := ImportPcaps(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10:01, 10:02 and 10:03, then one after the last packet.
+	if res.Files != 2 || res.Packets != 3 || res.Samples != 4 || !res.First.Equal(start) || !res.Last.Equal(start.Add(3*time.Minute)) {
+		t.Fatalf("result = %+v", res)
+	}
+
+	taken, ok, err := db.PrevSample(start.Add(3 * time.Minute))
+	if err != nil || !ok || !taken.Equal(start.Add(150*time.Second)) {
+		t.Fatalf("sample before the last = %v %v %v, want 10:03:00", taken, ok, err)
+	}
+	s, err := db.LoadSample(start.Add(3 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Peers) != 3 || len(s.Routers) != 1 {
+		t.Fatalf("last sample = %d peers, %d routers; want 3 and 1", len(s.Peers), len(s.Routers))
+	}
+	for _, p := range s.Peers {
+		if p.Address == "fe80::2" && !p.LastSeen.Equal(start.Add(3*time.Minute)) {
+			t.Errorf("fe80::2 last seen %v, want the capture time", p.LastSeen)
+		}
+	}
+	if !s.Routers[0].FirstSeen.Equal(start) {
+		t.Errorf("router first seen %v, want the capture time", s.Routers[0].FirstSeen)
+	}
+
+	if _, err := ImportPcaps(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "imported before") {
+		t.Errorf("second import = %v, want it refused", err)
+	}
+}
+
+func TestImportPcaps_Privacy(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 10, 0, 30, 0, time.UTC)
+	file := filepath.Join(dir, "a.pcap")
+	if err := os.WriteFile(file, writePcap(linkTypeRaw, start,
+		buildIPv6Packet("fe80::1", "ff02::1:ff00:2", 255, nil, nhICMPv6, buildNS(net.ParseIP("fe80::2"), net.HardwareAddr{0x02, 0, 0, 0, 0, 1})),
+	), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenHistoryDB(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := ImportPcaps(context.Background(), ImportConfig{
+		DB:      db,
+		Files:   []string{file},
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Privacy: &PrivacyConfig{DropMACs: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := db.LoadSample(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Peers) != 1 || s.Peers[0].MAC != "" {
+		t.Errorf("peers = %+v, want one without its MAC", s.Peers)
+	}
+}
+
+func TestImportPcaps_WindowedBytes(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 10, 0, 30, 0, time.UTC)
+	stats := NewNDPStats(5 * time.Minute)
+	var files []string
+	for i := 0; i < 3; i++ {
+		file := filepath.Join(dir, fmt.Sprintf("%d.pcap", i))
+		if err := os.WriteFile(file, writePcap(linkTypeRaw, start.Add(time.Duration(i)*4*time.Minute),
+			buildIPv6Packet("fe80::1", "ff02::1:ff00:2", 255, nil, nhICMPv6, buildNS(net.ParseIP("fe80::2"), nil)),
+		), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	db, err := OpenHistoryDB(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := ImportPcaps(context.Background(), ImportConfig{
+		DB:     db,
+		Files:  files,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Window: 5 * time.Minute,
+		stats:  stats,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range stats.GetStats() {
+		if p.Address != "fe80::1" {
+			continue
+		}
+		// The window at the last packet holds the last two of the three NSes.
+		if n := len(buildNS(net.ParseIP("fe80::2"), nil)); p.Total != 2 || p.TotalBytes != 2*n {
+			t.Errorf("fe80::1 = %d messages, %d bytes; want 2 and %d", p.Total, p.TotalBytes, 2*n)
+		}
+		return
+	}
+	t.Error("fe80::1 not tracked")
+}
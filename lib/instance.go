@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// instancePlaceholder in a file path is replaced by the instance name, so
+// several instances can share one config file.
+const instancePlaceholder = "{instance}"
+
+// ErrFileInUse is returned by LockFile when another process holds the lock.
+var ErrFileInUse = errors.New("in use by another ndpeekr instance")
+
+// InstanceName returns the name that keeps one instance's files apart from
+// another's: name if set, else the capture interfaces joined with "+"
+// ("eth0+eth1"). It is "" for an instance on all interfaces or reading a
+// pcap, whose files keep their plain names. Characters that don't belong in
+// a file name become "_".
+func InstanceName(name string, ifaces []string) string {
+	if name == "" {
+		name = strings.Join(ifaces, "+")
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == '+':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// ExpandInstance replaces {instance} in path with instance, or with
+// "default" for the unnamed instance.
+func ExpandInstance(path, instance string) string {
+	if instance == "" {
+		instance = "default"
+	}
+	return strings.ReplaceAll(path, instancePlaceholder, instance)
+}
+
+// InstancePath namespaces a default file path by instance: tui.json
+// becomes tui-eth0.json. The unnamed instance keeps path.
+func InstancePath(path, instance string) string {
+	if instance == "" || path == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + instance + ext
+}
+
+// instanceFileName names an output file: ndpeekr-<kind>-<stamp><ext>, with
+// the instance after "ndpeekr-" for a named instance.
+func instanceFileName(kind, instance, stamp, ext string) string {
+	if instance != "" {
+		return fmt.Sprintf("ndpeekr-%s-%s-%s%s", instance, kind, stamp, ext)
+	}
+	return fmt.Sprintf("ndpeekr-%s-%s%s", kind, stamp, ext)
+}
+
+// ExpandInstance replaces {instance} in every file path of the config.
+func (c *Config) ExpandInstance(instance string) {
+	if c.Sinks.NDJSON != nil {
+		c.Sinks.NDJSON.Path = ExpandInstance(c.Sinks.NDJSON.Path, instance)
+	}
+	if c.History != nil {
+		c.History.Path = ExpandInstance(c.History.Path, instance)
+	}
+	if c.Evidence != nil {
+		c.Evidence.Dir = ExpandInstance(c.Evidence.Dir, instance)
+	}
+}
+
+// FileLock is an advisory lock on a file or directory, held through
+// <path>.lock, so two instances never write the same history database,
+// event log or state file.
+type FileLock struct {
+	f *os.File
+}
+
+// LockFile locks path. If another process holds the lock it returns an
+// error wrapping ErrFileInUse that names that process. The lock is released
+// by Unlock or when the process exits, however it exits.
+func LockFile(path string) (*FileLock, error) {
+	lockPath := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0o755); err != nil {
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	if err := tryLock(f); err != nil {
+		defer f.Close()
+		if errors.Is(err, ErrFileInUse) {
+			owner := "unknown pid"
+			if data, rerr := os.ReadFile(lockPath); rerr == nil {
+				if pid, perr := strconv.Atoi(strings.TrimSpace(string(data))); perr == nil {
+					owner = "pid " + strconv.Itoa(pid)
+				}
+			}
+			return nil, fmt.Errorf("%s is %w (%s)", path, ErrFileInUse, owner)
+		}
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock. The lock file stays: removing it would let a
+// process that opened it before lock the unlinked file while another locks
+// a new one at the path, and both would think they hold the lock.
+func (l *FileLock) Unlock() error {
+	l.f.Truncate(0)
+	return l.f.Close()
+}
@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInstanceNames(t *testing.T) {
+	if got := InstanceName("", []string{"eth0", "eth1"}); got != "eth0+eth1" {
+		t.Errorf("InstanceName = %q, want eth0+eth1", got)
+	}
+	if got := InstanceName("lab/vlan 20", []string{"eth0"}); got != "lab_vlan_20" {
+		t.Errorf("InstanceName = %q, want lab_vlan_20", got)
+	}
+	if got := InstanceName("", nil); got != "" {
+		t.Errorf("InstanceName = %q, want the unnamed instance", got)
+	}
+
+	if got := InstancePath("/state/ndpeekr/tui.json", "eth0"); got != "/state/ndpeekr/tui-eth0.json" {
+		t.Errorf("InstancePath = %q", got)
+	}
+	if got := InstancePath("/state/ndpeekr/tui.json", ""); got != "/state/ndpeekr/tui.json" {
+		t.Errorf("InstancePath = %q, want the path unchanged", got)
+	}
+	if got := ExpandInstance("/var/lib/ndpeekr/{instance}/history.db", ""); got != "/var/lib/ndpeekr/default/history.db" {
+		t.Errorf("ExpandInstance = %q", got)
+	}
+
+	cfg := Config{History: &HistoryConfig{Path: "history-{instance}.db"}}
+	cfg.ExpandInstance("eth1")
+	if cfg.History.Path != "history-eth1.db" {
+		t.Errorf("history path = %q, want history-eth1.db", cfg.History.Path)
+	}
+
+	stats := NewNDPStats(5 * time.Minute)
+	path, err := WriteSnapshotFile(stats.Snapshot(), t.TempDir(), "eth0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(filepath.Base(path), "ndpeekr-eth0-snapshot-") {
+		t.Errorf("snapshot written to %s, want the instance in its name", path)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history.db")
+	lock, err := LockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LockFile(path)
+	if !errors.Is(err, ErrFileInUse) || !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("second lock: %v, want in use by this pid", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = LockFile(path)
+	if err != nil {
+		t.Fatalf("relock after unlock: %v", err)
+	}
+	lock.Unlock()
+}
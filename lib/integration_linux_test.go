@@ -0,0 +1,384 @@
+//go:build integration && linux
+
+package lib
+
+// End-to-end capture tests over a veth pair. One end lives in a scratch
+// network namespace and injects real NDP/MLD frames; the listener captures
+// them on the other end in the test's own namespace. Needs root and
+// iproute2:
+//
+//	sudo go test -tags integration -run Integration ./lib
+
+import (
+	"NDPeekr/lib/craft"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	itCaptureIface = "ndpit0" // in the test's namespace
+	itSendIface    = "ndpit1" // in the scratch namespace
+)
+
+var (
+	itRouterMAC = net.HardwareAddr{0x02, 0x00, 0x5e, 0x10, 0x00, 0x01}
+	itHostMAC   = net.HardwareAddr{0x02, 0x00, 0x5e, 0x10, 0x00, 0x10}
+)
+
+// setupVeth creates the scratch namespace and veth pair, removed on cleanup.
+func setupVeth(t *testing.T) string {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("integration tests need root")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("integration tests need iproute2")
+	}
+
+	ns := fmt.Sprintf("ndpeekr-it-%d", os.Getpid())
+	run := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+			t.Fatalf("ip %v: %v\n%s", args, err, out)
+		}
+	}
+	run("netns", "add", ns)
+	// Deleting the namespace destroys the veth end inside it, and with it the pair.
+	t.Cleanup(func() { _ = exec.Command("ip", "netns", "del", ns).Run() })
+
+	run("link", "add", itCaptureIface, "type", "veth", "peer", "name", itSendIface, "netns", ns)
+	run("link", "set", itCaptureIface, "up")
+	run("-n", ns, "link", "set", itSendIface, "up")
+	return ns
+}
+
+// inNetns runs fn on a thread switched into the named network namespace.
+// Sockets opened by fn stay in that namespace afterwards.
+func inNetns(t *testing.T, ns string, fn func() error) {
+	t.Helper()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	orig, err := os.Open("/proc/thread-self/ns/net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+	target, err := os.Open("/var/run/netns/" + ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+		t.Fatalf("setns %s: %v", ns, err)
+	}
+	defer func() {
+		if err := unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); err != nil {
+			panic(fmt.Sprintf("restore network namespace: %v", err))
+		}
+	}()
+	if err := fn(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// frameSender writes raw Ethernet frames on the scratch end of the pair.
+type frameSender struct {
+	fd      int
+	ifindex int
+}
+
+func openSender(t *testing.T, ns string) *frameSender {
+	t.Helper()
+	s := &frameSender{}
+	inNetns(t, ns, func() error {
+		ifi, err := net.InterfaceByName(itSendIface)
+		if err != nil {
+			return err
+		}
+		fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(htons(syscall.ETH_P_ALL)))
+		if err != nil {
+			return fmt.Errorf("open packet socket: %w", err)
+		}
+		s.fd, s.ifindex = fd, ifi.Index
+		return nil
+	})
+	t.Cleanup(func() { syscall.Close(s.fd) })
+	return s
+}
+
+// send wraps an IPv6 packet from srcMAC to dst (a multicast address) in an
+// Ethernet frame and transmits it.
+func (s *frameSender) send(t *testing.T, srcMAC net.HardwareAddr, dst string, pkt []byte) {
+	t.Helper()
+	s.sendFrame(t, craft.EthernetFrame(craft.MulticastMAC(net.ParseIP(dst)), srcMAC, pkt))
+}
+
+// sendFrame transmits a complete Ethernet frame.
+func (s *frameSender) sendFrame(t *testing.T, frame []byte) {
+	t.Helper()
+	addr := &syscall.SockaddrLinklayer{Ifindex: s.ifindex, Halen: 6}
+	copy(addr.Addr[:], frame[:6])
+	if err := syscall.Sendto(s.fd, frame, 0, addr); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
+
+// startListener captures on the test's end of the pair until the test ends.
+func startListener(t *testing.T, capture string) *NDPStats {
+	t.Helper()
+	return startListenerConfig(t, NDPListenerConfig{Interface: itCaptureIface, Capture: capture})
+}
+
+// startListenerConfig runs a listener with cfg, plus a logger and fresh
+// stats, until the test ends.
+func startListenerConfig(t *testing.T, cfg NDPListenerConfig) *NDPStats {
+	t.Helper()
+	stats := NewNDPStats(5 * time.Minute)
+	cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg.Stats = stats
+	l := NewNDPListener(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- l.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil && err != context.Canceled {
+			t.Errorf("listener: %v", err)
+		}
+	})
+	// Let the socket bind before traffic starts.
+	time.Sleep(200 * time.Millisecond)
+	return stats
+}
+
+// waitFor polls cond until it holds or the deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func peerByAddr(stats *NDPStats, addr string) (PeerSummary, bool) {
+	for _, p := range stats.GetStats() {
+		if p.Address == addr {
+			return p, true
+		}
+	}
+	return PeerSummary{}, false
+}
+
+func TestIntegration_Capture(t *testing.T) {
+	for _, capture := range []string{CaptureSocket, CapturePacket} {
+		t.Run(capture, func(t *testing.T) {
+			ns := setupVeth(t)
+			stats := startListener(t, capture)
+			sender := openSender(t, ns)
+
+			// The kernel only delivers multicast ICMPv6 to raw sockets for
+			// groups the interface has joined, so the socket backend sees
+			// traffic sent to all-nodes; the packet backend sees everything.
+			dst := func(group string) string {
+				if capture == CaptureSocket {
+					return "ff02::1"
+				}
+				return group
+			}
+
+			const router, host = "fe80::1", "fe80::10"
+			ra := buildRAFull(64, true, false, 1800, itRouterMAC,
+				buildPrefixInfoOption(net.ParseIP("2001:db8:1::"), 64, true, true, 86400, 14400),
+				buildRDNSSOption(600, net.ParseIP("2001:db8::53")))
+			sender.send(t, itHostMAC, dst("ff02::2"), buildIPv6Packet(host, dst("ff02::2"), 255, nil, nhICMPv6, buildRS(itHostMAC)))
+			sender.send(t, itRouterMAC, "ff02::1", buildIPv6Packet(router, "ff02::1", 255, nil, nhICMPv6, ra))
+			sender.send(t, itHostMAC, dst("ff02::1:ff00:1"), buildIPv6Packet(host, dst("ff02::1:ff00:1"), 255, nil, nhICMPv6, buildNS(net.ParseIP(router), itHostMAC)))
+			sender.send(t, itRouterMAC, "ff02::1", buildIPv6Packet(router, "ff02::1", 255, nil, nhICMPv6, buildNA(net.ParseIP(router), itRouterMAC)))
+			report := buildMLDv2Report([]net.IP{net.ParseIP("ff02::fb")})
+			sender.send(t, itHostMAC, dst("ff02::16"), buildIPv6Packet(host, dst("ff02::16"), 1, []extHeader{routerAlertHBH}, nhICMPv6, report))
+
+			waitFor(t, "router", func() bool { return len(stats.GetRouters()) > 0 })
+			waitFor(t, "host messages", func() bool {
+				p, ok := peerByAddr(stats, host)
+				return ok && p.Counts["router_solicitation"] == 1 && p.Counts["neighbor_solicitation"] == 1 && p.Counts["mld_report"] == 1
+			})
+
+			r := stats.GetRouters()[0]
+			if r.Address != router || r.MAC != itRouterMAC.String() || !r.Managed || r.Lifetime != 1800*time.Second {
+				t.Errorf("router = %+v", r)
+			}
+			if len(r.Prefixes) != 1 || r.Prefixes[0].Prefix != "2001:db8:1::/64" || len(r.RDNSS) != 1 {
+				t.Errorf("router options: prefixes %+v, rdnss %v", r.Prefixes, r.RDNSS)
+			}
+			if r.Interface != itCaptureIface {
+				t.Errorf("router interface = %q, want %s", r.Interface, itCaptureIface)
+			}
+
+			p, _ := peerByAddr(stats, host)
+			if p.MAC != itHostMAC.String() || p.HopLimit == 0 {
+				t.Errorf("host = %+v", p)
+			}
+			if len(p.Groups) != 1 || p.Groups[0] != "ff02::fb" {
+				t.Errorf("host groups = %v, want [ff02::fb]", p.Groups)
+			}
+			if capture == CapturePacket && p.NoRouterAlert != 0 {
+				t.Errorf("host NoRouterAlert = %d, want 0", p.NoRouterAlert)
+			}
+		})
+	}
+}
+
+func TestIntegration_PacketChecks(t *testing.T) {
+	ns := setupVeth(t)
+	stats := startListener(t, CapturePacket)
+	sender := openSender(t, ns)
+
+	// An MLD report without Router Alert and an NS with a broken checksum.
+	report := buildMLDv2Report([]net.IP{net.ParseIP("ff02::c")})
+	sender.send(t, itHostMAC, "ff02::16", buildIPv6Packet("fe80::20", "ff02::16", 1, nil, nhICMPv6, report))
+	ns1 := buildIPv6Packet("fe80::21", "ff02::1", 255, nil, nhICMPv6, buildNS(net.ParseIP("fe80::1"), itHostMAC))
+	ns1[len(ns1)-1] ^= 0xff
+	sender.send(t, itHostMAC, "ff02::1", ns1)
+
+	waitFor(t, "router alert violation", func() bool { return stats.GetMLDRouterAlertViolations()[raNoHopByHop] == 1 })
+	waitFor(t, "checksum failure", func() bool { return stats.ChecksumFailures() == 1 })
+	if _, ok := peerByAddr(stats, "fe80::21"); ok {
+		t.Error("message with a bad checksum was recorded")
+	}
+}
+
+func TestIntegration_RouterProbe(t *testing.T) {
+	ns := setupVeth(t)
+	// Skip DAD so our link-local address is usable at once, and give the
+	// scratch end fe80::1 so its kernel answers neighbor resolution for it.
+	for _, args := range [][]string{
+		{"sysctl", "-qw", "net.ipv6.conf." + itCaptureIface + ".accept_dad=0"},
+		{"ip", "link", "set", itCaptureIface, "down"},
+		{"ip", "link", "set", itCaptureIface, "up"},
+		{"ip", "-n", ns, "addr", "add", "fe80::1/64", "dev", itSendIface, "nodad"},
+	} {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+	}
+	stats := startListener(t, CaptureSocket)
+	sender := openSender(t, ns)
+
+	// Two routers advertise; only fe80::1 exists on the link. The RAs carry
+	// the scratch end's real MAC, which the kernel caches for the probes.
+	var mac net.HardwareAddr
+	inNetns(t, ns, func() error {
+		ifi, err := net.InterfaceByName(itSendIface)
+		if err == nil {
+			mac = ifi.HardwareAddr
+		}
+		return err
+	})
+	for _, router := range []string{"fe80::1", "fe80::2"} {
+		ra := buildRAFull(64, false, false, 1800, mac)
+		sender.send(t, mac, "ff02::1", buildIPv6Packet(router, "ff02::1", 255, nil, nhICMPv6, ra))
+	}
+	waitFor(t, "routers", func() bool { return len(stats.GetRouters()) == 2 })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prober := NewRouterProber(RouterProberConfig{
+		Interface: itCaptureIface,
+		Interval:  300 * time.Millisecond,
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:     stats,
+	})
+	go prober.Run(ctx)
+
+	reach := func(router string) RouterReachability {
+		for _, r := range stats.GetRouterReachability() {
+			if r.Router == router {
+				return r
+			}
+		}
+		return RouterReachability{}
+	}
+	waitFor(t, "fe80::2 unreachable", func() bool { return reach("fe80::2").Unreachable })
+	if r := reach("fe80::1"); r.Answers == 0 || r.Unreachable {
+		t.Errorf("fe80::1 = %+v, want answered probes", r)
+	}
+	found := false
+	for _, a := range stats.GetAlerts() {
+		found = found || (a.Category == "router_unreachable" && a.Source == "fe80::2")
+	}
+	if !found {
+		t.Error("no router_unreachable alert for fe80::2")
+	}
+}
+
+func TestIntegration_MemberPorts(t *testing.T) {
+	ns := setupVeth(t)
+	const bridge = "ndpitbr0"
+	for _, args := range [][]string{
+		{"link", "add", bridge, "type", "bridge"},
+		{"link", "set", itCaptureIface, "master", bridge},
+		{"link", "set", bridge, "up"},
+	} {
+		if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+			t.Fatalf("ip %v: %v\n%s", args, err, out)
+		}
+	}
+	t.Cleanup(func() { _ = exec.Command("ip", "link", "del", bridge).Run() })
+
+	stats := startListenerConfig(t, NDPListenerConfig{Interface: bridge, Capture: CapturePacket, MemberPorts: true})
+	sender := openSender(t, ns)
+
+	ra := buildRAFull(64, false, false, 1800, itRouterMAC)
+	sender.send(t, itRouterMAC, "ff02::1", buildIPv6Packet("fe80::1", "ff02::1", 255, nil, nhICMPv6, ra))
+	waitFor(t, "router", func() bool { return len(stats.GetRouters()) > 0 })
+
+	r := stats.GetRouters()[0]
+	if r.Interface != bridge || r.Port != itCaptureIface {
+		t.Errorf("router on %q port %q, want %s port %s", r.Interface, r.Port, bridge, itCaptureIface)
+	}
+	// The copy delivered to the bridge itself must not count twice.
+	time.Sleep(200 * time.Millisecond)
+	if p, _ := peerByAddr(stats, "fe80::1"); p.Counts["router_advertisement"] != 1 {
+		t.Errorf("RA counted %d times, want 1", p.Counts["router_advertisement"])
+	}
+}
+
+func TestIntegration_LLDP(t *testing.T) {
+	ns := setupVeth(t)
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewLLDPListener(LLDPListenerConfig{
+		Interfaces: []string{itCaptureIface},
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:      stats,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Run(ctx)
+	time.Sleep(200 * time.Millisecond)
+
+	sender := openSender(t, ns)
+	sender.sendFrame(t, buildLLDPFrame(120))
+	waitFor(t, "switch neighbor", func() bool { return len(stats.GetSwitchNeighbors()) == 1 })
+	if n := stats.GetSwitchNeighbors()[0]; n.Interface != itCaptureIface || n.System != "sw1" || n.Port != "Gi1/0/12" {
+		t.Errorf("neighbor = %+v", n)
+	}
+
+	sender.sendFrame(t, buildCDPFrame())
+	waitFor(t, "CDP neighbor", func() bool { return stats.GetSwitchNeighbors()[0].Protocol == ProtocolCDP })
+}
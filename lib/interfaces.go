@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// InterfaceSummary aggregates the peers and routers of one capture
+// interface, for captures on several (see SummarizeInterfaces).
+type InterfaceSummary struct {
+	Interface string         `json:"interface"` // "" when unknown, e.g. pcap replay
+	Peers     int            `json:"peers"`
+	Routers   int            `json:"routers"`
+	Total     int            `json:"total"`  // messages within the window
+	Counts    map[string]int `json:"counts"` // by message kind
+}
+
+// SummarizeInterfaces groups peers and routers by the interface they were
+// last heard on, sorted by interface name.
+func SummarizeInterfaces(peers []PeerSummary, routers []RouterInfo) []InterfaceSummary {
+	byName := make(map[string]*InterfaceSummary)
+	get := func(name string) *InterfaceSummary {
+		s, ok := byName[name]
+		if !ok {
+			s = &InterfaceSummary{Interface: name, Counts: make(map[string]int)}
+			byName[name] = s
+		}
+		return s
+	}
+	for _, p := range peers {
+		s := get(p.Interface)
+		s.Peers++
+		s.Total += p.Total
+		for kind, n := range p.Counts {
+			s.Counts[kind] += n
+		}
+	}
+	for _, r := range routers {
+		get(r.Interface).Routers++
+	}
+
+	result := make([]InterfaceSummary, 0, len(byName))
+	for _, s := range byName {
+		result = append(result, *s)
+	}
+	slices.SortFunc(result, func(a, b InterfaceSummary) int { return strings.Compare(a.Interface, b.Interface) })
+	return result
+}
+
+// IPv6Interfaces names the interfaces that are up, not loopback and have an
+// IPv6 address, in index order: what --iface all captures on.
+func IPv6Interfaces() ([]string, error) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("list interfaces: %w", err)
+	}
+	var names []string
+	for _, ifi := range ifis {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipn, ok := a.(*net.IPNet); ok && ipn.IP.To4() == nil {
+				names = append(names, ifi.Name)
+				break
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no interface is up with an IPv6 address")
+	}
+	return names, nil
+}
@@ -0,0 +1,30 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeInterfaces(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	for _, p := range []struct{ addr, iface, kind string }{
+		{"fe80::1%vlan10", "vlan10", "neighbor_solicitation"},
+		{"fe80::2%vlan10", "vlan10", "neighbor_advertisement"},
+		{"fe80::1%vlan20", "vlan20", "neighbor_solicitation"},
+	} {
+		stats.RecordMessage(p.addr, p.kind)
+		stats.RecordInterface(p.addr, p.iface)
+	}
+	stats.RecordRouter(RouterInfo{Address: "fe80::fe%vlan20", Interface: "vlan20", Lifetime: time.Minute})
+
+	got := SummarizeInterfaces(stats.GetStats(), stats.GetRouters())
+	if len(got) != 2 {
+		t.Fatalf("got %+v, want vlan10 and vlan20", got)
+	}
+	if s := got[0]; s.Interface != "vlan10" || s.Peers != 2 || s.Routers != 0 || s.Total != 2 || s.Counts["neighbor_advertisement"] != 1 {
+		t.Errorf("vlan10 = %+v", s)
+	}
+	if s := got[1]; s.Interface != "vlan20" || s.Peers != 1 || s.Routers != 1 || s.Total != 1 {
+		t.Errorf("vlan20 = %+v", s)
+	}
+}
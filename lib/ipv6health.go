@@ -0,0 +1,234 @@
+package lib
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// Points each signal contributes to a segment's IPv6 health score.
+const (
+	healthPointsRA         = 30
+	healthPointsRedundancy = 15
+	healthPointsDNS        = 20
+	healthPointsDAD        = 20
+	healthPointsAlerts     = 15
+	// healthAlertPenalty is what each warning in the window costs; a
+	// critical alert costs all the alert points.
+	healthAlertPenalty = 5
+)
+
+// SegmentHealth is the IPv6 health score of one segment, the interface it
+// was seen on: a 0-100 summary of how well the segment provides IPv6, for
+// tracking across sites. Score is the sum of the signals' points.
+type SegmentHealth struct {
+	Interface string         `json:"interface"` // "" when the capture can't tell links apart
+	Score     int            `json:"score"`
+	Signals   []HealthSignal `json:"signals"`
+}
+
+// HealthSignal is one input to a SegmentHealth score.
+type HealthSignal struct {
+	Name   string `json:"name"` // router_advertisements, router_redundancy, dns, dad or alerts
+	Points int    `json:"points"`
+	Max    int    `json:"max"`
+	Detail string `json:"detail"`
+}
+
+// GetIPv6Health scores every segment with routers or peers in the window,
+// ordered by interface name.
+func (s *NDPStats) GetIPv6Health() []SegmentHealth {
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ifaces := make(map[string]string, len(s.peers))
+	for key, ps := range s.peers {
+		ifaces[key] = ps.Interface
+	}
+	return ipv6Health(now, s.window, s.routersLocked(), s.dadLocked(now), s.alertsLocked(), ifaces)
+}
+
+// ipv6Health scores the segments of the routers and the peers in ifaces
+// (peer address -> interface). DAD transactions and alerts count on the
+// segment of the address they are about; when that is unknown and there is
+// only one segment, on that one.
+func ipv6Health(now time.Time, window time.Duration, routers []RouterInfo, dad []DADTransaction, alerts []Alert, ifaces map[string]string) []SegmentHealth {
+	segments := make(map[string]bool)
+	for _, r := range routers {
+		segments[r.Interface] = true
+	}
+	for _, iface := range ifaces {
+		segments[iface] = true
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+	unzonedIfaces := make(map[string]string, len(ifaces))
+	for addr, iface := range ifaces {
+		unzonedIfaces[unzoned(addr)] = iface
+	}
+	segmentOf := func(addr string) (string, bool) {
+		if a := addrKey(addr); a.IsValid() && a.Zone() != "" && segments[a.Zone()] {
+			return a.Zone(), true
+		}
+		if iface, ok := unzonedIfaces[unzoned(addr)]; ok {
+			return iface, true
+		}
+		if len(segments) == 1 {
+			for iface := range segments {
+				return iface, true
+			}
+		}
+		return "", false
+	}
+
+	byIface := make(map[string][]RouterInfo)
+	for _, r := range routers {
+		byIface[r.Interface] = append(byIface[r.Interface], r)
+	}
+	type dadCounts struct{ passed, duplicate int }
+	dadBy := make(map[string]*dadCounts)
+	for _, t := range dad {
+		iface, ok := segmentOf(t.Target)
+		if !ok || t.Outcome == DADPending {
+			continue
+		}
+		c := dadBy[iface]
+		if c == nil {
+			c = &dadCounts{}
+			dadBy[iface] = c
+		}
+		if t.Outcome == DADDuplicate || t.Outcome == DADConflict {
+			c.duplicate++
+		} else {
+			c.passed++
+		}
+	}
+	type alertCounts struct{ warning, critical int }
+	alertsBy := make(map[string]*alertCounts)
+	for _, a := range alerts {
+		if now.Sub(a.Time) > window || a.Severity < SeverityWarning {
+			continue
+		}
+		iface, ok := segmentOf(a.Source)
+		if !ok {
+			continue
+		}
+		c := alertsBy[iface]
+		if c == nil {
+			c = &alertCounts{}
+			alertsBy[iface] = c
+		}
+		if a.Severity >= SeverityCritical {
+			c.critical++
+		} else {
+			c.warning++
+		}
+	}
+
+	var out []SegmentHealth
+	for _, iface := range slices.Sorted(maps.Keys(segments)) {
+		rs := byIface[iface]
+		var defaults []RouterInfo
+		for _, r := range rs {
+			if r.Lifetime > 0 {
+				defaults = append(defaults, r)
+			}
+		}
+		h := SegmentHealth{Interface: iface}
+		h.Signals = append(h.Signals, raSignal(rs, defaults), redundancySignal(defaults), dnsSignal(rs))
+
+		sig := HealthSignal{Name: "dad", Max: healthPointsDAD, Points: healthPointsDAD, Detail: "no DAD conflicts seen"}
+		if c := dadBy[iface]; c != nil {
+			total := c.passed + c.duplicate
+			sig.Points = int(math.Round(float64(healthPointsDAD*c.passed) / float64(total)))
+			sig.Detail = fmt.Sprintf("%d of %d DAD probes passed", c.passed, total)
+		}
+		h.Signals = append(h.Signals, sig)
+
+		sig = HealthSignal{Name: "alerts", Max: healthPointsAlerts, Points: healthPointsAlerts, Detail: "no alerts"}
+		if c := alertsBy[iface]; c != nil {
+			sig.Points = max(0, healthPointsAlerts-healthAlertPenalty*c.warning)
+			if c.critical > 0 {
+				sig.Points = 0
+			}
+			sig.Detail = fmt.Sprintf("%d critical, %d warning alerts", c.critical, c.warning)
+		}
+		h.Signals = append(h.Signals, sig)
+
+		for _, sig := range h.Signals {
+			h.Score += sig.Points
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+func raSignal(rs, defaults []RouterInfo) HealthSignal {
+	sig := HealthSignal{Name: "router_advertisements", Max: healthPointsRA}
+	switch {
+	case len(defaults) > 0:
+		sig.Points = healthPointsRA
+		sig.Detail = "default router advertised"
+	case len(rs) > 0:
+		sig.Points = healthPointsRA / 3
+		sig.Detail = "RAs, but no default router (lifetime 0)"
+	default:
+		sig.Detail = "no Router Advertisements"
+	}
+	return sig
+}
+
+func redundancySignal(defaults []RouterInfo) HealthSignal {
+	sig := HealthSignal{Name: "router_redundancy", Max: healthPointsRedundancy}
+	switch len(defaults) {
+	case 0:
+		sig.Detail = "no default router"
+	case 1:
+		sig.Detail = "single default router"
+	default:
+		sig.Points = healthPointsRedundancy
+		sig.Detail = fmt.Sprintf("%d default routers", len(defaults))
+	}
+	return sig
+}
+
+// dnsSignal checks that every router advertises the same RDNSS servers,
+// or points hosts at DHCPv6 instead.
+func dnsSignal(rs []RouterInfo) HealthSignal {
+	sig := HealthSignal{Name: "dns", Max: healthPointsDNS}
+	if len(rs) == 0 {
+		sig.Detail = "no routers"
+		return sig
+	}
+	sets := make(map[string]bool)
+	advertising, dhcp := 0, false
+	for _, r := range rs {
+		if len(r.RDNSS) > 0 {
+			advertising++
+			sets[rdnssSet(r.RDNSS)] = true
+		}
+		dhcp = dhcp || r.Other || r.Managed
+	}
+	switch {
+	case len(sets) > 1:
+		sig.Points = healthPointsDNS / 4
+		sig.Detail = fmt.Sprintf("routers advertise %d different RDNSS sets", len(sets))
+	case advertising == len(rs):
+		sig.Points = healthPointsDNS
+		for set := range sets {
+			sig.Detail = "consistent RDNSS: " + set
+		}
+	case advertising > 0:
+		sig.Points = healthPointsDNS / 2
+		sig.Detail = fmt.Sprintf("RDNSS from only %d of %d routers", advertising, len(rs))
+	case dhcp:
+		sig.Points = healthPointsDNS
+		sig.Detail = "DNS via DHCPv6"
+	default:
+		sig.Detail = "no DNS servers advertised"
+	}
+	return sig
+}
@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIPv6Health(t *testing.T) {
+	now := time.Now()
+	routers := []RouterInfo{
+		{Address: "fe80::1", Interface: "eth0", Lifetime: 30 * time.Minute, RDNSS: []string{"2001:db8::53", "2001:db8::54"}},
+		{Address: "fe80::2", Interface: "eth0", Lifetime: 30 * time.Minute, RDNSS: []string{"2001:db8::54", "2001:db8::53"}},
+		{Address: "fe80::3", Interface: "eth1", Lifetime: 30 * time.Minute},
+	}
+	dad := []DADTransaction{
+		{Target: "fe80::a", Outcome: DADPassed},
+		{Target: "fe80::a", Outcome: DADDuplicate},
+		{Target: "fe80::b", Outcome: DADPending},
+		{Target: "fe80::ffff", Outcome: DADDuplicate}, // on neither segment as far as we know
+	}
+	alerts := []Alert{
+		{Time: now, Severity: SeverityCritical, Source: "2001:db8:1::b"},
+		{Time: now, Severity: SeverityWarning, Source: "fe80::a%eth0"},
+		{Time: now, Severity: SeverityInfo, Source: "fe80::a%eth0"},
+		{Time: now.Add(-time.Hour), Severity: SeverityCritical, Source: "fe80::a%eth0"}, // outside the window
+	}
+	ifaces := map[string]string{
+		"fe80::a%eth0":  "eth0",
+		"2001:db8:1::b": "eth1",
+	}
+
+	got := ipv6Health(now, 15*time.Minute, routers, dad, alerts, ifaces)
+	if len(got) != 2 || got[0].Interface != "eth0" || got[1].Interface != "eth1" {
+		t.Fatalf("segments = %+v, want eth0 and eth1", got)
+	}
+	want := map[string]map[string]int{
+		"eth0": {"router_advertisements": 30, "router_redundancy": 15, "dns": 20, "dad": 10, "alerts": 10},
+		"eth1": {"router_advertisements": 30, "router_redundancy": 0, "dns": 0, "dad": 20, "alerts": 0},
+	}
+	for _, h := range got {
+		total := 0
+		for _, sig := range h.Signals {
+			if sig.Points != want[h.Interface][sig.Name] {
+				t.Errorf("%s %s = %d (%s), want %d", h.Interface, sig.Name, sig.Points, sig.Detail, want[h.Interface][sig.Name])
+			}
+			total += sig.Points
+		}
+		if h.Score != total {
+			t.Errorf("%s score = %d, want the sum %d", h.Interface, h.Score, total)
+		}
+	}
+}
+
+func TestIPv6Health_DNS(t *testing.T) {
+	tests := []struct {
+		name    string
+		routers []RouterInfo
+		want    int
+	}{
+		{"dhcpv6", []RouterInfo{{Other: true}}, healthPointsDNS},
+		{"disagree", []RouterInfo{{RDNSS: []string{"2001:db8::53"}}, {RDNSS: []string{"2001:db8::99"}}}, healthPointsDNS / 4},
+		{"partial", []RouterInfo{{RDNSS: []string{"2001:db8::53"}}, {}}, healthPointsDNS / 2},
+		{"none", []RouterInfo{{}}, 0},
+	}
+	for _, tt := range tests {
+		if got := dnsSignal(tt.routers); got.Points != tt.want {
+			t.Errorf("%s: %d points (%s), want %d", tt.name, got.Points, got.Detail, tt.want)
+		}
+	}
+}
+
+func TestGetIPv6Health(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	if got := stats.GetIPv6Health(); got != nil {
+		t.Fatalf("health with nothing seen = %+v, want none", got)
+	}
+	stats.RecordRouter(RouterInfo{Address: "fe80::1", Interface: "eth0", Lifetime: time.Minute, LastSeen: time.Now()})
+	stats.RecordMessage("fe80::1", "router_advertisement")
+	stats.RecordInterface("fe80::1", "eth0")
+
+	got := stats.GetIPv6Health()
+	if len(got) != 1 || got[0].Interface != "eth0" || got[0].Score != 30+0+0+20+15 {
+		t.Errorf("health = %+v, want eth0 scoring 65", got)
+	}
+	if snap := stats.Snapshot(); len(snap.IPv6Health) != 1 || snap.IPv6Health[0].Score != got[0].Score {
+		t.Errorf("snapshot health = %+v, want %+v", snap.IPv6Health, got)
+	}
+}
@@ -0,0 +1,200 @@
+package lib
+
+import (
+	"NDPeekr/lib/craft"
+	"errors"
+	"net"
+)
+
+// IPv6 next-header values used while walking the extension header chain.
+const (
+	nhHopByHop    = 0
+	nhRouting     = 43
+	nhFragment    = 44
+	nhESP         = 50
+	nhAH          = 51
+	nhICMPv6      = 58
+	nhNoNext      = 59
+	nhDestOptions = 60
+)
+
+// maxExtHeaders bounds the chain walk; real NDP/MLD traffic carries at most one
+// or two extension headers.
+const maxExtHeaders = 8
+
+// Reasons an extension header chain is counted as unexpected.
+const (
+	extHopByHopNotFirst = "hop_by_hop_not_first"
+	extRouting          = "routing_header"
+	extFragment         = "fragment"
+	extAuth             = "authentication_header"
+	extESP              = "esp"
+	extTooMany          = "too_many_headers"
+	extTruncated        = "truncated"
+)
+
+var (
+	errNotIPv6     = errors.New("not an IPv6 packet")
+	errNoPayload   = errors.New("upper-layer payload unavailable")
+	errShortPacket = errors.New("truncated IPv6 packet")
+)
+
+// ipv6Packet is a decoded IPv6 packet with its extension headers skipped.
+type ipv6Packet struct {
+	src, dst   net.IP
+	hopLimit   int
+	nextHeader int    // upper-layer protocol after the extension headers, -1 if unknown
+	payload    []byte // upper-layer payload
+	extHeaders []int  // extension header types, in order
+	hbhOptions []byte // option area of the Hop-by-Hop header, nil if absent
+	// anomaly is "" for a normal chain (an optional leading Hop-by-Hop header
+	// and Destination Options headers) or the reason it is unexpected.
+	anomaly string
+}
+
+// decodeIPv6 parses an IPv6 header and walks Hop-by-Hop, Destination Options,
+// Routing, Fragment and AH headers to locate the upper-layer payload.
+// If the chain is unexpected the packet is still returned (with anomaly set)
+// whenever the payload can be located; otherwise an error is returned
+// together with the partially decoded packet so the anomaly can be counted.
+func decodeIPv6(pkt []byte) (ipv6Packet, error) {
+	p := ipv6Packet{nextHeader: -1}
+	if len(pkt) < 40 {
+		return p, errShortPacket
+	}
+	if pkt[0]>>4 != 6 {
+		return p, errNotIPv6
+	}
+
+	p.src = net.IP(pkt[8:24])
+	p.dst = net.IP(pkt[24:40])
+	p.hopLimit = int(pkt[7])
+
+	end := 40 + (int(pkt[4])<<8 | int(pkt[5]))
+	if end > len(pkt) {
+		p.anomaly = extTruncated
+		return p, errShortPacket
+	}
+	pkt = pkt[:end]
+
+	next := int(pkt[6])
+	off := 40
+	for {
+		var hdrLen int
+		switch next {
+		case nhHopByHop, nhDestOptions, nhRouting:
+			if off+2 > len(pkt) {
+				p.anomaly = extTruncated
+				return p, errShortPacket
+			}
+			hdrLen = (int(pkt[off+1]) + 1) * 8
+		case nhFragment:
+			hdrLen = 8
+		case nhAH:
+			if off+2 > len(pkt) {
+				p.anomaly = extTruncated
+				return p, errShortPacket
+			}
+			hdrLen = (int(pkt[off+1]) + 2) * 4
+		case nhESP:
+			// Everything after the ESP header is encrypted.
+			p.extHeaders = append(p.extHeaders, next)
+			p.anomaly = extESP
+			return p, errNoPayload
+		default:
+			// Upper-layer protocol (or No Next Header)
+			p.nextHeader = next
+			p.payload = pkt[off:]
+			return p, nil
+		}
+
+		if off+hdrLen > len(pkt) {
+			p.anomaly = extTruncated
+			return p, errShortPacket
+		}
+		if len(p.extHeaders) == maxExtHeaders {
+			p.anomaly = extTooMany
+			return p, errNoPayload
+		}
+
+		switch next {
+		case nhHopByHop:
+			if len(p.extHeaders) > 0 {
+				p.setAnomaly(extHopByHopNotFirst)
+			} else {
+				p.hbhOptions = pkt[off+2 : off+hdrLen]
+			}
+		case nhRouting:
+			p.setAnomaly(extRouting)
+		case nhAH:
+			p.setAnomaly(extAuth)
+		case nhFragment:
+			p.setAnomaly(extFragment)
+			// Only an atomic fragment (offset 0, no more fragments) holds
+			// the whole message; anything else can't be ingested.
+			fragOffset := int(pkt[off+2])<<5 | int(pkt[off+3])>>3
+			more := pkt[off+3]&1 != 0
+			if fragOffset != 0 || more {
+				p.extHeaders = append(p.extHeaders, next)
+				p.nextHeader = int(pkt[off])
+				return p, errNoPayload
+			}
+		}
+
+		p.extHeaders = append(p.extHeaders, next)
+		next = int(pkt[off])
+		off += hdrLen
+	}
+}
+
+// setAnomaly keeps the first reason found.
+func (p *ipv6Packet) setAnomaly(reason string) {
+	if p.anomaly == "" {
+		p.anomaly = reason
+	}
+}
+
+// RecordExtHeaderAnomaly counts an ICMPv6 packet whose extension header chain
+// was unexpected for the given reason.
+func (s *NDPStats) RecordExtHeaderAnomaly(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.extAnomalies[reason]++
+}
+
+// GetExtHeaderAnomalies returns the unexpected extension header counts by reason.
+func (s *NDPStats) GetExtHeaderAnomalies() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.extAnomaliesLocked()
+}
+
+// extAnomaliesLocked copies the anomaly counters. Callers must hold s.mu.
+func (s *NDPStats) extAnomaliesLocked() map[string]int {
+	result := make(map[string]int, len(s.extAnomalies))
+	for k, v := range s.extAnomalies {
+		result[k] = v
+	}
+	return result
+}
+
+// icmpv6Checksum computes the ICMPv6 checksum of msg over the IPv6
+// pseudo-header (RFC 8200 section 8.1). Computed over a message whose
+// checksum field is filled in, a valid message yields 0.
+func icmpv6Checksum(src, dst net.IP, msg []byte) uint16 {
+	return craft.Checksum(src, dst, msg)
+}
+
+// RecordChecksumFailure counts an ICMPv6 message dropped for a bad checksum.
+func (s *NDPStats) RecordChecksumFailure() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checksumFailures++
+}
+
+// ChecksumFailures returns the number of ICMPv6 messages dropped for a bad checksum.
+func (s *NDPStats) ChecksumFailures() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checksumFailures
+}
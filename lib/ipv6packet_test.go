@@ -0,0 +1,226 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+// extHeader is one extension header for buildIPv6Packet: its type and the
+// bytes following the next-header byte (length byte included).
+type extHeader struct {
+	typ  int
+	body []byte
+}
+
+// routerAlertHBH is a Hop-by-Hop header with the MLD Router Alert option and PadN.
+var routerAlertHBH = extHeader{nhHopByHop, []byte{0, 5, 2, 0, 0, 1, 0}}
+
+// buildIPv6Packet wraps payload in an IPv6 header and the given extension headers.
+func buildIPv6Packet(src, dst string, hopLimit int, exts []extHeader, upper int, payload []byte) []byte {
+	var chain []byte
+	for i, h := range exts {
+		next := upper
+		if i+1 < len(exts) {
+			next = exts[i+1].typ
+		}
+		chain = append(chain, byte(next))
+		chain = append(chain, h.body...)
+	}
+	first := upper
+	if len(exts) > 0 {
+		first = exts[0].typ
+	}
+
+	pkt := make([]byte, 40)
+	pkt[0] = 6 << 4
+	plen := len(chain) + len(payload)
+	pkt[4], pkt[5] = byte(plen>>8), byte(plen)
+	pkt[6] = byte(first)
+	pkt[7] = byte(hopLimit)
+	copy(pkt[8:24], net.ParseIP(src).To16())
+	copy(pkt[24:40], net.ParseIP(dst).To16())
+	pkt = append(pkt, chain...)
+	pkt = append(pkt, payload...)
+
+	if upper == nhICMPv6 && len(payload) >= 4 {
+		msg := pkt[len(pkt)-len(payload):]
+		msg[2], msg[3] = 0, 0
+		sum := icmpv6Checksum(net.ParseIP(src), net.ParseIP(dst), msg)
+		msg[2], msg[3] = byte(sum>>8), byte(sum)
+	}
+	return pkt
+}
+
+func TestDecodeIPv6_NoExtensionHeaders(t *testing.T) {
+	rs := buildRS(net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff})
+	p, err := decodeIPv6(buildIPv6Packet("fe80::1", "ff02::2", 255, nil, nhICMPv6, rs))
+	if err != nil {
+		t.Fatalf("decodeIPv6: %v", err)
+	}
+	if p.nextHeader != nhICMPv6 || len(p.payload) != len(rs) || p.payload[0] != 133 {
+		t.Errorf("payload not located: next=%d len=%d", p.nextHeader, len(p.payload))
+	}
+	if p.src.String() != "fe80::1" || p.dst.String() != "ff02::2" || p.hopLimit != 255 {
+		t.Errorf("header = %s -> %s hl %d", p.src, p.dst, p.hopLimit)
+	}
+	if p.anomaly != "" || len(p.extHeaders) != 0 {
+		t.Errorf("anomaly = %q, ext = %v; want none", p.anomaly, p.extHeaders)
+	}
+}
+
+func TestDecodeIPv6_SkipsHopByHopAndDestOptions(t *testing.T) {
+	report := buildMLDv1Report(net.ParseIP("ff02::fb"))
+	dstOpts := extHeader{nhDestOptions, []byte{0, 1, 4, 0, 0, 0, 0}} // PadN
+	p, err := decodeIPv6(buildIPv6Packet("fe80::2", "ff02::fb", 1, []extHeader{routerAlertHBH, dstOpts}, nhICMPv6, report))
+	if err != nil {
+		t.Fatalf("decodeIPv6: %v", err)
+	}
+	if p.nextHeader != nhICMPv6 || p.payload[0] != 131 {
+		t.Errorf("payload not located after extension headers")
+	}
+	if p.anomaly != "" {
+		t.Errorf("anomaly = %q, want none", p.anomaly)
+	}
+	if len(p.extHeaders) != 2 || p.extHeaders[0] != nhHopByHop || p.extHeaders[1] != nhDestOptions {
+		t.Errorf("extHeaders = %v", p.extHeaders)
+	}
+	if len(p.hbhOptions) != 6 || p.hbhOptions[0] != 5 {
+		t.Errorf("hbhOptions = %x, want Router Alert first", p.hbhOptions)
+	}
+}
+
+func TestDecodeIPv6_UnexpectedChains(t *testing.T) {
+	ns := buildNS(net.ParseIP("fe80::9"), nil)
+	routing := extHeader{nhRouting, []byte{0, 0, 0, 0, 0, 0, 0}}
+	atomicFrag := extHeader{nhFragment, []byte{0, 0, 0, 0, 0, 0, 1}}
+	firstFrag := extHeader{nhFragment, []byte{0, 0, 1, 0, 0, 0, 1}}
+	laterFrag := extHeader{nhFragment, []byte{0, 0, 8, 0, 0, 0, 1}}
+	dstOpts := extHeader{nhDestOptions, []byte{0, 1, 4, 0, 0, 0, 0}}
+
+	tests := []struct {
+		name    string
+		exts    []extHeader
+		anomaly string
+		wantErr bool
+	}{
+		{"routing", []extHeader{routing}, extRouting, false},
+		{"atomic fragment", []extHeader{atomicFrag}, extFragment, false},
+		{"first fragment", []extHeader{firstFrag}, extFragment, true},
+		{"later fragment", []extHeader{laterFrag}, extFragment, true},
+		{"hop-by-hop not first", []extHeader{dstOpts, routerAlertHBH}, extHopByHopNotFirst, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := decodeIPv6(buildIPv6Packet("fe80::3", "ff02::1:ff00:9", 255, tt.exts, nhICMPv6, ns))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if p.anomaly != tt.anomaly {
+				t.Errorf("anomaly = %q, want %q", p.anomaly, tt.anomaly)
+			}
+			if p.nextHeader != nhICMPv6 {
+				t.Errorf("nextHeader = %d, want ICMPv6", p.nextHeader)
+			}
+		})
+	}
+}
+
+func TestDecodeIPv6_Malformed(t *testing.T) {
+	if _, err := decodeIPv6(make([]byte, 20)); err == nil {
+		t.Error("short packet: expected error")
+	}
+	v4 := make([]byte, 40)
+	v4[0] = 4 << 4
+	if _, err := decodeIPv6(v4); err == nil {
+		t.Error("IPv4 packet: expected error")
+	}
+	pkt := buildIPv6Packet("fe80::1", "ff02::1", 255, []extHeader{routerAlertHBH}, nhICMPv6, buildRS(nil))
+	if p, err := decodeIPv6(pkt[:42]); err == nil || p.anomaly != extTruncated {
+		t.Errorf("truncated: err = %v, anomaly = %q", err, p.anomaly)
+	}
+}
+
+func TestHandlePacket_CountsAnomalies(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	ns := buildNS(net.ParseIP("fe80::9"), net.HardwareAddr{1, 2, 3, 4, 5, 6})
+	routing := extHeader{nhRouting, []byte{0, 0, 0, 0, 0, 0, 0}}
+	l.handlePacket(buildIPv6Packet("fe80::4", "ff02::1:ff00:9", 255, []extHeader{routing}, nhICMPv6, ns), 0, "")
+	l.handlePacket(buildIPv6Packet("fe80::5", "ff02::1:ff00:9", 255, nil, nhICMPv6, ns), 0, "")
+	// Non-ICMPv6 traffic is ignored entirely.
+	l.handlePacket(buildIPv6Packet("fe80::6", "fe80::7", 64, []extHeader{routing}, 17, make([]byte, 8)), 0, "")
+
+	if got := stats.GetExtHeaderAnomalies(); len(got) != 1 || got[extRouting] != 1 {
+		t.Errorf("anomalies = %v, want routing_header: 1", got)
+	}
+	if peers := stats.GetStats(); len(peers) != 2 {
+		t.Errorf("got %d peers, want 2 (the unexpected chain is still ingested)", len(peers))
+	}
+}
+
+func TestICMPv6Checksum(t *testing.T) {
+	pkt := buildIPv6Packet("fe80::1", "ff02::2", 255, nil, nhICMPv6, buildRS(nil))
+	src, dst, msg := net.IP(pkt[8:24]), net.IP(pkt[24:40]), pkt[40:]
+	if got := icmpv6Checksum(src, dst, msg); got != 0 {
+		t.Errorf("valid message: checksum = %#04x, want 0", got)
+	}
+	msg[len(msg)-1] ^= 0xff
+	if icmpv6Checksum(src, dst, msg) == 0 {
+		t.Error("corrupted message still verifies")
+	}
+	msg[len(msg)-1] ^= 0xff
+	if icmpv6Checksum(src, net.ParseIP("ff02::1"), msg) == 0 {
+		t.Error("checksum does not cover the pseudo-header")
+	}
+}
+
+func TestHandlePacket_DropsBadChecksum(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	pkt := buildIPv6Packet("fe80::4", "ff02::2", 255, nil, nhICMPv6, buildRS(net.HardwareAddr{1, 2, 3, 4, 5, 6}))
+	pkt[len(pkt)-1] ^= 0x01
+	l.handlePacket(pkt, 0, "")
+
+	if got := stats.ChecksumFailures(); got != 1 {
+		t.Errorf("checksum failures = %d, want 1", got)
+	}
+	if peers := stats.GetStats(); len(peers) != 0 {
+		t.Errorf("got %d peers, want the corrupt message dropped", len(peers))
+	}
+	if alerts := stats.GetAlerts(); len(alerts) != 1 || alerts[0].Category != "bad_checksum" {
+		t.Errorf("alerts = %+v, want one bad_checksum", alerts)
+	}
+}
+
+func TestHandlePacket_AttributesMemberPort(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	mac := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0x01}
+	l.handlePacket(buildIPv6Packet("fe80::1", "ff02::1", 255, nil, nhICMPv6, buildRAFull(64, false, false, 1800, mac)), 0, "eth3")
+
+	if routers := stats.GetRouters(); len(routers) != 1 || routers[0].Port != "eth3" {
+		t.Errorf("routers = %+v, want one on port eth3", routers)
+	}
+	f, err := ParseFilter(`port == "eth3"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peers := stats.GetStats(); len(peers) != 1 || !f.Match(peers[0]) {
+		t.Errorf("peers = %+v, want one matching port == eth3", peers)
+	}
+}
@@ -0,0 +1,252 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gopkg.in/yaml.v3"
+)
+
+// keyActions are the TUI actions that can be rebound, with the key each is
+// bound to by default. The model handles keys by these defaults; a Keymap
+// translates whatever the user pressed into them.
+var keyActions = []struct{ name, key string }{
+	{"quit", "q"},
+	{"next_tab", "tab"},
+	{"prev_tab", "shift+tab"},
+	{"up", "up"},
+	{"down", "down"},
+	{"page_up", "pgup"},
+	{"page_down", "pgdown"},
+	{"top", "home"},
+	{"bottom", "end"},
+	{"select", "enter"},
+	{"actions", "x"},
+	{"story", "i"},
+	{"mark", " "},
+	{"mark_all", "ctrl+a"},
+	{"back", "esc"},
+	{"sort", "s"},
+	{"merge", "m"},
+	{"dedup", "D"},
+	{"filter", "/"},
+	{"time_travel", "t"},
+	{"earlier", "left"},
+	{"later", "right"},
+	{"history", "h"},
+	{"export_graph", "e"},
+	{"edit_rule", "e"},
+	{"toggle_rule", " "},
+	{"ack", "a"},
+	{"freeze", "f"},
+	{"dump_ring", "w"},
+	{"segments", "p"},
+}
+
+// keyPresets add keys to actions on top of their defaults.
+var keyPresets = map[string]map[string][]string{
+	"default": {},
+	"vim": {
+		"up":        {"k"},
+		"down":      {"j"},
+		"page_up":   {"ctrl+b"},
+		"page_down": {"ctrl+f"},
+		"top":       {"g"},
+		"bottom":    {"G"},
+		"prev_tab":  {"H"},
+		"next_tab":  {"L"},
+	},
+	"emacs": {
+		"up":        {"ctrl+p"},
+		"down":      {"ctrl+n"},
+		"page_up":   {"alt+v"},
+		"page_down": {"ctrl+v"},
+		"top":       {"alt+<"},
+		"bottom":    {"alt+>"},
+		"back":      {"ctrl+g"},
+		"filter":    {"ctrl+s"},
+		"earlier":   {"ctrl+b"},
+		"later":     {"ctrl+f"},
+	},
+}
+
+// KeysConfig remaps the TUI's keys: a preset, then individual bindings.
+// A binding replaces every key of its action, preset ones included.
+type KeysConfig struct {
+	Preset   string              `yaml:"preset"`   // default, vim or emacs
+	Bindings map[string]KeyNames `yaml:"bindings"` // action -> keys, e.g. next_tab: "]"
+}
+
+// KeyNames is one key name or a list of them, as Bubble Tea spells them:
+// "q", "ctrl+n", "shift+tab", "pgdown", " " for space.
+type KeyNames []string
+
+// UnmarshalYAML accepts a single key as well as a list.
+func (k *KeyNames) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*k = KeyNames{value.Value}
+		return nil
+	}
+	return value.Decode((*[]string)(k))
+}
+
+// Keymap translates pressed keys into the default key of the action they
+// are bound to. Keys it doesn't mention keep their meaning; keys mapped to
+// "" are unbound. The nil Keymap is the default bindings.
+type Keymap map[string]string
+
+// NewKeymap compiles cfg.
+func NewKeymap(cfg KeysConfig) (Keymap, error) {
+	preset := cfg.Preset
+	if preset == "" {
+		preset = "default"
+	}
+	extra, ok := keyPresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (want default, vim or emacs)", cfg.Preset)
+	}
+
+	bound := make(map[string][]string, len(keyActions)) // action -> keys
+	for _, a := range keyActions {
+		bound[a.name] = append([]string{a.key}, extra[a.name]...)
+	}
+	for action, keys := range cfg.Bindings {
+		if _, ok := bound[action]; !ok {
+			return nil, fmt.Errorf("bindings: unknown action %q", action)
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("bindings.%s: no keys", action)
+		}
+		for _, key := range keys {
+			if key == "" || key == "ctrl+c" {
+				return nil, fmt.Errorf("bindings.%s: %q cannot be bound", action, key)
+			}
+		}
+		bound[action] = keys
+	}
+
+	km := make(Keymap)
+	owner := make(map[string]string) // key -> action, to report conflicts
+	for _, a := range keyActions {
+		for _, key := range bound[a.name] {
+			if other, ok := owner[key]; ok && km[key] != a.key {
+				return nil, fmt.Errorf("key %q is bound to both %s and %s", key, other, a.name)
+			}
+			owner[key] = a.name
+			km[key] = a.key
+		}
+	}
+	// Default keys no action kept are free again.
+	for _, a := range keyActions {
+		if _, ok := km[a.key]; !ok {
+			km[a.key] = ""
+		}
+	}
+	return km, nil
+}
+
+// translate returns the default key of the action key is bound to, "" if
+// key is unbound, or key itself if the map doesn't mention it.
+func (km Keymap) translate(key string) string {
+	if to, ok := km[key]; ok {
+		return to
+	}
+	return key
+}
+
+// defaultKey returns the key action is bound to by default.
+func defaultKey(action string) (string, bool) {
+	for _, a := range keyActions {
+		if a.name == action {
+			return a.key, true
+		}
+	}
+	return "", false
+}
+
+// Key returns the key to show for action in hints: its default key if it is
+// still bound, else the first of the keys it was given.
+func (km Keymap) Key(action string) string {
+	def, _ := defaultKey(action)
+	if to, ok := km[def]; !ok || to == def {
+		return keyLabel(def)
+	}
+	var keys []string
+	for key, to := range km {
+		if to == def && key != def {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return keyLabel(def)
+	}
+	slices.Sort(keys)
+	return keyLabel(keys[0])
+}
+
+// hints renders footer hints from action, description pairs. "navigate"
+// stands for the up and down keys; anything that isn't an action is shown
+// as is, e.g. "1-0".
+func (km Keymap) hints(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key := pairs[i]
+		if key == "navigate" {
+			key = km.Key("up") + "/" + km.Key("down")
+		} else if _, ok := defaultKey(key); ok {
+			key = km.Key(key)
+		}
+		parts = append(parts, key+": "+pairs[i+1])
+	}
+	return strings.Join(parts, "  ")
+}
+
+// keyLabel spells a key name for display.
+func keyLabel(key string) string {
+	switch key {
+	case " ":
+		return "Space"
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	case "left":
+		return "←"
+	case "right":
+		return "→"
+	case "enter", "esc", "tab":
+		return strings.ToUpper(key[:1]) + key[1:]
+	}
+	return key
+}
+
+// specialKeys are the tea.KeyTypes of the non-rune default keys.
+var specialKeys = map[string]tea.KeyType{
+	"tab":       tea.KeyTab,
+	"shift+tab": tea.KeyShiftTab,
+	"up":        tea.KeyUp,
+	"down":      tea.KeyDown,
+	"left":      tea.KeyLeft,
+	"right":     tea.KeyRight,
+	"pgup":      tea.KeyPgUp,
+	"pgdown":    tea.KeyPgDown,
+	"home":      tea.KeyHome,
+	"end":       tea.KeyEnd,
+	"enter":     tea.KeyEnter,
+	"esc":       tea.KeyEsc,
+	" ":         tea.KeySpace,
+}
+
+// keyMsg builds the key message Bubble Tea would deliver for key, so a
+// translated key reaches the tables as if it had been pressed.
+func keyMsg(key string) tea.KeyMsg {
+	if t, ok := specialKeys[key]; ok {
+		if t == tea.KeySpace {
+			return tea.KeyMsg{Type: t, Runes: []rune{' '}}
+		}
+		return tea.KeyMsg{Type: t}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
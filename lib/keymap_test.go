@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewKeymap(t *testing.T) {
+	var cfg KeysConfig
+	doc := "preset: vim\nbindings:\n  quit: Q\n  next_tab: [']', L]\n"
+	if err := yaml.Unmarshal([]byte(doc), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	km, err := NewKeymap(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"Q":   "q",    // rebound
+		"q":   "",     // freed by the rebinding
+		"]":   "tab",  // one of two keys
+		"tab": "",     // replaced, not added to
+		"j":   "down", // from the preset
+		"G":   "end",  // from the preset
+		"s":   "s",    // default
+		"e":   "e",    // shared by export_graph and edit_rule
+		"3":   "3",    // not an action
+	}
+	for key, want := range tests {
+		if got := km.translate(key); got != want {
+			t.Errorf("translate(%q) = %q, want %q", key, got, want)
+		}
+	}
+	if got := km.hints("navigate", "navigate", "next_tab", "switch view", "1-0", "filter", "quit", "quit"); got != "↑/↓: navigate  L: switch view  1-0: filter  Q: quit" {
+		t.Errorf("hints = %q", got)
+	}
+	if got := Keymap(nil).Key("toggle_rule"); got != "Space" {
+		t.Errorf("default toggle_rule key = %q, want Space", got)
+	}
+
+	for name, cfg := range map[string]KeysConfig{
+		"unknown preset": {Preset: "nano"},
+		"unknown action": {Bindings: map[string]KeyNames{"launch": {"l"}}},
+		"no keys":        {Bindings: map[string]KeyNames{"quit": {}}},
+		"ctrl+c":         {Bindings: map[string]KeyNames{"sort": {"ctrl+c"}}},
+		"conflict":       {Bindings: map[string]KeyNames{"sort": {"q"}}},
+		"preset clash":   {Preset: "vim", Bindings: map[string]KeyNames{"freeze": {"j"}}},
+	} {
+		if _, err := NewKeymap(cfg); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+func TestModel_RemappedKeys(t *testing.T) {
+	km, err := NewKeymap(KeysConfig{Preset: "emacs", Bindings: map[string]KeyNames{"next_tab": {"]"}, "quit": {"Q"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewModel(ModelConfig{Stats: NewNDPStats(5 * time.Minute), Keys: km})
+	press := func(key string) {
+		next, _ := m.handleKey(keyMsg(key))
+		m = next.(Model)
+	}
+
+	press("tab")
+	if m.activeTab != tabPeers {
+		t.Fatalf("tab switched tabs after being rebound")
+	}
+	press("]")
+	if m.activeTab == tabPeers {
+		t.Fatalf("] did not switch tabs")
+	}
+	press("q")
+	if m.quitting {
+		t.Fatal("q quit after being rebound")
+	}
+	if _, cmd := m.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("Q")}); cmd == nil {
+		t.Error("Q did not quit")
+	}
+}
@@ -0,0 +1,270 @@
+package lib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Link-layer discovery protocols, for SwitchNeighbor.Protocol.
+const (
+	ProtocolLLDP = "lldp"
+	ProtocolCDP  = "cdp"
+)
+
+var (
+	// lldpMAC is the nearest-bridge group address; switches never forward it.
+	lldpMAC = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+	cdpMAC  = net.HardwareAddr{0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcc}
+)
+
+const etherTypeLLDP = 0x88cc
+
+// defaultNeighborTTL is used for CDP announcements without a usable TTL.
+const defaultNeighborTTL = 180 * time.Second
+
+// SwitchNeighbor is the switch port at the other end of one of our
+// interfaces, as announced by LLDP or CDP.
+type SwitchNeighbor struct {
+	Interface string `json:"interface"` // our interface the announcement arrived on
+	Protocol  string `json:"protocol"`  // ProtocolLLDP or ProtocolCDP
+	// System is the switch's system name (LLDP) or device ID (CDP), falling
+	// back to the LLDP chassis ID.
+	System          string        `json:"system"`
+	ChassisID       string        `json:"chassis_id,omitempty"` // LLDP only
+	Port            string        `json:"port"`                 // the switch's port ID
+	PortDescription string        `json:"port_description,omitempty"`
+	Platform        string        `json:"platform,omitempty"` // CDP platform, or the LLDP system description
+	VLAN            int           `json:"vlan,omitempty"`     // port or native VLAN, 0 if not announced
+	TTL             time.Duration `json:"ttl"`
+	LastSeen        time.Time     `json:"last_seen"`
+}
+
+// String renders the neighbor the way the TUI shows it, e.g.
+// "sw1 port Gi1/0/12, VLAN 20".
+func (n SwitchNeighbor) String() string {
+	s := n.System + " port " + n.Port
+	if n.VLAN != 0 {
+		s += fmt.Sprintf(", VLAN %d", n.VLAN)
+	}
+	return s
+}
+
+// parseSwitchNeighbor decodes an Ethernet frame carrying an LLDP or CDP
+// announcement. It reports false for anything else or a malformed frame.
+func parseSwitchNeighbor(frame []byte) (SwitchNeighbor, bool) {
+	if len(frame) < 14 {
+		return SwitchNeighbor{}, false
+	}
+	dst := net.HardwareAddr(frame[:6])
+	switch {
+	case binary.BigEndian.Uint16(frame[12:14]) == etherTypeLLDP:
+		return parseLLDP(frame[14:])
+	case dst.String() == cdpMAC.String():
+		return parseCDP(frame[14:])
+	}
+	return SwitchNeighbor{}, false
+}
+
+// parseLLDP decodes an LLDPDU (IEEE 802.1AB). The mandatory Chassis ID, Port
+// ID and TTL TLVs must be present.
+func parseLLDP(b []byte) (SwitchNeighbor, bool) {
+	n := SwitchNeighbor{Protocol: ProtocolLLDP}
+	var seen int
+	for len(b) >= 2 {
+		hdr := binary.BigEndian.Uint16(b)
+		typ, length := int(hdr>>9), int(hdr&0x1ff)
+		if len(b) < 2+length {
+			return SwitchNeighbor{}, false
+		}
+		v := b[2 : 2+length]
+		b = b[2+length:]
+
+		switch typ {
+		case 0: // End of LLDPDU
+			b = nil
+		case 1: // Chassis ID
+			if length < 2 {
+				return SwitchNeighbor{}, false
+			}
+			n.ChassisID = lldpID(v[0], 4, v[1:])
+			seen |= 1
+		case 2: // Port ID
+			if length < 2 {
+				return SwitchNeighbor{}, false
+			}
+			n.Port = lldpID(v[0], 3, v[1:])
+			seen |= 2
+		case 3: // Time To Live
+			if length < 2 {
+				return SwitchNeighbor{}, false
+			}
+			n.TTL = time.Duration(binary.BigEndian.Uint16(v)) * time.Second
+			seen |= 4
+		case 4:
+			n.PortDescription = printable(v)
+		case 5:
+			n.System = printable(v)
+		case 6:
+			n.Platform = printable(v)
+		case 127: // Organizationally specific: IEEE 802.1 Port VLAN ID
+			if length >= 6 && v[0] == 0x00 && v[1] == 0x80 && v[2] == 0xc2 && v[3] == 1 {
+				n.VLAN = int(binary.BigEndian.Uint16(v[4:6]))
+			}
+		}
+	}
+	if seen != 7 {
+		return SwitchNeighbor{}, false
+	}
+	if n.System == "" {
+		n.System = n.ChassisID
+	}
+	return n, true
+}
+
+// lldpID renders a Chassis ID or Port ID value. macSubtype is the subtype
+// meaning "MAC address", which differs between the two TLVs.
+func lldpID(subtype, macSubtype byte, v []byte) string {
+	if subtype == macSubtype && len(v) == 6 {
+		return net.HardwareAddr(v).String()
+	}
+	if subtype == 5 && len(v) > 1 { // network address, IANA family first
+		switch {
+		case v[0] == 1 && len(v) == 5, v[0] == 2 && len(v) == 17:
+			return net.IP(v[1:]).String()
+		}
+	}
+	return printable(v)
+}
+
+// parseCDP decodes a Cisco Discovery Protocol announcement behind its
+// 802.2 LLC/SNAP header.
+func parseCDP(b []byte) (SwitchNeighbor, bool) {
+	// DSAP/SSAP 0xaa, UI control, Cisco OUI, protocol ID 0x2000, then the
+	// CDP version, TTL and checksum.
+	if len(b) < 12 || b[0] != 0xaa || b[1] != 0xaa || b[2] != 0x03 ||
+		b[3] != 0 || b[4] != 0 || b[5] != 0x0c || binary.BigEndian.Uint16(b[6:8]) != 0x2000 {
+		return SwitchNeighbor{}, false
+	}
+	n := SwitchNeighbor{Protocol: ProtocolCDP, TTL: time.Duration(b[9]) * time.Second}
+	if n.TTL == 0 {
+		n.TTL = defaultNeighborTTL
+	}
+	b = b[12:]
+	for len(b) >= 4 {
+		typ, length := binary.BigEndian.Uint16(b), int(binary.BigEndian.Uint16(b[2:4]))
+		if length < 4 || len(b) < length {
+			return SwitchNeighbor{}, false
+		}
+		v := b[4:length]
+		b = b[length:]
+
+		switch typ {
+		case 0x0001:
+			n.System = printable(v)
+		case 0x0003:
+			n.Port = printable(v)
+		case 0x0006:
+			n.Platform = printable(v)
+		case 0x000a: // native VLAN
+			if len(v) >= 2 {
+				n.VLAN = int(binary.BigEndian.Uint16(v))
+			}
+		}
+	}
+	if n.System == "" || n.Port == "" {
+		return SwitchNeighbor{}, false
+	}
+	return n, true
+}
+
+// printable returns v as a string with control characters dropped, so a
+// switch can't inject terminal escapes into the TUI.
+func printable(v []byte) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, string(v)))
+}
+
+// RecordSwitchNeighbor stores the switch port announced on n.Interface,
+// replacing the previous announcement there. A TTL of zero is a switch
+// withdrawing its announcement.
+func (s *NDPStats) RecordSwitchNeighbor(n SwitchNeighbor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n.TTL == 0 {
+		delete(s.switchNeighbors, n.Interface)
+		return
+	}
+	s.switchNeighbors[n.Interface] = n
+}
+
+// GetSwitchNeighbors returns the current switch port per interface, ordered
+// by interface name.
+func (s *NDPStats) GetSwitchNeighbors() []SwitchNeighbor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.switchNeighborsLocked()
+}
+
+// switchNeighborsLocked copies the switch neighbors. Callers must hold s.mu.
+func (s *NDPStats) switchNeighborsLocked() []SwitchNeighbor {
+	result := make([]SwitchNeighbor, 0, len(s.switchNeighbors))
+	for _, n := range s.switchNeighbors {
+		result = append(result, n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Interface < result[j].Interface })
+	return result
+}
+
+// pruneSwitchNeighborsLocked drops announcements whose TTL has run out.
+// Callers must hold s.mu.
+func (s *NDPStats) pruneSwitchNeighborsLocked(now time.Time) {
+	for iface, n := range s.switchNeighbors {
+		if now.Sub(n.LastSeen) > n.TTL {
+			delete(s.switchNeighbors, iface)
+		}
+	}
+}
+
+// LLDPListenerConfig configures an LLDPListener.
+type LLDPListenerConfig struct {
+	// Interfaces to listen on; the member ports of a bridge or bond among
+	// them are listened on too.
+	Interfaces []string
+	Logger     *slog.Logger // required
+	Stats      *NDPStats    // required; receives the switch neighbors
+}
+
+// LLDPListener captures LLDP and CDP announcements from the switches our
+// interfaces are plugged into, so the TUI can show which switch port (and
+// VLAN) each one is on. It is passive: nothing is announced.
+type LLDPListener struct {
+	cfg LLDPListenerConfig
+}
+
+func NewLLDPListener(cfg LLDPListenerConfig) *LLDPListener {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &LLDPListener{cfg: cfg}
+}
+
+// record stores an announcement that arrived on iface.
+func (l *LLDPListener) record(frame []byte, iface string, now time.Time) {
+	n, ok := parseSwitchNeighbor(frame)
+	if !ok {
+		l.cfg.Logger.Debug("malformed discovery frame", "iface", iface, "len", len(frame))
+		return
+	}
+	n.Interface, n.LastSeen = iface, now
+	l.cfg.Logger.Debug("switch neighbor", "iface", iface, "protocol", n.Protocol, "system", n.System, "port", n.Port, "vlan", n.VLAN)
+	l.cfg.Stats.RecordSwitchNeighbor(n)
+}
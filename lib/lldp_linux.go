@@ -0,0 +1,124 @@
+//go:build linux
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// discoveryFilter passes LLDP frames and frames to the CDP group address, so
+// the ETH_P_ALL socket doesn't copy every frame on the link to user space.
+var discoveryFilter = []bpf.Instruction{
+	bpf.LoadAbsolute{Off: 12, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: etherTypeLLDP, SkipTrue: 4},
+	bpf.LoadAbsolute{Off: 0, Size: 4},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x01000ccc, SkipFalse: 3},
+	bpf.LoadAbsolute{Off: 4, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0xcccc, SkipFalse: 1},
+	bpf.RetConstant{Val: 0xffff},
+	bpf.RetConstant{Val: 0},
+}
+
+// Run captures LLDP and CDP announcements until ctx is cancelled. Requires
+// root/CAP_NET_RAW.
+func (l *LLDPListener) Run(ctx context.Context) error {
+	ifaces, err := l.interfaces()
+	if err != nil {
+		return err
+	}
+
+	// CDP frames are 802.3 with an LLC header rather than an EtherType, so
+	// take everything and let the filter pick.
+	proto := htons(syscall.ETH_P_ALL)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(proto))
+	if err != nil {
+		return fmt.Errorf("open lldp socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	raw, err := bpf.Assemble(discoveryFilter)
+	if err != nil {
+		return fmt.Errorf("assemble lldp filter: %w", err)
+	}
+	filter := make([]unix.SockFilter, len(raw))
+	for i, ins := range raw {
+		filter[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &prog); err != nil {
+		return fmt.Errorf("attach lldp filter: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: proto}); err != nil {
+		return fmt.Errorf("bind lldp socket: %w", err)
+	}
+	// NICs drop multicast they haven't been asked for.
+	for index := range ifaces {
+		for _, mac := range []net.HardwareAddr{lldpMAC, cdpMAC} {
+			mreq := unix.PacketMreq{Ifindex: int32(index), Type: unix.PACKET_MR_MULTICAST, Alen: 6}
+			copy(mreq.Address[:], mac)
+			if err := unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, &mreq); err != nil {
+				return fmt.Errorf("join %s on %s: %w", mac, ifaces[index], err)
+			}
+		}
+	}
+	tv := syscall.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return fmt.Errorf("set lldp socket timeout: %w", err)
+	}
+
+	buf := make([]byte, 9216)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		n, from, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("read: %w", err)
+		}
+		ll, ok := from.(*syscall.SockaddrLinklayer)
+		if !ok || ll.Pkttype == packetOutgoing {
+			continue
+		}
+		if iface, ok := ifaces[ll.Ifindex]; ok {
+			l.record(buf[:n], iface, time.Now())
+		}
+	}
+}
+
+// interfaces resolves the configured interfaces, plus the member ports of
+// any bridge or bond among them, by ifindex.
+func (l *LLDPListener) interfaces() (map[int]string, error) {
+	ifaces := make(map[int]string)
+	for _, name := range l.cfg.Interfaces {
+		ifi, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("lldp: %w", err)
+		}
+		ifaces[ifi.Index] = ifi.Name
+		ports := &memberPorts{master: name}
+		if ports.refresh(time.Now()) == nil {
+			for index, port := range ports.ports {
+				ifaces[index] = port
+			}
+		}
+	}
+	if len(ifaces) == 0 {
+		return nil, errors.New("lldp: no interfaces")
+	}
+	return ifaces, nil
+}
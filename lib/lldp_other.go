@@ -0,0 +1,13 @@
+//go:build !linux
+
+package lib
+
+import (
+	"context"
+	"errors"
+)
+
+// Run is only implemented on Linux (AF_PACKET).
+func (l *LLDPListener) Run(ctx context.Context) error {
+	return errors.New("LLDP/CDP capture is only supported on linux")
+}
@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lldpTLV encodes one LLDP TLV.
+func lldpTLV(typ int, value []byte) []byte {
+	tlv := binary.BigEndian.AppendUint16(nil, uint16(typ<<9|len(value)))
+	return append(tlv, value...)
+}
+
+// buildLLDPFrame builds an LLDP frame from sw1 port Gi1/0/12 in VLAN 20
+// with the given TTL in seconds.
+func buildLLDPFrame(ttl uint16) []byte {
+	frame := append([]byte(nil), lldpMAC...)
+	frame = append(frame, 0x02, 0, 0, 0, 0, 0x01, 0x88, 0xcc)
+	frame = append(frame, lldpTLV(1, []byte{4, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55})...)
+	frame = append(frame, lldpTLV(2, append([]byte{5}, "Gi1/0/12"...))...)
+	frame = append(frame, lldpTLV(3, []byte{byte(ttl >> 8), byte(ttl)})...)
+	frame = append(frame, lldpTLV(4, []byte("uplink\x1b[31m"))...)
+	frame = append(frame, lldpTLV(5, []byte("sw1"))...)
+	frame = append(frame, lldpTLV(127, []byte{0x00, 0x80, 0xc2, 1, 0, 20})...)
+	return append(frame, lldpTLV(0, nil)...)
+}
+
+// buildCDPFrame builds a CDP frame from sw2 port GigabitEthernet0/3.
+func buildCDPFrame() []byte {
+	var tlvs []byte
+	tlv := func(typ uint16, value []byte) {
+		tlvs = binary.BigEndian.AppendUint16(tlvs, typ)
+		tlvs = binary.BigEndian.AppendUint16(tlvs, uint16(4+len(value)))
+		tlvs = append(tlvs, value...)
+	}
+	tlv(0x0001, []byte("sw2"))
+	tlv(0x0003, []byte("GigabitEthernet0/3"))
+	tlv(0x0006, []byte("cisco WS-C2960"))
+	tlv(0x000a, []byte{0, 30})
+
+	body := append([]byte{0xaa, 0xaa, 0x03, 0, 0, 0x0c, 0x20, 0x00, 2, 180, 0, 0}, tlvs...)
+	frame := append([]byte(nil), cdpMAC...)
+	frame = append(frame, 0x02, 0, 0, 0, 0, 0x02)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(body)))
+	return append(frame, body...)
+}
+
+func TestParseSwitchNeighbor_LLDP(t *testing.T) {
+	n, ok := parseSwitchNeighbor(buildLLDPFrame(120))
+	if !ok {
+		t.Fatal("LLDP frame not parsed")
+	}
+	want := SwitchNeighbor{Protocol: ProtocolLLDP, System: "sw1", ChassisID: "00:11:22:33:44:55",
+		Port: "Gi1/0/12", PortDescription: "uplink[31m", VLAN: 20, TTL: 120 * time.Second}
+	if n != want {
+		t.Errorf("got %+v\nwant %+v", n, want)
+	}
+	if got := n.String(); got != "sw1 port Gi1/0/12, VLAN 20" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParseSwitchNeighbor_CDP(t *testing.T) {
+	n, ok := parseSwitchNeighbor(buildCDPFrame())
+	if !ok {
+		t.Fatal("CDP frame not parsed")
+	}
+	want := SwitchNeighbor{Protocol: ProtocolCDP, System: "sw2", Port: "GigabitEthernet0/3",
+		Platform: "cisco WS-C2960", VLAN: 30, TTL: 180 * time.Second}
+	if n != want {
+		t.Errorf("got %+v\nwant %+v", n, want)
+	}
+}
+
+func TestParseSwitchNeighbor_Malformed(t *testing.T) {
+	lldp := buildLLDPFrame(120)
+	cdp := buildCDPFrame()
+	for name, frame := range map[string][]byte{
+		"short":           lldp[:10],
+		"truncated tlv":   lldp[:20],
+		"no ttl":          append(append([]byte(nil), lldp[:14+9+11]...), lldpTLV(0, nil)...),
+		"cdp bad snap":    append(append(append([]byte(nil), cdp[:14]...), 0xaa, 0xaa, 0x03, 0, 0, 0x0d), cdp[20:]...),
+		"cdp bad length":  append(append([]byte(nil), cdp[:26]...), 0, 1, 0, 2),
+		"other ethertype": append(append([]byte(nil), lldp[:12]...), 0x86, 0xdd),
+	} {
+		if n, ok := parseSwitchNeighbor(frame); ok {
+			t.Errorf("%s: parsed as %+v", name, n)
+		}
+	}
+}
+
+func TestSwitchNeighborExpiry(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewLLDPListener(LLDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	now := time.Now()
+	l.record(buildLLDPFrame(120), "eth0", now)
+	l.record(buildCDPFrame(), "eth1", now.Add(-time.Hour)) // TTL long gone
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if want := `ndpeekr_switch_neighbor_info{interface="eth0",protocol="lldp",system="sw1",port="Gi1/0/12",vlan="20"} 1`; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("metrics missing %q", want)
+	}
+
+	stats.Prune()
+	if got := stats.GetSwitchNeighbors(); len(got) != 1 || got[0].Interface != "eth0" {
+		t.Fatalf("neighbors after prune = %+v, want only eth0", got)
+	}
+
+	// A shutdown LLDPDU (TTL 0) withdraws the announcement.
+	l.record(buildLLDPFrame(0), "eth0", now)
+	if got := stats.GetSwitchNeighbors(); len(got) != 0 {
+		t.Errorf("neighbors after withdrawal = %+v", got)
+	}
+}
+
+func TestModelUpstreamPrefersMemberPort(t *testing.T) {
+	m := Model{switchNeighbors: map[string]SwitchNeighbor{
+		"br0":  {Interface: "br0", System: "sw1", Port: "Gi1/0/1"},
+		"eth1": {Interface: "eth1", System: "sw1", Port: "Gi1/0/7"},
+	}}
+	if n, _ := m.upstream("br0", "eth1"); n.Port != "Gi1/0/7" {
+		t.Errorf("member port: got %+v", n)
+	}
+	if n, _ := m.upstream("br0", "eth2"); n.Port != "Gi1/0/1" {
+		t.Errorf("unknown member port: got %+v", n)
+	}
+	if _, ok := m.upstream("", ""); ok {
+		t.Error("no interface matched a neighbor")
+	}
+}
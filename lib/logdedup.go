@@ -0,0 +1,205 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRateLimitedPeers bounds the per-peer rate limiter table; idle entries
+// are dropped once it is exceeded.
+const maxRateLimitedPeers = 4096
+
+// DedupHandlerConfig configures NewDedupHandler.
+type DedupHandlerConfig struct {
+	// PeerRate is the sustained number of records per second allowed for each
+	// "src" attribute value; 0 disables per-peer rate limiting.
+	PeerRate float64
+	// PeerBurst is how many records a peer may log at once (default 4×PeerRate, at least 1).
+	PeerBurst int
+	// RepeatFlush is how often a run of identical records is summarised while
+	// it is still going (default 30s).
+	RepeatFlush time.Duration
+}
+
+// DedupHandler wraps a slog.Handler so log storms stay small:
+//
+//   - consecutive identical records (same level, message and attributes,
+//     ignoring the timestamp) are coalesced into one
+//     "last message repeated N times" record;
+//   - records carrying a "src" attribute are rate limited per source, with a
+//     "log lines suppressed" record once the source is allowed again.
+//
+// It only affects logging; stats and sinks still see every message.
+type DedupHandler struct {
+	next  slog.Handler
+	attrs string // preformatted WithAttrs/WithGroup state, part of the identity
+	state *dedupState
+}
+
+// dedupState is shared by a handler and everything derived from it with WithAttrs/WithGroup.
+type dedupState struct {
+	mu  sync.Mutex
+	cfg DedupHandlerConfig
+
+	// last logged record and how many identical copies were swallowed since
+	lastKey     string
+	lastRecord  slog.Record
+	lastHandler slog.Handler
+	repeats     int
+	repeatSince time.Time
+
+	peers map[string]*logBucket
+}
+
+type logBucket struct {
+	tokens  float64
+	last    time.Time
+	dropped int
+}
+
+// NewDedupHandler wraps next with repeat coalescing and per-peer rate limiting.
+func NewDedupHandler(next slog.Handler, cfg DedupHandlerConfig) *DedupHandler {
+	if cfg.PeerBurst <= 0 {
+		cfg.PeerBurst = max(int(4*cfg.PeerRate), 1)
+	}
+	if cfg.RepeatFlush <= 0 {
+		cfg.RepeatFlush = 30 * time.Second
+	}
+	return &DedupHandler{
+		next:  next,
+		state: &dedupState{cfg: cfg, peers: make(map[string]*logBucket)},
+	}
+}
+
+func (h *DedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *DedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.attrs)
+	for _, a := range attrs {
+		b.WriteString(" " + a.String())
+	}
+	return &DedupHandler{next: h.next.WithAttrs(attrs), attrs: b.String(), state: h.state}
+}
+
+func (h *DedupHandler) WithGroup(name string) slog.Handler {
+	return &DedupHandler{next: h.next.WithGroup(name), attrs: h.attrs + " [" + name + "]", state: h.state}
+}
+
+func (h *DedupHandler) Handle(ctx context.Context, r slog.Record) error {
+	s := h.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Per-peer rate limit
+	if src := recordAttr(r, "src"); src != "" && s.cfg.PeerRate > 0 {
+		b := s.bucket(src, r.Time)
+		if b.tokens < 1 {
+			b.dropped++
+			return nil
+		}
+		b.tokens--
+		if b.dropped > 0 {
+			sum := slog.NewRecord(r.Time, slog.LevelWarn, "log lines suppressed by rate limit", 0)
+			sum.AddAttrs(slog.String("src", src), slog.Int("suppressed", b.dropped))
+			b.dropped = 0
+			if err := h.next.Handle(ctx, sum); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Coalesce identical consecutive records
+	key := h.recordKey(r)
+	if key == s.lastKey {
+		s.repeats++
+		if r.Time.Sub(s.repeatSince) < s.cfg.RepeatFlush {
+			return nil
+		}
+		// A long-running storm: report what has been swallowed so far.
+		return s.flushRepeatsLocked(ctx, r.Time)
+	}
+
+	if err := s.flushRepeatsLocked(ctx, r.Time); err != nil {
+		return err
+	}
+	s.lastKey = key
+	s.lastRecord = r.Clone()
+	s.lastHandler = h.next
+	s.repeatSince = r.Time
+	return h.next.Handle(ctx, r)
+}
+
+// Flush writes a pending "repeated" summary. Call it before exiting.
+func (h *DedupHandler) Flush() error {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	return h.state.flushRepeatsLocked(context.Background(), time.Now())
+}
+
+func (s *dedupState) flushRepeatsLocked(ctx context.Context, now time.Time) error {
+	if s.repeats == 0 {
+		return nil
+	}
+	sum := slog.NewRecord(now, s.lastRecord.Level, fmt.Sprintf("last message repeated %d times", s.repeats), 0)
+	sum.AddAttrs(slog.String("repeated", s.lastRecord.Message))
+	if src := recordAttr(s.lastRecord, "src"); src != "" {
+		sum.AddAttrs(slog.String("src", src))
+	}
+	s.repeats = 0
+	s.repeatSince = now
+	return s.lastHandler.Handle(ctx, sum)
+}
+
+// bucket returns src's token bucket, refilled up to now.
+func (s *dedupState) bucket(src string, now time.Time) *logBucket {
+	b, ok := s.peers[src]
+	if !ok {
+		if len(s.peers) >= maxRateLimitedPeers {
+			for k, old := range s.peers {
+				if now.Sub(old.last) > time.Minute && old.dropped == 0 {
+					delete(s.peers, k)
+				}
+			}
+		}
+		b = &logBucket{tokens: float64(s.cfg.PeerBurst), last: now}
+		s.peers[src] = b
+		return b
+	}
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*s.cfg.PeerRate, float64(s.cfg.PeerBurst))
+	b.last = now
+	return b
+}
+
+// recordKey identifies a record for repeat detection: level, message and
+// attributes, but not the time.
+func (h *DedupHandler) recordKey(r slog.Record) string {
+	var b strings.Builder
+	b.WriteString(r.Level.String())
+	b.WriteString(" " + r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		b.WriteString(" " + a.String())
+		return true
+	})
+	return b.String()
+}
+
+// recordAttr returns the string value of the top-level attribute key, or "".
+func recordAttr(r slog.Record, key string) string {
+	var v string
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == key {
+			v = a.Value.String()
+			return false
+		}
+		return true
+	})
+	return v
+}
@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func newTestDedupLogger(cfg DedupHandlerConfig) (*slog.Logger, *DedupHandler, *bytes.Buffer) {
+	var buf bytes.Buffer
+	h := NewDedupHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), cfg)
+	return slog.New(h).With("component", "test"), h, &buf
+}
+
+func TestDedupHandler_CoalescesRepeats(t *testing.T) {
+	logger, h, buf := newTestDedupLogger(DedupHandlerConfig{})
+
+	for range 5 {
+		logger.Info("ndp event", "ndp", "router_solicitation")
+	}
+	logger.Info("ndp event", "ndp", "neighbor_solicitation")
+	logger.Info("ndp event", "ndp", "neighbor_solicitation")
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if n := strings.Count(out, "ndp=router_solicitation"); n != 1 {
+		t.Errorf("router_solicitation logged %d times, want 1:\n%s", n, out)
+	}
+	if !strings.Contains(out, "last message repeated 4 times") {
+		t.Errorf("missing repeat summary for 4 repeats:\n%s", out)
+	}
+	if !strings.Contains(out, "last message repeated 1 times") {
+		t.Errorf("Flush did not write the pending repeat summary:\n%s", out)
+	}
+	if !strings.Contains(out, "component=test") {
+		t.Errorf("summary lost handler attributes:\n%s", out)
+	}
+}
+
+func TestDedupHandler_PeerRateLimit(t *testing.T) {
+	logger, _, buf := newTestDedupLogger(DedupHandlerConfig{PeerRate: 0.001, PeerBurst: 3})
+
+	for i := range 10 {
+		logger.Info("ndp event", "src", "fe80::1", "seq", i)
+	}
+	logger.Info("ndp event", "src", "fe80::2", "seq", 0)
+
+	out := buf.String()
+	if n := strings.Count(out, "src=fe80::1 seq="); n != 3 {
+		t.Errorf("fe80::1 logged %d lines, want burst of 3:\n%s", n, out)
+	}
+	if !strings.Contains(out, "src=fe80::2 seq=0") {
+		t.Errorf("other peers must not be limited:\n%s", out)
+	}
+}
+
+func TestDedupHandler_ReportsSuppressed(t *testing.T) {
+	logger, h, buf := newTestDedupLogger(DedupHandlerConfig{PeerRate: 1, PeerBurst: 1})
+
+	logger.Info("ndp event", "src", "fe80::1", "seq", 0)
+	logger.Info("ndp event", "src", "fe80::1", "seq", 1) // dropped
+
+	// Refill the bucket as if time had passed.
+	h.state.peers["fe80::1"].tokens = 1
+	logger.Info("ndp event", "src", "fe80::1", "seq", 2)
+
+	out := buf.String()
+	if !strings.Contains(out, "suppressed=1") {
+		t.Errorf("missing suppression summary:\n%s", out)
+	}
+}
@@ -0,0 +1,298 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMACLocationTimeout = 5 * time.Second
+	defaultMACLocationTTL     = 5 * time.Minute
+	// qBridgeWalkLimit bounds the Q-BRIDGE-MIB forwarding table walk.
+	qBridgeWalkLimit = 20000
+)
+
+// BRIDGE-MIB (RFC 4188), Q-BRIDGE-MIB (RFC 4363) and IF-MIB columns.
+var (
+	oidDot1dTpFdbPort       = []uint32{1, 3, 6, 1, 2, 1, 17, 4, 3, 1, 2}       // .<mac>
+	oidDot1qTpFdbPort       = []uint32{1, 3, 6, 1, 2, 1, 17, 7, 1, 2, 2, 1, 2} // .<fdb id>.<mac>
+	oidDot1dBasePortIfIndex = []uint32{1, 3, 6, 1, 2, 1, 17, 1, 4, 1, 2}       // .<bridge port>
+	oidIfName               = []uint32{1, 3, 6, 1, 2, 1, 31, 1, 1, 1, 1}       // .<ifIndex>
+)
+
+// MACLocation is a switch port a MAC address was learned on.
+type MACLocation struct {
+	Switch string `json:"switch"`
+	Port   string `json:"port"`
+	VLAN   int    `json:"vlan,omitempty"` // 0 if unknown
+}
+
+func (l MACLocation) String() string {
+	s := l.Switch + " " + l.Port
+	if l.VLAN != 0 {
+		s += fmt.Sprintf(" (vlan %d)", l.VLAN)
+	}
+	return s
+}
+
+// MACLocatorConfig configures a MACLocator.
+type MACLocatorConfig struct {
+	Config MACLocationConfig
+	Logger *slog.Logger
+	// HTTPClient is used for RESTCONF (default: one with the timeout).
+	HTTPClient *http.Client
+}
+
+// MACLocator resolves which switch port a MAC lives on by asking the
+// configured switches' forwarding tables, so an incident responder sees the
+// port next to the peer instead of logging into each switch. Answers,
+// including "not found", are cached for the TTL.
+type MACLocator struct {
+	cfg MACLocatorConfig
+
+	mu    sync.Mutex
+	cache map[string]macLocationEntry // key: lower-case MAC
+}
+
+type macLocationEntry struct {
+	locations []MACLocation
+	err       error
+	at        time.Time
+}
+
+// NewMACLocator returns a locator for cfg.Config.Switches.
+func NewMACLocator(cfg MACLocatorConfig) *MACLocator {
+	if cfg.Config.Timeout <= 0 {
+		cfg.Config.Timeout = defaultMACLocationTimeout
+	}
+	if cfg.Config.TTL <= 0 {
+		cfg.Config.TTL = defaultMACLocationTTL
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: cfg.Config.Timeout}
+	}
+	return &MACLocator{cfg: cfg, cache: make(map[string]macLocationEntry)}
+}
+
+// Locate asks every switch where mac is, in parallel, and returns the ports
+// it was found on, ordered by switch and port. Switches that fail are
+// reported in the error alongside whatever the others found.
+func (l *MACLocator) Locate(ctx context.Context, mac string) ([]MACLocation, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 {
+		return nil, fmt.Errorf("locate %q: not a MAC address", mac)
+	}
+	key := hw.String()
+
+	l.mu.Lock()
+	e, ok := l.cache[key]
+	l.mu.Unlock()
+	if ok && time.Since(e.at) < l.cfg.Config.TTL {
+		return e.locations, e.err
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		locations []MACLocation
+		errs      []error
+	)
+	for _, sw := range l.cfg.Config.Switches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, 3*l.cfg.Config.Timeout)
+			defer cancel()
+			var found []MACLocation
+			var err error
+			if sw.SNMP != "" {
+				found, err = l.locateSNMP(ctx, sw, hw)
+			} else {
+				found, err = l.locateRESTCONF(ctx, sw, hw)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				l.cfg.Logger.Debug("mac location failed", "switch", sw.Name, "mac", key, "err", err)
+				errs = append(errs, fmt.Errorf("%s: %w", sw.Name, err))
+			}
+			locations = append(locations, found...)
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(locations, func(i, j int) bool {
+		if locations[i].Switch != locations[j].Switch {
+			return locations[i].Switch < locations[j].Switch
+		}
+		return locations[i].Port < locations[j].Port
+	})
+	err = errors.Join(errs...)
+	if ctx.Err() == nil {
+		l.mu.Lock()
+		l.cache[key] = macLocationEntry{locations: locations, err: err, at: time.Now()}
+		l.mu.Unlock()
+	}
+	return locations, err
+}
+
+// locateSNMP looks mac up in the BRIDGE-MIB forwarding table, falling back
+// to a walk of the per-VLAN Q-BRIDGE-MIB table, and names the bridge ports.
+func (l *MACLocator) locateSNMP(ctx context.Context, sw SwitchConfig, hw net.HardwareAddr) ([]MACLocation, error) {
+	address := sw.SNMP
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "161")
+	}
+	community := sw.Community
+	if community == "" {
+		community = "public"
+	}
+	c := &snmpClient{address: address, community: community, timeout: l.cfg.Config.Timeout}
+
+	macArcs := make([]uint32, len(hw))
+	for i, b := range hw {
+		macArcs[i] = uint32(b)
+	}
+
+	type fdbEntry struct {
+		port int64
+		vlan int
+	}
+	var entries []fdbEntry
+	vb, err := c.get(ctx, append(append([]uint32{}, oidDot1dTpFdbPort...), macArcs...))
+	if err != nil {
+		return nil, err
+	}
+	if port, ok := vb.int(); ok && port > 0 {
+		entries = append(entries, fdbEntry{port: port})
+	} else {
+		vbs, err := c.walk(ctx, oidDot1qTpFdbPort, 50, qBridgeWalkLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, vb := range vbs {
+			suffix := vb.oid[len(oidDot1qTpFdbPort):]
+			if len(suffix) != 7 || compareOID(suffix[1:], macArcs) != 0 {
+				continue
+			}
+			if port, ok := vb.int(); ok && port > 0 {
+				entries = append(entries, fdbEntry{port: port, vlan: int(suffix[0])})
+			}
+		}
+	}
+
+	var locations []MACLocation
+	for _, e := range entries {
+		locations = append(locations, MACLocation{
+			Switch: sw.Name,
+			Port:   snmpPortName(ctx, c, e.port),
+			VLAN:   e.vlan,
+		})
+	}
+	return locations, nil
+}
+
+// snmpPortName maps a bridge port to its ifName, falling back to the port
+// number when the agent can't.
+func snmpPortName(ctx context.Context, c *snmpClient, port int64) string {
+	fallback := fmt.Sprintf("port %d", port)
+	vb, err := c.get(ctx, append(append([]uint32{}, oidDot1dBasePortIfIndex...), uint32(port)))
+	if err != nil {
+		return fallback
+	}
+	ifIndex, ok := vb.int()
+	if !ok {
+		return fallback
+	}
+	vb, err = c.get(ctx, append(append([]uint32{}, oidIfName...), uint32(ifIndex)))
+	if err != nil || vb.typ != berOctetString || len(vb.value) == 0 {
+		return fmt.Sprintf("ifIndex %d", ifIndex)
+	}
+	return string(vb.value)
+}
+
+// openconfigMACTable is the OpenConfig fdb/mac-table/entries container.
+type openconfigMACTable struct {
+	Entry []struct {
+		MACAddress string `json:"mac-address"`
+		VLAN       int    `json:"vlan"`
+		Interface  struct {
+			InterfaceRef struct {
+				State struct {
+					Interface    string `json:"interface"`
+					Subinterface *int   `json:"subinterface"`
+				} `json:"state"`
+			} `json:"interface-ref"`
+		} `json:"interface"`
+	} `json:"entry"`
+}
+
+// locateRESTCONF reads the OpenConfig MAC table of the switch's network
+// instance over RESTCONF (RFC 8040) and picks out hw.
+func (l *MACLocator) locateRESTCONF(ctx context.Context, sw SwitchConfig, hw net.HardwareAddr) ([]MACLocation, error) {
+	ni := sw.NetworkInstance
+	if ni == "" {
+		ni = "default"
+	}
+	u := strings.TrimSuffix(sw.RESTCONF, "/") +
+		"/data/openconfig-network-instance:network-instances/network-instance=" + url.PathEscape(ni) +
+		"/fdb/mac-table/entries"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/yang-data+json")
+	if sw.Username != "" {
+		req.SetBasicAuth(sw.Username, sw.Password)
+	}
+	resp, err := l.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("restconf %s: %s", u, resp.Status)
+	}
+
+	// The container is keyed by its module-qualified name.
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("restconf %s: %w", u, err)
+	}
+	var locations []MACLocation
+	for _, raw := range body {
+		var table openconfigMACTable
+		if err := json.Unmarshal(raw, &table); err != nil {
+			return nil, fmt.Errorf("restconf %s: %w", u, err)
+		}
+		for _, e := range table.Entry {
+			mac, err := net.ParseMAC(e.MACAddress)
+			if err != nil || mac.String() != hw.String() {
+				continue
+			}
+			port := e.Interface.InterfaceRef.State.Interface
+			if sub := e.Interface.InterfaceRef.State.Subinterface; sub != nil && *sub != 0 {
+				port += fmt.Sprintf(".%d", *sub)
+			}
+			if port == "" {
+				port = "?"
+			}
+			locations = append(locations, MACLocation{Switch: sw.Name, Port: port, VLAN: e.VLAN})
+		}
+	}
+	return locations, nil
+}
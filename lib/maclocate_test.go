@@ -0,0 +1,192 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSNMPAgent answers SNMPv2c Get and GetBulk requests from a fixed MIB.
+type fakeSNMPAgent struct {
+	conn     net.PacketConn
+	mib      []snmpVarBind // sorted by OID
+	requests atomic.Int32
+}
+
+func newFakeSNMPAgent(t *testing.T, mib map[string]snmpVarBind) *fakeSNMPAgent {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &fakeSNMPAgent{conn: conn}
+	for oid, vb := range mib {
+		vb.oid, _ = parseOID(oid)
+		a.mib = append(a.mib, vb)
+	}
+	sort.Slice(a.mib, func(i, j int) bool { return compareOID(a.mib[i].oid, a.mib[j].oid) < 0 })
+	t.Cleanup(func() { conn.Close() })
+	go a.serve()
+	return a
+}
+
+func (a *fakeSNMPAgent) serve() {
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		a.requests.Add(1)
+		_, _ = a.conn.WriteTo(a.answer(buf[:n]), from)
+	}
+}
+
+func (a *fakeSNMPAgent) answer(req []byte) []byte {
+	_, msg, _, _ := berRead(req)
+	_, _, msg, _ = berRead(msg) // version
+	_, _, msg, _ = berRead(msg) // community
+	pduType, pdu, _, _ := berRead(msg)
+	var ints [3]int64
+	for i := range ints {
+		var content []byte
+		_, content, pdu, _ = berRead(pdu)
+		ints[i] = berParseInt(content)
+	}
+	_, list, _, _ := berRead(pdu)
+	_, vb, _, _ := berRead(list)
+	_, name, _, _ := berRead(vb)
+	oid, _ := berParseOID(name)
+
+	var out []snmpVarBind
+	switch pduType {
+	case snmpGet:
+		out = append(out, snmpVarBind{oid: oid, typ: snmpNoSuchInstance})
+		for _, e := range a.mib {
+			if compareOID(e.oid, oid) == 0 {
+				out[0] = e
+			}
+		}
+	case snmpGetBulk:
+		for _, e := range a.mib {
+			if compareOID(e.oid, oid) > 0 && len(out) < int(ints[2]) {
+				out = append(out, e)
+			}
+		}
+		if len(out) < int(ints[2]) {
+			out = append(out, snmpVarBind{oid: oid, typ: snmpEndOfMibView})
+		}
+	}
+
+	var vbs []byte
+	for _, vb := range out {
+		vbs = append(vbs, berTLV(berSequence, append(berTLV(berOID, berOIDContent(vb.oid)), berTLV(vb.typ, vb.value)...))...)
+	}
+	resp := berTLV(berInteger, berInt(ints[0]))
+	resp = append(resp, berTLV(berInteger, berInt(0))...)
+	resp = append(resp, berTLV(berInteger, berInt(0))...)
+	resp = append(resp, berTLV(berSequence, vbs)...)
+	body := berTLV(berInteger, berInt(snmpVersion2c))
+	body = append(body, berTLV(berOctetString, []byte("public"))...)
+	body = append(body, berTLV(snmpResponse, resp)...)
+	return berTLV(berSequence, body)
+}
+
+func snmpInt(n int64) snmpVarBind { return snmpVarBind{typ: berInteger, value: berInt(n)} }
+
+func snmpString(s string) snmpVarBind { return snmpVarBind{typ: berOctetString, value: []byte(s)} }
+
+func testLocator(switches ...SwitchConfig) *MACLocator {
+	return NewMACLocator(MACLocatorConfig{
+		Config: MACLocationConfig{Switches: switches, Timeout: time.Second},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+}
+
+func TestMACLocator_BridgeMIB(t *testing.T) {
+	agent := newFakeSNMPAgent(t, map[string]snmpVarBind{
+		"1.3.6.1.2.1.17.4.3.1.2.2.0.0.0.0.1": snmpInt(3),
+		"1.3.6.1.2.1.17.1.4.1.2.3":           snmpInt(10103),
+		"1.3.6.1.2.1.31.1.1.1.1.10103":       snmpString("Gi1/0/3"),
+	})
+	l := testLocator(SwitchConfig{Name: "sw1", SNMP: agent.conn.LocalAddr().String()})
+
+	got, err := l.Locate(context.Background(), "02:00:00:00:00:01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].String() != "sw1 Gi1/0/3" {
+		t.Errorf("locations = %+v, want sw1 Gi1/0/3", got)
+	}
+
+	before := agent.requests.Load()
+	if _, err := l.Locate(context.Background(), "02-00-00-00-00-01"); err != nil {
+		t.Fatal(err)
+	}
+	if agent.requests.Load() != before {
+		t.Error("second lookup within the TTL queried the switch again")
+	}
+}
+
+func TestMACLocator_QBridgeMIB(t *testing.T) {
+	agent := newFakeSNMPAgent(t, map[string]snmpVarBind{
+		"1.3.6.1.2.1.17.7.1.2.2.1.2.10.2.0.0.0.0.9": snmpInt(4),
+		"1.3.6.1.2.1.17.7.1.2.2.1.2.20.2.0.0.0.0.1": snmpInt(5),
+		"1.3.6.1.2.1.17.7.1.2.2.1.2.30.2.0.0.0.0.1": snmpInt(6),
+		"1.3.6.1.2.1.17.1.4.1.2.6":                  snmpInt(6),
+		"1.3.6.1.2.1.31.1.1.1.1.6":                  snmpString("ge-0/0/6"),
+	})
+	l := testLocator(SwitchConfig{Name: "sw2", SNMP: agent.conn.LocalAddr().String()})
+
+	got, err := l.Locate(context.Background(), "02:00:00:00:00:01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"sw2 ge-0/0/6 (vlan 30)", "sw2 port 5 (vlan 20)"}
+	if len(got) != len(want) {
+		t.Fatalf("locations = %+v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("location %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMACLocator_RESTCONF(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/restconf/data/openconfig-network-instance:network-instances/network-instance=default/fdb/mac-table/entries" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"openconfig-network-instance:entries": {"entry": [
+			{"mac-address": "02:00:00:00:00:02", "vlan": 10, "interface": {"interface-ref": {"state": {"interface": "Ethernet1"}}}},
+			{"mac-address": "02:00:00:00:00:01", "vlan": 20, "interface": {"interface-ref": {"state": {"interface": "Ethernet7", "subinterface": 0}}}}
+		]}}`)
+	}))
+	defer srv.Close()
+
+	l := testLocator(
+		SwitchConfig{Name: "leaf1", RESTCONF: srv.URL + "/restconf/", Username: "admin", Password: "secret"},
+		SwitchConfig{Name: "leaf2", RESTCONF: srv.URL + "/restconf", Username: "admin", Password: "wrong"},
+	)
+	got, err := l.Locate(context.Background(), "02:00:00:00:00:01")
+	if len(got) != 1 || got[0].String() != "leaf1 Ethernet7 (vlan 20)" {
+		t.Errorf("locations = %+v, want leaf1 Ethernet7 (vlan 20)", got)
+	}
+	if err == nil {
+		t.Error("failing switch not reported")
+	}
+}
@@ -0,0 +1,240 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMaintenanceDuration bounds scheduled windows, which are checked minute
+// by minute back from the alert time.
+const maxMaintenanceDuration = 7 * 24 * time.Hour
+
+// maintenanceWindow is a compiled MaintenanceWindow.
+type maintenanceWindow struct {
+	MaintenanceWindow
+	schedule *cronSchedule  // nil for one-off windows
+	loc      *time.Location // schedule time zone
+	severity Severity       // downgraded alerts are raised at this severity
+}
+
+// compileMaintenanceWindow checks a window and parses its schedule.
+func compileMaintenanceWindow(w MaintenanceWindow) (*maintenanceWindow, error) {
+	if w.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	mw := &maintenanceWindow{MaintenanceWindow: w, loc: time.Local, severity: SeverityInfo}
+	switch {
+	case w.Schedule != "" && (!w.Start.IsZero() || !w.End.IsZero()):
+		return nil, fmt.Errorf("%s: use either schedule or start and end, not both", w.Name)
+	case w.Schedule != "":
+		s, err := parseCronSchedule(w.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: schedule: %w", w.Name, err)
+		}
+		if w.Duration < time.Minute || w.Duration > maxMaintenanceDuration {
+			return nil, fmt.Errorf("%s: duration must be between 1m and %s", w.Name, formatDuration(maxMaintenanceDuration))
+		}
+		mw.schedule = s
+		if w.Timezone != "" {
+			loc, err := time.LoadLocation(w.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("%s: timezone: %w", w.Name, err)
+			}
+			mw.loc = loc
+		}
+	case w.Start.IsZero() || w.End.IsZero():
+		return nil, fmt.Errorf("%s: either schedule and duration or start and end are required", w.Name)
+	case !w.End.After(w.Start):
+		return nil, fmt.Errorf("%s: end must be after start", w.Name)
+	}
+	switch w.Action {
+	case "", "suppress":
+		if w.Severity != nil {
+			return nil, fmt.Errorf("%s: severity only applies to action downgrade", w.Name)
+		}
+	case "downgrade":
+		if w.Severity != nil {
+			mw.severity = *w.Severity
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown action %q (want suppress or downgrade)", w.Name, w.Action)
+	}
+	return mw, nil
+}
+
+// active reports whether the window is open at t.
+func (w *maintenanceWindow) active(t time.Time) bool {
+	if w.schedule == nil {
+		return !t.Before(w.Start) && t.Before(w.End)
+	}
+	// Open if the schedule fired within the last Duration.
+	t = t.In(w.loc)
+	for m := t.Truncate(time.Minute); t.Sub(m) < w.Duration; m = m.Add(-time.Minute) {
+		if w.schedule.match(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether the window applies to alerts of category.
+func (w *maintenanceWindow) covers(category string) bool {
+	return len(w.Categories) == 0 || slices.Contains(w.Categories, category)
+}
+
+// SetMaintenance sets the maintenance windows consulted for every alert.
+func (s *NDPStats) SetMaintenance(windows []*maintenanceWindow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maintenance = windows
+}
+
+// applyMaintenance applies the first open maintenance window covering a. It
+// reports false if the alert is suppressed; a downgraded alert has its
+// severity lowered. Either way a.Maintenance names the window.
+func (s *NDPStats) applyMaintenance(a *Alert) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.maintenance {
+		if !w.covers(a.Category) || !w.active(a.Time) {
+			continue
+		}
+		a.Maintenance = w.Name
+		if w.Action == "downgrade" {
+			a.Severity = min(a.Severity, w.severity)
+			return true
+		}
+		s.maintenanceSuppressed[w.Name]++
+		return false
+	}
+	return true
+}
+
+// ActiveMaintenance returns the names of the maintenance windows open at now.
+func (s *NDPStats) ActiveMaintenance(now time.Time) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var names []string
+	for _, w := range s.maintenance {
+		if w.active(now) {
+			names = append(names, w.Name)
+		}
+	}
+	return names
+}
+
+// maintenanceSuppressedLocked copies the suppressed alert counts. Callers must hold s.mu.
+func (s *NDPStats) maintenanceSuppressedLocked() map[string]int {
+	if len(s.maintenanceSuppressed) == 0 {
+		return nil
+	}
+	out := make(map[string]int, len(s.maintenanceSuppressed))
+	for k, v := range s.maintenanceSuppressed {
+		out[k] = v
+	}
+	return out
+}
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit i set if value i matches
+	domAny, dowAny                bool
+}
+
+// parseCronSchedule parses a standard five-field cron expression. Each field
+// is *, or a comma-separated list of values and lo-hi ranges, each optionally
+// followed by /step. Day of week 7 is Sunday, as is 0.
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%q: want 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(fields))
+	}
+	var s cronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	return &s, nil
+}
+
+// parseCronField parses one cron field into a bit set of the values in [lo, hi].
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step %q", stepStr)
+			}
+			step = n
+		}
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("bad value %q", a)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("bad value %q", b)
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// match reports whether the schedule fires in the minute starting at t.
+// As in cron, when both day fields are restricted either may match.
+func (s *cronSchedule) match(t time.Time) bool {
+	if s.minute&(1<<t.Minute()) == 0 || s.hour&(1<<t.Hour()) == 0 || s.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
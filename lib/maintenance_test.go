@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestCronSchedule(t *testing.T) {
+	tests := []struct {
+		expr string
+		at   string
+		want bool
+	}{
+		{"0 2 * * 6", "2024-05-04T02:00:00Z", true}, // a Saturday
+		{"0 2 * * 6", "2024-05-05T02:00:00Z", false},
+		{"0 2 * * 7", "2024-05-05T02:00:00Z", true}, // 7 is Sunday
+		{"*/15 * * * *", "2024-05-05T13:45:00Z", true},
+		{"*/15 * * * *", "2024-05-05T13:46:00Z", false},
+		{"30 1-3 1,15 * *", "2024-05-15T03:30:00Z", true},
+		{"30 1-3 1,15 * *", "2024-05-14T03:30:00Z", false},
+		{"0 0 1 * 1", "2024-05-06T00:00:00Z", true}, // Monday, not the 1st
+		{"0 0 1 6 *", "2024-05-01T00:00:00Z", false},
+	}
+	for _, tt := range tests {
+		s, err := parseCronSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCronSchedule(%q): %v", tt.expr, err)
+		}
+		at, _ := time.Parse(time.RFC3339, tt.at)
+		if got := s.match(at); got != tt.want {
+			t.Errorf("%q at %s = %v, want %v", tt.expr, tt.at, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"0 2 * *", "60 * * * *", "* 5-2 * * *", "*/0 * * * *", "x * * * *"} {
+		if _, err := parseCronSchedule(bad); err == nil {
+			t.Errorf("parseCronSchedule(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	cfg, err := LoadConfig(writeTempConfig(t, `
+maintenance:
+  - name: router upgrades
+    schedule: "0 2 * * 6"
+    duration: 2h
+    timezone: UTC
+    categories: [router_unreachable]
+  - name: core swap
+    start: 2024-05-01T22:00:00Z
+    end: 2024-05-02T02:00:00Z
+    action: downgrade
+    severity: warning
+`))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	stats := NewNDPStats(5 * time.Minute)
+	stats.SetMaintenance(cfg.MaintenanceWindows())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	saturday := time.Date(2024, 5, 4, 3, 30, 0, 0, time.UTC)
+	if got := stats.ActiveMaintenance(saturday); len(got) != 1 || got[0] != "router upgrades" {
+		t.Errorf("ActiveMaintenance = %v, want [router upgrades]", got)
+	}
+	emitAlert(stats, logger, nil, Alert{Time: saturday, Severity: SeverityCritical, Category: "router_unreachable", Source: "fe80::1"})
+	emitAlert(stats, logger, nil, Alert{Time: saturday, Severity: SeverityCritical, Category: "rogue_router", Source: "fe80::2"})
+	emitAlert(stats, logger, nil, Alert{Time: saturday.Add(time.Hour), Severity: SeverityCritical, Category: "router_unreachable", Source: "fe80::1"})
+
+	swap := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	emitAlert(stats, logger, nil, Alert{Time: swap, Severity: SeverityCritical, Category: "rogue_router", Source: "fe80::3"})
+
+	alerts := stats.GetAlerts() // newest first
+	if len(alerts) != 3 {
+		t.Fatalf("got %d alerts, want 3: %+v", len(alerts), alerts)
+	}
+	if a := alerts[0]; a.Source != "fe80::3" || a.Severity != SeverityWarning || a.Maintenance != "core swap" {
+		t.Errorf("alert during core swap = %+v, want downgraded to warning", a)
+	}
+	if a := alerts[1]; a.Source != "fe80::1" || a.Severity != SeverityCritical || a.Maintenance != "" {
+		t.Errorf("alert after the window = %+v, want it raised as is", a)
+	}
+	if a := alerts[2]; a.Category != "rogue_router" || a.Maintenance != "" {
+		t.Errorf("uncovered category = %+v, want it raised as is", a)
+	}
+	if got := stats.Snapshot().MaintenanceSuppressed["router upgrades"]; got != 1 {
+		t.Errorf("suppressed = %d, want 1", got)
+	}
+}
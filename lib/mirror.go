@@ -0,0 +1,19 @@
+package lib
+
+import "net"
+
+// ViaMirror labels events observed on a mirror port (see
+// NDPListenerConfig.Mirror).
+const ViaMirror = "mirror"
+
+// handleFrame handles one Ethernet frame from a mirror port. Frames that
+// don't carry IPv6 are skipped.
+func (l *NDPListener) handleFrame(frame []byte, ifIndex int) {
+	pkt, ok := ipv6FromFrame(linkTypeEthernet, frame)
+	if !ok {
+		return
+	}
+	l.frameMAC = net.HardwareAddr(frame[6:12]).String()
+	l.handlePacket(pkt, ifIndex, "")
+	l.frameMAC = ""
+}
@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMirrorPcap(t *testing.T) {
+	// An RS without a link-layer address option: only the Ethernet header
+	// names the sender's MAC.
+	rs := buildIPv6Packet("fe80::1", "ff02::2", 255, nil, nhICMPv6, buildRS(nil))
+	arp := append(make([]byte, 12), 0x08, 0x06)
+	path := filepath.Join(t.TempDir(), "span.pcap")
+	if err := os.WriteFile(path, writePcap(linkTypeEthernet, time.Now(), ethernetFrame(rs), arp), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := NewNDPStats(5 * time.Minute)
+	rec := &recordingSink{}
+	l := NewNDPListener(NDPListenerConfig{
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:    stats,
+		Sinks:    []Sink{rec},
+		Capture:  CapturePcap,
+		PcapFile: path,
+		Mirror:   true,
+	})
+	if err := l.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	peers := stats.GetStats()
+	if len(peers) != 1 || peers[0].MAC != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("peers = %+v, want fe80::1 with the Ethernet source MAC", peers)
+	}
+	if len(rec.events) != 1 || rec.events[0].Via != ViaMirror || rec.events[0].MAC != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("events = %+v, want one labeled via mirror", rec.events)
+	}
+
+	// An option still wins over the Ethernet source.
+	l.handleFrame(ethernetFrame(buildIPv6Packet("fe80::2", "ff02::2", 255, nil, nhICMPv6, buildRS(net.HardwareAddr{2, 0, 0, 0, 0, 2}))), 0)
+	if ev := rec.events[len(rec.events)-1]; ev.MAC != "02:00:00:00:00:02" {
+		t.Errorf("MAC = %s, want the option's", ev.MAC)
+	}
+}
+
+func TestMirrorNeedsPacketCapture(t *testing.T) {
+	for name, cfg := range map[string]NDPListenerConfig{
+		"socket":       {Capture: CaptureSocket, Interface: "eth0", Mirror: true},
+		"no interface": {Capture: CapturePacket, Mirror: true},
+		"member ports": {Capture: CapturePacket, Interface: "br0", MemberPorts: true, Mirror: true},
+	} {
+		cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		if err := NewNDPListener(cfg).Run(context.Background()); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package lib
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"time"
+)
+
+// defaultMLDMaxResponseDelay is assumed when a query advertises no delay.
+const defaultMLDMaxResponseDelay = 10 * time.Second
+
+// A report only counts as the answer to a query if it arrives within
+// mldResponseSlack × the query's Maximum Response Delay; anything later is an
+// unsolicited report. Answers beyond the delay itself are counted as late.
+const mldResponseSlack = 2
+
+// mldQuery is the most recent query observed for a group (or all groups).
+type mldQuery struct {
+	at       time.Time
+	maxDelay time.Duration
+}
+
+// groupLatency accumulates response latencies for one multicast group since
+// startup. Per-member latencies live in PeerStats.MLDLatency.
+type groupLatency struct {
+	responses int
+	late      int
+	total     time.Duration
+	max       time.Duration
+}
+
+// MLDGroupLatency summarises how quickly members answered queries for a group.
+type MLDGroupLatency struct {
+	Group     string        `json:"group"`
+	Responses int           `json:"responses"` // reports matched to a query
+	Late      int           `json:"late"`      // answers after the Maximum Response Delay
+	Mean      time.Duration `json:"mean"`
+	Max       time.Duration `json:"max"`
+	// Slowest is the current member with the highest last latency.
+	Slowest        string        `json:"slowest,omitempty"`
+	SlowestLatency time.Duration `json:"slowest_latency,omitempty"`
+}
+
+// parseMLDQuery returns the multicast address of an MLDv1 or MLDv2 query
+// ("" for a general query) and its Maximum Response Delay.
+func parseMLDQuery(buf []byte) (group string, maxDelay time.Duration, ok bool) {
+	if len(buf) < 24 || buf[0] != 130 {
+		return "", 0, false
+	}
+	code := int(binary.BigEndian.Uint16(buf[4:6]))
+	// MLDv2 (RFC 3810 5.1.3) encodes large delays as a floating point value.
+	if len(buf) >= 28 && code >= 32768 {
+		mant := code & 0x0fff
+		exp := (code >> 12) & 0x7
+		code = (mant | 0x1000) << (exp + 3)
+	}
+	maxDelay = time.Duration(code) * time.Millisecond
+	if maxDelay <= 0 {
+		maxDelay = defaultMLDMaxResponseDelay
+	}
+
+	addr := net.IP(buf[8:24])
+	if !addr.IsUnspecified() {
+		group = addr.String()
+	}
+	return group, maxDelay, true
+}
+
+// RecordMLDQuery notes an observed query. group is "" for a general query.
+func (s *NDPStats) RecordMLDQuery(group string, maxDelay time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := mldQuery{at: now, maxDelay: maxDelay}
+	if group == "" {
+		s.mldGeneralQuery = q
+		return
+	}
+	s.mldGroupQueries[group] = q
+}
+
+// RecordMLDResponse matches a report from ip for group to the latest query
+// covering it and records the latency. Each member answers a query once;
+// repeats and unsolicited reports are ignored.
+func (s *NDPStats) RecordMLDResponse(ip, group string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := s.mldGeneralQuery
+	if gq, ok := s.mldGroupQueries[group]; ok && gq.at.After(q.at) {
+		q = gq
+	}
+	if q.at.IsZero() || now.Before(q.at) || now.Sub(q.at) > mldResponseSlack*q.maxDelay {
+		return
+	}
+	peer, ok := s.peers[ip]
+	if !ok {
+		return
+	}
+	key := ip + "|" + group
+	if !s.mldAnswered[key].Before(q.at) {
+		return
+	}
+	s.mldAnswered[key] = q.at
+
+	latency := now.Sub(q.at)
+	peer.MLDLatency[group] = latency
+
+	gl, ok := s.mldLatency[group]
+	if !ok {
+		gl = &groupLatency{}
+		s.mldLatency[group] = gl
+	}
+	gl.responses++
+	gl.total += latency
+	gl.max = max(gl.max, latency)
+	if latency > q.maxDelay {
+		gl.late++
+	}
+}
+
+// GetMLDLatencies returns per-group response latency, slowest maximum first.
+func (s *NDPStats) GetMLDLatencies() []MLDGroupLatency {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mldLatenciesLocked()
+}
+
+// mldLatenciesLocked builds the per-group latency summaries. Callers must hold s.mu.
+func (s *NDPStats) mldLatenciesLocked() []MLDGroupLatency {
+	result := make([]MLDGroupLatency, 0, len(s.mldLatency))
+	for group, gl := range s.mldLatency {
+		l := MLDGroupLatency{
+			Group:     group,
+			Responses: gl.responses,
+			Late:      gl.late,
+			Mean:      gl.total / time.Duration(gl.responses),
+			Max:       gl.max,
+		}
+		for addr, peer := range s.peers {
+			d, ok := peer.MLDLatency[group]
+			if ok && (d > l.SlowestLatency || (d == l.SlowestLatency && addr < l.Slowest)) {
+				l.Slowest, l.SlowestLatency = addr, d
+			}
+		}
+		result = append(result, l)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Max != result[j].Max {
+			return result[i].Max > result[j].Max
+		}
+		return result[i].Group < result[j].Group
+	})
+	return result
+}
+
+// pruneMLDLatencyLocked drops answered-query bookkeeping older than cutoff and
+// per-group latency for groups no current peer belongs to. Callers must hold s.mu.
+func (s *NDPStats) pruneMLDLatencyLocked(cutoff time.Time) {
+	for key, at := range s.mldAnswered {
+		if !at.After(cutoff) {
+			delete(s.mldAnswered, key)
+		}
+	}
+	for group, q := range s.mldGroupQueries {
+		if !q.at.After(cutoff) {
+			delete(s.mldGroupQueries, group)
+		}
+	}
+	for group := range s.mldLatency {
+		member := false
+		for _, peer := range s.peers {
+			if _, ok := peer.MLDLatency[group]; ok {
+				member = true
+				break
+			}
+		}
+		if !member {
+			delete(s.mldLatency, group)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// buildMLDQuery builds an MLDv1 (v2 == false) or MLDv2 query for group ("" for general).
+func buildMLDQuery(group string, code uint16, v2 bool) []byte {
+	buf := make([]byte, 24)
+	if v2 {
+		buf = make([]byte, 28)
+	}
+	buf[0] = 130
+	buf[4], buf[5] = byte(code>>8), byte(code)
+	if group != "" {
+		copy(buf[8:24], net.ParseIP(group).To16())
+	}
+	return buf
+}
+
+func TestParseMLDQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		buf       []byte
+		wantGroup string
+		wantDelay time.Duration
+	}{
+		{"v1 general", buildMLDQuery("", 10000, false), "", 10 * time.Second},
+		{"v1 group-specific", buildMLDQuery("ff02::fb", 1000, false), "ff02::fb", time.Second},
+		{"v2 linear", buildMLDQuery("", 20000, true), "", 20 * time.Second},
+		// exp 1, mant 0: (0x1000) << 4 = 65536 ms
+		{"v2 exponential", buildMLDQuery("", 0x9000, true), "", 65536 * time.Millisecond},
+		{"zero delay", buildMLDQuery("", 0, false), "", defaultMLDMaxResponseDelay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group, delay, ok := parseMLDQuery(tt.buf)
+			if !ok || group != tt.wantGroup || delay != tt.wantDelay {
+				t.Errorf("got (%q, %v, %v), want (%q, %v, true)", group, delay, ok, tt.wantGroup, tt.wantDelay)
+			}
+		})
+	}
+	if _, _, ok := parseMLDQuery(buildMLDQuery("", 0, false)[:20]); ok {
+		t.Error("short query: expected !ok")
+	}
+}
+
+func TestRecordMLDResponse(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	t0 := time.Now()
+	for _, ip := range []string{"fe80::1", "fe80::2", "fe80::3"} {
+		stats.RecordMessage(ip, "mld_report")
+	}
+
+	// Reports before any query are unsolicited.
+	stats.RecordMLDResponse("fe80::1", "ff02::fb", t0)
+
+	stats.RecordMLDQuery("", 10*time.Second, t0)
+	stats.RecordMLDResponse("fe80::1", "ff02::fb", t0.Add(2*time.Second))
+	stats.RecordMLDResponse("fe80::1", "ff02::fb", t0.Add(3*time.Second))  // repeat, ignored
+	stats.RecordMLDResponse("fe80::2", "ff02::fb", t0.Add(12*time.Second)) // late
+	stats.RecordMLDResponse("fe80::3", "ff02::fb", t0.Add(30*time.Second)) // too late to be an answer
+
+	got := stats.GetMLDLatencies()
+	if len(got) != 1 {
+		t.Fatalf("got %d groups, want 1", len(got))
+	}
+	l := got[0]
+	if l.Group != "ff02::fb" || l.Responses != 2 || l.Late != 1 {
+		t.Errorf("latency = %+v, want 2 responses, 1 late", l)
+	}
+	if l.Mean != 7*time.Second || l.Max != 12*time.Second {
+		t.Errorf("mean/max = %v/%v, want 7s/12s", l.Mean, l.Max)
+	}
+	if l.Slowest != "fe80::2" || l.SlowestLatency != 12*time.Second {
+		t.Errorf("slowest = %s (%v), want fe80::2 (12s)", l.Slowest, l.SlowestLatency)
+	}
+
+	// A group-specific query newer than the general query is matched instead.
+	t1 := t0.Add(time.Minute)
+	stats.RecordMLDQuery("ff02::fb", time.Second, t1)
+	stats.RecordMLDResponse("fe80::1", "ff02::fb", t1.Add(500*time.Millisecond))
+	for _, p := range stats.GetStats() {
+		if p.Address == "fe80::1" && p.MLDLatency["ff02::fb"] != 500*time.Millisecond {
+			t.Errorf("fe80::1 latency = %v, want 500ms", p.MLDLatency["ff02::fb"])
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package lib
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// mldQueryInterval is the default MLD Query Interval (RFC 3810 section
+	// 9.2). A peer watched this long has been asked for its groups at least
+	// once, so a Done for a group it never reported is not merely a join
+	// that happened before NDPeekr started.
+	mldQueryInterval = 125 * time.Second
+	// maxOrphanDones caps the Done-without-Join history.
+	maxOrphanDones = 100
+)
+
+// OrphanDone is an MLD Done for a group the peer never reported joining
+// while NDPeekr watched it: a host leaving a group it was never in, a stack
+// bug, or a spoofed Done trying to prune a group from snooping switches.
+type OrphanDone struct {
+	Peer  string    `json:"peer"`
+	Group string    `json:"group"`
+	At    time.Time `json:"at"`
+}
+
+// SilentGroup is a multicast group that has had members for at least the
+// window without a single packet sent to it: a subscription nothing uses,
+// or traffic that never reaches this segment.
+type SilentGroup struct {
+	Group   string    `json:"group"`
+	Members int       `json:"members"`
+	Since   time.Time `json:"since"` // first report for the group
+}
+
+// MulticastSanity collects the multicast data-quality signals.
+type MulticastSanity struct {
+	DoneWithoutJoin []OrphanDone  `json:"done_without_join,omitempty"` // newest first
+	SilentGroups    []SilentGroup `json:"silent_groups,omitempty"`     // by group
+	// TrafficVisible is false when the capture only sees ICMPv6 (the socket
+	// backend), so group traffic and SilentGroups are unknown.
+	TrafficVisible bool `json:"traffic_visible"`
+}
+
+// RecordMLDReport notes that ip reported group, so a later Done for it is
+// expected.
+func (s *NDPStats) RecordMLDReport(ip, group string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mldReported[ip+"|"+group] = at
+	if _, ok := s.groupJoined[group]; !ok {
+		s.groupJoined[group] = at
+	}
+}
+
+// RecordMLDDone records an MLD Done from ip for group and reports whether
+// it is an OrphanDone: ip never reported group although NDPeekr has seen it
+// for at least a query interval.
+func (s *NDPStats) RecordMLDDone(ip, group string, at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.mldReported[ip+"|"+group]; ok {
+		return false
+	}
+	peer, ok := s.peers[ip]
+	if !ok || at.Sub(peer.FirstSeen) < mldQueryInterval {
+		return false
+	}
+	s.orphanDones = append(s.orphanDones, OrphanDone{Peer: ip, Group: group, At: at})
+	if len(s.orphanDones) > maxOrphanDones {
+		s.orphanDones = s.orphanDones[len(s.orphanDones)-maxOrphanDones:]
+	}
+	return true
+}
+
+// RecordGroupTraffic notes a packet to multicast group dst at now. MLD is
+// signalling about a group rather than traffic to it and doesn't count.
+func (s *NDPStats) RecordGroupTraffic(dst string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groupTraffic[dst] = now
+	s.trafficVisible = true
+}
+
+// markTrafficVisible notes that a packet-level backend is capturing, so
+// groups without RecordGroupTraffic are really silent.
+func (s *NDPStats) markTrafficVisible() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trafficVisible = true
+}
+
+// recordGroupTraffic feeds multicast packets other than MLD to
+// RecordGroupTraffic.
+func (l *NDPListener) recordGroupTraffic(p ipv6Packet) {
+	if l.cfg.Stats == nil {
+		return
+	}
+	if !l.sawPackets {
+		l.sawPackets = true
+		l.cfg.Stats.markTrafficVisible()
+	}
+	if !p.dst.IsMulticast() {
+		return
+	}
+	if p.nextHeader == nhICMPv6 && len(p.payload) > 0 {
+		switch p.payload[0] {
+		case 130, 131, 132, 143: // MLD query, v1 report, done, v2 report
+			return
+		}
+	}
+	l.cfg.Stats.RecordGroupTraffic(p.dst.String(), l.now())
+}
+
+// GetMulticastSanity returns the Done-without-Join history and the groups
+// silent for the whole window.
+func (s *NDPStats) GetMulticastSanity() MulticastSanity {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.multicastSanityLocked(s.now())
+}
+
+// multicastSanityLocked computes GetMulticastSanity. Callers must hold s.mu.
+func (s *NDPStats) multicastSanityLocked(now time.Time) MulticastSanity {
+	cutoff := now.Add(-s.window)
+	ms := MulticastSanity{TrafficVisible: s.trafficVisible}
+	for i := len(s.orphanDones) - 1; i >= 0; i-- {
+		if d := s.orphanDones[i]; d.At.After(cutoff) {
+			ms.DoneWithoutJoin = append(ms.DoneWithoutJoin, d)
+		}
+	}
+	if !s.trafficVisible {
+		return ms
+	}
+
+	members := make(map[string]int)
+	for _, peer := range s.peers {
+		for group, last := range peer.Groups {
+			if last.After(cutoff) {
+				members[group]++
+			}
+		}
+	}
+	for group, n := range members {
+		since, ok := s.groupJoined[group]
+		if !ok || since.After(cutoff) || expectedSilent(group) {
+			continue
+		}
+		if last, ok := s.groupTraffic[group]; ok && last.After(cutoff) {
+			continue
+		}
+		ms.SilentGroups = append(ms.SilentGroups, SilentGroup{Group: group, Members: n, Since: since})
+	}
+	sort.Slice(ms.SilentGroups, func(i, j int) bool { return ms.SilentGroups[i].Group < ms.SilentGroups[j].Group })
+	return ms
+}
+
+// expectedSilent reports whether group is one every node joins and that is
+// normally quiet: all-nodes, all-routers, MLDv2-capable routers and the
+// solicited-node groups (ff02::1:ff00:0/104).
+func expectedSilent(group string) bool {
+	switch group {
+	case "ff02::1", "ff02::2", "ff02::16":
+		return true
+	}
+	a, err := netip.ParseAddr(group)
+	return err == nil && solicitedNodePrefix.Contains(a)
+}
+
+var solicitedNodePrefix = netip.MustParsePrefix("ff02::1:ff00:0/104")
+
+// pruneMulticastSanityLocked forgets reports of peers that are gone, joins
+// of groups without members and traffic older than cutoff. Callers must
+// hold s.mu.
+func (s *NDPStats) pruneMulticastSanityLocked(cutoff time.Time) {
+	for key := range s.mldReported {
+		ip, _, _ := strings.Cut(key, "|")
+		if _, ok := s.peers[ip]; !ok {
+			delete(s.mldReported, key)
+		}
+	}
+	joined := make(map[string]bool)
+	for _, peer := range s.peers {
+		for group := range peer.Groups {
+			joined[group] = true
+		}
+	}
+	for group := range s.groupJoined {
+		if !joined[group] {
+			delete(s.groupJoined, group)
+		}
+	}
+	for group, last := range s.groupTraffic {
+		if !last.After(cutoff) {
+			delete(s.groupTraffic, group)
+		}
+	}
+	kept := s.orphanDones[:0]
+	for _, d := range s.orphanDones {
+		if d.At.After(cutoff) {
+			kept = append(kept, d)
+		}
+	}
+	s.orphanDones = kept
+}
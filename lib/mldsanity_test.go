@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRecordMLDDone(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "mld_report")
+	stats.RecordMessage("fe80::2", "mld_done")
+	now := time.Now()
+
+	stats.RecordMLDReport("fe80::1", "ff02::fb", now)
+	if stats.RecordMLDDone("fe80::1", "ff02::fb", now.Add(mldQueryInterval)) {
+		t.Error("Done after a report flagged as orphan")
+	}
+	if stats.RecordMLDDone("fe80::2", "ff02::fb", now.Add(time.Second)) {
+		t.Error("Done from a peer seen for under a query interval flagged as orphan")
+	}
+	if !stats.RecordMLDDone("fe80::2", "ff02::c", now.Add(mldQueryInterval+time.Second)) {
+		t.Error("Done without a report not flagged")
+	}
+
+	got := stats.GetMulticastSanity().DoneWithoutJoin
+	if len(got) != 1 || got[0].Peer != "fe80::2" || got[0].Group != "ff02::c" {
+		t.Errorf("DoneWithoutJoin = %+v", got)
+	}
+}
+
+func TestGetMulticastSanity_SilentGroups(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	joined := time.Now().Add(-10 * time.Minute)
+	for _, group := range []string{"ff02::fb", "ff05::1:3", "ff02::1", "ff02::1:ff00:1"} {
+		stats.RecordMLDReport("fe80::1", group, joined)
+		stats.RecordMLDMembership("fe80::1", group)
+	}
+	stats.RecordMLDReport("fe80::1", "ff02::c", time.Now()) // joined too recently
+	stats.RecordMLDMembership("fe80::1", "ff02::c")
+
+	if ms := stats.GetMulticastSanity(); ms.TrafficVisible || len(ms.SilentGroups) != 0 {
+		t.Fatalf("without group traffic visible: %+v, want nothing silent", ms)
+	}
+
+	stats.RecordGroupTraffic("ff02::fb", time.Now())
+	ms := stats.GetMulticastSanity()
+	if len(ms.SilentGroups) != 1 || ms.SilentGroups[0].Group != "ff05::1:3" || ms.SilentGroups[0].Members != 1 {
+		t.Errorf("SilentGroups = %+v, want only ff05::1:3", ms.SilentGroups)
+	}
+}
+
+func TestHandlePacket_GroupTraffic(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	l.handlePacket(buildIPv6Packet("fe80::1", "ff02::fb", 255, nil, nhUDP, make([]byte, 8)), 0, "")
+	l.handlePacket(buildIPv6Packet("fe80::1", "ff02::c", 1, nil, nhICMPv6, buildMLDv1Report(net.ParseIP("ff02::c"))), 0, "")
+
+	stats.mu.RLock()
+	defer stats.mu.RUnlock()
+	if !stats.trafficVisible {
+		t.Error("packet-level capture did not mark group traffic visible")
+	}
+	if _, ok := stats.groupTraffic["ff02::fb"]; !ok {
+		t.Error("UDP to ff02::fb not recorded as group traffic")
+	}
+	if _, ok := stats.groupTraffic["ff02::c"]; ok {
+		t.Error("MLD report recorded as traffic to the group it reports")
+	}
+}
@@ -0,0 +1,218 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultMLDSnoopMisses is how many unanswered general queries in a
+	// row raise mld_reports_missing.
+	defaultMLDSnoopMisses = 2
+	// mldSnoopCheckInterval is how often the query and report state is
+	// checked.
+	mldSnoopCheckInterval = 10 * time.Second
+)
+
+// MLDSnoopConfig turns on MLD snooping verification: NDPeekr checks that
+// general queries arrive at the expected interval and that hosts known to
+// be in groups answer them. A snooping switch that drops queries, or eats
+// the reports answering them, otherwise only shows up as mDNS and other
+// link-local multicast quietly breaking once its group state times out.
+//
+// Snooping switches forward reports only towards the querier, so run this on
+// the querier's port or a mirror of it; elsewhere a healthy switch looks like
+// one eating every report.
+type MLDSnoopConfig struct {
+	// QueryInterval is the querier's general query interval (default 125s,
+	// RFC 3810). Queries are missing after the Other Querier Present
+	// Interval: twice this plus half the response delay.
+	QueryInterval time.Duration `yaml:"query_interval"`
+	// Misses is how many general queries in a row must go unanswered
+	// before alerting (default 2); a single miss is often just a busy link.
+	Misses int `yaml:"misses"`
+}
+
+// MLDSnoopStats is the MLD snooping verification state (see MLDSnoopConfig).
+type MLDSnoopStats struct {
+	Enabled bool `json:"enabled"`
+	// LastQuery is when the last general query was seen; zero if none yet.
+	LastQuery time.Time `json:"last_query,omitempty"`
+	// QueriesMissing is set while general queries are overdue.
+	QueriesMissing bool `json:"queries_missing"`
+	// Answered is how many hosts reported in answer to the last general
+	// query whose response window has closed, out of Members hosts with
+	// group memberships at the time.
+	Answered int `json:"answered"`
+	Members  int `json:"members"`
+	// Unanswered counts general queries in a row no member answered.
+	Unanswered int `json:"unanswered"`
+}
+
+// MLDSnoopCheckerConfig configures an MLDSnoopChecker.
+type MLDSnoopCheckerConfig struct {
+	Config MLDSnoopConfig
+	Logger *slog.Logger // required
+	Stats  *NDPStats    // required; supplies the queries and records alerts
+	Sinks  []Sink       // optional; receive the alerts
+}
+
+// MLDSnoopChecker raises mld_queries_missing when no general query has been
+// seen for the Other Querier Present Interval, and mld_reports_missing when
+// Misses general queries in a row got no report although hosts have joined
+// groups. Each alerts at most once per window.
+type MLDSnoopChecker struct {
+	cfg     MLDSnoopCheckerConfig
+	started time.Time
+	judged  time.Time // the last general query whose answers were counted
+}
+
+// NewMLDSnoopChecker returns a checker; call Run to start it.
+func NewMLDSnoopChecker(cfg MLDSnoopCheckerConfig) *MLDSnoopChecker {
+	if cfg.Config.QueryInterval <= 0 {
+		cfg.Config.QueryInterval = mldQueryInterval
+	}
+	if cfg.Config.Misses <= 0 {
+		cfg.Config.Misses = defaultMLDSnoopMisses
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	cfg.Stats.enableMLDSnoop()
+	return &MLDSnoopChecker{cfg: cfg, started: time.Now()}
+}
+
+// Run checks the query and report state until ctx is cancelled.
+func (c *MLDSnoopChecker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(mldSnoopCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			c.check(now)
+		}
+	}
+}
+
+// check looks at the latest general query at now.
+func (c *MLDSnoopChecker) check(now time.Time) {
+	q, answered, members := c.cfg.Stats.lastGeneralQuery()
+	overdue := 2*c.cfg.Config.QueryInterval + defaultMLDMaxResponseDelay/2
+	last := q.at
+	if last.IsZero() {
+		last = c.started
+	}
+	missing := now.Sub(last) > overdue
+	c.cfg.Stats.recordMLDSnoopQueries(q.at, missing)
+	if missing {
+		since := "since startup"
+		if !q.at.IsZero() {
+			since = "since " + q.at.Format("15:04:05")
+		}
+		c.alert(now, Alert{
+			Severity: SeverityWarning,
+			Category: "mld_queries_missing",
+			Message: fmt.Sprintf("no MLD general query %s (expected every %s): no querier, or the switch is not flooding queries",
+				since, formatDuration(c.cfg.Config.QueryInterval)),
+		})
+		return
+	}
+
+	// Count the answers once the query's response window has closed.
+	if q.at.IsZero() || !q.at.After(c.judged) || now.Sub(q.at) <= mldResponseSlack*q.maxDelay {
+		return
+	}
+	c.judged = q.at
+	unanswered := c.cfg.Stats.recordMLDSnoopAnswers(answered, members)
+	if unanswered < c.cfg.Config.Misses {
+		return
+	}
+	c.alert(now, Alert{
+		Severity: SeverityWarning,
+		Category: "mld_reports_missing",
+		Message: fmt.Sprintf("%d MLD general queries in a row got no report although %d hosts have joined groups: "+
+			"MLD snooping may be dropping reports, which breaks mDNS once group state expires", unanswered, members),
+	})
+}
+
+func (c *MLDSnoopChecker) alert(now time.Time, a Alert) {
+	if !c.cfg.Stats.alertDue(a.Category, now) {
+		return
+	}
+	a.Time = now
+	emitAlert(c.cfg.Stats, c.cfg.Logger, c.cfg.Sinks, a)
+}
+
+// lastGeneralQuery returns the latest general query, how many hosts
+// answered it, and how many hosts have reported group memberships within
+// the window before it (answering counts as reporting).
+func (s *NDPStats) lastGeneralQuery() (q mldQuery, answered, members int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	q = s.mldGeneralQuery
+	if q.at.IsZero() {
+		return q, 0, 0
+	}
+	reporters := make(map[string]bool)
+	for key, at := range s.mldAnswered {
+		if at.Equal(q.at) {
+			ip, _, _ := strings.Cut(key, "|")
+			reporters[ip] = true
+		}
+	}
+	cutoff := q.at.Add(-s.window)
+	for _, peer := range s.peers {
+		for _, last := range peer.Groups {
+			if last.After(cutoff) {
+				members++
+				break
+			}
+		}
+	}
+	return q, len(reporters), members
+}
+
+// enableMLDSnoop marks MLD snooping verification as on.
+func (s *NDPStats) enableMLDSnoop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mldSnoop.Enabled = true
+}
+
+// recordMLDSnoopQueries notes the latest general query and whether queries
+// are overdue.
+func (s *NDPStats) recordMLDSnoopQueries(last time.Time, missing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mldSnoop.LastQuery, s.mldSnoop.QueriesMissing = last, missing
+}
+
+// recordMLDSnoopAnswers records the answers to a general query and returns
+// how many queries in a row have now gone unanswered. A query with no known
+// members proves nothing and leaves the count alone.
+func (s *NDPStats) recordMLDSnoopAnswers(answered, members int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mldSnoop.Answered, s.mldSnoop.Members = answered, members
+	switch {
+	case answered > 0:
+		s.mldSnoop.Unanswered = 0
+	case members > 0:
+		s.mldSnoop.Unanswered++
+	}
+	return s.mldSnoop.Unanswered
+}
+
+// MLDSnoop returns the MLD snooping verification state.
+func (s *NDPStats) MLDSnoop() MLDSnoopStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mldSnoop
+}
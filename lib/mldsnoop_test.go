@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestMLDSnoopChecker(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	c := NewMLDSnoopChecker(MLDSnoopCheckerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	stats.RecordMLDMembership("fe80::2", "ff02::fb")
+	categories := func() map[string]int {
+		n := make(map[string]int)
+		for _, a := range stats.GetAlerts() {
+			n[a.Category]++
+		}
+		return n
+	}
+
+	// The first unanswered query may be a busy link; the second alerts.
+	t0 := time.Now()
+	stats.RecordMLDQuery("", time.Second, t0)
+	c.check(t0.Add(time.Second)) // still within the response window
+	c.check(t0.Add(3 * time.Second))
+	if st := stats.MLDSnoop(); st.Unanswered != 1 || st.Members != 1 || len(categories()) != 0 {
+		t.Fatalf("after one miss: %+v, alerts %v", st, categories())
+	}
+	stats.RecordMLDQuery("", time.Second, t0.Add(125*time.Second))
+	c.check(t0.Add(128 * time.Second))
+	if n := categories(); n["mld_reports_missing"] != 1 {
+		t.Fatalf("alerts = %v, want mld_reports_missing", n)
+	}
+
+	// An answer resets the count.
+	q := t0.Add(250 * time.Second)
+	stats.RecordMLDQuery("", time.Second, q)
+	stats.RecordMLDResponse("fe80::2", "ff02::fb", q.Add(500*time.Millisecond))
+	c.check(q.Add(3 * time.Second))
+	if st := stats.MLDSnoop(); st.Unanswered != 0 || st.Answered != 1 || st.QueriesMissing {
+		t.Errorf("after an answer: %+v", st)
+	}
+
+	// No query for longer than the Other Querier Present Interval.
+	c.check(q.Add(260 * time.Second))
+	if st := stats.MLDSnoop(); !st.QueriesMissing || categories()["mld_queries_missing"] != 1 {
+		t.Errorf("queries overdue: %+v, alerts %v", st, categories())
+	}
+}
@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"golang.org/x/net/ipv6"
+)
+
+// minIPv6MTU is the smallest link MTU IPv6 allows (RFC 8200 section 5).
+const minIPv6MTU = 1280
+
+// MTUStats is what Packet Too Big messages have shown about the link MTU.
+type MTUStats struct {
+	// ICMPErrors is set when Packet Too Big messages are observed (see
+	// NDPListenerConfig.ICMPErrors).
+	ICMPErrors   bool `json:"icmp_errors"`
+	PacketTooBig int  `json:"packet_too_big"`
+	// SmallestMTU is the smallest MTU a Packet Too Big reported, 0 if none.
+	SmallestMTU int `json:"smallest_mtu,omitempty"`
+	// LastSource and LastMTU describe the most recent Packet Too Big.
+	LastSource string `json:"last_source,omitempty"`
+	LastMTU    int    `json:"last_mtu,omitempty"`
+}
+
+// raMTUAlert compares the MTU a router advertises with the MTU of the
+// interface its RA arrived on. Hosts use the advertised MTU, so one above
+// the interface's is a blackhole for every packet in between; one below is
+// legal but usually a stale router setting. linkMTU or advertised may be 0
+// when unknown, in which case there is nothing to compare.
+func raMTUAlert(src, ifName string, linkMTU int, advertised uint32) (Alert, bool) {
+	if linkMTU <= 0 || advertised == 0 || int(advertised) == linkMTU {
+		return Alert{}, false
+	}
+	if int(advertised) > linkMTU {
+		return Alert{
+			Severity: SeverityWarning,
+			Category: "mtu_mismatch",
+			Source:   src,
+			Message: fmt.Sprintf("router advertises MTU %d but %s has MTU %d: packets between the two sizes are blackholed",
+				advertised, ifName, linkMTU),
+		}, true
+	}
+	return Alert{
+		Severity: SeverityInfo,
+		Category: "mtu_mismatch",
+		Source:   src,
+		Message:  fmt.Sprintf("router advertises MTU %d, below the MTU %d of %s", advertised, linkMTU, ifName),
+	}, true
+}
+
+// checkPacketTooBig records a Packet Too Big message and raises
+// ptb_below_minimum for one reporting an MTU IPv6 doesn't allow, and
+// mtu_mismatch for one about an on-link destination reporting less than the
+// link MTU: something on the link, a tunnel or a misconfigured bridge, can't
+// carry what the routers advertise, and hosts that miss the message
+// blackhole full-size packets.
+func (l *NDPListener) checkPacketTooBig(r received) {
+	buf := r.payload
+	if len(buf) < 8 {
+		return
+	}
+	mtu := int(binary.BigEndian.Uint32(buf[4:8]))
+	// The invoking packet follows; its destination is at IPv6 header offset 24.
+	var dst netip.Addr
+	if len(buf) >= 8+ipv6HeaderLen && buf[8]>>4 == 6 {
+		dst = netip.AddrFrom16([16]byte(buf[32:48]))
+	}
+	ifName := l.ifName(r.ifIndex)
+	link := ifName
+	if link == "" {
+		link = r.src.Zone()
+	}
+	src := l.peerAddr(r.src, link).String()
+	if l.cfg.Stats == nil {
+		return
+	}
+	onLink, linkMTU := l.cfg.Stats.recordPacketTooBig(src, mtu, dst)
+	if linkMTU == 0 && r.ifIndex != 0 {
+		if ifi, err := net.InterfaceByIndex(r.ifIndex); err == nil {
+			linkMTU = ifi.MTU
+		}
+	}
+
+	switch {
+	case mtu < minIPv6MTU:
+		l.raiseAlertOnce("ptb_below_minimum|"+src, Alert{
+			Severity: SeverityWarning,
+			Category: "ptb_below_minimum",
+			Source:   src,
+			Message: fmt.Sprintf("Packet Too Big reports MTU %d, below the IPv6 minimum of %d: hosts ignore it, and it is a known way to force fragmentation",
+				mtu, minIPv6MTU),
+			Port: r.port,
+		})
+	case onLink && linkMTU > 0 && mtu < linkMTU:
+		l.raiseAlertOnce("mtu_mismatch|"+src, Alert{
+			Severity: SeverityWarning,
+			Category: "mtu_mismatch",
+			Source:   src,
+			Message: fmt.Sprintf("Packet Too Big for on-link %s reports MTU %d, below the link MTU %d: something on the link can't carry full-size packets",
+				scopedAddr(dst, link), mtu, linkMTU),
+			Port: r.port,
+		})
+	}
+}
+
+// isPacketTooBig reports whether msg is an ICMPv6 Packet Too Big.
+func isPacketTooBig(msg []byte) bool {
+	return len(msg) > 0 && msg[0] == byte(ipv6.ICMPTypePacketTooBig)
+}
+
+// enableICMPErrors marks Packet Too Big messages as observed.
+func (s *NDPStats) enableICMPErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mtu.ICMPErrors = true
+}
+
+// recordPacketTooBig counts a Packet Too Big from src reporting mtu for a
+// packet to dst (invalid if unknown). It reports whether dst is on-link,
+// being link-local or inside a prefix some router advertises as on-link, and
+// the smallest MTU the routers advertise (0 if none does).
+func (s *NDPStats) recordPacketTooBig(src string, mtu int, dst netip.Addr) (onLink bool, linkMTU int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mtu.PacketTooBig++
+	s.mtu.LastSource, s.mtu.LastMTU = src, mtu
+	if s.mtu.SmallestMTU == 0 || mtu < s.mtu.SmallestMTU {
+		s.mtu.SmallestMTU = mtu
+	}
+
+	onLink = dst.IsLinkLocalUnicast()
+	for _, r := range s.routers {
+		if r.MTU != 0 && (linkMTU == 0 || int(r.MTU) < linkMTU) {
+			linkMTU = int(r.MTU)
+		}
+		for _, p := range r.Prefixes {
+			if pfx, err := netip.ParsePrefix(p.Prefix); err == nil && p.OnLink && pfx.Contains(dst.WithZone("")) {
+				onLink = true
+			}
+		}
+	}
+	return onLink, linkMTU
+}
+
+// MTU returns what Packet Too Big messages have shown.
+func (s *NDPStats) MTU() MTUStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mtu
+}
@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"NDPeekr/lib/craft"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestRAMTUAlert(t *testing.T) {
+	tests := []struct {
+		linkMTU    int
+		advertised uint32
+		alert      bool
+		severity   Severity
+	}{
+		{1500, 1500, false, 0},
+		{1500, 0, false, 0},
+		{0, 9000, false, 0},
+		{1500, 9000, true, SeverityWarning},
+		{9000, 1500, true, SeverityInfo},
+	}
+	for _, tt := range tests {
+		a, ok := raMTUAlert("fe80::1", "eth0", tt.linkMTU, tt.advertised)
+		if ok != tt.alert || a.Severity != tt.severity {
+			t.Errorf("raMTUAlert(link %d, advertised %d) = %+v, %v; want %v, %s",
+				tt.linkMTU, tt.advertised, a, ok, tt.alert, tt.severity)
+		}
+	}
+}
+
+// buildPacketTooBig builds a Packet Too Big reporting mtu for a packet to dst.
+func buildPacketTooBig(mtu uint32, dst string) []byte {
+	msg := make([]byte, 8)
+	msg[0] = 2 // Packet Too Big
+	binary.BigEndian.PutUint32(msg[4:8], mtu)
+	return append(msg, craft.IPv6Packet(net.ParseIP("2001:db8:1::10"), net.ParseIP(dst), 64, false, make([]byte, 64))...)
+}
+
+func TestPacketTooBig(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:      stats,
+		ICMPErrors: true,
+	})
+	stats.RecordRouter(RouterInfo{Address: "fe80::1", Lifetime: time.Minute, MTU: 1500,
+		Prefixes: []PrefixInfo{{Prefix: "2001:db8:1::/64", OnLink: true}}})
+	router := netip.MustParseAddr("fe80::1")
+
+	// Off-link destinations behind a smaller WAN link are plain PMTUD.
+	l.handle(received{src: router, payload: buildPacketTooBig(1492, "2001:db8:99::1")})
+	// An on-link destination below the advertised MTU is not.
+	l.handle(received{src: router, payload: buildPacketTooBig(1400, "2001:db8:1::20")})
+	l.handle(received{src: netip.MustParseAddr("fe80::66"), payload: buildPacketTooBig(576, "2001:db8:99::1")})
+
+	got := make(map[string]int)
+	for _, a := range stats.GetAlerts() {
+		got[a.Category]++
+	}
+	if got["mtu_mismatch"] != 1 || got["ptb_below_minimum"] != 1 || len(got) != 2 {
+		t.Errorf("alerts = %v, want one mtu_mismatch and one ptb_below_minimum", got)
+	}
+	if st := stats.MTU(); st.PacketTooBig != 3 || st.SmallestMTU != 576 || st.LastSource != "fe80::66" {
+		t.Errorf("stats = %+v", st)
+	}
+	if p := stats.GetStats(); len(p) != 0 {
+		t.Errorf("Packet Too Big created peers: %+v", p)
+	}
+}
@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// MulticastGroupLabel names a site-specific multicast group or range.
+type MulticastGroupLabel struct {
+	Prefix *net.IPNet // a single group is a /128
+	Label  string
+}
+
+// parseMulticastGroupLabels compiles the multicast_groups config section.
+// Keys are an IPv6 address or prefix; the result is ordered most specific first.
+func parseMulticastGroupLabels(groups map[string]string) ([]MulticastGroupLabel, error) {
+	labels := make([]MulticastGroupLabel, 0, len(groups))
+	for key, label := range groups {
+		var prefix *net.IPNet
+		if strings.Contains(key, "/") {
+			_, n, err := net.ParseCIDR(key)
+			if err != nil || n.IP.To4() != nil {
+				return nil, fmt.Errorf("%q is not an IPv6 prefix", key)
+			}
+			prefix = n
+		} else {
+			ip := net.ParseIP(key)
+			if ip == nil || ip.To4() != nil {
+				return nil, fmt.Errorf("%q is not an IPv6 address", key)
+			}
+			prefix = &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+		}
+		if !prefix.IP.IsMulticast() {
+			return nil, fmt.Errorf("%q is not a multicast address", key)
+		}
+		if label == "" {
+			return nil, fmt.Errorf("%q has an empty label", key)
+		}
+		labels = append(labels, MulticastGroupLabel{Prefix: prefix, Label: label})
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		oi, _ := labels[i].Prefix.Mask.Size()
+		oj, _ := labels[j].Prefix.Mask.Size()
+		if oi != oj {
+			return oi > oj
+		}
+		return labels[i].Prefix.String() < labels[j].Prefix.String()
+	})
+	return labels, nil
+}
+
+// customMulticastLabel returns the label of the most specific entry covering group, or "".
+func customMulticastLabel(labels []MulticastGroupLabel, group string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	ip := net.ParseIP(group)
+	if ip == nil {
+		return ""
+	}
+	for _, l := range labels {
+		if l.Prefix.Contains(ip) {
+			return l.Label
+		}
+	}
+	return ""
+}
+
+// IPv6 multicast scopes (RFC 4291 section 2.7, RFC 7346), the low nibble of
+// the second address byte.
+const (
+	scopeInterfaceLocal = 0x1
+	scopeLinkLocal      = 0x2
+	scopeRealmLocal     = 0x3
+	scopeAdminLocal     = 0x4
+	scopeSiteLocal      = 0x5
+	scopeOrgLocal       = 0x8
+	scopeGlobal         = 0xe
+)
+
+var multicastScopeNames = map[int]string{
+	scopeInterfaceLocal: "Interface-local",
+	scopeLinkLocal:      "Link-local",
+	scopeRealmLocal:     "Realm-local",
+	scopeAdminLocal:     "Admin-local",
+	scopeSiteLocal:      "Site-local",
+	scopeOrgLocal:       "Organization-local",
+	scopeGlobal:         "Global",
+}
+
+// multicastScope returns the scope field of a multicast group address, or -1
+// if group is not an IPv6 multicast address.
+func multicastScope(group string) int {
+	ip := net.ParseIP(group)
+	if ip == nil || ip.To4() != nil || !ip.IsMulticast() {
+		return -1
+	}
+	return int(ip[1] & 0x0f)
+}
+
+// multicastScopeName names a scope value; reserved and unassigned values are
+// shown as "Scope N".
+func multicastScopeName(scope int) string {
+	if name, ok := multicastScopeNames[scope]; ok {
+		return name
+	}
+	if scope < 0 {
+		return "Unknown"
+	}
+	return fmt.Sprintf("Scope %X", scope)
+}
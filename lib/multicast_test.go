@@ -0,0 +1,39 @@
+package lib
+
+import "testing"
+
+func TestMulticastScope(t *testing.T) {
+	tests := map[string]string{
+		"ff01::1":      "Interface-local",
+		"ff02::1":      "Link-local",
+		"ff05::1:3":    "Site-local",
+		"ff08::101":    "Organization-local",
+		"ff3e::8000:1": "Global", // flags nibble is ignored
+		"ff06::1":      "Scope 6",
+		"fe80::1":      "Unknown",
+		"garbage":      "Unknown",
+	}
+	for group, want := range tests {
+		if got := multicastScopeName(multicastScope(group)); got != want {
+			t.Errorf("scope of %s = %q, want %q", group, got, want)
+		}
+	}
+}
+
+func TestAggregateMulticastGroups_OrderedByScope(t *testing.T) {
+	peers := []PeerSummary{
+		{Groups: []string{"ff05::1:3", "ff02::fb", "ff0e::101"}},
+		{Groups: []string{"ff05::1:3", "ff02::1"}},
+		{Groups: []string{"ff02::1"}},
+	}
+	got := aggregateMulticastGroups(peers)
+	want := []string{"ff02::1", "ff02::fb", "ff05::1:3", "ff0e::101"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d groups, want %d", len(got), len(want))
+	}
+	for i, g := range got {
+		if g.Group != want[i] {
+			t.Errorf("entry %d = %s, want %s", i, g.Group, want[i])
+		}
+	}
+}
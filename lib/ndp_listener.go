@@ -1,12 +1,17 @@
 package lib
 
 import (
+	"NDPeekr/lib/craft"
 	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"iter"
 	"log/slog"
 	"net"
+	"net/netip"
+	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/net/icmp"
@@ -18,10 +23,80 @@ type NDPListenerConfig struct {
 	Interface  string       // optional; best-effort restriction by ifindex (requires control msgs)
 	Logger     *slog.Logger // required
 	Stats      *NDPStats    // optional; if set, records messages instead of logging
+	Sinks      []Sink       // optional; receive every event and alert
+	Capture    string       // CaptureSocket (default), CapturePacket or CapturePcap
+	PcapFile   string       // file to replay with CapturePcap
+	// MemberPorts captures on the member ports of the bridge or bond named
+	// by Interface and attributes each event to its port (CapturePacket only).
+	MemberPorts bool
+	// Ring, if set, keeps recent raw packets for later pcap dumps. Only
+	// packet-level backends (CapturePacket, CapturePcap) feed it.
+	Ring *PacketRing
+	// Shadow, if set, records every captured packet (see ShadowRecorder).
+	Shadow *ShadowRecorder
+	// ScopeByInterface keys every peer by address and capture interface, not
+	// only link-local ones, for captures spanning several interfaces: a
+	// global address seen on two VLANs is then two peers (see MergePeers).
+	ScopeByInterface bool
+	// MaxSampling caps adaptive sampling: when handling messages keeps the
+	// capture loop busy, only one in up to MaxSampling is fully processed
+	// and the rest are only counted (see SamplingStats). 0 or 1 processes
+	// every message. Needs Stats; pcap replay, which always runs flat out,
+	// is never sampled.
+	MaxSampling int
+	// FastPath profiles the message types on the segment and sends the
+	// dominant RS, NS and NA types down a fast path: no generic ICMPv6
+	// parse, cached interface names, no debug log fields unless debug
+	// logging is on, and no option usage matrix, so only the link-layer
+	// address option is decoded. Needs Stats.
+	FastPath bool
+	// RAGuard, if set, raises ra_guard_leak for every RA from a router it
+	// doesn't allow (see RAGuardConfig). Its entries must be valid.
+	RAGuard *RAGuardConfig
+	// RogueRA, if set, checks every RA for untrusted routers, short
+	// lifetimes and conflicting prefixes (see RogueRAConfig). Its entries
+	// must be valid. Needs Stats.
+	RogueRA *RogueRAConfig
+	// Mirror treats Interface as a SPAN or mirror port (CapturePacket), or
+	// the replayed file as captured on one (CapturePcap): frames are read
+	// whole from the Ethernet layer, in promiscuous mode, whoever they are
+	// addressed to; the Ethernet source stands in for a missing link-layer
+	// address option; and events are labeled via "mirror".
+	Mirror bool
+	// ICMPErrors also observes ICMPv6 Packet Too Big messages and checks the
+	// MTUs they report against the link's (see checkPacketTooBig). Needs Stats.
+	ICMPErrors bool
+	// Ready, if set, is called once the capture is open (see Health).
+	Ready func()
 }
 
+// Capture backends for NDPListenerConfig.Capture.
+const (
+	// CaptureSocket reads from a raw ICMPv6 socket; the kernel strips the
+	// IPv6 header and extension headers and validates the checksum.
+	CaptureSocket = "socket"
+	// CapturePacket reads whole IPv6 packets from an AF_PACKET socket (Linux)
+	// and walks the extension header chain itself.
+	CapturePacket = "packet"
+	// CapturePcap replays a pcap file (PcapFile) through the packet-level path.
+	CapturePcap = "pcap"
+)
+
 type NDPListener struct {
-	cfg NDPListenerConfig
+	cfg     NDPListenerConfig
+	sampler *sampler       // nil without MaxSampling
+	profile *fastProfiler  // nil without FastPath
+	raGuard *routerSet     // nil without RAGuard
+	rogueRA *rogueRA       // nil without RogueRA
+	ifNames map[int]string // see ifName
+	// replayAt is the capture time of the packet being replayed from a
+	// pcap, which runs far faster than it was captured; zero when live.
+	replayAt time.Time
+	// frameMAC is the Ethernet source of the frame being handled in mirror
+	// mode, "" otherwise.
+	frameMAC string
+	// sawPackets is set once recordGroupTraffic has run.
+	sawPackets bool
 }
 
 func NewNDPListener(cfg NDPListenerConfig) *NDPListener {
@@ -31,17 +106,66 @@ func NewNDPListener(cfg NDPListenerConfig) *NDPListener {
 	if cfg.Logger == nil {
 		cfg.Logger = slog.Default()
 	}
-	return &NDPListener{cfg: cfg}
+	l := &NDPListener{cfg: cfg}
+	if cfg.MaxSampling > 1 && cfg.Stats != nil && cfg.Capture != CapturePcap {
+		l.sampler = newSampler(cfg.MaxSampling)
+	}
+	if cfg.FastPath && cfg.Stats != nil {
+		l.profile = newFastProfiler()
+		cfg.Stats.enableFastPath()
+	}
+	if cfg.RAGuard != nil {
+		l.raGuard, _ = newRAGuard(*cfg.RAGuard) // validated with the config
+		if cfg.Stats != nil {
+			cfg.Stats.enableRAGuard()
+		}
+	}
+	if cfg.RogueRA != nil && cfg.Stats != nil {
+		l.rogueRA, _ = newRogueRA(*cfg.RogueRA) // validated with the config
+		cfg.Stats.enableRogueRA(len(cfg.RogueRA.Trusted))
+	}
+	if cfg.ICMPErrors && cfg.Stats != nil {
+		cfg.Stats.enableICMPErrors()
+	}
+	return l
+}
+
+// Run captures NDP/MLD messages until ctx is cancelled, using the configured
+// capture backend (see NDPListenerConfig.Capture).
+func (l *NDPListener) Run(ctx context.Context) error {
+	if l.cfg.MemberPorts && l.cfg.Capture != CapturePacket {
+		return errors.New("member port capture needs the packet backend")
+	}
+	if l.cfg.Mirror {
+		switch {
+		case l.cfg.Capture != CapturePacket && l.cfg.Capture != CapturePcap:
+			return errors.New("mirror mode needs the packet backend or a pcap replay")
+		case l.cfg.Capture == CapturePacket && l.cfg.Interface == "":
+			return errors.New("mirror mode needs an interface")
+		case l.cfg.MemberPorts:
+			return errors.New("mirror mode and member port capture are mutually exclusive")
+		}
+	}
+	switch l.cfg.Capture {
+	case "", CaptureSocket:
+		return l.runSocket(ctx)
+	case CapturePacket:
+		return l.runPacket(ctx)
+	case CapturePcap:
+		return l.runPcap(ctx)
+	default:
+		return fmt.Errorf("unknown capture backend %q", l.cfg.Capture)
+	}
 }
 
-// Run opens an ICMPv6 socket and logs common NDP message types.
+// runSocket opens an ICMPv6 socket and logs common NDP message types.
 //
 // Notes:
 // - Requires elevated privileges (root/CAP_NET_RAW) for "ip6:ipv6-icmp".
 // - Interface restriction is best-effort; we filter using the received IfIndex control message.
 // - If you later want strict NDP validity, enforce HopLimit == 255 before accepting events.
 // - -- TODO: Add hop limit as a cli parameter
-func (l *NDPListener) Run(ctx context.Context) error {
+func (l *NDPListener) runSocket(ctx context.Context) error {
 	// ICMPv6 socket (datagram-style, not net.Conn).
 	pc, err := icmp.ListenPacket("ip6:ipv6-icmp", l.cfg.ListenAddr)
 	if err != nil {
@@ -60,23 +184,11 @@ func (l *NDPListener) Run(ctx context.Context) error {
 		l.cfg.Logger.Warn("failed to enable ipv6 control messages; continuing", "err", err)
 	}
 
-	// Resolve the requested interface if any
-	var wantIfIndex int
-	if l.cfg.Interface != "" {
-		ifi, e := net.InterfaceByName(l.cfg.Interface)
-		if e != nil {
-			l.cfg.Logger.Warn("interface not found; continuing without restriction", "iface", l.cfg.Interface, "err", e)
-		} else {
-			wantIfIndex = ifi.Index
-			l.cfg.Logger.Info("interface restriction requested", "iface", ifi.Name, "ifindex", ifi.Index)
-		}
-	}
+	wantIfIndex := l.interfaceIndex()
+	l.ready()
 
 	buf := make([]byte, 64*1024)
 
-	// Use deadlines so ctx cancellation is honored promptly
-	const readTimeout = 800 * time.Millisecond
-
 	for {
 		select {
 		case <-ctx.Done():
@@ -99,8 +211,6 @@ func (l *NDPListener) Run(ctx context.Context) error {
 			return fmt.Errorf("read: %w", err)
 		}
 
-		srcIP := ipFromAddr(src)
-
 		// Best-effort interface restriction (requires cm.IfIndex)
 		if wantIfIndex != 0 {
 			if cm == nil || cm.IfIndex != wantIfIndex {
@@ -108,113 +218,510 @@ func (l *NDPListener) Run(ctx context.Context) error {
 			}
 		}
 
-		// Parse ICMPv6 message bytes
-		msg, perr := icmp.ParseMessage(ipv6.ICMPTypeEchoReply.Protocol(), buf[:n])
-		if perr != nil {
-			l.cfg.Logger.Warn("failed to parse icmpv6", "src", srcIP, "len", n, "err", perr)
-			continue
+		r := received{src: addrFromNet(src), payload: buf[:n]}
+		if cm != nil {
+			r.hopLimit = cm.HopLimit
+			r.ifIndex = cm.IfIndex
+			if dst, ok := netip.AddrFromSlice(cm.Dst); ok {
+				r.dst = dst.Unmap()
+			}
 		}
+		if l.cfg.Shadow != nil {
+			l.cfg.Shadow.addMessage(time.Now(), r)
+		}
+		l.handle(r)
+	}
+}
+
+// ready reports that the capture is open.
+func (l *NDPListener) ready() {
+	if l.cfg.Ready != nil {
+		l.cfg.Ready()
+	}
+}
 
-		ndpKind := classifyICMPv6(msg.Type)
-		if ndpKind == "" {
-			// Not an NDP ICMPv6 type; ignore by default
-			continue
+// readTimeout bounds each blocking read so ctx cancellation is honored promptly.
+const readTimeout = 800 * time.Millisecond
+
+// interfaceIndex resolves the requested interface, or returns 0 for none.
+func (l *NDPListener) interfaceIndex() int {
+	if l.cfg.Interface == "" {
+		return 0
+	}
+	ifi, e := net.InterfaceByName(l.cfg.Interface)
+	if e != nil {
+		l.cfg.Logger.Warn("interface not found; continuing without restriction", "iface", l.cfg.Interface, "err", e)
+		return 0
+	}
+	l.cfg.Logger.Info("interface restriction requested", "iface", ifi.Name, "ifindex", ifi.Index)
+	return ifi.Index
+}
+
+// handlePacket decodes a whole IPv6 packet from a packet-level backend,
+// skipping extension headers to reach the ICMPv6 message. ICMPv6 packets with
+// an unexpected extension header chain are counted in stats; they are still
+// processed when the message could be located. Messages with a bad checksum
+// are counted and dropped. port names the bridge or bond member port the
+// packet arrived on, if known.
+func (l *NDPListener) handlePacket(pkt []byte, ifIndex int, port string) {
+	if l.cfg.Ring != nil {
+		l.cfg.Ring.Add(time.Now(), pkt)
+	}
+	if l.cfg.Shadow != nil {
+		l.cfg.Shadow.AddPacket(time.Now(), pkt)
+	}
+	p, err := decodeIPv6(pkt)
+	l.recordGroupTraffic(p)
+	if p.nextHeader == nhVRRP || p.nextHeader == nhUDP {
+		l.handleFHRP(p, ifIndex)
+		return
+	}
+	if p.nextHeader != nhICMPv6 {
+		return
+	}
+	if p.anomaly != "" && l.cfg.Stats != nil {
+		l.cfg.Stats.RecordExtHeaderAnomaly(p.anomaly)
+	}
+	if err != nil {
+		l.cfg.Logger.Debug("icmpv6 payload unavailable", "src", p.src.String(), "reason", p.anomaly, "err", err)
+		return
+	}
+	if len(p.payload) < 4 {
+		return
+	}
+
+	// Unlike the socket backend, nothing has verified the checksum yet.
+	if icmpv6Checksum(p.src, p.dst, p.payload) != 0 {
+		src := p.src.String()
+		if l.cfg.Stats != nil {
+			l.cfg.Stats.RecordChecksumFailure()
 		}
+		l.cfg.Logger.Debug("icmpv6 checksum mismatch; dropped", "src", src, "len", len(p.payload))
+		l.raiseAlertOnce("bad_checksum|"+src, Alert{
+			Severity: SeverityInfo,
+			Category: "bad_checksum",
+			Source:   src,
+			Message:  fmt.Sprintf("ICMPv6 type %d with a bad checksum dropped", p.payload[0]),
+		})
+		return
+	}
+
+	l.handle(received{
+		src:      netip.AddrFrom16([16]byte(p.src)),
+		dst:      netip.AddrFrom16([16]byte(p.dst)),
+		hopLimit: p.hopLimit,
+		ifIndex:  ifIndex,
+		port:     port,
+		payload:  p.payload,
 
-		// this is the args sent to log info further down
-		fields := []any{
-			"type", msg.Type,
-			"code", msg.Code,
+		packetLevel: true,
+		extHeaders:  p.extHeaders,
+		hbh:         p.hbhOptions,
+	})
+}
+
+// received is one ICMPv6 message plus the IPv6 header fields that came with
+// it, independent of the capture backend.
+type received struct {
+	src      netip.Addr
+	dst      netip.Addr // invalid if unknown
+	hopLimit int        // 0 if unknown
+	ifIndex  int        // 0 if unknown
+	port     string     // bridge or bond member port, "" if unknown
+	payload  []byte     // the ICMPv6 message, type byte first
+	// packetLevel is set by backends that see the whole IPv6 packet; only
+	// then are extHeaders and hbh meaningful.
+	packetLevel bool
+	// extHeaders lists the IPv6 extension headers in front of the message.
+	extHeaders []int
+	// hbh is the Hop-by-Hop option area, nil if there was no such header.
+	hbh []byte
+}
+
+// handle processes one ICMPv6 message, or under overload may only count it
+// (see NDPListenerConfig.MaxSampling).
+func (l *NDPListener) handle(r received) {
+	l.checkRAGuard(r)
+	if l.cfg.ICMPErrors && isPacketTooBig(r.payload) {
+		l.checkPacketTooBig(r)
+		return
+	}
+	if l.sampler != nil && len(r.payload) > 0 {
+		l.sample(r)
+		return
+	}
+	l.process(r)
+}
+
+// process classifies one ICMPv6 message and records it to stats and sinks.
+func (l *NDPListener) process(r received) {
+	buf := r.payload
+	n := len(buf)
+	fast := l.onFastPath(buf)
+
+	// Parse ICMPv6 message bytes; the fast path only needs type and code
+	var (
+		icmpType ipv6.ICMPType
+		code     int
+	)
+	if fast {
+		icmpType, code = ipv6.ICMPType(buf[0]), int(buf[1])
+	} else {
+		msg, perr := icmp.ParseMessage(ipv6.ICMPTypeEchoReply.Protocol(), buf)
+		if perr != nil {
+			l.cfg.Logger.Warn("failed to parse icmpv6", "src", r.src, "len", n, "err", perr)
+			return
+		}
+		icmpType, code = msg.Type.(ipv6.ICMPType), msg.Code
+	}
+
+	ndpKind := classifyICMPv6(icmpType)
+	if ndpKind == "" {
+		// Not an NDP ICMPv6 type; ignore by default
+		return
+	}
+
+	var ifi *net.Interface
+	if r.ifIndex != 0 && !fast {
+		ifi, _ = net.InterfaceByIndex(r.ifIndex)
+	}
+	ifName := ""
+	if ifi != nil {
+		ifName = ifi.Name
+	} else if fast {
+		ifName = l.ifName(r.ifIndex)
+	}
+	// link zones link-local addresses; the socket backend may know it from
+	// the source's scope even without control messages.
+	link := ifName
+	if link == "" {
+		link = r.src.Zone()
+	}
+	srcIP := l.peerAddr(r.src, link).String()
+	dstIP := ""
+	if r.dst.IsValid() {
+		dstIP = scopedAddr(r.dst, link).String()
+	}
+
+	// this is the args sent to log info further down
+	var fields []any
+	if !fast || l.cfg.Logger.Enabled(context.Background(), slog.LevelDebug) {
+		fields = []any{
+			"type", icmpType,
+			"code", code,
 			"ndp", ndpKind,
 			"src", srcIP,
 			"len", n,
 		}
+		if r.hopLimit != 0 {
+			fields = append(fields, "hoplimit", r.hopLimit)
+		}
+		if ifName != "" {
+			fields = append(fields, "iface", ifName, "ifindex", r.ifIndex)
+		} else if r.ifIndex != 0 {
+			fields = append(fields, "ifindex", r.ifIndex)
+		}
+		if r.port != "" {
+			fields = append(fields, "port", r.port)
+		}
+		if dstIP != "" {
+			fields = append(fields, "dst", dstIP)
+		}
+	}
 
-		if cm != nil {
-			if cm.HopLimit != 0 {
-				fields = append(fields, "hoplimit", cm.HopLimit)
-			}
-			if cm.IfIndex != 0 {
-				if ifi, e := net.InterfaceByIndex(cm.IfIndex); e == nil {
-					fields = append(fields, "iface", ifi.Name, "ifindex", ifi.Index)
-				} else {
-					fields = append(fields, "ifindex", cm.IfIndex)
-				}
-			}
-			if cm.Dst != nil {
-				fields = append(fields, "dst", cm.Dst.String())
+	ev := Event{
+		Time:     l.now(),
+		Kind:     ndpKind,
+		Type:     int(buf[0]),
+		Code:     code,
+		Src:      srcIP,
+		Dst:      dstIP,
+		HopLimit: r.hopLimit,
+		Length:   n,
+		Port:     r.port,
+
+		ExtHeaders: r.extHeaders,
+	}
+	ev.Interface = ifName
+	if l.cfg.Mirror {
+		ev.Via = ViaMirror
+	}
+	var optTypes []byte
+	if !fast {
+		optTypes = ndpOptionTypes(buf)
+		for _, t := range optTypes {
+			ev.Options = append(ev.Options, optionName(t))
+		}
+	}
+
+	// Record to stats if configured, otherwise log
+	if l.cfg.Stats != nil {
+		if fields != nil {
+			l.cfg.Logger.Debug("ndp event", fields...)
+		}
+		if l.checkDuplicate(r, ifName, ev.Time) {
+			return
+		}
+		l.cfg.Stats.RecordMessage(srcIP, ndpKind)
+		if l.cfg.Stats.RecordSize(srcIP, ndpKind, n) {
+			l.raiseAlert(Alert{
+				Severity: SeverityWarning,
+				Category: "oversized_packet",
+				Source:   srcIP,
+				Message:  fmt.Sprintf("%s of %d bytes exceeds %d-byte threshold", ndpKind, n, oversizedThresholds[ndpKind]),
+				Port:     r.port,
+			})
+		}
+		if !fast {
+			l.cfg.Stats.RecordOptions(srcIP, ndpKind, optTypes)
+		}
+		if r.hopLimit != 0 {
+			l.cfg.Stats.RecordHopLimit(srcIP, r.hopLimit)
+		}
+		if ifName != "" {
+			l.cfg.Stats.RecordInterface(srcIP, ifName)
+		}
+		if r.port != "" {
+			l.cfg.Stats.RecordPort(srcIP, r.port)
+		}
+
+		// Extract link-layer (MAC) address from NDP options
+		var mac string
+		bound := srcIP // the address mac is claimed for
+		switch ndpKind {
+		case "router_solicitation", "router_advertisement", "neighbor_solicitation":
+			mac = parseLinkLayerAddr(buf, 1) // Source Link-Layer Address
+		case "neighbor_advertisement":
+			mac = parseLinkLayerAddr(buf, 2) // Target Link-Layer Address
+			if target := parseNDTarget(buf); mac != "" && target != "" {
+				// The TLLA is the target's: a spoofer sends from its own
+				// address with the victim's as the target.
+				bound = l.peerAddrString(target, link)
 			}
 		}
+		if mac == "" && !r.src.IsUnspecified() {
+			mac = l.frameMAC
+		}
+		if mac != "" {
+			l.checkBinding(srcIP, bound, mac, r.port)
+			l.cfg.Stats.RecordMAC(srcIP, mac)
+		}
+		ev.MAC = mac
 
-		// Record to stats if configured, otherwise log
-		if l.cfg.Stats != nil {
-			l.cfg.Stats.RecordMessage(srcIP, ndpKind)
-			if cm != nil {
-				if cm.HopLimit != 0 {
-					l.cfg.Stats.RecordHopLimit(srcIP, cm.HopLimit)
+		// Parse Router Advertisement details
+		if ndpKind == "router_advertisement" {
+			linkMTU := 0
+			if ifi != nil {
+				linkMTU = ifi.MTU
+			}
+			if ri := parseRA(buf, srcIP, mac, r.hopLimit, ifName); ri != nil {
+				ri.Port = r.port
+				ri.LastSeen = ev.Time
+				l.checkRogueRA(*ri, r.port)
+				l.cfg.Stats.RecordRouter(*ri)
+				l.checkRDNSS(*ri, r.port)
+				l.cfg.Stats.RecordRSResponse(srcIP, ev.Time)
+				ev.Router = ri
+				for _, a := range raSizeAlerts(srcIP, n, linkMTU, ri.MTU) {
+					a.Port = r.port
+					l.raiseAlertOnce(a.Category+"|"+srcIP, a)
 				}
-				if cm.IfIndex != 0 {
-					if ifi, e := net.InterfaceByIndex(cm.IfIndex); e == nil {
-						l.cfg.Stats.RecordInterface(srcIP, ifi.Name)
-					}
+				if a, ok := raMTUAlert(srcIP, ifName, linkMTU, ri.MTU); ok {
+					a.Port = r.port
+					l.raiseAlertOnce(a.Category+"|"+srcIP, a)
 				}
 			}
+		}
 
-			// Extract link-layer (MAC) address from NDP options
-			var mac string
-			switch ndpKind {
-			case "router_solicitation", "router_advertisement", "neighbor_solicitation":
-				mac = parseLinkLayerAddr(buf[:n], 1) // Source Link-Layer Address
-			case "neighbor_advertisement":
-				mac = parseLinkLayerAddr(buf[:n], 2) // Target Link-Layer Address
-			}
-			if mac != "" {
-				l.cfg.Stats.RecordMAC(srcIP, mac)
+		// MLD must carry a Hop-by-Hop Router Alert; only visible at packet level
+		if r.packetLevel && strings.HasPrefix(ndpKind, "mld_") {
+			ev.RouterAlert = "ok"
+			if reason := checkMLDRouterAlert(r.hbh); reason != "" {
+				ev.RouterAlert = reason
+				l.cfg.Stats.RecordMLDRouterAlertViolation(srcIP, reason)
+				l.raiseAlertOnce("mld_no_router_alert|"+srcIP, Alert{
+					Severity: SeverityWarning,
+					Category: "mld_no_router_alert",
+					Source:   srcIP,
+					Message:  fmt.Sprintf("%s without a valid Router Alert option (%s)", ndpKind, reason),
+				})
 			}
+		}
 
-			// Parse Router Advertisement details
-			if ndpKind == "router_advertisement" {
-				ifName := ""
-				if cm != nil && cm.IfIndex != 0 {
-					if ifi, e := net.InterfaceByIndex(cm.IfIndex); e == nil {
-						ifName = ifi.Name
-					}
+		// Solicited targets vs. the NAs defending them; DAD probes are
+		// tracked as DAD transactions instead
+		switch ndpKind {
+		case "neighbor_solicitation":
+			ev.Target = l.peerAddrString(parseNDTarget(buf), link)
+			if ev.Target != "" && srcIP == "::" {
+				l.cfg.Stats.RecordDADProbe(ev.Target, l.frameMAC, ev.Time)
+			} else if ev.Target != "" {
+				l.cfg.Stats.RecordNSTarget(srcIP, ev.Target, ev.Time)
+				if r.dst.IsValid() && !r.dst.IsMulticast() {
+					l.checkNUD(srcIP, ev.Target, ev.Time, r.port)
 				}
-				hopLim := 0
-				if cm != nil {
-					hopLim = cm.HopLimit
+			}
+		case "neighbor_advertisement":
+			ev.Target = l.peerAddrString(parseNDTarget(buf), link)
+			if ev.Target != "" {
+				l.cfg.Stats.RecordNATarget(srcIP, ev.Target, ev.Time)
+				l.checkNAOwnership(srcIP, ev.Target, mac)
+				if l.cfg.Stats.RecordRouterProbeAnswer(srcIP, ev.Target, ev.Time) {
+					l.raiseAlert(Alert{
+						Severity: SeverityInfo,
+						Category: "router_reachable",
+						Source:   srcIP,
+						Message:  fmt.Sprintf("router %s answers neighbor solicitations again", srcIP),
+					})
 				}
-				if ri := parseRA(buf[:n], srcIP, mac, hopLim, ifName); ri != nil {
-					l.cfg.Stats.RecordRouter(*ri)
+				if l.cfg.Stats.RecordDADResponse(srcIP, ev.Target, ev.Time) {
+					l.raiseAlert(Alert{
+						Severity: SeverityWarning,
+						Category: "dad_duplicate",
+						Source:   srcIP,
+						Message:  fmt.Sprintf("duplicate address detected: %s is already in use by %s", ev.Target, srcIP),
+						Port:     r.port,
+					})
 				}
+				l.checkDADConflict(srcIP, ev.Target, mac, ev.Time, r.port)
 			}
+		}
+
+		// Remember queries so the reports answering them can be timed
+		if ndpKind == "mld_query" {
+			if group, maxDelay, ok := parseMLDQuery(buf); ok {
+				l.cfg.Stats.RecordMLDQuery(group, maxDelay, ev.Time)
+			}
+		}
 
-			// Extract multicast group addresses from MLD reports/done
-			if ndpKind == "mld_report" || ndpKind == "mld_done" {
-				for _, group := range parseMLDGroups(buf[:n]) {
-					l.cfg.Stats.RecordMLDMembership(srcIP, group)
+		// Extract multicast group addresses from MLD reports/done
+		if ndpKind == "mld_report" || ndpKind == "mld_done" {
+			ev.Groups = parseMLDGroups(buf)
+			ev.MLDRecords = parseMLDv2Records(buf) // one per group, in order
+			for i, group := range ev.Groups {
+				// A record leaving the group is handled like a Done.
+				done := ndpKind == "mld_done" || (ev.MLDRecords != nil && ev.MLDRecords[i].Leave())
+				if done {
+					l.cfg.Stats.RecordMLDDone(srcIP, group, ev.Time)
+				}
+				l.cfg.Stats.RecordMLDMembership(srcIP, group)
+				if !done {
+					l.cfg.Stats.RecordMLDReport(srcIP, group, ev.Time)
+					l.cfg.Stats.RecordMLDResponse(srcIP, group, ev.Time)
 				}
 			}
-		} else {
-			l.cfg.Logger.Info("ndp event", fields...)
 		}
+	} else {
+		l.cfg.Logger.Info("ndp event", fields...)
 	}
-}
 
-func ipFromAddr(a net.Addr) string {
-	switch v := a.(type) {
-	case *net.IPAddr:
-		return v.IP.String()
-	case *net.UDPAddr:
-		return v.IP.String()
-	default:
-		if a == nil {
-			return ""
+	if l.cfg.Stats != nil && len(l.cfg.Sinks) > 0 {
+		ev.Peer = l.cfg.Stats.enrichmentFor(srcIP)
+	}
+	for _, s := range l.cfg.Sinks {
+		if err := s.WriteEvent(ev); err != nil {
+			l.cfg.Logger.Debug("sink write failed", "err", err)
 		}
-		return a.String()
 	}
 }
 
+// checkNUD records a unicast NS as a NUD probe and raises nud_broken or
+// nud_aggressive, once per window, when the sender's timers are off.
+func (l *NDPListener) checkNUD(srcIP, target string, now time.Time, port string) {
+	b := l.cfg.Stats.RecordNUDProbe(srcIP, target, now)
+	if b.Verdict == "" {
+		return
+	}
+	category := "nud_" + b.Verdict
+	l.raiseAlertOnce(category+"|"+srcIP, Alert{
+		Severity: SeverityWarning,
+		Category: category,
+		Source:   srcIP,
+		Message:  fmt.Sprintf("%s NUD: %s", b.Verdict, b.Reason),
+		Port:     port,
+	})
+}
+
+// checkDuplicate counts r against its capture interface (and member port)
+// and raises duplicate_packets once copies pile up there. Copies of
+// multicast within milliseconds are the signature of a switching loop or a
+// bridge forwarding back onto a segment. It reports whether r is a copy to
+// drop (see NDPStats.SetDedup).
+func (l *NDPListener) checkDuplicate(r received, ifName string, now time.Time) bool {
+	iface := ifName
+	if ifName != "" && r.port != "" {
+		iface += "/" + r.port
+	} else if r.port != "" {
+		iface = r.port
+	}
+	if !l.replayAt.IsZero() {
+		now = l.replayAt
+	}
+	dup, recent := l.cfg.Stats.RecordArrival(iface, messageHash(r.src, r.dst, r.payload), now)
+	if !dup {
+		return false
+	}
+	drop := l.cfg.Stats.dropDuplicate()
+	if recent < duplicateAlertMin {
+		return drop
+	}
+	name := iface
+	if name == "" {
+		name = "an unknown interface"
+	}
+	l.raiseAlertOnce("duplicate_packets|"+iface, Alert{
+		Severity: SeverityWarning,
+		Category: "duplicate_packets",
+		Message:  fmt.Sprintf("%d duplicate NDP/MLD packets on %s within %s; switching loop or misconfigured bridge?", recent, name, formatDuration(l.cfg.Stats.Window())),
+		Port:     r.port,
+	})
+	return drop
+}
+
+// peerAddr zones a peer address seen on link: link-local addresses always,
+// others too with ScopeByInterface. The unspecified address never is.
+func (l *NDPListener) peerAddr(a netip.Addr, link string) netip.Addr {
+	a = scopedAddr(a, link)
+	if l.cfg.ScopeByInterface && link != "" && a.Zone() == "" && !a.IsUnspecified() {
+		a = a.WithZone(link)
+	}
+	return a
+}
+
+// peerAddrString is peerAddr for an address parsed from a message body.
+// Unparsable input is returned unchanged.
+func (l *NDPListener) peerAddrString(s, link string) string {
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return s
+	}
+	return l.peerAddr(a, link).String()
+}
+
+// raiseAlert records an alert in stats (if configured), logs it at WARN
+// level and passes it to the sinks.
+func (l *NDPListener) raiseAlert(a Alert) {
+	emitAlert(l.cfg.Stats, l.cfg.Logger, l.cfg.Sinks, a)
+}
+
+// now returns the time by the stats' clock (see NDPStats.SetClock).
+func (l *NDPListener) now() time.Time {
+	if l.cfg.Stats == nil {
+		return time.Now()
+	}
+	return l.cfg.Stats.now()
+}
+
+// raiseAlertOnce is like raiseAlert but suppresses repeats of the same key
+// within the stats window, so a misbehaving router that keeps sending the
+// same bad RA produces one alert per window rather than one per packet.
+func (l *NDPListener) raiseAlertOnce(key string, a Alert) {
+	if l.cfg.Stats != nil && !l.cfg.Stats.alertDue(key, l.now()) {
+		return
+	}
+	l.raiseAlert(a)
+}
+
 // classifyICMPv6 maps ICMPv6 message types to internal kind strings.
 //
 // NDP (Neighbor Discovery Protocol):
@@ -290,35 +797,39 @@ func ndpOptionsOffset(icmpType byte) int {
 // optionType: 1 = Source Link-Layer Address, 2 = Target Link-Layer Address.
 // Returns "" if the option is not found or the packet is malformed.
 func parseLinkLayerAddr(buf []byte, optionType byte) string {
-	if len(buf) < 1 {
-		return ""
-	}
-	offset := ndpOptionsOffset(buf[0])
-	if offset < 0 || len(buf) < offset {
-		return ""
+	for oType, opt := range ndpOptions(buf) {
+		if oType == optionType && len(opt) >= 8 {
+			// Bytes 2-7 of the option are the 6-byte Ethernet MAC address
+			return net.HardwareAddr(opt[2:8]).String()
+		}
 	}
+	return ""
+}
 
-	// Walk the TLV option chain
-	for offset+2 <= len(buf) {
-		oType := buf[offset]
-		oLen := int(buf[offset+1]) * 8 // Length field is in 8-byte units
-
-		if oLen == 0 {
-			break // malformed option; avoid infinite loop
+// ndpOptions iterates over the TLV option chain of an NDP message, yielding
+// each option's type and its bytes (type and length included). buf is the
+// full ICMPv6 message; types without options yield nothing. The walk stops
+// at a zero-length or truncated option.
+func ndpOptions(buf []byte) iter.Seq2[byte, []byte] {
+	return func(yield func(byte, []byte) bool) {
+		if len(buf) < 1 {
+			return
 		}
-		if offset+oLen > len(buf) {
-			break // truncated
+		offset := ndpOptionsOffset(buf[0])
+		if offset < 0 {
+			return
 		}
-
-		if oType == optionType && oLen >= 8 {
-			// Bytes 2-7 of the option are the 6-byte Ethernet MAC address
-			mac := net.HardwareAddr(buf[offset+2 : offset+8])
-			return mac.String()
+		for offset+2 <= len(buf) {
+			oLen := int(buf[offset+1]) * 8 // Length field is in 8-byte units
+			if oLen == 0 || offset+oLen > len(buf) {
+				return // malformed or truncated; avoid an infinite loop
+			}
+			if !yield(buf[offset], buf[offset:offset+oLen]) {
+				return
+			}
+			offset += oLen
 		}
-
-		offset += oLen
 	}
-	return ""
 }
 
 // parseMLDGroups extracts multicast group addresses from a raw ICMPv6 packet.
@@ -351,7 +862,7 @@ func parseMLDv1Groups(buf []byte) []string {
 	if len(buf) < 24 {
 		return nil
 	}
-	group := net.IP(buf[8:24])
+	group := netip.AddrFrom16([16]byte(buf[8:24]))
 	if group.IsUnspecified() {
 		return nil
 	}
@@ -379,7 +890,8 @@ func parseMLDv1Groups(buf []byte) []string {
 // RA header layout (after 4-byte ICMPv6 header):
 //
 //	Byte 4:   Cur Hop Limit
-//	Byte 5:   Flags — bit 7 = M (managed), bit 6 = O (other config)
+//	Byte 5:   Flags — bit 7 = M (managed), bit 6 = O (other config),
+//	          bits 4-3 = default router preference (RFC 4191)
 //	Bytes 6-7: Router Lifetime (seconds, big-endian)
 //
 // RA options start at byte 16 (TLV chain).
@@ -400,6 +912,7 @@ func parseRA(buf []byte, srcIP, mac string, hopLimit int, ifName string) *Router
 	ri.HopLimit = int(buf[4])
 	ri.Managed = buf[5]&0x80 != 0
 	ri.Other = buf[5]&0x40 != 0
+	ri.Preference = int((buf[5] >> 3) & 0x03)
 	ri.Lifetime = time.Duration(binary.BigEndian.Uint16(buf[6:8])) * time.Second
 
 	// If the IPv6 hop limit from the control message is available and the RA
@@ -409,37 +922,30 @@ func parseRA(buf []byte, srcIP, mac string, hopLimit int, ifName string) *Router
 	}
 
 	// Walk RA options (TLV chain starting at byte 16)
-	offset := 16
-	for offset+2 <= len(buf) {
-		oType := buf[offset]
-		oLen := int(buf[offset+1]) * 8 // length in 8-byte units
-		if oLen == 0 {
-			break
-		}
-		if offset+oLen > len(buf) {
-			break
-		}
-
+	for oType, opt := range ndpOptions(buf) {
+		oLen := len(opt)
 		switch oType {
 		case 3: // Prefix Information (32 bytes)
 			if oLen >= 32 {
-				parseRAPrefixInfo(buf[offset:offset+oLen], ri)
+				parseRAPrefixInfo(opt, ri)
 			}
 		case 5: // MTU
 			if oLen >= 8 {
-				ri.MTU = binary.BigEndian.Uint32(buf[offset+4 : offset+8])
+				ri.MTU = binary.BigEndian.Uint32(opt[4:8])
 			}
 		case 24: // Route Information (RFC 4191)
 			if oLen >= 8 {
-				parseRARouteInfo(buf[offset:offset+oLen], oLen, ri)
+				parseRARouteInfo(opt, oLen, ri)
 			}
 		case 25: // RDNSS (RFC 6106)
 			if oLen >= 24 {
-				parseRARDNSS(buf[offset:offset+oLen], oLen, ri)
+				parseRARDNSS(opt, oLen, ri)
+			}
+		case 31: // DNSSL (RFC 8106)
+			if oLen >= 16 {
+				ri.DNSSL = append(ri.DNSSL, parseDNSSLNames(opt[8:])...)
 			}
 		}
-
-		offset += oLen
 	}
 
 	return ri
@@ -458,7 +964,7 @@ func parseRAPrefixInfo(opt []byte, ri *RouterInfo) {
 	autonomous := opt[3]&0x40 != 0
 	validLife := time.Duration(binary.BigEndian.Uint32(opt[4:8])) * time.Second
 	prefLife := time.Duration(binary.BigEndian.Uint32(opt[8:12])) * time.Second
-	prefix := net.IP(opt[16:32])
+	prefix := ip16String(opt[16:32])
 
 	ri.Prefixes = append(ri.Prefixes, PrefixInfo{
 		Prefix:        fmt.Sprintf("%s/%d", prefix, prefixLen),
@@ -481,7 +987,7 @@ func parseRARouteInfo(opt []byte, oLen int, ri *RouterInfo) {
 	lifetime := time.Duration(binary.BigEndian.Uint32(opt[4:8])) * time.Second
 
 	// Prefix bytes: remaining option bytes after the 8-byte header, up to 16 bytes
-	prefixBytes := make(net.IP, 16)
+	prefixBytes := make([]byte, 16)
 	copyLen := oLen - 8
 	if copyLen > 16 {
 		copyLen = 16
@@ -491,7 +997,7 @@ func parseRARouteInfo(opt []byte, oLen int, ri *RouterInfo) {
 	}
 
 	ri.Routes = append(ri.Routes, RouteInfo{
-		Prefix:     fmt.Sprintf("%s/%d", prefixBytes, prefixLen),
+		Prefix:     fmt.Sprintf("%s/%d", ip16String(prefixBytes), prefixLen),
 		PrefixLen:  prefixLen,
 		Preference: pref,
 		Lifetime:   lifetime,
@@ -505,22 +1011,107 @@ func parseRARouteInfo(opt []byte, oLen int, ri *RouterInfo) {
 func parseRARDNSS(opt []byte, oLen int, ri *RouterInfo) {
 	// Each address is 16 bytes, starting at offset 8
 	for off := 8; off+16 <= oLen && off+16 <= len(opt); off += 16 {
-		addr := net.IP(opt[off : off+16])
-		ri.RDNSS = append(ri.RDNSS, addr.String())
+		ri.RDNSS = append(ri.RDNSS, ip16String(opt[off:off+16]))
+	}
+}
+
+// parseDNSSLNames decodes the domain names of a DNSSL option (RFC 8106),
+// given the option bytes after its 8-byte header. Names are sequences of
+// length-prefixed labels ending with an empty one (RFC 1035, without
+// compression); zero bytes pad the option. A malformed name ends the list.
+// Names with a label that isn't a hostname label are skipped: any host on
+// the link could otherwise put terminal escapes into the router view.
+func parseDNSSLNames(b []byte) []string {
+	var names []string
+	var labels []string
+	bad := false
+	for i := 0; i < len(b); {
+		n := int(b[i])
+		i++
+		switch {
+		case n == 0:
+			if len(labels) == 0 && !bad {
+				continue // padding
+			}
+			if !bad {
+				names = append(names, strings.Join(labels, "."))
+			}
+			labels, bad = labels[:0], false
+		case n > 63 || i+n > len(b):
+			return names
+		default:
+			if hostnameLabel(b[i : i+n]) {
+				labels = append(labels, string(b[i:i+n]))
+			} else {
+				bad = true
+			}
+			i += n
+		}
 	}
+	return names
+}
+
+// hostnameLabel reports whether l is a DNS label made of letters, digits,
+// hyphens and underscores, not starting or ending with a hyphen.
+func hostnameLabel(l []byte) bool {
+	if len(l) == 0 || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+		return false
+	}
+	for _, c := range l {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
 }
 
 func parseMLDv2Groups(buf []byte) []string {
+	var groups []string
+	for _, r := range parseMLDv2Records(buf) {
+		groups = append(groups, r.Group)
+	}
+	return groups
+}
+
+// MLDRecord is one multicast address record of an MLDv2 Report.
+type MLDRecord struct {
+	// Type is is_in, is_ex (current state), to_in, to_ex (filter mode
+	// change), allow or block (source list change), or the number of an
+	// unknown type.
+	Type    string   `json:"type"`
+	Group   string   `json:"group"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+// mldRecordTypes names the MLDv2 record types (RFC 3810 section 5.2.12).
+var mldRecordTypes = map[byte]string{
+	craft.ModeIsInclude:       "is_in",
+	craft.ModeIsExclude:       "is_ex",
+	craft.ChangeToIncludeMode: "to_in",
+	craft.ChangeToExcludeMode: "to_ex",
+	craft.AllowNewSources:     "allow",
+	craft.BlockOldSources:     "block",
+}
+
+// Leave reports whether the record says the host stopped listening to the
+// group: INCLUDE mode with no sources, the MLDv2 equivalent of a Done.
+func (r MLDRecord) Leave() bool {
+	return (r.Type == "to_in" || r.Type == "is_in") && len(r.Sources) == 0
+}
+
+// parseMLDv2Records decodes the multicast address records of an MLDv2
+// Report (143), skipping those for the unspecified address. A truncated
+// record ends the list; sources past the end of buf are dropped.
+func parseMLDv2Records(buf []byte) []MLDRecord {
 	// Need at least: 4 (ICMPv6 header) + 4 (reserved + count) = 8
-	if len(buf) < 8 {
+	if len(buf) < 8 || buf[0] != 143 {
 		return nil
 	}
 	numRecords := int(binary.BigEndian.Uint16(buf[6:8]))
-	if numRecords == 0 {
-		return nil
-	}
 
-	var groups []string
+	var records []MLDRecord
 	offset := 8 // start of first record
 	for i := 0; i < numRecords; i++ {
 		// Each record needs at least 20 bytes (4 header + 16 group addr)
@@ -529,12 +1120,19 @@ func parseMLDv2Groups(buf []byte) []string {
 		}
 		auxDataLen := int(buf[offset+1])
 		numSources := int(binary.BigEndian.Uint16(buf[offset+2 : offset+4]))
-		group := net.IP(buf[offset+4 : offset+20])
+		group := netip.AddrFrom16([16]byte(buf[offset+4 : offset+20]))
 		if !group.IsUnspecified() {
-			groups = append(groups, group.String())
+			r := MLDRecord{Type: mldRecordTypes[buf[offset]], Group: group.String()}
+			if r.Type == "" {
+				r.Type = strconv.Itoa(int(buf[offset]))
+			}
+			for s := offset + 20; s+16 <= len(buf) && len(r.Sources) < numSources; s += 16 {
+				r.Sources = append(r.Sources, ip16String(buf[s:s+16]))
+			}
+			records = append(records, r)
 		}
 		// Advance: 20 (fixed) + sources*16 + auxData*4
 		offset += 20 + numSources*16 + auxDataLen*4
 	}
-	return groups
+	return records
 }
@@ -1,8 +1,14 @@
 package lib
 
 import (
+	"NDPeekr/lib/craft"
 	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"net/netip"
+	"slices"
 	"testing"
 	"time"
 
@@ -72,19 +78,27 @@ func TestClassifyICMPv6_NonNDPTypesReturnEmpty(t *testing.T) {
 	}
 }
 
-func TestIPFromAddr_IPAddr(t *testing.T) {
+func TestAddrFromNet_IPAddr(t *testing.T) {
 	a := &net.IPAddr{IP: net.ParseIP("fe80::1")}
-	got := ipFromAddr(a)
-	if got != "fe80::1" {
-		t.Fatalf("ipFromAddr(IPAddr) = %q, want %q", got, "fe80::1")
+	got := addrFromNet(a)
+	if got.String() != "fe80::1" {
+		t.Fatalf("addrFromNet(IPAddr) = %q, want %q", got, "fe80::1")
 	}
 }
 
-func TestIPFromAddr_UDPAddr(t *testing.T) {
-	a := &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 1234}
-	got := ipFromAddr(a)
-	if got != "2001:db8::2" {
-		t.Fatalf("ipFromAddr(UDPAddr) = %q, want %q", got, "2001:db8::2")
+func TestAddrFromNet_KeepsLinkLocalZone(t *testing.T) {
+	a := &net.IPAddr{IP: net.ParseIP("fe80::1"), Zone: "eth1"}
+	got := addrFromNet(a)
+	if got.String() != "fe80::1%eth1" {
+		t.Fatalf("addrFromNet(IPAddr) = %q, want %q", got, "fe80::1%eth1")
+	}
+}
+
+func TestAddrFromNet_UDPAddr(t *testing.T) {
+	a := &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 1234, Zone: "eth0"}
+	got := addrFromNet(a)
+	if got.String() != "2001:db8::2" {
+		t.Fatalf("addrFromNet(UDPAddr) = %q, want %q (global addresses carry no zone)", got, "2001:db8::2")
 	}
 }
 
@@ -93,18 +107,15 @@ type dummyAddr string
 func (d dummyAddr) Network() string { return "dummy" }
 func (d dummyAddr) String() string  { return string(d) }
 
-func TestIPFromAddr_UnknownAddrUsesString(t *testing.T) {
-	a := dummyAddr("weird://addr")
-	got := ipFromAddr(a)
-	if got != "weird://addr" {
-		t.Fatalf("ipFromAddr(dummy) = %q, want %q", got, "weird://addr")
+func TestAddrFromNet_UnknownAddr(t *testing.T) {
+	if got := addrFromNet(dummyAddr("weird://addr")); got.IsValid() {
+		t.Fatalf("addrFromNet(dummy) = %q, want invalid", got)
 	}
 }
 
-func TestIPFromAddr_Nil(t *testing.T) {
-	got := ipFromAddr(nil)
-	if got != "" {
-		t.Fatalf("ipFromAddr(nil) = %q, want empty string", got)
+func TestAddrFromNet_Nil(t *testing.T) {
+	if got := addrFromNet(nil); got.IsValid() {
+		t.Fatalf("addrFromNet(nil) = %q, want invalid", got)
 	}
 }
 
@@ -113,38 +124,18 @@ func TestIPFromAddr_Nil(t *testing.T) {
 // buildNS constructs a raw NS (type 135) packet with a Source Link-Layer Address option.
 // Layout: type(1) + code(1) + checksum(2) + reserved(4) + target(16) + option(8) = 32 bytes
 func buildNS(targetIP net.IP, srcMAC net.HardwareAddr) []byte {
-	buf := make([]byte, 32)
-	buf[0] = 135 // NS
-	copy(buf[8:24], targetIP.To16())
-	// Source Link-Layer Address option (type=1, len=1 → 8 bytes)
-	buf[24] = 1 // option type
-	buf[25] = 1 // length in 8-byte units
-	copy(buf[26:32], srcMAC)
-	return buf
+	return craft.BuildNS(craft.NS{Target: targetIP, SourceMAC: srcMAC})
 }
 
 // buildNA constructs a raw NA (type 136) packet with a Target Link-Layer Address option.
 func buildNA(targetIP net.IP, targetMAC net.HardwareAddr) []byte {
-	buf := make([]byte, 32)
-	buf[0] = 136 // NA
-	buf[4] = 0xe0 // R+S+O flags
-	copy(buf[8:24], targetIP.To16())
-	// Target Link-Layer Address option (type=2, len=1 → 8 bytes)
-	buf[24] = 2
-	buf[25] = 1
-	copy(buf[26:32], targetMAC)
-	return buf
+	return craft.BuildNA(craft.NA{Target: targetIP, TargetMAC: targetMAC, Router: true, Solicited: true, Override: true})
 }
 
 // buildRS constructs a raw RS (type 133) packet with a Source Link-Layer Address option.
 // Layout: type(1) + code(1) + checksum(2) + reserved(4) + option(8) = 16 bytes
 func buildRS(srcMAC net.HardwareAddr) []byte {
-	buf := make([]byte, 16)
-	buf[0] = 133 // RS
-	buf[8] = 1   // option type (Source LLA)
-	buf[9] = 1   // length
-	copy(buf[10:16], srcMAC)
-	return buf
+	return craft.BuildRS(craft.RS{SourceMAC: srcMAC})
 }
 
 // buildRA constructs a raw RA (type 134) packet with a Source Link-Layer Address option.
@@ -152,14 +143,7 @@ func buildRS(srcMAC net.HardwareAddr) []byte {
 //
 //	reachable(4) + retrans(4) + option(8) = 24 bytes
 func buildRA(srcMAC net.HardwareAddr) []byte {
-	buf := make([]byte, 24)
-	buf[0] = 134 // RA
-	buf[4] = 64  // cur hop limit
-	// Source Link-Layer Address option starts at offset 16
-	buf[16] = 1
-	buf[17] = 1
-	copy(buf[18:24], srcMAC)
-	return buf
+	return craft.BuildRA(craft.RA{CurHopLimit: 64, SourceMAC: srcMAC})
 }
 
 func TestParseLinkLayerAddr_NS(t *testing.T) {
@@ -261,6 +245,34 @@ func TestParseLinkLayerAddr_MultipleOptions(t *testing.T) {
 	}
 }
 
+func TestNDPOptions_Malformed(t *testing.T) {
+	// RS with an SLLA option, then a zero-length option and a trailing SLLA
+	// that must never be reached.
+	buf := make([]byte, 8+8+8+8)
+	buf[0] = 133
+	buf[8], buf[9] = 1, 1
+	buf[16], buf[17] = 14, 0
+	buf[24], buf[25] = 1, 1
+
+	var types []byte
+	for oType, opt := range ndpOptions(buf) {
+		if len(opt) != 8 {
+			t.Errorf("option %d: %d bytes, want 8", oType, len(opt))
+		}
+		types = append(types, oType)
+	}
+	if len(types) != 1 || types[0] != 1 {
+		t.Errorf("options = %v, want only the first SLLA", types)
+	}
+
+	// An option running past the end stops the walk too.
+	buf[17] = 4
+	buf[16] = 1
+	if got := ndpOptionTypes(buf); len(got) != 1 {
+		t.Errorf("truncated chain: types = %v, want [1]", got)
+	}
+}
+
 func TestNdpOptionsOffset(t *testing.T) {
 	cases := []struct {
 		icmpType byte
@@ -285,10 +297,7 @@ func TestNdpOptionsOffset(t *testing.T) {
 // buildMLDv1Report constructs a raw MLDv1 Report (type 131) packet.
 // Layout: type(1) + code(1) + checksum(2) + maxResponseDelay(2) + reserved(2) + multicastAddr(16) = 24 bytes
 func buildMLDv1Report(group net.IP) []byte {
-	buf := make([]byte, 24)
-	buf[0] = 131 // MLDv1 Report
-	copy(buf[8:24], group.To16())
-	return buf
+	return craft.BuildMLDv1Report(group)
 }
 
 // buildMLDv1Done constructs a raw MLDv1 Done (type 132) packet.
@@ -302,17 +311,11 @@ func buildMLDv1Done(group net.IP) []byte {
 // buildMLDv2Report constructs a raw MLDv2 Report (type 143) with the given groups.
 // Each record: recordType(1) + auxDataLen(1) + numSources(2) + multicastAddr(16) = 20 bytes
 func buildMLDv2Report(groups []net.IP) []byte {
-	numRecords := len(groups)
-	buf := make([]byte, 8+numRecords*20) // header(8) + records
-	buf[0] = 143                         // MLDv2 Report
-	buf[6] = byte(numRecords >> 8)
-	buf[7] = byte(numRecords)
+	records := make([]craft.MLDRecord, len(groups))
 	for i, group := range groups {
-		offset := 8 + i*20
-		buf[offset] = 4 // Record Type: CHANGE_TO_EXCLUDE (join)
-		copy(buf[offset+4:offset+20], group.To16())
+		records[i] = craft.MLDRecord{Type: craft.ChangeToExcludeMode, Group: group} // join
 	}
-	return buf
+	return craft.BuildMLDv2Report(records...)
 }
 
 func TestParseMLDGroups_MLDv1Report(t *testing.T) {
@@ -378,6 +381,56 @@ func TestParseMLDGroups_MLDv2EmptyReport(t *testing.T) {
 	}
 }
 
+func TestParseMLDv2Records(t *testing.T) {
+	src := net.ParseIP("2001:db8::5")
+	buf := craft.BuildMLDv2Report(
+		craft.MLDRecord{Type: craft.ChangeToExcludeMode, Group: net.ParseIP("ff02::fb")},
+		craft.MLDRecord{Type: craft.AllowNewSources, Group: net.ParseIP("ff3e::1"), Sources: []net.IP{src}},
+		craft.MLDRecord{Type: craft.ChangeToIncludeMode, Group: net.ParseIP("ff02::1:3")},
+		craft.MLDRecord{Type: 9, Group: net.ParseIP("ff02::c")},
+	)
+	got := parseMLDv2Records(buf)
+	want := []MLDRecord{
+		{Type: "to_ex", Group: "ff02::fb"},
+		{Type: "allow", Group: "ff3e::1", Sources: []string{"2001:db8::5"}},
+		{Type: "to_in", Group: "ff02::1:3"},
+		{Type: "9", Group: "ff02::c"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("records = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type || got[i].Group != want[i].Group || !slices.Equal(got[i].Sources, want[i].Sources) {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got[0].Leave() || got[1].Leave() || !got[2].Leave() {
+		t.Error("only TO_IN({}) should leave")
+	}
+	if parseMLDv2Records(buildMLDv1Report(net.ParseIP("ff02::fb"))) != nil {
+		t.Error("MLDv1 report decoded as MLDv2")
+	}
+}
+
+func TestHandle_MLDv2Leave(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	rec := &recordingSink{}
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+		Sinks:  []Sink{rec},
+	})
+	leave := craft.BuildMLDv2Report(craft.MLDRecord{Type: craft.ChangeToIncludeMode, Group: net.ParseIP("ff02::fb")})
+	l.handle(received{src: netip.MustParseAddr("fe80::1"), payload: leave})
+
+	if _, ok := stats.mldReported["fe80::1|ff02::fb"]; ok {
+		t.Error("a leave record counted as a report")
+	}
+	if len(rec.events) != 1 || len(rec.events[0].MLDRecords) != 1 || rec.events[0].MLDRecords[0].Type != "to_in" {
+		t.Errorf("events = %+v, want one with the to_in record", rec.events)
+	}
+}
+
 func TestParseMLDGroups_NonMLDType(t *testing.T) {
 	buf := []byte{133, 0, 0, 0, 0, 0, 0, 0} // RS type
 	got := parseMLDGroups(buf)
@@ -605,6 +658,34 @@ func TestParseRA_RDNSS(t *testing.T) {
 	}
 }
 
+func TestParseRA_DNSSLAndPreference(t *testing.T) {
+	buf := craft.BuildRA(craft.RA{
+		CurHopLimit:    64,
+		Preference:     -1,
+		RouterLifetime: 30 * time.Minute,
+		DNSSL:          []string{"corp.example.com", "example.com."},
+	})
+
+	ri := parseRA(buf, "fe80::1", "", 0, "")
+	if ri == nil {
+		t.Fatal("parseRA returned nil")
+	}
+	if ri.Preference != 3 {
+		t.Errorf("Preference = %d, want 3 (low)", ri.Preference)
+	}
+	if want := []string{"corp.example.com", "example.com"}; !slices.Equal(ri.DNSSL, want) {
+		t.Errorf("DNSSL = %q, want %q", ri.DNSSL, want)
+	}
+
+	// A label running past the option ends the list without panicking.
+	if got := parseDNSSLNames([]byte{4, 'c', 'o', 'r', 'p', 0, 9, 'x'}); !slices.Equal(got, []string{"corp"}) {
+		t.Errorf("truncated DNSSL = %q", got)
+	}
+	if got := parseDNSSLNames([]byte{4, 'e', 'v', 'i', 'l', 3, '\x1b', '[', '2', 0, 3, 'l', 'a', 'n', 0}); !slices.Equal(got, []string{"lan"}) {
+		t.Errorf("DNSSL with escapes = %q, want only lan", got)
+	}
+}
+
 func TestParseRA_RouteInfo(t *testing.T) {
 	prefix := net.ParseIP("2001:db8:1::")
 	routeOpt := buildRouteInfoOption(prefix, 48, 1, 7200) // high preference
@@ -699,3 +780,63 @@ func TestParseRA_NoOptions(t *testing.T) {
 		t.Errorf("Prefixes = %d, want 0", len(ri.Prefixes))
 	}
 }
+
+// --- Benchmarks ---
+
+func BenchmarkParseLinkLayerAddr(b *testing.B) {
+	ns := buildNS(net.ParseIP("2001:db8::1"), net.HardwareAddr{0x02, 0, 0, 0, 0, 1})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parseLinkLayerAddr(ns, 1)
+	}
+}
+
+func BenchmarkParseRA(b *testing.B) {
+	_, prefix, _ := net.ParseCIDR("2001:db8::/64")
+	ra := craft.BuildRA(craft.RA{
+		CurHopLimit:    64,
+		RouterLifetime: 30 * time.Minute,
+		SourceMAC:      net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		MTU:            1500,
+		Prefixes:       []craft.Prefix{{Prefix: *prefix, OnLink: true, Autonomous: true, ValidLifetime: time.Hour, PreferredLifetime: time.Hour}},
+		RDNSS:          []net.IP{net.ParseIP("2001:db8::53")},
+		RDNSSLifetime:  time.Hour,
+	})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parseRA(ra, "fe80::1", "02:00:00:00:00:01", 255, "eth0")
+	}
+}
+
+func BenchmarkParseMLDv2Groups(b *testing.B) {
+	groups := make([]net.IP, 10)
+	for i := range groups {
+		groups[i] = net.ParseIP(fmt.Sprintf("ff02::1:ff00:%x", i+1))
+	}
+	report := buildMLDv2Report(groups)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parseMLDGroups(report)
+	}
+}
+
+// BenchmarkHandlePacket is the whole packet-level path for one NS: decode,
+// checksum, classify and record, with 1000 peers tracked.
+func BenchmarkHandlePacket(b *testing.B) {
+	stats, _ := benchStats(1000)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	pkts := make([][]byte, 256)
+	for i := range pkts {
+		src := fmt.Sprintf("2001:db8::%x", i+1)
+		ns := buildNS(net.ParseIP("2001:db8::ffff"), net.HardwareAddr{0x02, 0, 0, 0, 0, byte(i)})
+		pkts[i] = buildIPv6Packet(src, "ff02::1:ff00:ffff", 255, nil, nhICMPv6, ns)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.handlePacket(pkts[i%len(pkts)], 0, "")
+	}
+}
@@ -1,48 +1,229 @@
 package lib
 
 import (
+	"net/netip"
+	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // NDPStats tracks all observed NDP peers and routers with thread-safe access
 type NDPStats struct {
 	mu      sync.RWMutex
-	peers   map[string]*PeerStats  // key: IPv6 address string
-	routers map[string]*RouterInfo // key: router link-local IPv6 address
-	window  time.Duration          // sliding window size (timeout)
+	peers   map[string]*PeerStats     // key: IPv6 address string, zoned if link-local (see addr.go)
+	routers map[string]*RouterInfo    // key: router link-local IPv6 address
+	window  time.Duration             // sliding window size (timeout)
+	grace   time.Duration             // how long quiet peers linger as stale before removal
+	sizes   map[string]*SizeHistogram // key: ndpKind
+	alerts  []Alert                   // oldest first, capped at maxAlerts
+	// alertKeys holds the last time each throttled alert key was raised.
+	alertKeys map[string]time.Time
+	// alertTotals counts every alert ever raised, by category and severity.
+	alertTotals map[alertTotalKey]int
+	// alertSeq counts every alert ever recorded, for AlertsSince.
+	alertSeq uint64
+	// enrich holds the latest Enricher results, keyed by peer address.
+	enrich map[string]Enrichment
+	// hostnames holds the Resolver's answers, keyed by peer address.
+	hostnames map[string]string
+	// clock is the time source, nil for the wall clock (see SetClock).
+	clock func() time.Time
+	// macClaims holds, per MAC, the addresses it claimed within
+	// bindingFloodWindow and when; bindingChanges the recent binding
+	// changes, oldest first (see RecordBinding).
+	macClaims      map[string]*macClaims
+	bindingChanges []BindingChange
+	// ignore hides peers matching any of these filters from every summary.
+	ignore []*Filter
+	// ignored and tags are what the TUI's batch actions set until exit:
+	// addresses hidden like ignore, and tags added to the inventory's.
+	ignored map[string]bool
+	tags    map[string][]string
+	// remembered holds pruned peers for the state database (see
+	// RestoreState); nil unless a state was restored.
+	remembered map[string]PersistedPeer
+	// maintenance holds the windows during which alerts are suppressed or
+	// downgraded; maintenanceSuppressed counts suppressed alerts by window.
+	maintenance           []*maintenanceWindow
+	maintenanceSuppressed map[string]int
+	// extAnomalies counts ICMPv6 packets with unexpected extension header
+	// chains since startup, keyed by reason (packet-level capture only).
+	extAnomalies map[string]int
+	// checksumFailures counts ICMPv6 messages dropped by packet-level backends
+	// because the checksum did not verify.
+	checksumFailures int
+	// routerAlertViolations counts MLD messages failing Router Alert validation, by reason.
+	routerAlertViolations map[string]int
+	// arrivals fingerprints the messages of the last duplicateWindow, and
+	// duplicates counts copies of them per capture interface.
+	arrivals   []duplicateArrival
+	duplicates map[string]*ifaceDuplicates
+
+	// mldReported holds the last report per "peer|group", groupJoined the
+	// first report per group, and groupTraffic the last packet to each
+	// multicast group (see GetMulticastSanity).
+	mldReported    map[string]time.Time
+	groupJoined    map[string]time.Time
+	groupTraffic   map[string]time.Time
+	trafficVisible bool
+	orphanDones    []OrphanDone
+	// goneRouters records routers that aged out, oldest first, capped at maxGoneRouters.
+	goneRouters []GoneRouter
+
+	// MLD query/response latency: the latest general and group-specific
+	// queries, the query time each peer|group last answered, and per-group
+	// latency totals.
+	mldGeneralQuery mldQuery
+	mldGroupQueries map[string]mldQuery
+	mldAnswered     map[string]time.Time
+	mldLatency      map[string]*groupLatency
+
+	// nsTargets tracks solicited target addresses, for comparison with
+	// solicited-node memberships and the NAs answering them.
+	nsTargets map[string]*nsTarget
+	// nudProbes holds the unicast NS each host sent each target, oldest
+	// first, by host and then target.
+	nudProbes map[string]map[string][]time.Time
+
+	// activity is the sleep/wake history per MAC; it outlives the peer entries.
+	activity map[string]*macActivity
+
+	// dad holds recent Duplicate Address Detection transactions, oldest
+	// first, capped at maxDADTransactions.
+	dad []*dadTransaction
+
+	// fhrp holds VRRP virtual routers and HSRP groups, keyed by fhrpKey.
+	fhrp map[string]*VirtualRouter
+
+	// rsSent is when we last sent a Router Solicitation (--solicit), and
+	// rsLatency how each router answered, keyed by router address.
+	rsSent    time.Time
+	rsLatency map[string]*rsLatency
+
+	// routerProbes tracks our unicast NS probes to routers (--probe-routers).
+	routerProbes map[string]*routerProbe
+
+	// switchNeighbors is the LLDP/CDP announced switch port per interface (--lldp).
+	switchNeighbors map[string]SwitchNeighbor
+
+	// status describes how this instance runs, for the status view.
+	status Status
+	// prunes describes the last Prune run by a Pruner.
+	prunes PruneStats
+	// sampling is the adaptive sampling state of the listener feeding these stats.
+	sampling SamplingStats
+	// fastPath is the fast path state of the listener feeding these stats.
+	fastPath FastPathStats
+	// raGuard is the RA guard canary state of the listener feeding these stats.
+	raGuard RAGuardStats
+	// rogueRA is the rogue RA detection state.
+	rogueRA RogueRAStats
+	// dedup is whether duplicate packets are dropped before recording.
+	dedup DedupStats
+	// mldSnoop is the MLD snooping verification state.
+	mldSnoop MLDSnoopStats
+	// mtu is what Packet Too Big messages have shown.
+	mtu MTUStats
+	// shared is the latest snapshot handed out by Shared, and sharedAge
+	// how long it is reused (see SetSharedAge).
+	shared    atomic.Pointer[Snapshot]
+	sharedAge atomic.Int64
+	// seconds counts messages by Unix second and type until TakeCounters
+	// collects them; nil unless EnableCounters was called.
+	seconds map[int64]map[string]int
+}
+
+// maxGoneRouters caps the previously-seen router history.
+const maxGoneRouters = 100
+
+// GoneRouter is a router that stopped advertising and aged out of the window,
+// with the last configuration it advertised.
+type GoneRouter struct {
+	RouterInfo
+	GoneAt time.Time `json:"gone_at"` // when Prune removed it
 }
 
 // PeerStats holds per-peer statistics
 type PeerStats struct {
+	// Addr is the parsed peer address; invalid if the key isn't an address.
+	Addr      netip.Addr
 	FirstSeen time.Time
 	LastSeen  time.Time
 	// Messages stores timestamps for each message type for windowed counting.
 	Messages map[string][]time.Time // key: ndpKind, value: timestamps
+	// Bytes stores the size of each message by type for windowed byte counting.
+	Bytes map[string][]byteSample
 	// Groups tracks multicast group memberships from MLD reports.
 	// key: multicast group address, value: last report time.
 	Groups map[string]time.Time
 	// MAC is the link-layer address extracted from NDP options (if seen).
 	MAC string
+	// MACHistory lists the MACs the peer has used, oldest first, at most
+	// maxMACHistory of them.
+	MACHistory []MACSighting
 	// HopLimit is the most recently observed IPv6 hop limit for this peer.
 	HopLimit int
 	// Interface is the most recently observed network interface name for this peer.
 	Interface string
+	// Port is the bridge or bond member port the peer was last seen on.
+	Port string
+	// Oversized counts messages above the per-type size threshold, keyed by ndpKind.
+	Oversized map[string]int
+	// NoRouterAlert counts MLD messages that failed Router Alert validation.
+	NoRouterAlert int
+	// MLDLatency is how long the peer last took to answer a query, by group.
+	MLDLatency map[string]time.Duration
+	// Defended holds the last time the peer advertised each NA target address.
+	Defended map[string]time.Time
+	// Options counts the NDP options the peer's messages carried, by ndpKind.
+	Options map[string]*optionCounts
 }
 
 // PeerSummary is a snapshot of peer stats for display
 type PeerSummary struct {
-	Address   string
-	FirstSeen time.Time
-	LastSeen  time.Time
-	Counts    map[string]int // message type -> count within window
-	Total     int
-	Groups    []string // multicast groups this peer has joined
-	MAC       string   // link-layer address (if observed)
-	HopLimit  int      // most recent IPv6 hop limit
-	Interface string   // most recent network interface name
-	GuessedOS string   // inferred OS/device type from MLD group memberships
+	Address   string         `json:"address"`
+	Addr      netip.Addr     `json:"-"` // Address parsed; may be invalid in summaries not built by NDPStats
+	FirstSeen time.Time      `json:"first_seen"`
+	LastSeen  time.Time      `json:"last_seen"`
+	Counts    map[string]int `json:"counts"` // message type -> count within window
+	Total     int            `json:"total"`
+	Groups    []string       `json:"groups,omitempty"`     // multicast groups this peer has joined
+	MAC       string         `json:"mac,omitempty"`        // link-layer address (if observed)
+	HopLimit  int            `json:"hop_limit,omitempty"`  // most recent IPv6 hop limit
+	Interface string         `json:"interface,omitempty"`  // most recent network interface name
+	Port      string         `json:"port,omitempty"`       // most recent bridge or bond member port
+	GuessedOS string         `json:"guessed_os,omitempty"` // inferred OS/device type from MLD group memberships
+	Oversized int            `json:"oversized,omitempty"`  // messages above the per-type size threshold since first seen
+	Stale     bool           `json:"stale,omitempty"`      // no messages in the window; kept for the grace period
+	// MACHistory lists the MACs the peer has used, oldest first.
+	MACHistory []MACSighting `json:"mac_history,omitempty"`
+	// Bytes is the ICMPv6 payload bytes within the window by message type.
+	Bytes map[string]int `json:"bytes"`
+	// TotalBytes is the payload bytes of every type within the window.
+	TotalBytes int `json:"total_bytes"`
+	// NoRouterAlert counts MLD messages without a valid Router Alert option (packet capture only).
+	NoRouterAlert int `json:"no_router_alert,omitempty"`
+	// MLDLatency is the last MLD query response latency per group.
+	MLDLatency map[string]time.Duration `json:"mld_latency,omitempty"`
+	// Undefended lists addresses implied by the peer's solicited-node groups
+	// that were solicited but never answered within the window.
+	Undefended []string `json:"undefended,omitempty"`
+	// Activity classifies the MAC's sleep/wake pattern: ActivityAlwaysOn,
+	// ActivityPeriodic, ActivitySleepy or "" while there is too little history.
+	Activity string `json:"activity,omitempty"`
+	// WakeInterval is the typical time between wake-ups for periodic and sleepy hosts.
+	WakeInterval time.Duration `json:"wake_interval,omitempty"`
+	// NDProxy is the /64 the peer answers Neighbor Solicitations for when it
+	// behaves as an ND proxy, "" otherwise.
+	NDProxy string `json:"nd_proxy,omitempty"`
+	// ProxiedTargets counts addresses other than its own the peer advertised within the window.
+	ProxiedTargets int `json:"proxied_targets,omitempty"`
+	// Popularity is how much other peers solicited the address within the
+	// window, nil if nobody did.
+	Popularity *Popularity `json:"popularity,omitempty"`
+	Enrichment
 }
 
 // GuessOS infers the likely OS or device type from MLD multicast group memberships.
@@ -87,36 +268,41 @@ func GuessOS(groups []string) string {
 
 // PrefixInfo holds prefix data extracted from RA Prefix Information options.
 type PrefixInfo struct {
-	Prefix        string        // e.g. "2001:db8::/64"
-	ValidLifetime time.Duration // valid lifetime
-	PreferredLife time.Duration // preferred lifetime
-	OnLink        bool          // L flag: prefix can be used for on-link determination
-	Autonomous    bool          // A flag: prefix can be used for SLAAC
+	Prefix        string        `json:"prefix"`         // e.g. "2001:db8::/64"
+	ValidLifetime time.Duration `json:"valid_lifetime"` // valid lifetime
+	PreferredLife time.Duration `json:"preferred_life"` // preferred lifetime
+	OnLink        bool          `json:"on_link"`        // L flag: prefix can be used for on-link determination
+	Autonomous    bool          `json:"autonomous"`     // A flag: prefix can be used for SLAAC
 }
 
 // RouteInfo holds route data extracted from RA Route Information options (RFC 4191).
 type RouteInfo struct {
-	Prefix     string        // e.g. "2001:db8:1::/48"
-	PrefixLen  int
-	Preference int           // 0=medium, 1=high, 3=low
-	Lifetime   time.Duration
+	Prefix     string        `json:"prefix"` // e.g. "2001:db8:1::/48"
+	PrefixLen  int           `json:"prefix_len"`
+	Preference int           `json:"preference"` // 0=medium, 1=high, 3=low
+	Lifetime   time.Duration `json:"lifetime"`
 }
 
 // RouterInfo holds data extracted from Router Advertisement messages.
 type RouterInfo struct {
-	Address   string        // router link-local IPv6
-	MAC       string        // from Source Link-Layer Address option
-	HopLimit  int           // cur hop limit field from RA
-	Lifetime  time.Duration // router lifetime
-	Managed   bool          // M flag: DHCPv6 for addresses
-	Other     bool          // O flag: DHCPv6 for other config
-	MTU       uint32        // from MTU option (0 if absent)
-	Prefixes  []PrefixInfo  // from Prefix Information options
-	RDNSS     []string      // DNS server addresses from RDNSS option
-	Routes    []RouteInfo   // from Route Information options
-	Interface string        // network interface name
-	FirstSeen time.Time
-	LastSeen  time.Time
+	Address  string        `json:"address"`       // router link-local IPv6
+	MAC      string        `json:"mac,omitempty"` // from Source Link-Layer Address option
+	HopLimit int           `json:"hop_limit"`     // cur hop limit field from RA
+	Lifetime time.Duration `json:"lifetime"`      // router lifetime
+	Managed  bool          `json:"managed"`       // M flag: DHCPv6 for addresses
+	Other    bool          `json:"other"`         // O flag: DHCPv6 for other config
+	// Preference is the default router preference (RFC 4191): 0=medium, 1=high, 3=low.
+	Preference int          `json:"preference,omitempty"`
+	MTU        uint32       `json:"mtu,omitempty"`      // from MTU option (0 if absent)
+	Prefixes   []PrefixInfo `json:"prefixes,omitempty"` // from Prefix Information options
+	RDNSS      []string     `json:"rdnss,omitempty"`    // DNS server addresses from RDNSS option
+	// DNSSL is the DNS search list from DNSSL options (RFC 8106).
+	DNSSL     []string    `json:"dnssl,omitempty"`
+	Routes    []RouteInfo `json:"routes,omitempty"`    // from Route Information options
+	Interface string      `json:"interface,omitempty"` // network interface name
+	Port      string      `json:"port,omitempty"`      // bridge or bond member port the RA arrived on
+	FirstSeen time.Time   `json:"first_seen"`
+	LastSeen  time.Time   `json:"last_seen"`
 }
 
 // NewNDPStats creates a new NDPStats tracker with the given sliding window duration.
@@ -125,12 +311,85 @@ func NewNDPStats(window time.Duration) *NDPStats {
 		peers:   make(map[string]*PeerStats),
 		routers: make(map[string]*RouterInfo),
 		window:  window,
+		sizes:   make(map[string]*SizeHistogram),
+		enrich:  make(map[string]Enrichment),
+
+		hostnames: make(map[string]string),
+		macClaims: make(map[string]*macClaims),
+
+		extAnomalies:          make(map[string]int),
+		routerAlertViolations: make(map[string]int),
+		duplicates:            make(map[string]*ifaceDuplicates),
+
+		mldReported:  make(map[string]time.Time),
+		groupJoined:  make(map[string]time.Time),
+		groupTraffic: make(map[string]time.Time),
+
+		mldGroupQueries: make(map[string]mldQuery),
+		mldAnswered:     make(map[string]time.Time),
+		mldLatency:      make(map[string]*groupLatency),
+		nsTargets:       make(map[string]*nsTarget),
+		nudProbes:       make(map[string]map[string][]time.Time),
+		activity:        make(map[string]*macActivity),
+		fhrp:            make(map[string]*VirtualRouter),
+		rsLatency:       make(map[string]*rsLatency),
+		routerProbes:    make(map[string]*routerProbe),
+		switchNeighbors: make(map[string]SwitchNeighbor),
+
+		alertKeys:   make(map[string]time.Time),
+		alertTotals: make(map[alertTotalKey]int),
+
+		maintenanceSuppressed: make(map[string]int),
 	}
 }
 
+// Clear forgets everything learned from traffic: peers, routers, size
+// histograms, stored alerts, router probes, switch ports and the per-host
+// state behind the derived views; the shared snapshot is dropped with them.
+// Configuration (ignore filters, tags, maintenance windows) and cumulative
+// counters are kept, so Prometheus counters never go back.
+func (s *NDPStats) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.peers = make(map[string]*PeerStats)
+	s.routers = make(map[string]*RouterInfo)
+	s.goneRouters = nil
+	s.sizes = make(map[string]*SizeHistogram)
+	s.alerts = nil
+	s.alertKeys = make(map[string]time.Time)
+	s.enrich = make(map[string]Enrichment)
+	s.hostnames = make(map[string]string)
+	s.macClaims = make(map[string]*macClaims)
+	s.bindingChanges = nil
+	if s.remembered != nil {
+		// Non-nil means a state DB is remembering pruned peers.
+		s.remembered = make(map[string]PersistedPeer)
+	}
+	s.arrivals = nil
+	s.duplicates = make(map[string]*ifaceDuplicates)
+	s.mldReported = make(map[string]time.Time)
+	s.groupJoined = make(map[string]time.Time)
+	s.groupTraffic = make(map[string]time.Time)
+	s.orphanDones = nil
+	s.mldGeneralQuery = mldQuery{}
+	s.mldGroupQueries = make(map[string]mldQuery)
+	s.mldAnswered = make(map[string]time.Time)
+	s.mldLatency = make(map[string]*groupLatency)
+	s.nsTargets = make(map[string]*nsTarget)
+	s.nudProbes = make(map[string]map[string][]time.Time)
+	s.activity = make(map[string]*macActivity)
+	s.dad = nil
+	s.fhrp = make(map[string]*VirtualRouter)
+	s.rsLatency = make(map[string]*rsLatency)
+	s.routerProbes = make(map[string]*routerProbe)
+	s.switchNeighbors = make(map[string]SwitchNeighbor)
+	s.shared.Store(nil)
+}
+
 // RecordMessage records an NDP/MLD message from the given IP address.
 func (s *NDPStats) RecordMessage(ip string, ndpKind string) {
-	now := time.Now()
+	now := s.now()
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -138,11 +397,13 @@ func (s *NDPStats) RecordMessage(ip string, ndpKind string) {
 	peer := s.getOrCreatePeer(ip, now)
 	peer.LastSeen = now
 	peer.Messages[ndpKind] = append(peer.Messages[ndpKind], now)
+	s.touchActivityLocked(peer.MAC, now)
+	s.countSecondLocked(ndpKind, now)
 }
 
 // RecordMLDMembership records that a peer has reported membership in a multicast group.
 func (s *NDPStats) RecordMLDMembership(ip string, group string) {
-	now := time.Now()
+	now := s.now()
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -156,8 +417,16 @@ func (s *NDPStats) RecordMAC(ip string, mac string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	peer := s.getOrCreatePeer(ip, time.Now())
+	now := s.now()
+	peer := s.getOrCreatePeer(ip, now)
+	if mac != peer.MAC {
+		peer.MACHistory = append(peer.MACHistory, MACSighting{MAC: mac, Since: now})
+		if len(peer.MACHistory) > maxMACHistory {
+			peer.MACHistory = peer.MACHistory[1:]
+		}
+	}
 	peer.MAC = mac
+	s.touchActivityLocked(mac, now)
 }
 
 // RecordHopLimit records the IPv6 hop limit observed for a peer.
@@ -165,7 +434,7 @@ func (s *NDPStats) RecordHopLimit(ip string, hopLimit int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	peer := s.getOrCreatePeer(ip, time.Now())
+	peer := s.getOrCreatePeer(ip, s.now())
 	peer.HopLimit = hopLimit
 }
 
@@ -174,17 +443,38 @@ func (s *NDPStats) RecordInterface(ip string, name string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	peer := s.getOrCreatePeer(ip, time.Now())
+	peer := s.getOrCreatePeer(ip, s.now())
 	peer.Interface = name
 }
 
+// RecordPort records the bridge or bond member port a peer was seen on.
+func (s *NDPStats) RecordPort(ip string, port string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peer := s.getOrCreatePeer(ip, s.now())
+	peer.Port = port
+}
+
 func (s *NDPStats) getOrCreatePeer(ip string, now time.Time) *PeerStats {
+	ip = canonicalAddr(ip)
 	peer, ok := s.peers[ip]
 	if !ok {
 		peer = &PeerStats{
+			Addr:      addrKey(ip),
 			FirstSeen: now,
 			Messages:  make(map[string][]time.Time),
+			Bytes:     make(map[string][]byteSample),
 			Groups:    make(map[string]time.Time),
+			Oversized: make(map[string]int),
+
+			MLDLatency: make(map[string]time.Duration),
+			Defended:   make(map[string]time.Time),
+			Options:    make(map[string]*optionCounts),
+		}
+		if r, ok := s.remembered[ip]; ok {
+			s.revivePeerLocked(peer, r)
+			delete(s.remembered, ip)
 		}
 		s.peers[ip] = peer
 	}
@@ -198,18 +488,59 @@ func (s *NDPStats) GetStats() []PeerSummary {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	cutoff := time.Now().Add(-s.window)
+	return s.summariesLocked(s.now())
+}
+
+// summariesLocked builds the peer summaries as of now. Callers must hold s.mu.
+func (s *NDPStats) summariesLocked(now time.Time) []PeerSummary {
+	cutoff := now.Add(-s.window)
 	summaries := make([]PeerSummary, 0, len(s.peers))
 
+	undefended := make(map[string][]string) // owner -> targets
+	for _, u := range s.undefendedLocked(now) {
+		for _, owner := range u.Owners {
+			undefended[owner] = append(undefended[owner], u.Target)
+		}
+	}
+
 	for addr, peer := range s.peers {
 		summary := PeerSummary{
 			Address:   addr,
+			Addr:      peer.Addr,
 			FirstSeen: peer.FirstSeen,
 			LastSeen:  peer.LastSeen,
 			Counts:    make(map[string]int),
+			Bytes:     make(map[string]int),
 			MAC:       peer.MAC,
 			HopLimit:  peer.HopLimit,
 			Interface: peer.Interface,
+			Port:      peer.Port,
+		}
+		summary.Enrichment = s.enrich[addr]
+		if summary.Hostname == "" {
+			summary.Hostname = s.hostnames[addr]
+		}
+		if tags := s.tags[addr]; len(tags) > 0 {
+			summary.Tags = append(slices.Clone(summary.Tags), tags...)
+		}
+		summary.MACHistory = slices.Clone(peer.MACHistory)
+		for _, n := range peer.Oversized {
+			summary.Oversized += n
+		}
+		summary.NoRouterAlert = peer.NoRouterAlert
+		summary.Undefended = undefended[addr]
+		summary.Activity, summary.WakeInterval = s.activityLocked(peer.MAC, now)
+		summary.NDProxy, summary.ProxiedTargets = s.proxyLocked(addr, peer, cutoff)
+		if ns, ok := s.nsTargets[addr]; ok {
+			if p, ok := ns.popularity(cutoff); ok {
+				summary.Popularity = &p
+			}
+		}
+		if len(peer.MLDLatency) > 0 {
+			summary.MLDLatency = make(map[string]time.Duration, len(peer.MLDLatency))
+			for group, d := range peer.MLDLatency {
+				summary.MLDLatency[group] = d
+			}
 		}
 
 		for kind, timestamps := range peer.Messages {
@@ -222,6 +553,11 @@ func (s *NDPStats) GetStats() []PeerSummary {
 			summary.Counts[kind] = count
 			summary.Total += count
 		}
+		for kind, samples := range peer.Bytes {
+			n := bytesSince(samples, cutoff)
+			summary.Bytes[kind] = n
+			summary.TotalBytes += n
+		}
 
 		// Collect multicast group memberships reported within the window
 		for group, lastSeen := range peer.Groups {
@@ -232,7 +568,11 @@ func (s *NDPStats) GetStats() []PeerSummary {
 		sort.Strings(summary.Groups)
 
 		summary.GuessedOS = GuessOS(summary.Groups)
+		summary.Stale = summary.Total == 0 && !peer.LastSeen.After(cutoff)
 
+		if s.ignoredLocked(summary) {
+			continue
+		}
 		summaries = append(summaries, summary)
 	}
 
@@ -245,12 +585,18 @@ func (s *NDPStats) GetStats() []PeerSummary {
 }
 
 // Prune removes timestamps older than the window from all peers.
-// Peers with no messages in the window are removed entirely.
+// Peers with no messages in the window are removed entirely once they have
+// also been quiet for the grace period; until then they are reported as stale.
+// Routers with no RA in the window are moved to the gone-router history.
 func (s *NDPStats) Prune() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	cutoff := time.Now().Add(-s.window)
+	now := s.now()
+	cutoff := now.Add(-s.window)
+	graceCutoff := cutoff.Add(-s.grace)
+	s.pruneBindingsLocked(now)
+	s.pruneAlertKeysLocked(cutoff)
 
 	for addr, peer := range s.peers {
 		totalKept := 0
@@ -269,19 +615,67 @@ func (s *NDPStats) Prune() {
 				delete(peer.Messages, kind)
 			}
 		}
+		for kind, samples := range peer.Bytes {
+			if kept := pruneByteSamples(samples, cutoff); len(kept) > 0 {
+				peer.Bytes[kind] = kept
+			} else {
+				delete(peer.Bytes, kind)
+			}
+		}
 
 		// Prune stale group memberships
 		for group, lastSeen := range peer.Groups {
 			if !lastSeen.After(cutoff) {
 				delete(peer.Groups, group)
+				delete(peer.MLDLatency, group)
 			}
 		}
 
-		// Remove peer if no messages remain in window
-		if totalKept == 0 {
+		// Remove peer if no messages remain in window and the grace period is over
+		if totalKept == 0 && !peer.LastSeen.After(graceCutoff) {
+			s.rememberLocked(persistedPeer(addr, peer))
 			delete(s.peers, addr)
+			delete(s.enrich, addr)
+			delete(s.hostnames, addr)
 		}
 	}
+
+	// Routers that have not advertised within the window move to the history
+	for addr, r := range s.routers {
+		if !r.LastSeen.After(cutoff) {
+			s.goneRouters = append(s.goneRouters, GoneRouter{RouterInfo: *r, GoneAt: now})
+			delete(s.routers, addr)
+		}
+	}
+	if len(s.goneRouters) > maxGoneRouters {
+		s.goneRouters = s.goneRouters[len(s.goneRouters)-maxGoneRouters:]
+	}
+
+	s.pruneMLDLatencyLocked(cutoff)
+	s.pruneNDTargetsLocked(cutoff)
+	s.pruneNUDLocked(cutoff)
+	s.pruneActivityLocked(now)
+	s.pruneDADLocked(cutoff)
+	s.pruneFHRPLocked(cutoff)
+	s.pruneRSLatencyLocked()
+	s.pruneRouterProbesLocked()
+	s.pruneSwitchNeighborsLocked(now)
+	s.pruneMulticastSanityLocked(cutoff)
+}
+
+// SetClock makes s read the time from now instead of the wall clock, so
+// imported captures are recorded at their capture times (see ImportPcaps).
+// Call it before anything is recorded.
+func (s *NDPStats) SetClock(now func() time.Time) {
+	s.clock = now
+}
+
+// now returns the time by s's clock.
+func (s *NDPStats) now() time.Time {
+	if s.clock != nil {
+		return s.clock()
+	}
+	return time.Now()
 }
 
 // Window returns the configured sliding window duration.
@@ -289,6 +683,71 @@ func (s *NDPStats) Window() time.Duration {
 	return s.window
 }
 
+// SetGrace sets how long a peer with no messages left in the window is kept
+// (and reported as stale) before Prune removes it. The default is zero.
+func (s *NDPStats) SetGrace(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grace = d
+}
+
+// Grace returns the configured stale grace period.
+func (s *NDPStats) Grace() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.grace
+}
+
+// SetEnrichment stores enrichment results for a known peer. Unknown
+// addresses are ignored so a slow lookup can't resurrect a pruned peer.
+func (s *NDPStats) SetEnrichment(ip string, e Enrichment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ip = canonicalAddr(ip)
+	if _, ok := s.peers[ip]; ok {
+		s.enrich[ip] = e
+	}
+}
+
+// enrichmentFor returns a copy of the enrichment for ip, or nil if nothing
+// is known about it.
+func (s *NDPStats) enrichmentFor(ip string) *Enrichment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e := s.enrich[ip]
+	if e.Hostname == "" {
+		e.Hostname = s.hostnames[ip]
+	}
+	if e.IsZero() {
+		return nil
+	}
+	e.Tags = slices.Clone(e.Tags)
+	e.Reputation = slices.Clone(e.Reputation)
+	return &e
+}
+
+// SetIgnore sets the ignore rules. Peers matching any filter are still
+// tracked but are left out of GetStats, snapshots and everything built on them.
+func (s *NDPStats) SetIgnore(filters []*Filter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignore = filters
+}
+
+func (s *NDPStats) ignoredLocked(p PeerSummary) bool {
+	if s.ignored[p.Address] {
+		return true
+	}
+	for _, f := range s.ignore {
+		if f.Match(p) {
+			return true
+		}
+	}
+	return false
+}
+
 // RecordRouter records or updates a router from an RA message.
 // On first observation, FirstSeen is set. On subsequent observations, all fields
 // except FirstSeen are updated to reflect the latest RA.
@@ -296,6 +755,7 @@ func (s *NDPStats) RecordRouter(info RouterInfo) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	info.Address = canonicalAddr(info.Address)
 	existing, ok := s.routers[info.Address]
 	if !ok {
 		info.FirstSeen = info.LastSeen
@@ -309,11 +769,14 @@ func (s *NDPStats) RecordRouter(info RouterInfo) {
 	existing.Lifetime = info.Lifetime
 	existing.Managed = info.Managed
 	existing.Other = info.Other
+	existing.Preference = info.Preference
 	existing.MTU = info.MTU
 	existing.Prefixes = info.Prefixes
 	existing.RDNSS = info.RDNSS
+	existing.DNSSL = info.DNSSL
 	existing.Routes = info.Routes
 	existing.Interface = info.Interface
+	existing.Port = info.Port
 	existing.LastSeen = info.LastSeen
 }
 
@@ -322,6 +785,11 @@ func (s *NDPStats) GetRouters() []RouterInfo {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	return s.routersLocked()
+}
+
+// routersLocked copies the router table sorted by last seen. Callers must hold s.mu.
+func (s *NDPStats) routersLocked() []RouterInfo {
 	result := make([]RouterInfo, 0, len(s.routers))
 	for _, r := range s.routers {
 		result = append(result, *r)
@@ -333,3 +801,20 @@ func (s *NDPStats) GetRouters() []RouterInfo {
 
 	return result
 }
+
+// GetGoneRouters returns routers that have aged out, most recently gone first.
+func (s *NDPStats) GetGoneRouters() []GoneRouter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.goneRoutersLocked()
+}
+
+// goneRoutersLocked copies the router history, newest first. Callers must hold s.mu.
+func (s *NDPStats) goneRoutersLocked() []GoneRouter {
+	result := make([]GoneRouter, len(s.goneRouters))
+	for i, g := range s.goneRouters {
+		result[len(s.goneRouters)-1-i] = g
+	}
+	return result
+}
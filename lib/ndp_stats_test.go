@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"fmt"
 	"testing"
 	"time"
 )
@@ -156,6 +157,36 @@ func TestPrune_RemovesOldTimestamps(t *testing.T) {
 	}
 }
 
+func TestPrune_GraceKeepsStalePeer(t *testing.T) {
+	stats := NewNDPStats(100 * time.Millisecond)
+	stats.SetGrace(200 * time.Millisecond)
+
+	stats.RecordMessage("fe80::1", "router_solicitation")
+
+	// Past the window but inside the grace period: kept as stale
+	time.Sleep(150 * time.Millisecond)
+	stats.Prune()
+
+	summaries := stats.GetStats()
+	if len(summaries) != 1 {
+		t.Fatalf("Within grace, got %d peers, want 1", len(summaries))
+	}
+	if !summaries[0].Stale {
+		t.Error("Peer should be stale within grace period")
+	}
+	if summaries[0].Total != 0 {
+		t.Errorf("Stale peer total = %d, want 0", summaries[0].Total)
+	}
+
+	// Past window + grace: removed
+	time.Sleep(200 * time.Millisecond)
+	stats.Prune()
+
+	if summaries = stats.GetStats(); len(summaries) != 0 {
+		t.Errorf("After grace, got %d peers, want 0", len(summaries))
+	}
+}
+
 func TestPrune_KeepsRecentMessages(t *testing.T) {
 	stats := NewNDPStats(1 * time.Second)
 
@@ -429,3 +460,97 @@ func TestFirstSeenLastSeen(t *testing.T) {
 		t.Errorf("LastSeen %v should be >= FirstSeen %v", peer.LastSeen, peer.FirstSeen)
 	}
 }
+
+func TestPrune_MovesSilentRoutersToHistory(t *testing.T) {
+	stats := NewNDPStats(1 * time.Minute)
+
+	now := time.Now()
+	stats.RecordRouter(RouterInfo{Address: "fe80::1", MTU: 1500, LastSeen: now.Add(-2 * time.Minute)})
+	stats.RecordRouter(RouterInfo{Address: "fe80::2", LastSeen: now})
+
+	stats.Prune()
+
+	routers := stats.GetRouters()
+	if len(routers) != 1 || routers[0].Address != "fe80::2" {
+		t.Fatalf("GetRouters() = %+v, want only fe80::2", routers)
+	}
+
+	gone := stats.GetGoneRouters()
+	if len(gone) != 1 {
+		t.Fatalf("GetGoneRouters() returned %d, want 1", len(gone))
+	}
+	if gone[0].Address != "fe80::1" || gone[0].MTU != 1500 {
+		t.Errorf("gone router = %+v, want fe80::1 with last advertised MTU", gone[0])
+	}
+	if gone[0].GoneAt.Before(now) {
+		t.Errorf("GoneAt = %v, want at or after %v", gone[0].GoneAt, now)
+	}
+}
+
+// --- Benchmarks ---
+
+// benchStats returns stats holding n peers that each sent one message.
+func benchStats(n int) (*NDPStats, []string) {
+	stats := NewNDPStats(15 * time.Minute)
+	addrs := make([]string, n)
+	for i := range addrs {
+		addrs[i] = fmt.Sprintf("2001:db8::%x", i+1)
+		stats.RecordMessage(addrs[i], "neighbor_solicitation")
+		stats.RecordMAC(addrs[i], fmt.Sprintf("02:00:00:00:%02x:%02x", i>>8&0xff, i&0xff))
+	}
+	return stats, addrs
+}
+
+func TestClear(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RestoreState(PersistedState{}, time.Now())
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordSwitchNeighbor(SwitchNeighbor{Interface: "eth0", Port: "Gi1/0/1", TTL: time.Minute, LastSeen: time.Now()})
+	if snap := stats.Shared(); len(snap.Peers) != 1 || len(snap.SwitchNeighbors) != 1 {
+		t.Fatalf("shared snapshot = %d peers, %d switch ports", len(snap.Peers), len(snap.SwitchNeighbors))
+	}
+
+	stats.Clear()
+	if snap := stats.Shared(); len(snap.Peers) != 0 || len(snap.SwitchNeighbors) != 0 {
+		t.Errorf("after Clear the shared snapshot has %d peers, %d switch ports", len(snap.Peers), len(snap.SwitchNeighbors))
+	}
+	// The state DB keeps remembering pruned peers.
+	stats.mu.Lock()
+	stats.rememberLocked(PersistedPeer{Address: "fe80::2", LastSeen: time.Now()})
+	n := len(stats.remembered)
+	stats.mu.Unlock()
+	if n != 1 {
+		t.Errorf("remembered %d peers after Clear, want 1", n)
+	}
+}
+
+func BenchmarkRecordMessage(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("peers=%d", n), func(b *testing.B) {
+			stats, addrs := benchStats(n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				stats.RecordMessage(addrs[i%n], "neighbor_solicitation")
+			}
+		})
+	}
+}
+
+func BenchmarkGetStats10k(b *testing.B) {
+	stats, _ := benchStats(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stats.GetStats()
+	}
+}
+
+func BenchmarkPrune10k(b *testing.B) {
+	stats, _ := benchStats(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stats.Prune()
+	}
+}
@@ -0,0 +1,203 @@
+package lib
+
+import (
+	"NDPeekr/lib/craft"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"time"
+)
+
+const (
+	// routerProbeTimeout is how long a router has to answer a probe. It is
+	// the default RetransTimer a host would wait (RFC 4861).
+	routerProbeTimeout = time.Second
+	// routerProbeFailures is how many probes in a row must go unanswered
+	// before a router counts as unreachable, matching MAX_UNICAST_SOLICIT.
+	routerProbeFailures = 3
+	// defaultProbeInterval is used when RouterProberConfig.Interval is unset.
+	defaultProbeInterval = 30 * time.Second
+)
+
+// routerProbe tracks our unicast NS probes to one router.
+type routerProbe struct {
+	sent        time.Time // latest probe
+	answered    bool      // whether the latest probe was answered
+	probes      int
+	answers     int
+	failures    int // consecutive unanswered probes
+	lastAnswer  time.Time
+	rtt         time.Duration
+	unreachable bool
+}
+
+// RouterReachability is how a router answered our neighbor resolution probes.
+type RouterReachability struct {
+	Router   string `json:"router"`
+	Probes   int    `json:"probes"`
+	Answers  int    `json:"answers"`
+	Failures int    `json:"failures"` // consecutive unanswered probes
+	// Unreachable is set once routerProbeFailures probes in a row went
+	// unanswered, while the router may well still be sending RAs.
+	Unreachable bool          `json:"unreachable"`
+	LastAnswer  time.Time     `json:"last_answer,omitempty"`
+	RTT         time.Duration `json:"rtt,omitempty"` // of the last answered probe
+}
+
+// RecordRouterProbe notes a probe sent to router at now, settling the
+// previous one first. It reports whether that made the router unreachable.
+func (s *NDPStats) RecordRouterProbe(router string, now time.Time) (unreachable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.routerProbes[router]
+	if !ok {
+		p = &routerProbe{}
+		s.routerProbes[router] = p
+	}
+	if !p.sent.IsZero() && !p.answered {
+		p.failures++
+		if p.failures >= routerProbeFailures && !p.unreachable {
+			p.unreachable = true
+			unreachable = true
+		}
+	}
+	p.sent = now
+	p.answered = false
+	p.probes++
+	return unreachable
+}
+
+// RecordRouterProbeAnswer checks whether an NA from router for its own
+// address answers our outstanding probe. It reports whether the router had
+// been unreachable and has now recovered.
+func (s *NDPStats) RecordRouterProbeAnswer(router, target string, now time.Time) (recovered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.routerProbes[router]
+	if !ok || target != router || p.answered || now.Before(p.sent) || now.Sub(p.sent) > routerProbeTimeout {
+		return false
+	}
+	p.answered = true
+	p.answers++
+	p.failures = 0
+	p.lastAnswer = now
+	p.rtt = now.Sub(p.sent)
+	recovered = p.unreachable
+	p.unreachable = false
+	return recovered
+}
+
+// GetRouterReachability returns the probe results per router, ordered by address.
+func (s *NDPStats) GetRouterReachability() []RouterReachability {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.routerReachabilityLocked()
+}
+
+// routerReachabilityLocked copies the probe results. Callers must hold s.mu.
+func (s *NDPStats) routerReachabilityLocked() []RouterReachability {
+	result := make([]RouterReachability, 0, len(s.routerProbes))
+	for router, p := range s.routerProbes {
+		result = append(result, RouterReachability{
+			Router:      router,
+			Probes:      p.probes,
+			Answers:     p.answers,
+			Failures:    p.failures,
+			Unreachable: p.unreachable,
+			LastAnswer:  p.lastAnswer,
+			RTT:         p.rtt,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Router < result[j].Router })
+	return result
+}
+
+// pruneRouterProbesLocked forgets probe results for routers no longer in the
+// router table. Callers must hold s.mu and prune routers first.
+func (s *NDPStats) pruneRouterProbesLocked() {
+	for router := range s.routerProbes {
+		if _, ok := s.routers[router]; !ok {
+			delete(s.routerProbes, router)
+		}
+	}
+}
+
+// RouterProberConfig configures a RouterProber.
+type RouterProberConfig struct {
+	Interface string        // required; routers advertising on this link are probed
+	Interval  time.Duration // between probe rounds; defaultProbeInterval if 0
+	Logger    *slog.Logger  // required
+	Stats     *NDPStats     // required; supplies the routers and receives results
+	Sinks     []Sink        // optional; receive router_unreachable alerts
+}
+
+// RouterProber periodically sends a unicast Neighbor Solicitation to every
+// known router and flags routers that keep advertising but stop answering
+// neighbor resolution, which hosts then can't use as their default router.
+// The NAs are captured by the NDPListener, which records the answers.
+type RouterProber struct {
+	cfg RouterProberConfig
+}
+
+func NewRouterProber(cfg RouterProberConfig) *RouterProber {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultProbeInterval
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &RouterProber{cfg: cfg}
+}
+
+// Run probes the routers every Interval until ctx is cancelled.
+func (p *RouterProber) Run(ctx context.Context) error {
+	ifi, err := net.InterfaceByName(p.cfg.Interface)
+	if err != nil {
+		return fmt.Errorf("probe routers: %w", err)
+	}
+	pc, err := listenNDSender(ifi)
+	if err != nil {
+		return fmt.Errorf("probe routers: %w", err)
+	}
+	defer pc.Close()
+
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		for _, r := range p.cfg.Stats.GetRouters() {
+			if r.Interface != "" && r.Interface != ifi.Name {
+				continue
+			}
+			target := net.ParseIP(unzoned(r.Address))
+			ns := craft.BuildNS(craft.NS{Target: target, SourceMAC: ifi.HardwareAddr})
+			zone := ""
+			if target.IsLinkLocalUnicast() {
+				zone = linkZone(ifi)
+			}
+			now := time.Now()
+			if _, err := pc.WriteTo(ns, &net.IPAddr{IP: target, Zone: zone}); err != nil {
+				p.cfg.Logger.Warn("failed to probe router", "router", r.Address, "err", err)
+				continue
+			}
+			if p.cfg.Stats.RecordRouterProbe(r.Address, now) {
+				emitAlert(p.cfg.Stats, p.cfg.Logger, p.cfg.Sinks, Alert{
+					Severity: SeverityCritical,
+					Category: "router_unreachable",
+					Source:   r.Address,
+					Message: fmt.Sprintf("router %s still advertises but has not answered %d neighbor solicitations in a row",
+						r.Address, routerProbeFailures),
+				})
+			}
+		}
+	}
+}
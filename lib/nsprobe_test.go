@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRouterProbeUnreachableAndRecovery(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	t0 := time.Now()
+
+	// Answered probe; an NA for another target or a late one doesn't count.
+	stats.RecordRouterProbe("fe80::1", t0)
+	if stats.RecordRouterProbeAnswer("fe80::1", "fe80::99", t0.Add(5*time.Millisecond)) {
+		t.Error("NA for another target reported recovery")
+	}
+	stats.RecordRouterProbeAnswer("fe80::1", "fe80::1", t0.Add(10*time.Millisecond))
+	got := stats.GetRouterReachability()
+	if len(got) != 1 || got[0].Answers != 1 || got[0].RTT != 10*time.Millisecond || got[0].Unreachable {
+		t.Fatalf("after answer = %+v", got)
+	}
+
+	// Three unanswered probes in a row; the third is settled by the fourth.
+	var unreachable []bool
+	for i := 1; i <= 4; i++ {
+		at := t0.Add(time.Duration(i) * 30 * time.Second)
+		unreachable = append(unreachable, stats.RecordRouterProbe("fe80::1", at))
+		stats.RecordRouterProbeAnswer("fe80::1", "fe80::1", at.Add(2*time.Second)) // too late
+	}
+	if want := []bool{false, false, false, true}; !slices.Equal(unreachable, want) {
+		t.Errorf("RecordRouterProbe results = %v, want %v", unreachable, want)
+	}
+	if r := stats.GetRouterReachability()[0]; !r.Unreachable || r.Failures != 3 || r.Probes != 5 || r.Answers != 1 {
+		t.Errorf("after failures = %+v", r)
+	}
+	// Staying unreachable doesn't report it again.
+	if stats.RecordRouterProbe("fe80::1", t0.Add(150*time.Second)) {
+		t.Error("unreachable reported twice")
+	}
+
+	if !stats.RecordRouterProbeAnswer("fe80::1", "fe80::1", t0.Add(150*time.Second+20*time.Millisecond)) {
+		t.Error("answer after being unreachable did not report recovery")
+	}
+	if r := stats.GetRouterReachability()[0]; r.Unreachable || r.Failures != 0 {
+		t.Errorf("after recovery = %+v", r)
+	}
+}
+
+func TestHandle_RouterProbeRecoveryAlert(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	now := time.Now()
+	for i := 0; i <= routerProbeFailures; i++ {
+		stats.RecordRouterProbe("fe80::1", now)
+	}
+
+	mac := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0x01}
+	l.handle(received{src: netip.MustParseAddr("fe80::1"), payload: buildNA(net.ParseIP("fe80::1"), mac)})
+
+	alerts := stats.GetAlerts()
+	if len(alerts) != 1 || alerts[0].Category != "router_reachable" || alerts[0].Source != "fe80::1" {
+		t.Errorf("alerts = %+v, want one router_reachable", alerts)
+	}
+}
@@ -0,0 +1,195 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	// nudBurstGap folds unicast NS to the same target closer together than
+	// this into one probe and its retransmissions (RetransTimer defaults to 1s).
+	nudBurstGap = 3 * time.Second
+	// nudMaxUnicastSolicit is MAX_UNICAST_SOLICIT (RFC 4861): a probe is sent
+	// at most this many times before the neighbor is declared unreachable.
+	nudMaxUnicastSolicit = 3
+	// nudMinRetransInterval is half the default RetransTimer; retransmissions
+	// faster than this point at a broken timer.
+	nudMinRetransInterval = 500 * time.Millisecond
+	// nudMinProbeInterval is the shortest ReachableTime RFC 4861 allows by
+	// default (half of 30s); probing a neighbor more often is aggressive.
+	nudMinProbeInterval = 15 * time.Second
+	// nudMinBursts is how many probes to one target it takes to judge the
+	// interval between them.
+	nudMinBursts = 3
+	// maxNUDProbes caps the probes kept per host and target.
+	maxNUDProbes = 256
+)
+
+// NUD verdicts.
+const (
+	NUDAggressive = "aggressive" // probes reachable neighbors far more often than ReachableTime
+	NUDBroken     = "broken"     // retransmits too fast or too often
+)
+
+// NUDBehavior is how a host runs Neighbor Unreachability Detection, inferred
+// from the unicast Neighbor Solicitations it sent within the window.
+// Solicitations to the same target less than 3s apart are one probe and its
+// retransmissions, a burst.
+type NUDBehavior struct {
+	Host      string   `json:"host"`
+	Targets   []string `json:"targets"` // addresses it probed, sorted
+	Probes    int      `json:"probes"`  // unicast NS sent
+	Bursts    int      `json:"bursts"`
+	MaxProbes int      `json:"max_probes"` // most NS in one burst: the probe plus its retransmissions
+	// RetransInterval is the median time between retransmissions, zero without any.
+	RetransInterval time.Duration `json:"retrans_interval,omitempty"`
+	// ProbeInterval is the median time between bursts to the same target,
+	// zero with fewer than three bursts to any target.
+	ProbeInterval time.Duration `json:"probe_interval,omitempty"`
+	// Verdict is NUDAggressive, NUDBroken or "" for timers within RFC 4861 defaults.
+	Verdict string `json:"verdict,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// RecordNUDProbe records a unicast Neighbor Solicitation from host to
+// target and returns the host's NUD behavior including it.
+func (s *NDPStats) RecordNUDProbe(host, target string, now time.Time) NUDBehavior {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	targets := s.nudProbes[host]
+	if targets == nil {
+		targets = make(map[string][]time.Time)
+		s.nudProbes[host] = targets
+	}
+	probes := append(targets[target], now)
+	if len(probes) > maxNUDProbes {
+		probes = probes[len(probes)-maxNUDProbes:]
+	}
+	targets[target] = probes
+	return s.nudBehaviorLocked(host, now.Add(-s.window))
+}
+
+// GetNUDBehavior returns the NUD behavior of every host that sent unicast
+// Neighbor Solicitations within the window: flagged hosts first, then by
+// probes sent.
+func (s *NDPStats) GetNUDBehavior() []NUDBehavior {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nudLocked(s.now())
+}
+
+// nudLocked computes GetNUDBehavior. Callers must hold s.mu.
+func (s *NDPStats) nudLocked(now time.Time) []NUDBehavior {
+	cutoff := now.Add(-s.window)
+	var out []NUDBehavior
+	for host := range s.nudProbes {
+		if b := s.nudBehaviorLocked(host, cutoff); b.Probes > 0 {
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if (a.Verdict != "") != (b.Verdict != "") {
+			return a.Verdict != ""
+		}
+		if a.Probes != b.Probes {
+			return a.Probes > b.Probes
+		}
+		return a.Host < b.Host
+	})
+	return out
+}
+
+// nudBehaviorLocked analyses host's probes after cutoff. Callers must hold s.mu.
+func (s *NDPStats) nudBehaviorLocked(host string, cutoff time.Time) NUDBehavior {
+	b := NUDBehavior{Host: host}
+	var retrans, between []time.Duration
+	var worstTarget string
+	worstBurst := 0
+	for target, probes := range s.nudProbes[host] {
+		var burstStarts []time.Time
+		burst := 0
+		var prev time.Time
+		for _, at := range probes {
+			if !at.After(cutoff) {
+				continue
+			}
+			b.Probes++
+			if burst > 0 && at.Sub(prev) < nudBurstGap {
+				retrans = append(retrans, at.Sub(prev))
+				burst++
+			} else {
+				burstStarts = append(burstStarts, at)
+				burst = 1
+			}
+			if burst > worstBurst {
+				worstBurst, worstTarget = burst, target
+			}
+			prev = at
+		}
+		if len(burstStarts) == 0 {
+			continue
+		}
+		b.Targets = append(b.Targets, target)
+		b.Bursts += len(burstStarts)
+		if len(burstStarts) >= nudMinBursts {
+			for i := 1; i < len(burstStarts); i++ {
+				between = append(between, burstStarts[i].Sub(burstStarts[i-1]))
+			}
+		}
+	}
+	sort.Strings(b.Targets)
+	b.MaxProbes = worstBurst
+	if len(retrans) > 0 {
+		slices.Sort(retrans)
+		b.RetransInterval = percentile(retrans, 50)
+	}
+	if len(between) > 0 {
+		slices.Sort(between)
+		b.ProbeInterval = percentile(between, 50)
+	}
+
+	switch {
+	case b.MaxProbes > nudMaxUnicastSolicit:
+		b.Verdict = NUDBroken
+		b.Reason = fmt.Sprintf("%d unicast NS to %s without a pause (at most %d expected)", b.MaxProbes, worstTarget, nudMaxUnicastSolicit)
+	case b.RetransInterval > 0 && b.RetransInterval < nudMinRetransInterval:
+		b.Verdict = NUDBroken
+		b.Reason = fmt.Sprintf("retransmits every %s (RetransTimer is 1s by default)", formatLatency(b.RetransInterval))
+	case b.ProbeInterval > 0 && b.ProbeInterval < nudMinProbeInterval:
+		b.Verdict = NUDAggressive
+		b.Reason = fmt.Sprintf("probes %s every %s (ReachableTime is at least 15s by default)", nudTargets(b.Targets), formatInterval(b.ProbeInterval))
+	}
+	return b
+}
+
+// nudTargets describes the probed targets for a reason.
+func nudTargets(targets []string) string {
+	if len(targets) == 1 {
+		return targets[0]
+	}
+	return fmt.Sprintf("%d neighbors", len(targets))
+}
+
+// pruneNUDLocked drops probes older than cutoff. Callers must hold s.mu.
+func (s *NDPStats) pruneNUDLocked(cutoff time.Time) {
+	for host, targets := range s.nudProbes {
+		for target, probes := range targets {
+			i := 0
+			for i < len(probes) && !probes[i].After(cutoff) {
+				i++
+			}
+			if i == len(probes) {
+				delete(targets, target)
+			} else {
+				targets[target] = probes[i:]
+			}
+		}
+		if len(targets) == 0 {
+			delete(s.nudProbes, host)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestNUDBehavior(t *testing.T) {
+	base := time.Now().Add(-2 * time.Minute)
+	at := func(d time.Duration) time.Time { return base.Add(d) }
+
+	tests := []struct {
+		name    string
+		probes  []time.Duration
+		verdict string
+	}{
+		// Reprobes after ReachableTime, one retransmission 1s later.
+		{"normal", []time.Duration{0, time.Second, 30 * time.Second, 62 * time.Second}, ""},
+		// A probe every 5s, answered each time.
+		{"aggressive", []time.Duration{0, 5 * time.Second, 10 * time.Second, 15 * time.Second}, NUDAggressive},
+		// Retransmissions 100ms apart.
+		{"fast retransmit", []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond}, NUDBroken},
+		// Six probes without a pause.
+		{"too many", []time.Duration{0, 2 * time.Second, 4 * time.Second, 6 * time.Second, 8 * time.Second, 10 * time.Second}, NUDBroken},
+	}
+	for _, tt := range tests {
+		stats := NewNDPStats(5 * time.Minute)
+		var b NUDBehavior
+		for _, d := range tt.probes {
+			b = stats.RecordNUDProbe("fe80::a", "fe80::1", at(d))
+		}
+		if b.Verdict != tt.verdict {
+			t.Errorf("%s: verdict %q (%s), want %q; %+v", tt.name, b.Verdict, b.Reason, tt.verdict, b)
+		}
+		if b.Probes != len(tt.probes) {
+			t.Errorf("%s: %d probes, want %d", tt.name, b.Probes, len(tt.probes))
+		}
+	}
+}
+
+func TestNUDBehavior_BurstsAndPrune(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	now := time.Now()
+	stats.RecordNUDProbe("fe80::a", "fe80::1", now.Add(-10*time.Minute)) // outside the window
+	stats.RecordNUDProbe("fe80::a", "fe80::1", now.Add(-40*time.Second))
+	stats.RecordNUDProbe("fe80::a", "fe80::1", now.Add(-39*time.Second))
+	stats.RecordNUDProbe("fe80::a", "fe80::2", now.Add(-20*time.Second))
+	stats.RecordNUDProbe("fe80::b", "fe80::1", now)
+
+	got := stats.GetNUDBehavior()
+	if len(got) != 2 || got[0].Host != "fe80::a" {
+		t.Fatalf("behavior = %+v, want fe80::a then fe80::b", got)
+	}
+	a := got[0]
+	if a.Probes != 3 || a.Bursts != 2 || a.MaxProbes != 2 || a.RetransInterval != time.Second || len(a.Targets) != 2 {
+		t.Errorf("fe80::a = %+v, want 3 probes in 2 bursts to 2 targets, retransmitting after 1s", a)
+	}
+
+	stats.Prune()
+	if n := len(stats.nudProbes["fe80::a"]["fe80::1"]); n != 2 {
+		t.Errorf("%d probes kept after prune, want 2", n)
+	}
+}
+
+func TestHandle_NUDAlert(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	src := netip.MustParseAddr("fe80::a")
+	gw := netip.MustParseAddr("fe80::1")
+	ns := buildNS(net.ParseIP("fe80::1"), nil)
+	// Multicast address resolution is not NUD.
+	l.handle(received{src: src, dst: netip.MustParseAddr("ff02::1:ff00:1"), payload: ns})
+	for range 5 {
+		l.handle(received{src: src, dst: gw, payload: ns})
+	}
+
+	got := stats.GetNUDBehavior()
+	if len(got) != 1 || got[0].Probes != 5 || got[0].Verdict != NUDBroken {
+		t.Fatalf("behavior = %+v, want 5 probes judged broken", got)
+	}
+	alerts := stats.GetAlerts()
+	if len(alerts) != 1 || alerts[0].Category != "nud_broken" || alerts[0].Source != "fe80::a" {
+		t.Errorf("alerts = %+v, want one nud_broken for fe80::a", alerts)
+	}
+}
@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ndpOptionNames names the NDP option types the usage matrix reports, and
+// ndpOptionOrder is their column order. Other types are reported as type-N.
+var ndpOptionNames = map[byte]string{
+	1:  "slla",              // Source Link-Layer Address
+	2:  "tlla",              // Target Link-Layer Address
+	3:  "prefix",            // Prefix Information
+	4:  "redirected_header", // Redirected Header
+	5:  "mtu",               // MTU
+	14: "nonce",             // Nonce (RFC 3971)
+	24: "route_info",        // Route Information (RFC 4191)
+	25: "rdnss",             // Recursive DNS Server (RFC 8106)
+	31: "dnssl",             // DNS Search List (RFC 8106)
+	37: "captive_portal",    // Captive Portal (RFC 8910)
+	38: "pref64",            // PREF64 (RFC 8781)
+}
+
+var ndpOptionOrder = []byte{1, 2, 3, 5, 24, 25, 31, 14, 37, 38, 4}
+
+// ndpOptionShortNames are column headers for the TUI.
+var ndpOptionShortNames = map[string]string{
+	"slla":              "SLLA",
+	"tlla":              "TLLA",
+	"prefix":            "PIO",
+	"redirected_header": "RdrH",
+	"mtu":               "MTU",
+	"nonce":             "Nonce",
+	"route_info":        "RIO",
+	"rdnss":             "RDNSS",
+	"dnssl":             "DNSSL",
+	"captive_portal":    "CapP",
+	"pref64":            "PR64",
+}
+
+// optionName returns the name of NDP option type t.
+func optionName(t byte) string {
+	if name, ok := ndpOptionNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type-%d", t)
+}
+
+// ndpOptionTypes returns the distinct option types in an NDP message, in the
+// order they first appear. buf is the full ICMPv6 message; nil is returned
+// for types without options.
+func ndpOptionTypes(buf []byte) []byte {
+	var types []byte
+	for oType := range ndpOptions(buf) {
+		if !slices.Contains(types, oType) {
+			types = append(types, oType)
+		}
+	}
+	return types
+}
+
+// optionCounts is how many of a peer's messages of one type carried each
+// option since the peer was first seen.
+type optionCounts struct {
+	messages int
+	options  map[byte]int
+}
+
+// OptionUsage is how often the peers of one stack include each NDP option
+// in one message type, since they were first seen. Stack is the guessed OS
+// (see GuessOS), "unknown" when nothing could be inferred.
+type OptionUsage struct {
+	Stack    string         `json:"stack"`
+	Kind     string         `json:"kind"`
+	Peers    int            `json:"peers"`
+	Messages int            `json:"messages"`
+	Options  map[string]int `json:"options"` // option name -> messages carrying it
+}
+
+// Share returns the fraction of the messages that carried option.
+func (u OptionUsage) Share(option string) float64 {
+	if u.Messages == 0 {
+		return 0
+	}
+	return float64(u.Options[option]) / float64(u.Messages)
+}
+
+// RecordOptions records the option types an NDP message from ip carried.
+// Messages of types without options are ignored.
+func (s *NDPStats) RecordOptions(ip string, ndpKind string, types []byte) {
+	switch ndpKind {
+	case "router_solicitation", "router_advertisement", "neighbor_solicitation", "neighbor_advertisement", "redirect":
+	default:
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peer := s.getOrCreatePeer(ip, s.now())
+	c := peer.Options[ndpKind]
+	if c == nil {
+		c = &optionCounts{options: make(map[byte]int)}
+		peer.Options[ndpKind] = c
+	}
+	c.messages++
+	for _, t := range types {
+		c.options[t]++
+	}
+}
+
+// GetOptionUsage returns the network-wide NDP option usage matrix.
+func (s *NDPStats) GetOptionUsage() []OptionUsage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.optionUsageLocked(s.summariesLocked(s.now()))
+}
+
+// optionUsageLocked aggregates the option counts of peers (ignored peers are
+// not among them) by stack and message type, ordered by stack then type.
+// Callers must hold s.mu.
+func (s *NDPStats) optionUsageLocked(peers []PeerSummary) []OptionUsage {
+	type key struct{ stack, kind string }
+	byKey := make(map[key]*OptionUsage)
+	for _, p := range peers {
+		ps, ok := s.peers[p.Address]
+		if !ok {
+			continue
+		}
+		stack := p.GuessedOS
+		if stack == "" {
+			stack = "unknown"
+		}
+		for kind, c := range ps.Options {
+			k := key{stack, kind}
+			u := byKey[k]
+			if u == nil {
+				u = &OptionUsage{Stack: stack, Kind: kind, Options: make(map[string]int)}
+				byKey[k] = u
+			}
+			u.Peers++
+			u.Messages += c.messages
+			for t, n := range c.options {
+				u.Options[optionName(t)] += n
+			}
+		}
+	}
+
+	out := make([]OptionUsage, 0, len(byKey))
+	for _, u := range byKey {
+		out = append(out, *u)
+	}
+	slices.SortFunc(out, func(a, b OptionUsage) int {
+		if c := strings.Compare(a.Stack, b.Stack); c != 0 {
+			return c
+		}
+		return slices.Index(msgColumnOrder, a.Kind) - slices.Index(msgColumnOrder, b.Kind)
+	})
+	return out
+}
+
+// optionColumns returns the option names used anywhere in usage, in
+// ndpOptionOrder and then by name, so the matrix only has columns for
+// options that were seen.
+func optionColumns(usage []OptionUsage) []string {
+	seen := make(map[string]bool)
+	for _, u := range usage {
+		for name, n := range u.Options {
+			if n > 0 {
+				seen[name] = true
+			}
+		}
+	}
+	var cols, others []string
+	for _, t := range ndpOptionOrder {
+		if name := optionName(t); seen[name] {
+			cols = append(cols, name)
+			delete(seen, name)
+		}
+	}
+	for name := range seen {
+		others = append(others, name)
+	}
+	slices.Sort(others)
+	return append(cols, others...)
+}
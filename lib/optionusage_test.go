@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"NDPeekr/lib/craft"
+)
+
+func TestNDPOptionTypes(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	nonce := []byte{14, 1, 1, 2, 3, 4, 5, 6}
+	ra := craft.BuildRA(craft.RA{
+		SourceMAC: mac,
+		MTU:       1500,
+		RDNSS:     []net.IP{net.ParseIP("2001:db8::53")},
+		Options:   [][]byte{nonce, {31, 0}}, // the zero-length DNSSL ends the walk
+	})
+	if got := ndpOptionTypes(ra); !slices.Equal(got, []byte{1, 5, 25, 14}) {
+		t.Errorf("RA option types = %v, want [1 5 25 14]", got)
+	}
+	if got := ndpOptionTypes(craft.BuildNS(craft.NS{Target: net.ParseIP("fe80::1")})); got != nil {
+		t.Errorf("DAD probe option types = %v, want none", got)
+	}
+	if got := ndpOptionTypes(craft.BuildMLDv1Report(net.ParseIP("ff02::fb"))); got != nil {
+		t.Errorf("MLD option types = %v, want none", got)
+	}
+}
+
+func TestOptionUsage(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	// Two Windows hosts, one of them leaving out the SLLA once.
+	for _, ip := range []string{"fe80::1", "fe80::2"} {
+		stats.RecordMLDMembership(ip, "ff02::1:3")
+		stats.RecordMessage(ip, "neighbor_solicitation")
+		stats.RecordOptions(ip, "neighbor_solicitation", []byte{1})
+	}
+	stats.RecordOptions("fe80::2", "neighbor_solicitation", nil)
+	stats.RecordMessage("fe80::9", "router_advertisement")
+	stats.RecordOptions("fe80::9", "router_advertisement", []byte{1, 3, 99})
+	stats.RecordOptions("fe80::9", "mld_report", []byte{1}) // no options in MLD
+
+	got := stats.GetOptionUsage()
+	if len(got) != 2 {
+		t.Fatalf("usage = %+v, want Windows NS and unknown RA", got)
+	}
+	if u := got[0]; u.Stack != "Windows" || u.Kind != "neighbor_solicitation" || u.Peers != 2 || u.Messages != 3 || u.Options["slla"] != 2 {
+		t.Errorf("Windows NS = %+v, want 2 peers, 3 messages, 2 with SLLA", u)
+	}
+	if u := got[1]; u.Stack != "unknown" || u.Kind != "router_advertisement" || u.Options["prefix"] != 1 || u.Options["type-99"] != 1 {
+		t.Errorf("unknown RA = %+v", u)
+	}
+
+	if cols := optionColumns(got); !slices.Equal(cols, []string{"slla", "prefix", "type-99"}) {
+		t.Errorf("columns = %v", cols)
+	}
+	rows := optionUsageRows(got)
+	if len(rows) != 3 || !strings.Contains(rows[0], "SLLA") || !strings.Contains(rows[1], "67%") {
+		t.Errorf("rows = %q", rows)
+	}
+	if snap := stats.Snapshot(); len(snap.OptionUsage) != 2 {
+		t.Errorf("snapshot option usage = %+v", snap.OptionUsage)
+	}
+}
@@ -0,0 +1,194 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Link-layer header types (https://www.tcpdump.org/linktypes.html) that can
+// carry IPv6 and are understood by the pcap replay backend.
+const (
+	linkTypeNull     = 0   // BSD loopback
+	linkTypeEthernet = 1   // Ethernet II, optionally 802.1Q tagged
+	linkTypeRaw      = 101 // raw IPv4/IPv6
+	linkTypeLinuxSLL = 113 // Linux "cooked" capture v1
+	linkTypeIPv6     = 229 // raw IPv6
+	linkTypeLinuxSL2 = 276 // Linux "cooked" capture v2
+)
+
+const (
+	pcapMagicMicro = 0xa1b2c3d4
+	pcapMagicNano  = 0xa1b23c4d
+	pcapngMagic    = 0x0a0d0d0a
+	etherTypeIPv6  = 0x86dd
+	etherTypeVLAN  = 0x8100
+	etherTypeQinQ  = 0x88a8
+)
+
+// pcapReader reads classic libpcap capture files (not pcapng).
+type pcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nano     bool
+	linkType uint32
+	buf      []byte
+}
+
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("read pcap header: %w", err)
+	}
+
+	p := &pcapReader{r: r, buf: make([]byte, 64*1024)}
+	switch {
+	case binary.LittleEndian.Uint32(hdr[0:4]) == pcapMagicMicro:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[0:4]) == pcapMagicMicro:
+		p.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(hdr[0:4]) == pcapMagicNano:
+		p.order, p.nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr[0:4]) == pcapMagicNano:
+		p.order, p.nano = binary.BigEndian, true
+	case binary.BigEndian.Uint32(hdr[0:4]) == pcapngMagic:
+		return nil, errors.New("pcapng is not supported; convert with: editcap -F pcap in.pcapng out.pcap")
+	default:
+		return nil, errors.New("not a pcap file")
+	}
+	p.linkType = p.order.Uint32(hdr[20:24]) & 0x0fffffff // upper bits hold FCS info
+	return p, nil
+}
+
+// next returns the next packet. The data slice is reused by the following call.
+func (p *pcapReader) next() (time.Time, []byte, error) {
+	var rec [16]byte
+	if _, err := io.ReadFull(p.r, rec[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return time.Time{}, nil, fmt.Errorf("truncated pcap record: %w", err)
+		}
+		return time.Time{}, nil, err
+	}
+	sec := int64(p.order.Uint32(rec[0:4]))
+	frac := int64(p.order.Uint32(rec[4:8]))
+	capLen := int(p.order.Uint32(rec[8:12]))
+	if !p.nano {
+		frac *= 1000
+	}
+
+	if capLen > len(p.buf) {
+		if capLen > 256*1024 {
+			return time.Time{}, nil, fmt.Errorf("pcap record of %d bytes is implausibly large", capLen)
+		}
+		p.buf = make([]byte, capLen)
+	}
+	data := p.buf[:capLen]
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return time.Time{}, nil, fmt.Errorf("truncated pcap record: %w", err)
+	}
+	return time.Unix(sec, frac), data, nil
+}
+
+// ipv6FromFrame strips the link-layer header and returns the IPv6 packet, or
+// false if the frame does not carry IPv6.
+func ipv6FromFrame(linkType uint32, frame []byte) ([]byte, bool) {
+	switch linkType {
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return nil, false
+		}
+		etherType := binary.BigEndian.Uint16(frame[12:14])
+		off := 14
+		for (etherType == etherTypeVLAN || etherType == etherTypeQinQ) && len(frame) >= off+4 {
+			etherType = binary.BigEndian.Uint16(frame[off+2 : off+4])
+			off += 4
+		}
+		if etherType != etherTypeIPv6 {
+			return nil, false
+		}
+		return frame[off:], true
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 || binary.BigEndian.Uint16(frame[14:16]) != etherTypeIPv6 {
+			return nil, false
+		}
+		return frame[16:], true
+	case linkTypeLinuxSL2:
+		if len(frame) < 20 || binary.BigEndian.Uint16(frame[0:2]) != etherTypeIPv6 {
+			return nil, false
+		}
+		return frame[20:], true
+	case linkTypeNull:
+		// 4-byte address family in host order; IPv6 is 10, 24, 28 or 30 depending on the OS.
+		if len(frame) < 4 {
+			return nil, false
+		}
+		return frame[4:], len(frame) > 4 && frame[4]>>4 == 6
+	case linkTypeRaw, linkTypeIPv6:
+		return frame, len(frame) > 0 && frame[0]>>4 == 6
+	default:
+		return nil, false
+	}
+}
+
+// runPcap replays a pcap file through the packet-level path as fast as it
+// can be read. Messages are recorded at the current time, not the capture
+// time. Returns nil once the file is exhausted.
+func (l *NDPListener) runPcap(ctx context.Context) error {
+	f, err := os.Open(l.cfg.PcapFile)
+	if err != nil {
+		return fmt.Errorf("open pcap: %w", err)
+	}
+	defer f.Close()
+
+	pr, err := newPcapReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", l.cfg.PcapFile, err)
+	}
+	l.ready()
+
+	packets, err := l.replay(ctx, pr, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%s: %w", l.cfg.PcapFile, err)
+	}
+	l.cfg.Logger.Info("pcap replay finished", "file", l.cfg.PcapFile, "packets", packets)
+	return nil
+}
+
+// replay feeds every packet pr reads through the packet-level path, calling
+// tick (if non-nil) with each packet's capture time before handling it. It
+// returns the number of packets read once pr is exhausted, or tick's error.
+func (l *NDPListener) replay(ctx context.Context, pr *pcapReader, tick func(time.Time) error) (int, error) {
+	packets := 0
+	for {
+		if ctx.Err() != nil {
+			return packets, ctx.Err()
+		}
+		ts, frame, err := pr.next()
+		if errors.Is(err, io.EOF) {
+			return packets, nil
+		}
+		if err != nil {
+			return packets, err
+		}
+		packets++
+		if tick != nil {
+			if err := tick(ts); err != nil {
+				return packets, err
+			}
+		}
+		if l.cfg.Mirror && pr.linkType == linkTypeEthernet {
+			l.replayAt = ts
+			l.handleFrame(frame, 0)
+		} else if pkt, ok := ipv6FromFrame(pr.linkType, frame); ok {
+			l.replayAt = ts
+			l.handlePacket(pkt, 0, "")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePcap builds a classic little-endian microsecond pcap file.
+func writePcap(linkType uint32, ts time.Time, frames ...[]byte) []byte {
+	var b bytes.Buffer
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], pcapMagicMicro)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:], linkType)
+	b.Write(hdr)
+	for _, f := range frames {
+		rec := make([]byte, 16)
+		binary.LittleEndian.PutUint32(rec[0:], uint32(ts.Unix()))
+		binary.LittleEndian.PutUint32(rec[4:], uint32(ts.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(rec[8:], uint32(len(f)))
+		binary.LittleEndian.PutUint32(rec[12:], uint32(len(f)))
+		b.Write(rec)
+		b.Write(f)
+	}
+	return b.Bytes()
+}
+
+// ethernetFrame wraps an IPv6 packet in an Ethernet header with one VLAN tag.
+func ethernetFrame(pkt []byte) []byte {
+	f := []byte{
+		0x33, 0x33, 0, 0, 0, 2, // dst
+		0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, // src
+		0x81, 0x00, 0x00, 0x0a, // 802.1Q, VLAN 10
+		0x86, 0xdd,
+	}
+	return append(f, pkt...)
+}
+
+func TestPcapReader(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 250_000_000, time.UTC)
+	pkt := buildIPv6Packet("fe80::1", "ff02::2", 255, nil, nhICMPv6, buildRS(nil))
+	pr, err := newPcapReader(bytes.NewReader(writePcap(linkTypeEthernet, ts, ethernetFrame(pkt))))
+	if err != nil {
+		t.Fatalf("newPcapReader: %v", err)
+	}
+
+	got, frame, err := pr.next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if !got.Equal(ts) {
+		t.Errorf("timestamp = %v, want %v", got, ts)
+	}
+	ip, ok := ipv6FromFrame(pr.linkType, frame)
+	if !ok || !bytes.Equal(ip, pkt) {
+		t.Errorf("ipv6FromFrame did not strip the tagged Ethernet header")
+	}
+	if _, _, err := pr.next(); !errors.Is(err, io.EOF) {
+		t.Errorf("after last record: err = %v, want EOF", err)
+	}
+}
+
+func TestPcapReader_RejectsPcapng(t *testing.T) {
+	ng := []byte{0x0a, 0x0d, 0x0d, 0x0a}
+	if _, err := newPcapReader(bytes.NewReader(append(ng, make([]byte, 20)...))); err == nil {
+		t.Error("pcapng: expected error")
+	}
+}
+
+func TestRunPcap(t *testing.T) {
+	good := buildIPv6Packet("fe80::1", "ff02::2", 255, nil, nhICMPv6, buildRS(net.HardwareAddr{1, 2, 3, 4, 5, 6}))
+	bad := buildIPv6Packet("fe80::2", "ff02::2", 255, nil, nhICMPv6, buildRS(nil))
+	bad[len(bad)-1] ^= 0x01
+
+	path := filepath.Join(t.TempDir(), "ndp.pcap")
+	if err := os.WriteFile(path, writePcap(linkTypeRaw, time.Now(), good, bad), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:    stats,
+		Capture:  CapturePcap,
+		PcapFile: path,
+	})
+	if err := l.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if peers := stats.GetStats(); len(peers) != 1 || peers[0].Address != "fe80::1" {
+		t.Errorf("peers = %+v, want only fe80::1", peers)
+	}
+	if got := stats.ChecksumFailures(); got != 1 {
+		t.Errorf("checksum failures = %d, want 1", got)
+	}
+}
@@ -0,0 +1,170 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// PacketRingConfig bounds a PacketRing. At least one limit must be set;
+// with both, packets are dropped by whichever is reached first.
+type PacketRingConfig struct {
+	Packets int           // keep at most this many packets (0 = no count limit)
+	Age     time.Duration // keep packets at most this old (0 = no age limit)
+}
+
+// ringPacket is one captured IPv6 packet.
+type ringPacket struct {
+	time time.Time
+	data []byte
+}
+
+// PacketRing keeps the most recent raw IPv6 packets from a packet-level
+// capture backend in memory, so the packets leading up to an alert can be
+// written to a pcap file after the fact. It is safe for concurrent use.
+type PacketRing struct {
+	cfg PacketRingConfig
+
+	mu      sync.Mutex
+	packets []ringPacket // oldest first
+}
+
+// NewPacketRing returns an empty ring.
+func NewPacketRing(cfg PacketRingConfig) (*PacketRing, error) {
+	if cfg.Packets <= 0 && cfg.Age <= 0 {
+		return nil, fmt.Errorf("packet ring needs a packet count or age limit")
+	}
+	return &PacketRing{cfg: cfg}, nil
+}
+
+// Add copies pkt into the ring and drops packets beyond the ring's limits.
+func (r *PacketRing) Add(now time.Time, pkt []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.packets = append(r.packets, ringPacket{time: now, data: append([]byte(nil), pkt...)})
+	drop := 0
+	if r.cfg.Packets > 0 && len(r.packets) > r.cfg.Packets {
+		drop = len(r.packets) - r.cfg.Packets
+	}
+	if r.cfg.Age > 0 {
+		for drop < len(r.packets) && now.Sub(r.packets[drop].time) > r.cfg.Age {
+			drop++
+		}
+	}
+	if drop > 0 {
+		// Shift rather than reslice so the backing array doesn't grow forever.
+		n := copy(r.packets, r.packets[drop:])
+		clear(r.packets[n:])
+		r.packets = r.packets[:n]
+	}
+}
+
+// Len returns the number of packets in the ring.
+func (r *PacketRing) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.packets)
+}
+
+// WritePcap writes the packets sent from or to addr, or every packet if addr
+// is empty, to w as a pcap file of raw IPv6 packets. It returns how many
+// packets it wrote.
+func (r *PacketRing) WritePcap(w io.Writer, addr string) (int, error) {
+	var match netip.Addr
+	if addr != "" {
+		a, err := netip.ParseAddr(addr)
+		if err != nil {
+			return 0, fmt.Errorf("packet ring: %w", err)
+		}
+		match = a.WithZone("")
+	}
+
+	r.mu.Lock()
+	packets := make([]ringPacket, 0, len(r.packets))
+	for _, p := range r.packets {
+		if !match.IsValid() || packetHasAddr(p.data, match) {
+			packets = append(packets, p) // data is never modified once added
+		}
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	if err := writePcapHeader(bw, linkTypeRaw); err != nil {
+		return 0, err
+	}
+	for _, p := range packets {
+		if err := writePcapRecord(bw, p.time, p.data); err != nil {
+			return 0, err
+		}
+	}
+	return len(packets), bw.Flush()
+}
+
+// writePcapHeader writes the header of a pcap file of linkType frames
+// (linkTypeRaw for raw IPv6 packets).
+func writePcapHeader(w io.Writer, linkType uint32) error {
+	var hdr [24]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], pcapMagicMicro)
+	binary.LittleEndian.PutUint16(hdr[4:6], 2) // version 2.4
+	binary.LittleEndian.PutUint16(hdr[6:8], 4)
+	binary.LittleEndian.PutUint32(hdr[16:20], 256*1024) // snaplen
+	binary.LittleEndian.PutUint32(hdr[20:24], linkType)
+	_, err := w.Write(hdr[:])
+	return err
+}
+
+// writePcapRecord writes one packet captured at t.
+func writePcapRecord(w io.Writer, t time.Time, data []byte) error {
+	var rec [16]byte
+	binary.LittleEndian.PutUint32(rec[0:4], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:8], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:12], uint32(len(data)))
+	binary.LittleEndian.PutUint32(rec[12:16], uint32(len(data)))
+	if _, err := w.Write(rec[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// WritePcapFile writes every packet in the ring to a timestamped pcap file
+// in dir, named after the instance if it has a name, and returns its path.
+func (r *PacketRing) WritePcapFile(dir, instance string, now time.Time) (string, error) {
+	path := filepath.Join(dir, instanceFileName("ring", instance, now.Format("20060102-150405.000"), ".pcap"))
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("write %s: %w", tmp, err)
+	}
+	_, err = r.WritePcap(f, "")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("rename %s: %w", tmp, err)
+	}
+	return path, nil
+}
+
+// packetHasAddr reports whether the IPv6 packet's source or destination is
+// addr.
+func packetHasAddr(pkt []byte, addr netip.Addr) bool {
+	if len(pkt) < 40 {
+		return false
+	}
+	src := netip.AddrFrom16([16]byte(pkt[8:24]))
+	dst := netip.AddrFrom16([16]byte(pkt[24:40]))
+	return src == addr || dst == addr
+}
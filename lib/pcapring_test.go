@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPacketRing_Limits(t *testing.T) {
+	now := time.Now()
+	pkt := buildIPv6Packet("fe80::1", "ff02::2", 255, nil, nhICMPv6, buildRS(nil))
+
+	r, err := NewPacketRing(PacketRingConfig{Packets: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 5 {
+		r.Add(now.Add(time.Duration(i)*time.Second), pkt)
+	}
+	if got := r.Len(); got != 3 {
+		t.Errorf("count-limited ring holds %d packets, want 3", got)
+	}
+
+	r, _ = NewPacketRing(PacketRingConfig{Age: 10 * time.Second})
+	for i := range 5 {
+		r.Add(now.Add(time.Duration(i)*5*time.Second), pkt)
+	}
+	if got := r.Len(); got != 3 { // 10s, 15s and 20s are within 10s of 20s
+		t.Errorf("age-limited ring holds %d packets, want 3", got)
+	}
+
+	if _, err := NewPacketRing(PacketRingConfig{}); err == nil {
+		t.Error("expected an error for a ring without limits")
+	}
+}
+
+func TestPacketRing_WritePcap(t *testing.T) {
+	now := time.Now()
+	r, _ := NewPacketRing(PacketRingConfig{Packets: 10})
+	rs := buildIPv6Packet("fe80::1", "ff02::2", 255, nil, nhICMPv6, buildRS(nil))
+	ns := buildIPv6Packet("fe80::2", "fe80::1", 255, nil, nhICMPv6, buildNS(net.ParseIP("fe80::1"), nil))
+	other := buildIPv6Packet("fe80::3", "ff02::1", 255, nil, nhICMPv6, buildRS(nil))
+	r.Add(now, rs)
+	r.Add(now, ns)
+	r.Add(now, other)
+
+	var b bytes.Buffer
+	n, err := r.WritePcap(&b, "fe80::1")
+	if err != nil {
+		t.Fatalf("WritePcap: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("wrote %d packets for fe80::1, want 2", n)
+	}
+
+	pr, err := newPcapReader(&b)
+	if err != nil {
+		t.Fatalf("reading back: %v", err)
+	}
+	var got [][]byte
+	for {
+		ts, frame, err := pr.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ts.Equal(now.Truncate(time.Microsecond)) {
+			t.Errorf("timestamp = %v, want %v", ts, now)
+		}
+		pkt, ok := ipv6FromFrame(pr.linkType, frame)
+		if !ok {
+			t.Fatalf("record is not IPv6")
+		}
+		got = append(got, append([]byte(nil), pkt...))
+	}
+	if len(got) != 2 || !bytes.Equal(got[0], rs) || !bytes.Equal(got[1], ns) {
+		t.Errorf("read back %d packets, want the RS and NS in order", len(got))
+	}
+
+	path, err := r.WritePcapFile(t.TempDir(), "", now)
+	if err != nil {
+		t.Fatalf("WritePcapFile: %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() != 24+3*16+int64(len(rs)+len(ns)+len(other)) {
+		t.Errorf("pcap file %s has the wrong size", path)
+	}
+}
+
+func TestHandlePacket_FeedsRing(t *testing.T) {
+	r, _ := NewPacketRing(PacketRingConfig{Packets: 10})
+	l := NewNDPListener(NDPListenerConfig{Stats: NewNDPStats(time.Minute), Ring: r})
+	pkt := buildIPv6Packet("fe80::1", "ff02::2", 255, nil, nhICMPv6, buildRS(nil))
+	l.handlePacket(pkt, 0, "")
+	pkt[8] = 0 // the ring keeps its own copy of a reused buffer
+
+	var b bytes.Buffer
+	if n, _ := r.WritePcap(&b, "fe80::1"); n != 1 {
+		t.Errorf("ring has %d packets from fe80::1, want 1", n)
+	}
+}
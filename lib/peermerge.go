@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MergePeers folds peers that share an address on different links
+// (fe80::1%eth0, fe80::1%eth1) into one summary keyed by the bare address.
+// Counts, bytes and totals are summed, groups and undefended targets combined, and
+// Interface lists every link; the other fields come from the peer seen most
+// recently. The result is sorted by total, chattiest first.
+func MergePeers(peers []PeerSummary) []PeerSummary {
+	var out []PeerSummary
+	index := make(map[string]int)
+	ifaces := make(map[string][]string)
+	for _, p := range peers {
+		addr := unzoned(p.Address)
+		link := p.Interface
+		if link == "" {
+			link = addrKey(p.Address).Zone()
+		}
+		i, ok := index[addr]
+		if !ok {
+			m := p
+			m.Address = addr
+			m.Addr = addrKey(addr)
+			m.Counts = make(map[string]int, len(p.Counts))
+			for k, n := range p.Counts {
+				m.Counts[k] = n
+			}
+			m.Bytes = make(map[string]int, len(p.Bytes))
+			for k, n := range p.Bytes {
+				m.Bytes[k] = n
+			}
+			m.Groups = slices.Clone(p.Groups)
+			m.Undefended = slices.Clone(p.Undefended)
+			index[addr] = len(out)
+			out = append(out, m)
+			if link != "" {
+				ifaces[addr] = []string{link}
+			}
+			continue
+		}
+		m := &out[i]
+		if p.LastSeen.After(m.LastSeen) {
+			counts, groups, undefended, total := m.Counts, m.Groups, m.Undefended, m.Total
+			first, oversized, noRA, stale := m.FirstSeen, m.Oversized, m.NoRouterAlert, m.Stale
+			bytes, totalBytes := m.Bytes, m.TotalBytes
+			*m = p
+			m.Address, m.Addr = addr, addrKey(addr)
+			m.Counts, m.Groups, m.Undefended, m.Total = counts, groups, undefended, total
+			m.FirstSeen, m.Oversized, m.NoRouterAlert, m.Stale = first, oversized, noRA, stale
+			m.Bytes, m.TotalBytes = bytes, totalBytes
+		}
+		for k, n := range p.Counts {
+			m.Counts[k] += n
+		}
+		for k, n := range p.Bytes {
+			m.Bytes[k] += n
+		}
+		m.Total += p.Total
+		m.TotalBytes += p.TotalBytes
+		m.Oversized += p.Oversized
+		m.NoRouterAlert += p.NoRouterAlert
+		m.Stale = m.Stale && p.Stale
+		m.FirstSeen = minTime(m.FirstSeen, p.FirstSeen)
+		m.Groups = mergeSorted(m.Groups, p.Groups)
+		m.Undefended = mergeSorted(m.Undefended, p.Undefended)
+		if link != "" && !slices.Contains(ifaces[addr], link) {
+			ifaces[addr] = append(ifaces[addr], link)
+		}
+	}
+	for i := range out {
+		if links := ifaces[out[i].Address]; len(links) > 1 {
+			sort.Strings(links)
+			out[i].Interface = strings.Join(links, ",")
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Total > out[j].Total })
+	return out
+}
+
+// mergeSorted returns the sorted union of a and b.
+func mergeSorted(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	out := append(slices.Clone(a), b...)
+	sort.Strings(out)
+	return slices.Compact(out)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestScopeByInterface(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:            stats,
+		ScopeByInterface: true,
+	})
+	l.handle(received{src: netip.MustParseAddr("2001:db8::1%vlan10"), payload: buildRS(nil)})
+	l.handle(received{src: netip.MustParseAddr("2001:db8::1%vlan20"), payload: buildRS(nil)})
+	l.handle(received{src: netip.MustParseAddr("2001:db8::1%vlan20"), payload: buildRS(nil)})
+	l.handle(received{src: netip.MustParseAddr("::"), payload: buildRS(nil)})
+
+	peers := stats.GetStats()
+	byAddr := make(map[string]PeerSummary)
+	for _, p := range peers {
+		byAddr[p.Address] = p
+	}
+	if len(peers) != 3 || byAddr["2001:db8::1%vlan10"].Total != 1 || byAddr["2001:db8::1%vlan20"].Total != 2 {
+		t.Fatalf("peers = %+v, want 2001:db8::1 once per VLAN and ::", peers)
+	}
+
+	merged := MergePeers(peers)
+	if len(merged) != 2 {
+		t.Fatalf("merged = %+v, want 2 peers", merged)
+	}
+	m := merged[0]
+	if m.Address != "2001:db8::1" || m.Total != 3 || m.Counts["router_solicitation"] != 3 || m.Interface != "vlan10,vlan20" {
+		t.Errorf("merged peer = %+v, want 2001:db8::1 with 3 RS on vlan10,vlan20", m)
+	}
+}
+
+func TestMergePeers(t *testing.T) {
+	now := time.Now()
+	peers := []PeerSummary{
+		{Address: "fe80::1%eth0", Interface: "eth0", MAC: "02:00:00:00:00:01", Total: 2,
+			Counts: map[string]int{"neighbor_solicitation": 2}, Groups: []string{"ff02::1", "ff02::fb"},
+			FirstSeen: now.Add(-time.Hour), LastSeen: now.Add(-time.Minute), Stale: true},
+		{Address: "fe80::1%eth1", Interface: "eth1", MAC: "02:00:00:00:00:02", Total: 1,
+			Counts: map[string]int{"neighbor_solicitation": 1}, Groups: []string{"ff02::1"},
+			FirstSeen: now.Add(-time.Minute), LastSeen: now},
+		{Address: "fe80::2%eth0", Interface: "eth0", Total: 5},
+	}
+	merged := MergePeers(peers)
+	if len(merged) != 2 || merged[0].Address != "fe80::2" {
+		t.Fatalf("merged = %+v, want fe80::2 first, then fe80::1", merged)
+	}
+	m := merged[1]
+	if m.Address != "fe80::1" || m.Addr != netip.MustParseAddr("fe80::1") {
+		t.Errorf("address = %q (%v), want fe80::1", m.Address, m.Addr)
+	}
+	if m.Total != 3 || m.Counts["neighbor_solicitation"] != 3 {
+		t.Errorf("totals = %d %v, want 3", m.Total, m.Counts)
+	}
+	if m.MAC != "02:00:00:00:00:02" || !m.LastSeen.Equal(now) || !m.FirstSeen.Equal(now.Add(-time.Hour)) {
+		t.Errorf("merged = %+v, want the latest MAC and the full seen range", m)
+	}
+	if len(m.Groups) != 2 || m.Interface != "eth0,eth1" || m.Stale {
+		t.Errorf("merged = %+v, want both groups, both links and not stale", m)
+	}
+	if peers[0].Counts["neighbor_solicitation"] != 2 {
+		t.Error("MergePeers modified its input")
+	}
+}
@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// Popularity is how much other peers solicit an address: the Neighbor
+// Solicitations targeting it within the window and who sent them. Servers and
+// gateways are solicited by many peers; a host only its router resolves is
+// solicited by one. DAD probes are not counted.
+type Popularity struct {
+	Solicitations int      `json:"solicitations"`
+	Solicitors    []string `json:"solicitors"` // peers that solicited the address, sorted
+	// Inter-arrival percentiles of the solicitations; zero with fewer than two.
+	IntervalP50 time.Duration `json:"interval_p50,omitempty"`
+	IntervalP90 time.Duration `json:"interval_p90,omitempty"`
+	IntervalP99 time.Duration `json:"interval_p99,omitempty"`
+}
+
+// SolicitedTarget is the popularity of one solicited address, whether or not
+// it ever sent anything itself.
+type SolicitedTarget struct {
+	Target string `json:"target"`
+	Popularity
+}
+
+// GetSolicitedTargets returns every address solicited within the window,
+// most popular (most distinct solicitors, then most solicitations) first.
+func (s *NDPStats) GetSolicitedTargets() []SolicitedTarget {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.solicitedTargetsLocked(s.now())
+}
+
+// solicitedTargetsLocked computes GetSolicitedTargets. Callers must hold s.mu.
+func (s *NDPStats) solicitedTargetsLocked(now time.Time) []SolicitedTarget {
+	cutoff := now.Add(-s.window)
+	var result []SolicitedTarget
+	for target, ns := range s.nsTargets {
+		if p, ok := ns.popularity(cutoff); ok {
+			result = append(result, SolicitedTarget{Target: target, Popularity: p})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if len(a.Solicitors) != len(b.Solicitors) {
+			return len(a.Solicitors) > len(b.Solicitors)
+		}
+		if a.Solicitations != b.Solicitations {
+			return a.Solicitations > b.Solicitations
+		}
+		return a.Target < b.Target
+	})
+	return result
+}
+
+// popularity summarises the solicitations after cutoff; ok is false if
+// there were none.
+func (ns *nsTarget) popularity(cutoff time.Time) (p Popularity, ok bool) {
+	var gaps []time.Duration
+	var prev time.Time
+	for _, a := range ns.arrivals {
+		if !a.at.After(cutoff) {
+			continue
+		}
+		if p.Solicitations > 0 {
+			gaps = append(gaps, a.at.Sub(prev))
+		}
+		prev = a.at
+		p.Solicitations++
+		p.Solicitors = append(p.Solicitors, a.from)
+	}
+	if p.Solicitations == 0 {
+		return Popularity{}, false
+	}
+	sort.Strings(p.Solicitors)
+	p.Solicitors = slices.Compact(p.Solicitors)
+	if len(gaps) > 0 {
+		sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+		p.IntervalP50 = percentile(gaps, 50)
+		p.IntervalP90 = percentile(gaps, 90)
+		p.IntervalP99 = percentile(gaps, 99)
+	}
+	return p, true
+}
+
+// percentile returns the nearest-rank pth percentile of sorted, which must
+// not be empty.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// solicitations and solicitors are nil-safe accessors for filters.
+func (p *Popularity) solicitations() int {
+	if p == nil {
+		return 0
+	}
+	return p.Solicitations
+}
+
+func (p *Popularity) solicitors() []string {
+	if p == nil {
+		return nil
+	}
+	return p.Solicitors
+}
@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestSolicitedTargets(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	now := time.Now()
+
+	// The gateway is resolved by three hosts; a printer by one, twice.
+	for i, host := range []string{"fe80::a", "fe80::b", "fe80::c"} {
+		stats.RecordMessage(host, "neighbor_solicitation")
+		stats.RecordNSTarget(host, "fe80::1", now.Add(time.Duration(i)*10*time.Second))
+	}
+	stats.RecordNSTarget("fe80::a", "fe80::1", now.Add(time.Minute))
+	stats.RecordNSTarget("fe80::a", "fe80::99", now.Add(-time.Second))
+	stats.RecordNSTarget("fe80::a", "fe80::99", now)
+	stats.RecordMessage("fe80::1", "router_advertisement")
+
+	// Solicitations before the window don't count.
+	stats.RecordNSTarget("fe80::d", "fe80::1", now.Add(-10*time.Minute))
+
+	targets := stats.GetSolicitedTargets()
+	if len(targets) != 2 || targets[0].Target != "fe80::1" || targets[1].Target != "fe80::99" {
+		t.Fatalf("targets = %+v, want fe80::1 then fe80::99", targets)
+	}
+	gw := targets[0]
+	if gw.Solicitations != 4 || len(gw.Solicitors) != 3 || gw.Solicitors[0] != "fe80::a" {
+		t.Errorf("fe80::1 = %+v, want 4 solicitations from fe80::a, b and c", gw)
+	}
+	// Gaps of 10s, 10s and 40s.
+	if gw.IntervalP50 != 10*time.Second || gw.IntervalP90 != 40*time.Second || gw.IntervalP99 != 40*time.Second {
+		t.Errorf("fe80::1 intervals = %v/%v/%v, want 10s/40s/40s", gw.IntervalP50, gw.IntervalP90, gw.IntervalP99)
+	}
+	if p := targets[1]; p.Solicitations != 2 || p.IntervalP50 != time.Second {
+		t.Errorf("fe80::99 = %+v", p)
+	}
+
+	f, err := ParseFilter("solicitors >= 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	popular := FilterPeers(stats.GetStats(), f)
+	if len(popular) != 1 || popular[0].Address != "fe80::1" || popular[0].Popularity.Solicitations != 4 {
+		t.Errorf("popular peers = %+v, want fe80::1", popular)
+	}
+}
+
+func TestSolicitorsAreScopedLikePeers(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	l.handle(received{src: netip.MustParseAddr("fe80::a%eth0"), payload: buildNS(net.ParseIP("fe80::1"), nil)})
+	l.handle(received{src: netip.MustParseAddr("::"), payload: buildNS(net.ParseIP("fe80::2"), nil)})
+
+	targets := stats.GetSolicitedTargets()
+	if len(targets) != 1 {
+		t.Fatalf("targets = %+v, want only fe80::1%%eth0 (DAD probes are not solicitations)", targets)
+	}
+	if tt := targets[0]; tt.Target != "fe80::1%eth0" || len(tt.Solicitors) != 1 || tt.Solicitors[0] != "fe80::a%eth0" {
+		t.Errorf("target = %+v", tt)
+	}
+}
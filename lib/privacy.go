@@ -0,0 +1,201 @@
+package lib
+
+import (
+	"net"
+	"slices"
+)
+
+// PrivacyConfig keeps device identifiers out of everything NDPeekr stores or
+// ships: the history and state databases, freeze snapshots, evidence
+// bundles and the sinks. The TUI and API still show them live. Counts, groups, vendors and
+// the other aggregate statistics are kept. A nil PrivacyConfig changes
+// nothing.
+type PrivacyConfig struct {
+	// DropMACs removes MAC addresses, including those mentioned in alert
+	// messages, and leaves raw packets out of evidence bundles.
+	DropMACs bool `yaml:"drop_macs"`
+	// DropHostnames removes reverse DNS hostnames and inventory names.
+	DropHostnames bool `yaml:"drop_hostnames"`
+}
+
+// macPlaceholder replaces a MAC dropped from free text.
+const macPlaceholder = "(mac)"
+
+// Peer returns p without the dropped identifiers.
+func (c *PrivacyConfig) Peer(p PeerSummary) PeerSummary {
+	if c == nil {
+		return p
+	}
+	if c.DropMACs {
+		p.MAC, p.MACHistory = "", nil
+	}
+	p.Enrichment = c.enrichment(p.Enrichment)
+	return p
+}
+
+// PersistedPeer returns p without its MACs when MACs are dropped.
+func (c *PrivacyConfig) PersistedPeer(p PersistedPeer) PersistedPeer {
+	if c != nil && c.DropMACs {
+		p.MAC, p.MACHistory = "", nil
+	}
+	return p
+}
+
+// Router returns r without its MAC when MACs are dropped.
+func (c *PrivacyConfig) Router(r RouterInfo) RouterInfo {
+	if c != nil && c.DropMACs {
+		r.MAC = ""
+	}
+	return r
+}
+
+func (c *PrivacyConfig) enrichment(e Enrichment) Enrichment {
+	if c.DropHostnames {
+		e.Hostname, e.Name = "", ""
+	}
+	return e
+}
+
+func (c *PrivacyConfig) enrichmentPtr(e *Enrichment) *Enrichment {
+	if e == nil || !c.DropHostnames {
+		return e
+	}
+	out := c.enrichment(*e)
+	return &out
+}
+
+// Event returns ev without the dropped identifiers.
+func (c *PrivacyConfig) Event(ev Event) Event {
+	if c == nil {
+		return ev
+	}
+	if c.DropMACs {
+		ev.MAC = ""
+	}
+	if ev.Router != nil && c.DropMACs {
+		r := c.Router(*ev.Router)
+		ev.Router = &r
+	}
+	ev.Peer = c.enrichmentPtr(ev.Peer)
+	return ev
+}
+
+// Alert returns a without the dropped identifiers. MACs in the message are
+// replaced with "(mac)"; hostnames in it are left alone.
+func (c *PrivacyConfig) Alert(a Alert) Alert {
+	if c == nil {
+		return a
+	}
+	if c.DropMACs {
+		a.Source, a.Message = dropMACs(a.Source), dropMACs(a.Message)
+		if a.Binding != nil {
+			b := c.binding(*a.Binding)
+			a.Binding = &b
+		}
+		if a.DAD != nil {
+			d := c.dad(*a.DAD)
+			a.DAD = &d
+		}
+	}
+	a.Peer = c.enrichmentPtr(a.Peer)
+	return a
+}
+
+// binding returns b with its MACs replaced by macPlaceholder, so flips
+// still show when MACs are dropped.
+func (c *PrivacyConfig) binding(b BindingChange) BindingChange {
+	b.MAC = macPlaceholder
+	if b.OldMAC != "" {
+		b.OldMAC = macPlaceholder
+	}
+	return b
+}
+
+// dad returns d with its MACs replaced by macPlaceholder, so conflicts
+// still show when MACs are dropped.
+func (c *PrivacyConfig) dad(d DADTransaction) DADTransaction {
+	if d.Requester != "" {
+		d.Requester = macPlaceholder
+	}
+	d.MACs = slices.Clone(d.MACs)
+	for i := range d.MACs {
+		d.MACs[i] = macPlaceholder
+	}
+	return d
+}
+
+// dropMACs replaces every MAC in s with macPlaceholder.
+func dropMACs(s string) string {
+	return anonymizeToken.ReplaceAllStringFunc(s, func(tok string) string {
+		if mac, err := net.ParseMAC(tok); err == nil && len(mac) == 6 {
+			return macPlaceholder
+		}
+		return tok
+	})
+}
+
+// Snapshot returns a copy of snap without the dropped identifiers. snap
+// itself, which may be shared, is left untouched.
+func (c *PrivacyConfig) Snapshot(snap Snapshot) Snapshot {
+	if c == nil || (!c.DropMACs && !c.DropHostnames) {
+		return snap
+	}
+	snap.Peers = slices.Clone(snap.Peers)
+	for i := range snap.Peers {
+		snap.Peers[i] = c.Peer(snap.Peers[i])
+	}
+	snap.Alerts = slices.Clone(snap.Alerts)
+	for i := range snap.Alerts {
+		snap.Alerts[i] = c.Alert(snap.Alerts[i])
+	}
+	if !c.DropMACs {
+		return snap
+	}
+	snap.Routers = slices.Clone(snap.Routers)
+	for i := range snap.Routers {
+		snap.Routers[i] = c.Router(snap.Routers[i])
+	}
+	snap.Gone = slices.Clone(snap.Gone)
+	for i := range snap.Gone {
+		snap.Gone[i].RouterInfo = c.Router(snap.Gone[i].RouterInfo)
+	}
+	snap.Bindings = slices.Clone(snap.Bindings)
+	for i := range snap.Bindings {
+		snap.Bindings[i] = c.binding(snap.Bindings[i])
+	}
+	snap.DAD = slices.Clone(snap.DAD)
+	for i := range snap.DAD {
+		snap.DAD[i] = c.dad(snap.DAD[i])
+	}
+	snap.VirtualRouters = slices.Clone(snap.VirtualRouters)
+	for i := range snap.VirtualRouters {
+		snap.VirtualRouters[i].MasterMAC = ""
+	}
+	return snap
+}
+
+// PrivateSinks wraps sinks so the events and alerts they receive go through
+// c first. With c nil it returns sinks as they are.
+func PrivateSinks(sinks []Sink, c *PrivacyConfig) []Sink {
+	if c == nil {
+		return sinks
+	}
+	out := make([]Sink, len(sinks))
+	for i, s := range sinks {
+		out[i] = privateSink{Sink: s, privacy: c}
+	}
+	return out
+}
+
+type privateSink struct {
+	Sink
+	privacy *PrivacyConfig
+}
+
+func (s privateSink) WriteEvent(ev Event) error {
+	return s.Sink.WriteEvent(s.privacy.Event(ev))
+}
+
+func (s privateSink) WriteAlert(a Alert) error {
+	return s.Sink.WriteAlert(s.privacy.Alert(a))
+}
@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrivacyConfig_Snapshot(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordMAC("fe80::1", "00:11:22:33:44:55")
+	stats.SetEnrichment("fe80::1", Enrichment{Hostname: "alice-laptop.example", Vendor: "Acme", Name: "alice"})
+	stats.RecordRouter(RouterInfo{Address: "fe80::fe", MAC: "00:11:22:33:44:fe", Lifetime: time.Minute})
+	stats.RecordAlert(Alert{Time: time.Now(), Category: "mac_change", Source: "fe80::1",
+		Message: "MAC changed from 00:11:22:33:44:55 to 00:11:22:33:44:66 at 12:00:01"})
+	snap := stats.Snapshot()
+
+	c := &PrivacyConfig{DropMACs: true, DropHostnames: true}
+	got := c.Snapshot(snap)
+	p := got.Peers[0]
+	if p.MAC != "" || p.Hostname != "" || p.Name != "" || p.Vendor != "Acme" || p.Total != 1 {
+		t.Errorf("peer = %+v, want MAC, hostname and name dropped, vendor and counts kept", p)
+	}
+	if got.Routers[0].MAC != "" {
+		t.Errorf("router MAC %q kept", got.Routers[0].MAC)
+	}
+	if want := "MAC changed from (mac) to (mac) at 12:00:01"; got.Alerts[0].Message != want {
+		t.Errorf("alert message = %q, want %q", got.Alerts[0].Message, want)
+	}
+	if snap.Peers[0].MAC == "" || snap.Routers[0].MAC == "" || snap.Peers[0].Hostname == "" {
+		t.Error("the original snapshot was changed")
+	}
+
+	var none *PrivacyConfig
+	if got := none.Peer(snap.Peers[0]); got.MAC == "" {
+		t.Error("a nil PrivacyConfig dropped the MAC")
+	}
+}
+
+func TestPrivacyConfig_History(t *testing.T) {
+	db, err := OpenHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordMAC("fe80::1", "00:11:22:33:44:55")
+	r := NewHistoryRecorder(HistoryRecorderConfig{DB: db, Stats: stats, Privacy: &PrivacyConfig{DropMACs: true}})
+	r.record()
+
+	var mac string
+	var total int
+	if err := db.db.QueryRow(`SELECT mac, total FROM peers`).Scan(&mac, &total); err != nil {
+		t.Fatal(err)
+	}
+	if mac != "" || total != 1 {
+		t.Errorf("stored mac %q, total %d; want no MAC and the count", mac, total)
+	}
+}
+
+func TestPrivateSinks(t *testing.T) {
+	rec := &recordingSink{}
+	sinks := PrivateSinks([]Sink{rec}, &PrivacyConfig{DropMACs: true, DropHostnames: true})
+	_ = sinks[0].WriteEvent(Event{Src: "fe80::1", MAC: "00:11:22:33:44:55", Peer: &Enrichment{Hostname: "h", Vendor: "v"}})
+	_ = sinks[0].WriteAlert(Alert{Source: "fe80::1", Message: "from 00:11:22:33:44:55"})
+	if ev := rec.events[0]; ev.MAC != "" || ev.Peer.Hostname != "" || ev.Peer.Vendor != "v" {
+		t.Errorf("event = %+v, peer %+v", ev, ev.Peer)
+	}
+	if a := rec.alerts[0]; a.Message != "from (mac)" {
+		t.Errorf("alert message = %q", a.Message)
+	}
+}
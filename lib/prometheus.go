@@ -0,0 +1,401 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetricsHandler serves the current stats in the Prometheus text exposition format.
+// Every scrape is rendered from the shared Snapshot so the metrics are mutually consistent.
+// With a node name set (Status.Node), every sample carries a node label.
+func MetricsHandler(stats *NDPStats) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		snap := *stats.Shared()
+		if snap.Node == "" {
+			writeMetrics(w, snap, stats.GetAlertCounts())
+			return
+		}
+		var buf bytes.Buffer
+		writeMetrics(&buf, snap, stats.GetAlertCounts())
+		writeNodeLabeled(w, buf.Bytes(), snap.Node)
+	})
+}
+
+// writeNodeLabeled copies the exposition text in metrics to w, adding a
+// node label to every sample.
+func writeNodeLabeled(w io.Writer, metrics []byte, node string) {
+	label := `node="` + promLabelEscape(node) + `"`
+	for _, line := range strings.SplitAfter(string(metrics), "\n") {
+		i := strings.IndexAny(line, "{ ")
+		switch {
+		case i < 0 || strings.HasPrefix(line, "#"):
+			io.WriteString(w, line)
+		case line[i] == '{':
+			io.WriteString(w, line[:i+1]+label+","+line[i+1:])
+		default:
+			io.WriteString(w, line[:i]+"{"+label+"}"+line[i:])
+		}
+	}
+}
+
+// ServePrometheus runs an HTTP server exposing /metrics until ctx is cancelled.
+func ServePrometheus(ctx context.Context, cfg PrometheusSinkConfig, stats *NDPStats, logger *slog.Logger) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler(stats))
+
+	logger.Info("serving prometheus metrics", "listen", cfg.Listen)
+	if err := serveHTTP(ctx, cfg.Listen, mux); err != nil {
+		return fmt.Errorf("prometheus sink: %w", err)
+	}
+	return nil
+}
+
+// serveHTTP serves handler on addr until ctx is cancelled.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func writeMetrics(w io.Writer, snap Snapshot, alertCounts []AlertCount) {
+	fmt.Fprintln(w, "# HELP ndpeekr_peers Peers with activity in the sliding window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_peers gauge")
+	fmt.Fprintf(w, "ndpeekr_peers %d\n", len(snap.Peers))
+
+	macs := make(map[string]bool)
+	for _, p := range snap.Peers {
+		if p.MAC != "" {
+			macs[p.MAC] = true
+		}
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_macs Distinct link-layer addresses of the peers in the window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_macs gauge")
+	fmt.Fprintf(w, "ndpeekr_macs %d\n", len(macs))
+
+	fmt.Fprintln(w, "# HELP ndpeekr_routers Routers observed via Router Advertisements.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_routers gauge")
+	fmt.Fprintf(w, "ndpeekr_routers %d\n", len(snap.Routers))
+
+	fmt.Fprintln(w, "# HELP ndpeekr_multicast_groups Multicast groups with at least one member in the window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_multicast_groups gauge")
+	fmt.Fprintf(w, "ndpeekr_multicast_groups %d\n", len(snap.Groups))
+
+	fmt.Fprintln(w, "# HELP ndpeekr_window_messages Messages of each type within the sliding window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_window_messages gauge")
+	for _, t := range snap.Summary.Types {
+		fmt.Fprintf(w, "ndpeekr_window_messages{type=\"%s\"} %d\n", promLabelEscape(t.Kind), t.Count)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_window_message_rate Messages of each type per second, averaged over the sliding window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_window_message_rate gauge")
+	for _, t := range snap.Summary.Types {
+		fmt.Fprintf(w, "ndpeekr_window_message_rate{type=\"%s\"} %g\n", promLabelEscape(t.Kind), t.Rate)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_window_bytes ICMPv6 payload bytes of each message type within the sliding window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_window_bytes gauge")
+	for _, t := range snap.Summary.Types {
+		fmt.Fprintf(w, "ndpeekr_window_bytes{type=\"%s\"} %d\n", promLabelEscape(t.Kind), t.Bytes)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_window_byte_rate Bytes of each message type per second, averaged over the sliding window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_window_byte_rate gauge")
+	for _, t := range snap.Summary.Types {
+		fmt.Fprintf(w, "ndpeekr_window_byte_rate{type=\"%s\"} %g\n", promLabelEscape(t.Kind), t.ByteRate)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_window_senders Peers that sent each message type within the sliding window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_window_senders gauge")
+	for _, t := range snap.Summary.Types {
+		fmt.Fprintf(w, "ndpeekr_window_senders{type=\"%s\"} %d\n", promLabelEscape(t.Kind), t.Senders)
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_message_size_bytes ICMPv6 payload size of NDP/MLD messages since startup.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_message_size_bytes histogram")
+	kinds := make([]string, 0, len(snap.Sizes))
+	for kind := range snap.Sizes {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		h := snap.Sizes[kind]
+		cumulative := 0
+		for i, bound := range sizeBucketBounds {
+			cumulative += h.Buckets[i]
+			fmt.Fprintf(w, "ndpeekr_message_size_bytes_bucket{type=\"%s\",le=\"%d\"} %d\n", promLabelEscape(kind), bound-1, cumulative)
+		}
+		fmt.Fprintf(w, "ndpeekr_message_size_bytes_bucket{type=\"%s\",le=\"+Inf\"} %d\n", promLabelEscape(kind), h.Count)
+		fmt.Fprintf(w, "ndpeekr_message_size_bytes_sum{type=\"%s\"} %d\n", promLabelEscape(kind), h.Sum)
+		fmt.Fprintf(w, "ndpeekr_message_size_bytes_count{type=\"%s\"} %d\n", promLabelEscape(kind), h.Count)
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_unexpected_ext_header_packets_total ICMPv6 packets with an unexpected IPv6 extension header chain (packet capture only).")
+	fmt.Fprintln(w, "# TYPE ndpeekr_unexpected_ext_header_packets_total counter")
+	reasons := make([]string, 0, len(snap.ExtHeaders))
+	for reason := range snap.ExtHeaders {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "ndpeekr_unexpected_ext_header_packets_total{reason=\"%s\"} %d\n", promLabelEscape(reason), snap.ExtHeaders[reason])
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_mld_router_alert_violations_total MLD messages without a valid Hop-by-Hop Router Alert option (packet capture only).")
+	fmt.Fprintln(w, "# TYPE ndpeekr_mld_router_alert_violations_total counter")
+	reasons = reasons[:0]
+	for reason := range snap.RouterAlert {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "ndpeekr_mld_router_alert_violations_total{reason=\"%s\"} %d\n", promLabelEscape(reason), snap.RouterAlert[reason])
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_icmpv6_checksum_failures_total ICMPv6 messages dropped for a bad checksum (packet capture and pcap replay).")
+	fmt.Fprintln(w, "# TYPE ndpeekr_icmpv6_checksum_failures_total counter")
+	fmt.Fprintf(w, "ndpeekr_icmpv6_checksum_failures_total %d\n", snap.ChecksumFailures)
+
+	fmt.Fprintln(w, "# HELP ndpeekr_sampling_rate One in this many messages is fully processed; above 1 under overload.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_sampling_rate gauge")
+	fmt.Fprintf(w, "ndpeekr_sampling_rate %d\n", snap.Sampling.Rate)
+	fmt.Fprintln(w, "# HELP ndpeekr_messages_shed_total Messages only counted, not parsed, because of sampling.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_messages_shed_total counter")
+	fmt.Fprintf(w, "ndpeekr_messages_shed_total %d\n", snap.Sampling.Shed)
+	if snap.FastPath.Enabled {
+		fmt.Fprintln(w, "# HELP ndpeekr_fast_path_messages_total Messages handled on the profile-guided fast path.")
+		fmt.Fprintln(w, "# TYPE ndpeekr_fast_path_messages_total counter")
+		fmt.Fprintf(w, "ndpeekr_fast_path_messages_total %d\n", snap.FastPath.Fast)
+	}
+	if snap.RAGuard.Enabled {
+		fmt.Fprintln(w, "# HELP ndpeekr_ra_guard_leaks_total Router Advertisements that got past RA guard.")
+		fmt.Fprintln(w, "# TYPE ndpeekr_ra_guard_leaks_total counter")
+		fmt.Fprintf(w, "ndpeekr_ra_guard_leaks_total %d\n", snap.RAGuard.Leaks)
+	}
+	if snap.RogueRA.Enabled {
+		fmt.Fprintln(w, "# HELP ndpeekr_rogue_ras_total Router Advertisements from routers not listed as trusted.")
+		fmt.Fprintln(w, "# TYPE ndpeekr_rogue_ras_total counter")
+		fmt.Fprintf(w, "ndpeekr_rogue_ras_total %d\n", snap.RogueRA.RogueRAs)
+	}
+	if snap.MLDSnoop.Enabled {
+		fmt.Fprintln(w, "# HELP ndpeekr_mld_unanswered_queries MLD general queries in a row that no group member answered.")
+		fmt.Fprintln(w, "# TYPE ndpeekr_mld_unanswered_queries gauge")
+		fmt.Fprintf(w, "ndpeekr_mld_unanswered_queries %d\n", snap.MLDSnoop.Unanswered)
+	}
+	if snap.MTU.ICMPErrors {
+		fmt.Fprintln(w, "# HELP ndpeekr_packet_too_big_total ICMPv6 Packet Too Big messages observed.")
+		fmt.Fprintln(w, "# TYPE ndpeekr_packet_too_big_total counter")
+		fmt.Fprintf(w, "ndpeekr_packet_too_big_total %d\n", snap.MTU.PacketTooBig)
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_interface_messages_total NDP/MLD messages captured, by interface.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_interface_messages_total counter")
+	for _, d := range snap.Duplicates {
+		fmt.Fprintf(w, "ndpeekr_interface_messages_total{interface=\"%s\"} %d\n", promLabelEscape(d.Interface), d.Messages)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_interface_peers Peers within the window, by the interface they were last heard on.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_interface_peers gauge")
+	for _, s := range SummarizeInterfaces(snap.Peers, snap.Routers) {
+		fmt.Fprintf(w, "ndpeekr_interface_peers{interface=\"%s\"} %d\n", promLabelEscape(s.Interface), s.Peers)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_duplicate_messages_total NDP/MLD messages that repeated one seen milliseconds before, by interface.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_duplicate_messages_total counter")
+	for _, d := range snap.Duplicates {
+		fmt.Fprintf(w, "ndpeekr_duplicate_messages_total{interface=\"%s\"} %d\n", promLabelEscape(d.Interface), d.Duplicates)
+	}
+	if snap.Dedup.Enabled {
+		fmt.Fprintln(w, "# HELP ndpeekr_deduped_messages_total Duplicate NDP/MLD messages dropped before recording.")
+		fmt.Fprintln(w, "# TYPE ndpeekr_deduped_messages_total counter")
+		fmt.Fprintf(w, "ndpeekr_deduped_messages_total %d\n", snap.Dedup.Dropped)
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_mld_responses_total MLD reports matched to an observed query, by group.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_mld_responses_total counter")
+	for _, l := range snap.MLDLatency {
+		fmt.Fprintf(w, "ndpeekr_mld_responses_total{group=\"%s\"} %d\n", promLabelEscape(l.Group), l.Responses)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_mld_late_responses_total MLD query answers after the Maximum Response Delay, by group.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_mld_late_responses_total counter")
+	for _, l := range snap.MLDLatency {
+		fmt.Fprintf(w, "ndpeekr_mld_late_responses_total{group=\"%s\"} %d\n", promLabelEscape(l.Group), l.Late)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_mld_response_latency_seconds MLD query response latency, by group.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_mld_response_latency_seconds gauge")
+	for _, l := range snap.MLDLatency {
+		g := promLabelEscape(l.Group)
+		fmt.Fprintf(w, "ndpeekr_mld_response_latency_seconds{group=\"%s\",stat=\"mean\"} %g\n", g, l.Mean.Seconds())
+		fmt.Fprintf(w, "ndpeekr_mld_response_latency_seconds{group=\"%s\",stat=\"max\"} %g\n", g, l.Max.Seconds())
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_router_rs_responses_total Router Solicitations a router answered with an RA (--solicit).")
+	fmt.Fprintln(w, "# TYPE ndpeekr_router_rs_responses_total counter")
+	for _, l := range snap.RSLatency {
+		fmt.Fprintf(w, "ndpeekr_router_rs_responses_total{router=\"%s\"} %d\n", promLabelEscape(l.Router), l.Responses)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_router_rs_missed_total Router Solicitations a router did not answer (--solicit).")
+	fmt.Fprintln(w, "# TYPE ndpeekr_router_rs_missed_total counter")
+	for _, l := range snap.RSLatency {
+		fmt.Fprintf(w, "ndpeekr_router_rs_missed_total{router=\"%s\"} %d\n", promLabelEscape(l.Router), l.Missed)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_router_rs_latency_seconds Time from our Router Solicitation to the router's RA (--solicit).")
+	fmt.Fprintln(w, "# TYPE ndpeekr_router_rs_latency_seconds gauge")
+	for _, l := range snap.RSLatency {
+		r := promLabelEscape(l.Router)
+		fmt.Fprintf(w, "ndpeekr_router_rs_latency_seconds{router=\"%s\",stat=\"last\"} %g\n", r, l.Last.Seconds())
+		fmt.Fprintf(w, "ndpeekr_router_rs_latency_seconds{router=\"%s\",stat=\"mean\"} %g\n", r, l.Mean.Seconds())
+		fmt.Fprintf(w, "ndpeekr_router_rs_latency_seconds{router=\"%s\",stat=\"max\"} %g\n", r, l.Max.Seconds())
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_router_probes_total Unicast Neighbor Solicitations sent to a router (--probe-routers).")
+	fmt.Fprintln(w, "# TYPE ndpeekr_router_probes_total counter")
+	for _, r := range snap.Reachability {
+		fmt.Fprintf(w, "ndpeekr_router_probes_total{router=\"%s\"} %d\n", promLabelEscape(r.Router), r.Probes)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_router_probe_answers_total Probes a router answered with a Neighbor Advertisement (--probe-routers).")
+	fmt.Fprintln(w, "# TYPE ndpeekr_router_probe_answers_total counter")
+	for _, r := range snap.Reachability {
+		fmt.Fprintf(w, "ndpeekr_router_probe_answers_total{router=\"%s\"} %d\n", promLabelEscape(r.Router), r.Answers)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_router_unreachable Whether a router stopped answering neighbor resolution (--probe-routers).")
+	fmt.Fprintln(w, "# TYPE ndpeekr_router_unreachable gauge")
+	for _, r := range snap.Reachability {
+		v := 0
+		if r.Unreachable {
+			v = 1
+		}
+		fmt.Fprintf(w, "ndpeekr_router_unreachable{router=\"%s\"} %d\n", promLabelEscape(r.Router), v)
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_switch_neighbor_info The switch port an interface is plugged into, from LLDP or CDP (--lldp).")
+	fmt.Fprintln(w, "# TYPE ndpeekr_switch_neighbor_info gauge")
+	for _, n := range snap.SwitchNeighbors {
+		fmt.Fprintf(w, "ndpeekr_switch_neighbor_info{interface=\"%s\",protocol=\"%s\",system=\"%s\",port=\"%s\",vlan=\"%d\"} 1\n",
+			promLabelEscape(n.Interface), n.Protocol, promLabelEscape(n.System), promLabelEscape(n.Port), n.VLAN)
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_peer_info Enrichment for each peer with any, to join onto per-address series.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_peer_info gauge")
+	for _, p := range snap.Peers {
+		if p.Enrichment.IsZero() {
+			continue
+		}
+		fmt.Fprintf(w, "ndpeekr_peer_info{address=\"%s\",mac=\"%s\",hostname=\"%s\",vendor=\"%s\",name=\"%s\",device_type=\"%s\",trusted=\"%t\",tags=\"%s\"} 1\n",
+			p.Address, p.MAC, promLabelEscape(p.Hostname), promLabelEscape(p.Vendor), promLabelEscape(p.Name),
+			promLabelEscape(p.DeviceType), p.Trusted, promLabelEscape(strings.Join(p.Tags, ",")))
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_peer_reputation Reputation verdicts for each peer a reputation source knows.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_peer_reputation gauge")
+	for _, p := range snap.Peers {
+		for _, v := range p.Reputation {
+			fmt.Fprintf(w, "ndpeekr_peer_reputation{address=\"%s\",source=\"%s\",verdict=\"%s\"} 1\n",
+				p.Address, promLabelEscape(v.Source), promLabelEscape(v.Verdict))
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_alerts_suppressed_total Alerts suppressed by a maintenance window, by window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_alerts_suppressed_total counter")
+	for _, name := range slices.Sorted(maps.Keys(snap.MaintenanceSuppressed)) {
+		fmt.Fprintf(w, "ndpeekr_alerts_suppressed_total{window=\"%s\"} %d\n", promLabelEscape(name), snap.MaintenanceSuppressed[name])
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_ns_target_solicitations Neighbor Solicitations targeting each address in the window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_ns_target_solicitations gauge")
+	for _, t := range snap.SolicitedTargets {
+		fmt.Fprintf(w, "ndpeekr_ns_target_solicitations{target=\"%s\"} %d\n", t.Target, t.Solicitations)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_ns_target_solicitors Distinct peers soliciting each address in the window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_ns_target_solicitors gauge")
+	for _, t := range snap.SolicitedTargets {
+		fmt.Fprintf(w, "ndpeekr_ns_target_solicitors{target=\"%s\"} %d\n", t.Target, len(t.Solicitors))
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_ns_target_interval_seconds Time between Neighbor Solicitations targeting each address in the window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_ns_target_interval_seconds gauge")
+	for _, t := range snap.SolicitedTargets {
+		if t.Solicitations < 2 {
+			continue
+		}
+		fmt.Fprintf(w, "ndpeekr_ns_target_interval_seconds{target=\"%s\",quantile=\"0.5\"} %g\n", t.Target, t.IntervalP50.Seconds())
+		fmt.Fprintf(w, "ndpeekr_ns_target_interval_seconds{target=\"%s\",quantile=\"0.9\"} %g\n", t.Target, t.IntervalP90.Seconds())
+		fmt.Fprintf(w, "ndpeekr_ns_target_interval_seconds{target=\"%s\",quantile=\"0.99\"} %g\n", t.Target, t.IntervalP99.Seconds())
+	}
+
+	build := ReadBuildInfo()
+	fmt.Fprintln(w, "# HELP ndpeekr_build_info The running NDPeekr build.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_build_info gauge")
+	fmt.Fprintf(w, "ndpeekr_build_info{version=\"%s\",revision=\"%s\",go_version=\"%s\"} 1\n",
+		promLabelEscape(build.Version), build.Revision, build.GoVersion)
+
+	fmt.Fprintln(w, "# HELP ndpeekr_undefended_addresses Addresses with solicited-node members whose Neighbor Solicitations went unanswered in the window.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_undefended_addresses gauge")
+	fmt.Fprintf(w, "ndpeekr_undefended_addresses %d\n", len(snap.Undefended))
+
+	fmt.Fprintln(w, "# HELP ndpeekr_ipv6_health_score IPv6 health score per segment, 0-100.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_ipv6_health_score gauge")
+	for _, h := range snap.IPv6Health {
+		fmt.Fprintf(w, "ndpeekr_ipv6_health_score{interface=\"%s\"} %d\n", promLabelEscape(h.Interface), h.Score)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_ipv6_health_signal_points Points each signal contributes to the IPv6 health score.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_ipv6_health_signal_points gauge")
+	for _, h := range snap.IPv6Health {
+		for _, sig := range h.Signals {
+			fmt.Fprintf(w, "ndpeekr_ipv6_health_signal_points{interface=\"%s\",signal=\"%s\"} %d\n", promLabelEscape(h.Interface), sig.Name, sig.Points)
+		}
+	}
+
+	nudFlagged := map[string]int{NUDAggressive: 0, NUDBroken: 0}
+	for _, b := range snap.NUD {
+		if b.Verdict != "" {
+			nudFlagged[b.Verdict]++
+		}
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_nud_hosts Hosts whose NUD probing in the window looks aggressive or broken.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_nud_hosts gauge")
+	for _, verdict := range []string{NUDAggressive, NUDBroken} {
+		fmt.Fprintf(w, "ndpeekr_nud_hosts{verdict=\"%s\"} %d\n", verdict, nudFlagged[verdict])
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_option_usage_messages NDP messages of each type from each stack, since their senders were first seen.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_option_usage_messages gauge")
+	for _, u := range snap.OptionUsage {
+		fmt.Fprintf(w, "ndpeekr_option_usage_messages{stack=\"%s\",type=\"%s\"} %d\n", promLabelEscape(u.Stack), u.Kind, u.Messages)
+	}
+	fmt.Fprintln(w, "# HELP ndpeekr_option_usage Of those messages, the ones carrying each NDP option.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_option_usage gauge")
+	for _, u := range snap.OptionUsage {
+		for _, name := range slices.Sorted(maps.Keys(u.Options)) {
+			fmt.Fprintf(w, "ndpeekr_option_usage{stack=\"%s\",type=\"%s\",option=\"%s\"} %d\n", promLabelEscape(u.Stack), u.Kind, name, u.Options[name])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP ndpeekr_alerts_total Alerts raised since startup.")
+	fmt.Fprintln(w, "# TYPE ndpeekr_alerts_total counter")
+	for _, c := range alertCounts {
+		fmt.Fprintf(w, "ndpeekr_alerts_total{category=\"%s\",severity=\"%s\"} %d\n", promLabelEscape(c.Category), c.Severity, c.Count)
+	}
+}
+
+// promLabelEscape escapes a label value for the text exposition format.
+func promLabelEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
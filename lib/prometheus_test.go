@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_solicitation")
+	stats.RecordSize("fe80::1", "router_solicitation", 16)
+	stats.RecordRouter(RouterInfo{Address: "fe80::99", LastSeen: time.Now()})
+	stats.RecordAlert(Alert{Severity: SeverityCritical, Category: "fragmented_ra"})
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"ndpeekr_peers 1\n",
+		"ndpeekr_routers 1\n",
+		`ndpeekr_window_messages{type="router_solicitation"} 1`,
+		`ndpeekr_message_size_bytes_bucket{type="router_solicitation",le="63"} 1`,
+		`ndpeekr_message_size_bytes_bucket{type="router_solicitation",le="+Inf"} 1`,
+		`ndpeekr_message_size_bytes_sum{type="router_solicitation"} 16`,
+		`ndpeekr_alerts_total{category="fragmented_ra",severity="critical"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
+
+func TestMetricsHandler_Node(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.SetStatus(Status{Node: "worker-1"})
+	stats.RecordMessage("fe80::1", "router_solicitation")
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE ndpeekr_peers gauge\n",
+		`ndpeekr_peers{node="worker-1"} 1` + "\n",
+		`ndpeekr_window_messages{node="worker-1",type="router_solicitation"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
+
+func TestPromLabelEscape(t *testing.T) {
+	if got := promLabelEscape("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("promLabelEscape = %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Protocol Buffers wire format (https://protobuf.dev/programming-guides/encoding/)
+// for the handful of gNMI messages the gNMI target encodes by hand.
+
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+	pbFixed32 = 5
+)
+
+var errProtobuf = errors.New("malformed protobuf")
+
+// pbField is one decoded field: v holds varint and fixed values, data the
+// contents of length-delimited ones.
+type pbField struct {
+	num  int
+	wire int
+	v    uint64
+	data []byte
+}
+
+func pbAppendVarint(b []byte, v uint64) []byte {
+	return binary.AppendUvarint(b, v)
+}
+
+func pbAppendTag(b []byte, num, wire int) []byte {
+	return pbAppendVarint(b, uint64(num)<<3|uint64(wire))
+}
+
+// pbAppendUint appends a varint field, even when v is zero, as oneof
+// members must be.
+func pbAppendUint(b []byte, num int, v uint64) []byte {
+	return pbAppendVarint(pbAppendTag(b, num, pbVarint), v)
+}
+
+func pbAppendBool(b []byte, num int, v bool) []byte {
+	if v {
+		return pbAppendUint(b, num, 1)
+	}
+	return pbAppendUint(b, num, 0)
+}
+
+func pbAppendDouble(b []byte, num int, v float64) []byte {
+	return binary.LittleEndian.AppendUint64(pbAppendTag(b, num, pbFixed64), math.Float64bits(v))
+}
+
+func pbAppendBytes(b []byte, num int, data []byte) []byte {
+	b = pbAppendVarint(pbAppendTag(b, num, pbBytes), uint64(len(data)))
+	return append(b, data...)
+}
+
+func pbAppendString(b []byte, num int, s string) []byte {
+	b = pbAppendVarint(pbAppendTag(b, num, pbBytes), uint64(len(s)))
+	return append(b, s...)
+}
+
+// pbParse splits a message into its fields, in wire order.
+func pbParse(b []byte) ([]pbField, error) {
+	var fields []pbField
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 || tag>>3 == 0 || tag>>3 > math.MaxInt32 {
+			return nil, errProtobuf
+		}
+		b = b[n:]
+		f := pbField{num: int(tag >> 3), wire: int(tag & 7)}
+		switch f.wire {
+		case pbVarint:
+			if f.v, n = binary.Uvarint(b); n <= 0 {
+				return nil, errProtobuf
+			}
+			b = b[n:]
+		case pbFixed64:
+			if len(b) < 8 {
+				return nil, errProtobuf
+			}
+			f.v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case pbFixed32:
+			if len(b) < 4 {
+				return nil, errProtobuf
+			}
+			f.v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case pbBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return nil, errProtobuf
+			}
+			f.data, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return nil, errProtobuf
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
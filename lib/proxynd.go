@@ -0,0 +1,93 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// proxyMinTargets is how many distinct addresses other than its own a
+	// peer must advertise within the window before it can be an ND proxy.
+	proxyMinTargets = 8
+	// proxyPrefixShare is the fraction of those targets that must fall in
+	// one /64: proxies answer for a structured range (a delegated prefix, a
+	// container or VM subnet), spoofers for whatever they want to intercept.
+	proxyPrefixShare = 0.75
+)
+
+// proxyLocked reports whether the peer at addr behaves as an ND proxy: it
+// advertised many foreign targets within the window, mostly in one /64. It
+// returns that prefix and the number of foreign targets. Callers must hold s.mu.
+func (s *NDPStats) proxyLocked(addr string, peer *PeerStats, cutoff time.Time) (string, int) {
+	byPrefix := make(map[string]int)
+	foreign := 0
+	for target, last := range peer.Defended {
+		if target == addr || !last.After(cutoff) {
+			continue
+		}
+		ip := addrKey(target)
+		if !ip.Is6() {
+			continue
+		}
+		foreign++
+		prefix, _ := ip.WithZone("").Prefix(64)
+		byPrefix[prefix.String()]++
+	}
+	if foreign < proxyMinTargets {
+		return "", foreign
+	}
+	for prefix, n := range byPrefix {
+		if float64(n) >= proxyPrefixShare*float64(foreign) {
+			return prefix, foreign
+		}
+	}
+	return "", foreign
+}
+
+// IsNDProxy reports whether ip currently classifies as an ND proxy.
+func (s *NDPStats) IsNDProxy(ip string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	peer, ok := s.peers[ip]
+	if !ok {
+		return false
+	}
+	prefix, _ := s.proxyLocked(ip, peer, s.now().Add(-s.window))
+	return prefix != ""
+}
+
+// PeerMAC returns the link-layer address last seen for ip, or "".
+func (s *NDPStats) PeerMAC(ip string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if peer, ok := s.peers[canonicalAddr(ip)]; ok {
+		return peer.MAC
+	}
+	return ""
+}
+
+// checkNAOwnership warns when src advertises target with a link-layer address
+// other than the one target itself was seen with, which is what NA spoofing
+// looks like. ND proxies do this legitimately, so for peers classified as
+// proxies the mismatch is only logged.
+func (l *NDPListener) checkNAOwnership(src, target, mac string) {
+	if target == src || mac == "" {
+		return
+	}
+	owner := l.cfg.Stats.PeerMAC(target)
+	if owner == "" || owner == mac {
+		return
+	}
+	if l.cfg.Stats.IsNDProxy(src) {
+		l.cfg.Logger.Debug("nd proxy advertised a known address", "src", src, "target", target, "mac", mac, "owner_mac", owner)
+		return
+	}
+	l.raiseAlertOnce("na_spoof|"+src, Alert{
+		Severity: SeverityWarning,
+		Category: "na_spoof",
+		Source:   src,
+		Message:  fmt.Sprintf("%s advertises %s at %s, but %s was seen at %s", src, target, mac, target, owner),
+	})
+}
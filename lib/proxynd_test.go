@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestNDProxyClassification(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	now := time.Now()
+
+	// fe80::1 answers for a run of addresses in one /64.
+	for i := 1; i <= 10; i++ {
+		stats.RecordNATarget("fe80::1", fmt.Sprintf("2001:db8:1::%x", i), now)
+	}
+	// fe80::2 answers for its own global address and a few scattered ones.
+	stats.RecordNATarget("fe80::2", "fe80::2", now)
+	for i := 1; i <= 9; i++ {
+		stats.RecordNATarget("fe80::2", fmt.Sprintf("2001:db8:%x::1", i), now)
+	}
+
+	byAddr := make(map[string]PeerSummary)
+	for _, p := range stats.GetStats() {
+		byAddr[p.Address] = p
+	}
+	if p := byAddr["fe80::1"]; p.NDProxy != "2001:db8:1::/64" || p.ProxiedTargets != 10 {
+		t.Errorf("fe80::1 proxy = %q (%d targets), want 2001:db8:1::/64 (10)", p.NDProxy, p.ProxiedTargets)
+	}
+	if p := byAddr["fe80::2"]; p.NDProxy != "" || p.ProxiedTargets != 9 {
+		t.Errorf("fe80::2 proxy = %q (%d targets), want none (9)", p.NDProxy, p.ProxiedTargets)
+	}
+	if !stats.IsNDProxy("fe80::1") || stats.IsNDProxy("fe80::2") {
+		t.Error("IsNDProxy disagrees with the summaries")
+	}
+}
+
+func TestHandle_NASpoofAlert(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	owner := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x0a}
+	other := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x0b}
+	proxy := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x0c}
+
+	// 2001:db8:1::a announces itself.
+	l.handle(received{src: netip.MustParseAddr("2001:db8:1::a"), dst: netip.MustParseAddr("ff02::1"), payload: buildNA(net.ParseIP("2001:db8:1::a"), owner)})
+
+	// fe80::c proxies the whole /64, including 2001:db8:1::a.
+	for i := 1; i <= proxyMinTargets; i++ {
+		l.handle(received{src: netip.MustParseAddr("fe80::c"), dst: netip.MustParseAddr("ff02::1"), payload: buildNA(net.ParseIP(fmt.Sprintf("2001:db8:1::1:%x", i)), proxy)})
+	}
+	l.handle(received{src: netip.MustParseAddr("fe80::c"), dst: netip.MustParseAddr("ff02::1"), payload: buildNA(net.ParseIP("2001:db8:1::a"), proxy)})
+	if alerts := stats.GetAlerts(); len(alerts) != 0 {
+		t.Fatalf("proxy raised alerts: %+v", alerts)
+	}
+
+	// fe80::b claims 2001:db8:1::a out of the blue, which also flips its
+	// binding.
+	l.handle(received{src: netip.MustParseAddr("fe80::b"), dst: netip.MustParseAddr("ff02::1"), payload: buildNA(net.ParseIP("2001:db8:1::a"), other)})
+	alerts := stats.GetAlerts()
+	if len(alerts) != 2 || alerts[0].Category != "na_spoof" || alerts[0].Source != "fe80::b" || alerts[1].Category != BindingFlip {
+		t.Errorf("alerts = %+v, want a na_spoof from fe80::b and a binding flip", alerts)
+	}
+}
@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	// defaultPruneInterval is how often a Pruner prunes by default.
+	defaultPruneInterval = 2 * time.Second
+	// pruneDutyCycle bounds the share of wall time spent pruning: after a
+	// prune that took d, the next waits at least pruneDutyCycle*d.
+	pruneDutyCycle = 10
+	// slowPrune is how long a prune may take before it is logged.
+	slowPrune = 100 * time.Millisecond
+)
+
+// PrunerConfig configures a Pruner.
+type PrunerConfig struct {
+	Stats    *NDPStats     // required
+	Logger   *slog.Logger  // required
+	Interval time.Duration // between prunes (default 2s)
+}
+
+// Pruner ages peers, routers and the rest of NDPStats out of the window on
+// its own timer, away from the TUI's render tick, so a slow prune of a huge
+// peer set delays the next prune rather than the next frame. When a prune
+// takes long it backs off, spending at most a tenth of the time pruning.
+type Pruner struct {
+	cfg PrunerConfig
+}
+
+// NewPruner returns a pruner; call Run to start it.
+func NewPruner(cfg PrunerConfig) *Pruner {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultPruneInterval
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &Pruner{cfg: cfg}
+}
+
+// Run prunes until ctx is cancelled.
+func (p *Pruner) Run(ctx context.Context) error {
+	timer := time.NewTimer(p.cfg.Interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			timer.Reset(p.next(p.prune()))
+		}
+	}
+}
+
+// prune runs one prune and returns how long it took.
+func (p *Pruner) prune() time.Duration {
+	start := time.Now()
+	p.cfg.Stats.Prune()
+	took := time.Since(start)
+	p.cfg.Stats.recordPrune(start, took)
+	if took >= slowPrune {
+		p.cfg.Logger.Warn("slow prune", "took", took)
+	}
+	return took
+}
+
+// next returns the wait before the prune after one that took took.
+func (p *Pruner) next(took time.Duration) time.Duration {
+	return max(p.cfg.Interval, pruneDutyCycle*took)
+}
+
+// PruneStats describes the last prune.
+type PruneStats struct {
+	Last     time.Time     `json:"last"`
+	Duration time.Duration `json:"duration"`
+	Total    int           `json:"total"` // prunes since startup
+}
+
+// recordPrune notes a prune that started at start and took took.
+func (s *NDPStats) recordPrune(start time.Time, took time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prunes.Last = start
+	s.prunes.Duration = took
+	s.prunes.Total++
+}
+
+// PruneStats returns when the last prune ran and how long it took.
+func (s *NDPStats) PruneStats() PruneStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.prunes
+}
@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestPruner(t *testing.T) {
+	stats := NewNDPStats(50 * time.Millisecond)
+	stats.RecordMessage("fe80::1", "router_solicitation")
+	p := NewPruner(PrunerConfig{
+		Stats:    stats,
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Interval: 10 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(stats.GetStats()) > 0 || stats.PruneStats().Total == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("peer not pruned; prune stats %+v", stats.PruneStats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st := stats.Status(); st.Prune.Total == 0 || st.Prune.Last.IsZero() {
+		t.Errorf("status prune = %+v", st.Prune)
+	}
+}
+
+func TestPrunerBacksOff(t *testing.T) {
+	p := NewPruner(PrunerConfig{Stats: NewNDPStats(time.Minute)})
+	if got := p.next(time.Millisecond); got != defaultPruneInterval {
+		t.Errorf("next after a fast prune = %s, want %s", got, defaultPruneInterval)
+	}
+	if got := p.next(time.Second); got != 10*time.Second {
+		t.Errorf("next after a 1s prune = %s, want 10s", got)
+	}
+}
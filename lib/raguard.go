@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+// RAGuardConfig turns NDPeekr into an RA guard canary for a host behind a
+// port that RA guard protects: any Router Advertisement that reaches it is a
+// leak and raises a critical ra_guard_leak alert, unless it comes from a
+// router in Allow.
+type RAGuardConfig struct {
+	// Allow lists the routers RA guard lets through, by address or MAC.
+	Allow []string `yaml:"allow"`
+}
+
+// routerSet is a list of routers by address or MAC, as RAGuardConfig.Allow
+// and RogueRAConfig.Trusted give them.
+type routerSet struct {
+	addrs map[netip.Addr]bool
+	macs  map[string]bool
+}
+
+// newRouterSet compiles entries; field names the config key in errors.
+func newRouterSet(field string, entries []string) (*routerSet, error) {
+	g := &routerSet{addrs: make(map[netip.Addr]bool), macs: make(map[string]bool)}
+	for _, s := range entries {
+		if a, err := netip.ParseAddr(s); err == nil {
+			g.addrs[a.WithZone("")] = true
+		} else if mac, err := net.ParseMAC(s); err == nil {
+			g.macs[mac.String()] = true
+		} else {
+			return nil, fmt.Errorf("%s: %q is neither an IPv6 address nor a MAC", field, s)
+		}
+	}
+	return g, nil
+}
+
+func newRAGuard(cfg RAGuardConfig) (*routerSet, error) {
+	return newRouterSet("ra_guard.allow", cfg.Allow)
+}
+
+// allowed reports whether the router at src with link-layer address mac
+// (possibly "") is in the set.
+func (g *routerSet) allowed(src netip.Addr, mac string) bool {
+	return g.addrs[src.WithZone("")] || (mac != "" && g.macs[mac])
+}
+
+// RAGuardStats is the RA guard canary's state (see RAGuardConfig).
+type RAGuardStats struct {
+	Enabled bool `json:"enabled"`
+	// Leaks counts RAs from routers not allowed since startup.
+	Leaks int `json:"leaks"`
+	// LastLeak and LastSource describe the most recent leak.
+	LastLeak   time.Time `json:"last_leak,omitempty"`
+	LastSource string    `json:"last_source,omitempty"`
+}
+
+// checkRAGuard raises ra_guard_leak for an RA that is not allowed. It runs
+// before sampling so overload never hides a leak, and alerts once per
+// source within the window.
+func (l *NDPListener) checkRAGuard(r received) {
+	if l.raGuard == nil || len(r.payload) < 16 || r.payload[0] != byte(ipv6.ICMPTypeRouterAdvertisement) {
+		return
+	}
+	mac := parseLinkLayerAddr(r.payload, 1)
+	if l.raGuard.allowed(r.src, mac) {
+		return
+	}
+	ifName := l.ifName(r.ifIndex)
+	link := ifName
+	if link == "" {
+		link = r.src.Zone()
+	}
+	src := l.peerAddr(r.src, link).String()
+	now := l.now()
+	if l.cfg.Stats != nil {
+		l.cfg.Stats.recordRAGuardLeak(src, now)
+	}
+
+	from := src
+	if mac != "" {
+		from += " (" + mac + ")"
+	}
+	where := ""
+	if ifName != "" {
+		where = " on " + ifName
+	}
+	lifetime := time.Duration(r.payload[6])<<8 | time.Duration(r.payload[7])
+	l.raiseAlertOnce("ra_guard_leak|"+src, Alert{
+		Time:     now,
+		Severity: SeverityCritical,
+		Category: "ra_guard_leak",
+		Source:   src,
+		Message: fmt.Sprintf("RA from %s got past RA guard%s (router lifetime %s, hop limit %d)",
+			from, where, formatDuration(lifetime*time.Second), r.hopLimit),
+		Port: r.port,
+	})
+}
+
+// enableRAGuard marks the canary as armed.
+func (s *NDPStats) enableRAGuard() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.raGuard.Enabled = true
+}
+
+// recordRAGuardLeak counts an RA that got past RA guard.
+func (s *NDPStats) recordRAGuardLeak(src string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.raGuard.Leaks++
+	s.raGuard.LastLeak, s.raGuard.LastSource = at, src
+}
+
+// RAGuard returns the RA guard canary's state.
+func (s *NDPStats) RAGuard() RAGuardStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.raGuard
+}
@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRAGuard(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:       stats,
+		MaxSampling: 64,
+		RAGuard:     &RAGuardConfig{Allow: []string{"fe80::1", "02:00:00:00:00:fe"}},
+	})
+	if !stats.RAGuard().Enabled {
+		t.Fatal("canary not armed")
+	}
+
+	allowedMAC := net.HardwareAddr{0x02, 0, 0, 0, 0, 0xfe}
+	rogueMAC := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x66}
+	l.handle(received{src: netip.MustParseAddr("fe80::1"), hopLimit: 255, payload: buildRAFull(64, false, false, 1800, nil)})
+	l.handle(received{src: netip.MustParseAddr("fe80::2"), hopLimit: 255, payload: buildRAFull(64, false, false, 1800, allowedMAC)})
+	if st := stats.RAGuard(); st.Leaks != 0 {
+		t.Fatalf("allowed routers leaked: %+v", st)
+	}
+
+	for i := 0; i < 3; i++ {
+		l.handle(received{src: netip.MustParseAddr("fe80::66"), hopLimit: 255, payload: buildRAFull(64, false, false, 1800, rogueMAC)})
+	}
+	st := stats.RAGuard()
+	if st.Leaks != 3 || st.LastSource != "fe80::66" {
+		t.Errorf("stats = %+v, want 3 leaks from fe80::66", st)
+	}
+	var leaks []Alert
+	for _, a := range stats.GetAlerts() {
+		if a.Category == "ra_guard_leak" {
+			leaks = append(leaks, a)
+		}
+	}
+	if len(leaks) != 1 || leaks[0].Severity != SeverityCritical || !strings.Contains(leaks[0].Message, "02:00:00:00:00:66") {
+		t.Errorf("alerts = %+v, want one critical leak naming the MAC", leaks)
+	}
+
+	if _, err := newRAGuard(RAGuardConfig{Allow: []string{"router1"}}); err == nil {
+		t.Error("invalid allow entry accepted")
+	}
+}
@@ -0,0 +1,294 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// defaultReputationTimeout bounds a single exec reputation lookup.
+	defaultReputationTimeout = 5 * time.Second
+	// maxReputationLookupsPerCycle caps lookups per enrichment cycle, per
+	// source; the rest wait for the next cycle.
+	maxReputationLookupsPerCycle = 32
+)
+
+// Reputation verdicts, from best to worst.
+const (
+	ReputationClean      = "clean"
+	ReputationSuspicious = "suspicious"
+	ReputationMalicious  = "malicious"
+)
+
+// reputationRank orders verdicts; unknown verdicts rank with clean.
+var reputationRank = map[string]int{ReputationSuspicious: 1, ReputationMalicious: 2}
+
+// ReputationVerdict is what one reputation source says about a peer.
+type ReputationVerdict struct {
+	Source  string `json:"source"`
+	Verdict string `json:"verdict"` // clean, suspicious or malicious
+	Reason  string `json:"reason,omitempty"`
+}
+
+// ReputationSource looks a peer up against an external source: an internal
+// blocklist, a threat intel service and so on. Lookup returns nil if the
+// source knows nothing about the peer. The Enricher calls Lookup from a
+// single goroutine and caches answers for the enrichment TTL.
+//
+// Blocklist files and exec commands are built in (see ReputationConfig);
+// programs embedding the lib package can pass their own sources in
+// EnricherConfig.Reputation.
+type ReputationSource interface {
+	Name() string
+	Lookup(ctx context.Context, address, mac string) (*ReputationVerdict, error)
+}
+
+// ReputationConfig configures one built-in reputation source: either a
+// blocklist file or a command.
+type ReputationConfig struct {
+	Name string `yaml:"name"`
+	// Blocklist is a file with one address, prefix or MAC per line,
+	// optionally followed by a verdict and a reason:
+	//   2001:db8:bad::/48 malicious known C2 range
+	Blocklist string `yaml:"blocklist"`
+	// Command is run per peer with templated Args ({{.Address}}, {{.MAC}})
+	// and prints a JSON verdict, {"verdict": "...", "reason": "..."}, or
+	// nothing if it knows nothing about the peer.
+	Command string        `yaml:"command"`
+	Args    []string      `yaml:"args"`
+	Timeout time.Duration `yaml:"timeout"` // per lookup (default 5s)
+}
+
+// NewReputationSource builds the source cfg describes.
+func NewReputationSource(cfg ReputationConfig) (ReputationSource, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	switch {
+	case cfg.Blocklist != "" && cfg.Command != "":
+		return nil, fmt.Errorf("%s: blocklist and command are mutually exclusive", cfg.Name)
+	case cfg.Blocklist != "":
+		return loadBlocklist(cfg.Name, cfg.Blocklist)
+	case cfg.Command != "":
+		args, err := parseExecArgs(cfg.Args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: args: %w", cfg.Name, err)
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = defaultReputationTimeout
+		}
+		return &execReputation{cfg: cfg, args: args}, nil
+	}
+	return nil, fmt.Errorf("%s: blocklist or command is required", cfg.Name)
+}
+
+// blocklistEntry is a blocklist line's verdict and reason.
+type blocklistEntry struct {
+	verdict, reason string
+}
+
+// blocklist is a ReputationSource backed by a file loaded at startup.
+type blocklist struct {
+	name     string
+	addrs    map[netip.Addr]blocklistEntry
+	prefixes map[netip.Prefix]blocklistEntry
+	macs     map[string]blocklistEntry // lower-case, colon separated
+}
+
+// loadBlocklist reads a blocklist file. Blank lines and lines starting
+// with '#' are skipped; entries without a verdict are malicious.
+func loadBlocklist(name, path string) (*blocklist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open blocklist: %w", err)
+	}
+	defer f.Close()
+
+	bl := &blocklist{
+		name:     name,
+		addrs:    make(map[netip.Addr]blocklistEntry),
+		prefixes: make(map[netip.Prefix]blocklistEntry),
+		macs:     make(map[string]blocklistEntry),
+	}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		e := blocklistEntry{verdict: ReputationMalicious}
+		if len(fields) > 1 {
+			e.verdict = fields[1]
+			if _, ok := reputationRank[e.verdict]; !ok && e.verdict != ReputationClean {
+				return nil, fmt.Errorf("%s:%d: unknown verdict %q", path, n, e.verdict)
+			}
+			e.reason = strings.Join(fields[2:], " ")
+		}
+		if p, err := netip.ParsePrefix(fields[0]); err == nil {
+			bl.prefixes[p.Masked()] = e
+		} else if a, err := netip.ParseAddr(fields[0]); err == nil {
+			bl.addrs[a.WithZone("")] = e
+		} else if hw, err := net.ParseMAC(fields[0]); err == nil {
+			bl.macs[hw.String()] = e
+		} else {
+			return nil, fmt.Errorf("%s:%d: %q is not an address, prefix or MAC", path, n, fields[0])
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read blocklist: %w", err)
+	}
+	return bl, nil
+}
+
+func (b *blocklist) Name() string { return b.name }
+
+// Lookup matches the address exactly, then the longest prefix containing
+// it, then the MAC.
+func (b *blocklist) Lookup(_ context.Context, address, mac string) (*ReputationVerdict, error) {
+	verdict := func(e blocklistEntry) *ReputationVerdict {
+		return &ReputationVerdict{Source: b.name, Verdict: e.verdict, Reason: e.reason}
+	}
+	if a, err := netip.ParseAddr(address); err == nil {
+		a = a.WithZone("")
+		if e, ok := b.addrs[a]; ok {
+			return verdict(e), nil
+		}
+		for bits := a.BitLen(); bits >= 0; bits-- {
+			p, _ := a.Prefix(bits)
+			if e, ok := b.prefixes[p]; ok {
+				return verdict(e), nil
+			}
+		}
+	}
+	if hw, err := net.ParseMAC(mac); err == nil {
+		if e, ok := b.macs[hw.String()]; ok {
+			return verdict(e), nil
+		}
+	}
+	return nil, nil
+}
+
+// execReputation is a ReputationSource that runs a command per lookup.
+type execReputation struct {
+	cfg  ReputationConfig
+	args []*template.Template
+}
+
+func (x *execReputation) Name() string { return x.cfg.Name }
+
+func (x *execReputation) Lookup(ctx context.Context, address, mac string) (*ReputationVerdict, error) {
+	data := struct{ Address, MAC string }{address, mac}
+	args := make([]string, len(x.args))
+	for i, t := range x.args {
+		var b bytes.Buffer
+		if err := t.Execute(&b, data); err != nil {
+			return nil, err
+		}
+		args[i] = b.String()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, x.cfg.Timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, x.cfg.Command, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", x.cfg.Command, err)
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, nil
+	}
+	var v ReputationVerdict
+	if err := json.Unmarshal(out, &v); err != nil {
+		return nil, fmt.Errorf("%s: parse verdict: %w", x.cfg.Command, err)
+	}
+	if v.Verdict == "" {
+		return nil, nil
+	}
+	v.Source = x.cfg.Name
+	return &v, nil
+}
+
+// WorstReputation returns the worst verdict in vs, or "" if vs is empty.
+func WorstReputation(vs []ReputationVerdict) string {
+	worst := ""
+	for _, v := range vs {
+		if worst == "" || reputationRank[v.Verdict] > reputationRank[worst] {
+			worst = v.Verdict
+		}
+	}
+	return worst
+}
+
+// reputationCacheEntry is a cached answer from one source about one peer.
+type reputationCacheEntry struct {
+	verdict *ReputationVerdict // nil for "knows nothing" or a failed lookup
+	fetched time.Time
+}
+
+// reputation looks p up in every source, from the cache where the answer is
+// younger than the TTL. lookups counts lookups per source this cycle.
+func (e *Enricher) reputation(ctx context.Context, p PeerSummary, now time.Time, lookups map[string]int) []ReputationVerdict {
+	var out []ReputationVerdict
+	for _, src := range e.reputationSources {
+		key := src.Name() + "|" + p.Address
+		entry, ok := e.repCache[key]
+		if (!ok || now.Sub(entry.fetched) >= e.ttl) && lookups[src.Name()] < maxReputationLookupsPerCycle && ctx.Err() == nil {
+			lookups[src.Name()]++
+			v, err := src.Lookup(ctx, unzoned(p.Address), p.MAC)
+			if err != nil {
+				e.logger.Debug("reputation lookup failed", "source", src.Name(), "peer", p.Address, "err", err)
+			}
+			entry = reputationCacheEntry{verdict: v, fetched: now}
+			e.repCache[key] = entry
+		}
+		if entry.verdict != nil {
+			out = append(out, *entry.verdict)
+		}
+	}
+	return out
+}
+
+// alertReputation raises a warning for a suspicious peer and a critical
+// alert for a malicious one, once per window.
+func (e *Enricher) alertReputation(p PeerSummary, vs []ReputationVerdict) {
+	worst := WorstReputation(vs)
+	sev := SeverityWarning
+	switch worst {
+	case ReputationMalicious:
+		sev = SeverityCritical
+	case ReputationSuspicious:
+	default:
+		return
+	}
+	var why []string
+	for _, v := range vs {
+		if v.Verdict != worst {
+			continue
+		}
+		if v.Reason != "" {
+			why = append(why, v.Source+": "+v.Reason)
+		} else {
+			why = append(why, v.Source)
+		}
+	}
+	category := "reputation_" + worst
+	if !e.stats.alertDue(category+"|"+p.Address, time.Now()) {
+		return
+	}
+	emitAlert(e.stats, e.logger, e.sinks, Alert{
+		Severity: sev,
+		Category: category,
+		Source:   p.Address,
+		Message:  fmt.Sprintf("%s is listed as %s (%s)", p.Address, worst, strings.Join(why, "; ")),
+		Port:     p.Port,
+	})
+}
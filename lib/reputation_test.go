@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	body := "# site blocklist\n" +
+		"2001:db8:bad::/48 malicious known C2 range\n" +
+		"2001:db8:bad:1::/64 suspicious\n" +
+		"fe80::dead%eth0 suspicious lab box\n" +
+		"02:00:5E:10:00:01\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := NewReputationSource(ReputationConfig{Name: "corp", Blocklist: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr, mac string
+		verdict   string
+		reason    string
+	}{
+		{"2001:db8:bad::7", "", ReputationMalicious, "known C2 range"},
+		{"2001:db8:bad:1::7", "", ReputationSuspicious, ""}, // longest prefix wins
+		{"fe80::dead", "", ReputationSuspicious, "lab box"},
+		{"fe80::1", "02:00:5e:10:00:01", ReputationMalicious, ""},
+		{"2001:db8::1", "02:00:5e:10:00:02", "", ""},
+	}
+	for _, tt := range tests {
+		v, err := src.Lookup(context.Background(), tt.addr, tt.mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.verdict == "" {
+			if v != nil {
+				t.Errorf("%s: got %+v, want nothing", tt.addr, v)
+			}
+			continue
+		}
+		if v == nil || v.Verdict != tt.verdict || v.Reason != tt.reason || v.Source != "corp" {
+			t.Errorf("%s: got %+v, want %s %q", tt.addr, v, tt.verdict, tt.reason)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("2001:db8::1 evil\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReputationSource(ReputationConfig{Name: "corp", Blocklist: path}); err == nil {
+		t.Error("unknown verdict: want an error")
+	}
+	for name, cfg := range map[string]ReputationConfig{
+		"no name":   {Command: "/bin/true"},
+		"no source": {Name: "x"},
+		"both":      {Name: "x", Command: "/bin/true", Blocklist: path},
+	} {
+		if _, err := NewReputationSource(cfg); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+func TestExecReputation(t *testing.T) {
+	src, err := NewReputationSource(ReputationConfig{
+		Name:    "intel",
+		Command: "/bin/sh",
+		Args:    []string{"-c", `[ "$0" = 2001:db8::bad ] && echo '{"verdict": "malicious", "reason": "seen in campaign X"}'; true`, "{{.Address}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := src.Lookup(context.Background(), "2001:db8::bad", "")
+	if err != nil || v == nil || v.Verdict != ReputationMalicious || v.Reason != "seen in campaign X" || v.Source != "intel" {
+		t.Errorf("got %+v, %v; want malicious from intel", v, err)
+	}
+	if v, err := src.Lookup(context.Background(), "2001:db8::1", ""); v != nil || err != nil {
+		t.Errorf("got %+v, %v; want nothing", v, err)
+	}
+}
+
+// staticReputation is a ReputationSource for tests.
+type staticReputation map[string]string
+
+func (s staticReputation) Name() string { return "static" }
+
+func (s staticReputation) Lookup(_ context.Context, address, _ string) (*ReputationVerdict, error) {
+	if v, ok := s[address]; ok {
+		return &ReputationVerdict{Source: "static", Verdict: v}, nil
+	}
+	return nil, nil
+}
+
+func TestEnricher_Reputation(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_advertisement")
+	stats.RecordMessage("fe80::2", "neighbor_solicitation")
+	stats.RecordMessage("fe80::3", "neighbor_solicitation")
+
+	e, err := NewEnricher(EnricherConfig{
+		Stats:      stats,
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Reputation: []ReputationSource{staticReputation{"fe80::1": ReputationMalicious, "fe80::2": ReputationClean}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	e.refresh(context.Background(), now)
+	e.refresh(context.Background(), now.Add(time.Second)) // no repeat alert
+
+	f, err := ParseFilter(`reputation == "malicious"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peers := FilterPeers(stats.GetStats(), f)
+	if len(peers) != 1 || peers[0].Address != "fe80::1" || len(peers[0].Reputation) != 1 {
+		t.Errorf("malicious peers = %+v, want fe80::1", peers)
+	}
+	alerts := stats.GetAlerts()
+	if len(alerts) != 1 || alerts[0].Category != "reputation_malicious" || alerts[0].Severity != SeverityCritical ||
+		alerts[0].Peer == nil || WorstReputation(alerts[0].Peer.Reputation) != ReputationMalicious {
+		t.Errorf("alerts = %+v, want one critical reputation_malicious with the verdict attached", alerts)
+	}
+}
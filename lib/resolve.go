@@ -0,0 +1,358 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	defaultResolveInterval = 5 * time.Second
+	defaultResolveTTL      = 30 * time.Minute
+	defaultResolveWorkers  = 4
+
+	// resolveNegativeTTL is how long a peer without a name waits before
+	// it is asked about again.
+	resolveNegativeTTL = 5 * time.Minute
+	// mdnsTimeout bounds one mDNS query; responders answer within a second.
+	mdnsTimeout = time.Second
+	// resolveQueueSize bounds the peers waiting for a worker; the rest wait
+	// for the next scan.
+	resolveQueueSize = 256
+)
+
+var mdnsGroup = netip.MustParseAddr("ff02::fb")
+
+// ParseResolve parses --resolve: a comma-separated list of "ptr" (reverse
+// DNS through the system resolver) and "mdns" (multicast DNS on the peer's
+// link).
+func ParseResolve(s string) (ptr, mdns bool, err error) {
+	for _, mode := range strings.Split(s, ",") {
+		switch strings.TrimSpace(mode) {
+		case "ptr":
+			ptr = true
+		case "mdns":
+			mdns = true
+		case "":
+		default:
+			return false, false, fmt.Errorf("--resolve: unknown mode %q (want ptr, mdns or ptr,mdns)", mode)
+		}
+	}
+	return ptr, mdns, nil
+}
+
+// ResolverConfig configures a Resolver.
+type ResolverConfig struct {
+	Stats    *NDPStats
+	Logger   *slog.Logger
+	PTR      bool          // reverse DNS (PTR) lookups through the system resolver
+	MDNS     bool          // reverse lookups by one-shot mDNS query on the peer's link
+	Interval time.Duration // how often peers are checked for names to look up (default 5s)
+	TTL      time.Duration // how long names are cached (default 30m)
+	Workers  int           // concurrent lookups (default 4)
+}
+
+// Resolver looks up hostnames for the peers in the background and stores
+// them in stats (see NDPStats.SetHostname), where they fill in for
+// enrichment's reverse DNS. Lookups run on a few workers, never on the
+// capture or render path, and answers are cached so each address is only
+// asked about again once its entry expires.
+type Resolver struct {
+	cfg        ResolverConfig
+	lookupPTR  func(ctx context.Context, addr string) ([]string, error)
+	lookupMDNS func(ctx context.Context, addr netip.Addr, iface string) (string, error)
+
+	mu      sync.Mutex
+	cache   map[string]resolveEntry // key: peer address
+	pending map[string]bool         // queued or being looked up
+	queue   chan PeerSummary
+}
+
+type resolveEntry struct {
+	hostname string // "" when nothing answered
+	fetched  time.Time
+}
+
+// NewResolver returns a Resolver; Run starts it.
+func NewResolver(cfg ResolverConfig) *Resolver {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultResolveInterval
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultResolveTTL
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultResolveWorkers
+	}
+	return &Resolver{
+		cfg:        cfg,
+		lookupPTR:  net.DefaultResolver.LookupAddr,
+		lookupMDNS: mdnsLookupAddr,
+		cache:      make(map[string]resolveEntry),
+		pending:    make(map[string]bool),
+		queue:      make(chan PeerSummary, resolveQueueSize),
+	}
+}
+
+// Run scans the peers every interval until ctx is cancelled.
+func (r *Resolver) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range r.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case p := <-r.queue:
+					r.resolve(ctx, p)
+				}
+			}
+		}()
+	}
+	defer wg.Wait()
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		r.scan(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan hands every peer without a fresh cache entry to the workers and
+// stores the cached names of the rest, for peers that came back after
+// being pruned.
+func (r *Resolver) scan(now time.Time) {
+	peers := r.cfg.Stats.GetStats()
+	seen := make(map[string]bool, len(peers))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range peers {
+		seen[p.Address] = true
+		if entry, ok := r.cache[p.Address]; ok && now.Sub(entry.fetched) < r.ttl(entry) {
+			if entry.hostname != "" && p.Hostname == "" {
+				r.cfg.Stats.SetHostname(p.Address, entry.hostname)
+			}
+			continue
+		}
+		if r.pending[p.Address] {
+			continue
+		}
+		select {
+		case r.queue <- p:
+			r.pending[p.Address] = true
+		default:
+			return
+		}
+	}
+
+	// Forget expired answers for peers that are gone.
+	for addr, entry := range r.cache {
+		if !seen[addr] && now.Sub(entry.fetched) >= r.ttl(entry) {
+			delete(r.cache, addr)
+		}
+	}
+}
+
+func (r *Resolver) ttl(entry resolveEntry) time.Duration {
+	if entry.hostname == "" {
+		return min(r.cfg.TTL, resolveNegativeTTL)
+	}
+	return r.cfg.TTL
+}
+
+// resolve looks p up by PTR, then by mDNS if that found nothing, and
+// records the answer.
+func (r *Resolver) resolve(ctx context.Context, p PeerSummary) {
+	addr, err := netip.ParseAddr(p.Address)
+	if err != nil {
+		r.done(p.Address, "")
+		return
+	}
+	var hostname, source string
+	if r.cfg.PTR {
+		lctx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+		if names, err := r.lookupPTR(lctx, addr.WithZone("").String()); err == nil && len(names) > 0 {
+			hostname, source = strings.TrimSuffix(names[0], "."), "ptr"
+		}
+		cancel()
+	}
+	if hostname == "" && r.cfg.MDNS {
+		iface := addr.Zone()
+		if iface == "" {
+			iface = p.Interface
+		}
+		if iface != "" {
+			lctx, cancel := context.WithTimeout(ctx, mdnsTimeout)
+			if name, err := r.lookupMDNS(lctx, addr.WithZone(""), iface); err == nil && name != "" {
+				hostname, source = name, "mdns"
+			}
+			cancel()
+		}
+	}
+	if ctx.Err() != nil {
+		// Cancelled mid-lookup: leave it uncached.
+		r.mu.Lock()
+		delete(r.pending, p.Address)
+		r.mu.Unlock()
+		return
+	}
+	if hostname != "" {
+		r.cfg.Stats.SetHostname(p.Address, hostname)
+		r.cfg.Logger.Debug("hostname resolved", "addr", p.Address, "hostname", hostname, "source", source)
+	}
+	r.done(p.Address, hostname)
+}
+
+func (r *Resolver) done(addr, hostname string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, addr)
+	r.cache[addr] = resolveEntry{hostname: hostname, fetched: time.Now()}
+}
+
+// reverseName returns the ip6.arpa (or in-addr.arpa) name of addr.
+func reverseName(addr netip.Addr) string {
+	var b strings.Builder
+	if addr.Is4() {
+		a := addr.As4()
+		fmt.Fprintf(&b, "%d.%d.%d.%d.in-addr.arpa.", a[3], a[2], a[1], a[0])
+		return b.String()
+	}
+	a := addr.As16()
+	for i := len(a) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%x.%x.", a[i]&0x0f, a[i]>>4)
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
+
+// mdnsQuery builds a one-shot mDNS query (RFC 6762 section 5.1) for the
+// PTR record of addr, asking for a unicast response.
+func mdnsQuery(addr netip.Addr) ([]byte, error) {
+	name, err := dnsmessage.NewName(reverseName(addr))
+	if err != nil {
+		return nil, err
+	}
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET | 1<<15, // QU: unicast response requested
+		}},
+	}
+	return msg.Pack()
+}
+
+// parseMDNSReply returns the target of the first PTR answer for addr's
+// reverse name in an mDNS response, without the trailing dot, or "".
+// Targets that aren't valid hostnames are ignored: dnsmessage doesn't check
+// label bytes, and any responder could put terminal escapes in the table.
+func parseMDNSReply(b []byte, addr netip.Addr) string {
+	var p dnsmessage.Parser
+	h, err := p.Start(b)
+	if err != nil || !h.Response {
+		return ""
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return ""
+	}
+	want := reverseName(addr)
+	for {
+		ah, err := p.AnswerHeader()
+		if err != nil {
+			return ""
+		}
+		if ah.Type != dnsmessage.TypePTR || !strings.EqualFold(ah.Name.String(), want) {
+			if err := p.SkipAnswer(); err != nil {
+				return ""
+			}
+			continue
+		}
+		ptr, err := p.PTRResource()
+		if err != nil {
+			return ""
+		}
+		name := strings.TrimSuffix(ptr.PTR.String(), ".")
+		if !validHostname(name) {
+			return ""
+		}
+		return name
+	}
+}
+
+// validHostname reports whether name is a dotted hostname of at most 253
+// characters made of hostname labels.
+func validHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, l := range strings.Split(name, ".") {
+		if !hostnameLabel([]byte(l)) {
+			return false
+		}
+	}
+	return true
+}
+
+// mdnsLookupAddr asks the mDNS responders on iface for addr's name and
+// returns the first answer, or "" if none came before ctx's deadline.
+func mdnsLookupAddr(ctx context.Context, addr netip.Addr, iface string) (string, error) {
+	query, err := mdnsQuery(addr)
+	if err != nil {
+		return "", err
+	}
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6unspecified})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(mdnsTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return "", err
+	}
+	dst := net.UDPAddrFromAddrPort(netip.AddrPortFrom(mdnsGroup.WithZone(iface), 5353))
+	if _, err := conn.WriteToUDP(query, dst); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// The deadline passed without an answer.
+			return "", nil
+		}
+		if name := parseMDNSReply(buf[:n], addr); name != "" {
+			return name, nil
+		}
+	}
+}
+
+// SetHostname records a resolved hostname for ip (see Resolver). It fills
+// in Hostname wherever enrichment has none.
+func (s *NDPStats) SetHostname(ip, hostname string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ip = canonicalAddr(ip)
+	if _, ok := s.peers[ip]; ok {
+		s.hostnames[ip] = hostname
+	}
+}
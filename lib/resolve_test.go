@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/netip"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestParseResolve(t *testing.T) {
+	if ptr, mdns, err := ParseResolve("ptr, mdns"); err != nil || !ptr || !mdns {
+		t.Errorf("ptr, mdns = %v %v %v", ptr, mdns, err)
+	}
+	if ptr, mdns, err := ParseResolve(""); err != nil || ptr || mdns {
+		t.Errorf("empty = %v %v %v", ptr, mdns, err)
+	}
+	if _, _, err := ParseResolve("llmnr"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	got := reverseName(netip.MustParseAddr("2001:db8::567:89ab"))
+	want := "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."
+	if got != want {
+		t.Errorf("reverseName = %s, want %s", got, want)
+	}
+}
+
+func TestMDNSReply(t *testing.T) {
+	addr := netip.MustParseAddr("fe80::1")
+	if _, err := mdnsQuery(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, Authoritative: true})
+	b.StartAnswers()
+	other := dnsmessage.MustNewName(reverseName(netip.MustParseAddr("fe80::2")))
+	_ = b.PTRResource(dnsmessage.ResourceHeader{Name: other, Class: dnsmessage.ClassINET}, dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("other.local.")})
+	name := dnsmessage.MustNewName(reverseName(addr))
+	_ = b.PTRResource(dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET | 1<<15}, dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("printer.local.")})
+	msg, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parseMDNSReply(msg, addr); got != "printer.local" {
+		t.Errorf("parseMDNSReply = %q, want printer.local", got)
+	}
+	if got := parseMDNSReply(msg, netip.MustParseAddr("fe80::3")); got != "" {
+		t.Errorf("answer for another address = %q", got)
+	}
+
+	b = dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true})
+	b.StartAnswers()
+	_ = b.PTRResource(dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET}, dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("\x1b[2Jevil.local.")})
+	if msg, err = b.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	if got := parseMDNSReply(msg, addr); got != "" {
+		t.Errorf("answer with escapes = %q, want it ignored", got)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("2001:db8::1", "neighbor_solicitation")
+	stats.RecordMessage("fe80::2%eth0", "neighbor_solicitation")
+
+	r := NewResolver(ResolverConfig{Stats: stats, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), PTR: true, MDNS: true})
+	r.lookupPTR = func(ctx context.Context, addr string) ([]string, error) {
+		if addr == "2001:db8::1" {
+			return []string{"host1.example.net."}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	var mdnsIface string
+	r.lookupMDNS = func(ctx context.Context, addr netip.Addr, iface string) (string, error) {
+		mdnsIface = iface
+		return "laptop.local", nil
+	}
+
+	r.scan(time.Now())
+	for len(r.queue) > 0 {
+		r.resolve(context.Background(), <-r.queue)
+	}
+	names := make(map[string]string)
+	for _, p := range stats.GetStats() {
+		names[p.Address] = p.Hostname
+	}
+	if names["2001:db8::1"] != "host1.example.net" || names["fe80::2%eth0"] != "laptop.local" || mdnsIface != "eth0" {
+		t.Errorf("hostnames = %v, mDNS asked on %q", names, mdnsIface)
+	}
+
+	// Cached answers aren't looked up again, and come back after a prune.
+	stats.Clear()
+	stats.RecordMessage("2001:db8::1", "neighbor_solicitation")
+	r.scan(time.Now())
+	if len(r.queue) != 0 {
+		t.Errorf("%d cached peers queued again", len(r.queue))
+	}
+	p := stats.GetStats()
+	if len(p) != 1 || p[0].Hostname != "host1.example.net" {
+		t.Fatalf("peers after clear = %+v", p)
+	}
+	if row := peerRows(p, time.Minute, true)[0]; len(row) != len(newPeerTable(true).Columns()) || row[len(row)-1] != "host1.example.net" {
+		t.Errorf("row = %q, want the hostname last", row)
+	}
+}
@@ -0,0 +1,334 @@
+package lib
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// yangModule is the YANG schema the RESTCONF data tree follows.
+//
+//go:embed ndpeekr.yang
+var yangModule string
+
+const (
+	yangModuleName     = "ndpeekr"
+	yangModuleRevision = "2026-10-15"
+	yangNamespace      = "urn:ndpeekr:params:xml:ns:yang:ndpeekr"
+	restconfMediaType  = "application/yang-data+json"
+	restconfDataPrefix = "/restconf/data/"
+)
+
+// yangNeighbor is a neighbor list entry in RFC 7951 JSON encoding.
+type yangNeighbor struct {
+	Address       string             `json:"address"`
+	MAC           string             `json:"mac-address,omitempty"`
+	Interface     string             `json:"interface,omitempty"`
+	HopLimit      int                `json:"hop-limit,omitempty"`
+	FirstSeen     string             `json:"first-seen"`
+	LastSeen      string             `json:"last-seen"`
+	TotalMessages int                `json:"total-messages"`
+	TotalBytes    int                `json:"total-bytes"`
+	MessageCounts []yangMessageCount `json:"message-count,omitempty"`
+	Groups        []string           `json:"multicast-group,omitempty"`
+	GuessedOS     string             `json:"guessed-os,omitempty"`
+	Hostname      string             `json:"hostname,omitempty"`
+	Vendor        string             `json:"vendor,omitempty"`
+	Name          string             `json:"name,omitempty"`
+	DeviceType    string             `json:"device-type,omitempty"`
+	Trusted       bool               `json:"trusted,omitempty"`
+	Tags          []string           `json:"tag,omitempty"`
+	Stale         bool               `json:"stale"`
+}
+
+type yangMessageCount struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+	Bytes int    `json:"bytes"`
+}
+
+// yangRouter is a router list entry in RFC 7951 JSON encoding.
+type yangRouter struct {
+	Address   string       `json:"address"`
+	MAC       string       `json:"mac-address,omitempty"`
+	Interface string       `json:"interface,omitempty"`
+	HopLimit  int          `json:"hop-limit"`
+	Lifetime  int64        `json:"lifetime"`
+	Managed   bool         `json:"managed"`
+	Other     bool         `json:"other"`
+	MTU       uint32       `json:"mtu,omitempty"`
+	FirstSeen string       `json:"first-seen"`
+	LastSeen  string       `json:"last-seen"`
+	Prefixes  []yangPrefix `json:"prefix,omitempty"`
+	RDNSS     []string     `json:"rdnss,omitempty"`
+}
+
+type yangPrefix struct {
+	Prefix            string `json:"prefix"`
+	ValidLifetime     int64  `json:"valid-lifetime"`
+	PreferredLifetime int64  `json:"preferred-lifetime"`
+	OnLink            bool   `json:"on-link"`
+	Autonomous        bool   `json:"autonomous"`
+}
+
+type yangNeighbors struct {
+	Neighbor []yangNeighbor `json:"neighbor,omitempty"`
+}
+
+type yangRouters struct {
+	Router []yangRouter `json:"router,omitempty"`
+}
+
+type yangTree struct {
+	Routers   yangRouters   `json:"routers"`
+	Neighbors yangNeighbors `json:"neighbors"`
+}
+
+func yangTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func toYANGNeighbor(p PeerSummary) yangNeighbor {
+	n := yangNeighbor{
+		Address:       p.Address,
+		MAC:           p.MAC,
+		Interface:     p.Interface,
+		HopLimit:      p.HopLimit,
+		FirstSeen:     yangTime(p.FirstSeen),
+		LastSeen:      yangTime(p.LastSeen),
+		TotalMessages: p.Total,
+		TotalBytes:    p.TotalBytes,
+		Groups:        p.Groups,
+		GuessedOS:     p.GuessedOS,
+		Hostname:      p.Hostname,
+		Vendor:        p.Vendor,
+		Name:          p.Name,
+		DeviceType:    p.DeviceType,
+		Trusted:       p.Trusted,
+		Tags:          p.Tags,
+		Stale:         p.Stale,
+	}
+	for kind, count := range p.Counts {
+		n.MessageCounts = append(n.MessageCounts, yangMessageCount{Type: kind, Count: count, Bytes: p.Bytes[kind]})
+	}
+	sort.Slice(n.MessageCounts, func(i, j int) bool { return n.MessageCounts[i].Type < n.MessageCounts[j].Type })
+	return n
+}
+
+func toYANGRouter(r RouterInfo) yangRouter {
+	y := yangRouter{
+		Address:   r.Address,
+		MAC:       r.MAC,
+		Interface: r.Interface,
+		HopLimit:  r.HopLimit,
+		Lifetime:  int64(r.Lifetime / time.Second),
+		Managed:   r.Managed,
+		Other:     r.Other,
+		MTU:       r.MTU,
+		FirstSeen: yangTime(r.FirstSeen),
+		LastSeen:  yangTime(r.LastSeen),
+		RDNSS:     r.RDNSS,
+	}
+	for _, p := range r.Prefixes {
+		y.Prefixes = append(y.Prefixes, yangPrefix{
+			Prefix:            p.Prefix,
+			ValidLifetime:     int64(p.ValidLifetime / time.Second),
+			PreferredLifetime: int64(p.PreferredLife / time.Second),
+			OnLink:            p.OnLink,
+			Autonomous:        p.Autonomous,
+		})
+	}
+	return y
+}
+
+// RESTCONFHandler serves the peer and router tables as the read-only
+// "ndpeekr" YANG module (ndpeekr.yang) over RESTCONF (RFC 8040) paths, for
+// model-driven automation tooling:
+//
+//	GET /.well-known/host-meta                                  RESTCONF root discovery
+//	GET /restconf/data/ndpeekr:ndpeekr                          the whole tree
+//	GET /restconf/data/ndpeekr:ndpeekr/routers[/router=<addr>]  routers, or one router
+//	GET /restconf/data/ndpeekr:ndpeekr/neighbors[/neighbor=<addr>]
+//	GET /restconf/data/ietf-yang-library:modules-state          supported modules
+//	GET /restconf/yang/ndpeekr                                  the YANG module source
+//
+// Responses use application/yang-data+json (RFC 7951); errors use the
+// ietf-restconf errors structure.
+func RESTCONFHandler(stats *NDPStats) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/host-meta", restconfGET(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xrd+xml")
+		fmt.Fprint(w, `<XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0">`+"\n"+
+			`  <Link rel="restconf" href="/restconf"/>`+"\n"+`</XRD>`+"\n")
+	}))
+	mux.HandleFunc("/restconf", restconfGET(func(w http.ResponseWriter, r *http.Request) {
+		writeRESTCONF(w, map[string]any{"ietf-restconf:restconf": map[string]any{
+			"data":                 map[string]any{},
+			"operations":           map[string]any{},
+			"yang-library-version": "2016-06-21",
+		}})
+	}))
+	mux.HandleFunc("/restconf/yang/"+yangModuleName, restconfGET(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yang")
+		fmt.Fprint(w, yangModule)
+	}))
+	mux.HandleFunc(restconfDataPrefix, restconfGET(func(w http.ResponseWriter, r *http.Request) {
+		serveRESTCONFData(w, r, stats)
+	}))
+	mux.HandleFunc("/restconf/", restconfGET(func(w http.ResponseWriter, r *http.Request) {
+		writeRESTCONFError(w, http.StatusNotFound, "invalid-value", "unknown resource "+r.URL.Path)
+	}))
+	return mux
+}
+
+// restconfGET rejects methods other than GET and HEAD; the tree is read-only.
+func restconfGET(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeRESTCONFError(w, http.StatusMethodNotAllowed, "operation-not-supported", "the ndpeekr data tree is read-only")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// serveRESTCONFData resolves a data resource path. Only the first node is
+// module-qualified; list keys follow "=" and are percent-decoded.
+func serveRESTCONFData(w http.ResponseWriter, r *http.Request, stats *NDPStats) {
+	var segs []string
+	for _, s := range strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), restconfDataPrefix), "/") {
+		if s == "" {
+			continue
+		}
+		seg, err := url.PathUnescape(s)
+		if err != nil {
+			writeRESTCONFError(w, http.StatusBadRequest, "malformed-message", err.Error())
+			return
+		}
+		segs = append(segs, seg)
+	}
+	if len(segs) == 0 {
+		writeRESTCONFError(w, http.StatusNotFound, "invalid-value", "no data resource given")
+		return
+	}
+	if segs[0] == "ietf-yang-library:modules-state" && len(segs) == 1 {
+		writeRESTCONF(w, yangLibrary())
+		return
+	}
+	if segs[0] != yangModuleName+":ndpeekr" {
+		writeRESTCONFError(w, http.StatusNotFound, "invalid-value", "unknown data node "+segs[0])
+		return
+	}
+
+	notFound := func() {
+		writeRESTCONFError(w, http.StatusNotFound, "invalid-value", "no such resource /"+strings.Join(segs, "/"))
+	}
+	q := yangModuleName + ":"
+	switch len(segs) {
+	case 1:
+		writeRESTCONF(w, map[string]any{q + "ndpeekr": yangTree{
+			Routers:   yangRouters{Router: restconfRouters(stats)},
+			Neighbors: yangNeighbors{Neighbor: restconfNeighbors(stats)},
+		}})
+	case 2:
+		switch segs[1] {
+		case "routers":
+			writeRESTCONF(w, map[string]any{q + "routers": yangRouters{Router: restconfRouters(stats)}})
+		case "neighbors":
+			writeRESTCONF(w, map[string]any{q + "neighbors": yangNeighbors{Neighbor: restconfNeighbors(stats)}})
+		default:
+			notFound()
+		}
+	case 3:
+		list, key, ok := strings.Cut(segs[2], "=")
+		if !ok {
+			notFound()
+			return
+		}
+		switch {
+		case segs[1] == "routers" && list == "router":
+			for _, rt := range restconfRouters(stats) {
+				if sameAddress(rt.Address, key) {
+					writeRESTCONF(w, map[string]any{q + "router": []yangRouter{rt}})
+					return
+				}
+			}
+		case segs[1] == "neighbors" && list == "neighbor":
+			for _, n := range restconfNeighbors(stats) {
+				if sameAddress(n.Address, key) {
+					writeRESTCONF(w, map[string]any{q + "neighbor": []yangNeighbor{n}})
+					return
+				}
+			}
+		}
+		notFound()
+	default:
+		notFound()
+	}
+}
+
+func restconfRouters(stats *NDPStats) []yangRouter {
+	routers := stats.GetRouters()
+	out := make([]yangRouter, len(routers))
+	for i, r := range routers {
+		out[i] = toYANGRouter(r)
+	}
+	return out
+}
+
+func restconfNeighbors(stats *NDPStats) []yangNeighbor {
+	peers := stats.GetStats()
+	out := make([]yangNeighbor, len(peers))
+	for i, p := range peers {
+		out[i] = toYANGNeighbor(p)
+	}
+	return out
+}
+
+// sameAddress compares IPv6 addresses regardless of textual form, so a
+// list key of "fe80:0::1" finds fe80::1. A key without a zone finds the
+// address on any link.
+func sameAddress(a, key string) bool {
+	ipA, ipB := addrKey(a), addrKey(key)
+	if !ipA.IsValid() || !ipB.IsValid() {
+		return a == key
+	}
+	return addrMatches(ipA, ipB)
+}
+
+// yangLibrary describes the supported modules in the RFC 7895 format.
+func yangLibrary() map[string]any {
+	return map[string]any{"ietf-yang-library:modules-state": map[string]any{
+		"module-set-id": yangModuleName + "@" + yangModuleRevision,
+		"module": []map[string]any{{
+			"name":             yangModuleName,
+			"revision":         yangModuleRevision,
+			"schema":           "/restconf/yang/" + yangModuleName,
+			"namespace":        yangNamespace,
+			"conformance-type": "implement",
+		}},
+	}}
+}
+
+func writeRESTCONF(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", restconfMediaType)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeRESTCONFError(w http.ResponseWriter, code int, tag, msg string) {
+	w.Header().Set("Content-Type", restconfMediaType)
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"ietf-restconf:errors": map[string]any{
+		"error": []map[string]string{{
+			"error-type":    "protocol",
+			"error-tag":     tag,
+			"error-message": msg,
+		}},
+	}})
+}
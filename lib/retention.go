@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	// historyRetainInterval is how often a HistoryRecorder applies the
+	// retention limits.
+	historyRetainInterval = 10 * time.Minute
+	// retainSizeStep is the share of the remaining samples dropped per round
+	// while the database is over its size limit.
+	retainSizeStep = 10
+	// compactFreeShare is the share of the file, in percent, that must be
+	// free pages before Retain rewrites the database.
+	compactFreeShare = 25
+)
+
+// RetentionResult reports what one Retain call did.
+type RetentionResult struct {
+	Samples   int64 // samples dropped, with their peer and router rows
+	Alerts    int64 // alerts dropped
+	Compacted bool  // the database was rewritten to return free space
+	Bytes     int64 // size of the data left, in bytes
+}
+
+// Retain drops samples and alerts older than maxAge, then the oldest
+// samples (and the alerts up to them) while the data takes more than
+// maxBytes, keeping at least the newest sample. A zero limit is not
+// applied. When dropped rows leave a quarter of the file free, the database
+// is compacted with VACUUM so the file shrinks.
+func (h *HistoryDB) Retain(now time.Time, maxAge time.Duration, maxBytes int64) (RetentionResult, error) {
+	var res RetentionResult
+	if maxAge > 0 {
+		if err := h.dropBefore(now.Add(-maxAge).UnixNano(), &res); err != nil {
+			return res, err
+		}
+	}
+
+	pages, free, pageSize, err := h.pageStats()
+	if err != nil {
+		return res, err
+	}
+	res.Bytes = (pages - free) * pageSize
+	for maxBytes > 0 && res.Bytes > maxBytes {
+		var samples int64
+		if err := h.db.QueryRow(`SELECT COUNT(*) FROM samples`).Scan(&samples); err != nil {
+			return res, fmt.Errorf("retain history: %w", err)
+		}
+		if samples <= 1 {
+			break
+		}
+		// The sample after the oldest tenth (at least one) is the new cutoff.
+		var cutoff int64
+		err := h.db.QueryRow(`SELECT ts FROM samples ORDER BY ts LIMIT 1 OFFSET ?`,
+			max(samples/retainSizeStep, 1)).Scan(&cutoff)
+		if err != nil {
+			return res, fmt.Errorf("retain history: %w", err)
+		}
+		if err := h.dropBefore(cutoff, &res); err != nil {
+			return res, err
+		}
+		if pages, free, pageSize, err = h.pageStats(); err != nil {
+			return res, err
+		}
+		res.Bytes = (pages - free) * pageSize
+	}
+
+	if (res.Samples > 0 || res.Alerts > 0) && free*100 >= pages*compactFreeShare {
+		if _, err := h.db.Exec(`VACUUM`); err != nil {
+			return res, fmt.Errorf("compact history: %w", err)
+		}
+		res.Compacted = true
+	}
+	return res, nil
+}
+
+// dropBefore deletes the samples, peer and router rows, counters and alerts
+// older than ts (Unix nanoseconds) in one transaction, adding the counts to
+// res.
+func (h *HistoryDB) dropBefore(ts int64, res *RetentionResult) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return fmt.Errorf("retain history: %w", err)
+	}
+	defer tx.Rollback()
+
+	var samples, alerts sql.Result
+	if samples, err = tx.Exec(`DELETE FROM samples WHERE ts < ?`, ts); err != nil {
+		return fmt.Errorf("retain history samples: %w", err)
+	}
+	for _, table := range []string{"peers", "routers", "counters"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE ts < ?`, ts); err != nil {
+			return fmt.Errorf("retain history %s: %w", table, err)
+		}
+	}
+	if alerts, err = tx.Exec(`DELETE FROM alerts WHERE ts < ?`, ts); err != nil {
+		return fmt.Errorf("retain history alerts: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("retain history: %w", err)
+	}
+	n, _ := samples.RowsAffected()
+	res.Samples += n
+	n, _ = alerts.RowsAffected()
+	res.Alerts += n
+	return nil
+}
+
+// pageStats returns the database's page count, free page count and page
+// size.
+func (h *HistoryDB) pageStats() (pages, free, size int64, err error) {
+	for _, q := range []struct {
+		pragma string
+		v      *int64
+	}{{"page_count", &pages}, {"freelist_count", &free}, {"page_size", &size}} {
+		if err := h.db.QueryRow(`PRAGMA ` + q.pragma).Scan(q.v); err != nil {
+			return 0, 0, 0, fmt.Errorf("read history size: %w", err)
+		}
+	}
+	return pages, free, size, nil
+}
@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryRetain(t *testing.T) {
+	db, err := OpenHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		snap := Snapshot{Taken: now.Add(-time.Duration(100-i) * time.Hour)}
+		for j := 0; j < 20; j++ {
+			snap.Peers = append(snap.Peers, PeerSummary{Address: fmt.Sprintf("2001:db8::%x", j), MAC: "aa:bb:cc:dd:ee:ff", Total: 1})
+		}
+		snap.Alerts = []Alert{{Time: snap.Taken, Severity: SeverityWarning, Category: "test", Source: "fe80::1", Message: "x"}}
+		if err := db.Record(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(table string) int64 {
+		t.Helper()
+		var n int64
+		if err := db.db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// By age: samples from 100h to 51h ago go.
+	res, err := db.Retain(now, 50*time.Hour+time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Samples != 50 || res.Alerts != 50 || count("samples") != 50 || count("peers") != 50*20 {
+		t.Fatalf("after max_age: %+v, %d samples, %d peer rows", res, count("samples"), count("peers"))
+	}
+	if !res.Compacted {
+		t.Error("dropping half the rows should compact")
+	}
+
+	// By size: the oldest samples go until the data fits, newest kept.
+	const limit = 64 << 10
+	res, err = db.Retain(now, 0, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Bytes > limit || res.Samples == 0 {
+		t.Fatalf("after max_size: %+v", res)
+	}
+	var newest int64
+	if err := db.db.QueryRow(`SELECT MAX(ts) FROM samples`).Scan(&newest); err != nil || newest != now.Add(-time.Hour).UnixNano() {
+		t.Errorf("newest sample = %d, %v; want the last one kept", newest, err)
+	}
+
+	// Nothing to drop: no compaction.
+	if res, err = db.Retain(now, 0, limit); err != nil || res.Samples != 0 || res.Compacted {
+		t.Errorf("second Retain = %+v, %v; want a no-op", res, err)
+	}
+}
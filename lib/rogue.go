@@ -0,0 +1,221 @@
+package lib
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// defaultMinRALifetime is the shortest router or prefix lifetime not
+// flagged as short.
+const defaultMinRALifetime = time.Minute
+
+// RogueRAConfig turns on rogue RA detection: RAs from routers not in Trusted
+// raise a critical rogue_ra alert. Unlike RAGuardConfig, which treats every
+// RA as a leak, it expects RAs and watches for the wrong routers sending
+// them, for lifetimes that tear down routes or addresses, and for routers
+// on one link advertising conflicting prefixes. The lifetime and prefix
+// checks run even with no trusted routers listed.
+type RogueRAConfig struct {
+	// Trusted lists the routers allowed to advertise, by address or MAC.
+	Trusted []string `yaml:"trusted"`
+	// MinLifetime is the shortest router lifetime or prefix valid lifetime
+	// not flagged as short (default 1m).
+	MinLifetime time.Duration `yaml:"min_lifetime"`
+}
+
+// AddTrusted adds the comma-separated routers in list to Trusted, as
+// --trusted-routers gives them.
+func (c *RogueRAConfig) AddTrusted(list string) error {
+	var added []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			added = append(added, s)
+		}
+	}
+	if _, err := newRouterSet("--trusted-routers", added); err != nil {
+		return err
+	}
+	c.Trusted = append(c.Trusted, added...)
+	return nil
+}
+
+// rogueRA is a compiled RogueRAConfig.
+type rogueRA struct {
+	trusted     *routerSet
+	minLifetime time.Duration
+}
+
+func newRogueRA(cfg RogueRAConfig) (*rogueRA, error) {
+	trusted, err := newRouterSet("rogue_ra.trusted", cfg.Trusted)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MinLifetime < 0 {
+		return nil, fmt.Errorf("rogue_ra.min_lifetime must not be negative")
+	}
+	if cfg.MinLifetime == 0 {
+		cfg.MinLifetime = defaultMinRALifetime
+	}
+	return &rogueRA{trusted: trusted, minLifetime: cfg.MinLifetime}, nil
+}
+
+// isTrusted reports whether ri comes from a trusted router. With no trusted
+// routers listed every router is.
+func (d *rogueRA) isTrusted(ri RouterInfo) bool {
+	if len(d.trusted.addrs) == 0 && len(d.trusted.macs) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ri.Address)
+	return err == nil && d.trusted.allowed(addr, ri.MAC)
+}
+
+// alerts checks ri, the RA just received, against prev, the same router's
+// previous RA (nil for a new router), and routers, every router known
+// before it.
+func (d *rogueRA) alerts(ri RouterInfo, prev *RouterInfo, routers []RouterInfo) []Alert {
+	var out []Alert
+	where := ""
+	if ri.Interface != "" {
+		where = " on " + ri.Interface
+	}
+	if !d.isTrusted(ri) {
+		from := ri.Address
+		if ri.MAC != "" {
+			from += " (" + ri.MAC + ")"
+		}
+		var prefixes []string
+		for _, p := range ri.Prefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+		msg := fmt.Sprintf("RA from untrusted router %s%s (router lifetime %s", from, where, formatDuration(ri.Lifetime))
+		if len(prefixes) > 0 {
+			msg += ", prefixes " + strings.Join(prefixes, ", ")
+		}
+		out = append(out, Alert{Severity: SeverityCritical, Category: "rogue_ra", Source: ri.Address, Message: msg + ")"})
+	}
+
+	switch {
+	case ri.Lifetime == 0 && prev != nil && prev.Lifetime > 0:
+		out = append(out, Alert{
+			Severity: SeverityWarning,
+			Category: "ra_zero_lifetime",
+			Source:   ri.Address,
+			Message: fmt.Sprintf("router %s%s dropped its router lifetime from %s to 0: hosts remove it as a default router",
+				ri.Address, where, formatDuration(prev.Lifetime)),
+		})
+	case ri.Lifetime > 0 && ri.Lifetime < d.minLifetime:
+		out = append(out, Alert{
+			Severity: SeverityWarning,
+			Category: "ra_short_lifetime",
+			Source:   ri.Address,
+			Message: fmt.Sprintf("router %s%s advertises a router lifetime of %s: hosts lose their default route if an RA is missed",
+				ri.Address, where, formatDuration(ri.Lifetime)),
+		})
+	}
+	for _, p := range ri.Prefixes {
+		if (p.OnLink || p.Autonomous) && p.ValidLifetime < d.minLifetime {
+			out = append(out, Alert{
+				Severity: SeverityWarning,
+				Category: "ra_short_lifetime",
+				Source:   ri.Address,
+				Message: fmt.Sprintf("router %s%s advertises %s with a valid lifetime of %s: hosts drop its addresses or routes",
+					ri.Address, where, p.Prefix, formatDuration(p.ValidLifetime)),
+			})
+		}
+	}
+
+	for _, other := range routers {
+		if other.Address == ri.Address || other.Interface != ri.Interface {
+			continue
+		}
+		if a, ok := prefixConflict(ri, other); ok {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// prefixConflict reports the first prefix ri advertises that overlaps one
+// other advertises on the same link with a different length: hosts then
+// disagree on what is on-link and which addresses to form.
+func prefixConflict(ri, other RouterInfo) (Alert, bool) {
+	for _, p := range ri.Prefixes {
+		mine, err := netip.ParsePrefix(p.Prefix)
+		if err != nil {
+			continue
+		}
+		for _, q := range other.Prefixes {
+			theirs, err := netip.ParsePrefix(q.Prefix)
+			if err != nil || mine == theirs || !mine.Overlaps(theirs) {
+				continue
+			}
+			return Alert{
+				Severity: SeverityWarning,
+				Category: "ra_prefix_conflict",
+				Source:   ri.Address,
+				Message: fmt.Sprintf("router %s advertises %s but router %s on the same link advertises %s",
+					ri.Address, mine, other.Address, theirs),
+			}, true
+		}
+	}
+	return Alert{}, false
+}
+
+// checkRogueRA raises the alerts rogue RA detection finds in ri. It must run
+// before ri is recorded, so the router's previous RA is still known. Each
+// category alerts once per router within the window.
+func (l *NDPListener) checkRogueRA(ri RouterInfo, port string) {
+	if l.rogueRA == nil || l.cfg.Stats == nil {
+		return
+	}
+	routers := l.cfg.Stats.GetRouters()
+	var prev *RouterInfo
+	for i := range routers {
+		if routers[i].Address == ri.Address {
+			prev = &routers[i]
+		}
+	}
+	if !l.rogueRA.isTrusted(ri) {
+		l.cfg.Stats.recordRogueRA(ri.Address, l.now())
+	}
+	for _, a := range l.rogueRA.alerts(ri, prev, routers) {
+		a.Port = port
+		l.raiseAlertOnce(a.Category+"|"+ri.Address, a)
+	}
+}
+
+// RogueRAStats is the rogue RA detection state (see RogueRAConfig).
+type RogueRAStats struct {
+	Enabled bool `json:"enabled"`
+	// Trusted is how many routers are listed as trusted.
+	Trusted int `json:"trusted"`
+	// RogueRAs counts RAs from untrusted routers since startup.
+	RogueRAs int `json:"rogue_ras"`
+	// LastRogue and LastSource describe the most recent one.
+	LastRogue  time.Time `json:"last_rogue,omitempty"`
+	LastSource string    `json:"last_source,omitempty"`
+}
+
+// enableRogueRA marks rogue RA detection as on.
+func (s *NDPStats) enableRogueRA(trusted int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rogueRA.Enabled, s.rogueRA.Trusted = true, trusted
+}
+
+// recordRogueRA counts an RA from an untrusted router.
+func (s *NDPStats) recordRogueRA(src string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rogueRA.RogueRAs++
+	s.rogueRA.LastRogue, s.rogueRA.LastSource = at, src
+}
+
+// RogueRA returns the rogue RA detection state.
+func (s *NDPStats) RogueRA() RogueRAStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rogueRA
+}
@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestRogueRAAlerts(t *testing.T) {
+	d, err := newRogueRA(RogueRAConfig{Trusted: []string{"fe80::1", "02:00:00:00:00:fe"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := RouterInfo{Address: "fe80::1", Interface: "eth0", Lifetime: 30 * time.Minute,
+		Prefixes: []PrefixInfo{{Prefix: "2001:db8:1::/64", ValidLifetime: 24 * time.Hour, OnLink: true, Autonomous: true}}}
+	categories := func(alerts []Alert) map[string]int {
+		n := make(map[string]int)
+		for _, a := range alerts {
+			n[a.Category]++
+		}
+		return n
+	}
+
+	if got := d.alerts(gw, &gw, nil); len(got) != 0 {
+		t.Errorf("trusted router alerted: %+v", got)
+	}
+	byMAC := gw
+	byMAC.Address, byMAC.MAC = "fe80::2", "02:00:00:00:00:fe"
+	if got := d.alerts(byMAC, nil, nil); len(got) != 0 {
+		t.Errorf("router trusted by MAC alerted: %+v", got)
+	}
+
+	withdrawn := gw
+	withdrawn.Lifetime = 0
+	if n := categories(d.alerts(withdrawn, &gw, nil)); n["ra_zero_lifetime"] != 1 || len(n) != 1 {
+		t.Errorf("zeroed lifetime: %v", n)
+	}
+	if n := categories(d.alerts(withdrawn, nil, nil)); len(n) != 0 {
+		t.Errorf("router never a default router: %v", n)
+	}
+
+	rogue := RouterInfo{Address: "fe80::66", Interface: "eth0", Lifetime: 10 * time.Second,
+		Prefixes: []PrefixInfo{{Prefix: "2001:db8:1::/48", ValidLifetime: 0, OnLink: true}}}
+	n := categories(d.alerts(rogue, nil, []RouterInfo{gw}))
+	if n["rogue_ra"] != 1 || n["ra_short_lifetime"] != 2 || n["ra_prefix_conflict"] != 1 {
+		t.Errorf("rogue router: %v", n)
+	}
+	rogue.Interface = "eth1" // prefixes on another link don't conflict
+	if n := categories(d.alerts(rogue, nil, []RouterInfo{gw})); n["ra_prefix_conflict"] != 0 {
+		t.Errorf("conflict across links: %v", n)
+	}
+
+	if _, err := newRogueRA(RogueRAConfig{Trusted: []string{"gw"}}); err == nil {
+		t.Error("invalid trusted entry accepted")
+	}
+	var cfg RogueRAConfig
+	if err := cfg.AddTrusted("fe80::1, 02:00:00:00:00:fe"); err != nil || len(cfg.Trusted) != 2 {
+		t.Errorf("AddTrusted = %v, %v", cfg.Trusted, err)
+	}
+}
+
+func TestRogueRAListener(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:   stats,
+		RogueRA: &RogueRAConfig{Trusted: []string{"fe80::1"}},
+	})
+	rogueMAC := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x66}
+	l.handle(received{src: netip.MustParseAddr("fe80::1"), hopLimit: 255, payload: buildRAFull(64, false, false, 1800, nil)})
+	for i := 0; i < 3; i++ {
+		l.handle(received{src: netip.MustParseAddr("fe80::66"), hopLimit: 255, payload: buildRAFull(64, false, false, 1800, rogueMAC)})
+	}
+	if st := stats.RogueRA(); !st.Enabled || st.Trusted != 1 || st.RogueRAs != 3 || st.LastSource != "fe80::66" {
+		t.Errorf("stats = %+v", st)
+	}
+	var rogue int
+	for _, a := range stats.GetAlerts() {
+		if a.Category == "rogue_ra" {
+			rogue++
+		}
+	}
+	if rogue != 1 {
+		t.Errorf("%d rogue_ra alerts, want one per window", rogue)
+	}
+}
@@ -0,0 +1,73 @@
+package lib
+
+// Hop-by-Hop Router Alert option (RFC 2711); MLD uses value 0 (RFC 2710, RFC 3810).
+const (
+	hbhOptPad1        = 0
+	hbhOptRouterAlert = 5
+	routerAlertMLD    = 0
+)
+
+// Reasons an MLD message fails Router Alert validation.
+const (
+	raNoHopByHop = "no_hop_by_hop"        // no Hop-by-Hop header at all
+	raMissing    = "no_router_alert"      // Hop-by-Hop header without the option
+	raNotMLD     = "router_alert_not_mld" // option present with a value other than 0 (MLD)
+)
+
+// checkMLDRouterAlert validates the Hop-by-Hop header of an MLD message.
+// hbh is the option area of the header (nil if the packet had none).
+// It returns "" if a Router Alert option with the MLD value is present,
+// otherwise the reason.
+func checkMLDRouterAlert(hbh []byte) string {
+	if hbh == nil {
+		return raNoHopByHop
+	}
+	for off := 0; off < len(hbh); {
+		typ := hbh[off]
+		if typ == hbhOptPad1 {
+			off++
+			continue
+		}
+		if off+2 > len(hbh) {
+			break
+		}
+		n := int(hbh[off+1])
+		if off+2+n > len(hbh) {
+			break
+		}
+		if typ == hbhOptRouterAlert {
+			if n == 2 && hbh[off+2] == 0 && hbh[off+3] == routerAlertMLD {
+				return ""
+			}
+			return raNotMLD
+		}
+		off += 2 + n
+	}
+	return raMissing
+}
+
+// RecordMLDRouterAlertViolation counts an MLD message from ip that failed
+// Router Alert validation for reason.
+func (s *NDPStats) RecordMLDRouterAlertViolation(ip, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.routerAlertViolations[reason]++
+	s.getOrCreatePeer(ip, s.now()).NoRouterAlert++
+}
+
+// GetMLDRouterAlertViolations returns the Router Alert violation counts by reason.
+func (s *NDPStats) GetMLDRouterAlertViolations() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.routerAlertViolationsLocked()
+}
+
+// routerAlertViolationsLocked copies the violation counters. Callers must hold s.mu.
+func (s *NDPStats) routerAlertViolationsLocked() map[string]int {
+	result := make(map[string]int, len(s.routerAlertViolations))
+	for k, v := range s.routerAlertViolations {
+		result[k] = v
+	}
+	return result
+}
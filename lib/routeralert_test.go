@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCheckMLDRouterAlert(t *testing.T) {
+	tests := []struct {
+		name string
+		hbh  []byte
+		want string
+	}{
+		{"router alert + PadN", []byte{5, 2, 0, 0, 1, 0}, ""},
+		{"Pad1 then router alert", []byte{0, 0, 5, 2, 0, 0}, ""},
+		{"no hop-by-hop header", nil, raNoHopByHop},
+		{"only padding", []byte{1, 4, 0, 0, 0, 0}, raMissing},
+		{"router alert for RSVP", []byte{5, 2, 0, 1, 1, 0}, raNotMLD},
+		{"truncated option", []byte{1, 9, 0, 0, 0, 0}, raMissing},
+	}
+	for _, tt := range tests {
+		if got := checkMLDRouterAlert(tt.hbh); got != tt.want {
+			t.Errorf("%s: checkMLDRouterAlert = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandlePacket_MLDWithoutRouterAlert(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	report := buildMLDv1Report(net.ParseIP("ff02::fb"))
+	l.handlePacket(buildIPv6Packet("fe80::1", "ff02::fb", 1, []extHeader{routerAlertHBH}, nhICMPv6, report), 0, "")
+	l.handlePacket(buildIPv6Packet("fe80::2", "ff02::fb", 1, nil, nhICMPv6, report), 0, "")
+
+	if got := stats.GetMLDRouterAlertViolations(); len(got) != 1 || got[raNoHopByHop] != 1 {
+		t.Errorf("violations = %v, want no_hop_by_hop: 1", got)
+	}
+	for _, p := range stats.GetStats() {
+		want := 0
+		if p.Address == "fe80::2" {
+			want = 1
+		}
+		if p.NoRouterAlert != want {
+			t.Errorf("%s NoRouterAlert = %d, want %d", p.Address, p.NoRouterAlert, want)
+		}
+	}
+	alerts := stats.GetAlerts()
+	if len(alerts) != 1 || alerts[0].Category != "mld_no_router_alert" || alerts[0].Source != "fe80::2" {
+		t.Errorf("alerts = %+v, want one mld_no_router_alert for fe80::2", alerts)
+	}
+}
@@ -0,0 +1,375 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultRuleInterval is how often rules are evaluated when unset.
+const defaultRuleInterval = 10 * time.Second
+
+// builtinRulePacks holds the rule packs shipped with NDPeekr, selectable by
+// name in rules.packs.
+//
+//go:embed rulepacks/*.yaml
+var builtinRulePacks embed.FS
+
+// RulePack is a named set of detection rules, as found in a rule pack file.
+type RulePack struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"` // free-form, e.g. "2024.3"; shown in the status view
+	Description string `yaml:"description"`
+	Rules       []Rule `yaml:"rules"`
+}
+
+// RulePackInfo describes a loaded rule pack, for the status view.
+type RulePackInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Source  string `json:"source"` // "builtin" or the file path
+	Rules   int    `json:"rules"`  // rules in the pack, enabled or not
+}
+
+// Rule raises an alert for each peer matching a filter expression. The alert
+// category is the rule name and the message is a text/template over
+// PeerSummary. Each rule fires at most once per peer per window.
+type Rule struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Severity    Severity `yaml:"severity"`
+	Match       string   `yaml:"match"`   // filter expression (see Filter)
+	Message     string   `yaml:"message"` // default: the description
+
+	Pack string `yaml:"-"` // the pack the rule came from
+
+	filter  *Filter
+	message *template.Template
+}
+
+// compile parses the rule's expression and message template.
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule without a name")
+	}
+	if r.Match == "" {
+		return fmt.Errorf("rule %s: match is required", r.Name)
+	}
+	f, err := ParseFilter(r.Match)
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	r.filter = f
+	msg := r.Message
+	if msg == "" {
+		msg = r.Description + " ({{.Address}})"
+	}
+	t, err := template.New(r.Name).Option("missingkey=error").Parse(msg)
+	if err != nil {
+		return fmt.Errorf("rule %s: message: %w", r.Name, err)
+	}
+	r.message = t
+	return nil
+}
+
+// BuiltinRulePacks lists the names of the shipped rule packs.
+func BuiltinRulePacks() []string {
+	entries, _ := fs.ReadDir(builtinRulePacks, "rulepacks")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	return names
+}
+
+// parseRulePack decodes and compiles a rule pack file.
+func parseRulePack(data []byte, source string) (RulePack, error) {
+	var pack RulePack
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&pack); err != nil {
+		return pack, fmt.Errorf("rule pack %s: %w", source, err)
+	}
+	if pack.Name == "" {
+		pack.Name = strings.TrimSuffix(path.Base(source), path.Ext(source))
+	}
+	for i := range pack.Rules {
+		pack.Rules[i].Pack = pack.Name
+		if err := pack.Rules[i].compile(); err != nil {
+			return pack, fmt.Errorf("rule pack %s: %w", source, err)
+		}
+	}
+	return pack, nil
+}
+
+// loadRules loads the built-in packs and files selected by cfg, in that
+// order, and drops disabled rules. A rule in a later pack replaces an
+// earlier rule of the same name, so site files can override shipped rules.
+// It also describes every pack it loaded.
+func loadRules(cfg RulesConfig) ([]Rule, []RulePackInfo, error) {
+	var packs []RulePack
+	var infos []RulePackInfo
+	for _, name := range cfg.Packs {
+		data, err := builtinRulePacks.ReadFile("rulepacks/" + name + ".yaml")
+		if err != nil {
+			return nil, nil, fmt.Errorf("unknown rule pack %q (built in: %s)", name, strings.Join(BuiltinRulePacks(), ", "))
+		}
+		pack, err := parseRulePack(data, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		packs = append(packs, pack)
+		infos = append(infos, RulePackInfo{Name: pack.Name, Version: pack.Version, Source: "builtin", Rules: len(pack.Rules)})
+	}
+	for _, file := range cfg.Files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read rule pack: %w", err)
+		}
+		pack, err := parseRulePack(data, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		packs = append(packs, pack)
+		infos = append(infos, RulePackInfo{Name: pack.Name, Version: pack.Version, Source: file, Rules: len(pack.Rules)})
+	}
+
+	var rules []Rule
+	index := make(map[string]int)
+	for _, pack := range packs {
+		for _, r := range pack.Rules {
+			if slices.Contains(cfg.Disable, r.Name) || slices.Contains(cfg.Disable, pack.Name) {
+				continue
+			}
+			if i, ok := index[r.Name]; ok {
+				rules[i] = r
+				continue
+			}
+			index[r.Name] = len(rules)
+			rules = append(rules, r)
+		}
+	}
+	return rules, infos, nil
+}
+
+// RuleEngineConfig configures a RuleEngine.
+type RuleEngineConfig struct {
+	Rules    []Rule        // compiled, e.g. from Config.DetectionRules
+	Interval time.Duration // between evaluations; defaultRuleInterval if 0
+	Logger   *slog.Logger  // required
+	Stats    *NDPStats     // required; supplies the peers and records alerts
+	Sinks    []Sink        // optional; receive the alerts
+}
+
+// RuleStatus is a rule's definition and how it has behaved since startup,
+// for tuning noisy rules.
+type RuleStatus struct {
+	Name        string    `json:"name"`
+	Pack        string    `json:"pack"`
+	Description string    `json:"description"`
+	Severity    Severity  `json:"severity"`
+	Match       string    `json:"match"`
+	Enabled     bool      `json:"enabled"`
+	Matching    int       `json:"matching"` // peers matching at the last evaluation
+	Fired       int       `json:"fired"`    // alerts raised
+	Acked       int       `json:"acked"`    // alerts acknowledged in the TUI
+	Ignored     int       `json:"ignored"`  // alerts left to expire unacknowledged
+	LastFired   time.Time `json:"last_fired"`
+}
+
+// AckRate is the share of the rule's seen alerts that were acknowledged,
+// or -1 if none were seen yet.
+func (r RuleStatus) AckRate() float64 {
+	if r.Acked+r.Ignored == 0 {
+		return -1
+	}
+	return float64(r.Acked) / float64(r.Acked+r.Ignored)
+}
+
+// ruleState is a rule plus its counters.
+type ruleState struct {
+	rule                            Rule
+	enabled                         bool
+	matching, fired, acked, ignored int
+	last                            time.Time
+}
+
+// RuleEngine evaluates data-driven detection rules against the peer table
+// on its own cadence and raises an alert for every match. Rules can be
+// retuned and switched off while running; such changes last until exit.
+type RuleEngine struct {
+	cfg RuleEngineConfig
+
+	mu    sync.Mutex
+	rules []*ruleState
+}
+
+// NewRuleEngine returns a rule engine with every rule enabled.
+func NewRuleEngine(cfg RuleEngineConfig) *RuleEngine {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultRuleInterval
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	e := &RuleEngine{cfg: cfg}
+	for _, r := range cfg.Rules {
+		e.rules = append(e.rules, &ruleState{rule: r, enabled: true})
+	}
+	return e
+}
+
+// Run evaluates the rules every interval until ctx is cancelled.
+func (e *RuleEngine) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			e.evaluate(now)
+		}
+	}
+}
+
+// evaluate checks every enabled rule against every peer once.
+func (e *RuleEngine) evaluate(now time.Time) {
+	peers := e.cfg.Stats.GetStats()
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Address < peers[j].Address })
+
+	var alerts []Alert
+	e.mu.Lock()
+	for _, st := range e.rules {
+		st.matching = 0
+		if !st.enabled {
+			continue
+		}
+		r := st.rule
+		for _, p := range peers {
+			if !r.filter.Match(p) {
+				continue
+			}
+			st.matching++
+			if !e.cfg.Stats.alertDue("rule|"+r.Name+"|"+p.Address, now) {
+				continue
+			}
+			var msg bytes.Buffer
+			if err := r.message.Execute(&msg, p); err != nil {
+				e.cfg.Logger.Warn("rule message failed", "rule", r.Name, "err", err)
+				msg.Reset()
+				msg.WriteString(r.Description)
+			}
+			st.fired++
+			st.last = now
+			alerts = append(alerts, Alert{
+				Time:     now,
+				Severity: r.Severity,
+				Category: r.Name,
+				Source:   p.Address,
+				Message:  msg.String(),
+				Port:     p.Port,
+			})
+		}
+	}
+	e.mu.Unlock()
+
+	// Sinks may be slow; don't hold the lock while writing to them.
+	for _, a := range alerts {
+		emitAlert(e.cfg.Stats, e.cfg.Logger, e.cfg.Sinks, a)
+	}
+}
+
+// Status returns every rule with its counters, in load order.
+func (e *RuleEngine) Status() []RuleStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	out := make([]RuleStatus, len(e.rules))
+	for i, st := range e.rules {
+		out[i] = RuleStatus{
+			Name:        st.rule.Name,
+			Pack:        st.rule.Pack,
+			Description: st.rule.Description,
+			Severity:    st.rule.Severity,
+			Match:       st.rule.Match,
+			Enabled:     st.enabled,
+			Matching:    st.matching,
+			Fired:       st.fired,
+			Acked:       st.acked,
+			Ignored:     st.ignored,
+			LastFired:   st.last,
+		}
+	}
+	return out
+}
+
+// lookup returns the named rule's state. Callers must hold e.mu.
+func (e *RuleEngine) lookup(name string) *ruleState {
+	for _, st := range e.rules {
+		if st.rule.Name == name {
+			return st
+		}
+	}
+	return nil
+}
+
+// SetMatch replaces the named rule's filter expression, e.g. to raise a
+// threshold. The counters are kept.
+func (e *RuleEngine) SetMatch(name, expr string) error {
+	f, err := ParseFilter(expr)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	st := e.lookup(name)
+	if st == nil {
+		return fmt.Errorf("no rule %q", name)
+	}
+	st.rule.Match = expr
+	st.rule.filter = f
+	return nil
+}
+
+// SetEnabled switches the named rule on or off.
+func (e *RuleEngine) SetEnabled(name string, enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if st := e.lookup(name); st != nil {
+		st.enabled = enabled
+	}
+}
+
+// RecordFeedback counts an alert of the given category as acknowledged or
+// ignored by the operator. Categories that aren't rules are ignored.
+func (e *RuleEngine) RecordFeedback(category string, acked bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	st := e.lookup(category)
+	if st == nil {
+		return
+	}
+	if acked {
+		st.acked++
+	} else {
+		st.ignored++
+	}
+}
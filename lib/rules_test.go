@@ -0,0 +1,157 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuiltinRulePacksCompile(t *testing.T) {
+	packs := BuiltinRulePacks()
+	if len(packs) < 2 {
+		t.Fatalf("built-in packs = %v, want home and enterprise at least", packs)
+	}
+	rules, _, err := loadRules(RulesConfig{Packs: packs})
+	if err != nil {
+		t.Fatalf("loadRules: %v", err)
+	}
+	for _, r := range rules {
+		if r.Description == "" {
+			t.Errorf("rule %s has no description", r.Name)
+		}
+		if err := r.message.Execute(io.Discard, PeerSummary{Address: "fe80::1"}); err != nil {
+			t.Errorf("rule %s message: %v", r.Name, err)
+		}
+	}
+}
+
+func TestLoadConfig_Rules(t *testing.T) {
+	site := filepath.Join(t.TempDir(), "site.yaml")
+	if err := os.WriteFile(site, []byte(`
+rules:
+  - name: untrusted_router
+    description: Overridden by the site
+    severity: info
+    match: counts.ra > 5
+  - name: chatty
+    description: Very chatty host
+    severity: warning
+    match: total > 1000
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(writeTempConfig(t, `
+rules:
+  packs: [enterprise]
+  files: [`+site+`]
+  disable: [random_mac_router]
+`))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	byName := make(map[string]Rule)
+	for _, r := range cfg.DetectionRules() {
+		byName[r.Name] = r
+	}
+	if r := byName["untrusted_router"]; r.Severity != SeverityInfo || r.Pack != "site" {
+		t.Errorf("untrusted_router = %+v, want the site override", r)
+	}
+	if _, ok := byName["chatty"]; !ok {
+		t.Error("site rule chatty missing")
+	}
+	if _, ok := byName["random_mac_router"]; ok {
+		t.Error("disabled rule random_mac_router loaded")
+	}
+	if _, ok := byName["untrusted_redirect"]; !ok {
+		t.Error("built-in rule untrusted_redirect missing")
+	}
+}
+
+func TestRuleEngine_Evaluate(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_advertisement")
+	stats.RecordMessage("fe80::2", "router_advertisement")
+	stats.SetEnrichment("fe80::2", Enrichment{Name: "core", Trusted: true})
+	stats.RecordMessage("fe80::3", "neighbor_solicitation")
+
+	rules, _, err := loadRules(RulesConfig{Packs: []string{"enterprise"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewRuleEngine(RuleEngineConfig{
+		Rules:  rules,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+	now := time.Now()
+	e.evaluate(now)
+	e.evaluate(now.Add(time.Minute)) // within the window: no repeat
+
+	alerts := stats.GetAlerts()
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1: %+v", len(alerts), alerts)
+	}
+	a := alerts[0]
+	if a.Category != "untrusted_router" || a.Source != "fe80::1" || a.Severity != SeverityCritical {
+		t.Errorf("alert = %+v, want critical untrusted_router for fe80::1", a)
+	}
+	if want := "fe80::1 (unknown) sends Router Advertisements but is not a trusted router"; a.Message != want {
+		t.Errorf("message = %q, want %q", a.Message, want)
+	}
+}
+
+func TestRuleEngine_Tuning(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_solicitation")
+	rules, _, err := loadRules(RulesConfig{Packs: []string{"home"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewRuleEngine(RuleEngineConfig{
+		Rules:  rules,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  stats,
+	})
+
+	// Lower the RS storm threshold so the peer matches, and switch
+	// another rule off.
+	if err := e.SetMatch("rs_storm", "counts.rs > 0"); err != nil {
+		t.Fatalf("SetMatch: %v", err)
+	}
+	if err := e.SetMatch("rs_storm", "counts.rs >"); err == nil {
+		t.Error("SetMatch accepted a bad expression")
+	}
+	e.SetEnabled("nd_proxy_seen", false)
+
+	m := NewModel(ModelConfig{Stats: stats, Rules: e})
+	now := time.Now()
+	e.evaluate(now)
+	m.updateToasts(now)
+	m.ackToast()
+
+	stats.RecordMessage("fe80::2", "router_solicitation")
+	e.evaluate(now.Add(time.Second))
+	m.updateToasts(now.Add(time.Second))
+	m.updateToasts(now.Add(time.Minute)) // expires unacknowledged
+
+	byName := make(map[string]RuleStatus)
+	for _, r := range e.Status() {
+		byName[r.Name] = r
+	}
+	rs := byName["rs_storm"]
+	if rs.Match != "counts.rs > 0" || rs.Fired != 2 || rs.Matching != 2 || rs.Acked != 1 || rs.Ignored != 1 || rs.AckRate() != 0.5 {
+		t.Errorf("rs_storm = %+v, want 2 fired, 1 acked, 1 ignored", rs)
+	}
+	if byName["nd_proxy_seen"].Enabled {
+		t.Error("nd_proxy_seen still enabled")
+	}
+
+	m.refreshRules()
+	if len(m.rules) == 0 || m.rules[0].Name != "rs_storm" {
+		t.Errorf("rules tab = %+v, want the noisiest rule first", m.rules)
+	}
+}
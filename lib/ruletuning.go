@@ -0,0 +1,179 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func newRuleTable() table.Model {
+	columns := []table.Column{
+		{Title: "Rule", Width: 26},
+		{Title: "Pack", Width: 10},
+		{Title: "Severity", Width: 8},
+		{Title: "On", Width: 3},
+		{Title: "Now", Width: 4},
+		{Title: "Fired", Width: 6},
+		{Title: "Acked", Width: 6},
+		{Title: "Ignored", Width: 7},
+		{Title: "Ack %", Width: 5},
+		{Title: "Last", Width: 8},
+		{Title: "Match", Width: 40},
+	}
+
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(true)
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57")).
+		Bold(false)
+
+	return table.New(
+		table.WithColumns(columns),
+		table.WithFocused(false),
+		table.WithHeight(20),
+		table.WithStyles(s),
+	)
+}
+
+// sortRuleStatus puts the noisiest rules first: most alerts fired, then
+// the lowest acknowledgement rate, then by name.
+func sortRuleStatus(rules []RuleStatus) {
+	slices.SortStableFunc(rules, func(a, b RuleStatus) int {
+		if a.Fired != b.Fired {
+			return b.Fired - a.Fired
+		}
+		if ra, rb := a.AckRate(), b.AckRate(); ra != rb {
+			if ra < rb {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+// ruleRows converts rule statuses into table rows.
+func ruleRows(rules []RuleStatus) []table.Row {
+	rows := make([]table.Row, 0, len(rules))
+	for _, r := range rules {
+		on := "yes"
+		if !r.Enabled {
+			on = "no"
+		}
+		rate := "-"
+		if ack := r.AckRate(); ack >= 0 {
+			rate = fmt.Sprintf("%.0f", ack*100)
+		}
+		last := "-"
+		if !r.LastFired.IsZero() {
+			last = formatTimestamp(r.LastFired)
+		}
+		rows = append(rows, table.Row{
+			r.Name,
+			r.Pack,
+			r.Severity.String(),
+			on,
+			fmt.Sprintf("%d", r.Matching),
+			fmt.Sprintf("%d", r.Fired),
+			fmt.Sprintf("%d", r.Acked),
+			fmt.Sprintf("%d", r.Ignored),
+			rate,
+			last,
+			r.Match,
+		})
+	}
+	return rows
+}
+
+// refreshRules reloads the rule statuses from the engine.
+func (m *Model) refreshRules() {
+	if m.ruleEngine == nil {
+		return
+	}
+	m.rules = m.ruleEngine.Status()
+	sortRuleStatus(m.rules)
+	m.ruleTable.SetRows(ruleRows(m.rules))
+}
+
+// selectedRule returns the rule under the cursor on the Rules tab.
+func (m Model) selectedRule() (RuleStatus, bool) {
+	i := m.ruleTable.Cursor()
+	if i < 0 || i >= len(m.rules) {
+		return RuleStatus{}, false
+	}
+	return m.rules[i], true
+}
+
+// handleRuleKey handles the Rules tab's own keys: space toggles the rule
+// under the cursor and e edits its match expression. It reports whether the
+// key was one of them.
+func (m *Model) handleRuleKey(key string) (tea.Cmd, bool) {
+	r, ok := m.selectedRule()
+	if !ok {
+		return nil, false
+	}
+	switch key {
+	case " ":
+		m.ruleEngine.SetEnabled(r.Name, !r.Enabled)
+		m.refreshRules()
+		return nil, true
+	case "e":
+		m.editingRule = r.Name
+		m.ruleInput.SetValue(r.Match)
+		m.ruleInput.CursorEnd()
+		return m.ruleInput.Focus(), true
+	}
+	return nil, false
+}
+
+// handleRuleEditKey edits a rule's match expression. Enter applies it if it
+// compiles, Esc leaves the rule unchanged.
+func (m Model) handleRuleEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.editingRule = ""
+		m.ruleInput.Blur()
+		return m, nil
+	case "enter":
+		expr := strings.TrimSpace(m.ruleInput.Value())
+		if err := m.ruleEngine.SetMatch(m.editingRule, expr); err != nil {
+			m.setStatus(err.Error())
+			return m, nil
+		}
+		m.setStatus(fmt.Sprintf("Rule %s now matches %s (until exit; copy it to the pack file to keep it)", m.editingRule, expr))
+		m.editingRule = ""
+		m.ruleInput.Blur()
+		m.refreshRules()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.ruleInput, cmd = m.ruleInput.Update(msg)
+	return m, cmd
+}
+
+// renderRules renders the Rules tab.
+func (m Model) renderRules() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Detection Rules"))
+	b.WriteString("\n")
+	if m.ruleEngine == nil || len(m.rules) == 0 {
+		b.WriteString("No detection rules configured (rules section in the config file).\n")
+		return b.String()
+	}
+	b.WriteString(m.ruleTable.View())
+	b.WriteString("\n\n")
+	if r, ok := m.selectedRule(); ok && r.Description != "" {
+		b.WriteString(fmt.Sprintf("%s  %s\n", detailLabel.Render(r.Name+":"), r.Description))
+	}
+	b.WriteString("Noisiest first. Ack % is the share of toasts acknowledged with 'a' rather than left to expire.\n")
+	return b.String()
+}
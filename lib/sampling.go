@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// samplingPeriod is how often the capture loop's load is measured.
+	samplingPeriod = time.Second
+	// samplingHighLoad and samplingLowLoad are the shares of wall time
+	// spent handling messages above which sampling doubles, and below which
+	// it halves again.
+	samplingHighLoad = 0.8
+	samplingLowLoad  = 0.3
+)
+
+// SamplingStats describes adaptive sampling under overload (see
+// NDPListenerConfig.MaxSampling).
+type SamplingStats struct {
+	// Rate is 1 when every message is fully processed, N when only one in N
+	// is and the others are only counted.
+	Rate int `json:"rate"`
+	// Shed counts messages only counted since startup.
+	Shed uint64 `json:"shed"`
+	// Since is when Rate last changed.
+	Since time.Time `json:"since,omitempty"`
+}
+
+// sampler decides which messages a listener fully processes. It measures
+// the share of wall time the capture loop spends in handle over each
+// samplingPeriod: above samplingHighLoad the loop is about to fall behind the
+// socket, so the rate doubles, up to max; below samplingLowLoad it halves.
+// Not safe for concurrent use; each listener has its own.
+type sampler struct {
+	max   int
+	rate  int
+	seq   uint64
+	start time.Time     // of the current period
+	busy  time.Duration // spent handling messages in the current period
+}
+
+func newSampler(max int) *sampler {
+	return &sampler{max: max, rate: 1}
+}
+
+// admit reports whether the next message should be fully processed.
+func (s *sampler) admit() bool {
+	s.seq++
+	return s.rate == 1 || s.seq%uint64(s.rate) == 0
+}
+
+// observe adds a message that took took to handle at now, and returns the
+// new rate and the load behind it when the rate changes.
+func (s *sampler) observe(now time.Time, took time.Duration) (rate int, load float64, changed bool) {
+	if s.start.IsZero() {
+		s.start = now
+	}
+	s.busy += took
+	elapsed := now.Sub(s.start)
+	if elapsed < samplingPeriod {
+		return s.rate, 0, false
+	}
+	load = float64(s.busy) / float64(elapsed)
+	s.start, s.busy = now, 0
+
+	prev := s.rate
+	switch {
+	case load > samplingHighLoad && s.rate < s.max:
+		s.rate = min(s.rate*2, s.max)
+	case load < samplingLowLoad && s.rate > 1:
+		s.rate /= 2
+	}
+	return s.rate, load, s.rate != prev
+}
+
+// countOnly records a message that sampling skipped: its type is counted
+// against the sender, nothing else is parsed.
+func (l *NDPListener) countOnly(r received) {
+	kind := classifyICMPv6(ipv6.ICMPType(r.payload[0]))
+	if kind == "" {
+		return
+	}
+	link := l.ifName(r.ifIndex)
+	if link == "" {
+		link = r.src.Zone()
+	}
+	l.cfg.Stats.RecordMessage(l.peerAddr(r.src, link).String(), kind)
+	l.cfg.Stats.recordShed()
+}
+
+// ifName returns the name of interface ifIndex, "" if unknown, caching the
+// lookups for countOnly.
+func (l *NDPListener) ifName(ifIndex int) string {
+	if ifIndex == 0 {
+		return ""
+	}
+	if name, ok := l.ifNames[ifIndex]; ok {
+		return name
+	}
+	name := ""
+	if ifi, err := net.InterfaceByIndex(ifIndex); err == nil {
+		name = ifi.Name
+	}
+	if l.ifNames == nil {
+		l.ifNames = make(map[int]string)
+	}
+	l.ifNames[ifIndex] = name
+	return name
+}
+
+// sample runs handle for r under adaptive sampling.
+func (l *NDPListener) sample(r received) {
+	start := time.Now()
+	if l.sampler.admit() {
+		l.process(r)
+	} else {
+		l.countOnly(r)
+	}
+	now := time.Now()
+	rate, load, changed := l.sampler.observe(now, now.Sub(start))
+	if !changed {
+		return
+	}
+	l.cfg.Stats.setSamplingRate(rate, now)
+	if rate > 1 {
+		l.cfg.Logger.Warn("capture overloaded; sampling", "rate", rate, "load", load)
+	} else {
+		l.cfg.Logger.Info("capture load normal; sampling off", "load", load)
+	}
+}
+
+// recordShed counts a message that sampling only counted.
+func (s *NDPStats) recordShed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sampling.Shed++
+}
+
+// setSamplingRate records a sampling rate change at now.
+func (s *NDPStats) setSamplingRate(rate int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sampling.Rate = rate
+	s.sampling.Since = now
+}
+
+// Sampling returns the current sampling rate and how many messages were
+// only counted.
+func (s *NDPStats) Sampling() SamplingStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.samplingLocked()
+}
+
+func (s *NDPStats) samplingLocked() SamplingStats {
+	st := s.sampling
+	if st.Rate == 0 {
+		st.Rate = 1
+	}
+	return st
+}
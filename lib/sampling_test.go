@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	s := newSampler(4)
+	start := time.Now()
+
+	// Busy 90% of a second: sample 1 in 2, then 1 in 4, never beyond max.
+	for i, want := range []int{2, 4, 4} {
+		s.observe(start.Add(time.Duration(i)*time.Second), 0)
+		rate, load, _ := s.observe(start.Add(time.Duration(i+1)*time.Second), 900*time.Millisecond)
+		if rate != want || load < 0.85 {
+			t.Fatalf("period %d: rate %d load %.2f, want rate %d", i, rate, load, want)
+		}
+	}
+	admitted := 0
+	for i := 0; i < 8; i++ {
+		if s.admit() {
+			admitted++
+		}
+	}
+	if admitted != 2 {
+		t.Errorf("admitted %d of 8 at 1 in 4, want 2", admitted)
+	}
+
+	// Idle again: back down one step per period.
+	if rate, _, changed := s.observe(start.Add(5*time.Second), time.Millisecond); !changed || rate != 2 {
+		t.Errorf("after an idle period rate = %d (changed %v), want 2", rate, changed)
+	}
+}
+
+func TestHandle_SampledMessagesAreCounted(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	l := NewNDPListener(NDPListenerConfig{
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:       stats,
+		MaxSampling: 4,
+	})
+	l.sampler.rate = 4
+
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	for i := 0; i < 8; i++ {
+		l.handle(received{src: netip.MustParseAddr("fe80::1"), payload: buildNS(net.ParseIP("fe80::2"), mac)})
+	}
+
+	peers := stats.GetStats()
+	if len(peers) != 1 || peers[0].Counts["neighbor_solicitation"] != 8 {
+		t.Fatalf("peers = %+v, want fe80::1 with all 8 NSs counted", peers)
+	}
+	if got := stats.Sampling(); got.Shed != 6 {
+		t.Errorf("shed = %d, want 6", got.Shed)
+	}
+	if got := stats.GetSolicitedTargets(); len(got) != 1 || got[0].Solicitations != 2 {
+		t.Errorf("solicited targets = %+v, want 2 fully parsed NSs", got)
+	}
+}
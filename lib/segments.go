@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// segmentDataMsg carries the peers and routers fetched from an instance.
+type segmentDataMsg struct {
+	url     string
+	peers   []PeerSummary
+	routers []RouterInfo
+	err     error
+}
+
+// fetchSegment returns a command that loads the selected instance's peers
+// and routers.
+func (m Model) fetchSegment() tea.Cmd {
+	fed, base := m.federation, m.segment.URL
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), federationTimeout)
+		defer cancel()
+		peers, routers, err := fed.FetchSegment(ctx, base)
+		return segmentDataMsg{url: base, peers: peers, routers: routers, err: err}
+	}
+}
+
+// openSegments shows the segment picker: this instance first, then every
+// instance the federation knows.
+func (m *Model) openSegments() {
+	if m.federation == nil {
+		m.setStatus("No other instances configured (federation in the config)")
+		return
+	}
+	m.segments = m.federation.Instances()
+	m.segmentCursor = 0
+	if m.segment != nil {
+		if i := slices.IndexFunc(m.segments, func(in Instance) bool { return in.Name == m.segment.Name }); i >= 0 {
+			m.segmentCursor = i + 1
+		}
+	}
+	m.activeView = "segments"
+}
+
+// handleSegmentKey moves through the picker and switches to the segment
+// under the cursor on Enter.
+func (m Model) handleSegmentKey(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "up":
+		m.segmentCursor = max(m.segmentCursor-1, 0)
+	case "down":
+		m.segmentCursor = min(m.segmentCursor+1, len(m.segments))
+	case "esc":
+		m.activeView = "table"
+	case "q":
+		m.quitting = true
+		return m, tea.Quit
+	case "enter":
+		m.activeView = "table"
+		if m.segmentCursor == 0 {
+			if m.segment != nil {
+				m.segment = nil
+				m.loadLive()
+			}
+			return m, nil
+		}
+		in := m.segments[m.segmentCursor-1]
+		m.segment = &in
+		m.travelling, m.travelAt, m.timeline = false, time.Time{}, nil
+		m.setStatus("Loading " + in.Name + "...")
+		return m, m.fetchSegment()
+	}
+	return m, nil
+}
+
+// applySegmentData shows fetched peers and routers if they are still for
+// the selected segment.
+func (m *Model) applySegmentData(msg segmentDataMsg) {
+	if m.segment == nil || msg.url != m.segment.URL {
+		return
+	}
+	if msg.err != nil {
+		m.setStatus(m.segment.Name + ": " + msg.err.Error())
+		return
+	}
+	m.peers = msg.peers
+	m.setPeerRows()
+	m.routers = msg.routers
+	m.routerTable.SetRows(routerRows(m.routers, nil))
+}
+
+// renderSegments draws the segment picker.
+func (m Model) renderSegments() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Segments"))
+	b.WriteString("\n\n")
+	row := func(i int, text string) {
+		cursor := "  "
+		if i == m.segmentCursor {
+			cursor = "▶ "
+		}
+		b.WriteString(cursor + text + "\n")
+	}
+	local := "this instance"
+	if m.federation.Name() != "" {
+		local = m.federation.Name() + " (this instance)"
+	}
+	row(0, local)
+	for i, in := range m.segments {
+		state := "up"
+		if !in.Up {
+			state = "down"
+			if in.Error != "" {
+				state += ": " + in.Error
+			}
+			if in.Polled.IsZero() {
+				state = "not polled yet"
+			}
+		}
+		var health []string
+		for _, iface := range slices.Sorted(maps.Keys(in.Health)) {
+			health = append(health, segmentName(iface)+" "+healthScoreStyle(in.Health[iface]).Render(fmt.Sprint(in.Health[iface])))
+		}
+		text := fmt.Sprintf("%-20s %-32s %s", in.Name, in.URL, detailLabel.Render(state))
+		if in.Interface != "" {
+			text += "  " + in.Interface
+		}
+		if len(health) > 0 {
+			text += "  health " + strings.Join(health, " ")
+		}
+		if !in.Up {
+			text = staleStyle.Render(fmt.Sprintf("%-20s %-32s ", in.Name, in.URL)) + warnStyle.Render(state)
+		}
+		row(i+1, text)
+	}
+	return b.String()
+}
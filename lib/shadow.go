@@ -0,0 +1,194 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultShadowInterval is how often the shadow recorder writes a snapshot.
+const defaultShadowInterval = time.Minute
+
+// ShadowRecorderConfig configures a ShadowRecorder.
+type ShadowRecorderConfig struct {
+	Dir      string        // output directory, created if missing
+	Stats    *NDPStats     // required; supplies the snapshots
+	Logger   *slog.Logger  // required
+	Interval time.Duration // between snapshots (default 1m)
+	// Args is the command line, recorded in meta.json.
+	Args []string
+}
+
+// ShadowRecorder writes everything needed to reproduce a run into one
+// directory, for running two versions of NDPeekr side by side and diffing
+// what they saw:
+//
+//	meta.json        version, VCS revision, command line and start time
+//	packets.pcap     every captured packet, replayable with --read-pcap
+//	events.ndjson    every event and alert, as the NDJSON sink writes them
+//	snapshots/       a snapshot every Interval and one at exit
+//
+// The socket backend only sees the ICMPv6 message, so its packets are
+// written behind an IPv6 header rebuilt from the addresses and hop limit
+// that came with it. It is a Sink; feed it packets through
+// NDPListenerConfig.Shadow and call Run for the snapshots.
+type ShadowRecorder struct {
+	cfg    ShadowRecorderConfig
+	events *NDJSONSink
+
+	mu      sync.Mutex
+	pcap    *os.File
+	packets *bufio.Writer
+	closed  bool
+}
+
+// shadowMeta is the content of meta.json.
+type shadowMeta struct {
+	Build   BuildInfo `json:"build"`
+	Args    []string  `json:"args"`
+	Started time.Time `json:"started"`
+}
+
+// NewShadowRecorder creates the output directory and its files. Existing
+// files are overwritten, so each run should get its own directory.
+func NewShadowRecorder(cfg ShadowRecorderConfig) (*ShadowRecorder, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultShadowInterval
+	}
+	if err := os.MkdirAll(filepath.Join(cfg.Dir, "snapshots"), 0755); err != nil {
+		return nil, fmt.Errorf("shadow output: %w", err)
+	}
+
+	meta := shadowMeta{Build: ReadBuildInfo(), Args: cfg.Args, Started: time.Now().UTC()}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("shadow output: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(cfg.Dir, "meta.json"), data); err != nil {
+		return nil, fmt.Errorf("shadow output: %w", err)
+	}
+
+	eventsPath := filepath.Join(cfg.Dir, "events.ndjson")
+	if err := os.Truncate(eventsPath, 0); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("shadow output: %w", err)
+	}
+	events, err := NewNDJSONSink(eventsPath)
+	if err != nil {
+		return nil, fmt.Errorf("shadow output: %w", err)
+	}
+	f, err := os.Create(filepath.Join(cfg.Dir, "packets.pcap"))
+	if err != nil {
+		events.Close()
+		return nil, fmt.Errorf("shadow output: %w", err)
+	}
+	r := &ShadowRecorder{cfg: cfg, events: events, pcap: f, packets: bufio.NewWriter(f)}
+	if err := writePcapHeader(r.packets, linkTypeRaw); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("shadow output: %w", err)
+	}
+	return r, nil
+}
+
+// AddPacket records one raw IPv6 packet.
+func (r *ShadowRecorder) AddPacket(now time.Time, pkt []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	if err := writePcapRecord(r.packets, now, pkt); err != nil {
+		r.cfg.Logger.Warn("shadow packet write failed", "err", err)
+	}
+}
+
+// addMessage records an ICMPv6 message from the socket backend behind a
+// rebuilt IPv6 header.
+func (r *ShadowRecorder) addMessage(now time.Time, m received) {
+	r.AddPacket(now, rebuildIPv6(m))
+}
+
+// rebuildIPv6 puts an IPv6 header in front of m's payload. An unknown
+// destination is written as ::, an unknown hop limit as 255.
+func rebuildIPv6(m received) []byte {
+	pkt := make([]byte, 40+len(m.payload))
+	pkt[0] = 0x60
+	binary.BigEndian.PutUint16(pkt[4:6], uint16(len(m.payload)))
+	pkt[6] = 58 // ICMPv6
+	pkt[7] = 255
+	if m.hopLimit > 0 {
+		pkt[7] = byte(m.hopLimit)
+	}
+	src := m.src.As16()
+	copy(pkt[8:24], src[:])
+	if m.dst.IsValid() {
+		dst := m.dst.As16()
+		copy(pkt[24:40], dst[:])
+	}
+	copy(pkt[40:], m.payload)
+	return pkt
+}
+
+// WriteEvent records ev in events.ndjson.
+func (r *ShadowRecorder) WriteEvent(ev Event) error {
+	return r.events.WriteEvent(ev)
+}
+
+// WriteAlert records a in events.ndjson.
+func (r *ShadowRecorder) WriteAlert(a Alert) error {
+	return r.events.WriteAlert(a)
+}
+
+// Run writes a snapshot every interval until ctx is cancelled, then a final
+// one so the state at shutdown is kept. Packets are flushed with each.
+func (r *ShadowRecorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.record()
+			return
+		case <-ticker.C:
+			r.record()
+		}
+	}
+}
+
+func (r *ShadowRecorder) record() {
+	if _, err := WriteSnapshotFile(*r.cfg.Stats.Shared(), filepath.Join(r.cfg.Dir, "snapshots"), "", nil); err != nil {
+		r.cfg.Logger.Warn("shadow snapshot failed", "err", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		if err := r.packets.Flush(); err != nil {
+			r.cfg.Logger.Warn("shadow packet write failed", "err", err)
+		}
+	}
+}
+
+// Close flushes and closes the packet and event files.
+func (r *ShadowRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.packets.Flush()
+	if cerr := r.pcap.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := r.events.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
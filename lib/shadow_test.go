@@ -0,0 +1,81 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRebuildIPv6(t *testing.T) {
+	pkt := buildIPv6Packet("fe80::1", "ff02::2", 255, nil, nhICMPv6, buildRS(nil))
+	r := received{
+		src:      netip.MustParseAddr("fe80::1%eth0"),
+		dst:      netip.MustParseAddr("ff02::2"),
+		hopLimit: 255,
+		payload:  pkt[40:],
+	}
+	if got := rebuildIPv6(r); !bytes.Equal(got, pkt) {
+		t.Errorf("rebuilt packet\n%x\nwant\n%x", got, pkt)
+	}
+}
+
+func TestShadowRecorder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shadow")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	stats := NewNDPStats(5 * time.Minute)
+	shadow, err := NewShadowRecorder(ShadowRecorderConfig{Dir: dir, Stats: stats, Logger: logger, Args: []string{"ndpeekr", "--shadow-output", dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewNDPListener(NDPListenerConfig{Logger: logger, Stats: stats, Sinks: []Sink{shadow}, Shadow: shadow})
+
+	// One message from the socket backend, one whole packet.
+	rs := buildIPv6Packet("fe80::1", "ff02::2", 255, nil, nhICMPv6, buildRS(nil))
+	r := received{src: netip.MustParseAddr("fe80::1"), dst: netip.MustParseAddr("ff02::2"), hopLimit: 255, payload: rs[40:]}
+	shadow.addMessage(time.Now(), r)
+	l.handle(r)
+	l.handlePacket(buildIPv6Packet("fe80::2", "ff02::1", 255, nil, nhICMPv6, buildNA(net.ParseIP("fe80::2"), nil)), 0, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	shadow.Run(ctx)
+	if err := shadow.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var meta shadowMeta
+	if data, err := os.ReadFile(filepath.Join(dir, "meta.json")); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(data, &meta); err != nil || len(meta.Args) != 3 {
+		t.Errorf("meta.json = %s (%v)", data, err)
+	}
+	events, err := os.ReadFile(filepath.Join(dir, "events.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(events), "\n"); n != 2 {
+		t.Errorf("events.ndjson has %d lines, want 2:\n%s", n, events)
+	}
+	if snaps, _ := filepath.Glob(filepath.Join(dir, "snapshots", "ndpeekr-snapshot-*.json")); len(snaps) != 1 {
+		t.Errorf("snapshots = %v, want the one written at exit", snaps)
+	}
+
+	// The recorded packets replay to the same peers.
+	replayed := NewNDPStats(5 * time.Minute)
+	rl := NewNDPListener(NDPListenerConfig{Logger: logger, Stats: replayed, Capture: CapturePcap, PcapFile: filepath.Join(dir, "packets.pcap")})
+	if err := rl.Run(context.Background()); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if peers := replayed.GetStats(); len(peers) != 2 {
+		t.Errorf("replayed peers = %+v, want fe80::1 and fe80::2", peers)
+	}
+}
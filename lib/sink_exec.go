@@ -0,0 +1,168 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	// defaultExecTimeout bounds each run of the exec sink's command.
+	defaultExecTimeout = 30 * time.Second
+	// defaultExecPerMinute is the exec sink's rate limit when unset.
+	defaultExecPerMinute = 6
+)
+
+// ExecSink runs an external command for each alert, with the alert's fields
+// in templated arguments and NDPEEKR_ALERT_* environment variables. Commands
+// run in the background, so a slow script never stalls capture, and are
+// rate limited so an alert storm doesn't fork hundreds of processes.
+type ExecSink struct {
+	cfg    ExecSinkConfig
+	args   []*template.Template
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	dropped int // alerts skipped by the rate limit since the last run
+	wg      sync.WaitGroup
+}
+
+// NewExecSink validates cfg and compiles its argument templates.
+func NewExecSink(cfg ExecSinkConfig, logger *slog.Logger) (*ExecSink, error) {
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("exec sink: command is required")
+	}
+	args, err := parseExecArgs(cfg.Args)
+	if err != nil {
+		return nil, fmt.Errorf("exec sink: %w", err)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultExecTimeout
+	}
+	if cfg.PerMinute <= 0 {
+		cfg.PerMinute = defaultExecPerMinute
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &ExecSink{cfg: cfg, args: args, logger: logger, tokens: float64(cfg.PerMinute)}, nil
+}
+
+// parseExecArgs compiles each argument as a text/template over Alert.
+func parseExecArgs(args []string) ([]*template.Template, error) {
+	tmpls := make([]*template.Template, len(args))
+	for i, arg := range args {
+		t, err := template.New(fmt.Sprintf("args[%d]", i)).Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+		tmpls[i] = t
+	}
+	return tmpls, nil
+}
+
+// WriteEvent ignores events; only alerts run the command.
+func (s *ExecSink) WriteEvent(ev Event) error {
+	return nil
+}
+
+// WriteAlert starts the command for a if it passes the severity and category
+// filters and the rate limit. Errors from the command itself are logged.
+func (s *ExecSink) WriteAlert(a Alert) error {
+	if a.Severity < s.cfg.MinSeverity {
+		return nil
+	}
+	if len(s.cfg.Categories) > 0 && !slices.Contains(s.cfg.Categories, a.Category) {
+		return nil
+	}
+
+	args := make([]string, len(s.args))
+	for i, t := range s.args {
+		var b bytes.Buffer
+		if err := t.Execute(&b, a); err != nil {
+			return fmt.Errorf("exec sink: %w", err)
+		}
+		args[i] = b.String()
+	}
+	env, err := alertEnv(a)
+	if err != nil {
+		return fmt.Errorf("exec sink: %w", err)
+	}
+
+	dropped, ok := s.take(time.Now())
+	if !ok {
+		return nil
+	}
+	if dropped > 0 {
+		s.logger.Warn("exec sink rate limited alerts", "dropped", dropped)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, s.cfg.Command, args...)
+		cmd.Env = append(os.Environ(), env...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			s.logger.Warn("exec sink command failed", "command", s.cfg.Command, "category", a.Category, "err", err, "output", string(out))
+			return
+		}
+		s.logger.Debug("exec sink command ran", "command", s.cfg.Command, "category", a.Category)
+	}()
+	return nil
+}
+
+// take spends a token from the per-minute budget. It reports how many alerts
+// were dropped since the last successful take, and whether this one may run.
+func (s *ExecSink) take(now time.Time) (dropped int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.last.IsZero() {
+		s.tokens = min(s.tokens+now.Sub(s.last).Minutes()*float64(s.cfg.PerMinute), float64(s.cfg.PerMinute))
+	}
+	s.last = now
+	if s.tokens < 1 {
+		s.dropped++
+		return 0, false
+	}
+	s.tokens--
+	dropped, s.dropped = s.dropped, 0
+	return dropped, true
+}
+
+// Close waits for running commands to finish or time out.
+func (s *ExecSink) Close() error {
+	s.wg.Wait()
+	return nil
+}
+
+// alertEnv passes the alert's fields to the command as environment
+// variables, plus the whole alert as JSON.
+func alertEnv(a Alert) ([]string, error) {
+	js, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"NDPEEKR_ALERT_TIME=" + a.Time.Format(time.RFC3339Nano),
+		"NDPEEKR_ALERT_SEVERITY=" + a.Severity.String(),
+		"NDPEEKR_ALERT_CATEGORY=" + a.Category,
+		"NDPEEKR_ALERT_SOURCE=" + a.Source,
+		"NDPEEKR_ALERT_MESSAGE=" + a.Message,
+		"NDPEEKR_ALERT_PORT=" + a.Port,
+		"NDPEEKR_ALERT_JSON=" + string(js),
+	}, nil
+}
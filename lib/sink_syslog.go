@@ -0,0 +1,73 @@
+//go:build !windows && !plan9
+
+package lib
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// SyslogSink forwards events at INFO and alerts at WARNING/CRIT priority.
+type SyslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink connects to the local syslog daemon, or to a remote one when
+// cfg.Network and cfg.Address are set.
+func NewSyslogSink(cfg SyslogSinkConfig) (*SyslogSink, error) {
+	tag := cfg.Tag
+	if tag == "" {
+		tag = "ndpeekr"
+	}
+	w, err := syslog.Dial(cfg.Network, cfg.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, fmt.Errorf("open syslog sink: %w", err)
+	}
+	return &SyslogSink{w: w}, nil
+}
+
+func (s *SyslogSink) WriteEvent(ev Event) error {
+	return s.w.Info(fmt.Sprintf("ndp event kind=%s src=%s dst=%s iface=%s len=%d mac=%s",
+		ev.Kind, ev.Src, ev.Dst, ev.Interface, ev.Length, ev.MAC) + syslogPeerFields(ev.Peer))
+}
+
+func (s *SyslogSink) WriteAlert(a Alert) error {
+	line := fmt.Sprintf("ndp alert severity=%s category=%s src=%s msg=%q",
+		a.Severity, a.Category, a.Source, a.Message) + syslogPeerFields(a.Peer)
+	switch a.Severity {
+	case SeverityCritical:
+		return s.w.Crit(line)
+	case SeverityWarning:
+		return s.w.Warning(line)
+	default:
+		return s.w.Notice(line)
+	}
+}
+
+// syslogPeerFields formats the known enrichment fields as key=value pairs.
+func syslogPeerFields(e *Enrichment) string {
+	if e == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, f := range [][2]string{{"name", e.Name}, {"type", e.DeviceType}, {"hostname", e.Hostname}, {"vendor", e.Vendor}} {
+		if f[1] != "" {
+			fmt.Fprintf(&b, " %s=%q", f[0], f[1])
+		}
+	}
+	if e.Trusted {
+		b.WriteString(" trusted=true")
+	}
+	if len(e.Tags) > 0 {
+		fmt.Fprintf(&b, " tags=%s", strings.Join(e.Tags, ","))
+	}
+	if worst := WorstReputation(e.Reputation); worst != "" {
+		fmt.Fprintf(&b, " reputation=%s", worst)
+	}
+	return b.String()
+}
+
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
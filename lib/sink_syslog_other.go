@@ -0,0 +1,17 @@
+//go:build windows || plan9
+
+package lib
+
+import "errors"
+
+// SyslogSink is unavailable on this platform.
+type SyslogSink struct{}
+
+// NewSyslogSink always fails on platforms without log/syslog.
+func NewSyslogSink(cfg SyslogSinkConfig) (*SyslogSink, error) {
+	return nil, errors.New("syslog sink is not supported on this platform")
+}
+
+func (s *SyslogSink) WriteEvent(ev Event) error { return nil }
+func (s *SyslogSink) WriteAlert(a Alert) error  { return nil }
+func (s *SyslogSink) Close() error              { return nil }
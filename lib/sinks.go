@@ -0,0 +1,301 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultSinkQueue is how many events and alerts a queued sink may fall
+	// behind by before new ones are dropped.
+	defaultSinkQueue = 4096
+	// sinkDrainTimeout bounds how long closing a queued sink waits for it
+	// to write out its queue.
+	sinkDrainTimeout = 5 * time.Second
+)
+
+// Sink is a headless output that receives every event and alert.
+// Sinks run alongside the TUI; implementations must be safe for concurrent use.
+type Sink interface {
+	WriteEvent(ev Event) error
+	WriteAlert(a Alert) error
+	Close() error
+}
+
+// OpenSinks constructs the event sinks enabled in cfg. The Prometheus sink is
+// pull-based and started separately with ServePrometheus. Sinks that work in
+// the background log to logger.
+func OpenSinks(cfg SinksConfig, logger *slog.Logger) ([]Sink, error) {
+	var sinks []Sink
+
+	if cfg.NDJSON != nil {
+		s, err := NewNDJSONSink(cfg.NDJSON.Path)
+		if err != nil {
+			CloseSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.Syslog != nil {
+		s, err := NewSyslogSink(*cfg.Syslog)
+		if err != nil {
+			CloseSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.Exec != nil {
+		s, err := NewExecSink(*cfg.Exec, logger)
+		if err != nil {
+			CloseSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+
+	return sinks, nil
+}
+
+// CloseSinks closes every sink and returns the combined errors.
+func CloseSinks(sinks []Sink) error {
+	var errs []error
+	for _, s := range sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NodeSinks wraps sinks so every event and alert they receive carries node
+// (Event.Node, Alert.Node). With node empty it returns sinks as they are.
+func NodeSinks(sinks []Sink, node string) []Sink {
+	if node == "" {
+		return sinks
+	}
+	out := make([]Sink, len(sinks))
+	for i, s := range sinks {
+		out[i] = nodeSink{Sink: s, node: node}
+	}
+	return out
+}
+
+type nodeSink struct {
+	Sink
+	node string
+}
+
+func (s nodeSink) WriteEvent(ev Event) error {
+	ev.Node = s.node
+	return s.Sink.WriteEvent(ev)
+}
+
+func (s nodeSink) WriteAlert(a Alert) error {
+	a.Node = s.node
+	return s.Sink.WriteAlert(a)
+}
+
+// QueueSinks puts each sink behind a queue of size records (default 4096)
+// drained by a goroutine of its own, so a slow sink, such as a syslog
+// collector over TCP that stopped reading, never stalls capture. Records
+// that find the queue full are dropped and counted; the count is logged to
+// logger once the sink catches up. Closing a queued sink writes out what
+// is queued, then closes the sink; a sink stuck in a write is given up on
+// after 5s and left open.
+func QueueSinks(sinks []Sink, size int, logger *slog.Logger) []Sink {
+	if size <= 0 {
+		size = defaultSinkQueue
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	out := make([]Sink, len(sinks))
+	for i, s := range sinks {
+		q := &queuedSink{sink: s, name: sinkName(s), logger: logger, queue: make(chan sinkRecord, size), done: make(chan struct{})}
+		go q.run()
+		out[i] = q
+	}
+	return out
+}
+
+// sinkRecord is an event or an alert waiting for a queued sink.
+type sinkRecord struct {
+	event *Event
+	alert *Alert
+}
+
+type queuedSink struct {
+	sink   Sink
+	name   string
+	logger *slog.Logger
+	queue  chan sinkRecord
+	done   chan struct{}
+	// dropped counts the records dropped since the last time it was logged.
+	dropped atomic.Int64
+
+	mu     sync.RWMutex // write lock to close queue
+	closed bool
+}
+
+func (s *queuedSink) WriteEvent(ev Event) error {
+	return s.enqueue(sinkRecord{event: &ev})
+}
+
+func (s *queuedSink) WriteAlert(a Alert) error {
+	return s.enqueue(sinkRecord{alert: &a})
+}
+
+// enqueue queues rec without blocking, dropping it if the queue is full.
+func (s *queuedSink) enqueue(rec sinkRecord) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return fmt.Errorf("%s sink is closed", s.name)
+	}
+	select {
+	case s.queue <- rec:
+	default:
+		s.dropped.Add(1)
+	}
+	return nil
+}
+
+// run writes the queued records to the sink until the queue is closed.
+func (s *queuedSink) run() {
+	defer close(s.done)
+	for rec := range s.queue {
+		var err error
+		if rec.event != nil {
+			err = s.sink.WriteEvent(*rec.event)
+		} else {
+			err = s.sink.WriteAlert(*rec.alert)
+		}
+		if err != nil {
+			s.logger.Debug("sink write failed", "sink", s.name, "err", err)
+		}
+		if n := s.dropped.Swap(0); n > 0 {
+			s.logger.Warn("sink fell behind, records dropped", "sink", s.name, "dropped", n)
+		}
+	}
+}
+
+func (s *queuedSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	case <-time.After(sinkDrainTimeout):
+		return fmt.Errorf("%s sink: %d records still queued after %s", s.name, len(s.queue), sinkDrainTimeout)
+	}
+	if n := s.dropped.Swap(0); n > 0 {
+		s.logger.Warn("sink fell behind, records dropped", "sink", s.name, "dropped", n)
+	}
+	return s.sink.Close()
+}
+
+// sinkName names the sink inside the privacy and node wrappers, for logs.
+func sinkName(s Sink) string {
+	for {
+		switch w := s.(type) {
+		case privateSink:
+			s = w.Sink
+		case nodeSink:
+			s = w.Sink
+		default:
+			name := strings.TrimPrefix(fmt.Sprintf("%T", s), "*lib.")
+			return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(name, "Sink"), "Recorder"))
+		}
+	}
+}
+
+// NDJSONSink appends newline-delimited JSON records to a file. Each line is
+// either {"event": {...}} or {"alert": {...}}.
+type NDJSONSink struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+type ndjsonRecord struct {
+	Event *Event `json:"event,omitempty"`
+	Alert *Alert `json:"alert,omitempty"`
+}
+
+// NewNDJSONSink opens (or creates) path for appending.
+func NewNDJSONSink(path string) (*NDJSONSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open ndjson sink: %w", err)
+	}
+	return &NDJSONSink{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (s *NDJSONSink) WriteEvent(ev Event) error {
+	return s.write(ndjsonRecord{Event: &ev})
+}
+
+func (s *NDJSONSink) WriteAlert(a Alert) error {
+	return s.write(ndjsonRecord{Alert: &a})
+}
+
+func (s *NDJSONSink) write(rec ndjsonRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(rec)
+}
+
+func (s *NDJSONSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// JSONLSink writes each event as one bare JSON object per line, for piping
+// into jq, Vector or a SIEM (--output jsonl). Unlike NDJSONSink it doesn't
+// wrap records or carry alerts, so every line has the same shape.
+type JSONLSink struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// NewJSONLSink opens (or creates) path for appending; "-" is stdout.
+func NewJSONLSink(path string) (*JSONLSink, error) {
+	if path == "-" {
+		return &JSONLSink{enc: json.NewEncoder(os.Stdout)}, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open jsonl output: %w", err)
+	}
+	return &JSONLSink{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (s *JSONLSink) WriteEvent(ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(ev)
+}
+
+func (s *JSONLSink) WriteAlert(Alert) error { return nil }
+
+// Close closes the output file; stdout is left open.
+func (s *JSONLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
@@ -0,0 +1,215 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNDJSONSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sinks, err := OpenSinks(SinksConfig{NDJSON: &NDJSONSinkConfig{Path: path}}, nil)
+	if err != nil {
+		t.Fatalf("OpenSinks: %v", err)
+	}
+	if len(sinks) != 1 {
+		t.Fatalf("got %d sinks, want 1", len(sinks))
+	}
+
+	s := sinks[0]
+	if err := s.WriteEvent(Event{Time: time.Now(), Kind: "router_solicitation", Src: "fe80::1", Length: 16}); err != nil {
+		t.Fatalf("WriteEvent: %v", err)
+	}
+	if err := s.WriteAlert(Alert{Severity: SeverityWarning, Category: "oversized_packet", Source: "fe80::1"}); err != nil {
+		t.Fatalf("WriteAlert: %v", err)
+	}
+	if err := CloseSinks(sinks); err != nil {
+		t.Fatalf("CloseSinks: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+
+	var rec ndjsonRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil || rec.Event == nil || rec.Event.Kind != "router_solicitation" {
+		t.Errorf("line 1 = %s, want router_solicitation event", lines[0])
+	}
+	if !strings.Contains(lines[1], `"severity":"warning"`) {
+		t.Errorf("line 2 = %s, want alert with named severity", lines[1])
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	s, err := NewJSONLSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewNDPListener(NDPListenerConfig{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Stats:  NewNDPStats(5 * time.Minute),
+		Sinks:  []Sink{s},
+	})
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	pio := buildPrefixInfoOption(net.ParseIP("2001:db8::"), 64, true, true, 86400, 14400)
+	l.handle(received{src: netip.MustParseAddr("fe80::1"), hopLimit: 255, payload: buildRAFull(64, false, false, 1800, mac, pio)})
+	_ = s.WriteAlert(Alert{Category: "test"})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want the event only:\n%s", len(lines), data)
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(lines[0]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Kind != "router_advertisement" || ev.HopLimit != 255 || !slices.Equal(ev.Options, []string{"slla", "prefix"}) ||
+		ev.Router == nil || len(ev.Router.Prefixes) != 1 {
+		t.Errorf("event = %s", lines[0])
+	}
+}
+
+// recordingSink keeps what it is sent.
+type recordingSink struct {
+	events []Event
+	alerts []Alert
+}
+
+func (s *recordingSink) WriteEvent(ev Event) error { s.events = append(s.events, ev); return nil }
+func (s *recordingSink) WriteAlert(a Alert) error  { s.alerts = append(s.alerts, a); return nil }
+func (s *recordingSink) Close() error              { return nil }
+
+func TestNodeSinks(t *testing.T) {
+	rec := &recordingSink{}
+	if got := NodeSinks([]Sink{rec}, ""); got[0] != Sink(rec) {
+		t.Error("NodeSinks without a node wrapped the sink")
+	}
+	sinks := NodeSinks([]Sink{rec}, "worker-1")
+	_ = sinks[0].WriteEvent(Event{Kind: "router_solicitation"})
+	_ = sinks[0].WriteAlert(Alert{Category: "rogue_ra"})
+	if len(rec.events) != 1 || rec.events[0].Node != "worker-1" || len(rec.alerts) != 1 || rec.alerts[0].Node != "worker-1" {
+		t.Errorf("events %+v, alerts %+v: want both labeled worker-1", rec.events, rec.alerts)
+	}
+}
+
+// stalledSink blocks every write until release is closed.
+type stalledSink struct {
+	recordingSink
+	release chan struct{}
+}
+
+func (s *stalledSink) WriteEvent(ev Event) error {
+	<-s.release
+	return s.recordingSink.WriteEvent(ev)
+}
+
+func TestQueueSinks(t *testing.T) {
+	var log strings.Builder
+	stalled := &stalledSink{release: make(chan struct{})}
+	sinks := QueueSinks([]Sink{stalled}, 2, slog.New(slog.NewTextHandler(&log, nil)))
+
+	done := make(chan struct{})
+	go func() {
+		for range 10 {
+			sinks[0].WriteEvent(Event{Kind: "neighbor_solicitation"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writes blocked on a stalled sink")
+	}
+
+	close(stalled.release)
+	if err := CloseSinks(sinks); err != nil {
+		t.Fatal(err)
+	}
+	// One write in progress and two queued; the rest were dropped.
+	if n := len(stalled.events); n < 2 || n > 3 {
+		t.Errorf("%d events written, want the 2 or 3 that fit", n)
+	}
+	if !strings.Contains(log.String(), "records dropped") || !strings.Contains(log.String(), "sink=stalled") {
+		t.Errorf("log = %q, want the drops reported", log.String())
+	}
+	if err := sinks[0].WriteEvent(Event{}); err == nil {
+		t.Error("write after Close accepted")
+	}
+}
+
+func TestExecSink(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("needs /bin/sh")
+	}
+	dir := t.TempDir()
+	path := writeTempConfig(t, `
+sinks:
+  exec:
+    command: /bin/sh
+    args: ["-c", 'echo "$1 $2 $NDPEEKR_ALERT_CATEGORY port=$NDPEEKR_ALERT_PORT" >> `+filepath.Join(dir, "out")+`', "sh", "{{.Severity}}", "{{.Source}}"]
+    min_severity: warning
+    per_minute: 2
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	sinks, err := OpenSinks(cfg.Sinks, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("OpenSinks: %v", err)
+	}
+
+	s := sinks[0]
+	for _, a := range []Alert{
+		{Severity: SeverityInfo, Category: "router_reachable", Source: "fe80::1"}, // below min_severity
+		{Severity: SeverityCritical, Category: "rogue_ra", Source: "fe80::2", Port: "eth1"},
+		{Severity: SeverityWarning, Category: "oversized_ra", Source: "fe80::3"},
+		{Severity: SeverityWarning, Category: "oversized_ra", Source: "fe80::4"}, // rate limited
+	} {
+		if err := s.WriteAlert(a); err != nil {
+			t.Fatalf("WriteAlert: %v", err)
+		}
+	}
+	if err := CloseSinks(sinks); err != nil {
+		t.Fatalf("CloseSinks: %v", err)
+	}
+
+	out, err := os.ReadFile(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	slices.Sort(lines) // the commands run concurrently
+	want := []string{"critical fe80::2 rogue_ra port=eth1", "warning fe80::3 oversized_ra port="}
+	if !slices.Equal(lines, want) {
+		t.Errorf("command ran with %q, want %q", lines, want)
+	}
+}
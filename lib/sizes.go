@@ -0,0 +1,176 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+)
+
+// sizeBucketBounds are the exclusive upper bounds (in bytes) of the message size
+// histogram buckets. A final overflow bucket collects everything larger.
+var sizeBucketBounds = []int{64, 128, 256, 512, 1024, 1280}
+
+// sizeBucketLabels are column headers matching sizeBucketBounds plus the overflow bucket.
+var sizeBucketLabels = []string{"<64", "<128", "<256", "<512", "<1024", "<1280", "1280+"}
+
+// oversizedThresholds is the ICMPv6 payload size above which a message of the
+// given kind is considered anomalous. Normal RS/NS/NA traffic carries at most
+// a link-layer address and nonce option; RA, Redirect and MLD messages are
+// flagged once they no longer fit an IPv6 minimum-MTU packet (1280 - 40 byte
+// IPv6 header), since such packets must be fragmented and RFC 6980 forbids
+// fragmented ND.
+var oversizedThresholds = map[string]int{
+	"router_solicitation":            64,
+	"router_advertisement":           1232,
+	"neighbor_solicitation":          128,
+	"neighbor_advertisement":         128,
+	"redirect":                       1232,
+	"duplicate_address_request":      128,
+	"duplicate_address_confirmation": 128,
+	"mld_query":                      1232,
+	"mld_report":                     1232,
+	"mld_done":                       64,
+}
+
+// SizeHistogram is a bucketed distribution of ICMPv6 payload sizes for one message type.
+// Counts are cumulative since startup and are not subject to the sliding window.
+type SizeHistogram struct {
+	Buckets   []int `json:"buckets"`   // len(sizeBucketLabels) counters
+	Count     int   `json:"count"`     // total messages recorded
+	Sum       int   `json:"sum"`       // total bytes recorded
+	Max       int   `json:"max"`       // largest message seen
+	Oversized int   `json:"oversized"` // messages above the oversized threshold
+}
+
+func newSizeHistogram() *SizeHistogram {
+	return &SizeHistogram{Buckets: make([]int, len(sizeBucketLabels))}
+}
+
+func (h *SizeHistogram) add(n int, oversized bool) {
+	i := 0
+	for i < len(sizeBucketBounds) && n >= sizeBucketBounds[i] {
+		i++
+	}
+	h.Buckets[i]++
+	h.Count++
+	h.Sum += n
+	if n > h.Max {
+		h.Max = n
+	}
+	if oversized {
+		h.Oversized++
+	}
+}
+
+// isOversized reports whether an n-byte message of the given kind exceeds its threshold.
+func isOversized(ndpKind string, n int) bool {
+	limit, ok := oversizedThresholds[ndpKind]
+	return ok && n > limit
+}
+
+// byteSample is the size of one message, for counting a peer's bytes within
+// the window.
+type byteSample struct {
+	at time.Time
+	n  int
+}
+
+// bytesSince sums the samples after cutoff.
+func bytesSince(samples []byteSample, cutoff time.Time) int {
+	total := 0
+	for _, b := range samples {
+		if b.at.After(cutoff) {
+			total += b.n
+		}
+	}
+	return total
+}
+
+// pruneByteSamples returns the samples after cutoff.
+func pruneByteSamples(samples []byteSample, cutoff time.Time) []byteSample {
+	kept := make([]byteSample, 0, len(samples))
+	for _, b := range samples {
+		if b.at.After(cutoff) {
+			kept = append(kept, b)
+		}
+	}
+	return kept
+}
+
+// RecordSize adds an n-byte message from ip to the per-type size histogram
+// and to the peer's byte count. It returns true if this is the first oversized message of this kind from
+// the peer, so callers can raise a single alert instead of one per packet.
+func (s *NDPStats) RecordSize(ip string, ndpKind string, n int) bool {
+	oversized := isOversized(ndpKind, n)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.sizes[ndpKind]
+	if !ok {
+		h = newSizeHistogram()
+		s.sizes[ndpKind] = h
+	}
+	h.add(n, oversized)
+
+	now := s.now()
+	peer := s.getOrCreatePeer(ip, now)
+	peer.Bytes[ndpKind] = append(peer.Bytes[ndpKind], byteSample{at: now, n: n})
+	if !oversized {
+		return false
+	}
+	peer.Oversized[ndpKind]++
+	return peer.Oversized[ndpKind] == 1
+}
+
+// GetSizeHistograms returns a copy of the per-type size histograms keyed by ndpKind.
+func (s *NDPStats) GetSizeHistograms() map[string]SizeHistogram {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sizesLocked()
+}
+
+// sizesLocked deep-copies the size histograms. Callers must hold s.mu.
+func (s *NDPStats) sizesLocked() map[string]SizeHistogram {
+	result := make(map[string]SizeHistogram, len(s.sizes))
+	for kind, h := range s.sizes {
+		copied := *h
+		copied.Buckets = append([]int(nil), h.Buckets...)
+		result[kind] = copied
+	}
+	return result
+}
+
+// ipv6HeaderLen is the size of the fixed IPv6 header preceding the ICMPv6 payload.
+const ipv6HeaderLen = 40
+
+// raSizeAlerts checks an n-byte Router Advertisement against the MTU of the
+// link it arrived on and against the MTU the router itself advertises.
+//
+// The ICMPv6 socket only delivers reassembled datagrams, so fragmentation is
+// inferred: an RA whose IPv6 packet is larger than the link MTU cannot have
+// arrived unfragmented. RFC 6980 forbids fragmented ND, and fragmentation is
+// a known technique for slipping RAs past RA guard. linkMTU or advertisedMTU
+// may be 0 when unknown, in which case that check is skipped.
+func raSizeAlerts(src string, n, linkMTU int, advertisedMTU uint32) []Alert {
+	var alerts []Alert
+	pktLen := n + ipv6HeaderLen
+
+	if linkMTU > 0 && pktLen > linkMTU {
+		alerts = append(alerts, Alert{
+			Severity: SeverityCritical,
+			Category: "fragmented_ra",
+			Source:   src,
+			Message:  fmt.Sprintf("RA of %d bytes exceeds link MTU %d and must have been fragmented (RFC 6980)", pktLen, linkMTU),
+		})
+	}
+	if advertisedMTU > 0 && pktLen > int(advertisedMTU) {
+		alerts = append(alerts, Alert{
+			Severity: SeverityWarning,
+			Category: "oversized_ra",
+			Source:   src,
+			Message:  fmt.Sprintf("RA of %d bytes exceeds the %d-byte MTU it advertises", pktLen, advertisedMTU),
+		})
+	}
+	return alerts
+}
@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordSize_Buckets(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+
+	stats.RecordSize("fe80::1", "router_advertisement", 40)
+	stats.RecordSize("fe80::1", "router_advertisement", 64)
+	stats.RecordSize("fe80::1", "router_advertisement", 1300)
+
+	h, ok := stats.GetSizeHistograms()["router_advertisement"]
+	if !ok {
+		t.Fatal("no histogram for router_advertisement")
+	}
+	if h.Count != 3 {
+		t.Errorf("Count = %d, want 3", h.Count)
+	}
+	if h.Buckets[0] != 1 || h.Buckets[1] != 1 || h.Buckets[len(h.Buckets)-1] != 1 {
+		t.Errorf("Buckets = %v, want one each in <64, <128 and 1280+", h.Buckets)
+	}
+	if h.Max != 1300 {
+		t.Errorf("Max = %d, want 1300", h.Max)
+	}
+	if h.Oversized != 1 {
+		t.Errorf("Oversized = %d, want 1", h.Oversized)
+	}
+}
+
+func TestRecordSize_FirstOversizedOnly(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+
+	if stats.RecordSize("fe80::1", "neighbor_solicitation", 32) {
+		t.Error("normal-sized NS should not be reported as oversized")
+	}
+	if !stats.RecordSize("fe80::1", "neighbor_solicitation", 512) {
+		t.Error("first oversized NS should be reported")
+	}
+	if stats.RecordSize("fe80::1", "neighbor_solicitation", 512) {
+		t.Error("second oversized NS from the same peer should not be reported again")
+	}
+	if !stats.RecordSize("fe80::2", "neighbor_solicitation", 512) {
+		t.Error("first oversized NS from a different peer should be reported")
+	}
+
+	for _, p := range stats.GetStats() {
+		if p.Address == "fe80::1" && p.Oversized != 2 {
+			t.Errorf("fe80::1 Oversized = %d, want 2", p.Oversized)
+		}
+	}
+}
+
+func TestRecordSize_PeerBytes(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordSize("fe80::1", "neighbor_solicitation", 32)
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	stats.RecordSize("fe80::1", "neighbor_solicitation", 40)
+	stats.RecordMessage("fe80::1", "mld_report")
+	stats.RecordSize("fe80::1", "mld_report", 28)
+
+	p := stats.GetStats()[0]
+	if p.Bytes["neighbor_solicitation"] != 72 || p.Bytes["mld_report"] != 28 || p.TotalBytes != 100 {
+		t.Errorf("bytes = %v (total %d), want NS 72, MR 28, total 100", p.Bytes, p.TotalBytes)
+	}
+
+	// Age the NS bytes out of the window; the report stays.
+	stats.mu.Lock()
+	for i := range stats.peers["fe80::1"].Bytes["neighbor_solicitation"] {
+		stats.peers["fe80::1"].Bytes["neighbor_solicitation"][i].at = time.Now().Add(-10 * time.Minute)
+	}
+	stats.mu.Unlock()
+	stats.Prune()
+
+	p = stats.GetStats()[0]
+	if p.TotalBytes != 28 {
+		t.Errorf("total bytes after prune = %d, want 28", p.TotalBytes)
+	}
+	if _, ok := stats.peers["fe80::1"].Bytes["neighbor_solicitation"]; ok {
+		t.Error("expired NS byte samples were not pruned")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for v, want := range map[float64]string{0: "0", 0.4: "0.4", 512: "512", 1234: "1.2k", 45678: "46k", 2.5e6: "2.5M", 34e6: "34M"} {
+		if got := formatBytes(v); got != want {
+			t.Errorf("formatBytes(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestGetSizeHistograms_ReturnsCopy(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordSize("fe80::1", "router_solicitation", 16)
+
+	h := stats.GetSizeHistograms()["router_solicitation"]
+	h.Buckets[0] = 99
+
+	if got := stats.GetSizeHistograms()["router_solicitation"].Buckets[0]; got != 1 {
+		t.Errorf("internal bucket modified through copy: got %d, want 1", got)
+	}
+}
+
+func TestRASizeAlerts(t *testing.T) {
+	tests := []struct {
+		name          string
+		n, linkMTU    int
+		advertisedMTU uint32
+		want          []string
+	}{
+		{"fits", 200, 1500, 1500, nil},
+		{"unknown mtus", 4000, 0, 0, nil},
+		{"fragmented", 1600, 1500, 0, []string{"fragmented_ra"}},
+		{"exceeds advertised", 1300, 1500, 1280, []string{"oversized_ra"}},
+		{"both", 1600, 1500, 1280, []string{"fragmented_ra", "oversized_ra"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts := raSizeAlerts("fe80::1", tt.n, tt.linkMTU, tt.advertisedMTU)
+			if len(alerts) != len(tt.want) {
+				t.Fatalf("got %d alerts, want %d", len(alerts), len(tt.want))
+			}
+			for i, a := range alerts {
+				if a.Category != tt.want[i] {
+					t.Errorf("alert[%d].Category = %q, want %q", i, a.Category, tt.want[i])
+				}
+				if a.Source != "fe80::1" {
+					t.Errorf("alert[%d].Source = %q, want fe80::1", i, a.Source)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Snapshot is a consistent point-in-time copy of everything NDPStats knows.
+// All fields are captured under a single lock acquisition, so peers, routers,
+// groups and alerts always describe the same instant.
+type Snapshot struct {
+	Taken   time.Time                `json:"taken"`
+	Node    string                   `json:"node,omitempty"` // Status.Node
+	Window  time.Duration            `json:"window"`
+	Peers   []PeerSummary            `json:"peers"`
+	Routers []RouterInfo             `json:"routers"`
+	Gone    []GoneRouter             `json:"gone_routers"`
+	Groups  map[string][]string      `json:"groups"` // multicast group -> member addresses
+	Sizes   map[string]SizeHistogram `json:"sizes"`
+	Alerts  []Alert                  `json:"alerts"`
+	// Summary is the network-wide traffic per message type in the window.
+	Summary NetworkSummary `json:"summary"`
+	// ExtHeaders counts ICMPv6 packets with unexpected extension header chains.
+	ExtHeaders map[string]int `json:"unexpected_ext_headers,omitempty"`
+	// RouterAlert counts MLD messages failing Router Alert validation, by reason.
+	RouterAlert map[string]int `json:"mld_router_alert_violations,omitempty"`
+	// ChecksumFailures counts ICMPv6 messages dropped for a bad checksum.
+	ChecksumFailures int `json:"checksum_failures,omitempty"`
+	// Sampling is the adaptive sampling rate under overload.
+	Sampling SamplingStats `json:"sampling"`
+	// FastPath is the profile-guided fast path state.
+	FastPath FastPathStats `json:"fast_path"`
+	// RAGuard is the RA guard canary state.
+	RAGuard RAGuardStats `json:"ra_guard"`
+	// RogueRA is the rogue RA detection state.
+	RogueRA RogueRAStats `json:"rogue_ra"`
+	// MLDSnoop is the MLD snooping verification state.
+	MLDSnoop MLDSnoopStats `json:"mld_snoop"`
+	// MTU is what Packet Too Big messages have shown.
+	MTU MTUStats `json:"mtu"`
+	// Duplicates counts duplicated packets per capture interface.
+	Duplicates []InterfaceDuplicates `json:"duplicates,omitempty"`
+	// Dedup is the state of duplicate dropping.
+	Dedup DedupStats `json:"dedup"`
+	// MLDLatency is the MLD query response latency per group.
+	MLDLatency []MLDGroupLatency `json:"mld_latency,omitempty"`
+	// Multicast holds MLD Done-without-Join and silent groups.
+	Multicast MulticastSanity `json:"multicast_sanity"`
+	// Undefended lists joined-but-unanswered addresses.
+	Undefended []UndefendedAddress `json:"undefended,omitempty"`
+	// SolicitedTargets is how popular each solicited address is, most first.
+	SolicitedTargets []SolicitedTarget `json:"solicited_targets,omitempty"`
+	// SolicitGraph lists who solicited whom (see SolicitGraph).
+	SolicitGraph []SolicitEdge `json:"solicit_graph,omitempty"`
+	// Bindings lists recent IP/MAC binding changes, newest first.
+	Bindings []BindingChange `json:"bindings,omitempty"`
+	// DAD lists recent Duplicate Address Detection transactions, newest first.
+	DAD []DADTransaction `json:"dad,omitempty"`
+	// VirtualRouters lists VRRP/HSRP groups and their current masters.
+	VirtualRouters []VirtualRouter `json:"virtual_routers,omitempty"`
+	// RSLatency is how quickly routers answered our Router Solicitations (--solicit).
+	RSLatency []RouterRSLatency `json:"rs_latency,omitempty"`
+	// Reachability is how routers answered our NS probes (--probe-routers).
+	Reachability []RouterReachability `json:"router_reachability,omitempty"`
+	// SwitchNeighbors is the switch port each interface is plugged into (--lldp).
+	SwitchNeighbors []SwitchNeighbor `json:"switch_neighbors,omitempty"`
+	// MaintenanceSuppressed counts alerts suppressed by each maintenance window.
+	MaintenanceSuppressed map[string]int `json:"maintenance_suppressed,omitempty"`
+	// IPv6Health is the IPv6 health score per segment.
+	IPv6Health []SegmentHealth `json:"ipv6_health,omitempty"`
+	// OptionUsage is the NDP option usage matrix by stack and message type.
+	OptionUsage []OptionUsage `json:"option_usage,omitempty"`
+	// NUD is how hosts probe neighbor reachability.
+	NUD []NUDBehavior `json:"nud,omitempty"`
+}
+
+// defaultSharedAge is how long Shared reuses a snapshot unless SetSharedAge
+// says otherwise.
+const defaultSharedAge = time.Second
+
+// SetSharedAge sets how long Shared hands out the same snapshot. Half the
+// TUI refresh interval makes every tick take a new one, which the API and
+// exporters then share until the next.
+func (s *NDPStats) SetSharedAge(d time.Duration) {
+	s.sharedAge.Store(int64(d))
+}
+
+// Shared returns the latest snapshot if it is younger than the shared age
+// (see SetSharedAge), and otherwise takes a new one and publishes it. The
+// TUI, API and exporters all read from it, so they take the stats lock and
+// allocate once per tick between them instead of once per request, and
+// show the same instant. The snapshot is shared: callers must not modify
+// it or anything it refers to.
+func (s *NDPStats) Shared() *Snapshot {
+	age := time.Duration(s.sharedAge.Load())
+	if age <= 0 {
+		age = defaultSharedAge
+	}
+	if snap := s.shared.Load(); snap != nil && time.Since(snap.Taken) < age {
+		return snap
+	}
+	snap := s.Snapshot()
+	s.shared.Store(&snap)
+	return &snap
+}
+
+// Graph returns the solicit graph of the snapshot.
+func (snap *Snapshot) Graph() SolicitGraph {
+	nodes := make(map[string]bool)
+	for _, e := range snap.SolicitGraph {
+		nodes[e.Source] = true
+		nodes[e.Target] = true
+	}
+	g := SolicitGraph{Nodes: make([]string, 0, len(nodes)), Edges: snap.SolicitGraph}
+	for n := range nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Strings(g.Nodes)
+	if g.Edges == nil {
+		g.Edges = []SolicitEdge{}
+	}
+	return g
+}
+
+// Snapshot atomically copies the current peers, routers, router history, multicast groups,
+// size histograms and alerts. Capture continues unaffected once it returns.
+func (s *NDPStats) Snapshot() Snapshot {
+	now := s.now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := Snapshot{
+		Taken:   now,
+		Node:    s.status.Node,
+		Window:  s.window,
+		Peers:   s.summariesLocked(now),
+		Routers: s.routersLocked(),
+		Gone:    s.goneRoutersLocked(),
+		Groups:  make(map[string][]string),
+		Sizes:   s.sizesLocked(),
+		Alerts:  s.alertsLocked(),
+
+		ExtHeaders:  s.extAnomaliesLocked(),
+		RouterAlert: s.routerAlertViolationsLocked(),
+
+		ChecksumFailures: s.checksumFailures,
+		Sampling:         s.samplingLocked(),
+		FastPath:         s.fastPathLocked(),
+		RAGuard:          s.raGuard,
+		RogueRA:          s.rogueRA,
+		MLDSnoop:         s.mldSnoop,
+		MTU:              s.mtu,
+		Duplicates:       s.duplicatesLocked(now),
+		Dedup:            s.dedup,
+		MLDLatency:       s.mldLatenciesLocked(),
+		Multicast:        s.multicastSanityLocked(now),
+		Undefended:       s.undefendedLocked(now),
+		Bindings:         s.bindingChangesLocked(),
+		SolicitedTargets: s.solicitedTargetsLocked(now),
+		NUD:              s.nudLocked(now),
+		SolicitGraph:     s.solicitGraphLocked(now).Edges,
+		DAD:              s.dadLocked(now),
+		VirtualRouters:   s.virtualRoutersLocked(),
+		RSLatency:        s.rsLatenciesLocked(),
+		Reachability:     s.routerReachabilityLocked(),
+		SwitchNeighbors:  s.switchNeighborsLocked(),
+
+		MaintenanceSuppressed: s.maintenanceSuppressedLocked(),
+	}
+	snap.Summary = SummarizeMessages(snap.Peers, s.window)
+	snap.OptionUsage = s.optionUsageLocked(snap.Peers)
+	for _, p := range snap.Peers {
+		for _, g := range p.Groups {
+			snap.Groups[g] = append(snap.Groups[g], p.Address)
+		}
+	}
+	for _, members := range snap.Groups {
+		sort.Strings(members)
+	}
+	ifaces := make(map[string]string, len(snap.Peers))
+	for _, p := range snap.Peers {
+		ifaces[p.Address] = p.Interface
+	}
+	snap.IPv6Health = ipv6Health(now, s.window, snap.Routers, snap.DAD, snap.Alerts, ifaces)
+	return snap
+}
+
+// WriteSnapshotFile writes snap as indented JSON to a timestamped file in dir
+// and returns its path. A named instance (see InstanceName) is part of the
+// file name. The file is written to a temporary name and renamed into place
+// so readers never observe a partial snapshot.
+func WriteSnapshotFile(snap Snapshot, dir, instance string, anon *Anonymizer) (string, error) {
+	name := instanceFileName("snapshot", instance, snap.Taken.Format("20060102-150405.000"), ".json")
+	path := filepath.Join(dir, name)
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode snapshot: %w", err)
+	}
+	if data, err = anon.JSON(data); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// writeFileAtomic writes data to path via a temporary file and rename.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename %s: %w", tmp, err)
+	}
+	return nil
+}
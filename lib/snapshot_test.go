@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshot_CapturesEverything(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+
+	stats.RecordMessage("fe80::1", "mld_report")
+	stats.RecordMLDMembership("fe80::1", "ff02::fb")
+	stats.RecordMessage("fe80::2", "mld_report")
+	stats.RecordMLDMembership("fe80::2", "ff02::fb")
+	stats.RecordRouter(RouterInfo{Address: "fe80::99", LastSeen: time.Now()})
+	stats.RecordAlert(Alert{Category: "test", Source: "fe80::1"})
+
+	snap := stats.Snapshot()
+
+	if len(snap.Peers) != 2 {
+		t.Errorf("Peers = %d, want 2", len(snap.Peers))
+	}
+	if len(snap.Routers) != 1 {
+		t.Errorf("Routers = %d, want 1", len(snap.Routers))
+	}
+	if got := snap.Groups["ff02::fb"]; len(got) != 2 || got[0] != "fe80::1" || got[1] != "fe80::2" {
+		t.Errorf("Groups[ff02::fb] = %v, want [fe80::1 fe80::2]", got)
+	}
+	if len(snap.Alerts) != 1 {
+		t.Errorf("Alerts = %d, want 1", len(snap.Alerts))
+	}
+	if snap.Window != 5*time.Minute {
+		t.Errorf("Window = %v, want 5m", snap.Window)
+	}
+}
+
+func TestWriteSnapshotFile(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1", "router_solicitation")
+	stats.RecordAlert(Alert{Severity: SeverityCritical, Category: "test"})
+
+	dir := t.TempDir()
+	path, err := WriteSnapshotFile(stats.Snapshot(), dir, "", nil)
+	if err != nil {
+		t.Fatalf("WriteSnapshotFile: %v", err)
+	}
+	if filepath.Dir(path) != dir {
+		t.Errorf("path %q not in %q", path, dir)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	var got Snapshot
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if len(got.Peers) != 1 || got.Peers[0].Address != "fe80::1" {
+		t.Errorf("Peers = %+v, want fe80::1", got.Peers)
+	}
+	if len(got.Alerts) != 1 || got.Alerts[0].Severity != SeverityCritical {
+		t.Errorf("Alerts = %+v, want one critical alert", got.Alerts)
+	}
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temporary file left behind")
+	}
+}
+
+func TestShared(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.SetSharedAge(time.Hour)
+	stats.RecordMessage("fe80::1", "neighbor_solicitation")
+
+	first := stats.Shared()
+	stats.RecordMessage("fe80::2", "neighbor_solicitation")
+	if again := stats.Shared(); again != first || len(again.Peers) != 1 {
+		t.Fatalf("Shared within its age took a new snapshot (%d peers)", len(again.Peers))
+	}
+
+	stats.SetSharedAge(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if next := stats.Shared(); next == first || len(next.Peers) != 2 {
+		t.Errorf("Shared after its age = %d peers, want a new snapshot with 2", len(next.Peers))
+	}
+}
+
+func TestSnapshot_Graph(t *testing.T) {
+	snap := Snapshot{SolicitGraph: []SolicitEdge{
+		{Source: "fe80::1", Target: "fe80::2"},
+		{Source: "fe80::3", Target: "fe80::2"},
+	}}
+	g := snap.Graph()
+	if len(g.Nodes) != 3 || g.Nodes[0] != "fe80::1" || g.Nodes[2] != "fe80::3" || len(g.Edges) != 2 {
+		t.Errorf("graph = %+v, want 3 sorted nodes and both edges", g)
+	}
+}
@@ -0,0 +1,342 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// A minimal SNMPv2c manager (RFC 3416) for the few GETs and walks MAC
+// location needs: BER encoding of the message, GetRequest, GetBulkRequest
+// and the response value types.
+
+// BER and SNMP tags.
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+
+	snmpGet      = 0xa0
+	snmpResponse = 0xa2
+	snmpGetBulk  = 0xa5
+
+	snmpCounter32      = 0x41
+	snmpGauge32        = 0x42
+	snmpTimeTicks      = 0x43
+	snmpNoSuchObject   = 0x80
+	snmpNoSuchInstance = 0x81
+	snmpEndOfMibView   = 0x82
+
+	snmpVersion2c = 1
+	snmpRetries   = 2
+)
+
+var errBER = errors.New("malformed BER")
+
+// snmpVarBind is one name/value pair of a response.
+type snmpVarBind struct {
+	oid   []uint32
+	typ   byte
+	value []byte // content octets
+}
+
+// int returns an INTEGER, Counter32, Gauge32 or TimeTicks value.
+func (vb snmpVarBind) int() (int64, bool) {
+	switch vb.typ {
+	case berInteger, snmpCounter32, snmpGauge32, snmpTimeTicks:
+	default:
+		return 0, false
+	}
+	if len(vb.value) == 0 || len(vb.value) > 8 {
+		return 0, false
+	}
+	return berParseInt(vb.value), true
+}
+
+// exists reports whether the agent had a value for the name.
+func (vb snmpVarBind) exists() bool {
+	return vb.typ != snmpNoSuchObject && vb.typ != snmpNoSuchInstance && vb.typ != snmpEndOfMibView
+}
+
+// snmpClient sends SNMPv2c requests to one agent over UDP.
+type snmpClient struct {
+	address   string // host:port
+	community string
+	timeout   time.Duration // per attempt
+}
+
+// get fetches oid with a GetRequest.
+func (c *snmpClient) get(ctx context.Context, oid []uint32) (snmpVarBind, error) {
+	vbs, err := c.request(ctx, snmpGet, 0, 0, [][]uint32{oid})
+	if err != nil {
+		return snmpVarBind{}, err
+	}
+	if len(vbs) != 1 {
+		return snmpVarBind{}, fmt.Errorf("snmp %s: %d varbinds in response to one", c.address, len(vbs))
+	}
+	return vbs[0], nil
+}
+
+// walk returns every varbind under root, fetching maxReps per GetBulk and
+// giving up after limit varbinds.
+func (c *snmpClient) walk(ctx context.Context, root []uint32, maxReps, limit int) ([]snmpVarBind, error) {
+	var out []snmpVarBind
+	next := root
+	for len(out) < limit {
+		vbs, err := c.request(ctx, snmpGetBulk, 0, maxReps, [][]uint32{next})
+		if err != nil {
+			return out, err
+		}
+		for _, vb := range vbs {
+			if !vb.exists() || !hasOIDPrefix(vb.oid, root) {
+				return out, nil
+			}
+			if compareOID(vb.oid, next) <= 0 {
+				return out, fmt.Errorf("agent returned %s out of order", formatOID(vb.oid))
+			}
+			out = append(out, vb)
+			next = vb.oid
+		}
+		if len(vbs) == 0 {
+			return out, nil
+		}
+	}
+	return out, fmt.Errorf("walk of %s stopped after %d entries", formatOID(root), limit)
+}
+
+// request sends one PDU, retrying on timeout, and returns the response's
+// varbinds. For GetBulk, a and b are non-repeaters and max-repetitions.
+func (c *snmpClient) request(ctx context.Context, pduType byte, a, b int, oids [][]uint32) ([]snmpVarBind, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", c.address)
+	if err != nil {
+		return nil, fmt.Errorf("snmp %s: %w", c.address, err)
+	}
+	defer conn.Close()
+
+	id := int64(rand.Int32())
+	msg := snmpMessage(c.community, pduType, id, a, b, oids)
+	buf := make([]byte, 65535)
+	for attempt := 0; attempt <= snmpRetries; attempt++ {
+		if _, err := conn.Write(msg); err != nil {
+			return nil, fmt.Errorf("snmp %s: %w", c.address, err)
+		}
+		deadline := time.Now().Add(c.timeout)
+		if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+			deadline = dl
+		}
+		_ = conn.SetReadDeadline(deadline)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() && ctx.Err() == nil {
+					break // retry
+				}
+				return nil, fmt.Errorf("snmp %s: %w", c.address, err)
+			}
+			respID, vbs, err := parseSNMPResponse(buf[:n])
+			if err != nil {
+				return nil, fmt.Errorf("snmp %s: %w", c.address, err)
+			}
+			if respID == id {
+				return vbs, nil
+			}
+			// A late answer to an earlier attempt; keep reading.
+		}
+	}
+	return nil, fmt.Errorf("snmp %s: no response after %d attempts", c.address, snmpRetries+1)
+}
+
+// snmpMessage encodes an SNMPv2c message carrying one request PDU.
+func snmpMessage(community string, pduType byte, id int64, a, b int, oids [][]uint32) []byte {
+	var vbs []byte
+	for _, oid := range oids {
+		vbs = append(vbs, berTLV(berSequence, append(berTLV(berOID, berOIDContent(oid)), berNull, 0))...)
+	}
+	pdu := berTLV(berInteger, berInt(id))
+	pdu = append(pdu, berTLV(berInteger, berInt(int64(a)))...)
+	pdu = append(pdu, berTLV(berInteger, berInt(int64(b)))...)
+	pdu = append(pdu, berTLV(berSequence, vbs)...)
+
+	msg := berTLV(berInteger, berInt(snmpVersion2c))
+	msg = append(msg, berTLV(berOctetString, []byte(community))...)
+	msg = append(msg, berTLV(pduType, pdu)...)
+	return berTLV(berSequence, msg)
+}
+
+// parseSNMPResponse decodes a Response PDU, returning its request ID and
+// varbinds, or an error for a failed request.
+func parseSNMPResponse(b []byte) (int64, []snmpVarBind, error) {
+	tag, msg, _, err := berRead(b)
+	if err != nil || tag != berSequence {
+		return 0, nil, errBER
+	}
+	var fields [3][]byte
+	var tags [3]byte
+	for i := range fields {
+		if tags[i], fields[i], msg, err = berRead(msg); err != nil {
+			return 0, nil, err
+		}
+	}
+	if tags[2] != snmpResponse {
+		return 0, nil, fmt.Errorf("unexpected PDU type %#x", tags[2])
+	}
+	pdu := fields[2]
+	var ints [3]int64
+	for i := range ints {
+		var content []byte
+		if tag, content, pdu, err = berRead(pdu); err != nil || tag != berInteger {
+			return 0, nil, errBER
+		}
+		ints[i] = berParseInt(content)
+	}
+	if ints[1] != 0 {
+		return ints[0], nil, fmt.Errorf("agent error-status %d at index %d", ints[1], ints[2])
+	}
+	tag, list, _, err := berRead(pdu)
+	if err != nil || tag != berSequence {
+		return 0, nil, errBER
+	}
+	var vbs []snmpVarBind
+	for len(list) > 0 {
+		var vb, name []byte
+		if tag, vb, list, err = berRead(list); err != nil || tag != berSequence {
+			return 0, nil, errBER
+		}
+		if tag, name, vb, err = berRead(vb); err != nil || tag != berOID {
+			return 0, nil, errBER
+		}
+		oid, ok := berParseOID(name)
+		if !ok {
+			return 0, nil, errBER
+		}
+		typ, value, _, err := berRead(vb)
+		if err != nil {
+			return 0, nil, err
+		}
+		vbs = append(vbs, snmpVarBind{oid: oid, typ: typ, value: value})
+	}
+	return ints[0], vbs, nil
+}
+
+// berTLV encodes a tag, length and content.
+func berTLV(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	switch n := len(content); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+// berRead splits the first TLV off b.
+func berRead(b []byte) (tag byte, content, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errBER
+	}
+	tag, n, off := b[0], int(b[1]), 2
+	if n&0x80 != 0 {
+		octets := n & 0x7f
+		if octets == 0 || octets > 3 || len(b) < 2+octets {
+			return 0, nil, nil, errBER
+		}
+		n = 0
+		for _, c := range b[2 : 2+octets] {
+			n = n<<8 | int(c)
+		}
+		off += octets
+	}
+	if len(b)-off < n {
+		return 0, nil, nil, errBER
+	}
+	return tag, b[off : off+n], b[off+n:], nil
+}
+
+// berInt encodes n in the fewest two's complement octets.
+func berInt(n int64) []byte {
+	out := []byte{byte(n)}
+	for n >= 0x80 || n < -0x80 {
+		n >>= 8
+		out = append([]byte{byte(n)}, out...)
+	}
+	return out
+}
+
+func berParseInt(b []byte) int64 {
+	var n int64
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n = -1
+	}
+	for _, c := range b {
+		n = n<<8 | int64(c)
+	}
+	return n
+}
+
+// berOIDContent encodes oid, which has at least two arcs.
+func berOIDContent(oid []uint32) []byte {
+	out := berBase128(nil, oid[0]*40+oid[1])
+	for _, arc := range oid[2:] {
+		out = berBase128(out, arc)
+	}
+	return out
+}
+
+func berBase128(b []byte, v uint32) []byte {
+	var tmp [5]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(v & 0x7f)
+	for v >>= 7; v > 0; v >>= 7 {
+		i--
+		tmp[i] = byte(v&0x7f) | 0x80
+	}
+	return append(b, tmp[i:]...)
+}
+
+func berParseOID(b []byte) ([]uint32, bool) {
+	var arcs []uint32
+	var v uint32
+	for i, c := range b {
+		if v > 1<<25 {
+			return nil, false
+		}
+		v = v<<7 | uint32(c&0x7f)
+		if c&0x80 != 0 {
+			if i == len(b)-1 {
+				return nil, false
+			}
+			continue
+		}
+		if len(arcs) == 0 {
+			first := min(v/40, 2)
+			arcs = append(arcs, first, v-first*40)
+		} else {
+			arcs = append(arcs, v)
+		}
+		v = 0
+	}
+	return arcs, len(arcs) >= 2
+}
+
+// hasOIDPrefix reports whether oid lies under prefix.
+func hasOIDPrefix(oid, prefix []uint32) bool {
+	if len(oid) <= len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if oid[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,235 @@
+package lib
+
+import (
+	"NDPeekr/lib/craft"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// rsResponseWindow is how long after our RS an RA still counts as the
+	// answer. Routers delay solicited RAs by up to MAX_RA_DELAY_TIME (0.5s)
+	// and rate limit multicast RAs to one per MIN_DELAY_BETWEEN_RAS (3s)
+	// (RFC 4861 section 6.2.6), so a healthy router answers well within it.
+	rsResponseWindow = 4 * time.Second
+	// maxRSLatencySamples caps the latency history kept per router.
+	maxRSLatencySamples = 60
+	// defaultSolicitInterval is used when SolicitorConfig.Interval is unset.
+	defaultSolicitInterval = time.Minute
+)
+
+var allRouters = net.ParseIP("ff02::2")
+
+// rsLatency tracks how a router answered our Router Solicitations.
+type rsLatency struct {
+	answered  time.Time // the RS this router last answered
+	responses int
+	missed    int
+	total     time.Duration
+	min, max  time.Duration
+	samples   []RSLatencySample
+}
+
+// RSLatencySample is one RS → RA round trip.
+type RSLatencySample struct {
+	At      time.Time     `json:"at"` // when the RS was sent
+	Latency time.Duration `json:"latency"`
+}
+
+// RouterRSLatency summarises how quickly a router answered our Router
+// Solicitations, a simple responsiveness health metric.
+type RouterRSLatency struct {
+	Router    string        `json:"router"`
+	Responses int           `json:"responses"` // solicitations answered
+	Missed    int           `json:"missed"`    // solicitations not answered within rsResponseWindow
+	Last      time.Duration `json:"last"`
+	Min       time.Duration `json:"min"`
+	Mean      time.Duration `json:"mean"`
+	Max       time.Duration `json:"max"`
+	// Samples holds the most recent round trips, oldest first.
+	Samples []RSLatencySample `json:"samples"`
+}
+
+// RecordSolicit notes that we sent a Router Solicitation at now. Routers that
+// answered an earlier solicitation but not the previous one are counted as
+// having missed it.
+func (s *NDPStats) RecordSolicit(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.rsSent.IsZero() {
+		for _, rl := range s.rsLatency {
+			if !rl.answered.Equal(s.rsSent) {
+				rl.missed++
+			}
+		}
+	}
+	s.rsSent = now
+}
+
+// RecordRSResponse matches an RA from router to our latest Router
+// Solicitation and records the latency. Only the first RA from each router
+// within rsResponseWindow counts; anything else is unsolicited.
+func (s *NDPStats) RecordRSResponse(router string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rsSent.IsZero() || now.Before(s.rsSent) || now.Sub(s.rsSent) > rsResponseWindow {
+		return
+	}
+	rl, ok := s.rsLatency[router]
+	if !ok {
+		rl = &rsLatency{}
+		s.rsLatency[router] = rl
+	}
+	if rl.answered.Equal(s.rsSent) {
+		return
+	}
+	rl.answered = s.rsSent
+
+	latency := now.Sub(s.rsSent)
+	if rl.responses == 0 || latency < rl.min {
+		rl.min = latency
+	}
+	rl.max = max(rl.max, latency)
+	rl.responses++
+	rl.total += latency
+	rl.samples = append(rl.samples, RSLatencySample{At: s.rsSent, Latency: latency})
+	if len(rl.samples) > maxRSLatencySamples {
+		rl.samples = rl.samples[len(rl.samples)-maxRSLatencySamples:]
+	}
+}
+
+// GetRSLatencies returns per-router RS → RA latency, ordered by router address.
+func (s *NDPStats) GetRSLatencies() []RouterRSLatency {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rsLatenciesLocked()
+}
+
+// rsLatenciesLocked builds the per-router latency summaries. Callers must hold s.mu.
+func (s *NDPStats) rsLatenciesLocked() []RouterRSLatency {
+	result := make([]RouterRSLatency, 0, len(s.rsLatency))
+	for router, rl := range s.rsLatency {
+		result = append(result, RouterRSLatency{
+			Router:    router,
+			Responses: rl.responses,
+			Missed:    rl.missed,
+			Last:      rl.samples[len(rl.samples)-1].Latency,
+			Min:       rl.min,
+			Mean:      rl.total / time.Duration(rl.responses),
+			Max:       rl.max,
+			Samples:   append([]RSLatencySample(nil), rl.samples...),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Router < result[j].Router })
+	return result
+}
+
+// pruneRSLatencyLocked forgets latency for routers no longer in the router
+// table. Callers must hold s.mu and prune routers first.
+func (s *NDPStats) pruneRSLatencyLocked() {
+	for router := range s.rsLatency {
+		if _, ok := s.routers[router]; !ok {
+			delete(s.rsLatency, router)
+		}
+	}
+}
+
+// SolicitorConfig configures a Solicitor.
+type SolicitorConfig struct {
+	Interface string        // required; RSs go to ff02::2 on this link
+	Interval  time.Duration // between solicitations; defaultSolicitInterval if 0
+	Logger    *slog.Logger  // required
+	Stats     *NDPStats     // required; receives the send times
+}
+
+// Solicitor periodically sends Router Solicitations so that routers answer
+// with an RA and their response latency can be measured. The RAs themselves
+// are captured by the NDPListener like any other.
+type Solicitor struct {
+	cfg SolicitorConfig
+}
+
+func NewSolicitor(cfg SolicitorConfig) *Solicitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultSolicitInterval
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &Solicitor{cfg: cfg}
+}
+
+// Run sends a Router Solicitation immediately and then every Interval until
+// ctx is cancelled.
+func (s *Solicitor) Run(ctx context.Context) error {
+	ifi, err := net.InterfaceByName(s.cfg.Interface)
+	if err != nil {
+		return fmt.Errorf("solicit: %w", err)
+	}
+	pc, err := listenNDSender(ifi)
+	if err != nil {
+		return fmt.Errorf("solicit: %w", err)
+	}
+	defer pc.Close()
+
+	rs := craft.BuildRS(craft.RS{SourceMAC: ifi.HardwareAddr})
+	dst := &net.IPAddr{IP: allRouters, Zone: linkZone(ifi)}
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		if _, err := pc.WriteTo(rs, dst); err != nil {
+			s.cfg.Logger.Warn("failed to send router solicitation", "iface", ifi.Name, "err", err)
+		} else {
+			s.cfg.Stats.RecordSolicit(now)
+			s.cfg.Logger.Debug("sent router solicitation", "iface", ifi.Name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// linkZone returns the zone for link-scoped destinations on ifi. It is the
+// interface index rather than the name: the net package caches name-to-index
+// lookups for up to a minute, so a recreated interface's name would still
+// resolve to the old index.
+func linkZone(ifi *net.Interface) string {
+	return strconv.Itoa(ifi.Index)
+}
+
+// listenNDSender opens an ICMPv6 socket for sending ND messages on ifi. It
+// receives nothing; the NDPListener captures the answers.
+func listenNDSender(ifi *net.Interface) (*icmp.PacketConn, error) {
+	pc, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return nil, fmt.Errorf("listen icmpv6: %w", err)
+	}
+
+	// RFC 4861 requires hop limit 255 on every ND message.
+	p := pc.IPv6PacketConn()
+	err = errors.Join(p.SetHopLimit(255), p.SetMulticastHopLimit(255), p.SetMulticastInterface(ifi))
+	if err != nil {
+		pc.Close()
+		return nil, fmt.Errorf("configure icmpv6 socket: %w", err)
+	}
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	_ = p.SetICMPFilter(&filter)
+	return pc, nil
+}
@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordRSResponse(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	t0 := time.Now()
+
+	// An RA before we ever solicited is unsolicited.
+	stats.RecordRSResponse("fe80::1", t0)
+	if got := stats.GetRSLatencies(); len(got) != 0 {
+		t.Fatalf("latencies before any RS = %+v", got)
+	}
+
+	stats.RecordSolicit(t0)
+	stats.RecordRSResponse("fe80::1", t0.Add(20*time.Millisecond))
+	stats.RecordRSResponse("fe80::1", t0.Add(30*time.Millisecond)) // repeat, ignored
+	stats.RecordRSResponse("fe80::2", t0.Add(300*time.Millisecond))
+	stats.RecordRSResponse("fe80::3", t0.Add(10*time.Second)) // too late to be an answer
+
+	t1 := t0.Add(time.Minute)
+	stats.RecordSolicit(t1)
+	stats.RecordRSResponse("fe80::1", t1.Add(40*time.Millisecond))
+
+	// fe80::2 missed the second RS.
+	stats.RecordSolicit(t1.Add(time.Minute))
+
+	got := stats.GetRSLatencies()
+	if len(got) != 2 {
+		t.Fatalf("got %d routers, want 2: %+v", len(got), got)
+	}
+	r1, r2 := got[0], got[1]
+	if r1.Router != "fe80::1" || r1.Responses != 2 || r1.Missed != 0 || r1.Last != 40*time.Millisecond ||
+		r1.Min != 20*time.Millisecond || r1.Mean != 30*time.Millisecond || r1.Max != 40*time.Millisecond {
+		t.Errorf("fe80::1 = %+v", r1)
+	}
+	if len(r1.Samples) != 2 || !r1.Samples[0].At.Equal(t0) || !r1.Samples[1].At.Equal(t1) {
+		t.Errorf("fe80::1 samples = %+v", r1.Samples)
+	}
+	if r2.Router != "fe80::2" || r2.Responses != 1 || r2.Missed != 1 || r2.Last != 300*time.Millisecond {
+		t.Errorf("fe80::2 = %+v", r2)
+	}
+}
+
+func TestRSLatencyPrunedWithRouter(t *testing.T) {
+	stats := NewNDPStats(time.Minute)
+	now := time.Now()
+	stats.RecordRouter(RouterInfo{Address: "fe80::1", LastSeen: now})
+	stats.RecordSolicit(now)
+	stats.RecordRSResponse("fe80::1", now.Add(10*time.Millisecond))
+	stats.RecordRSResponse("fe80::2", now.Add(10*time.Millisecond)) // not in the router table
+
+	stats.Prune()
+	got := stats.GetRSLatencies()
+	if len(got) != 1 || got[0].Router != "fe80::1" {
+		t.Errorf("latencies after prune = %+v, want only fe80::1", got)
+	}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if want := `ndpeekr_router_rs_latency_seconds{router="fe80::1",stat="last"} 0.01`; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("metrics missing %q", want)
+	}
+}
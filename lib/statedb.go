@@ -0,0 +1,367 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultStateInterval = 5 * time.Minute
+	// maxRememberedPeers caps the peers kept only for their first-seen time
+	// once pruned; the least recently seen are forgotten first.
+	maxRememberedPeers = 10000
+)
+
+// stateSchema holds the tables of the last saved state: one row per peer
+// and router, replaced on every save. Times are Unix nanoseconds.
+const stateSchema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS peers (
+	address     TEXT    PRIMARY KEY,
+	mac         TEXT    NOT NULL DEFAULT '',
+	iface       TEXT    NOT NULL DEFAULT '',
+	port        TEXT    NOT NULL DEFAULT '',
+	first_seen  INTEGER NOT NULL,
+	last_seen   INTEGER NOT NULL,
+	mac_history TEXT    NOT NULL DEFAULT '[]', -- JSON array of MACSighting
+	groups      TEXT    NOT NULL DEFAULT '{}'  -- JSON: group -> last report
+);
+
+CREATE TABLE IF NOT EXISTS routers (
+	address   TEXT    PRIMARY KEY,
+	last_seen INTEGER NOT NULL,
+	info      TEXT    NOT NULL -- JSON RouterInfo
+);
+`
+
+// PersistedPeer is what the state database keeps about a peer: enough to
+// give it back its first-seen time, MACs and group memberships after a
+// restart. Message counts are not kept.
+type PersistedPeer struct {
+	Address    string
+	MAC        string
+	MACHistory []MACSighting
+	Interface  string
+	Port       string
+	FirstSeen  time.Time
+	LastSeen   time.Time
+	// Groups holds the last report time of each multicast group.
+	Groups map[string]time.Time
+}
+
+// PersistedState is the state saved to and restored from the state database.
+type PersistedState struct {
+	Peers   []PersistedPeer
+	Routers []RouterInfo
+	// Saved is when the state was saved; zero if nothing was.
+	Saved time.Time
+}
+
+// StateDB is the SQLite state database behind --state-db.
+type StateDB struct {
+	db *sql.DB
+}
+
+// OpenStateDB opens (creating if needed) the state database at path.
+func OpenStateDB(path string) (*StateDB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open state db: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(stateSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create state schema in %s: %w", path, err)
+	}
+	return &StateDB{db: db}, nil
+}
+
+// Close closes the database.
+func (d *StateDB) Close() error {
+	return d.db.Close()
+}
+
+// Save replaces the stored state with st in a single transaction.
+func (d *StateDB) Save(st PersistedState) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM peers; DELETE FROM routers`); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	for _, p := range st.Peers {
+		history, _ := json.Marshal(nonNil(p.MACHistory))
+		groups, _ := json.Marshal(p.Groups)
+		if p.Groups == nil {
+			groups = []byte("{}")
+		}
+		_, err := tx.Exec(`INSERT INTO peers (address, mac, iface, port, first_seen, last_seen, mac_history, groups)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.Address, p.MAC, p.Interface, p.Port, p.FirstSeen.UnixNano(), p.LastSeen.UnixNano(),
+			string(history), string(groups))
+		if err != nil {
+			return fmt.Errorf("save peer %s: %w", p.Address, err)
+		}
+	}
+	for _, r := range st.Routers {
+		info, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("save router %s: %w", r.Address, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO routers (address, last_seen, info) VALUES (?, ?, ?)`,
+			r.Address, r.LastSeen.UnixNano(), string(info)); err != nil {
+			return fmt.Errorf("save router %s: %w", r.Address, err)
+		}
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES ('saved', ?)`,
+		strconv.FormatInt(st.Saved.UnixNano(), 10)); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	return nil
+}
+
+// Load returns the stored state; it is empty if nothing was saved yet.
+func (d *StateDB) Load() (PersistedState, error) {
+	var st PersistedState
+	var saved string
+	switch err := d.db.QueryRow(`SELECT value FROM meta WHERE key = 'saved'`).Scan(&saved); err {
+	case nil:
+		ns, err := strconv.ParseInt(saved, 10, 64)
+		if err != nil {
+			return st, fmt.Errorf("load state: bad save time %q", saved)
+		}
+		st.Saved = time.Unix(0, ns)
+	case sql.ErrNoRows:
+	default:
+		return st, fmt.Errorf("load state: %w", err)
+	}
+
+	rows, err := d.db.Query(`SELECT address, mac, iface, port, first_seen, last_seen, mac_history, groups FROM peers`)
+	if err != nil {
+		return st, fmt.Errorf("load peers: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p PersistedPeer
+		var first, last int64
+		var history, groups string
+		if err := rows.Scan(&p.Address, &p.MAC, &p.Interface, &p.Port, &first, &last, &history, &groups); err != nil {
+			return st, fmt.Errorf("load peers: %w", err)
+		}
+		p.FirstSeen, p.LastSeen = time.Unix(0, first), time.Unix(0, last)
+		if err := json.Unmarshal([]byte(history), &p.MACHistory); err != nil {
+			return st, fmt.Errorf("load peer %s: %w", p.Address, err)
+		}
+		if err := json.Unmarshal([]byte(groups), &p.Groups); err != nil {
+			return st, fmt.Errorf("load peer %s: %w", p.Address, err)
+		}
+		st.Peers = append(st.Peers, p)
+	}
+	if err := rows.Err(); err != nil {
+		return st, fmt.Errorf("load peers: %w", err)
+	}
+
+	rrows, err := d.db.Query(`SELECT info FROM routers ORDER BY last_seen`)
+	if err != nil {
+		return st, fmt.Errorf("load routers: %w", err)
+	}
+	defer rrows.Close()
+	for rrows.Next() {
+		var info string
+		var r RouterInfo
+		if err := rrows.Scan(&info); err != nil {
+			return st, fmt.Errorf("load routers: %w", err)
+		}
+		if err := json.Unmarshal([]byte(info), &r); err != nil {
+			return st, fmt.Errorf("load routers: %w", err)
+		}
+		st.Routers = append(st.Routers, r)
+	}
+	if err := rrows.Err(); err != nil {
+		return st, fmt.Errorf("load routers: %w", err)
+	}
+	return st, nil
+}
+
+// PersistentState returns the peers, including pruned ones still
+// remembered, and routers to save.
+func (s *NDPStats) PersistentState(now time.Time) PersistedState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st := PersistedState{Saved: now, Routers: s.routersLocked()}
+	for addr, peer := range s.peers {
+		st.Peers = append(st.Peers, persistedPeer(addr, peer))
+	}
+	for _, p := range s.remembered {
+		st.Peers = append(st.Peers, p)
+	}
+	return st
+}
+
+// persistedPeer copies what the state database keeps about peer.
+func persistedPeer(addr string, peer *PeerStats) PersistedPeer {
+	groups := make(map[string]time.Time, len(peer.Groups))
+	for g, t := range peer.Groups {
+		groups[g] = t
+	}
+	return PersistedPeer{
+		Address:    addr,
+		MAC:        peer.MAC,
+		MACHistory: slices.Clone(peer.MACHistory),
+		Interface:  peer.Interface,
+		Port:       peer.Port,
+		FirstSeen:  peer.FirstSeen,
+		LastSeen:   peer.LastSeen,
+		Groups:     groups,
+	}
+}
+
+// RestoreState loads st into the stats. Peers seen within the window and
+// grace period come back with no messages counted, and age out as usual if
+// they stay quiet; older ones are only remembered, so they get their
+// first-seen time back if they return. Routers past the window go to the
+// gone-router history. From now on pruned peers are remembered too, so they
+// are saved with the rest.
+func (s *NDPStats) RestoreState(st PersistedState, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.remembered == nil {
+		s.remembered = make(map[string]PersistedPeer)
+	}
+	cutoff := now.Add(-s.window)
+	for _, p := range st.Peers {
+		p.Address = canonicalAddr(p.Address)
+		if _, ok := s.peers[p.Address]; ok {
+			continue
+		}
+		if !p.LastSeen.After(cutoff.Add(-s.grace)) {
+			s.rememberLocked(p)
+			continue
+		}
+		peer := s.getOrCreatePeer(p.Address, p.FirstSeen)
+		peer.LastSeen = p.LastSeen
+		s.revivePeerLocked(peer, p)
+		for g, t := range p.Groups {
+			peer.Groups[g] = t
+		}
+	}
+	for _, r := range st.Routers {
+		r.Address = canonicalAddr(r.Address)
+		if _, ok := s.routers[r.Address]; ok {
+			continue
+		}
+		if !r.LastSeen.After(cutoff) {
+			s.goneRouters = append(s.goneRouters, GoneRouter{RouterInfo: r, GoneAt: r.LastSeen.Add(s.window)})
+			continue
+		}
+		copied := r
+		s.routers[r.Address] = &copied
+	}
+	if len(s.goneRouters) > maxGoneRouters {
+		s.goneRouters = s.goneRouters[len(s.goneRouters)-maxGoneRouters:]
+	}
+}
+
+// revivePeerLocked gives peer back what was remembered about it. Callers
+// must hold s.mu.
+func (s *NDPStats) revivePeerLocked(peer *PeerStats, p PersistedPeer) {
+	peer.FirstSeen = p.FirstSeen
+	peer.MAC = p.MAC
+	peer.MACHistory = p.MACHistory
+	peer.Interface = p.Interface
+	peer.Port = p.Port
+}
+
+// rememberLocked keeps p after its peer is pruned, forgetting the least
+// recently seen peer beyond maxRememberedPeers. It does nothing unless a
+// state was restored. Callers must hold s.mu.
+func (s *NDPStats) rememberLocked(p PersistedPeer) {
+	if s.remembered == nil {
+		return
+	}
+	p.Groups = nil
+	s.remembered[p.Address] = p
+	if len(s.remembered) <= maxRememberedPeers {
+		return
+	}
+	oldest := ""
+	for addr, r := range s.remembered {
+		if oldest == "" || r.LastSeen.Before(s.remembered[oldest].LastSeen) {
+			oldest = addr
+		}
+	}
+	delete(s.remembered, oldest)
+}
+
+// StateSaverConfig configures a StateSaver.
+type StateSaverConfig struct {
+	DB       *StateDB
+	Stats    *NDPStats
+	Logger   *slog.Logger
+	Interval time.Duration // default 5m
+	// Privacy, when set, drops MACs before the state is saved.
+	Privacy *PrivacyConfig
+}
+
+// StateSaver saves the stats to the state database periodically and on
+// shutdown.
+type StateSaver struct {
+	cfg StateSaverConfig
+}
+
+// NewStateSaver returns a saver; call Run to start saving.
+func NewStateSaver(cfg StateSaverConfig) *StateSaver {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultStateInterval
+	}
+	return &StateSaver{cfg: cfg}
+}
+
+// Run saves every interval until ctx is cancelled, then saves once more so
+// the state at shutdown is kept.
+func (s *StateSaver) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.save()
+			return
+		case <-ticker.C:
+			s.save()
+		}
+	}
+}
+
+func (s *StateSaver) save() {
+	st := s.cfg.Stats.PersistentState(time.Now())
+	for i := range st.Peers {
+		st.Peers[i] = s.cfg.Privacy.PersistedPeer(st.Peers[i])
+	}
+	for i := range st.Routers {
+		st.Routers[i] = s.cfg.Privacy.Router(st.Routers[i])
+	}
+	if err := s.cfg.DB.Save(st); err != nil {
+		s.cfg.Logger.Warn("state save failed", "err", err)
+	}
+}
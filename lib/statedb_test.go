@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateDBRoundTrip(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::1%eth0", "neighbor_solicitation")
+	stats.RecordMAC("fe80::1%eth0", "00:11:22:33:44:55")
+	stats.RecordMLDMembership("fe80::1%eth0", "ff02::fb")
+	stats.RecordRouter(RouterInfo{Address: "fe80::a", Interface: "eth0", Lifetime: 30 * time.Minute, LastSeen: time.Now(),
+		Prefixes: []PrefixInfo{{Prefix: "2001:db8::/64", ValidLifetime: time.Hour}}})
+
+	db, err := OpenStateDB(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if st, err := db.Load(); err != nil || len(st.Peers) != 0 || !st.Saved.IsZero() {
+		t.Fatalf("empty db loaded %+v, %v", st, err)
+	}
+
+	saver := NewStateSaver(StateSaverConfig{DB: db, Stats: stats, Privacy: &PrivacyConfig{DropMACs: true}})
+	saver.save()
+	st, err := db.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Peers) != 1 || st.Peers[0].MAC != "" || st.Peers[0].Groups["ff02::fb"].IsZero() {
+		t.Errorf("peers = %+v, want one without its MAC", st.Peers)
+	}
+	if len(st.Routers) != 1 || len(st.Routers[0].Prefixes) != 1 || st.Saved.IsZero() {
+		t.Errorf("routers = %+v, saved %v", st.Routers, st.Saved)
+	}
+
+	// A second save replaces the first.
+	saver.cfg.Privacy = nil
+	saver.save()
+	if st, _ = db.Load(); len(st.Peers) != 1 || st.Peers[0].MAC != "00:11:22:33:44:55" {
+		t.Errorf("peers after resave = %+v", st.Peers)
+	}
+}
+
+func TestRestoreState(t *testing.T) {
+	now := time.Now()
+	long := now.Add(-30 * 24 * time.Hour)
+	st := PersistedState{
+		Peers: []PersistedPeer{
+			{Address: "fe80::1%eth0", MAC: "00:11:22:33:44:55", FirstSeen: long, LastSeen: now.Add(-time.Minute),
+				MACHistory: []MACSighting{{MAC: "00:11:22:33:44:55", Since: long}}},
+			{Address: "fe80::2%eth0", MAC: "00:11:22:33:44:66", FirstSeen: long, LastSeen: now.Add(-time.Hour)},
+		},
+		Routers: []RouterInfo{
+			{Address: "fe80::a", LastSeen: now.Add(-time.Minute)},
+			{Address: "fe80::b", LastSeen: now.Add(-time.Hour)},
+		},
+	}
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RestoreState(st, now)
+
+	peers := stats.GetStats()
+	if len(peers) != 1 || peers[0].Address != "fe80::1%eth0" || !peers[0].FirstSeen.Equal(long) || peers[0].Total != 0 {
+		t.Fatalf("peers = %+v, want the recent peer back with no messages", peers)
+	}
+	if len(stats.GetRouters()) != 1 || len(stats.GetGoneRouters()) != 1 {
+		t.Errorf("routers = %+v, gone = %+v", stats.GetRouters(), stats.GetGoneRouters())
+	}
+
+	// The old peer keeps its first-seen time when it returns, and its MAC
+	// history doesn't grow for the same MAC.
+	stats.RecordMessage("fe80::2%eth0", "neighbor_solicitation")
+	stats.RecordMAC("fe80::2%eth0", "00:11:22:33:44:66")
+	for _, p := range stats.GetStats() {
+		if p.Address == "fe80::2%eth0" && (!p.FirstSeen.Equal(long) || len(p.MACHistory) != 0) {
+			t.Errorf("returning peer = %+v", p)
+		}
+	}
+
+	// Pruned peers are remembered and saved with the rest.
+	stats.Prune()
+	if got := stats.PersistentState(now).Peers; len(got) != 2 {
+		t.Errorf("persistent peers = %+v, want both", got)
+	}
+}
@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// BuildInfo identifies the running binary.
+type BuildInfo struct {
+	Version   string `json:"version"` // module version, "(devel)" for local builds
+	Revision  string `json:"revision,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // built from a dirty tree
+	GoVersion string `json:"go_version"`
+}
+
+// ReadBuildInfo returns the version and VCS revision embedded by the Go
+// toolchain.
+func ReadBuildInfo() BuildInfo {
+	b := BuildInfo{Version: "(devel)", GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	if info.Main.Version != "" {
+		b.Version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Revision = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+// Status describes how this instance was built, started and configured, so
+// remote troubleshooting can start from facts. main fills it in with
+// SetStatus; NDPStats only stores it for the API and the TUI.
+type Status struct {
+	Build     BuildInfo     `json:"build"`
+	Started   time.Time     `json:"started"`
+	Uptime    time.Duration `json:"uptime"`  // set by NDPStats.Status
+	Capture   string        `json:"capture"` // backend in use, e.g. CapturePacket
+	Interface string        `json:"interface,omitempty"`
+	PcapFile  string        `json:"pcap_file,omitempty"`
+	Mirror    bool          `json:"mirror,omitempty"` // --mirror: the input is a mirror port
+	Node      string        `json:"node,omitempty"`   // --node-name, labeling everything reported
+	Window    time.Duration `json:"window"`
+	// Sinks names the outputs events and alerts go to.
+	Sinks []string `json:"sinks"`
+	// RulePacks are the loaded detection rule packs.
+	RulePacks []RulePackInfo `json:"rule_packs,omitempty"`
+	// ConfigPath is the --config file, "" without one.
+	ConfigPath string `json:"config_path,omitempty"`
+	// Prune is when the last prune ran and how long it took; set by
+	// NDPStats.Status.
+	Prune PruneStats `json:"prune"`
+	// Config is the active configuration with secrets redacted (see
+	// Config.Redacted).
+	Config map[string]any `json:"config,omitempty"`
+}
+
+// SetStatus records how this instance runs.
+func (s *NDPStats) SetStatus(st Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = st
+}
+
+// Status returns what SetStatus recorded, with the uptime filled in.
+func (s *NDPStats) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st := s.status
+	if !st.Started.IsZero() {
+		st.Uptime = time.Since(st.Started).Truncate(time.Second)
+	}
+	st.Prune = s.prunes
+	return st
+}
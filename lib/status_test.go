@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigStatus(t *testing.T) {
+	cfg, err := LoadConfig(writeTempConfig(t, `
+sinks:
+  ndjson:
+    path: events.ndjson
+  exec:
+    command: /usr/bin/curl
+    args: ["-H", "Authorization: Bearer s3cret", "https://hooks.example.net/{{.Category}}"]
+rules:
+  packs: [home]
+mac_location:
+  switches:
+    - name: sw1
+      snmp: 192.0.2.1
+      community: c0mmunity
+api:
+  listen: 127.0.0.1:9311
+  admin_token: t0ken
+enrichment:
+  reputation:
+    - name: intel
+      command: /usr/local/bin/lookup
+      args: ["--api-key", "r3putation", "{{.Address}}"]
+actions:
+  - name: scan
+    command: /usr/bin/nmap
+    args: ["--script-args", "creds=4ction", "{{.Address}}"]
+anonymize:
+  mode: hash
+  key: k3y
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(cfg.Sinks.Enabled(), ","); got != "ndjson,exec" {
+		t.Errorf("enabled sinks = %s, want ndjson,exec", got)
+	}
+	if packs := cfg.RulePacks(); len(packs) != 1 || packs[0].Name != "home" || packs[0].Version != "1" || packs[0].Source != "builtin" || packs[0].Rules == 0 {
+		t.Errorf("rule packs = %+v", packs)
+	}
+
+	red := cfg.Redacted()
+	data, _ := json.Marshal(red)
+	if strings.Contains(string(data), "s3cret") || strings.Contains(string(data), "hooks.example.net") || strings.Contains(string(data), "c0mmunity") || strings.Contains(string(data), "t0ken") || strings.Contains(string(data), "k3y") ||
+		strings.Contains(string(data), "r3putation") || strings.Contains(string(data), "4ction") {
+		t.Errorf("redacted config leaks secrets: %s", data)
+	}
+	if !strings.Contains(string(data), `"command":"/usr/bin/curl"`) || !strings.Contains(string(data), `"path":"events.ndjson"`) {
+		t.Errorf("redacted config lost settings: %s", data)
+	}
+	if cfg.Sinks.Exec.Args[1] != "Authorization: Bearer s3cret" {
+		t.Error("Redacted modified the config itself")
+	}
+
+	stats := NewNDPStats(5 * time.Minute)
+	stats.SetStatus(Status{
+		Build:     ReadBuildInfo(),
+		Started:   time.Now().Add(-time.Hour),
+		Capture:   CapturePacket,
+		Sinks:     cfg.Sinks.Enabled(),
+		RulePacks: cfg.RulePacks(),
+		Config:    red,
+	})
+	rec := httptest.NewRecorder()
+	APIHandler(stats).ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/status", nil))
+	var st Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("status: %v\n%s", err, rec.Body.String())
+	}
+	if st.Build.GoVersion == "" || st.Capture != CapturePacket || st.Uptime < time.Hour || len(st.RulePacks) != 1 {
+		t.Errorf("status = %+v", st)
+	}
+}
@@ -0,0 +1,401 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// maxMACHistory is how many MACs a peer's history keeps.
+	maxMACHistory = 8
+	// storyTimelineLen is how many of a host's messages its story lists.
+	storyTimelineLen = 50
+	// neighborTimeout bounds reading the kernel neighbor cache.
+	neighborTimeout = 2 * time.Second
+	// neighborCacheAge is how long a neighborCache reuses a reading.
+	neighborCacheAge = 5 * time.Second
+)
+
+// MACSighting is a MAC a peer started using at Since.
+type MACSighting struct {
+	MAC   string    `json:"mac"`
+	Since time.Time `json:"since"`
+}
+
+// StoryMessage is one message in a host's timeline.
+type StoryMessage struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`    // ndpKind
+	Address string    `json:"address"` // which of the host's addresses sent it
+}
+
+// KernelNeighbor is an entry of the kernel's IPv6 neighbor cache.
+type KernelNeighbor struct {
+	Address   string `json:"address"`
+	Interface string `json:"interface,omitempty"`
+	MAC       string `json:"mac,omitempty"`
+	// State is the NUD state, e.g. REACHABLE, STALE or FAILED.
+	State  string `json:"state"`
+	Router bool   `json:"router,omitempty"`
+}
+
+// PeerStory is everything known about one host, for investigating it on a
+// single screen: the host is the selected address plus every other address
+// seen with the same MAC.
+type PeerStory struct {
+	Address string `json:"address"` // the address the story was asked for
+	MAC     string `json:"mac,omitempty"`
+	Enrichment
+	// Addresses are the host's peers, the selected one first.
+	Addresses []PeerSummary `json:"addresses"`
+	// MACHistory merges the MAC histories of all the addresses, oldest first.
+	MACHistory []MACSighting `json:"mac_history,omitempty"`
+	// Groups are the multicast groups any of the addresses joined.
+	Groups []string `json:"groups,omitempty"`
+	// Timeline is the host's most recent messages within the window, newest first.
+	Timeline []StoryMessage `json:"timeline,omitempty"`
+	// Alerts are the stored alerts about any of the addresses or
+	// mentioning the MAC, newest first.
+	Alerts []Alert `json:"alerts,omitempty"`
+	// Neighbors is the kernel neighbor cache's view of the host; the
+	// caller fills it in (see KernelNeighbors).
+	Neighbors []KernelNeighbor `json:"kernel_neighbors,omitempty"`
+}
+
+// Story builds the story of the host using address. It reports false if
+// address isn't a known peer.
+func (s *NDPStats) Story(address string) (PeerStory, bool) {
+	address = canonicalAddr(address)
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	peers := s.summariesLocked(now)
+	i := slices.IndexFunc(peers, func(p PeerSummary) bool { return p.Address == address })
+	if i < 0 {
+		return PeerStory{}, false
+	}
+	self := peers[i]
+	story := PeerStory{Address: address, MAC: self.MAC, Enrichment: self.Enrichment}
+	story.Addresses = append(story.Addresses, self)
+	for _, p := range peers {
+		if p.Address != address && self.MAC != "" && p.MAC == self.MAC {
+			story.Addresses = append(story.Addresses, p)
+		}
+	}
+
+	addrs := make(map[string]bool, len(story.Addresses))
+	groups := make(map[string]bool)
+	for _, p := range story.Addresses {
+		addrs[p.Address] = true
+		for _, g := range p.Groups {
+			groups[g] = true
+		}
+		story.MACHistory = append(story.MACHistory, p.MACHistory...)
+		if story.Hostname == "" {
+			story.Hostname = p.Hostname
+		}
+		if story.Vendor == "" {
+			story.Vendor = p.Vendor
+		}
+	}
+	story.MACHistory = mergeMACHistory(story.MACHistory)
+	for g := range groups {
+		story.Groups = append(story.Groups, g)
+	}
+	sort.Strings(story.Groups)
+
+	cutoff := now.Add(-s.window)
+	for addr := range addrs {
+		peer, ok := s.peers[addr]
+		if !ok {
+			continue
+		}
+		for kind, times := range peer.Messages {
+			for _, t := range times {
+				if t.After(cutoff) {
+					story.Timeline = append(story.Timeline, StoryMessage{Time: t, Kind: kind, Address: addr})
+				}
+			}
+		}
+	}
+	sort.Slice(story.Timeline, func(i, j int) bool { return story.Timeline[i].Time.After(story.Timeline[j].Time) })
+	if len(story.Timeline) > storyTimelineLen {
+		story.Timeline = story.Timeline[:storyTimelineLen]
+	}
+
+	for _, a := range s.alertsLocked() {
+		if addrs[a.Source] || (self.MAC != "" && strings.Contains(strings.ToLower(a.Message), self.MAC)) {
+			story.Alerts = append(story.Alerts, a)
+		}
+	}
+	return story, true
+}
+
+// mergeMACHistory sorts sightings by time and drops those repeating the MAC
+// before them.
+func mergeMACHistory(h []MACSighting) []MACSighting {
+	sort.SliceStable(h, func(i, j int) bool { return h[i].Since.Before(h[j].Since) })
+	out := h[:0]
+	for _, m := range h {
+		if len(out) == 0 || out[len(out)-1].MAC != m.MAC {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// NeighborsOf returns the entries of neighbors about the story's host: one
+// of its addresses or its MAC.
+func (st PeerStory) NeighborsOf(neighbors []KernelNeighbor) []KernelNeighbor {
+	var out []KernelNeighbor
+	for _, n := range neighbors {
+		mine := st.MAC != "" && n.MAC == st.MAC
+		for _, p := range st.Addresses {
+			if n.Address == p.Address {
+				mine = true
+			}
+		}
+		if mine {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// KernelNeighbors reads the kernel's IPv6 neighbor cache with ip(8), which
+// only exists on Linux.
+func KernelNeighbors(ctx context.Context) ([]KernelNeighbor, error) {
+	ctx, cancel := context.WithTimeout(ctx, neighborTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ip", "-6", "neigh", "show").Output()
+	if err != nil {
+		return nil, fmt.Errorf("kernel neighbors: %w", err)
+	}
+	return parseIPNeigh(out), nil
+}
+
+// neighborCache rate-limits KernelNeighbors for callers that read it on
+// demand, such as API clients: they share one reading, failed or not, for
+// neighborCacheAge. Safe for concurrent use.
+type neighborCache struct {
+	mu        sync.Mutex
+	read      time.Time
+	neighbors []KernelNeighbor
+	err       error
+}
+
+// get returns the cached kernel neighbors, reading them again if the
+// cached ones are older than neighborCacheAge. The reading outlives ctx's
+// cancellation, since other callers share it.
+func (c *neighborCache) get(ctx context.Context) ([]KernelNeighbor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); now.Sub(c.read) >= neighborCacheAge {
+		c.neighbors, c.err = KernelNeighbors(context.WithoutCancel(ctx))
+		c.read = now
+	}
+	return c.neighbors, c.err
+}
+
+// parseIPNeigh parses `ip -6 neigh show` output, one entry per line:
+//
+//	fe80::1 dev eth0 lladdr 00:11:22:33:44:55 router REACHABLE
+//
+// Link-local addresses get the interface as their zone, as peers have.
+func parseIPNeigh(out []byte) []KernelNeighbor {
+	var neighbors []KernelNeighbor
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		n := KernelNeighbor{Address: fields[0]}
+		var states []string
+		for i := 1; i < len(fields); i++ {
+			switch f := fields[i]; {
+			case f == "dev" && i+1 < len(fields):
+				i++
+				n.Interface = fields[i]
+			case f == "lladdr" && i+1 < len(fields):
+				i++
+				n.MAC = strings.ToLower(fields[i])
+			case f == "router":
+				n.Router = true
+			case f == strings.ToUpper(f):
+				states = append(states, f)
+			}
+		}
+		n.State = strings.Join(states, ",")
+		if ip, err := netip.ParseAddr(n.Address); err == nil && ip.IsLinkLocalUnicast() && n.Interface != "" {
+			n.Address = ip.WithZone(n.Interface).String()
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors
+}
+
+// storyNeighborsMsg carries the kernel neighbor cache read for a story.
+type storyNeighborsMsg struct {
+	address   string
+	neighbors []KernelNeighbor
+	err       error
+}
+
+// openStory shows the story of the host using p. Stories are built from
+// this instance's live stats, so time travel and other segments have none.
+func (m *Model) openStory(p *PeerSummary) tea.Cmd {
+	if p == nil {
+		return nil
+	}
+	if m.segment != nil || m.travelling {
+		m.setStatus("The story view only covers this instance's live stats")
+		return nil
+	}
+	story, ok := m.stats.Story(p.Address)
+	if !ok {
+		m.setStatus(p.Address + " is no longer a known peer")
+		return nil
+	}
+	m.story, m.storyErr = &story, nil
+	m.storyFrom = m.activeView
+	m.activeView = "story"
+	address := story.Address
+	return func() tea.Msg {
+		neighbors, err := KernelNeighbors(context.Background())
+		return storyNeighborsMsg{address: address, neighbors: neighbors, err: err}
+	}
+}
+
+// refreshStory rebuilds the open story from the stats, keeping the kernel
+// neighbor cache entries already read.
+func (m *Model) refreshStory() {
+	if m.story == nil {
+		return
+	}
+	if story, ok := m.stats.Story(m.story.Address); ok {
+		story.Neighbors = m.story.Neighbors
+		m.story = &story
+	}
+}
+
+// applyStoryNeighbors fills in the kernel neighbor cache if msg is for the
+// open story.
+func (m *Model) applyStoryNeighbors(msg storyNeighborsMsg) {
+	if m.story == nil || m.story.Address != msg.address {
+		return
+	}
+	m.storyErr = msg.err
+	m.story.Neighbors = m.story.NeighborsOf(msg.neighbors)
+}
+
+// renderStory draws everything known about the host in m.story.
+func (m Model) renderStory() string {
+	st := m.story
+	if st == nil {
+		return "No peer selected.\n"
+	}
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Host Story: " + st.Address))
+	b.WriteString("\n\n")
+	line := func(label, value string) {
+		if value != "" {
+			b.WriteString(fmt.Sprintf("  %s  %s\n", detailLabel.Render(label), value))
+		}
+	}
+	section := func(title string) {
+		b.WriteString(fmt.Sprintf("\n  %s\n", detailLabel.Render(title)))
+	}
+
+	line("MAC:", st.MAC)
+	line("Vendor:", st.Vendor)
+	line("Hostname:", st.Hostname)
+	line("Name:", st.Name)
+	line("Device Type:", st.DeviceType)
+
+	section(fmt.Sprintf("Addresses (%d):", len(st.Addresses)))
+	for _, p := range st.Addresses {
+		iface := p.Interface
+		if iface == "" {
+			iface = "-"
+		}
+		text := fmt.Sprintf("    %-40s %-10s %5d msgs  first %s  last %s",
+			p.Address, iface, p.Total, formatTimestamp(p.FirstSeen), formatTimestamp(p.LastSeen))
+		if p.Stale {
+			text = staleStyle.Render(text)
+		}
+		b.WriteString(text + "\n")
+	}
+
+	if len(st.MACHistory) > 1 {
+		section("MAC History:")
+		for _, h := range st.MACHistory {
+			b.WriteString(fmt.Sprintf("    %-17s since %s\n", h.MAC, formatTimestamp(h.Since)))
+		}
+	}
+
+	if len(st.Groups) > 0 {
+		section("Multicast Groups:")
+		for _, g := range st.Groups {
+			b.WriteString(strings.TrimRight(fmt.Sprintf("    %-40s %s", g, multicastLabel(g, m.multicastLabels)), " ") + "\n")
+		}
+	}
+
+	section("Kernel Neighbor Cache:")
+	switch {
+	case m.storyErr != nil:
+		b.WriteString("    " + staleStyle.Render(m.storyErr.Error()) + "\n")
+	case len(st.Neighbors) == 0:
+		b.WriteString("    " + staleStyle.Render("no entries") + "\n")
+	}
+	for _, n := range st.Neighbors {
+		text := fmt.Sprintf("    %-40s %-17s %s", n.Address, n.MAC, n.State)
+		if n.Router {
+			text += " (router)"
+		}
+		if strings.Contains(n.State, "FAILED") || strings.Contains(n.State, "INCOMPLETE") {
+			text = warnStyle.Render(text)
+		}
+		b.WriteString(text + "\n")
+	}
+
+	if len(st.Alerts) > 0 {
+		section(fmt.Sprintf("Alerts (%d):", len(st.Alerts)))
+		for _, a := range st.Alerts {
+			text := fmt.Sprintf("    %s %-7s %s: %s", a.Time.Format("15:04:05"), strings.ToUpper(a.Severity.String()), a.Category, a.Message)
+			if m.width > 4 {
+				text = truncate(text, m.width-4)
+			}
+			b.WriteString(text + "\n")
+		}
+	}
+
+	if len(st.Timeline) > 0 {
+		section("Recent Messages:")
+		for _, msg := range st.Timeline {
+			name := msgShortNames[msg.Kind]
+			if name == "" {
+				name = msg.Kind
+			}
+			text := fmt.Sprintf("    %s %-4s", msg.Time.Format("15:04:05.000"), name)
+			if len(st.Addresses) > 1 {
+				text += " " + msg.Address
+			}
+			b.WriteString(text + "\n")
+		}
+	}
+	return b.String()
+}
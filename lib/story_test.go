@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStory(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	stats.RecordMessage("fe80::2%eth0", "neighbor_solicitation")
+	stats.RecordMAC("fe80::2%eth0", "00:11:22:33:44:55")
+	stats.RecordMAC("fe80::2%eth0", "00:11:22:33:44:66")
+	stats.RecordMessage("2001:db8::2", "neighbor_advertisement")
+	stats.RecordMAC("2001:db8::2", "00:11:22:33:44:66")
+	stats.RecordMLDMembership("2001:db8::2", "ff02::fb")
+	stats.RecordMessage("fe80::9%eth0", "router_solicitation")
+	stats.RecordMAC("fe80::9%eth0", "00:11:22:33:44:99")
+	stats.RecordAlert(Alert{Category: "na_spoof", Source: "2001:db8::2", Message: "spoofed"})
+	stats.RecordAlert(Alert{Category: "mac_moved", Source: "fe80::7", Message: "00:11:22:33:44:66 moved ports"})
+	stats.RecordAlert(Alert{Category: "other", Source: "fe80::9%eth0", Message: "unrelated"})
+
+	story, ok := stats.Story("fe80::2%eth0")
+	if !ok {
+		t.Fatal("no story for a known peer")
+	}
+	if len(story.Addresses) != 2 || story.Addresses[0].Address != "fe80::2%eth0" || story.Addresses[1].Address != "2001:db8::2" {
+		t.Errorf("addresses = %+v", story.Addresses)
+	}
+	if len(story.MACHistory) != 2 || story.MACHistory[0].MAC != "00:11:22:33:44:55" || story.MACHistory[1].MAC != "00:11:22:33:44:66" {
+		t.Errorf("MAC history = %+v", story.MACHistory)
+	}
+	if len(story.Groups) != 1 || len(story.Timeline) != 2 || story.Timeline[0].Kind != "neighbor_advertisement" {
+		t.Errorf("groups = %v, timeline = %+v", story.Groups, story.Timeline)
+	}
+	if len(story.Alerts) != 2 {
+		t.Errorf("alerts = %+v, want na_spoof and mac_moved", story.Alerts)
+	}
+	if _, ok := stats.Story("2001:db8::99"); ok {
+		t.Error("story for an unknown peer")
+	}
+}
+
+func TestParseIPNeigh(t *testing.T) {
+	out := []byte(`fe80::1 dev eth0 lladdr 00:11:22:33:44:55 router REACHABLE
+2001:db8::2 dev eth0 lladdr 00:11:22:33:44:66 STALE
+2001:db8::3 dev eth1 FAILED
+`)
+	got := parseIPNeigh(out)
+	if len(got) != 3 {
+		t.Fatalf("got %d entries, want 3", len(got))
+	}
+	if n := got[0]; n.Address != "fe80::1%eth0" || n.MAC != "00:11:22:33:44:55" || !n.Router || n.State != "REACHABLE" {
+		t.Errorf("router entry = %+v", n)
+	}
+	if n := got[2]; n.Interface != "eth1" || n.MAC != "" || n.State != "FAILED" {
+		t.Errorf("failed entry = %+v", n)
+	}
+
+	story := PeerStory{MAC: "00:11:22:33:44:66", Addresses: []PeerSummary{{Address: "fe80::1%eth0"}}}
+	if mine := story.NeighborsOf(got); len(mine) != 2 {
+		t.Errorf("NeighborsOf = %+v, want the address and the MAC entries", mine)
+	}
+}
+
+func TestNeighborCache(t *testing.T) {
+	cached := []KernelNeighbor{{Address: "fe80::1%eth0", State: "REACHABLE"}}
+	c := &neighborCache{read: time.Now(), neighbors: cached}
+	got, err := c.get(context.Background())
+	if err != nil || len(got) != 1 || got[0].Address != "fe80::1%eth0" {
+		t.Errorf("get = %+v, %v; want the cached reading", got, err)
+	}
+}
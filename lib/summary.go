@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"slices"
+	"time"
+)
+
+// MessageSummary is the network-wide traffic of one message type within the
+// sliding window.
+type MessageSummary struct {
+	Kind    string  `json:"kind"`
+	Count   int     `json:"count"`   // messages in the window
+	Rate    float64 `json:"rate"`    // messages per second over the window
+	Senders int     `json:"senders"` // peers that sent at least one
+	// Bytes is the ICMPv6 payload bytes in the window; ByteRate is per second.
+	Bytes    int     `json:"bytes"`
+	ByteRate float64 `json:"byte_rate"`
+}
+
+// NetworkSummary is the network-wide traffic within the sliding window,
+// over all peers (ignored peers excluded) rather than per peer.
+type NetworkSummary struct {
+	Window time.Duration    `json:"window"`
+	Peers  int              `json:"peers"`
+	Total  int              `json:"total"` // messages of every type
+	Rate   float64          `json:"rate"`  // messages per second over the window
+	Types  []MessageSummary `json:"types"` // every known type in column order, then any others
+	// Bytes is the ICMPv6 payload bytes of every type; ByteRate is per second.
+	Bytes    int     `json:"bytes"`
+	ByteRate float64 `json:"byte_rate"`
+}
+
+// Type returns the summary for kind, zero if none was seen.
+func (n NetworkSummary) Type(kind string) MessageSummary {
+	for _, t := range n.Types {
+		if t.Kind == kind {
+			return t
+		}
+	}
+	return MessageSummary{Kind: kind}
+}
+
+// SummarizeMessages totals the per-type counts and bytes of peers. Rates are per
+// second over window; they are zero if window is.
+func SummarizeMessages(peers []PeerSummary, window time.Duration) NetworkSummary {
+	counts := make(map[string]int)
+	senders := make(map[string]int)
+	bytes := make(map[string]int)
+	n := NetworkSummary{Window: window, Peers: len(peers)}
+	for _, p := range peers {
+		for kind, c := range p.Counts {
+			counts[kind] += c
+			if c > 0 {
+				senders[kind]++
+			}
+		}
+		for kind, b := range p.Bytes {
+			bytes[kind] += b
+		}
+		n.Total += p.Total
+		n.Bytes += p.TotalBytes
+	}
+
+	kinds := slices.Clone(msgColumnOrder)
+	var others []string
+	for kind := range counts {
+		if !slices.Contains(msgColumnOrder, kind) {
+			others = append(others, kind)
+		}
+	}
+	slices.Sort(others)
+	kinds = append(kinds, others...)
+
+	rate := func(c int) float64 {
+		if window <= 0 {
+			return 0
+		}
+		return float64(c) / window.Seconds()
+	}
+	n.Rate = rate(n.Total)
+	n.ByteRate = rate(n.Bytes)
+	n.Types = make([]MessageSummary, 0, len(kinds))
+	for _, kind := range kinds {
+		n.Types = append(n.Types, MessageSummary{
+			Kind: kind, Count: counts[kind], Rate: rate(counts[kind]), Senders: senders[kind],
+			Bytes: bytes[kind], ByteRate: rate(bytes[kind]),
+		})
+	}
+	return n
+}
+
+// MessageSummary returns the network-wide per-type counts and rates for the
+// window.
+func (s *NDPStats) MessageSummary() NetworkSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return SummarizeMessages(s.summariesLocked(s.now()), s.window)
+}
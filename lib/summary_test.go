@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageSummary(t *testing.T) {
+	stats := NewNDPStats(10 * time.Second)
+	for range 4 {
+		stats.RecordMessage("fe80::1", "neighbor_solicitation")
+	}
+	stats.RecordMessage("fe80::2", "neighbor_solicitation")
+	stats.RecordSize("fe80::2", "neighbor_solicitation", 32)
+	stats.RecordMessage("fe80::2", "router_advertisement")
+	stats.RecordSize("fe80::2", "router_advertisement", 88)
+	stats.RecordMessage("fe80::3", "mld_report")
+	f, err := ParseFilter(`addr == "fe80::3"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats.SetIgnore([]*Filter{f})
+
+	sum := stats.MessageSummary()
+	if sum.Peers != 2 || sum.Total != 6 || sum.Rate != 0.6 {
+		t.Errorf("summary = %d peers, %d messages, %v/s; want 2, 6, 0.6/s", sum.Peers, sum.Total, sum.Rate)
+	}
+	if ns := sum.Type("neighbor_solicitation"); ns.Count != 5 || ns.Senders != 2 || ns.Rate != 0.5 {
+		t.Errorf("NS = %+v, want 5 from 2 senders at 0.5/s", ns)
+	}
+	if sum.Bytes != 120 || sum.ByteRate != 12 || sum.Type("router_advertisement").Bytes != 88 {
+		t.Errorf("bytes = %d at %v/s (RA %+v), want 120 at 12/s with 88 from RAs", sum.Bytes, sum.ByteRate, sum.Type("router_advertisement"))
+	}
+	if mr := sum.Type("mld_report"); mr.Count != 0 {
+		t.Errorf("MR = %+v, want the ignored peer left out", mr)
+	}
+	if len(sum.Types) != len(msgColumnOrder) || sum.Types[0].Kind != "router_solicitation" {
+		t.Errorf("types = %+v, want every type in column order", sum.Types)
+	}
+
+	if snap := stats.Snapshot(); snap.Summary.Total != 6 {
+		t.Errorf("snapshot summary total = %d, want 6", snap.Summary.Total)
+	}
+	if line := networkRateLine(sum); !strings.Contains(line, "0.60 msg/s, 12 B/s") || !strings.Contains(line, "NS 0.50") {
+		t.Errorf("rate line = %q", line)
+	}
+}
@@ -0,0 +1,174 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timelineSpan is how much history the time travel timeline shows.
+const timelineSpan = 24 * time.Hour
+
+// sparkBlocks draw a bucket's message count relative to the busiest one.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// TimelineMark is a recorded alert placed on the timeline.
+type TimelineMark struct {
+	Time     time.Time
+	Severity Severity
+	Category string
+	Source   string
+}
+
+// TimelineBucket is one step of the timeline: the messages counted in it
+// and the alerts raised in it.
+type TimelineBucket struct {
+	Start time.Time
+	Count int
+	Marks []TimelineMark
+}
+
+// Worst returns the highest severity among the bucket's alerts, and false
+// if it has none.
+func (b TimelineBucket) Worst() (Severity, bool) {
+	if len(b.Marks) == 0 {
+		return 0, false
+	}
+	worst := b.Marks[0].Severity
+	for _, m := range b.Marks[1:] {
+		worst = max(worst, m.Severity)
+	}
+	return worst, true
+}
+
+// Timeline is message traffic over time from the history counters, with the
+// recorded alerts as annotations.
+type Timeline struct {
+	From    time.Time
+	Step    time.Duration
+	Buckets []TimelineBucket
+}
+
+// Bucket returns the index of the bucket holding t, or -1 if t is outside
+// the timeline.
+func (tl Timeline) Bucket(t time.Time) int {
+	if tl.Step <= 0 || t.Before(tl.From) {
+		return -1
+	}
+	i := int(t.Sub(tl.From) / tl.Step)
+	if i >= len(tl.Buckets) {
+		return -1
+	}
+	return i
+}
+
+// Timeline splits [from, to) into n buckets and fills them with the message
+// counters and alerts recorded in each. A counter already rolled up to a
+// coarser resolution than the step counts in the bucket its start falls in.
+func (h *HistoryDB) Timeline(from, to time.Time, n int) (Timeline, error) {
+	if n <= 0 || !to.After(from) {
+		return Timeline{}, fmt.Errorf("timeline: empty range")
+	}
+	tl := Timeline{From: from, Step: max(to.Sub(from)/time.Duration(n), 1), Buckets: make([]TimelineBucket, n)}
+	for i := range tl.Buckets {
+		tl.Buckets[i].Start = from.Add(time.Duration(i) * tl.Step)
+	}
+	lo, hi, step := from.UnixNano(), to.UnixNano(), int64(tl.Step)
+
+	rows, err := h.db.Query(`SELECT (ts - ?) / ?, SUM(count) FROM counters WHERE ts >= ? AND ts < ? GROUP BY 1`,
+		lo, step, lo, hi)
+	if err != nil {
+		return tl, fmt.Errorf("timeline counters: %w", err)
+	}
+	for rows.Next() {
+		var i int64
+		var count int
+		if err := rows.Scan(&i, &count); err != nil {
+			rows.Close()
+			return tl, fmt.Errorf("timeline counters: %w", err)
+		}
+		if i >= 0 && i < int64(n) {
+			tl.Buckets[i].Count += count
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return tl, fmt.Errorf("timeline counters: %w", err)
+	}
+
+	rows, err = h.db.Query(`SELECT ts, severity, category, source FROM alerts WHERE ts >= ? AND ts < ? ORDER BY ts`, lo, hi)
+	if err != nil {
+		return tl, fmt.Errorf("timeline alerts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ts int64
+		var sev string
+		var m TimelineMark
+		if err := rows.Scan(&ts, &sev, &m.Category, &m.Source); err != nil {
+			return tl, fmt.Errorf("timeline alerts: %w", err)
+		}
+		m.Time = time.Unix(0, ts)
+		_ = m.Severity.UnmarshalText([]byte(sev)) // unknown severities read as info
+		if i := tl.Bucket(m.Time); i >= 0 {
+			tl.Buckets[i].Marks = append(tl.Buckets[i].Marks, m)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return tl, fmt.Errorf("timeline alerts: %w", err)
+	}
+	return tl, nil
+}
+
+// timelineRange returns the span of history the timeline shows around at:
+// the last timelineSpan, or one centred on at if at is older than that.
+func timelineRange(at, now time.Time) (time.Time, time.Time) {
+	if at.Before(now.Add(-timelineSpan)) {
+		return at.Add(-timelineSpan / 2), at.Add(timelineSpan / 2)
+	}
+	return now.Add(-timelineSpan), now
+}
+
+// renderTimeline draws tl as a sparkline of message counts with a marker
+// row under it: an alert marker, colored by the worst severity, under every
+// bucket with alerts, and ▲ under the bucket holding cursor. The line after
+// lists the alerts in the cursor's bucket.
+func renderTimeline(tl Timeline, cursor time.Time) string {
+	peak := 0
+	for _, b := range tl.Buckets {
+		peak = max(peak, b.Count)
+	}
+	at := tl.Bucket(cursor)
+	var spark, marks strings.Builder
+	for i, b := range tl.Buckets {
+		switch {
+		case b.Count == 0:
+			spark.WriteRune(' ')
+		default:
+			spark.WriteRune(sparkBlocks[(b.Count*(len(sparkBlocks)-1)+peak-1)/peak])
+		}
+		sev, ok := b.Worst()
+		switch {
+		case i == at:
+			marks.WriteString(headerStyle.Render("▲"))
+		case ok:
+			marks.WriteString(toastStyles[sev].Render("!"))
+		default:
+			marks.WriteRune(' ')
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(detailLabel.Render(fmt.Sprintf("%s – %s, peak %d per %s",
+		tl.From.Format("01-02 15:04"), tl.From.Add(tl.Step*time.Duration(len(tl.Buckets))).Format("01-02 15:04"),
+		peak, formatDuration(tl.Step))))
+	b.WriteString("\n" + spark.String() + "\n" + marks.String() + "\n")
+	if at >= 0 && len(tl.Buckets[at].Marks) > 0 {
+		var texts []string
+		for _, m := range tl.Buckets[at].Marks {
+			texts = append(texts, toastStyles[m.Severity].Render(fmt.Sprintf("%s %s %s", m.Time.Format("15:04:05"), m.Category, m.Source)))
+		}
+		b.WriteString("Alerts here: " + strings.Join(texts, ", ") + "\n")
+	}
+	return b.String()
+}
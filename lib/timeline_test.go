@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryTimeline(t *testing.T) {
+	db, err := OpenHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	from := time.Date(2024, 5, 10, 2, 0, 0, 0, time.UTC)
+	spike := from.Add(13 * time.Minute)
+	err = db.RecordCounters([]CounterBucket{
+		{Start: from.Add(time.Minute), Resolution: time.Second, Kind: "neighbor_solicitation", Count: 5},
+		{Start: spike, Resolution: time.Second, Kind: "router_advertisement", Count: 400},
+		{Start: spike.Add(time.Second), Resolution: time.Second, Kind: "neighbor_solicitation", Count: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Record(Snapshot{Taken: spike.Add(time.Minute), Alerts: []Alert{
+		{Time: spike.Add(20 * time.Second), Severity: SeverityCritical, Category: "rogue_ra", Source: "fe80::bad"},
+		{Time: spike.Add(30 * time.Second), Severity: SeverityWarning, Category: "ra_flood", Source: "fe80::bad"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tl, err := db.Timeline(from, from.Add(time.Hour), 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tl.Step != time.Minute || len(tl.Buckets) != 60 {
+		t.Fatalf("step %s, %d buckets; want 1m, 60", tl.Step, len(tl.Buckets))
+	}
+	b := tl.Buckets[13]
+	if b.Count != 500 || len(b.Marks) != 2 {
+		t.Fatalf("bucket 02:13 = %+v, want 500 messages and 2 alerts", b)
+	}
+	if sev, ok := b.Worst(); !ok || sev != SeverityCritical {
+		t.Errorf("worst = %v, %v; want critical", sev, ok)
+	}
+	if tl.Buckets[1].Count != 5 || tl.Buckets[2].Count != 0 {
+		t.Errorf("buckets 1, 2 = %d, %d; want 5, 0", tl.Buckets[1].Count, tl.Buckets[2].Count)
+	}
+
+	out := renderTimeline(tl, spike)
+	if !strings.Contains(out, "█") || !strings.Contains(out, "▲") || !strings.Contains(out, "rogue_ra") {
+		t.Errorf("rendered timeline lacks the spike, cursor or alert:\n%s", out)
+	}
+}
@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// HistorySample is the peer and router tables as recorded at one moment.
+type HistorySample struct {
+	Taken   time.Time
+	Peers   []PeerSummary // ordered by total, like NDPStats.GetStats
+	Routers []RouterInfo  // ordered by address
+}
+
+// PrevSample returns the time of the newest sample strictly before t.
+func (h *HistoryDB) PrevSample(t time.Time) (time.Time, bool, error) {
+	return h.sampleTime(`SELECT MAX(ts) FROM samples WHERE ts < ?`, t)
+}
+
+// NextSample returns the time of the oldest sample strictly after t.
+func (h *HistoryDB) NextSample(t time.Time) (time.Time, bool, error) {
+	return h.sampleTime(`SELECT MIN(ts) FROM samples WHERE ts > ?`, t)
+}
+
+func (h *HistoryDB) sampleTime(query string, t time.Time) (time.Time, bool, error) {
+	var ts sql.NullInt64
+	if err := h.db.QueryRow(query, t.UnixNano()).Scan(&ts); err != nil {
+		return time.Time{}, false, fmt.Errorf("find history sample: %w", err)
+	}
+	if !ts.Valid {
+		return time.Time{}, false, nil
+	}
+	return time.Unix(0, ts.Int64), true, nil
+}
+
+// LoadSample reads the sample recorded at exactly taken (as returned by
+// PrevSample or NextSample).
+func (h *HistoryDB) LoadSample(taken time.Time) (HistorySample, error) {
+	sample := HistorySample{Taken: taken}
+	ts := taken.UnixNano()
+
+	rows, err := h.db.Query(`SELECT address, mac, iface, first_seen, last_seen, total, counts, groups, os, hostname, vendor, name, stale,
+		device_type, trusted, tags
+		FROM peers WHERE ts = ?`, ts)
+	if err != nil {
+		return sample, fmt.Errorf("load history peers: %w", err)
+	}
+	for rows.Next() {
+		var (
+			p              PeerSummary
+			first, last    int64
+			counts, groups string
+			tags           string
+		)
+		if err := rows.Scan(&p.Address, &p.MAC, &p.Interface, &first, &last, &p.Total, &counts, &groups,
+			&p.GuessedOS, &p.Hostname, &p.Vendor, &p.Name, &p.Stale, &p.DeviceType, &p.Trusted, &tags); err != nil {
+			rows.Close()
+			return sample, fmt.Errorf("load history peers: %w", err)
+		}
+		// Older releases wrote IPv4-mapped addresses in dotted form.
+		p.Address = canonicalAddr(p.Address)
+		p.Addr = addrKey(p.Address)
+		p.FirstSeen, p.LastSeen = time.Unix(0, first), time.Unix(0, last)
+		err := errors.Join(json.Unmarshal([]byte(counts), &p.Counts), json.Unmarshal([]byte(groups), &p.Groups),
+			json.Unmarshal([]byte(tags), &p.Tags))
+		if err != nil {
+			rows.Close()
+			return sample, fmt.Errorf("load history peer %s: %w", p.Address, err)
+		}
+		sample.Peers = append(sample.Peers, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return sample, fmt.Errorf("load history peers: %w", err)
+	}
+	sort.SliceStable(sample.Peers, func(i, j int) bool { return sample.Peers[i].Total > sample.Peers[j].Total })
+
+	rows, err = h.db.Query(`SELECT address, mac, iface, lifetime, managed, other, mtu, prefixes, rdnss, first_seen, last_seen,
+		preference, dnssl
+		FROM routers WHERE ts = ? ORDER BY address`, ts)
+	if err != nil {
+		return sample, fmt.Errorf("load history routers: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			r                      RouterInfo
+			lifetime, first, last  int64
+			prefixes, rdnss, dnssl string
+		)
+		if err := rows.Scan(&r.Address, &r.MAC, &r.Interface, &lifetime, &r.Managed, &r.Other, &r.MTU,
+			&prefixes, &rdnss, &first, &last, &r.Preference, &dnssl); err != nil {
+			return sample, fmt.Errorf("load history routers: %w", err)
+		}
+		r.Address = canonicalAddr(r.Address)
+		r.Lifetime = time.Duration(lifetime) * time.Second
+		r.FirstSeen, r.LastSeen = time.Unix(0, first), time.Unix(0, last)
+		err := errors.Join(json.Unmarshal([]byte(prefixes), &r.Prefixes), json.Unmarshal([]byte(rdnss), &r.RDNSS),
+			json.Unmarshal([]byte(dnssl), &r.DNSSL))
+		if err != nil {
+			return sample, fmt.Errorf("load history router %s: %w", r.Address, err)
+		}
+		sample.Routers = append(sample.Routers, r)
+	}
+	if err := rows.Err(); err != nil {
+		return sample, fmt.Errorf("load history routers: %w", err)
+	}
+	return sample, nil
+}
@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// UIState is the part of the TUI's view state that is kept across launches:
+// what the user chose to look at, not what there was to see.
+type UIState struct {
+	Tab          string   `json:"tab,omitempty"` // tab name, e.g. "Routers"
+	SortByIdle   bool     `json:"sort_by_idle,omitempty"`
+	MergeLinks   bool     `json:"merge_links,omitempty"`
+	QuickFilters []string `json:"quick_filters,omitempty"` // message types
+	Filter       string   `json:"filter,omitempty"`        // filter bar expression
+	ShowGone     bool     `json:"show_gone,omitempty"`     // Routers tab shows previously seen routers
+}
+
+// DefaultUIStatePath returns where the TUI keeps its state:
+// $XDG_STATE_HOME/ndpeekr/tui.json, or ~/.local/state/ndpeekr/tui.json.
+// It returns "" if neither can be determined.
+func DefaultUIStatePath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "ndpeekr", "tui.json")
+}
+
+// LoadUIState reads the state saved at path. A missing file is the zero
+// state, not an error.
+func LoadUIState(path string) (UIState, error) {
+	var st UIState
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, fmt.Errorf("read ui state: %w", err)
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("parse ui state %s: %w", path, err)
+	}
+	return st, nil
+}
+
+// SaveUIState writes st to path, creating its directory.
+func SaveUIState(path string, st UIState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("save ui state: %w", err)
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode ui state: %w", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+// UIState returns the model's current view state, for SaveUIState.
+func (m Model) UIState() UIState {
+	st := UIState{
+		Tab:        tabNames[m.activeTab],
+		SortByIdle: m.sortByIdle,
+		MergeLinks: m.mergeLinks,
+		Filter:     m.filter.String(),
+		ShowGone:   m.showGone,
+	}
+	for _, kind := range msgColumnOrder {
+		if m.quickFilters[kind] {
+			st.QuickFilters = append(st.QuickFilters, kind)
+		}
+	}
+	return st
+}
+
+// applyUIState restores st. Anything that no longer applies, such as an
+// unknown tab, the Compare tab without a second input or a filter that no
+// longer parses, is left at its default.
+func (m *Model) applyUIState(st UIState) {
+	m.sortByIdle = m.sortByIdle || st.SortByIdle
+	m.mergeLinks = st.MergeLinks
+	m.showGone = st.ShowGone
+	for _, kind := range st.QuickFilters {
+		if slices.Contains(msgColumnOrder, kind) {
+			m.quickFilters[kind] = true
+		}
+	}
+	if st.Filter != "" {
+		if f, err := ParseFilter(st.Filter); err == nil {
+			m.filter = f
+		}
+	}
+	if i := slices.Index(tabNames, st.Tab); i >= 0 && i < m.tabCount() {
+		m.switchTab(i)
+	}
+}
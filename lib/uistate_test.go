@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUIState_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "tui.json")
+	if st, err := LoadUIState(path); err != nil || !reflect.DeepEqual(st, UIState{}) {
+		t.Fatalf("missing file: %+v, %v; want the zero state", st, err)
+	}
+
+	want := UIState{Tab: "Routers", SortByIdle: true, QuickFilters: []string{"router_advertisement"}, Filter: `iface == "eth0"`, ShowGone: true}
+	if err := SaveUIState(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadUIState(path)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %+v, %v; want %+v", got, err, want)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadUIState(path); err == nil {
+		t.Error("corrupt state file: want an error")
+	}
+}
+
+func TestModel_RestoresUIState(t *testing.T) {
+	st := UIState{
+		Tab:          "Sizes",
+		MergeLinks:   true,
+		QuickFilters: []string{"neighbor_solicitation", "bogus"},
+		Filter:       `total > 3`,
+	}
+	m := NewModel(ModelConfig{Stats: NewNDPStats(5 * time.Minute), Window: 5 * time.Minute, UIState: &st})
+	got := m.UIState()
+	want := UIState{Tab: "Sizes", MergeLinks: true, QuickFilters: []string{"neighbor_solicitation"}, Filter: `total > 3`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("state = %+v, want %+v", got, want)
+	}
+
+	// Without a second input there is no Compare tab; a broken filter is dropped.
+	st = UIState{Tab: "Compare", Filter: `total >`}
+	m = NewModel(ModelConfig{Stats: NewNDPStats(5 * time.Minute), UIState: &st})
+	if got := m.UIState(); got.Tab != tabNames[tabPeers] || got.Filter != "" {
+		t.Errorf("state = %+v, want the peers tab and no filter", got)
+	}
+}
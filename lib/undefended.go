@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// ndResponseGrace is how long a target has to answer its first observed
+// Neighbor Solicitation before it can be reported as undefended.
+const ndResponseGrace = 3 * time.Second
+
+// nsTarget tracks Neighbor Solicitations seen for one target address.
+type nsTarget struct {
+	first, last time.Time
+	count       int
+	// arrivals holds each solicitation within the window, oldest first.
+	arrivals []nsArrival
+}
+
+// nsArrival is one Neighbor Solicitation for a target.
+type nsArrival struct {
+	at   time.Time
+	from string // soliciting peer
+}
+
+// UndefendedAddress is an address whose solicited-node group has members,
+// implying someone owns it, but for which Neighbor Solicitations went
+// unanswered throughout the window. Typical causes are devices that are
+// asleep or half-configured and stale MLD state on the joiner.
+type UndefendedAddress struct {
+	Target        string    `json:"target"`
+	Group         string    `json:"group"`  // solicited-node multicast group
+	Owners        []string  `json:"owners"` // peers that joined Group
+	Solicitations int       `json:"solicitations"`
+	LastSolicited time.Time `json:"last_solicited"`
+}
+
+// parseNDTarget returns the Target Address of an NS or NA, or "".
+func parseNDTarget(buf []byte) string {
+	if len(buf) < 24 || (buf[0] != 135 && buf[0] != 136) {
+		return ""
+	}
+	return ip16String(buf[8:24])
+}
+
+// solicitedNodeGroup returns the solicited-node multicast address of ip
+// (ff02::1:ff00:0/104 plus its low 24 bits), or "". Any zone is ignored:
+// memberships are recorded without one.
+func solicitedNodeGroup(ip string) string {
+	a := addrKey(ip)
+	if !a.Is6() || a.Is4In6() {
+		return ""
+	}
+	b := a.As16()
+	g := [16]byte{0: 0xff, 1: 0x02, 11: 0x01, 12: 0xff}
+	copy(g[13:], b[13:16])
+	return netip.AddrFrom16(g).String()
+}
+
+// RecordNSTarget notes that ip sent a Neighbor Solicitation for target. DAD
+// probes (unspecified source) should not be recorded: no one is expected to
+// answer.
+func (s *NDPStats) RecordNSTarget(ip, target string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.nsTargets[target]
+	if !ok {
+		t = &nsTarget{first: now}
+		s.nsTargets[target] = t
+	}
+	t.last = now
+	t.count++
+	t.arrivals = append(t.arrivals, nsArrival{at: now, from: ip})
+}
+
+// RecordNATarget notes that ip sent a Neighbor Advertisement for target.
+func (s *NDPStats) RecordNATarget(ip, target string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peer := s.getOrCreatePeer(ip, now)
+	peer.Defended[target] = now
+}
+
+// GetUndefendedAddresses returns solicited but unanswered addresses that
+// some peer's solicited-node membership claims, ordered by target.
+func (s *NDPStats) GetUndefendedAddresses() []UndefendedAddress {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.undefendedLocked(s.now())
+}
+
+// undefendedLocked compares solicited-node memberships with NS/NA traffic
+// within the window. Callers must hold s.mu.
+func (s *NDPStats) undefendedLocked(now time.Time) []UndefendedAddress {
+	cutoff := now.Add(-s.window)
+
+	// Group memberships and defended targets within the window.
+	joined := make(map[string][]string)
+	defended := make(map[string]bool)
+	for addr, peer := range s.peers {
+		for group, last := range peer.Groups {
+			if last.After(cutoff) {
+				joined[group] = append(joined[group], addr)
+			}
+		}
+		for target, last := range peer.Defended {
+			if last.After(cutoff) {
+				defended[target] = true
+			}
+		}
+	}
+
+	var result []UndefendedAddress
+	for target, ns := range s.nsTargets {
+		if defended[target] || !ns.last.After(cutoff) || now.Sub(ns.first) < ndResponseGrace {
+			continue
+		}
+		group := solicitedNodeGroup(target)
+		owners := joined[group]
+		if len(owners) == 0 {
+			continue
+		}
+		owners = append([]string(nil), owners...)
+		sort.Strings(owners)
+		result = append(result, UndefendedAddress{
+			Target:        target,
+			Group:         group,
+			Owners:        owners,
+			Solicitations: ns.count,
+			LastSolicited: ns.last,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Target < result[j].Target })
+	return result
+}
+
+// pruneNDTargetsLocked forgets solicitations and advertisements older than
+// cutoff. Callers must hold s.mu.
+func (s *NDPStats) pruneNDTargetsLocked(cutoff time.Time) {
+	for target, ns := range s.nsTargets {
+		if !ns.last.After(cutoff) {
+			delete(s.nsTargets, target)
+			continue
+		}
+		i := 0
+		for i < len(ns.arrivals) && !ns.arrivals[i].at.After(cutoff) {
+			i++
+		}
+		ns.arrivals = ns.arrivals[i:]
+	}
+	for _, peer := range s.peers {
+		for target, last := range peer.Defended {
+			if !last.After(cutoff) {
+				delete(peer.Defended, target)
+			}
+		}
+	}
+}
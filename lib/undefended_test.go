@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSolicitedNodeGroup(t *testing.T) {
+	if got := solicitedNodeGroup("2001:db8::1:2a3b:4c5d"); got != "ff02::1:ff3b:4c5d" {
+		t.Errorf("solicitedNodeGroup = %s, want ff02::1:ff3b:4c5d", got)
+	}
+	if got := solicitedNodeGroup("bogus"); got != "" {
+		t.Errorf("solicitedNodeGroup(bogus) = %q, want empty", got)
+	}
+}
+
+func TestParseNDTarget(t *testing.T) {
+	if got := parseNDTarget(buildNS(net.ParseIP("fe80::9"), nil)); got != "fe80::9" {
+		t.Errorf("NS target = %q, want fe80::9", got)
+	}
+	if got := parseNDTarget(buildRS(nil)); got != "" {
+		t.Errorf("RS target = %q, want empty", got)
+	}
+}
+
+func TestUndefendedAddresses(t *testing.T) {
+	stats := NewNDPStats(5 * time.Minute)
+	asked := time.Now().Add(-10 * time.Second)
+
+	// fe80::a sleeps: joined the solicited-node group of fe80::a, never answers.
+	stats.RecordMLDMembership("fe80::a", solicitedNodeGroup("fe80::a"))
+	stats.RecordNSTarget("fe80::99", "fe80::a", asked)
+	stats.RecordNSTarget("fe80::99", "fe80::a", asked.Add(time.Second))
+
+	// fe80::b answers.
+	stats.RecordMLDMembership("fe80::b", solicitedNodeGroup("fe80::b"))
+	stats.RecordNSTarget("fe80::99", "fe80::b", asked)
+	stats.RecordNATarget("fe80::b", "fe80::b", asked.Add(time.Millisecond))
+
+	// Nobody claims fe80::c; a scan for it is not a discrepancy.
+	stats.RecordNSTarget("fe80::99", "fe80::c", asked)
+
+	// fe80::d was only just solicited and still has time to answer.
+	stats.RecordMLDMembership("fe80::d", solicitedNodeGroup("fe80::d"))
+	stats.RecordNSTarget("fe80::99", "fe80::d", time.Now())
+
+	got := stats.GetUndefendedAddresses()
+	if len(got) != 1 {
+		t.Fatalf("got %d undefended addresses (%+v), want 1", len(got), got)
+	}
+	u := got[0]
+	if u.Target != "fe80::a" || u.Solicitations != 2 || len(u.Owners) != 1 || u.Owners[0] != "fe80::a" {
+		t.Errorf("undefended = %+v", u)
+	}
+
+	for _, p := range stats.GetStats() {
+		want := 0
+		if p.Address == "fe80::a" {
+			want = 1
+		}
+		if len(p.Undefended) != want {
+			t.Errorf("%s: Undefended = %v, want %d entries", p.Address, p.Undefended, want)
+		}
+	}
+}
@@ -3,75 +3,1312 @@ package main
 import (
 	"NDPeekr/lib"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "export: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		if err := runHealthcheck(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "healthcheck: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		if err := runCtl(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "ctl: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "import: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "genpcap" {
+		if err := runGenpcap(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "genpcap: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var (
 		listenAddr = flag.String("listen", "::", "IPv6 address to bind (typically ::)")
-		ifaceName  = flag.String("iface", "", "Optional interface name to restrict reads (best-effort)")
+		ifaceName  = flag.String("iface", "", "Optional interface name, comma-separated names, or all for every interface with IPv6, to restrict reads (best-effort)")
 		logLevel   = flag.String("log-level", "info", "debug|info|warn|error")
 		window     = flag.Duration("window", 15*time.Minute, "Sliding window duration for stats (e.g. 15m, 1h)")
 		refresh    = flag.Duration("refresh", 2*time.Second, "Table refresh interval (e.g. 2s, 500ms)")
+		snapDir    = flag.String("snapshot-dir", ".", "Directory for freeze snapshots written with the 'f' key")
+		configPath = flag.String("config", "", "Optional YAML config file (sinks, API, enrichment, ignore rules)")
+		sortBy     = flag.String("sort", "total", "Initial peer sort order: total|idle (toggle with 's')")
+		uiState    = flag.String("ui-state", lib.DefaultUIStatePath(), "File keeping the TUI's tab, sort order and filters across launches (empty to disable)")
+		capture    = flag.String("capture", "socket", "Capture backend: socket (ICMPv6 socket) or packet (AF_PACKET, Linux; sees extension headers)")
+		readPcap   = flag.String("read-pcap", "", "Replay a pcap file instead of capturing live (implies --capture pcap)")
+		logRate    = flag.Float64("log-rate", 5, "Per-peer log lines per second before suppression (0 = unlimited)")
+		grace      = flag.Duration("grace", 2*time.Minute, "How long quiet peers stay visible as stale after leaving the window")
+
+		maxSampling   = flag.Int("max-sampling", 64, "Under overload, fully parse only 1 in up to N messages and just count the rest (1 = never sample)")
+		fastPath      = flag.Bool("fast-path", false, "Handle the dominant RS/NS/NA types on a lighter parse path; they are left out of the option usage matrix")
+		icmpErrors    = flag.Bool("icmp-errors", false, "Also observe ICMPv6 Packet Too Big messages and check the MTUs they report against the link MTU")
+		dedup         = flag.Bool("dedup", false, "Drop NDP/MLD packets repeating one captured within 20ms, on any interface, before recording them ('D' on the Sizes tab toggles)")
+		trusted       = flag.String("trusted-routers", "", "Comma-separated router addresses or MACs allowed to send RAs; RAs from others raise rogue_ra (adds to rogue_ra.trusted)")
+		pruneInterval = flag.Duration("prune-interval", 2*time.Second, "How often peers and routers are aged out of the window, independent of --refresh")
+
+		memberPorts = flag.Bool("member-ports", false, "With --capture packet on a bridge or bond --iface, capture on its member ports and attribute events to them")
+		mirror      = flag.Bool("mirror", false, "--iface is a SPAN/mirror port: read whole Ethernet frames in promiscuous mode and label events via mirror (implies --capture packet)")
+
+		stateDB       = flag.String("state-db", "", "SQLite file keeping peers, routers, MACs and group memberships across restarts (paths may use {instance})")
+		stateInterval = flag.Duration("state-interval", 5*time.Minute, "How often --state-db is saved, besides on shutdown")
+
+		resolve = flag.String("resolve", "", "Look up peer hostnames in the background: ptr (reverse DNS), mdns (multicast DNS on the peer's link) or ptr,mdns")
+
+		controlSocket = flag.String("control-socket", "", "Serve the JSON-RPC control protocol on this unix socket for 'ndpeekr ctl' and local automation (paths may use {instance})")
+		controlGroup  = flag.String("control-group", "", "Also let members of this group use --control-socket (default: root and the user running ndpeekr only)")
+
+		perInterface = flag.Bool("per-interface", false, "Without --iface, key every peer by address and interface so one address on two links is two rows ('m' merges them)")
+
+		ringPackets = flag.Int("ring-packets", 0, "Keep the last N raw packets in memory for pcap dumps ('w' key, evidence bundles); needs --capture packet")
+		ringAge     = flag.Duration("ring-age", 0, "Keep raw packets up to this old in the ring (e.g. 5m); combines with --ring-packets")
+
+		shadowOutput   = flag.String("shadow-output", "", "Record raw packets, events and periodic snapshots into this directory, to diff two versions run side by side")
+		shadowInterval = flag.Duration("shadow-interval", time.Minute, "Interval between snapshots with --shadow-output")
+
+		compareIface = flag.String("compare-iface", "", "Also capture on this interface and compare it with the main input (Compare tab)")
+		comparePcap  = flag.String("compare-pcap", "", "Also replay this pcap file and compare it with the main input (Compare tab)")
+
+		solicit         = flag.Bool("solicit", false, "Send Router Solicitations on --iface and measure each router's RS to RA latency")
+		solicitInterval = flag.Duration("solicit-interval", time.Minute, "Interval between Router Solicitations with --solicit")
+		probeRouters    = flag.Bool("probe-routers", false, "Send unicast Neighbor Solicitations to known routers on --iface and flag those that stop answering")
+		probeInterval   = flag.Duration("probe-interval", 30*time.Second, "Interval between router probes with --probe-routers")
+		lldp            = flag.Bool("lldp", false, "Listen for LLDP/CDP on --iface (and --compare-iface) to show the upstream switch port")
+
+		output       = flag.String("output", "tui", "Output mode: tui, or jsonl to skip the TUI and write one JSON object per event to --output-file")
+		outputFile   = flag.String("output-file", "-", "File --output jsonl appends events to, - for stdout")
+		headless     = flag.Bool("headless", false, "Run without the TUI until SIGINT/SIGTERM (the default when stdin or stdout is not a terminal)")
+		k8s          = flag.Bool("k8s", false, "Kubernetes DaemonSet mode: --headless, JSON logs on stderr, --node-name from $NODE_NAME and --health-listen :9312 unless set")
+		nodeName     = flag.String("node-name", "", "Label events, alerts, metrics and logs with this node name")
+		instanceName = flag.String("instance", "", "Name keeping this instance's files apart from other instances on the host (default: the --iface names); paths may use {instance}")
+		healthListen = flag.String("health-listen", "", "Serve /livez, /readyz and /healthz probes on this address (e.g. :9312)")
+		logFormat    = flag.String("log-format", "text", "Log format: text|json")
+		logPath      = flag.String("log-file", "", "Log file, - for stderr (default ndpeekr.log, or stderr when headless)")
 	)
 	flag.Parse()
 
-	level := parseLogLevel(*logLevel)
+	// A LevelVar so the API's admin endpoint can change it at runtime.
+	level := new(slog.LevelVar)
+	level.Set(parseLogLevel(*logLevel))
 
-	// Log to a file instead of stderr so output doesn't corrupt the TUI alt screen.
-	logFile, err := os.OpenFile("ndpeekr.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to open log file: %v\n", err)
-		os.Exit(1)
+	if *k8s {
+		set := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		*headless = true
+		if !set["log-format"] {
+			*logFormat = "json"
+		}
+		if *nodeName == "" {
+			*nodeName = os.Getenv("NODE_NAME")
+		}
+		if !set["health-listen"] {
+			*healthListen = ":9312"
+		}
+	}
+	switch *output {
+	case "tui":
+	case "jsonl":
+		*headless = true
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --output %q: want tui or jsonl\n", *output)
+		os.Exit(2)
+	}
+	if !*headless && (!isTerminal(os.Stdin) || !isTerminal(os.Stdout)) {
+		*headless = true
+	}
+	if *logPath == "" {
+		*logPath = "ndpeekr.log"
+		if *headless {
+			*logPath = "-"
+		}
+	}
+	if *logFormat != "text" && *logFormat != "json" {
+		fmt.Fprintf(os.Stderr, "invalid --log-format %q: want text or json\n", *logFormat)
+		os.Exit(2)
+	}
+	ifaces := splitInterfaces(*ifaceName)
+	// Instance names and files stay "all" as interfaces come and go.
+	instanceIfaces := ifaces
+	if *ifaceName == "all" {
+		var err error
+		if ifaces, err = lib.IPv6Interfaces(); err != nil {
+			fmt.Fprintf(os.Stderr, "--iface all: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if *mirror && *readPcap == "" {
+		captureSet := false
+		flag.Visit(func(f *flag.Flag) { captureSet = captureSet || f.Name == "capture" })
+		if (captureSet && *capture != lib.CapturePacket) || len(ifaces) != 1 || *memberPorts {
+			fmt.Fprintln(os.Stderr, "--mirror needs --capture packet on a single --iface, without --member-ports")
+			os.Exit(2)
+		}
+		if *solicit || *probeRouters {
+			fmt.Fprintln(os.Stderr, "--solicit and --probe-routers send from this host; a mirror port can't carry them")
+			os.Exit(2)
+		}
+		*capture = lib.CapturePacket
+	}
+	if *readPcap != "" {
+		*capture = lib.CapturePcap
+	}
+	resolvePTR, resolveMDNS, resolveErr := lib.ParseResolve(*resolve)
+	if resolveErr != nil {
+		fmt.Fprintln(os.Stderr, resolveErr)
+		os.Exit(2)
+	}
+
+	if *compareIface != "" && *comparePcap != "" {
+		fmt.Fprintln(os.Stderr, "--compare-iface and --compare-pcap are mutually exclusive")
+		os.Exit(2)
+	}
+
+	if *memberPorts && (len(ifaces) != 1 || *capture != lib.CapturePacket) {
+		fmt.Fprintln(os.Stderr, "--member-ports needs --capture packet on a bridge or bond --iface")
+		os.Exit(2)
+	}
+
+	if *maxSampling < 1 {
+		fmt.Fprintln(os.Stderr, "--max-sampling must be at least 1")
+		os.Exit(2)
+	}
+
+	if *pruneInterval <= 0 {
+		fmt.Fprintln(os.Stderr, "--prune-interval must be positive")
+		os.Exit(2)
+	}
+
+	if *perInterface && ((*ifaceName != "" && *ifaceName != "all") || *readPcap != "") {
+		fmt.Fprintln(os.Stderr, "--per-interface needs a live capture on all interfaces (no --iface, or --iface all)")
+		os.Exit(2)
+	}
+
+	if (*ringPackets > 0 || *ringAge > 0) && *capture == lib.CaptureSocket {
+		fmt.Fprintln(os.Stderr, "--ring-packets and --ring-age need --capture packet or --read-pcap")
+		os.Exit(2)
+	}
+
+	if *solicit && (len(ifaces) != 1 || *readPcap != "") {
+		fmt.Fprintln(os.Stderr, "--solicit needs a live capture on a single --iface")
+		os.Exit(2)
+	}
+	if *probeRouters && (len(ifaces) != 1 || *readPcap != "") {
+		fmt.Fprintln(os.Stderr, "--probe-routers needs a live capture on a single --iface")
+		os.Exit(2)
+	}
+	if *lldp && (len(ifaces) == 0 || *readPcap != "") {
+		fmt.Fprintln(os.Stderr, "--lldp needs a live capture on --iface")
+		os.Exit(2)
+	}
+	if len(ifaces) > 1 && *readPcap != "" {
+		fmt.Fprintln(os.Stderr, "--read-pcap takes at most one --iface")
+		os.Exit(2)
+	}
+	// A missing interface would lift that listener's restriction and
+	// count every other interface's traffic twice.
+	if len(ifaces) > 1 {
+		for _, name := range ifaces {
+			if _, err := net.InterfaceByName(name); err != nil {
+				fmt.Fprintf(os.Stderr, "--iface %s: %v\n", name, err)
+				os.Exit(2)
+			}
+		}
+	}
+
+	if *sortBy != "total" && *sortBy != "idle" {
+		fmt.Fprintf(os.Stderr, "invalid --sort %q: want total or idle\n", *sortBy)
+		os.Exit(2)
 	}
-	defer logFile.Close()
 
-	handler := slog.NewTextHandler(logFile, &slog.HandlerOptions{Level: level})
-	logger := slog.New(handler).With("component", "ndpmon")
+	cfg := &lib.Config{}
+	if *configPath != "" {
+		var err error
+		cfg, err = lib.LoadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *trusted != "" {
+		if cfg.RogueRA == nil {
+			cfg.RogueRA = &lib.RogueRAConfig{}
+		}
+		if err := cfg.RogueRA.AddTrusted(*trusted); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+	}
 
+	// Keep this instance's files apart from those of instances on other
+	// interfaces, and refuse to share one with an instance that is running.
+	instance := lib.InstanceName(*instanceName, instanceIfaces)
+	cfg.ExpandInstance(instance)
+	*snapDir = lib.ExpandInstance(*snapDir, instance)
+	*shadowOutput = lib.ExpandInstance(*shadowOutput, instance)
+	*logPath = lib.ExpandInstance(*logPath, instance)
+	*stateDB = lib.ExpandInstance(*stateDB, instance)
+	*controlSocket = lib.ExpandInstance(*controlSocket, instance)
+	uiStateSet := false
+	flag.Visit(func(f *flag.Flag) { uiStateSet = uiStateSet || f.Name == "ui-state" })
+	if !uiStateSet {
+		*uiState = lib.InstancePath(*uiState, instance)
+	}
+	*uiState = lib.ExpandInstance(*uiState, instance)
+	var exclusive []string
+	if cfg.History != nil {
+		exclusive = append(exclusive, cfg.History.Path)
+	}
+	if cfg.Sinks.NDJSON != nil {
+		exclusive = append(exclusive, cfg.Sinks.NDJSON.Path)
+	}
+	if *shadowOutput != "" {
+		exclusive = append(exclusive, *shadowOutput)
+	}
+	if *stateDB != "" {
+		exclusive = append(exclusive, *stateDB)
+	}
+	if *controlSocket != "" {
+		exclusive = append(exclusive, *controlSocket)
+	}
+	for _, path := range exclusive {
+		lock, err := lib.LockFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v; give each instance its own files with --instance and {instance} in paths\n", err)
+			os.Exit(1)
+		}
+		defer lock.Unlock()
+	}
+
+	// With the TUI, log to a file instead of stderr so output doesn't corrupt the alt screen.
+	logFile := os.Stderr
+	var err error
+	if *logPath != "-" {
+		logFile, err = os.OpenFile(*logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open log file: %v\n", err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+	} else if !*headless {
+		fmt.Fprintln(os.Stderr, "--log-file - needs --headless")
+		os.Exit(2)
+	}
+	var base slog.Handler = slog.NewTextHandler(logFile, &slog.HandlerOptions{Level: level})
+	if *logFormat == "json" {
+		base = slog.NewJSONHandler(logFile, &slog.HandlerOptions{Level: level})
+	}
+
+	// Coalesce repeated lines and rate limit per peer so a storm can't fill the disk.
+	handler := lib.NewDedupHandler(base, lib.DedupHandlerConfig{
+		PeerRate: *logRate,
+	})
+	defer handler.Flush()
+	logger := slog.New(handler)
+	if *nodeName != "" {
+		logger = logger.With("node", *nodeName)
+	}
+	logger = logger.With("component", "ndpmon")
+
+	// Headless, SIGINT and SIGTERM (as sent by the kubelet) stop capture.
 	ctx, cancel := context.WithCancel(context.Background())
+	if *headless {
+		ctx, cancel = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	}
 	defer cancel()
+	health := lib.NewHealth()
 
 	// Create stats tracker
 	stats := lib.NewNDPStats(*window)
+	stats.SetGrace(*grace)
+	stats.SetSharedAge(*refresh / 2)
+	stats.SetIgnore(cfg.IgnoreFilters())
+	stats.SetMaintenance(cfg.MaintenanceWindows())
+	stats.SetDedup(*dedup)
 
-	l := lib.NewNDPListener(lib.NDPListenerConfig{
-		ListenAddr: *listenAddr,
-		Interface:  *ifaceName,
-		Logger:     logger.With("component", "ndp_listener"),
-		Stats:      stats,
-	})
+	// Bring back the peers and routers of the last run before capturing.
+	var stateStore *lib.StateDB
+	if *stateDB != "" {
+		stateStore, err = lib.OpenStateDB(*stateDB)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer stateStore.Close()
+		st, err := stateStore.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		stats.RestoreState(st, time.Now())
+		logger.Info("restored state", "path", *stateDB, "peers", len(st.Peers), "routers", len(st.Routers), "saved", st.Saved)
+	}
+
+	var ring *lib.PacketRing
+	if *ringPackets > 0 || *ringAge > 0 {
+		ring, err = lib.NewPacketRing(lib.PacketRingConfig{Packets: *ringPackets, Age: *ringAge})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+	}
 
-	// Start listener in background goroutine.
-	listenerErrCh := make(chan error, 1)
-	go func() {
-		listenerErrCh <- l.Run(ctx)
-	}()
+	// Headless sinks run alongside the TUI.
+	sinks, err := lib.OpenSinks(cfg.Sinks, logger.With("component", "sinks"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if cfg.Evidence != nil {
+		ecfg := lib.EvidenceRecorderConfig{
+			Stats:  stats,
+			Logger: logger.With("component", "evidence"),
+			Config: *cfg.Evidence,
+		}
+		if ring != nil {
+			ecfg.Packets = ring
+		}
+		ecfg.Privacy = cfg.Privacy
+		evidence, err := lib.NewEvidenceRecorder(ecfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, evidence)
+	}
+	if *output == "jsonl" {
+		jsonl, err := lib.NewJSONLSink(*outputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, jsonl)
+	}
+	sinks = lib.PrivateSinks(sinks, cfg.Privacy)
+	shadowDone := make(chan struct{})
+	var shadow *lib.ShadowRecorder
+	if *shadowOutput != "" {
+		shadow, err = lib.NewShadowRecorder(lib.ShadowRecorderConfig{
+			Dir:      *shadowOutput,
+			Stats:    stats,
+			Logger:   logger.With("component", "shadow"),
+			Interval: *shadowInterval,
+			Args:     os.Args,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, shadow)
+		go func() {
+			shadow.Run(ctx)
+			close(shadowDone)
+		}()
+	} else {
+		close(shadowDone)
+	}
+	// Sinks write on goroutines of their own: a stalled collector must not
+	// stall capture.
+	sinks = lib.QueueSinks(sinks, 0, logger.With("component", "sinks"))
+	defer lib.CloseSinks(sinks)
+	sinks = lib.NodeSinks(sinks, *nodeName)
+
+	sinkNames := cfg.Sinks.Enabled()
+	if cfg.Evidence != nil {
+		sinkNames = append(sinkNames, "evidence")
+	}
+	if shadow != nil {
+		sinkNames = append(sinkNames, "shadow")
+	}
+	status := lib.Status{
+		Build:      lib.ReadBuildInfo(),
+		Started:    time.Now(),
+		Capture:    *capture,
+		Interface:  strings.Join(ifaces, ","),
+		PcapFile:   *readPcap,
+		Mirror:     *mirror,
+		Node:       *nodeName,
+		Window:     *window,
+		Sinks:      sinkNames,
+		RulePacks:  cfg.RulePacks(),
+		ConfigPath: *configPath,
+	}
+	if *configPath != "" {
+		status.Config = cfg.Redacted()
+	}
+	stats.SetStatus(status)
+
+	if cfg.Sinks.Prometheus != nil {
+		go func() {
+			if err := lib.ServePrometheus(ctx, *cfg.Sinks.Prometheus, stats, logger.With("component", "prometheus")); err != nil {
+				logger.Error("prometheus sink stopped", "err", err)
+				health.Fail("prometheus", err)
+			}
+		}()
+	}
+
+	if cfg.Sinks.AgentX != nil {
+		go func() {
+			if err := lib.ServeAgentX(ctx, *cfg.Sinks.AgentX, stats, logger.With("component", "agentx")); err != nil {
+				logger.Error("agentx subagent stopped", "err", err)
+				health.Fail("agentx", err)
+			}
+		}()
+	}
+
+	if cfg.Sinks.GNMI != nil {
+		go func() {
+			if err := lib.ServeGNMI(ctx, *cfg.Sinks.GNMI, stats, logger.With("component", "gnmi")); err != nil {
+				logger.Error("gnmi sink stopped", "err", err)
+				health.Fail("gnmi", err)
+			}
+		}()
+	}
+
+	var federation *lib.Federation
+	if cfg.Federation != nil {
+		name := *nodeName
+		if name == "" {
+			name = instance
+		}
+		if name == "" {
+			name, _ = os.Hostname()
+		}
+		federation, err = lib.NewFederation(*cfg.Federation, name, logger.With("component", "federation"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		go federation.Run(ctx)
+	}
+
+	if cfg.API != nil {
+		go func() {
+			if err := lib.ServeAPI(ctx, *cfg.API, stats, federation, level, logger.With("component", "api")); err != nil {
+				logger.Error("api stopped", "err", err)
+				health.Fail("api", err)
+			}
+		}()
+	}
+
+	if *controlSocket != "" {
+		go func() {
+			err := lib.ServeControl(ctx, lib.ControlConfig{
+				Path:        *controlSocket,
+				Group:       *controlGroup,
+				Stats:       stats,
+				Level:       level,
+				SnapshotDir: *snapDir,
+				Instance:    instance,
+				Anonymizer:  cfg.Anonymizer(),
+				Privacy:     cfg.Privacy,
+				Logger:      logger.With("component", "control"),
+			})
+			if err != nil {
+				logger.Error("control socket stopped", "err", err)
+				health.Fail("control", err)
+			}
+		}()
+	}
+
+	if cfg.Enrichment != nil {
+		enricher, err := lib.NewEnricher(lib.EnricherConfig{
+			Stats:  stats,
+			Logger: logger.With("component", "enrich"),
+			Config: *cfg.Enrichment,
+			Sinks:  sinks,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		go enricher.Run(ctx)
+	}
+	if resolvePTR || resolveMDNS {
+		resolver := lib.NewResolver(lib.ResolverConfig{
+			Stats:  stats,
+			Logger: logger.With("component", "resolve"),
+			PTR:    resolvePTR,
+			MDNS:   resolveMDNS,
+		})
+		go resolver.Run(ctx)
+	}
+
+	historyDone := make(chan struct{})
+	var historyDB *lib.HistoryDB
+	if cfg.History != nil {
+		historyDB, err = lib.OpenHistoryDB(cfg.History.Path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer historyDB.Close()
+		recorder := lib.NewHistoryRecorder(lib.HistoryRecorderConfig{
+			DB:         historyDB,
+			Stats:      stats,
+			Logger:     logger.With("component", "history"),
+			Interval:   cfg.History.Interval,
+			MaxAge:     cfg.History.MaxAge,
+			MaxBytes:   cfg.History.MaxSizeMB << 20,
+			Downsample: cfg.History.Downsample,
+			Privacy:    cfg.Privacy,
+		})
+		go func() {
+			recorder.Run(ctx)
+			close(historyDone)
+		}()
+	} else {
+		close(historyDone)
+	}
+
+	stateDone := make(chan struct{})
+	if stateStore != nil {
+		saver := lib.NewStateSaver(lib.StateSaverConfig{
+			DB:       stateStore,
+			Stats:    stats,
+			Logger:   logger.With("component", "state"),
+			Interval: *stateInterval,
+			Privacy:  cfg.Privacy,
+		})
+		go func() {
+			saver.Run(ctx)
+			close(stateDone)
+		}()
+	} else {
+		close(stateDone)
+	}
+
+	// One listener per --iface, all feeding the same stats and sinks.
+	listenIfaces := ifaces
+	if len(listenIfaces) == 0 {
+		listenIfaces = []string{""}
+	}
+	listenerErrCh := make(chan error, len(listenIfaces))
+	for _, iface := range listenIfaces {
+		component := "capture"
+		if iface != "" {
+			component += " " + iface
+		}
+		health.Expect(component)
+		l := lib.NewNDPListener(lib.NDPListenerConfig{
+			ListenAddr: *listenAddr,
+			Interface:  iface,
+			Logger:     logger.With("component", "ndp_listener"),
+			Stats:      stats,
+			Sinks:      sinks,
+			Capture:    *capture,
+			PcapFile:   *readPcap,
+
+			MemberPorts:      *memberPorts,
+			Mirror:           *mirror,
+			Ring:             ring,
+			Shadow:           shadow,
+			ScopeByInterface: *perInterface,
+			MaxSampling:      *maxSampling,
+			FastPath:         *fastPath,
+			RAGuard:          cfg.RAGuard,
+			RogueRA:          cfg.RogueRA,
+			ICMPErrors:       *icmpErrors,
+			Ready:            func() { health.Ready(component) },
+		})
+
+		// Start listener in background goroutine.
+		go func() {
+			err := l.Run(ctx)
+			if err != nil && ctx.Err() == nil {
+				health.Fail(component, err)
+			}
+			listenerErrCh <- err
+		}()
+	}
+
+	if *healthListen != "" {
+		go func() {
+			if err := lib.ServeHealth(ctx, *healthListen, health, logger.With("component", "health")); err != nil {
+				logger.Error("health probes stopped", "err", err)
+			}
+		}()
+	}
 
 	logger.Info("starting NDP listener", "listen", *listenAddr, "iface", *ifaceName, "window", *window, "refresh", *refresh)
 
+	if *solicit {
+		solicitor := lib.NewSolicitor(lib.SolicitorConfig{
+			Interface: ifaces[0],
+			Interval:  *solicitInterval,
+			Logger:    logger.With("component", "solicit"),
+			Stats:     stats,
+		})
+		go func() {
+			if err := solicitor.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("solicitor stopped", "err", err)
+			}
+		}()
+	}
+
+	if *probeRouters {
+		prober := lib.NewRouterProber(lib.RouterProberConfig{
+			Interface: ifaces[0],
+			Interval:  *probeInterval,
+			Logger:    logger.With("component", "probe"),
+			Stats:     stats,
+			Sinks:     sinks,
+		})
+		go func() {
+			if err := prober.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("router prober stopped", "err", err)
+			}
+		}()
+	}
+
+	var ruleEngine *lib.RuleEngine
+	if cfg.Rules != nil {
+		ruleEngine = lib.NewRuleEngine(lib.RuleEngineConfig{
+			Rules:    cfg.DetectionRules(),
+			Interval: cfg.Rules.Interval,
+			Logger:   logger.With("component", "rules"),
+			Stats:    stats,
+			Sinks:    sinks,
+		})
+		go func() {
+			if err := ruleEngine.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("rule engine stopped", "err", err)
+			}
+		}()
+	}
+
+	if cfg.Cardinality != nil {
+		guard := lib.NewCardinalityGuard(lib.CardinalityGuardConfig{
+			Config: *cfg.Cardinality,
+			Logger: logger.With("component", "cardinality"),
+			Stats:  stats,
+			Sinks:  sinks,
+		})
+		go func() {
+			if err := guard.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("cardinality guard stopped", "err", err)
+			}
+		}()
+	}
+
+	if cfg.MLDSnoop != nil {
+		checker := lib.NewMLDSnoopChecker(lib.MLDSnoopCheckerConfig{
+			Config: *cfg.MLDSnoop,
+			Logger: logger.With("component", "mld_snoop"),
+			Stats:  stats,
+			Sinks:  sinks,
+		})
+		go func() {
+			if err := checker.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("MLD snooping check stopped", "err", err)
+			}
+		}()
+	}
+
+	if *lldp {
+		lldpIfaces := ifaces
+		if *compareIface != "" {
+			lldpIfaces = append(lldpIfaces[:len(lldpIfaces):len(lldpIfaces)], *compareIface)
+		}
+		lldpListener := lib.NewLLDPListener(lib.LLDPListenerConfig{
+			Interfaces: lldpIfaces,
+			Logger:     logger.With("component", "lldp"),
+			Stats:      stats,
+		})
+		go func() {
+			if err := lldpListener.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("LLDP listener stopped", "err", err)
+			}
+		}()
+	}
+
+	// Comparison mode: a second input with its own stats and no sinks, so
+	// alerts and events are not reported twice.
+	var compareStats *lib.NDPStats
+	if *compareIface != "" || *comparePcap != "" {
+		compareStats = lib.NewNDPStats(*window)
+		compareStats.SetGrace(*grace)
+		compareStats.SetDedup(*dedup)
+		compareStats.SetIgnore(cfg.IgnoreFilters())
+
+		compareCapture := *capture
+		if *comparePcap != "" {
+			compareCapture = lib.CapturePcap
+		} else if compareCapture == lib.CapturePcap {
+			compareCapture = lib.CaptureSocket
+		}
+		cl := lib.NewNDPListener(lib.NDPListenerConfig{
+			ListenAddr: *listenAddr,
+			Interface:  *compareIface,
+			Logger:     logger.With("component", "ndp_listener", "input", "compare"),
+			Stats:      compareStats,
+			Capture:    compareCapture,
+			PcapFile:   *comparePcap,
+		})
+		go func() {
+			if err := cl.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("compare listener stopped", "err", err)
+			}
+		}()
+	}
+
+	// Pruning runs on its own timer so a slow prune never stalls rendering.
+	for _, s := range []*lib.NDPStats{stats, compareStats} {
+		if s == nil {
+			continue
+		}
+		pruner := lib.NewPruner(lib.PrunerConfig{
+			Stats:    s,
+			Logger:   logger.With("component", "pruner"),
+			Interval: *pruneInterval,
+		})
+		go func() {
+			if err := pruner.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("pruner stopped", "err", err)
+			}
+		}()
+	}
+
+	var locator *lib.MACLocator
+	if cfg.MACLocation != nil {
+		locator = lib.NewMACLocator(lib.MACLocatorConfig{
+			Config: *cfg.MACLocation,
+			Logger: logger.With("component", "maclocate"),
+		})
+	}
+
+	if *headless {
+		// Run until a signal or until a listener stops: a failed capture
+		// exits non-zero so the orchestrator restarts it, a finished pcap
+		// replay exits cleanly.
+		logger.Info("running headless", "health", *healthListen)
+		var listenErr error
+		select {
+		case <-ctx.Done():
+		case listenErr = <-listenerErrCh:
+		}
+		cancel()
+		<-historyDone
+		<-stateDone
+		<-shadowDone
+		if listenErr != nil && !errors.Is(listenErr, context.Canceled) {
+			logger.Error("listener error", "err", listenErr)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Restore the view state of the last run; an explicit --sort wins.
+	// A second TUI for the same instance neither restores nor saves it.
+	var restored *lib.UIState
+	if *uiState != "" {
+		lock, err := lib.LockFile(*uiState)
+		if err != nil {
+			logger.Warn("not keeping ui state", "err", err)
+			*uiState = ""
+		} else {
+			defer lock.Unlock()
+		}
+	}
+	if *uiState != "" {
+		st, err := lib.LoadUIState(*uiState)
+		if err != nil {
+			logger.Warn("ignoring saved ui state", "err", err)
+		}
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "sort" {
+				st.SortByIdle = false
+			}
+		})
+		restored = &st
+	}
+
 	// Create and run Bubble Tea program.
-	m := lib.NewModel(stats, *window, *refresh)
+	m := lib.NewModel(lib.ModelConfig{
+		Stats:       stats,
+		Window:      *window,
+		Refresh:     *refresh,
+		SnapshotDir: *snapDir,
+		SortByIdle:  *sortBy == "idle",
+		Instance:    instance,
+
+		MulticastLabels: cfg.MulticastGroupLabels(),
+
+		CompareStats:  compareStats,
+		CompareLabels: [2]string{inputLabel(*ifaceName, *readPcap), inputLabel(*compareIface, *comparePcap)},
+		History:       historyDB,
+		Ring:          ring,
+		Rules:         ruleEngine,
+		Locator:       locator,
+		UIState:       restored,
+		Keys:          cfg.Keymap(),
+		Actions:       cfg.PeerActions(),
+		Federation:    federation,
+		Anonymizer:    cfg.Anonymizer(),
+		Privacy:       cfg.Privacy,
+		Hostnames:     resolvePTR || resolveMDNS,
+	})
 	p := tea.NewProgram(m, tea.WithAltScreen())
 
 	// Run blocks until the user quits (Ctrl+C or 'q').
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "TUI error: %v\n", err)
 		cancel()
 		os.Exit(1)
 	}
+	if fm, ok := final.(lib.Model); ok && *uiState != "" {
+		if err := lib.SaveUIState(*uiState, fm.UIState()); err != nil {
+			logger.Warn("could not save ui state", "err", err)
+		}
+	}
 
-	// TUI exited normally; shut down the listener.
+	// TUI exited normally; shut down the listener and write the last history sample and state.
 	cancel()
-	if err := <-listenerErrCh; err != nil && ctx.Err() == nil {
-		logger.Error("listener error", "err", err)
-		os.Exit(1)
+	<-historyDone
+	<-stateDone
+	<-shadowDone
+	for range listenIfaces {
+		if err := <-listenerErrCh; err != nil && ctx.Err() == nil {
+			logger.Error("listener error", "err", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// runExport implements "ndpeekr export": dump history database rows for a
+// time range as CSV files, one per table.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	var (
+		dbPath  = fs.String("db", "", "History database (history.path in the config file)")
+		from    = fs.String("from", "24h", "Start of range: RFC 3339, YYYY-MM-DD[ HH:MM], or a duration ago")
+		to      = fs.String("to", "0s", "End of range, same formats as --from")
+		format  = fs.String("format", "csv", "Output format: csv")
+		table   = fs.String("table", "all", "Table to export: peers|routers|alerts|counters|all")
+		out     = fs.String("out", ".", "Output directory for <table>.csv, or - for stdout (single table only)")
+		anon    = fs.String("anonymize", "", "Anonymize addresses and MACs: hash (consistent keyed hashes) or truncate (keep the /48 and OUI)")
+		anonKey = fs.String("anonymize-key", "", "Key for --anonymize hash; reuse it to make separate exports match up (default random)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dbPath == "" {
+		return fmt.Errorf("--db is required")
+	}
+	if *format != "csv" {
+		return fmt.Errorf("unsupported --format %q: want csv", *format)
+	}
+	var anonymizer *lib.Anonymizer
+	if *anon != "" {
+		a, err := lib.NewAnonymizer(*anon, *anonKey)
+		if err != nil {
+			return fmt.Errorf("--anonymize: %w", err)
+		}
+		anonymizer = a
+	}
+
+	now := time.Now()
+	start, err := lib.ParseExportTime(*from, now)
+	if err != nil {
+		return fmt.Errorf("--from: %w", err)
+	}
+	end, err := lib.ParseExportTime(*to, now)
+	if err != nil {
+		return fmt.Errorf("--to: %w", err)
+	}
+
+	tables := lib.ExportTables
+	if *table != "all" {
+		tables = []string{*table}
+	}
+	if *out == "-" && len(tables) != 1 {
+		return fmt.Errorf("--out - needs a single --table")
+	}
+
+	db, err := lib.OpenHistoryDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *out == "-" {
+		return db.Export(os.Stdout, tables[0], start, end, anonymizer)
+	}
+	for _, t := range tables {
+		path := filepath.Join(*out, t+".csv")
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := db.Export(f, t, start, end, anonymizer); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "wrote", path)
+	}
+	return nil
+}
+
+// runImport implements "ndpeekr import": replay pcap files into a history
+// database at their capture times, so past captures can be browsed and
+// exported like live history.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ndpeekr import --db PATH [flags] FILE.pcap...")
+		fs.PrintDefaults()
+	}
+	var (
+		cfgPath  = fs.String("config", "", "Config file whose privacy section (and history.path, if --db is missing) applies")
+		dbPath   = fs.String("db", "", "History database (history.path in the config file), created if missing")
+		window   = fs.Duration("window", 15*time.Minute, "Sliding window to replay the captures with, as --window live")
+		interval = fs.Duration("interval", time.Minute, "Capture time between samples, as history.interval live")
+		mirror   = fs.Bool("mirror", false, "Ethernet captures were taken on a SPAN or mirror port (see --mirror)")
+	)
+	// Flags may come after the files too: ndpeekr import *.pcap --db x.db
+	var files []string
+	for rest := args; ; rest = fs.Args()[1:] {
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+	}
+	cfg := &lib.Config{}
+	if *cfgPath != "" {
+		var err error
+		if cfg, err = lib.LoadConfig(*cfgPath); err != nil {
+			return err
+		}
+		if *dbPath == "" && cfg.History != nil {
+			*dbPath = cfg.History.Path
+		}
+	}
+	if *dbPath == "" || len(files) == 0 {
+		fs.Usage()
+		return fmt.Errorf("--db and at least one pcap file are required")
+	}
+
+	// A running instance recording into the same database holds its lock.
+	lock, err := lib.LockFile(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	db, err := lib.OpenHistoryDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	res, err := lib.ImportPcaps(ctx, lib.ImportConfig{
+		DB:    db,
+		Files: files,
+		// Alerts go to the database; only failures are worth printing.
+		Logger:   slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})),
+		Window:   *window,
+		Interval: *interval,
+		Mirror:   *mirror,
+		Privacy:  cfg.Privacy,
+	})
+	if err != nil {
+		return err
+	}
+	if res.Samples == 0 {
+		fmt.Fprintf(os.Stderr, "no packets in %d files\n", len(files))
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "imported %d packets from %d files as %d samples, %s to %s\n",
+		res.Packets, res.Files, res.Samples, res.First.Format(time.DateTime), res.Last.Format(time.DateTime))
+	return nil
+}
+
+// runGenpcap implements "ndpeekr genpcap": write the synthetic NDP/MLD
+// fixture corpus as pcap files.
+func runGenpcap(args []string) error {
+	fs := flag.NewFlagSet("genpcap", flag.ContinueOnError)
+	var (
+		out  = fs.String("out", ".", "Output directory, created if missing")
+		link = fs.String("link", "raw", "Link type: raw (IPv6 packets) or ethernet (Ethernet II frames)")
+		only = fs.String("only", "", "Comma-separated fixture name patterns (e.g. 'ra-*,ns-dad'); default all")
+		list = fs.Bool("list", false, "List the fixtures and exit")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *link != "raw" && *link != "ethernet" {
+		return fmt.Errorf("unsupported --link %q: want raw or ethernet", *link)
+	}
+	var patterns []string
+	if *only != "" {
+		patterns = strings.Split(*only, ",")
+	}
+
+	if *list {
+		fixtures, err := lib.SelectFixtures(patterns)
+		if err != nil {
+			return err
+		}
+		for _, f := range fixtures {
+			kind := "valid"
+			if f.Malformed {
+				kind = "malformed"
+			}
+			fmt.Printf("%-24s %-9s %s\n", f.Name, kind, f.Description)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	paths, err := lib.WriteFixturePcaps(lib.GenPcapConfig{Dir: *out, Ethernet: *link == "ethernet", Only: patterns})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d files to %s\n", len(paths), *out)
+	return nil
+}
+
+// runHealthcheck implements "ndpeekr healthcheck": query a running
+// instance's /healthz, for container images without curl or wget.
+func runHealthcheck(args []string) error {
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	var (
+		url     = fs.String("url", "http://127.0.0.1:9312/healthz", "Health endpoint of the running instance (--health-listen)")
+		timeout = fs.Duration("timeout", 3*time.Second, "Give up after this long")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Get(*url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// runCtl implements "ndpeekr ctl": manage a running instance through its
+// --control-socket, so headless instances need no HTTP API for it.
+func runCtl(args []string) error {
+	fs := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ndpeekr ctl --socket PATH <command> [flags]")
+		fmt.Fprintln(fs.Output(), "commands: status, peers [--filter EXPR], routers, ack-alert [--category C] [--source ADDR], set-log-level LEVEL, save-snapshot, call METHOD [PARAMS]")
+		fs.PrintDefaults()
+	}
+	var (
+		socket  = fs.String("socket", "", "Control socket of the running instance (its --control-socket)")
+		timeout = fs.Duration("timeout", 5*time.Second, "Give up after this long")
+		asJSON  = fs.Bool("json", false, "Print the instance's JSON answer instead of a table")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *socket == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("--socket and a command are required")
+	}
+	client := lib.NewControlClient(*socket, *timeout)
+	ctx := context.Background()
+
+	cmd := flag.NewFlagSet("ctl "+fs.Arg(0), flag.ContinueOnError)
+	var (
+		filter   = cmd.String("filter", "", "Only peers matching this filter expression (peers)")
+		category = cmd.String("category", "", "Only alerts of this category; default any (ack-alert)")
+		source   = cmd.String("source", "", "Only alerts about this address; default any (ack-alert)")
+	)
+	if err := cmd.Parse(fs.Args()[1:]); err != nil {
+		return err
+	}
+
+	var out any
+	switch fs.Arg(0) {
+	case "status":
+		var st lib.Status
+		if err := client.Call(ctx, "status", nil, &st); err != nil {
+			return err
+		}
+		if out = st; !*asJSON {
+			input := st.Interface
+			if st.PcapFile != "" {
+				input = st.PcapFile
+			}
+			fmt.Printf("version  %s (%s)\n", st.Build.Version, st.Build.GoVersion)
+			fmt.Printf("uptime   %s\n", st.Uptime)
+			fmt.Printf("capture  %s %s\n", st.Capture, input)
+			fmt.Printf("window   %s\n", st.Window)
+			fmt.Printf("sinks    %s\n", strings.Join(st.Sinks, ", "))
+			return nil
+		}
+	case "peers":
+		var peers []lib.PeerSummary
+		if err := client.Call(ctx, "peers", map[string]string{"filter": *filter}, &peers); err != nil {
+			return err
+		}
+		if out = peers; !*asJSON {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ADDRESS\tMAC\tMESSAGES\tLAST SEEN")
+			for _, p := range peers {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", p.Address, p.MAC, p.Total, p.LastSeen.Format(time.DateTime))
+			}
+			return w.Flush()
+		}
+	case "routers":
+		var routers []lib.RouterInfo
+		if err := client.Call(ctx, "routers", nil, &routers); err != nil {
+			return err
+		}
+		if out = routers; !*asJSON {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ADDRESS\tINTERFACE\tLIFETIME\tPREFIXES")
+			for _, r := range routers {
+				prefixes := make([]string, len(r.Prefixes))
+				for i, p := range r.Prefixes {
+					prefixes[i] = p.Prefix
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Address, r.Interface, r.Lifetime, strings.Join(prefixes, ","))
+			}
+			return w.Flush()
+		}
+	case "ack-alert":
+		var res struct {
+			Acked int `json:"acked"`
+		}
+		req := map[string]string{"category": *category, "source": *source}
+		if err := client.Call(ctx, "ack_alerts", req, &res); err != nil {
+			return err
+		}
+		if out = res; !*asJSON {
+			fmt.Printf("acknowledged %d alerts\n", res.Acked)
+			return nil
+		}
+	case "set-log-level":
+		if cmd.NArg() != 1 {
+			return fmt.Errorf("set-log-level needs a level: debug, info, warn or error")
+		}
+		var res struct {
+			Level string `json:"level"`
+		}
+		if err := client.Call(ctx, "set_log_level", map[string]string{"level": cmd.Arg(0)}, &res); err != nil {
+			return err
+		}
+		if out = res; !*asJSON {
+			fmt.Printf("log level %s\n", res.Level)
+			return nil
+		}
+	case "save-snapshot":
+		var res struct {
+			Path string `json:"path"`
+		}
+		if err := client.Call(ctx, "save_snapshot", nil, &res); err != nil {
+			return err
+		}
+		if out = res; !*asJSON {
+			fmt.Println(res.Path)
+			return nil
+		}
+	case "call":
+		// Any method, for what has no command of its own; the answer is
+		// printed as JSON.
+		if cmd.NArg() < 1 || cmd.NArg() > 2 {
+			return fmt.Errorf("call needs a method and optionally its params as JSON")
+		}
+		var params any
+		if cmd.NArg() == 2 {
+			if err := json.Unmarshal([]byte(cmd.Arg(1)), &params); err != nil {
+				return fmt.Errorf("params: %w", err)
+			}
+		}
+		var res json.RawMessage
+		if err := client.Call(ctx, cmd.Arg(0), params, &res); err != nil {
+			return err
+		}
+		out = res
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", fs.Arg(0))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// splitInterfaces splits the comma-separated --iface list.
+func splitInterfaces(s string) []string {
+	var ifaces []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			ifaces = append(ifaces, name)
+		}
+	}
+	return ifaces
+}
+
+// isTerminal reports whether f is a terminal; without one, such as in a
+// container without a TTY, ndpeekr runs headless.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// inputLabel names a capture input for the Compare tab.
+func inputLabel(iface, pcap string) string {
+	switch {
+	case pcap != "":
+		return filepath.Base(pcap)
+	case iface != "":
+		return iface
+	default:
+		return "all interfaces"
 	}
 }
 